@@ -0,0 +1,241 @@
+package accesslog
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Sink types selectable in configuration
+const (
+	SinkNone   = "none"
+	SinkStdout = "stdout"
+	SinkFile   = "file"
+	SinkSyslog = "syslog"
+	SinkHTTP   = "http"
+)
+
+// Entry is a single access log record
+type Entry struct {
+	Time       time.Time `json:"time"`
+	Method     string    `json:"method"`
+	Host       string    `json:"host"`
+	Path       string    `json:"path"`
+	Query      string    `json:"query,omitempty"`
+	Proto      string    `json:"proto"`
+	Status     int       `json:"status"`
+	Bytes      int64     `json:"bytes"`
+	DurationMs float64   `json:"durationMs"`
+	ClientIP   string    `json:"clientIp"`
+	UserAgent  string    `json:"userAgent,omitempty"`
+	Referer    string    `json:"referer,omitempty"`
+	Backend    string    `json:"backend,omitempty"`
+	RequestID  string    `json:"requestId,omitempty"`
+}
+
+// Sink receives access log entries
+type Sink interface {
+	// Write records a single entry
+	Write(e *Entry) error
+	// Close flushes pending entries and releases resources
+	Close() error
+}
+
+// Config selects and configures an access log sink
+type Config struct {
+	Sink   string `json:"sink"`             // none, stdout, file, syslog, http
+	Format string `json:"format,omitempty"` // json (default), common, combined; http always ships JSON
+
+	// File sink
+	Path       string `json:"path"`
+	MaxSizeMB  int    `json:"maxSizeMB"`
+	MaxBackups int    `json:"maxBackups"`
+
+	// Syslog sink
+	SyslogNetwork string `json:"syslogNetwork"` // "", udp, tcp, unix
+	SyslogAddress string `json:"syslogAddress"` // empty uses the local syslog daemon
+	SyslogTag     string `json:"syslogTag"`
+
+	// HTTP/ndjson shipper
+	URL           string        `json:"url"`
+	BatchSize     int           `json:"batchSize"`
+	FlushInterval time.Duration `json:"flushInterval"`
+	BufferSize    int           `json:"bufferSize"`
+}
+
+// NewSink creates the sink described by cfg; a nil sink is returned for "none"
+func NewSink(cfg Config) (Sink, error) {
+	encode, err := newEncoder(cfg.Format)
+	if err != nil {
+		return nil, err
+	}
+	switch strings.ToLower(cfg.Sink) {
+	case "", SinkNone:
+		return nil, nil
+	case SinkStdout:
+		s := NewWriterSink(os.Stdout)
+		s.encode = encode
+		return s, nil
+	case SinkFile:
+		s, err := NewFileSink(cfg.Path, cfg.MaxSizeMB, cfg.MaxBackups)
+		if err != nil {
+			return nil, err
+		}
+		s.encode = encode
+		return s, nil
+	case SinkSyslog:
+		s, err := NewSyslogSink(cfg.SyslogNetwork, cfg.SyslogAddress, cfg.SyslogTag)
+		if err != nil {
+			return nil, err
+		}
+		s.encode = encode
+		return s, nil
+	case SinkHTTP:
+		s, err := NewHTTPSink(cfg.URL, cfg.BatchSize, cfg.FlushInterval, cfg.BufferSize)
+		if err != nil {
+			return nil, err
+		}
+		return s, nil
+	default:
+		return nil, fmt.Errorf("unknown access log sink: %s", cfg.Sink)
+	}
+}
+
+// WriterSink writes entries as lines to an io.Writer, JSON unless the
+// Config selects another format
+type WriterSink struct {
+	mu     sync.Mutex
+	w      io.Writer
+	encode encoder
+}
+
+// NewWriterSink creates a sink writing JSON lines to w
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{w: w, encode: encodeJSON}
+}
+
+// Write encodes the entry as a single line
+func (s *WriterSink) Write(e *Entry) error {
+	line, err := s.encode(e)
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err = s.w.Write(line)
+	return err
+}
+
+// Close is a no-op; the underlying writer is owned by the caller
+func (s *WriterSink) Close() error {
+	return nil
+}
+
+type contextKey struct{}
+
+// annotations holds values filled in by handlers further down the chain
+type annotations struct {
+	mu      sync.Mutex
+	backend string
+}
+
+// SetBackend records the backend that served the request, if access logging is active
+func SetBackend(ctx context.Context, backend string) {
+	if a, ok := ctx.Value(contextKey{}).(*annotations); ok {
+		a.mu.Lock()
+		a.backend = backend
+		a.mu.Unlock()
+	}
+}
+
+// Middleware records an access log entry for every request into sink
+func Middleware(sink Sink) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		if sink == nil {
+			return next
+		}
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			a := &annotations{}
+			r = r.WithContext(context.WithValue(r.Context(), contextKey{}, a))
+
+			rw := &responseWriter{ResponseWriter: w, statusCode: http.StatusOK}
+			next.ServeHTTP(rw, r)
+
+			a.mu.Lock()
+			backend := a.backend
+			a.mu.Unlock()
+
+			entry := &Entry{
+				Time:       start,
+				Method:     r.Method,
+				Host:       r.Host,
+				Path:       r.URL.Path,
+				Query:      r.URL.RawQuery,
+				Proto:      r.Proto,
+				Status:     rw.statusCode,
+				Bytes:      rw.bytes,
+				DurationMs: float64(time.Since(start).Microseconds()) / 1000,
+				ClientIP:   clientIP(r),
+				UserAgent:  r.UserAgent(),
+				Referer:    r.Referer(),
+				Backend:    backend,
+				RequestID:  logging.RequestID(r.Context()),
+			}
+			if err := sink.Write(entry); err != nil {
+				logging.Logger().Error("failed to write access log entry", "error", err)
+			}
+		})
+	}
+}
+
+// responseWriter wraps http.ResponseWriter to capture status code and size
+type responseWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	bytes       int64
+	wroteHeader bool
+}
+
+func (rw *responseWriter) WriteHeader(code int) {
+	if !rw.wroteHeader {
+		rw.statusCode = code
+		rw.wroteHeader = true
+	}
+	rw.ResponseWriter.WriteHeader(code)
+}
+
+func (rw *responseWriter) Write(p []byte) (int, error) {
+	rw.wroteHeader = true
+	n, err := rw.ResponseWriter.Write(p)
+	rw.bytes += int64(n)
+	return n, err
+}
+
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
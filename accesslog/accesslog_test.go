@@ -0,0 +1,171 @@
+package accesslog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMiddleware_WritesEntry(t *testing.T) {
+	var buf bytes.Buffer
+	sink := NewWriterSink(&buf)
+
+	handler := Middleware(sink)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		SetBackend(r.Context(), "http://backend:8081")
+		w.WriteHeader(http.StatusTeapot)
+		w.Write([]byte("short and stout"))
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/pot?brew=1", nil)
+	req.RemoteAddr = "10.0.0.1:5555"
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	var e Entry
+	if err := json.Unmarshal(buf.Bytes(), &e); err != nil {
+		t.Fatalf("Failed to decode entry: %v", err)
+	}
+	if e.Status != http.StatusTeapot {
+		t.Errorf("Expected status %d, got %d", http.StatusTeapot, e.Status)
+	}
+	if e.Bytes != int64(len("short and stout")) {
+		t.Errorf("Expected %d bytes, got %d", len("short and stout"), e.Bytes)
+	}
+	if e.Path != "/pot" || e.Query != "brew=1" {
+		t.Errorf("Unexpected path/query: %s ? %s", e.Path, e.Query)
+	}
+	if e.ClientIP != "10.0.0.1" {
+		t.Errorf("Expected client IP 10.0.0.1, got %s", e.ClientIP)
+	}
+	if e.Backend != "http://backend:8081" {
+		t.Errorf("Expected backend annotation, got %q", e.Backend)
+	}
+}
+
+func TestNewSink(t *testing.T) {
+	if s, err := NewSink(Config{Sink: SinkNone}); err != nil || s != nil {
+		t.Errorf("NewSink(none) = %v, %v; want nil, nil", s, err)
+	}
+	if _, err := NewSink(Config{Sink: "carrier-pigeon"}); err == nil {
+		t.Error("NewSink should reject unknown sink types")
+	}
+	if _, err := NewSink(Config{Sink: SinkFile}); err == nil {
+		t.Error("NewSink(file) should require a path")
+	}
+	if _, err := NewSink(Config{Sink: SinkHTTP}); err == nil {
+		t.Error("NewSink(http) should require a url")
+	}
+	if _, err := NewSink(Config{Sink: SinkStdout, Format: "apache"}); err == nil {
+		t.Error("NewSink should reject unknown formats")
+	}
+}
+
+func TestEncoder_Formats(t *testing.T) {
+	e := &Entry{
+		Time:      time.Date(2024, time.March, 5, 14, 7, 9, 0, time.FixedZone("", -7*3600)),
+		Method:    "GET",
+		Path:      "/say \"hi\"\n",
+		Query:     "q=1",
+		Proto:     "HTTP/1.1",
+		Status:    200,
+		Bytes:     512,
+		ClientIP:  "10.0.0.1",
+		UserAgent: "curl/8.0",
+	}
+	tests := []struct {
+		format string
+		want   string
+	}{
+		{FormatCommon, `10.0.0.1 - - [05/Mar/2024:14:07:09 -0700] "GET /say \x22hi\x22\x0a?q=1 HTTP/1.1" 200 512`},
+		{FormatCombined, `10.0.0.1 - - [05/Mar/2024:14:07:09 -0700] "GET /say \x22hi\x22\x0a?q=1 HTTP/1.1" 200 512 "-" "curl/8.0"`},
+	}
+	for _, tt := range tests {
+		encode, err := newEncoder(tt.format)
+		if err != nil {
+			t.Fatalf("newEncoder(%s) error = %v", tt.format, err)
+		}
+		line, _ := encode(e)
+		if string(line) != tt.want {
+			t.Errorf("%s:\n got %s\nwant %s", tt.format, line, tt.want)
+		}
+	}
+
+	e.Bytes = 0
+	encode, _ := newEncoder(FormatCommon)
+	if line, _ := encode(e); !strings.HasSuffix(string(line), " 200 -") {
+		t.Errorf("Expected an empty body logged as -, got %s", line)
+	}
+}
+
+func TestFileSink_Rotates(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "access.log")
+	sink, err := NewFileSink(path, 1, 2)
+	if err != nil {
+		t.Fatalf("NewFileSink() error = %v", err)
+	}
+	defer sink.Close()
+
+	// Shrink the limit so a handful of entries triggers rotation
+	sink.maxBytes = 300
+
+	for i := 0; i < 10; i++ {
+		if err := sink.Write(&Entry{Method: "GET", Path: "/rotate", Status: 200}); err != nil {
+			t.Fatalf("Write() error = %v", err)
+		}
+	}
+
+	for _, name := range []string{path, path + ".1", path + ".2"} {
+		info, err := os.Stat(name)
+		if err != nil {
+			t.Fatalf("Expected %s to exist: %v", name, err)
+		}
+		if info.Size() > 300 {
+			t.Errorf("%s exceeds size limit: %d bytes", name, info.Size())
+		}
+	}
+	if _, err := os.Stat(path + ".3"); !os.IsNotExist(err) {
+		t.Error("Backups beyond maxBackups should be removed")
+	}
+}
+
+func TestHTTPSink_ShipsNDJSON(t *testing.T) {
+	var mu sync.Mutex
+	var lines []string
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ct := r.Header.Get("Content-Type"); ct != "application/x-ndjson" {
+			t.Errorf("Unexpected content type %q", ct)
+		}
+		body, _ := io.ReadAll(r.Body)
+		scanner := bufio.NewScanner(strings.NewReader(string(body)))
+		mu.Lock()
+		for scanner.Scan() {
+			lines = append(lines, scanner.Text())
+		}
+		mu.Unlock()
+	}))
+	defer server.Close()
+
+	sink, err := NewHTTPSink(server.URL, 2, time.Hour, 10)
+	if err != nil {
+		t.Fatalf("NewHTTPSink() error = %v", err)
+	}
+	for i := 0; i < 3; i++ {
+		sink.Write(&Entry{Method: "GET", Path: "/ship"})
+	}
+	sink.Close()
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(lines) != 3 {
+		t.Errorf("Expected 3 shipped lines, got %d", len(lines))
+	}
+}
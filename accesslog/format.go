@@ -0,0 +1,100 @@
+package accesslog
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Line formats of the stdout, file and syslog sinks; the http shipper
+// always sends JSON
+const (
+	FormatJSON     = "json"
+	FormatCommon   = "common"
+	FormatCombined = "combined"
+)
+
+// clfTime is the timestamp layout of the Common Log Format
+const clfTime = "02/Jan/2006:15:04:05 -0700"
+
+// encoder turns an entry into a single line without its newline
+type encoder func(e *Entry) ([]byte, error)
+
+// newEncoder returns the encoder of format; empty selects JSON
+func newEncoder(format string) (encoder, error) {
+	switch strings.ToLower(format) {
+	case "", FormatJSON:
+		return encodeJSON, nil
+	case FormatCommon:
+		return func(e *Entry) ([]byte, error) { return e.appendCommon(nil), nil }, nil
+	case FormatCombined:
+		return func(e *Entry) ([]byte, error) { return e.appendCombined(nil), nil }, nil
+	default:
+		return nil, fmt.Errorf("unknown access log format: %s", format)
+	}
+}
+
+func encodeJSON(e *Entry) ([]byte, error) {
+	return json.Marshal(e)
+}
+
+// appendCommon appends the entry in Common Log Format:
+//
+//	10.0.0.1 - - [02/Jan/2006:15:04:05 -0700] "GET /path?q=1 HTTP/1.1" 200 512
+func (e *Entry) appendCommon(b []byte) []byte {
+	b = append(b, clfField(e.ClientIP)...)
+	b = append(b, " - - ["...)
+	b = e.Time.AppendFormat(b, clfTime)
+	b = append(b, "] \""...)
+	b = append(b, e.Method...)
+	b = append(b, ' ')
+	b = appendEscaped(b, e.Path)
+	if e.Query != "" {
+		b = append(b, '?')
+		b = appendEscaped(b, e.Query)
+	}
+	b = append(b, ' ')
+	b = append(b, e.Proto...)
+	b = append(b, "\" "...)
+	b = strconv.AppendInt(b, int64(e.Status), 10)
+	b = append(b, ' ')
+	if e.Bytes > 0 {
+		return strconv.AppendInt(b, e.Bytes, 10)
+	}
+	return append(b, '-')
+}
+
+// appendCombined appends the entry in Combined Log Format, the Common Log
+// Format followed by the quoted referer and user agent
+func (e *Entry) appendCombined(b []byte) []byte {
+	b = e.appendCommon(b)
+	b = append(b, " \""...)
+	b = appendEscaped(b, clfField(e.Referer))
+	b = append(b, "\" \""...)
+	b = appendEscaped(b, clfField(e.UserAgent))
+	return append(b, '"')
+}
+
+// appendEscaped appends s with quotes, backslashes and control characters
+// escaped as \xHH like Apache does, so a request can't break the line
+func appendEscaped(b []byte, s string) []byte {
+	const hex = "0123456789abcdef"
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c < 0x20 || c == 0x7f || c == '"' || c == '\\' {
+			b = append(b, '\\', 'x', hex[c>>4], hex[c&0xf])
+			continue
+		}
+		b = append(b, c)
+	}
+	return b
+}
+
+// clfField returns "-" for an empty field
+func clfField(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
@@ -0,0 +1,132 @@
+package accesslog
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Defaults for the HTTP shipper
+const (
+	DefaultBatchSize     = 100
+	DefaultFlushInterval = 5 * time.Second
+	DefaultBufferSize    = 10000
+)
+
+// HTTPSink ships entries as newline-delimited JSON batches to an HTTP endpoint
+type HTTPSink struct {
+	url           string
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	entries       chan *Entry
+	done          chan struct{}
+	closeOnce     sync.Once
+	dropped       int64
+}
+
+// NewHTTPSink starts a background shipper posting batches to url
+func NewHTTPSink(url string, batchSize int, flushInterval time.Duration, bufferSize int) (*HTTPSink, error) {
+	if url == "" {
+		return nil, fmt.Errorf("http sink requires a url")
+	}
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+	if flushInterval <= 0 {
+		flushInterval = DefaultFlushInterval
+	}
+	if bufferSize <= 0 {
+		bufferSize = DefaultBufferSize
+	}
+
+	s := &HTTPSink{
+		url:           url,
+		batchSize:     batchSize,
+		flushInterval: flushInterval,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		entries:       make(chan *Entry, bufferSize),
+		done:          make(chan struct{}),
+	}
+	go s.run()
+	return s, nil
+}
+
+// Write queues the entry for shipping; entries are dropped when the buffer is full
+func (s *HTTPSink) Write(e *Entry) error {
+	select {
+	case s.entries <- e:
+		return nil
+	default:
+		atomic.AddInt64(&s.dropped, 1)
+		return fmt.Errorf("access log buffer full, entry dropped")
+	}
+}
+
+// Dropped returns the number of entries dropped because the buffer was full
+func (s *HTTPSink) Dropped() int64 {
+	return atomic.LoadInt64(&s.dropped)
+}
+
+// Close flushes queued entries and stops the shipper
+func (s *HTTPSink) Close() error {
+	s.closeOnce.Do(func() {
+		close(s.entries)
+		<-s.done
+	})
+	return nil
+}
+
+func (s *HTTPSink) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Entry, 0, s.batchSize)
+	for {
+		select {
+		case e, ok := <-s.entries:
+			if !ok {
+				s.ship(batch)
+				return
+			}
+			batch = append(batch, e)
+			if len(batch) >= s.batchSize {
+				s.ship(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			s.ship(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (s *HTTPSink) ship(batch []*Entry) {
+	if len(batch) == 0 {
+		return
+	}
+
+	var buf bytes.Buffer
+	enc := json.NewEncoder(&buf)
+	for _, e := range batch {
+		enc.Encode(e)
+	}
+
+	resp, err := s.client.Post(s.url, "application/x-ndjson", &buf)
+	if err != nil {
+		logging.Logger().Warn("failed to ship access log entries", "entries", len(batch), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logging.Logger().Warn("access log shipper endpoint refused entries", "status", resp.StatusCode)
+	}
+}
@@ -0,0 +1,41 @@
+//go:build !windows && !plan9
+
+package accesslog
+
+import (
+	"fmt"
+	"log/syslog"
+)
+
+// SyslogSink sends entries to syslog at INFO priority, JSON encoded unless
+// the Config selects another format
+type SyslogSink struct {
+	w      *syslog.Writer
+	encode encoder
+}
+
+// NewSyslogSink connects to syslog; an empty network and address use the local daemon
+func NewSyslogSink(network, address, tag string) (*SyslogSink, error) {
+	if tag == "" {
+		tag = "go-balancer"
+	}
+	w, err := syslog.Dial(network, address, syslog.LOG_INFO|syslog.LOG_LOCAL0, tag)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to syslog: %w", err)
+	}
+	return &SyslogSink{w: w, encode: encodeJSON}, nil
+}
+
+// Write sends the entry as a single syslog message
+func (s *SyslogSink) Write(e *Entry) error {
+	line, err := s.encode(e)
+	if err != nil {
+		return err
+	}
+	return s.w.Info(string(line))
+}
+
+// Close closes the syslog connection
+func (s *SyslogSink) Close() error {
+	return s.w.Close()
+}
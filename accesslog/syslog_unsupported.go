@@ -0,0 +1,25 @@
+//go:build windows || plan9
+
+package accesslog
+
+import "fmt"
+
+// SyslogSink is not supported on this platform
+type SyslogSink struct {
+	encode encoder
+}
+
+// NewSyslogSink is not supported on this platform
+func NewSyslogSink(network, address, tag string) (*SyslogSink, error) {
+	return nil, fmt.Errorf("syslog sink is not supported on this platform")
+}
+
+// Write is never reached; NewSyslogSink always fails
+func (s *SyslogSink) Write(e *Entry) error {
+	return fmt.Errorf("syslog sink is not supported on this platform")
+}
+
+// Close is a no-op
+func (s *SyslogSink) Close() error {
+	return nil
+}
@@ -0,0 +1,548 @@
+// Package admin implements the authenticated admin REST API used to change
+// the load balancer at runtime
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/internal/jsonconf"
+	"github.com/TaiTitans/go-balancer/middleware"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+// Options configures the admin API
+type Options struct {
+	LoadBalancer *balancer.LoadBalancer
+	// Token is the bearer token required on every request
+	Token string
+	// Audit records mutating requests (optional)
+	Audit *audit.Log
+	// NewStrategy builds a strategy by name for PUT /admin/strategy
+	NewStrategy func(name string) (strategy.Strategy, error)
+	// Reload re-reads and applies the config; POST /admin/reload answers
+	// 501 when nil
+	Reload func() error
+	// Features holds the feature flags served at /admin/flags (optional)
+	Features *features.Registry
+	// Schedules holds the scheduled weight changes served at
+	// /admin/schedules (optional)
+	Schedules *schedule.Scheduler
+	// Chaos is the fault injector served at /admin/chaos (optional; fault
+	// injection is off when nil)
+	Chaos *chaos.Injector
+	// BlueGreen switches traffic between the blue and green pools served at
+	// /admin/bluegreen (optional)
+	BlueGreen *router.Switch
+}
+
+// Server routes the admin API; additional admin handlers (audit log, debug
+// tap, config history...) are mounted with Handle
+type Server struct {
+	opts Options
+	mux  *http.ServeMux
+}
+
+// BackendStatus is the admin view of a backend
+type BackendStatus struct {
+	URL         string            `json:"url"`
+	Alive       bool              `json:"alive"`
+	Enabled     bool              `json:"enabled"`
+	Draining    bool              `json:"draining"`
+	Weight      int               `json:"weight"`
+	Backup      bool              `json:"backup"`
+	Connections int               `json:"connections"`
+	InFlight    int               `json:"inFlight"`
+	FailCount   int               `json:"failCount"`
+	Labels      map[string]string `json:"labels,omitempty"`
+}
+
+// ImportResult answers a state import
+type ImportResult struct {
+	// Restored counts the backends the snapshot was applied to
+	Restored int             `json:"restored"`
+	Backends []BackendStatus `json:"backends"`
+	// Warning lists saved values that were skipped
+	Warning string `json:"warning,omitempty"`
+}
+
+// ChaosStatus is the admin view of fault injection
+type ChaosStatus struct {
+	Enabled bool         `json:"enabled"`
+	Rules   []chaos.Rule `json:"rules"`
+	Stats   chaos.Stats  `json:"stats"`
+}
+
+// New creates the admin API
+func New(opts Options) *Server {
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.mux.HandleFunc("GET /admin/backends", s.listBackends)
+	s.mux.HandleFunc("POST /admin/backends", s.addBackend)
+	s.mux.HandleFunc("DELETE /admin/backends", s.removeBackend)
+	s.mux.HandleFunc("PUT /admin/backends/weight", s.setWeight)
+	s.mux.HandleFunc("PUT /admin/backends/drain", s.setDraining)
+	s.mux.HandleFunc("PUT /admin/backends/enabled", s.setEnabled)
+	s.mux.HandleFunc("GET /admin/strategy", s.getStrategy)
+	s.mux.HandleFunc("PUT /admin/strategy", s.setStrategy)
+	s.mux.HandleFunc("GET /admin/maintenance", s.getMaintenance)
+	s.mux.HandleFunc("PUT /admin/maintenance", s.setMaintenance)
+	s.mux.HandleFunc("POST /admin/reload", s.reload)
+	s.mux.HandleFunc("GET /admin/flags", s.listFlags)
+	s.mux.HandleFunc("PUT /admin/flags", s.setFlag)
+	s.mux.HandleFunc("GET /admin/schedules", s.listSchedules)
+	s.mux.HandleFunc("POST /admin/schedules", s.addSchedule)
+	s.mux.HandleFunc("DELETE /admin/schedules", s.cancelSchedule)
+	s.mux.HandleFunc("GET /admin/state", s.exportState)
+	s.mux.HandleFunc("PUT /admin/state", s.importState)
+	s.mux.HandleFunc("GET /admin/chaos", s.getChaos)
+	s.mux.HandleFunc("PUT /admin/chaos", s.setChaos)
+	s.mux.HandleFunc("DELETE /admin/chaos", s.clearChaos)
+	s.mux.HandleFunc("GET /admin/bluegreen", s.getBlueGreen)
+	s.mux.HandleFunc("PUT /admin/bluegreen", s.setBlueGreen)
+	s.mux.HandleFunc("POST /admin/bluegreen/toggle", s.setBlueGreen)
+	return s
+}
+
+// Handle mounts an additional admin handler
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.mux.Handle(pattern, handler)
+}
+
+// Handler returns the API behind token authentication and audit logging
+func (s *Server) Handler() http.Handler {
+	return middleware.TokenAuth(s.opts.Token)(s.opts.Audit.Middleware(s.mux))
+}
+
+func (s *Server) listBackends(w http.ResponseWriter, r *http.Request) {
+	backends := s.opts.LoadBalancer.GetBackends()
+	out := make([]BackendStatus, 0, len(backends))
+	for _, b := range backends {
+		out = append(out, statusOf(b))
+	}
+	writeJSON(w, http.StatusOK, out)
+}
+
+func (s *Server) addBackend(w http.ResponseWriter, r *http.Request) {
+	var cfg backend.Config
+	if err := json.NewDecoder(r.Body).Decode(&cfg); err != nil {
+		http.Error(w, fmt.Sprintf("invalid backend: %v", err), http.StatusBadRequest)
+		return
+	}
+	if cfg.URL == "" {
+		http.Error(w, "url is required", http.StatusBadRequest)
+		return
+	}
+	if err := s.opts.LoadBalancer.AddBackend(cfg.URL, balancer.WithConfig(cfg)); err != nil {
+		status := http.StatusBadRequest
+		if errors.Is(err, balancer.ErrBackendExists) {
+			status = http.StatusConflict
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	if b := s.find(cfg.URL); b != nil {
+		writeJSON(w, http.StatusCreated, statusOf(b))
+		return
+	}
+	w.WriteHeader(http.StatusCreated)
+}
+
+func (s *Server) removeBackend(w http.ResponseWriter, r *http.Request) {
+	target := s.target(w, r)
+	if target == nil {
+		return
+	}
+	if err := s.opts.LoadBalancer.RemoveBackend(target.GetURL().String()); err != nil {
+		status := http.StatusInternalServerError
+		switch {
+		case errors.Is(err, balancer.ErrLastBackend):
+			status = http.StatusConflict
+		case errors.Is(err, balancer.ErrBackendNotFound):
+			status = http.StatusNotFound
+		}
+		http.Error(w, err.Error(), status)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) setWeight(w http.ResponseWriter, r *http.Request) {
+	target := s.target(w, r)
+	if target == nil {
+		return
+	}
+	var body struct {
+		Weight int `json:"weight"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid body: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := target.SetWeight(body.Weight); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusOf(target))
+}
+
+func (s *Server) setDraining(w http.ResponseWriter, r *http.Request) {
+	target := s.target(w, r)
+	if target == nil {
+		return
+	}
+	var body struct {
+		Draining *bool `json:"draining"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Draining == nil {
+		http.Error(w, `body must be {"draining": true|false}`, http.StatusBadRequest)
+		return
+	}
+	target.SetDraining(*body.Draining)
+	writeJSON(w, http.StatusOK, statusOf(target))
+}
+
+func (s *Server) setEnabled(w http.ResponseWriter, r *http.Request) {
+	target := s.target(w, r)
+	if target == nil {
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, `body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+	if err := s.opts.LoadBalancer.SetBackendEnabled(target.GetURL().String(), *body.Enabled); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	writeJSON(w, http.StatusOK, statusOf(target))
+}
+
+func (s *Server) getStrategy(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]string{"type": s.opts.LoadBalancer.GetStrategy().Name()})
+}
+
+func (s *Server) setStrategy(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Type string `json:"type"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Type == "" {
+		http.Error(w, `body must be {"type": "<strategy>"}`, http.StatusBadRequest)
+		return
+	}
+	if s.opts.NewStrategy == nil {
+		http.Error(w, "strategy switching is not available", http.StatusNotImplemented)
+		return
+	}
+	strat, err := s.opts.NewStrategy(body.Type)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	// Switching to the active strategy would only reset its state
+	previous := s.opts.LoadBalancer.GetStrategy().Name()
+	changed := strat.Name() != previous
+	if changed {
+		s.opts.LoadBalancer.SetStrategyAs(strat, audit.ActorFromRequest(r))
+	}
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"type":     strat.Name(),
+		"previous": previous,
+		"changed":  changed,
+	})
+}
+
+func (s *Server) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": s.opts.LoadBalancer.InMaintenance()})
+}
+
+func (s *Server) setMaintenance(w http.ResponseWriter, r *http.Request) {
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, `body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+	s.opts.LoadBalancer.SetMaintenance(*body.Enabled)
+	writeJSON(w, http.StatusOK, map[string]bool{"enabled": *body.Enabled})
+}
+
+func (s *Server) reload(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Reload == nil {
+		http.Error(w, "no config file to reload", http.StatusNotImplemented)
+		return
+	}
+	if err := s.opts.Reload(); err != nil {
+		http.Error(w, fmt.Sprintf("Reload failed: %v", err), http.StatusUnprocessableEntity)
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"status": "reloaded"})
+}
+
+func (s *Server) listFlags(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Features == nil {
+		writeJSON(w, http.StatusOK, []features.State{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Features.List())
+}
+
+func (s *Server) setFlag(w http.ResponseWriter, r *http.Request) {
+	name := r.URL.Query().Get("name")
+	if name == "" {
+		http.Error(w, "name query parameter is required", http.StatusBadRequest)
+		return
+	}
+	var body struct {
+		Enabled *bool `json:"enabled"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Enabled == nil {
+		http.Error(w, `body must be {"enabled": true|false}`, http.StatusBadRequest)
+		return
+	}
+	if s.opts.Features == nil {
+		http.Error(w, fmt.Sprintf("unknown feature flag %q", name), http.StatusNotFound)
+		return
+	}
+	if err := s.opts.Features.Set(name, *body.Enabled, audit.ActorFromRequest(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	state, _ := s.opts.Features.Get(name)
+	writeJSON(w, http.StatusOK, state)
+}
+
+func (s *Server) listSchedules(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Schedules == nil {
+		writeJSON(w, http.StatusOK, []schedule.Job{})
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.Schedules.List())
+}
+
+func (s *Server) addSchedule(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Schedules == nil {
+		http.Error(w, "scheduling is not available", http.StatusNotImplemented)
+		return
+	}
+	// Decoded like the config file, so durations may be given as "2h"
+	var raw interface{}
+	var cfg schedule.Config
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := jsonconf.Decode(raw, &cfg); err != nil {
+		http.Error(w, fmt.Sprintf("invalid schedule: %v", err), http.StatusBadRequest)
+		return
+	}
+	job, err := s.opts.Schedules.Add(cfg, audit.ActorFromRequest(r))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusCreated, job)
+}
+
+func (s *Server) cancelSchedule(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(r.URL.Query().Get("id"))
+	if err != nil {
+		http.Error(w, "id query parameter must be a number", http.StatusBadRequest)
+		return
+	}
+	if s.opts.Schedules == nil {
+		http.Error(w, fmt.Sprintf("schedule %d not found", id), http.StatusNotFound)
+		return
+	}
+	if err := s.opts.Schedules.Cancel(id, audit.ActorFromRequest(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Server) exportState(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.opts.LoadBalancer.State())
+}
+
+func (s *Server) importState(w http.ResponseWriter, r *http.Request) {
+	replace, err := parseBool(r.URL.Query().Get("replace"))
+	if err != nil {
+		http.Error(w, "replace must be true or false", http.StatusBadRequest)
+		return
+	}
+	var st balancer.State
+	if err := json.NewDecoder(r.Body).Decode(&st); err != nil {
+		http.Error(w, fmt.Sprintf("invalid state: %v", err), http.StatusBadRequest)
+		return
+	}
+	restored, err := s.opts.LoadBalancer.ImportState(st, replace, audit.ActorFromRequest(r))
+	result := ImportResult{Restored: restored}
+	switch {
+	case errors.Is(err, balancer.ErrStateSkipped):
+		result.Warning = err.Error()
+	case err != nil:
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	backends := s.opts.LoadBalancer.GetBackends()
+	result.Backends = make([]BackendStatus, 0, len(backends))
+	for _, b := range backends {
+		result.Backends = append(result.Backends, statusOf(b))
+	}
+	writeJSON(w, http.StatusOK, result)
+}
+
+// parseBool parses an optional boolean query parameter
+func parseBool(v string) (bool, error) {
+	if v == "" {
+		return false, nil
+	}
+	return strconv.ParseBool(v)
+}
+
+func (s *Server) getChaos(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.chaosStatus())
+}
+
+func (s *Server) setChaos(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Chaos == nil {
+		http.Error(w, "fault injection is not enabled (start with -chaos)", http.StatusNotImplemented)
+		return
+	}
+	var raw interface{}
+	var body struct {
+		Rules []chaos.Rule `json:"rules"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&raw); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rules: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := jsonconf.Decode(raw, &body); err != nil {
+		http.Error(w, fmt.Sprintf("invalid rules: %v", err), http.StatusBadRequest)
+		return
+	}
+	if err := s.opts.Chaos.SetRules(body.Rules, audit.ActorFromRequest(r)); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.chaosStatus())
+}
+
+func (s *Server) clearChaos(w http.ResponseWriter, r *http.Request) {
+	if s.opts.Chaos != nil {
+		s.opts.Chaos.SetRules(nil, audit.ActorFromRequest(r))
+	}
+	writeJSON(w, http.StatusOK, s.chaosStatus())
+}
+
+func (s *Server) chaosStatus() ChaosStatus {
+	if s.opts.Chaos == nil {
+		return ChaosStatus{Rules: []chaos.Rule{}}
+	}
+	return ChaosStatus{Enabled: true, Rules: s.opts.Chaos.Rules(), Stats: s.opts.Chaos.Stats()}
+}
+
+func (s *Server) getBlueGreen(w http.ResponseWriter, r *http.Request) {
+	if s.opts.BlueGreen == nil {
+		http.Error(w, errBlueGreenOff, http.StatusNotImplemented)
+		return
+	}
+	writeJSON(w, http.StatusOK, s.opts.BlueGreen.Status())
+}
+
+const errBlueGreenOff = "blue/green switching is not configured (set blueGreen.pools)"
+
+// setBlueGreen activates the pool named in the body, or the other pool for
+// POST /admin/bluegreen/toggle; in-flight requests finish on the old one
+func (s *Server) setBlueGreen(w http.ResponseWriter, r *http.Request) {
+	if s.opts.BlueGreen == nil {
+		http.Error(w, errBlueGreenOff, http.StatusNotImplemented)
+		return
+	}
+	var previous string
+	var err error
+	if r.Method == http.MethodPost {
+		previous, err = s.opts.BlueGreen.Toggle(audit.ActorFromRequest(r))
+	} else {
+		var body struct {
+			Active string `json:"active"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.Active == "" {
+			http.Error(w, `body must be {"active": "<pool>"}`, http.StatusBadRequest)
+			return
+		}
+		previous, err = s.opts.BlueGreen.Set(body.Active, audit.ActorFromRequest(r))
+	}
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	active := s.opts.BlueGreen.Active()
+	writeJSON(w, http.StatusOK, map[string]interface{}{
+		"active":   active,
+		"previous": previous,
+		"changed":  active != previous,
+	})
+}
+
+// target resolves the ?url= backend, answering 400/404 itself when it fails
+func (s *Server) target(w http.ResponseWriter, r *http.Request) *backend.Backend {
+	u := r.URL.Query().Get("url")
+	if u == "" {
+		http.Error(w, "url query parameter is required", http.StatusBadRequest)
+		return nil
+	}
+	b := s.find(u)
+	if b == nil {
+		http.Error(w, "backend not found", http.StatusNotFound)
+	}
+	return b
+}
+
+// find returns the backend with the given URL
+func (s *Server) find(u string) *backend.Backend {
+	for _, b := range s.opts.LoadBalancer.GetBackends() {
+		if b.GetURL().String() == u {
+			return b
+		}
+	}
+	return nil
+}
+
+func statusOf(b *backend.Backend) BackendStatus {
+	return BackendStatus{
+		URL:         b.GetURL().String(),
+		Alive:       b.IsAlive(),
+		Enabled:     !b.IsDisabled(),
+		Draining:    b.IsDraining(),
+		Weight:      b.GetWeight(),
+		Backup:      b.IsBackup(),
+		Connections: b.OpenConnections(),
+		InFlight:    b.InFlight(),
+		FailCount:   b.GetFailCount(),
+		Labels:      b.Labels(),
+	}
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
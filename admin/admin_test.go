@@ -0,0 +1,400 @@
+package admin
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+const testToken = "secret"
+
+func newTestServer(t *testing.T, reload func() error) (*Server, *balancer.LoadBalancer) {
+	t.Helper()
+	auditLog, _ := audit.New("", 0)
+	flags := features.NewRegistry(auditLog)
+	lb, err := balancer.NewLoadBalancer(balancer.Config{
+		BackendURLs:         []string{"http://backend1:80", "http://backend2:80"},
+		Strategy:            strategy.NewRoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+		AuditLog:            auditLog,
+		Features:            flags,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	s := New(Options{
+		LoadBalancer: lb,
+		Token:        testToken,
+		Audit:        auditLog,
+		NewStrategy: func(name string) (strategy.Strategy, error) {
+			if name == "leastconnections" {
+				return strategy.NewLeastConnections(), nil
+			}
+			return nil, fmt.Errorf("unknown strategy: %s", name)
+		},
+		Reload:    reload,
+		Features:  flags,
+		Schedules: schedule.New(lb.GetBackends, auditLog),
+	})
+	return s, lb
+}
+
+func do(s *Server, method, target, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, strings.NewReader(body))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/backends", nil))
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("Expected 401, got %d", rec.Code)
+	}
+}
+
+func TestServer_Backends(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+
+	rec := do(s, http.MethodPost, "/admin/backends", `{"url": "http://backend3:80", "weight": 3}`)
+	if rec.Code != http.StatusCreated {
+		t.Fatalf("Expected 201, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(s, http.MethodPost, "/admin/backends", `{"url": "http://backend3:80"}`); rec.Code != http.StatusConflict {
+		t.Errorf("Expected 409 for a duplicate backend, got %d", rec.Code)
+	}
+
+	rec = do(s, http.MethodGet, "/admin/backends", "")
+	var list []BackendStatus
+	if err := json.Unmarshal(rec.Body.Bytes(), &list); err != nil {
+		t.Fatalf("Failed to decode backends: %v", err)
+	}
+	if len(list) != 3 || list[2].URL != "http://backend3:80" || list[2].Weight != 3 {
+		t.Errorf("Expected backend3 with weight 3 to be listed, got %+v", list)
+	}
+
+	if rec := do(s, http.MethodDelete, "/admin/backends?url=http://backend1:80", ""); rec.Code != http.StatusNoContent {
+		t.Errorf("Expected 204, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if len(lb.GetBackends()) != 2 {
+		t.Errorf("Expected 2 backends after removal, got %d", len(lb.GetBackends()))
+	}
+	if rec := do(s, http.MethodDelete, "/admin/backends?url=http://missing:80", ""); rec.Code != http.StatusNotFound {
+		t.Errorf("Expected 404, got %d", rec.Code)
+	}
+}
+
+func TestServer_WeightAndDrain(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+	target := "?url=http://backend1:80"
+
+	if rec := do(s, http.MethodPut, "/admin/backends/weight"+target, `{"weight": 5}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if w := lb.GetBackends()[0].GetWeight(); w != 5 {
+		t.Errorf("Expected weight 5, got %d", w)
+	}
+	if rec := do(s, http.MethodPut, "/admin/backends/weight"+target, `{"weight": 0}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for weight 0, got %d", rec.Code)
+	}
+
+	if rec := do(s, http.MethodPut, "/admin/backends/drain"+target, `{"draining": true}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if b := lb.GetBackends()[0]; !b.IsDraining() || b.IsAvailable() {
+		t.Error("Expected backend1 to be draining and unavailable")
+	}
+	if rec := do(s, http.MethodPut, "/admin/backends/drain"+target, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without draining field, got %d", rec.Code)
+	}
+}
+
+func TestServer_Enabled(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+	target := "?url=http://backend1:80"
+
+	rec := do(s, http.MethodPut, "/admin/backends/enabled"+target, `{"enabled": false}`)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	var status BackendStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.Enabled || !lb.GetBackends()[0].IsDisabled() {
+		t.Errorf("Expected backend1 to be disabled, got %+v", status)
+	}
+	if rec := do(s, http.MethodPut, "/admin/backends/enabled"+target, `{}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without enabled field, got %d", rec.Code)
+	}
+}
+
+func TestServer_Strategy(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+
+	if rec := do(s, http.MethodPut, "/admin/strategy", `{"type": "fastest"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for unknown strategy, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPut, "/admin/strategy", `{"strategy": "leastconnections"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 without type, got %d", rec.Code)
+	}
+
+	req := httptest.NewRequest(http.MethodPut, "/admin/strategy", strings.NewReader(`{"type": "leastconnections"}`))
+	req.Header.Set("Authorization", "Bearer "+testToken)
+	req.Header.Set("X-Actor", "alice")
+	rec := httptest.NewRecorder()
+	s.Handler().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	want := strategy.NewLeastConnections().Name()
+	if name := lb.GetStrategy().Name(); name != want {
+		t.Errorf("Expected least connections strategy, got %s", name)
+	}
+
+	var change *audit.Entry
+	for _, e := range s.opts.Audit.Recent(10) {
+		if e.Action == "strategy.change" {
+			change = &e
+		}
+	}
+	if change == nil || !strings.HasPrefix(change.Actor, "alice@") || change.Target != want {
+		t.Errorf("Expected a strategy.change audit entry by alice, got %+v", change)
+	}
+
+	// Selecting the active strategy keeps its state
+	active := lb.GetStrategy()
+	rec = do(s, http.MethodPut, "/admin/strategy", `{"type": "leastconnections"}`)
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"changed": false`) {
+		t.Errorf("Expected an unchanged answer, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if lb.GetStrategy() != active {
+		t.Error("Expected the active strategy instance to be kept")
+	}
+}
+
+func TestServer_Maintenance(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+
+	if rec := do(s, http.MethodPut, "/admin/maintenance", `{"enabled": true}`); rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if !lb.InMaintenance() {
+		t.Error("Expected maintenance mode to be on")
+	}
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 during maintenance, got %d", rec.Code)
+	}
+}
+
+func TestServer_Reload(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+	if rec := do(s, http.MethodPost, "/admin/reload", ""); rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without a config file, got %d", rec.Code)
+	}
+
+	calls := 0
+	s, _ = newTestServer(t, func() error {
+		calls++
+		if calls > 1 {
+			return errors.New("invalid config")
+		}
+		return nil
+	})
+	if rec := do(s, http.MethodPost, "/admin/reload", ""); rec.Code != http.StatusOK {
+		t.Errorf("Expected 200, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/admin/reload", ""); rec.Code != http.StatusUnprocessableEntity {
+		t.Errorf("Expected 422 for a failed reload, got %d", rec.Code)
+	}
+}
+
+func TestServer_Flags(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	rec := do(s, http.MethodGet, "/admin/flags", "")
+	var flags []features.State
+	if err := json.Unmarshal(rec.Body.Bytes(), &flags); err != nil || len(flags) != 2 {
+		t.Fatalf("Expected passthrough and top-stats flags, got %s", rec.Body.String())
+	}
+
+	rec = do(s, http.MethodPut, "/admin/flags?name="+features.TopStats, `{"enabled": false}`)
+	var state features.State
+	json.Unmarshal(rec.Body.Bytes(), &state)
+	if rec.Code != http.StatusOK || state.Enabled || state.Effective {
+		t.Errorf("Expected top-stats off, got %d: %s", rec.Code, rec.Body.String())
+	}
+	found := false
+	for _, e := range s.opts.Audit.Recent(10) {
+		found = found || (e.Action == "feature.disable" && e.Detail == features.TopStats)
+	}
+	if !found {
+		t.Error("Expected a feature.disable audit entry")
+	}
+
+	tests := []struct {
+		target, body string
+		want         int
+	}{
+		{"/admin/flags?name=retries", `{"enabled": false}`, http.StatusNotFound},
+		{"/admin/flags", `{"enabled": false}`, http.StatusBadRequest},
+		{"/admin/flags?name=" + features.TopStats, `{}`, http.StatusBadRequest},
+	}
+	for _, tt := range tests {
+		if rec := do(s, http.MethodPut, tt.target, tt.body); rec.Code != tt.want {
+			t.Errorf("Expected %d for %s %s, got %d", tt.want, tt.target, tt.body, rec.Code)
+		}
+	}
+}
+
+func TestServer_Schedules(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	rec := do(s, http.MethodPost, "/admin/schedules",
+		`{"name": "canary", "backend": "http://backend2:80", "to": 50, "duration": "2h", "daily": "01:00"}`)
+	var job schedule.Job
+	json.Unmarshal(rec.Body.Bytes(), &job)
+	if rec.Code != http.StatusCreated || job.Name != "canary" || job.Duration != 2*time.Hour || job.Source != schedule.SourceAPI {
+		t.Fatalf("Expected the canary schedule, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	rec = do(s, http.MethodGet, "/admin/schedules", "")
+	var jobs []schedule.Job
+	if err := json.Unmarshal(rec.Body.Bytes(), &jobs); err != nil || len(jobs) != 1 {
+		t.Fatalf("Expected one schedule, got %s", rec.Body.String())
+	}
+
+	tests := []struct {
+		method, target, body string
+		want                 int
+	}{
+		{http.MethodPost, "/admin/schedules", `{"backend": "http://backend2:80", "to": 500}`, http.StatusBadRequest},
+		{http.MethodPost, "/admin/schedules", `{"to": 5, "duration": "soon"}`, http.StatusBadRequest},
+		{http.MethodDelete, "/admin/schedules?id=x", "", http.StatusBadRequest},
+		{http.MethodDelete, fmt.Sprintf("/admin/schedules?id=%d", job.ID), "", http.StatusNoContent},
+		{http.MethodDelete, fmt.Sprintf("/admin/schedules?id=%d", job.ID), "", http.StatusNotFound},
+	}
+	for _, tt := range tests {
+		if rec := do(s, tt.method, tt.target, tt.body); rec.Code != tt.want {
+			t.Errorf("Expected %d for %s %s %s, got %d: %s", tt.want, tt.method, tt.target, tt.body, rec.Code, rec.Body.String())
+		}
+	}
+}
+
+func TestServer_Chaos(t *testing.T) {
+	s, _ := newTestServer(t, nil)
+
+	rec := do(s, http.MethodPut, "/admin/chaos", `{"rules": [{"pathPrefix": "/api/", "latency": "200ms"}]}`)
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without an injector, got %d", rec.Code)
+	}
+
+	injector, _ := chaos.New(nil, s.opts.Audit)
+	s.opts.Chaos = injector
+	rec = do(s, http.MethodPut, "/admin/chaos", `{"rules": [{"pathPrefix": "/api/", "latency": "200ms"}]}`)
+	var status ChaosStatus
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if rec.Code != http.StatusOK || !status.Enabled || len(status.Rules) != 1 || status.Rules[0].Latency != 200*time.Millisecond {
+		t.Fatalf("Expected one 200ms latency rule, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	if rec := do(s, http.MethodPut, "/admin/chaos", `{"rules": [{"errorRate": 3}]}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an invalid rule, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodDelete, "/admin/chaos", ""); rec.Code != http.StatusOK || len(injector.Rules()) != 0 {
+		t.Errorf("Expected the rules to be cleared, got %d: %s", rec.Code, rec.Body.String())
+	}
+}
+
+func TestServer_BlueGreen(t *testing.T) {
+	s, lb := newTestServer(t, nil)
+
+	if rec := do(s, http.MethodPost, "/admin/bluegreen/toggle", ""); rec.Code != http.StatusNotImplemented {
+		t.Errorf("Expected 501 without a switch, got %d", rec.Code)
+	}
+
+	sw, err := router.NewSwitch(map[string]http.Handler{"blue": lb, "green": http.NotFoundHandler()}, "blue", s.opts.Audit)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s.opts.BlueGreen = sw
+	rec := do(s, http.MethodPut, "/admin/bluegreen", `{"active": "green"}`)
+	var result struct{ Active, Previous string }
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || sw.Active() != "green" || result.Previous != "blue" {
+		t.Errorf("Expected green to be active, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if rec := do(s, http.MethodPut, "/admin/bluegreen", `{"active": "red"}`); rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected 400 for an unknown pool, got %d", rec.Code)
+	}
+	if rec := do(s, http.MethodPost, "/admin/bluegreen/toggle", ""); rec.Code != http.StatusOK || sw.Active() != "blue" {
+		t.Errorf("Expected the toggle to activate blue, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	var status router.SwitchStatus
+	rec = do(s, http.MethodGet, "/admin/bluegreen", "")
+	json.Unmarshal(rec.Body.Bytes(), &status)
+	if status.Active != "blue" || len(status.Targets) != 2 || status.Targets[0].InFlight != 0 || status.Targets[1].InFlight != -1 {
+		t.Errorf("Unexpected status %s", rec.Body.String())
+	}
+	switches := 0
+	for _, e := range s.opts.Audit.Recent(0) {
+		if e.Action == "switch.set" {
+			switches++
+		}
+	}
+	if switches != 2 {
+		t.Errorf("Expected 2 audited switches, got %d", switches)
+	}
+}
+
+func TestServer_State(t *testing.T) {
+	source, sourceLB := newTestServer(t, nil)
+	sourceLB.GetBackends()[1].SetWeight(9)
+	rec := do(source, http.MethodGet, "/admin/state", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rec.Code)
+	}
+	snapshot := rec.Body.String()
+
+	target, targetLB := newTestServer(t, nil)
+	targetLB.SetBackends([]backend.Config{{URL: "http://other:80"}})
+	rec = do(target, http.MethodPut, "/admin/state?replace=true", snapshot)
+	var result ImportResult
+	json.Unmarshal(rec.Body.Bytes(), &result)
+	if rec.Code != http.StatusOK || result.Restored != 2 || len(result.Backends) != 2 || result.Backends[1].Weight != 9 {
+		t.Fatalf("Expected the source pool with backend2 at weight 9, got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	tests := []struct {
+		target, body string
+	}{
+		{"/admin/state?replace=maybe", snapshot},
+		{"/admin/state", "{"},
+		{"/admin/state", `{"version": 99}`},
+	}
+	for _, tt := range tests {
+		if rec := do(target, http.MethodPut, tt.target, tt.body); rec.Code != http.StatusBadRequest {
+			t.Errorf("Expected 400 for %s %.20s, got %d", tt.target, tt.body, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,264 @@
+package admin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+)
+
+// Client calls the admin API of a running load balancer
+type Client struct {
+	base  string
+	token string
+	http  *http.Client
+}
+
+// NewClient creates a client for the admin API at base (e.g.
+// "http://localhost:9090")
+func NewClient(base, token string, timeout time.Duration) *Client {
+	return &Client{
+		base:  strings.TrimRight(base, "/"),
+		token: token,
+		http:  &http.Client{Timeout: timeout},
+	}
+}
+
+// Backends lists the backends
+func (c *Client) Backends(ctx context.Context) ([]BackendStatus, error) {
+	var out []BackendStatus
+	err := c.do(ctx, http.MethodGet, "/admin/backends", nil, nil, &out)
+	return out, err
+}
+
+// AddBackend adds a backend
+func (c *Client) AddBackend(ctx context.Context, cfg backend.Config) (BackendStatus, error) {
+	var out BackendStatus
+	err := c.do(ctx, http.MethodPost, "/admin/backends", nil, cfg, &out)
+	return out, err
+}
+
+// RemoveBackend removes the backend with the given URL
+func (c *Client) RemoveBackend(ctx context.Context, backendURL string) error {
+	return c.do(ctx, http.MethodDelete, "/admin/backends", url.Values{"url": {backendURL}}, nil, nil)
+}
+
+// SetWeight changes a backend's weight
+func (c *Client) SetWeight(ctx context.Context, backendURL string, weight int) (BackendStatus, error) {
+	var out BackendStatus
+	body := map[string]int{"weight": weight}
+	err := c.do(ctx, http.MethodPut, "/admin/backends/weight", url.Values{"url": {backendURL}}, body, &out)
+	return out, err
+}
+
+// SetDraining drains (or undrains) a backend
+func (c *Client) SetDraining(ctx context.Context, backendURL string, draining bool) (BackendStatus, error) {
+	var out BackendStatus
+	body := map[string]bool{"draining": draining}
+	err := c.do(ctx, http.MethodPut, "/admin/backends/drain", url.Values{"url": {backendURL}}, body, &out)
+	return out, err
+}
+
+// SetEnabled takes a backend out of rotation for maintenance (or puts it back)
+func (c *Client) SetEnabled(ctx context.Context, backendURL string, enabled bool) (BackendStatus, error) {
+	var out BackendStatus
+	body := map[string]bool{"enabled": enabled}
+	err := c.do(ctx, http.MethodPut, "/admin/backends/enabled", url.Values{"url": {backendURL}}, body, &out)
+	return out, err
+}
+
+// Strategy returns the active strategy name
+func (c *Client) Strategy(ctx context.Context) (string, error) {
+	var out struct {
+		Type string `json:"type"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin/strategy", nil, nil, &out)
+	return out.Type, err
+}
+
+// SetStrategy switches the strategy and returns its name
+func (c *Client) SetStrategy(ctx context.Context, name string) (string, error) {
+	var out struct {
+		Type string `json:"type"`
+	}
+	err := c.do(ctx, http.MethodPut, "/admin/strategy", nil, map[string]string{"type": name}, &out)
+	return out.Type, err
+}
+
+// Maintenance reports whether maintenance mode is on
+func (c *Client) Maintenance(ctx context.Context) (bool, error) {
+	var out struct {
+		Enabled bool `json:"enabled"`
+	}
+	err := c.do(ctx, http.MethodGet, "/admin/maintenance", nil, nil, &out)
+	return out.Enabled, err
+}
+
+// SetMaintenance toggles maintenance mode
+func (c *Client) SetMaintenance(ctx context.Context, enabled bool) error {
+	return c.do(ctx, http.MethodPut, "/admin/maintenance", nil, map[string]bool{"enabled": enabled}, nil)
+}
+
+// Flags lists the feature flags
+func (c *Client) Flags(ctx context.Context) ([]features.State, error) {
+	var out []features.State
+	err := c.do(ctx, http.MethodGet, "/admin/flags", nil, nil, &out)
+	return out, err
+}
+
+// SetFlag switches a feature flag on or off
+func (c *Client) SetFlag(ctx context.Context, name string, enabled bool) (features.State, error) {
+	var out features.State
+	err := c.do(ctx, http.MethodPut, "/admin/flags", url.Values{"name": {name}}, map[string]bool{"enabled": enabled}, &out)
+	return out, err
+}
+
+// Schedules lists the scheduled weight changes
+func (c *Client) Schedules(ctx context.Context) ([]schedule.Job, error) {
+	var out []schedule.Job
+	err := c.do(ctx, http.MethodGet, "/admin/schedules", nil, nil, &out)
+	return out, err
+}
+
+// AddSchedule schedules a weight change
+func (c *Client) AddSchedule(ctx context.Context, cfg schedule.Config) (schedule.Job, error) {
+	var out schedule.Job
+	err := c.do(ctx, http.MethodPost, "/admin/schedules", nil, cfg, &out)
+	return out, err
+}
+
+// CancelSchedule removes a scheduled weight change
+func (c *Client) CancelSchedule(ctx context.Context, id int) error {
+	return c.do(ctx, http.MethodDelete, "/admin/schedules", url.Values{"id": {strconv.Itoa(id)}}, nil, nil)
+}
+
+// State exports the pool state (backends, weights, health, drain and
+// disable flags)
+func (c *Client) State(ctx context.Context) (balancer.State, error) {
+	var out balancer.State
+	err := c.do(ctx, http.MethodGet, "/admin/state", nil, nil, &out)
+	return out, err
+}
+
+// ImportState applies a snapshot; with replace the pool becomes the
+// snapshot's backends
+func (c *Client) ImportState(ctx context.Context, st balancer.State, replace bool) (ImportResult, error) {
+	var out ImportResult
+	query := url.Values{"replace": {strconv.FormatBool(replace)}}
+	err := c.do(ctx, http.MethodPut, "/admin/state", query, st, &out)
+	return out, err
+}
+
+// Chaos returns the fault injection rules and counts
+func (c *Client) Chaos(ctx context.Context) (ChaosStatus, error) {
+	var out ChaosStatus
+	err := c.do(ctx, http.MethodGet, "/admin/chaos", nil, nil, &out)
+	return out, err
+}
+
+// SetChaos replaces the fault injection rules
+func (c *Client) SetChaos(ctx context.Context, rules []chaos.Rule) (ChaosStatus, error) {
+	var out ChaosStatus
+	err := c.do(ctx, http.MethodPut, "/admin/chaos", nil, map[string][]chaos.Rule{"rules": rules}, &out)
+	return out, err
+}
+
+// ClearChaos stops injecting faults
+func (c *Client) ClearChaos(ctx context.Context) (ChaosStatus, error) {
+	var out ChaosStatus
+	err := c.do(ctx, http.MethodDelete, "/admin/chaos", nil, nil, &out)
+	return out, err
+}
+
+// BlueGreen returns the active pool of the blue/green switch and the
+// requests each pool still serves
+func (c *Client) BlueGreen(ctx context.Context) (router.SwitchStatus, error) {
+	var out router.SwitchStatus
+	err := c.do(ctx, http.MethodGet, "/admin/bluegreen", nil, nil, &out)
+	return out, err
+}
+
+// SetBlueGreen sends new traffic to the named pool and returns the
+// previously active one
+func (c *Client) SetBlueGreen(ctx context.Context, pool string) (string, error) {
+	var out struct {
+		Previous string `json:"previous"`
+	}
+	err := c.do(ctx, http.MethodPut, "/admin/bluegreen", nil, map[string]string{"active": pool}, &out)
+	return out.Previous, err
+}
+
+// ToggleBlueGreen sends new traffic to the inactive pool and returns the
+// previously active one
+func (c *Client) ToggleBlueGreen(ctx context.Context) (string, error) {
+	var out struct {
+		Previous string `json:"previous"`
+	}
+	err := c.do(ctx, http.MethodPost, "/admin/bluegreen/toggle", nil, nil, &out)
+	return out.Previous, err
+}
+
+// Reload asks the load balancer to re-read its config
+func (c *Client) Reload(ctx context.Context) error {
+	return c.do(ctx, http.MethodPost, "/admin/reload", nil, nil, nil)
+}
+
+// do sends a request with an optional JSON body and decodes a JSON answer
+// into out; non-2xx answers become errors carrying the response text
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, in, out interface{}) error {
+	target := c.base + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+
+	var body io.Reader
+	if in != nil {
+		data, err := json.Marshal(in)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		body = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, target, body)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if in != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	if out == nil || resp.StatusCode == http.StatusNoContent {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response: %w", err)
+	}
+	return nil
+}
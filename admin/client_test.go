@@ -0,0 +1,71 @@
+package admin
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/schedule"
+)
+
+func TestClient(t *testing.T) {
+	s, lb := newTestServer(t, func() error { return nil })
+	server := httptest.NewServer(s.Handler())
+	defer server.Close()
+
+	ctx := context.Background()
+	client := NewClient(server.URL+"/", testToken, 5*time.Second)
+
+	if _, err := client.AddBackend(ctx, backend.Config{URL: "http://backend3:80", Weight: 2}); err != nil {
+		t.Fatalf("AddBackend failed: %v", err)
+	}
+	if _, err := client.SetDraining(ctx, "http://backend3:80", true); err != nil {
+		t.Fatalf("SetDraining failed: %v", err)
+	}
+	list, err := client.Backends(ctx)
+	if err != nil {
+		t.Fatalf("Backends failed: %v", err)
+	}
+	if len(list) != 3 || !list[2].Draining || list[2].Weight != 2 {
+		t.Errorf("Expected a draining backend3 with weight 2, got %+v", list)
+	}
+	if err := client.RemoveBackend(ctx, "http://backend3:80"); err != nil {
+		t.Errorf("RemoveBackend failed: %v", err)
+	}
+
+	if name, err := client.SetStrategy(ctx, "leastconnections"); err != nil || name != lb.GetStrategy().Name() {
+		t.Errorf("Expected strategy %s, got %s (%v)", lb.GetStrategy().Name(), name, err)
+	}
+	if err := client.SetMaintenance(ctx, true); err != nil {
+		t.Errorf("SetMaintenance failed: %v", err)
+	}
+	if on, _ := client.Maintenance(ctx); !on {
+		t.Error("Expected maintenance mode to be on")
+	}
+	job, err := client.AddSchedule(ctx, schedule.Config{Backend: "http://backend1:80", To: 10, Duration: time.Hour, Daily: "02:00"})
+	if err != nil {
+		t.Fatalf("AddSchedule failed: %v", err)
+	}
+	if jobs, _ := client.Schedules(ctx); len(jobs) != 1 || jobs[0].Duration != time.Hour {
+		t.Errorf("Expected one schedule lasting 1h, got %+v", jobs)
+	}
+	if err := client.CancelSchedule(ctx, job.ID); err != nil {
+		t.Errorf("CancelSchedule failed: %v", err)
+	}
+	if err := client.Reload(ctx); err != nil {
+		t.Errorf("Reload failed: %v", err)
+	}
+
+	_, err = client.SetWeight(ctx, "http://missing:80", 3)
+	if err == nil || !strings.Contains(err.Error(), "404") || !strings.Contains(err.Error(), "backend not found") {
+		t.Errorf("Expected a 404 error with the server message, got %v", err)
+	}
+
+	unauthorized := NewClient(server.URL, "wrong", 5*time.Second)
+	if _, err := unauthorized.Backends(ctx); err == nil || !strings.Contains(err.Error(), "401") {
+		t.Errorf("Expected a 401 error, got %v", err)
+	}
+}
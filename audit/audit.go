@@ -0,0 +1,201 @@
+package audit
+
+import (
+	"encoding/json"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultMaxRecent is the number of entries kept in memory for the admin API
+const DefaultMaxRecent = 500
+
+// SystemActor is used for mutations not triggered by an admin request
+const SystemActor = "system"
+
+// Entry is a single audited mutation
+type Entry struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Actor  string    `json:"actor"`
+	Action string    `json:"action"`
+	Target string    `json:"target,omitempty"`
+	Detail string    `json:"detail,omitempty"`
+}
+
+// Log is an append-only audit log kept in memory and optionally on disk
+type Log struct {
+	mu        sync.Mutex
+	seq       uint64
+	recent    []Entry
+	maxRecent int
+	file      *os.File
+	enc       *json.Encoder
+}
+
+// New creates an audit log; when path is non-empty entries are appended to it as JSON lines
+func New(path string, maxRecent int) (*Log, error) {
+	if maxRecent <= 0 {
+		maxRecent = DefaultMaxRecent
+	}
+	l := &Log{maxRecent: maxRecent}
+
+	if path != "" {
+		file, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open audit log: %w", err)
+		}
+		l.file = file
+		l.enc = json.NewEncoder(file)
+	}
+	return l, nil
+}
+
+// Record appends an entry; it is safe to call on a nil *Log
+func (l *Log) Record(actor, action, target, detail string) {
+	if l == nil {
+		return
+	}
+	if actor == "" {
+		actor = SystemActor
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.seq++
+	e := Entry{
+		Seq:    l.seq,
+		Time:   time.Now(),
+		Actor:  actor,
+		Action: action,
+		Target: target,
+		Detail: detail,
+	}
+
+	if len(l.recent) >= l.maxRecent {
+		l.recent = l.recent[1:]
+	}
+	l.recent = append(l.recent, e)
+
+	if l.enc != nil {
+		if err := l.enc.Encode(e); err != nil {
+			logging.Logger().Error("failed to persist audit entry", "seq", e.Seq, "error", err)
+		}
+	}
+}
+
+// Recent returns up to n of the most recent entries, oldest first (n <= 0 returns all)
+func (l *Log) Recent(n int) []Entry {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	start := 0
+	if n > 0 && n < len(l.recent) {
+		start = len(l.recent) - n
+	}
+	entries := make([]Entry, len(l.recent)-start)
+	copy(entries, l.recent[start:])
+	return entries
+}
+
+// Close closes the backing file, if any
+func (l *Log) Close() error {
+	if l == nil {
+		return nil
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.file == nil {
+		return nil
+	}
+	err := l.file.Close()
+	l.file = nil
+	l.enc = nil
+	return err
+}
+
+// Handler returns an HTTP handler listing recent entries (?limit=N)
+func (l *Log) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", "GET")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		limit := 100
+		if v := r.URL.Query().Get("limit"); v != "" {
+			n, err := strconv.Atoi(v)
+			if err != nil || n < 0 {
+				http.Error(w, "invalid limit", http.StatusBadRequest)
+				return
+			}
+			limit = n
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(l.Recent(limit))
+	}
+}
+
+// Middleware records every mutating request (anything but GET/HEAD/OPTIONS) passing through next
+func (l *Log) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		rw := &statusWriter{ResponseWriter: w, statusCode: http.StatusOK}
+		next.ServeHTTP(rw, r)
+
+		l.Record(ActorFromRequest(r), r.Method+" "+r.URL.Path, r.URL.RawQuery,
+			fmt.Sprintf("status=%d", rw.statusCode))
+	})
+}
+
+// ActorFromRequest identifies who made an admin request: the X-Actor header
+// (if supplied) qualified by the client address
+func ActorFromRequest(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+	if actor := r.Header.Get("X-Actor"); actor != "" {
+		return actor + "@" + host
+	}
+	return "admin@" + host
+}
+
+// statusWriter wraps http.ResponseWriter to capture status code
+type statusWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+}
+
+func (sw *statusWriter) WriteHeader(code int) {
+	if !sw.wroteHeader {
+		sw.statusCode = code
+		sw.wroteHeader = true
+	}
+	sw.ResponseWriter.WriteHeader(code)
+}
+
+func (sw *statusWriter) Write(p []byte) (int, error) {
+	sw.wroteHeader = true
+	return sw.ResponseWriter.Write(p)
+}
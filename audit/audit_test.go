@@ -0,0 +1,97 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLog_RecordAndRecent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.log")
+	l, err := New(path, 3)
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	for _, action := range []string{"a", "b", "c", "d"} {
+		l.Record("", action, "", "")
+	}
+
+	recent := l.Recent(0)
+	if len(recent) != 3 {
+		t.Fatalf("Expected 3 recent entries, got %d", len(recent))
+	}
+	if recent[0].Action != "b" || recent[2].Action != "d" {
+		t.Errorf("Unexpected recent window: %+v", recent)
+	}
+	if recent[2].Seq != 4 {
+		t.Errorf("Expected seq 4, got %d", recent[2].Seq)
+	}
+	if recent[0].Actor != SystemActor {
+		t.Errorf("Expected default actor %q, got %q", SystemActor, recent[0].Actor)
+	}
+	if got := l.Recent(1); len(got) != 1 || got[0].Action != "d" {
+		t.Errorf("Recent(1) = %+v", got)
+	}
+	l.Close()
+
+	// Every entry, including those evicted from memory, must be on disk
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("Failed to open audit file: %v", err)
+	}
+	defer file.Close()
+	lines := 0
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		lines++
+	}
+	if lines != 4 {
+		t.Errorf("Expected 4 persisted entries, got %d", lines)
+	}
+}
+
+func TestLog_NilSafe(t *testing.T) {
+	var l *Log
+	l.Record("x", "y", "", "")
+	if l.Recent(10) != nil {
+		t.Error("Recent on nil log should return nil")
+	}
+}
+
+func TestLog_Middleware(t *testing.T) {
+	l, _ := New("", 10)
+	handler := l.Middleware(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+	}))
+
+	get := httptest.NewRequest(http.MethodGet, "/admin/thing", nil)
+	handler.ServeHTTP(httptest.NewRecorder(), get)
+
+	post := httptest.NewRequest(http.MethodPost, "/admin/thing", nil)
+	post.RemoteAddr = "192.0.2.7:1234"
+	post.Header.Set("X-Actor", "alice")
+	handler.ServeHTTP(httptest.NewRecorder(), post)
+
+	entries := l.Recent(0)
+	if len(entries) != 1 {
+		t.Fatalf("Expected only the mutating request to be audited, got %d entries", len(entries))
+	}
+	if entries[0].Actor != "alice@192.0.2.7" {
+		t.Errorf("Unexpected actor %q", entries[0].Actor)
+	}
+	if entries[0].Action != "POST /admin/thing" || entries[0].Detail != "status=202" {
+		t.Errorf("Unexpected entry %+v", entries[0])
+	}
+
+	rr := httptest.NewRecorder()
+	l.Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/audit?limit=5", nil))
+	var listed []Entry
+	if err := json.Unmarshal(rr.Body.Bytes(), &listed); err != nil || len(listed) != 1 {
+		t.Errorf("Handler returned %q (err %v)", rr.Body.String(), err)
+	}
+}
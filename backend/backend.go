@@ -1,13 +1,16 @@
 package backend
 
 import (
-	"log"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httputil"
 	"net/url"
 	"sync"
 	"sync/atomic"
 	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
 )
 
 // Backend represents a backend server
@@ -20,19 +23,101 @@ type Backend struct {
 	ReverseProxy *httputil.ReverseProxy
 	FailCount    int32
 	LastCheck    time.Time
+	config       Config
+	draining     bool
+	disabled     bool
+	ejected      bool
+	weight       atomic.Int32 // runtime weight override (0 = configured weight)
+	onChange     atomic.Pointer[func()]
+	// ResponseTime is a moving average; fastest and slowest bound the
+	// samples it was built from
+	fastest time.Duration
+	slowest time.Duration
+	// limitRejections counts reservations refused at MaxConnections
+	limitRejections atomic.Int64
+	// bytesIn and bytesOut count the request and response body bytes of
+	// the requests proxied to the backend
+	bytesIn, bytesOut atomic.Int64
+	// address caches URL.String() for stats and metric labels
+	address string
+	// errorPolicy answers failed proxy attempts (502 Bad Gateway when nil)
+	errorPolicy atomic.Pointer[ErrorPolicy]
+	// idleLimit is the MaxIdlePerBackend enforced by ReapIdle
+	idleLimit int
+	// phases accumulates the upstream timings per Phase
+	phases [numPhases]phaseCounter
+	// passive tracks proxy errors and half-open probes
+	passive passiveState
+	// healthDefaults is the health check the config applies on top of
+	healthDefaults atomic.Pointer[HealthCheck]
 }
 
 // Serve handles the HTTP request by forwarding it to the backend server
 func (b *Backend) Serve(w http.ResponseWriter, r *http.Request) {
-	start := time.Now()
 	atomic.AddInt32(&b.Connections, 1)
+	b.serve(w, r)
+}
+
+// serve forwards a request already counted in Connections and releases it
+func (b *Backend) serve(w http.ResponseWriter, r *http.Request) {
+	start := time.Now()
+	client := r.Context()
+	r, stop := b.applyTimeouts(r)
 	defer func() {
-		atomic.AddInt32(&b.Connections, -1)
-		b.UpdateResponseTime(time.Since(start))
+		b.Release()
+		// A request the client abandoned says nothing about the backend's
+		// speed; one cut off by the upstream timeouts took at least as long
+		if client.Err() == nil {
+			b.UpdateResponseTime(time.Since(start))
+		}
+		stop()
 	}()
 	b.ReverseProxy.ServeHTTP(w, r)
 }
 
+// TryAcquire reserves a slot for a request, failing when the backend is at
+// MaxConnections. Checking and reserving in one step keeps bursts from
+// overshooting the limit.
+func (b *Backend) TryAcquire() bool {
+	limit := int32(b.config.MaxConnections)
+	if limit <= 0 {
+		atomic.AddInt32(&b.Connections, 1)
+		return true
+	}
+	for {
+		current := atomic.LoadInt32(&b.Connections)
+		if current >= limit {
+			b.limitRejections.Add(1)
+			return false
+		}
+		if atomic.CompareAndSwapInt32(&b.Connections, current, current+1) {
+			return true
+		}
+	}
+}
+
+// Release frees a slot reserved with TryAcquire
+func (b *Backend) Release() {
+	atomic.AddInt32(&b.Connections, -1)
+}
+
+// LimitRejections returns how often TryAcquire found the backend full
+func (b *Backend) LimitRejections() int64 {
+	return b.limitRejections.Load()
+}
+
+// AddBytes records the request body bytes sent to the backend and the
+// response bytes returned to the client for a proxied request
+func (b *Backend) AddBytes(in, out int64) {
+	b.bytesIn.Add(in)
+	b.bytesOut.Add(out)
+}
+
+// Bytes returns the request and response bytes recorded by AddBytes
+func (b *Backend) Bytes() (in, out int64) {
+	return b.bytesIn.Load(), b.bytesOut.Load()
+}
+
 // ServerPool manages a pool of backend servers
 type ServerPool struct {
 	backends []*Backend
@@ -40,17 +125,29 @@ type ServerPool struct {
 	mu       sync.RWMutex
 }
 
-// NewBackend creates a new backend instance with enhanced configuration
+// NewBackend creates a new backend instance with default settings
 func NewBackend(urlStr string) (*Backend, error) {
-	u, err := url.Parse(urlStr)
+	return NewBackendWithConfig(Config{URL: urlStr})
+}
+
+// NewBackendWithConfig creates a new backend instance from per-backend settings
+func NewBackendWithConfig(cfg Config) (*Backend, error) {
+	u, err := url.Parse(cfg.URL)
 	if err != nil {
 		return nil, err
 	}
+	transport, settings, err := cfg.transport()
+	if err != nil {
+		return nil, fmt.Errorf("backend %s: %w", cfg.URL, err)
+	}
 
 	b := &Backend{
 		URL:       u,
 		Alive:     true,
 		LastCheck: time.Now(),
+		config:    cfg,
+		address:   u.String(),
+		idleLimit: settings.MaxIdlePerBackend,
 	}
 
 	// Create reverse proxy with custom configuration
@@ -60,22 +157,54 @@ func NewBackend(urlStr string) (*Backend, error) {
 	originalDirector := rp.Director
 	rp.Director = func(req *http.Request) {
 		originalDirector(req)
+		b.traceRequest(req)
+		setForwardedHeaders(req)
 		req.Host = u.Host
-		req.Header.Set("X-Forwarded-Host", req.Header.Get("Host"))
 		req.Header.Set("X-Origin-Host", u.Host)
-		req.Header.Set("X-Forwarded-Proto", req.URL.Scheme)
 	}
 
 	// Error handler with automatic retry and failure tracking
 	rp.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
-		log.Printf("[Backend Error] %s: %v", u, err)
-		atomic.AddInt32(&b.FailCount, 1)
-		b.SetAlive(false)
+		err = timeoutCause(r.Context(), err)
+		recordProxyError(r, err)
+		// An oversized upload is the client's fault, not the backend's
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		// Neither is a client that went away, canceling the upstream request
+		if ClassifyError(r.Context(), err) == ErrorClientCanceled {
+			w.WriteHeader(StatusClientClosedRequest)
+			return
+		}
+		logging.Logger().ErrorContext(r.Context(), "backend error",
+			"backend", u.String(), "path", r.URL.Path, "error", err)
+		policy := b.errorPolicy.Load()
+		if (policy == nil || !policy.KeepUp) && b.countFailure() {
+			b.markFailed()
+		}
+		if policy != nil && policy.Handler != nil {
+			policy.Handler(w, r, err)
+			return
+		}
 		http.Error(w, "Bad Gateway", http.StatusBadGateway)
 	}
 
+	// Pooled copy buffers, sized from the bodies this backend returns
+	buffers := &BufferPool{}
+	rp.BufferPool = buffers
+	rp.FlushInterval = cfg.FlushInterval
+
 	// Custom response modifier for logging
 	rp.ModifyResponse = func(resp *http.Response) error {
+		startBody(resp)
+		buffers.Observe(resp.ContentLength)
+		// The client already gets the balancer's request ID; a backend
+		// echoing it would send it twice
+		if logging.RequestID(resp.Request.Context()) != "" {
+			resp.Header.Del(logging.RequestIDHeader)
+		}
 		// Reset fail count on successful response
 		if resp.StatusCode < 500 {
 			atomic.StoreInt32(&b.FailCount, 0)
@@ -83,16 +212,113 @@ func NewBackend(urlStr string) (*Backend, error) {
 		return nil
 	}
 
+	rp.Transport = transport
 	b.ReverseProxy = rp
 
 	return b, nil
 }
 
+// OnStateChange sets fn to be called after the alive, draining or disabled
+// state of the backend changes, replacing any previous callback (nil
+// removes it). The pool owning the backend uses it to maintain its snapshot
+// of eligible backends.
+func (b *Backend) OnStateChange(fn func()) {
+	if fn == nil {
+		b.onChange.Store(nil)
+		return
+	}
+	b.onChange.Store(&fn)
+}
+
+// generation changes whenever a backend's weight or state changes
+var generation atomic.Uint64
+
+// Generation returns a number that changes whenever the weight or the
+// alive, draining, disabled or ejected state of any backend changes, so
+// strategies can cache tables derived from them
+func Generation() uint64 {
+	return generation.Load()
+}
+
+// notify runs the OnStateChange callback; callers must not hold b.mu
+func (b *Backend) notify() {
+	generation.Add(1)
+	if fn := b.onChange.Load(); fn != nil {
+		(*fn)()
+	}
+}
+
 // SetAlive sets the alive status of the backend
 func (b *Backend) SetAlive(alive bool) {
 	b.mu.Lock()
-	defer b.mu.Unlock()
+	changed := b.Alive != alive
 	b.Alive = alive
+	b.mu.Unlock()
+	if alive {
+		b.stopHalfOpen()
+	}
+	if changed {
+		b.notify()
+	}
+}
+
+// SetDraining stops (or resumes) routing new requests to the backend;
+// in-flight requests are not affected
+func (b *Backend) SetDraining(draining bool) {
+	b.mu.Lock()
+	changed := b.draining != draining
+	b.draining = draining
+	b.mu.Unlock()
+	if changed {
+		b.notify()
+	}
+}
+
+// IsDraining reports whether the backend is excluded from new requests
+func (b *Backend) IsDraining() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.draining
+}
+
+// SetDisabled takes the backend out of rotation for maintenance (or puts it
+// back); unlike health, only an operator changes it
+func (b *Backend) SetDisabled(disabled bool) {
+	b.mu.Lock()
+	changed := b.disabled != disabled
+	b.disabled = disabled
+	b.mu.Unlock()
+	if changed {
+		b.notify()
+	}
+}
+
+// IsDisabled reports whether an operator took the backend out of rotation
+func (b *Backend) IsDisabled() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.disabled
+}
+
+// SetEjected takes the backend out of rotation while outlier detection
+// finds it slower or failing more than the rest of its pool (or puts it
+// back)
+func (b *Backend) SetEjected(ejected bool) {
+	b.mu.Lock()
+	changed := b.ejected != ejected
+	b.ejected = ejected
+	b.mu.Unlock()
+	if changed {
+		b.notify()
+	}
+}
+
+// IsEjected reports whether outlier detection took the backend out of
+// rotation
+func (b *Backend) IsEjected() bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.ejected
 }
 
 // IsAlive returns the alive status of the backend
@@ -107,21 +333,42 @@ func (b *Backend) GetURL() *url.URL {
 	return b.URL
 }
 
-// IncrementConnections increments the connection count atomically
+// String returns the backend URL without allocating
+func (b *Backend) String() string {
+	return b.address
+}
+
+// IncrementConnections counts one more in-flight request
 func (b *Backend) IncrementConnections() {
 	atomic.AddInt32(&b.Connections, 1)
 }
 
-// DecrementConnections decrements the connection count atomically
+// DecrementConnections counts one in-flight request less, never going below zero
 func (b *Backend) DecrementConnections() {
-	atomic.AddInt32(&b.Connections, -1)
+	for {
+		current := atomic.LoadInt32(&b.Connections)
+		if current <= 0 {
+			return
+		}
+		if atomic.CompareAndSwapInt32(&b.Connections, current, current-1) {
+			return
+		}
+	}
 }
 
-// GetConnections returns the current connection count
-func (b *Backend) GetConnections() int {
+// InFlight returns the number of requests currently proxied to the backend
+func (b *Backend) InFlight() int {
 	return int(atomic.LoadInt32(&b.Connections))
 }
 
+// GetConnections returns the number of in-flight requests.
+//
+// Deprecated: despite its name it does not count upstream connections; use
+// InFlight, or OpenConnections for the connections to the backend.
+func (b *Backend) GetConnections() int {
+	return b.InFlight()
+}
+
 // GetFailCount returns the current failure count
 func (b *Backend) GetFailCount() int {
 	return int(atomic.LoadInt32(&b.FailCount))
@@ -146,20 +393,38 @@ func (b *Backend) GetLastCheck() time.Time {
 	return b.LastCheck
 }
 
-// UpdateResponseTime updates the response time of the backend
+// UpdateResponseTime folds a response time sample into the backend's moving
+// average
 func (b *Backend) UpdateResponseTime(duration time.Duration) {
 	b.mu.Lock()
 	defer b.mu.Unlock()
-	b.ResponseTime = duration
+	if b.ResponseTime == 0 && b.slowest == 0 {
+		b.ResponseTime, b.fastest, b.slowest = duration, duration, duration
+		return
+	}
+	alpha := b.config.ResponseTimeAlpha
+	if alpha <= 0 {
+		alpha = DefaultResponseTimeAlpha
+	}
+	b.ResponseTime += time.Duration(alpha * float64(duration-b.ResponseTime))
+	b.fastest = min(b.fastest, duration)
+	b.slowest = max(b.slowest, duration)
 }
 
-// GetResponseTime returns the response time of the backend
+// GetResponseTime returns the moving average response time of the backend
 func (b *Backend) GetResponseTime() time.Duration {
 	b.mu.RLock()
 	defer b.mu.RUnlock()
 	return b.ResponseTime
 }
 
+// ResponseTimeRange returns the fastest and slowest response times seen
+func (b *Backend) ResponseTimeRange() (fastest, slowest time.Duration) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.fastest, b.slowest
+}
+
 // NewServerPool creates a new server pool
 func NewServerPool() *ServerPool {
 	return &ServerPool{
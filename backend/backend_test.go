@@ -1,8 +1,15 @@
 package backend
 
 import (
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
 )
 
 func TestNewBackend(t *testing.T) {
@@ -54,6 +61,28 @@ func TestBackend_SetAlive(t *testing.T) {
 	}
 }
 
+func TestBackend_DecrementConnectionsConcurrent(t *testing.T) {
+	b, _ := NewBackend("http://localhost:8080")
+	for range 50 {
+		b.IncrementConnections()
+	}
+
+	// Twice as many releases as acquisitions must stop at zero
+	done := make(chan struct{})
+	for range 100 {
+		go func() {
+			b.DecrementConnections()
+			done <- struct{}{}
+		}()
+	}
+	for range 100 {
+		<-done
+	}
+	if b.GetConnections() != 0 {
+		t.Errorf("Expected the counter clamped at 0, got %d", b.GetConnections())
+	}
+}
+
 func TestBackend_Connections(t *testing.T) {
 	backend, _ := NewBackend("http://localhost:8080")
 
@@ -78,6 +107,34 @@ func TestBackend_Connections(t *testing.T) {
 	}
 }
 
+func TestBackend_TryAcquire(t *testing.T) {
+	b, _ := NewBackendWithConfig(Config{URL: "http://localhost:8080", MaxConnections: 5})
+
+	var acquired atomic.Int32
+	var wg sync.WaitGroup
+	for range 50 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if b.TryAcquire() {
+				acquired.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if acquired.Load() != 5 || b.InFlight() != 5 {
+		t.Errorf("Expected exactly 5 reservations, got %d (%d in flight)", acquired.Load(), b.InFlight())
+	}
+	if b.LimitRejections() != 45 {
+		t.Errorf("Expected 45 rejections, got %d", b.LimitRejections())
+	}
+	b.Release()
+	if !b.TryAcquire() {
+		t.Error("Expected a reservation after a release")
+	}
+}
+
 func TestBackend_ResponseTime(t *testing.T) {
 	backend, _ := NewBackend("http://localhost:8080")
 
@@ -88,3 +145,199 @@ func TestBackend_ResponseTime(t *testing.T) {
 		t.Errorf("Expected response time %v, got %v", testDuration, backend.GetResponseTime())
 	}
 }
+
+func TestBackend_ResponseTimeAverage(t *testing.T) {
+	tests := []struct {
+		name    string
+		alpha   float64
+		samples []time.Duration
+		want    time.Duration
+	}{
+		{"default alpha", 0, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, 120 * time.Millisecond},
+		{"last sample only", 1, []time.Duration{100 * time.Millisecond, 200 * time.Millisecond}, 200 * time.Millisecond},
+		{"outlier damped", 0.5, []time.Duration{10 * time.Millisecond, 10 * time.Millisecond, 90 * time.Millisecond, 10 * time.Millisecond}, 30 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, _ := NewBackendWithConfig(Config{URL: "http://localhost:8080", ResponseTimeAlpha: tt.alpha})
+			for _, d := range tt.samples {
+				b.UpdateResponseTime(d)
+			}
+			if got := b.GetResponseTime(); got != tt.want {
+				t.Errorf("Expected response time %v, got %v", tt.want, got)
+			}
+			fastest, slowest := b.ResponseTimeRange()
+			if fastest != slices.Min(tt.samples) || slowest != slices.Max(tt.samples) {
+				t.Errorf("Expected range %v-%v, got %v-%v", slices.Min(tt.samples), slices.Max(tt.samples), fastest, slowest)
+			}
+		})
+	}
+}
+
+func TestBackend_Config(t *testing.T) {
+	b, err := NewBackendWithConfig(Config{
+		URL:        "http://localhost:8080/app?x=1",
+		Weight:     3,
+		HealthPath: "/healthz",
+		Labels:     map[string]string{"zone": "a"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	if b.GetWeight() != 3 {
+		t.Errorf("Expected weight 3, got %d", b.GetWeight())
+	}
+	if b.HealthURL() != "http://localhost:8080/healthz" {
+		t.Errorf("Expected health URL http://localhost:8080/healthz, got %s", b.HealthURL())
+	}
+	if b.Labels()["zone"] != "a" {
+		t.Errorf("Expected label zone=a, got %v", b.Labels())
+	}
+
+	plain, _ := NewBackend("http://localhost:8080")
+	if plain.GetWeight() != 1 || plain.HealthURL() != "http://localhost:8080" {
+		t.Errorf("Unexpected defaults: weight %d, health URL %s", plain.GetWeight(), plain.HealthURL())
+	}
+}
+
+func TestBackend_MaxFails(t *testing.T) {
+	b, err := NewBackendWithConfig(Config{URL: "http://127.0.0.1:1", MaxFails: 2})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	for i, wantAlive := range []bool{true, false} {
+		b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if b.IsAlive() != wantAlive {
+			t.Errorf("After %d failure(s): expected alive=%v", i+1, wantAlive)
+		}
+	}
+}
+
+func TestBackend_FailWindow(t *testing.T) {
+	b, err := NewBackendWithConfig(Config{URL: "http://127.0.0.1:1", MaxFails: 2, FailWindow: 30 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	time.Sleep(60 * time.Millisecond)
+	// The first failure left the window, so this one starts a new streak
+	b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if !b.IsAlive() {
+		t.Fatal("Expected failures further apart than the window not to add up")
+	}
+	b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if b.IsAlive() {
+		t.Error("Expected two failures within the window to mark the backend down")
+	}
+}
+
+func TestBackend_HalfOpen(t *testing.T) {
+	var healthy atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" {
+			if !healthy.Load() {
+				w.WriteHeader(http.StatusServiceUnavailable)
+			}
+			return
+		}
+		// Every proxied request fails with a dropped connection
+		conn, _, _ := w.(http.Hijacker).Hijack()
+		conn.Close()
+	}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, HealthPath: "/health", FailTimeout: 20 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer b.Retire()
+	b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if b.IsAlive() {
+		t.Fatal("Expected a proxy error to mark the backend down")
+	}
+
+	// Failed half-open probes keep it down
+	deadline := time.Now().Add(2 * time.Second)
+	for probes, _ := b.HalfOpenProbes(); probes < 2; probes, _ = b.HalfOpenProbes() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected repeated half-open probes")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if b.IsAlive() {
+		t.Error("Expected a failed half-open probe to keep the backend down")
+	}
+
+	healthy.Store(true)
+	for !b.IsAlive() {
+		if time.Now().After(deadline) {
+			t.Fatal("Expected a passing half-open probe to return the backend")
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+	if _, recovered := b.HalfOpenProbes(); recovered != 1 {
+		t.Errorf("Expected 1 recovery, got %d", recovered)
+	}
+	if b.GetFailCount() != 0 {
+		t.Errorf("Expected the fail count reset, got %d", b.GetFailCount())
+	}
+}
+
+func TestBackend_WeightAndDraining(t *testing.T) {
+	b, err := NewBackendWithConfig(Config{URL: "http://localhost:8081", Weight: 2})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+
+	if err := b.SetWeight(MaxWeight + 1); err == nil {
+		t.Error("Expected an error for a weight above MaxWeight")
+	}
+	if err := b.SetWeight(7); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if b.GetWeight() != 7 || b.Config().Weight != 7 {
+		t.Errorf("Expected weight 7, got %d (config %d)", b.GetWeight(), b.Config().Weight)
+	}
+
+	b.SetDraining(true)
+	if !b.IsAlive() || b.IsAvailable() {
+		t.Error("Expected a draining backend to stay alive but be unavailable")
+	}
+}
+
+func TestBackend_InvalidTLSConfig(t *testing.T) {
+	_, err := NewBackendWithConfig(Config{
+		URL: "https://localhost:8443",
+		TLS: TLSConfig{CAFile: "/nonexistent/ca.pem"},
+	})
+	if err == nil {
+		t.Error("Expected error for missing CA file")
+	}
+}
+
+func TestBackend_RequestIDEchoedOnce(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set(logging.RequestIDHeader, r.Header.Get(logging.RequestIDHeader))
+	}))
+	defer server.Close()
+
+	b, err := NewBackend(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set(logging.RequestIDHeader, "abc")
+	req = req.WithContext(logging.WithRequestID(req.Context(), "abc"))
+	rec := httptest.NewRecorder()
+	rec.Header().Set(logging.RequestIDHeader, "abc")
+	if err := b.ServeRequest(rec, req); err != nil {
+		t.Fatalf("ServeRequest() error = %v", err)
+	}
+	if ids := rec.Header().Values(logging.RequestIDHeader); len(ids) != 1 {
+		t.Errorf("Expected the request ID once, got %v", ids)
+	}
+}
@@ -0,0 +1,77 @@
+package backend
+
+import (
+	"math/bits"
+	"sync"
+	"sync/atomic"
+)
+
+// Size classes of the buffers used to copy response bodies, powers of two
+// from 4KB to 64KB
+const (
+	minBufferShift = 12
+	maxBufferShift = 16
+)
+
+// bufferPools holds one pool per size class, shared by every backend
+var bufferPools [maxBufferShift - minBufferShift + 1]sync.Pool
+
+// BufferPool is the httputil.BufferPool of a backend's reverse proxy. It
+// hands out pooled buffers sized for the bodies the backend usually returns,
+// so small JSON answers don't pin 32KB buffers and large downloads aren't
+// copied in small chunks.
+type BufferPool struct {
+	// typical is a moving average of the response body sizes seen so far;
+	// 0 until the first response with a known length
+	typical atomic.Int64
+}
+
+// Get returns a buffer of the size class matching the typical body size
+func (p *BufferPool) Get() []byte {
+	shift := p.shift()
+	if buf, ok := bufferPools[shift-minBufferShift].Get().(*[]byte); ok {
+		return *buf
+	}
+	return make([]byte, 1<<shift)
+}
+
+// Put returns a buffer obtained from Get to its pool
+func (p *BufferPool) Put(buf []byte) {
+	size := cap(buf)
+	shift := bits.Len(uint(size)) - 1
+	if shift < minBufferShift || shift > maxBufferShift || size != 1<<shift {
+		return
+	}
+	buf = buf[:size]
+	bufferPools[shift-minBufferShift].Put(&buf)
+}
+
+// Observe records the length of a response body; unknown lengths (-1) are
+// ignored
+func (p *BufferPool) Observe(length int64) {
+	if length < 0 {
+		return
+	}
+	for {
+		prev := p.typical.Load()
+		next := length
+		if prev > 0 {
+			// Weight the new sample by 1/8
+			next = prev + (length-prev)/8
+		}
+		if p.typical.CompareAndSwap(prev, next) {
+			return
+		}
+	}
+}
+
+// shift returns the size class for the typical body size; 32KB, the size
+// httputil uses on its own, until a body has been seen
+func (p *BufferPool) shift() int {
+	typical := p.typical.Load()
+	if typical == 0 {
+		return 15
+	}
+	shift := bits.Len64(uint64(typical - 1))
+	return min(max(shift, minBufferShift), maxBufferShift)
+}
@@ -0,0 +1,231 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"time"
+)
+
+// Config holds the settings of a single backend
+type Config struct {
+	URL    string `json:"url"`
+	Weight int    `json:"weight"`
+	// HealthPath and HealthInterval override the global health check settings
+	HealthPath     string        `json:"healthPath,omitempty"`
+	HealthInterval time.Duration `json:"healthInterval,omitempty"`
+	// MaxConnections caps concurrent proxied requests (0 = unlimited)
+	MaxConnections int `json:"maxConnections,omitempty"`
+	// MaxFails is the number of consecutive proxy errors before the backend is marked down (0 = 1)
+	MaxFails        int               `json:"maxFails,omitempty"`
+	DialTimeout     time.Duration     `json:"dialTimeout,omitempty"`
+	ResponseTimeout time.Duration     `json:"responseTimeout,omitempty"` // time to wait for response headers
+	TLS             TLSConfig         `json:"tls"`
+	Labels          map[string]string `json:"labels,omitempty"`
+	// Backup backends only receive traffic when no primary backend is available
+	Backup bool `json:"backup,omitempty"`
+	// Priority is the tier of the backend for the failover strategy: only
+	// the lowest tier with an available backend serves (0 = first tier)
+	Priority int `json:"priority,omitempty"`
+	// Transport overrides the shared connection pool settings for this backend
+	Transport TransportConfig `json:"transport"`
+	// ResponseTimeAlpha weights new samples in the response time moving
+	// average (0 = DefaultResponseTimeAlpha, 1 = last sample only)
+	ResponseTimeAlpha float64 `json:"responseTimeAlpha,omitempty"`
+	// FlushInterval is how often a streamed response is flushed to the
+	// client while it is copied (0 = when the copy buffer fills, negative =
+	// after every write). Responses of unknown length and event streams are
+	// always flushed immediately.
+	FlushInterval time.Duration `json:"flushInterval,omitempty"`
+	// RequestTimeout bounds each proxied request as a whole and IdleTimeout
+	// the pauses while its response body is read (0 = none); routes can set
+	// shorter ones
+	RequestTimeout time.Duration `json:"requestTimeout,omitempty"`
+	IdleTimeout    time.Duration `json:"idleTimeout,omitempty"`
+	// FailWindow bounds the streak of proxy errors counted towards
+	// MaxFails: errors further from the first one start a new streak (0 =
+	// no bound)
+	FailWindow time.Duration `json:"failWindow,omitempty"`
+	// FailTimeout is how long a backend marked down by proxy errors stays
+	// out before a half-open probe of its health URL may return it to
+	// rotation (0 = only health checks return it)
+	FailTimeout time.Duration `json:"failTimeout,omitempty"`
+	// HealthMethod, HealthHeaders, HealthStatus and HealthBody override the
+	// method, headers, expected status codes and expected body substring of
+	// the global health check (see HealthCheck)
+	HealthMethod  string            `json:"healthMethod,omitempty"`
+	HealthHeaders map[string]string `json:"healthHeaders,omitempty"`
+	HealthStatus  []int             `json:"healthStatus,omitempty"`
+	HealthBody    string            `json:"healthBody,omitempty"`
+}
+
+// DefaultResponseTimeAlpha smooths the response time over roughly the last
+// ten samples
+const DefaultResponseTimeAlpha = 0.2
+
+// TLSConfig configures TLS towards an https backend
+type TLSConfig struct {
+	InsecureSkipVerify bool   `json:"insecureSkipVerify,omitempty"`
+	ServerName         string `json:"serverName,omitempty"`
+	CAFile             string `json:"caFile,omitempty"`   // PEM bundle used instead of the system roots
+	CertFile           string `json:"certFile,omitempty"` // client certificate for mTLS
+	KeyFile            string `json:"keyFile,omitempty"`
+}
+
+// IsZero reports whether no TLS option is set
+func (c TLSConfig) IsZero() bool {
+	return c == TLSConfig{}
+}
+
+// Build creates the crypto/tls configuration
+func (c TLSConfig) Build() (*tls.Config, error) {
+	cfg := &tls.Config{
+		InsecureSkipVerify: c.InsecureSkipVerify,
+		ServerName:         c.ServerName,
+	}
+	if c.CAFile != "" {
+		pem, err := os.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+	return cfg, nil
+}
+
+// transport returns the shared transport, or a dedicated one when the
+// config needs its own settings
+func (c Config) transport() (http.RoundTripper, TransportConfig, error) {
+	shared, settings := sharedTransport()
+	settings = settings.merge(c.Transport)
+	// The reaper settings don't need a transport of their own
+	pool := c.Transport
+	pool.ReapInterval, pool.MaxIdlePerBackend = 0, 0
+	if c.DialTimeout == 0 && c.ResponseTimeout == 0 && c.TLS.IsZero() && pool.IsZero() {
+		return shared, settings, nil
+	}
+
+	t := settings.newTransport()
+	if c.DialTimeout > 0 {
+		t.DialContext = countConns((&net.Dialer{
+			Timeout:   c.DialTimeout,
+			KeepAlive: 30 * time.Second,
+		}).DialContext)
+	}
+	t.ResponseHeaderTimeout = c.ResponseTimeout
+	if !c.TLS.IsZero() {
+		tlsConfig, err := c.TLS.Build()
+		if err != nil {
+			return nil, settings, err
+		}
+		t.TLSClientConfig = tlsConfig
+	}
+	return t, settings, nil
+}
+
+// MaxWeight is the largest accepted backend weight
+const MaxWeight = 100
+
+// Config returns the backend settings, including a runtime weight change
+func (b *Backend) Config() Config {
+	cfg := b.config
+	if w := b.weight.Load(); w > 0 {
+		cfg.Weight = int(w)
+	}
+	return cfg
+}
+
+// GetWeight returns the backend weight (at least 1)
+func (b *Backend) GetWeight() int {
+	if w := b.weight.Load(); w > 0 {
+		return int(w)
+	}
+	if b.config.Weight <= 0 {
+		return 1
+	}
+	return b.config.Weight
+}
+
+// SetWeight changes the backend weight at runtime
+func (b *Backend) SetWeight(weight int) error {
+	if weight < 1 || weight > MaxWeight {
+		return fmt.Errorf("weight %d is out of range (1-%d)", weight, MaxWeight)
+	}
+	if b.weight.Swap(int32(weight)) != int32(weight) {
+		generation.Add(1)
+	}
+	return nil
+}
+
+// WeightOverride returns the weight set with SetWeight, or 0 when the
+// configured weight applies
+func (b *Backend) WeightOverride() int {
+	return int(b.weight.Load())
+}
+
+// IsBackup reports whether the backend only serves when primaries are unavailable
+func (b *Backend) IsBackup() bool {
+	return b.config.Backup
+}
+
+// Priority returns the failover tier of the backend
+func (b *Backend) Priority() int {
+	return b.config.Priority
+}
+
+// Labels returns the backend labels
+func (b *Backend) Labels() map[string]string {
+	return b.config.Labels
+}
+
+// HasLabels reports whether the backend carries all of labels
+func (b *Backend) HasLabels(labels map[string]string) bool {
+	for k, v := range labels {
+		if b.config.Labels[k] != v {
+			return false
+		}
+	}
+	return true
+}
+
+// IsAvailable reports whether the backend is alive, enabled, neither
+// draining nor ejected, and below its connection limit
+func (b *Backend) IsAvailable() bool {
+	if !b.IsAlive() || b.IsDraining() || b.IsDisabled() || b.IsEjected() {
+		return false
+	}
+	return b.HasCapacity()
+}
+
+// HasCapacity reports whether the backend is below its connection limit,
+// without looking at its state
+func (b *Backend) HasCapacity() bool {
+	return b.config.MaxConnections <= 0 || b.InFlight() < b.config.MaxConnections
+}
+
+// HealthInterval returns the per-backend probe interval override (0 = global)
+func (b *Backend) HealthInterval() time.Duration {
+	return b.config.HealthInterval
+}
+
+// maxFails returns the consecutive failure threshold for marking the backend down
+func (b *Backend) maxFails() int32 {
+	if b.config.MaxFails <= 0 {
+		return 1
+	}
+	return int32(b.config.MaxFails)
+}
@@ -0,0 +1,130 @@
+package backend
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"net/http"
+	"strings"
+	"text/template"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// ErrorHandler answers a request whose proxying to a backend failed with err
+type ErrorHandler func(w http.ResponseWriter, r *http.Request, err error)
+
+// ErrorPolicy decides how failed proxy attempts are answered and whether
+// they count against the backend's health
+type ErrorPolicy struct {
+	// Handler writes the answer (502 Bad Gateway when nil)
+	Handler ErrorHandler
+	// KeepUp stops proxy errors from counting towards MaxFails, leaving it
+	// to health checks to mark the backend down
+	KeepUp bool
+}
+
+// SetErrorPolicy changes how the backend answers failed proxy attempts.
+// Oversized bodies (413) and clients that went away are handled before it.
+func (b *Backend) SetErrorPolicy(p ErrorPolicy) {
+	b.errorPolicy.Store(&p)
+}
+
+// ErrorPage configures the answer to failed proxy attempts from the config
+// file
+type ErrorPage struct {
+	// Status replaces 502 Bad Gateway
+	Status int `json:"status,omitempty"`
+	// ContentType of Body (text/plain; charset=utf-8 by default); HTML
+	// bodies escape the values they insert
+	ContentType string `json:"contentType,omitempty"`
+	// Body is a Go template receiving ErrorPageData
+	Body string `json:"body,omitempty"`
+	// MarkDown set to false keeps proxy errors from marking backends down
+	MarkDown *bool `json:"markDown,omitempty"`
+}
+
+// ErrorPageData is available to ErrorPage body templates
+type ErrorPageData struct {
+	Status     int
+	StatusText string
+	// Class is the failure class, e.g. connection_refused (see ErrorClasses)
+	Class     string
+	Error     string
+	Method    string
+	Path      string
+	RequestID string
+}
+
+// IsZero reports whether the error page keeps the defaults
+func (p ErrorPage) IsZero() bool {
+	return p.Status == 0 && p.ContentType == "" && p.Body == "" && p.MarkDown == nil
+}
+
+// Validate checks the status and body template
+func (p ErrorPage) Validate() error {
+	_, err := p.Policy()
+	return err
+}
+
+// Policy builds the error policy the page describes
+func (p ErrorPage) Policy() (ErrorPolicy, error) {
+	policy := ErrorPolicy{KeepUp: p.MarkDown != nil && !*p.MarkDown}
+	if p.Status == 0 && p.ContentType == "" && p.Body == "" {
+		return policy, nil
+	}
+	status := p.Status
+	if status == 0 {
+		status = http.StatusBadGateway
+	}
+	if status < 400 || status > 599 {
+		return policy, fmt.Errorf("status %d is not an error status (400-599)", status)
+	}
+	contentType := p.ContentType
+	if contentType == "" {
+		contentType = "text/plain; charset=utf-8"
+	}
+	body := p.Body
+	if body == "" {
+		body = "{{.StatusText}}\n"
+	}
+
+	// HTML bodies get contextual escaping, anything else is inserted as is
+	var execute func(buf *bytes.Buffer, data ErrorPageData) error
+	if strings.HasPrefix(contentType, "text/html") {
+		tmpl, err := htmltemplate.New("errorPage").Parse(body)
+		if err != nil {
+			return policy, fmt.Errorf("body: %w", err)
+		}
+		execute = func(buf *bytes.Buffer, data ErrorPageData) error { return tmpl.Execute(buf, data) }
+	} else {
+		tmpl, err := template.New("errorPage").Parse(body)
+		if err != nil {
+			return policy, fmt.Errorf("body: %w", err)
+		}
+		execute = func(buf *bytes.Buffer, data ErrorPageData) error { return tmpl.Execute(buf, data) }
+	}
+
+	policy.Handler = func(w http.ResponseWriter, r *http.Request, err error) {
+		data := ErrorPageData{
+			Status:     status,
+			StatusText: http.StatusText(status),
+			Class:      ClassifyError(r.Context(), err),
+			Error:      err.Error(),
+			Method:     r.Method,
+			Path:       r.URL.Path,
+			RequestID:  logging.RequestID(r.Context()),
+		}
+		var buf bytes.Buffer
+		if err := execute(&buf, data); err != nil {
+			logging.Logger().ErrorContext(r.Context(), "error page template failed", "error", err)
+			http.Error(w, data.StatusText, status)
+			return
+		}
+		w.Header().Set("Content-Type", contentType)
+		w.Header().Set("X-Content-Type-Options", "nosniff")
+		w.WriteHeader(status)
+		w.Write(buf.Bytes())
+	}
+	return policy, nil
+}
@@ -0,0 +1,104 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// refusedURL returns the address of a closed server, which refuses connections
+func refusedURL(t *testing.T) string {
+	t.Helper()
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+	return server.URL
+}
+
+func TestErrorPage(t *testing.T) {
+	keepUp := false
+	tests := []struct {
+		name        string
+		page        ErrorPage
+		status      int
+		contentType string
+		body        string
+		alive       bool
+	}{
+		{
+			name:        "default",
+			page:        ErrorPage{},
+			status:      http.StatusBadGateway,
+			contentType: "text/plain; charset=utf-8",
+			body:        "Bad Gateway\n",
+			alive:       false,
+		},
+		{
+			name: "JSON body",
+			page: ErrorPage{
+				Status:      http.StatusServiceUnavailable,
+				ContentType: "application/json",
+				Body:        `{"error":"{{.Class}}","path":"{{.Path}}"}`,
+			},
+			status:      http.StatusServiceUnavailable,
+			contentType: "application/json",
+			body:        `{"error":"connection_refused","path":"/a<b>"}`,
+			alive:       false,
+		},
+		{
+			name: "HTML body escapes values",
+			page: ErrorPage{
+				ContentType: "text/html; charset=utf-8",
+				Body:        "<h1>{{.Status}} {{.StatusText}}</h1><p>{{.Path}}</p>",
+				MarkDown:    &keepUp,
+			},
+			status:      http.StatusBadGateway,
+			contentType: "text/html; charset=utf-8",
+			body:        "<h1>502 Bad Gateway</h1><p>/a&lt;b&gt;</p>",
+			alive:       true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBackendWithConfig(Config{URL: refusedURL(t), MaxFails: 1})
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			policy, err := tt.page.Policy()
+			if err != nil {
+				t.Fatalf("Failed to build the error policy: %v", err)
+			}
+			b.SetErrorPolicy(policy)
+
+			rec := httptest.NewRecorder()
+			b.Serve(rec, httptest.NewRequest(http.MethodGet, "/a<b>", nil))
+
+			if rec.Code != tt.status {
+				t.Errorf("Expected status %d, got %d", tt.status, rec.Code)
+			}
+			if got := rec.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Expected content type %q, got %q", tt.contentType, got)
+			}
+			if got := rec.Body.String(); got != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, got)
+			}
+			if b.IsAlive() != tt.alive {
+				t.Errorf("Expected alive %v, got %v", tt.alive, b.IsAlive())
+			}
+		})
+	}
+}
+
+func TestErrorPage_Validate(t *testing.T) {
+	for _, page := range []ErrorPage{
+		{Status: 200},
+		{Body: "{{.Missing"},
+	} {
+		if err := page.Validate(); err == nil {
+			t.Errorf("Expected an error for %+v", page)
+		}
+	}
+	if err := (ErrorPage{Body: "Upstream unavailable"}).Validate(); err != nil {
+		t.Errorf("Expected a valid page, got %v", err)
+	}
+}
@@ -0,0 +1,131 @@
+package backend
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"syscall"
+)
+
+// Failure classes used in metrics and stats
+const (
+	ErrorDialTimeout     = "dial_timeout"
+	ErrorConnRefused     = "connection_refused"
+	ErrorConnReset       = "connection_reset"
+	ErrorTLS             = "tls_error"
+	ErrorUpstreamTimeout = "upstream_timeout"
+	ErrorUpstream5xx     = "upstream_5xx"
+	ErrorClientCanceled  = "client_canceled"
+	ErrorNoBackend       = "no_backend"
+	ErrorPanic           = "panic"
+	ErrorRequestTooLarge = "request_too_large"
+	ErrorOther           = "other"
+)
+
+// StatusClientClosedRequest is the status (borrowed from nginx) recorded for
+// requests whose client went away before the answer
+const StatusClientClosedRequest = 499
+
+// ErrorClasses lists every failure class in a stable order
+var ErrorClasses = []string{
+	ErrorDialTimeout,
+	ErrorConnRefused,
+	ErrorConnReset,
+	ErrorTLS,
+	ErrorUpstreamTimeout,
+	ErrorUpstream5xx,
+	ErrorClientCanceled,
+	ErrorNoBackend,
+	ErrorPanic,
+	ErrorRequestTooLarge,
+	ErrorOther,
+}
+
+// ClassifyError maps a proxy error to one of the failure classes; ctx is the
+// incoming request's context, used to tell client cancellations apart
+func ClassifyError(ctx context.Context, err error) string {
+	if err == nil {
+		return ""
+	}
+	var timeout *phaseTimeout
+	if errors.As(err, &timeout) {
+		return ErrorUpstreamTimeout
+	}
+	if errors.Is(err, context.Canceled) || (ctx != nil && errors.Is(ctx.Err(), context.Canceled)) {
+		return ErrorClientCanceled
+	}
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		return ErrorRequestTooLarge
+	}
+	if errors.Is(err, syscall.ECONNREFUSED) {
+		return ErrorConnRefused
+	}
+	if errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.EPIPE) {
+		return ErrorConnReset
+	}
+	if isTLSError(err) {
+		return ErrorTLS
+	}
+
+	var opErr *net.OpError
+	if errors.As(err, &opErr) && opErr.Op == "dial" && opErr.Timeout() {
+		return ErrorDialTimeout
+	}
+	var netErr net.Error
+	if errors.Is(err, context.DeadlineExceeded) || (errors.As(err, &netErr) && netErr.Timeout()) {
+		return ErrorUpstreamTimeout
+	}
+	return ErrorOther
+}
+
+func isTLSError(err error) bool {
+	var recordErr tls.RecordHeaderError
+	var verifyErr *tls.CertificateVerificationError
+	var unknownAuthority x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var invalidErr x509.CertificateInvalidError
+	switch {
+	case errors.As(err, &recordErr),
+		errors.As(err, &verifyErr),
+		errors.As(err, &unknownAuthority),
+		errors.As(err, &hostnameErr),
+		errors.As(err, &invalidErr):
+		return true
+	}
+	return strings.Contains(err.Error(), "tls:")
+}
+
+type proxyErrorKey struct{}
+
+// errorSlot receives the proxy error for a single request
+type errorSlot struct {
+	err error
+}
+
+// ServeRequest proxies the request like Serve and returns the proxy error,
+// if any, that caused a Bad Gateway response
+func (b *Backend) ServeRequest(w http.ResponseWriter, r *http.Request) error {
+	slot := &errorSlot{}
+	b.Serve(w, r.WithContext(context.WithValue(r.Context(), proxyErrorKey{}, slot)))
+	return slot.err
+}
+
+// ServeAcquired proxies like ServeRequest a request holding a slot from
+// TryAcquire, and releases the slot
+func (b *Backend) ServeAcquired(w http.ResponseWriter, r *http.Request) error {
+	slot := &errorSlot{}
+	b.serve(w, r.WithContext(context.WithValue(r.Context(), proxyErrorKey{}, slot)))
+	return slot.err
+}
+
+// recordProxyError stores err in the request's error slot when ServeRequest is used
+func recordProxyError(r *http.Request, err error) {
+	if slot, ok := r.Context().Value(proxyErrorKey{}).(*errorSlot); ok {
+		slot.err = err
+	}
+}
@@ -0,0 +1,52 @@
+package backend
+
+import (
+	"context"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+)
+
+type timeoutError struct{}
+
+func (timeoutError) Error() string   { return "i/o timeout" }
+func (timeoutError) Timeout() bool   { return true }
+func (timeoutError) Temporary() bool { return true }
+
+func TestClassifyError(t *testing.T) {
+	canceled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	dialRefused := &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}
+	dialTimeout := &net.OpError{Op: "dial", Net: "tcp", Err: timeoutError{}}
+	readTimeout := &net.OpError{Op: "read", Net: "tcp", Err: timeoutError{}}
+
+	tests := []struct {
+		name string
+		ctx  context.Context
+		err  error
+		want string
+	}{
+		{"nil", context.Background(), nil, ""},
+		{"connection refused", context.Background(), dialRefused, ErrorConnRefused},
+		{"dial timeout", context.Background(), dialTimeout, ErrorDialTimeout},
+		{"read timeout", context.Background(), readTimeout, ErrorUpstreamTimeout},
+		{"deadline", context.Background(), fmt.Errorf("wrapped: %w", context.DeadlineExceeded), ErrorUpstreamTimeout},
+		{"reset", context.Background(), fmt.Errorf("read: %w", syscall.ECONNRESET), ErrorConnReset},
+		{"tls", context.Background(), x509.UnknownAuthorityError{}, ErrorTLS},
+		{"client canceled", canceled, errors.New("anything"), ErrorClientCanceled},
+		{"other", context.Background(), errors.New("boom"), ErrorOther},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ClassifyError(tt.ctx, tt.err); got != tt.want {
+				t.Errorf("ClassifyError() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
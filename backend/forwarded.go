@@ -0,0 +1,151 @@
+package backend
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+	"sync/atomic"
+)
+
+// trustedProxies holds the networks whose forwarding headers are kept
+var trustedProxies atomic.Pointer[[]netip.Prefix]
+
+// SetTrustedProxies sets the proxies (CIDRs or single addresses) allowed to
+// forward requests on behalf of clients. Requests arriving from one of them
+// keep their X-Forwarded-*, Forwarded and X-Real-IP information, extended
+// with the hop to the backend; from anywhere else those headers are
+// replaced, so clients can't spoof their address. Empty trusts no proxy.
+func SetTrustedProxies(proxies []string) error {
+	prefixes, err := ParseTrustedProxies(proxies)
+	if err != nil {
+		return err
+	}
+	trustedProxies.Store(&prefixes)
+	return nil
+}
+
+// ParseTrustedProxies parses CIDRs or single addresses
+func ParseTrustedProxies(proxies []string) ([]netip.Prefix, error) {
+	prefixes := make([]netip.Prefix, 0, len(proxies))
+	for _, p := range proxies {
+		if strings.Contains(p, "/") {
+			prefix, err := netip.ParsePrefix(p)
+			if err != nil {
+				return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+			}
+			prefixes = append(prefixes, prefix.Masked())
+			continue
+		}
+		addr, err := netip.ParseAddr(p)
+		if err != nil {
+			return nil, fmt.Errorf("trusted proxy %q: %w", p, err)
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+	}
+	return prefixes, nil
+}
+
+// trusted reports whether ip belongs to a trusted proxy
+func trusted(ip string) bool {
+	prefixes := trustedProxies.Load()
+	if prefixes == nil || len(*prefixes) == 0 {
+		return false
+	}
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap()
+	for _, prefix := range *prefixes {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// setForwardedHeaders describes the client to the backend on the outgoing
+// request out. The reverse proxy appends the peer address to
+// X-Forwarded-For afterwards, so the header is only cleared here when the
+// peer isn't trusted.
+func setForwardedHeaders(out *http.Request) {
+	peer, _, err := net.SplitHostPort(out.RemoteAddr)
+	if err != nil {
+		peer = out.RemoteAddr
+	}
+	fromProxy := trusted(peer)
+
+	scheme := "http"
+	if out.TLS != nil {
+		scheme = "https"
+	}
+	proto, host := scheme, out.Host
+
+	// chain lists the addresses the request was forwarded for, before the peer
+	var chain []string
+	if fromProxy {
+		for _, v := range out.Header.Values("X-Forwarded-For") {
+			for _, ip := range strings.Split(v, ",") {
+				if ip = strings.TrimSpace(ip); ip != "" {
+					chain = append(chain, ip)
+				}
+			}
+		}
+		if v := out.Header.Get("X-Forwarded-Proto"); v != "" {
+			proto = v
+		}
+		if v := out.Header.Get("X-Forwarded-Host"); v != "" {
+			host = v
+		}
+	} else {
+		out.Header.Del("X-Forwarded-For")
+		out.Header.Del("Forwarded")
+		out.Header.Del("X-Real-IP")
+	}
+	out.Header.Set("X-Forwarded-Host", host)
+	out.Header.Set("X-Forwarded-Proto", proto)
+
+	node := "unknown"
+	if peer != "" {
+		node = forwardedNode(peer)
+		out.Header.Set("X-Real-IP", clientAddress(append(chain, peer)))
+	}
+	element := "for=" + node + ";host=" + quoteForwarded(out.Host) + ";proto=" + scheme
+	if prior := strings.Join(out.Header.Values("Forwarded"), ", "); prior != "" {
+		element = prior + ", " + element
+	}
+	out.Header.Set("Forwarded", element)
+}
+
+// clientAddress returns the address of the client in a forwarding chain:
+// the rightmost hop that isn't a trusted proxy, or the first one when they
+// all are
+func clientAddress(chain []string) string {
+	for i := len(chain) - 1; i > 0; i-- {
+		if !trusted(chain[i]) {
+			return chain[i]
+		}
+	}
+	return chain[0]
+}
+
+// forwardedNode formats an address as an RFC 7239 node, quoting and
+// bracketing IPv6 addresses
+func forwardedNode(ip string) string {
+	if strings.Contains(ip, ":") {
+		return `"[` + ip + `]"`
+	}
+	return ip
+}
+
+// quoteForwarded quotes an RFC 7239 value unless it is a plain token
+func quoteForwarded(v string) string {
+	for _, c := range v {
+		if !(c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9' || strings.ContainsRune("!#$%&'*+-.^_`|~", c)) {
+			return `"` + strings.ReplaceAll(v, `"`, `\"`) + `"`
+		}
+	}
+	return v
+}
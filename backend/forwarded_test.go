@@ -0,0 +1,119 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestForwardedHeaders(t *testing.T) {
+	var got http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer server.Close()
+
+	b, err := NewBackend(server.URL)
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	if err := SetTrustedProxies([]string{"10.0.0.0/8", "192.0.2.7"}); err != nil {
+		t.Fatalf("Failed to set trusted proxies: %v", err)
+	}
+	t.Cleanup(func() { SetTrustedProxies(nil) })
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		headers    map[string]string
+		xff        string
+		realIP     string
+		proto      string
+		host       string
+		forwarded  string
+	}{
+		{
+			name:       "direct client",
+			remoteAddr: "203.0.113.5:4000",
+			xff:        "203.0.113.5",
+			realIP:     "203.0.113.5",
+			proto:      "http",
+			host:       "shop.example",
+			forwarded:  "for=203.0.113.5;host=shop.example;proto=http",
+		},
+		{
+			name:       "spoofed headers from an untrusted client",
+			remoteAddr: "203.0.113.5:4000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "1.2.3.4",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "evil.example",
+				"X-Real-IP":         "1.2.3.4",
+				"Forwarded":         "for=1.2.3.4",
+			},
+			xff:       "203.0.113.5",
+			realIP:    "203.0.113.5",
+			proto:     "http",
+			host:      "shop.example",
+			forwarded: "for=203.0.113.5;host=shop.example;proto=http",
+		},
+		{
+			name:       "trusted proxy chain",
+			remoteAddr: "10.1.2.3:4000",
+			headers: map[string]string{
+				"X-Forwarded-For":   "198.51.100.9, 192.0.2.7",
+				"X-Forwarded-Proto": "https",
+				"X-Forwarded-Host":  "www.example",
+				"Forwarded":         "for=198.51.100.9;proto=https",
+			},
+			xff:       "198.51.100.9, 192.0.2.7, 10.1.2.3",
+			realIP:    "198.51.100.9",
+			proto:     "https",
+			host:      "www.example",
+			forwarded: "for=198.51.100.9;proto=https, for=10.1.2.3;host=shop.example;proto=http",
+		},
+		{
+			name:       "IPv6 client",
+			remoteAddr: "[2001:db8::1]:4000",
+			xff:        "2001:db8::1",
+			realIP:     "2001:db8::1",
+			proto:      "http",
+			host:       "shop.example",
+			forwarded:  `for="[2001:db8::1]";host=shop.example;proto=http`,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "http://shop.example/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			b.Serve(httptest.NewRecorder(), req)
+
+			for header, want := range map[string]string{
+				"X-Forwarded-For":   tt.xff,
+				"X-Real-IP":         tt.realIP,
+				"X-Forwarded-Proto": tt.proto,
+				"X-Forwarded-Host":  tt.host,
+				"Forwarded":         tt.forwarded,
+			} {
+				if got.Get(header) != want {
+					t.Errorf("Expected %s %q, got %q", header, want, got.Get(header))
+				}
+			}
+		})
+	}
+}
+
+func TestParseTrustedProxies(t *testing.T) {
+	if _, err := ParseTrustedProxies([]string{"10.0.0.0/8", "::1", "fd00::/8"}); err != nil {
+		t.Errorf("Expected valid proxies, got %v", err)
+	}
+	for _, invalid := range []string{"10.0.0.0/33", "proxy.local"} {
+		if _, err := ParseTrustedProxies([]string{invalid}); err == nil {
+			t.Errorf("Expected an error for %q", invalid)
+		}
+	}
+}
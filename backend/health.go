@@ -0,0 +1,153 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"maps"
+	"net/http"
+	"net/url"
+	"slices"
+	"strings"
+)
+
+// maxHealthBody bounds the part of a probe response searched for
+// ExpectedBody
+const maxHealthBody = 64 << 10
+
+// HealthCheck describes the request of an active health check and the
+// answer that passes it; zero fields keep the defaults
+type HealthCheck struct {
+	// Path is probed instead of the backend URL
+	Path string `json:"path,omitempty"`
+	// Method defaults to GET
+	Method string `json:"method,omitempty"`
+	// Headers are sent with the probe, e.g. an auth token; "Host" sets the
+	// request host
+	Headers map[string]string `json:"headers,omitempty"`
+	// ExpectedStatus lists the passing status codes (default: any 2xx or
+	// 3xx)
+	ExpectedStatus []int `json:"expectedStatus,omitempty"`
+	// ExpectedBody must appear in the first 64KB of the response body
+	ExpectedBody string `json:"expectedBody,omitempty"`
+}
+
+// Validate checks the method and the expected status codes
+func (c HealthCheck) Validate() error {
+	if c.Method != "" && (strings.ContainsAny(c.Method, " \t\r\n") || strings.ToUpper(c.Method) != c.Method) {
+		return fmt.Errorf("method %q is not an upper-case HTTP method", c.Method)
+	}
+	for _, status := range c.ExpectedStatus {
+		if status < 100 || status > 599 {
+			return fmt.Errorf("expected status %d is out of range (100-599)", status)
+		}
+	}
+	return nil
+}
+
+// merge returns c with the fields set in override replacing its own;
+// headers are combined, override winning
+func (c HealthCheck) merge(override HealthCheck) HealthCheck {
+	if override.Path != "" {
+		c.Path = override.Path
+	}
+	if override.Method != "" {
+		c.Method = override.Method
+	}
+	if len(override.Headers) > 0 {
+		headers := maps.Clone(c.Headers)
+		if headers == nil {
+			headers = make(map[string]string, len(override.Headers))
+		}
+		maps.Copy(headers, override.Headers)
+		c.Headers = headers
+	}
+	if len(override.ExpectedStatus) > 0 {
+		c.ExpectedStatus = override.ExpectedStatus
+	}
+	if override.ExpectedBody != "" {
+		c.ExpectedBody = override.ExpectedBody
+	}
+	return c
+}
+
+// URL returns the probed URL of a backend at base
+func (c HealthCheck) URL(base *url.URL) string {
+	if c.Path == "" {
+		return base.String()
+	}
+	u := *base
+	u.Path = c.Path
+	u.RawQuery = ""
+	return u.String()
+}
+
+// NewRequest creates the probe request for a backend at base
+func (c HealthCheck) NewRequest(ctx context.Context, base *url.URL) (*http.Request, error) {
+	method := c.Method
+	if method == "" {
+		method = http.MethodGet
+	}
+	req, err := http.NewRequestWithContext(ctx, method, c.URL(base), nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	for k, v := range c.Headers {
+		if strings.EqualFold(k, "Host") {
+			req.Host = v
+			continue
+		}
+		req.Header.Set(k, v)
+	}
+	return req, nil
+}
+
+// Check reports why resp fails the health check, or nil when it passes.
+// The caller closes the body.
+func (c HealthCheck) Check(resp *http.Response) error {
+	if len(c.ExpectedStatus) > 0 {
+		if !slices.Contains(c.ExpectedStatus, resp.StatusCode) {
+			return fmt.Errorf("returned status %d, expected %v", resp.StatusCode, c.ExpectedStatus)
+		}
+	} else if resp.StatusCode < 200 || resp.StatusCode >= 400 {
+		return fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	if c.ExpectedBody == "" {
+		return nil
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxHealthBody))
+	if err != nil {
+		return fmt.Errorf("failed to read body: %w", err)
+	}
+	if !strings.Contains(string(body), c.ExpectedBody) {
+		return fmt.Errorf("body does not contain %q", c.ExpectedBody)
+	}
+	return nil
+}
+
+// SetHealthDefaults sets the health check the backend's own health settings
+// apply on top of; the health checker probing the backend sets it
+func (b *Backend) SetHealthDefaults(c HealthCheck) {
+	b.healthDefaults.Store(&c)
+}
+
+// HealthCheck returns the effective health check of the backend: its own
+// health settings over the defaults
+func (b *Backend) HealthCheck() HealthCheck {
+	var c HealthCheck
+	if defaults := b.healthDefaults.Load(); defaults != nil {
+		c = *defaults
+	}
+	return c.merge(HealthCheck{
+		Path:           b.config.HealthPath,
+		Method:         b.config.HealthMethod,
+		Headers:        b.config.HealthHeaders,
+		ExpectedStatus: b.config.HealthStatus,
+		ExpectedBody:   b.config.HealthBody,
+	})
+}
+
+// HealthURL returns the URL probed by active health checks
+func (b *Backend) HealthURL() string {
+	return b.HealthCheck().URL(b.URL)
+}
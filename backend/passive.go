@@ -0,0 +1,142 @@
+package backend
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// halfOpenTimeout bounds a half-open probe when no ResponseTimeout is set
+const halfOpenTimeout = 5 * time.Second
+
+// passiveState tracks the proxy errors of a backend: the streak counted
+// towards MaxFails and, once they marked it down, the half-open probes that
+// may bring it back
+type passiveState struct {
+	mu sync.Mutex
+	// streakStart is when the current failure streak began
+	streakStart time.Time
+	// timer fires the next half-open probe while the backend is down
+	// because of proxy errors
+	timer   *time.Timer
+	retired bool
+	// probes counts half-open probes, recovered the ones that passed
+	probes    atomic.Int64
+	recovered atomic.Int64
+}
+
+// countFailure adds a proxy error to the streak and reports whether it
+// reached MaxFails. With a FailWindow, a streak older than the window
+// starts over.
+func (b *Backend) countFailure() bool {
+	now := time.Now()
+	b.passive.mu.Lock()
+	defer b.passive.mu.Unlock()
+	if window := b.config.FailWindow; window > 0 && now.Sub(b.passive.streakStart) > window {
+		atomic.StoreInt32(&b.FailCount, 0)
+	}
+	if atomic.LoadInt32(&b.FailCount) == 0 {
+		b.passive.streakStart = now
+	}
+	return atomic.AddInt32(&b.FailCount, 1) >= b.maxFails()
+}
+
+// markFailed takes the backend out of rotation after MaxFails proxy
+// errors. With a FailTimeout it is probed half-open once the timeout has
+// passed, instead of waiting for the active health checks.
+func (b *Backend) markFailed() {
+	b.SetAlive(false)
+	if b.config.FailTimeout <= 0 {
+		return
+	}
+	b.passive.mu.Lock()
+	defer b.passive.mu.Unlock()
+	if b.passive.timer == nil && !b.passive.retired {
+		b.passive.timer = time.AfterFunc(b.config.FailTimeout, b.halfOpen)
+	}
+}
+
+// halfOpen probes the health URL of a backend marked down by proxy errors;
+// it returns to rotation when the probe passes, and is probed again after
+// FailTimeout otherwise
+func (b *Backend) halfOpen() {
+	b.passive.mu.Lock()
+	if b.passive.timer == nil || b.passive.retired {
+		// Revived or removed meanwhile
+		b.passive.mu.Unlock()
+		return
+	}
+	b.passive.mu.Unlock()
+
+	b.passive.probes.Add(1)
+	err := b.probeHealth()
+
+	b.passive.mu.Lock()
+	if b.passive.timer == nil || b.passive.retired {
+		b.passive.mu.Unlock()
+		return
+	}
+	if err != nil {
+		b.passive.timer.Reset(b.config.FailTimeout)
+		b.passive.mu.Unlock()
+		logging.Logger().Info("backend failed its half-open probe", "backend", b.address, "error", err)
+		return
+	}
+	b.passive.timer = nil
+	b.passive.mu.Unlock()
+
+	b.passive.recovered.Add(1)
+	b.ResetFailCount()
+	b.SetAlive(true)
+	logging.Logger().Info("backend passed its half-open probe", "backend", b.address)
+}
+
+// probeHealth runs the backend's health check through its transport
+func (b *Backend) probeHealth() error {
+	timeout := b.config.ResponseTimeout
+	if timeout <= 0 {
+		timeout = halfOpenTimeout
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+	check := b.HealthCheck()
+	req, err := check.NewRequest(ctx, b.URL)
+	if err != nil {
+		return err
+	}
+	resp, err := b.ReverseProxy.Transport.RoundTrip(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return check.Check(resp)
+}
+
+// stopHalfOpen cancels pending half-open probes, e.g. when a health check
+// brought the backend back
+func (b *Backend) stopHalfOpen() {
+	b.passive.mu.Lock()
+	defer b.passive.mu.Unlock()
+	if b.passive.timer != nil {
+		b.passive.timer.Stop()
+		b.passive.timer = nil
+	}
+}
+
+// Retire stops the background work of a backend leaving its pool; it keeps
+// serving the requests already proxied to it
+func (b *Backend) Retire() {
+	b.passive.mu.Lock()
+	b.passive.retired = true
+	b.passive.mu.Unlock()
+	b.stopHalfOpen()
+}
+
+// HalfOpenProbes returns how many half-open probes ran and how many of them
+// returned the backend to rotation
+func (b *Backend) HalfOpenProbes() (probes, recovered int64) {
+	return b.passive.probes.Load(), b.passive.recovered.Load()
+}
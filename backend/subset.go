@@ -0,0 +1,57 @@
+package backend
+
+import (
+	"fmt"
+	"maps"
+	"slices"
+	"strings"
+)
+
+// Subset restricts selection to the backends carrying all of Labels, e.g.
+// {"zone": "us-east-1a"} to keep traffic in the balancer's zone
+type Subset struct {
+	Labels map[string]string `json:"labels,omitempty"`
+	// Fallback lets requests go to the rest of the pool while no matching
+	// backend is available (a locality preference); without it they fail
+	// like in an empty pool
+	Fallback bool `json:"fallback,omitempty"`
+}
+
+// IsZero reports whether the subset selects the whole pool
+func (s Subset) IsZero() bool {
+	return len(s.Labels) == 0
+}
+
+// Validate checks the label names
+func (s Subset) Validate() error {
+	if s.Fallback && len(s.Labels) == 0 {
+		return fmt.Errorf("fallback requires labels")
+	}
+	for k := range s.Labels {
+		if k == "" {
+			return fmt.Errorf("label names must not be empty")
+		}
+	}
+	return nil
+}
+
+// Matches reports whether b belongs to the subset
+func (s Subset) Matches(b *Backend) bool {
+	return b.HasLabels(s.Labels)
+}
+
+// Equal reports whether both subsets select the same backends the same way
+func (s Subset) Equal(other Subset) bool {
+	return s.Fallback == other.Fallback && maps.Equal(s.Labels, other.Labels)
+}
+
+// String formats the labels as sorted "key=value" pairs, e.g.
+// "tier=gold,zone=a"
+func (s Subset) String() string {
+	pairs := make([]string, 0, len(s.Labels))
+	for k, v := range s.Labels {
+		pairs = append(pairs, k+"="+v)
+	}
+	slices.Sort(pairs)
+	return strings.Join(pairs, ",")
+}
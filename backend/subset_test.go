@@ -0,0 +1,39 @@
+package backend
+
+import "testing"
+
+func TestSubset_Matches(t *testing.T) {
+	b, err := NewBackendWithConfig(Config{URL: "http://localhost:8081", Labels: map[string]string{"zone": "a", "tier": "gold"}})
+	if err != nil {
+		t.Fatalf("NewBackendWithConfig failed: %v", err)
+	}
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{nil, true},
+		{map[string]string{"zone": "a"}, true},
+		{map[string]string{"zone": "a", "tier": "gold"}, true},
+		{map[string]string{"zone": "b"}, false},
+		{map[string]string{"zone": "a", "version": "v2"}, false},
+	}
+	for _, tt := range tests {
+		if got := (Subset{Labels: tt.labels}).Matches(b); got != tt.want {
+			t.Errorf("Matches(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+
+	s := Subset{Labels: map[string]string{"zone": "a", "tier": "gold"}, Fallback: true}
+	if got := s.String(); got != "tier=gold,zone=a" {
+		t.Errorf("String() = %q", got)
+	}
+	if !s.Equal(Subset{Labels: map[string]string{"tier": "gold", "zone": "a"}, Fallback: true}) || s.Equal(Subset{Labels: s.Labels}) {
+		t.Error("Expected subsets to be equal only with the same labels and fallback")
+	}
+	if err := (Subset{Fallback: true}).Validate(); err == nil {
+		t.Error("Expected fallback without labels to be rejected")
+	}
+	if err := (Subset{Labels: map[string]string{"": "a"}}).Validate(); err == nil {
+		t.Error("Expected an empty label name to be rejected")
+	}
+}
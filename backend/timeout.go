@@ -0,0 +1,143 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+)
+
+// Timeouts bound the phases of a proxied request on top of the backend's
+// own settings, e.g. for the route a request matched. Zero fields don't
+// apply; where both set one, the shorter wins.
+type Timeouts struct {
+	// Response is the wait for the response headers
+	Response time.Duration
+	// Idle is the longest pause between response body reads
+	Idle time.Duration
+}
+
+// shorter returns the shorter timeout of each phase, ignoring unset ones
+func (t Timeouts) shorter(other Timeouts) Timeouts {
+	pick := func(a, b time.Duration) time.Duration {
+		if a == 0 || (b > 0 && b < a) {
+			return b
+		}
+		return a
+	}
+	return Timeouts{Response: pick(t.Response, other.Response), Idle: pick(t.Idle, other.Idle)}
+}
+
+type timeoutsKey struct{}
+
+// WithTimeouts returns a context whose proxied requests are bounded by t
+func WithTimeouts(ctx context.Context, t Timeouts) context.Context {
+	if t == (Timeouts{}) {
+		return ctx
+	}
+	return context.WithValue(ctx, timeoutsKey{}, t)
+}
+
+// phaseTimeout is the cause of a request canceled by its Timeouts; it is
+// classified as upstream_timeout rather than as canceled by the client
+type phaseTimeout struct{ msg string }
+
+func (e *phaseTimeout) Error() string   { return e.msg }
+func (e *phaseTimeout) Timeout() bool   { return true }
+func (e *phaseTimeout) Temporary() bool { return true }
+
+var (
+	errResponseTimeout = &phaseTimeout{"timeout awaiting response headers"}
+	errIdleTimeout     = &phaseTimeout{"timeout awaiting response body"}
+)
+
+type timersKey struct{}
+
+// requestTimers enforce the Timeouts of one proxied request
+type requestTimers struct {
+	timeouts Timeouts
+	cancel   context.CancelCauseFunc
+	headers  *time.Timer
+}
+
+// applyTimeouts bounds r by the backend's RequestTimeout and by the
+// response and idle timeouts of the backend and the request context. stop
+// releases them once the request is done.
+func (b *Backend) applyTimeouts(r *http.Request) (_ *http.Request, stop func()) {
+	ctx, stopRequest := r.Context(), func() {}
+	if b.config.RequestTimeout > 0 {
+		ctx, stopRequest = context.WithTimeout(ctx, b.config.RequestTimeout)
+	}
+	requested, _ := ctx.Value(timeoutsKey{}).(Timeouts)
+	timeouts := Timeouts{Idle: b.config.IdleTimeout}.shorter(requested)
+	if timeouts == (Timeouts{}) {
+		return r.WithContext(ctx), stopRequest
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	timers := &requestTimers{timeouts: timeouts, cancel: cancel}
+	if timeouts.Response > 0 {
+		timers.headers = time.AfterFunc(timeouts.Response, func() { cancel(errResponseTimeout) })
+	}
+	return r.WithContext(context.WithValue(ctx, timersKey{}, timers)), func() {
+		if timers.headers != nil {
+			timers.headers.Stop()
+		}
+		cancel(nil)
+		stopRequest()
+	}
+}
+
+// startBody stops the response timeout of resp's request once its headers
+// arrived and watches the body for the idle timeout
+func startBody(resp *http.Response) {
+	timers, ok := resp.Request.Context().Value(timersKey{}).(*requestTimers)
+	if !ok {
+		return
+	}
+	if timers.headers != nil {
+		timers.headers.Stop()
+	}
+	// An upgraded connection's body must stay writable
+	if timers.timeouts.Idle > 0 && resp.StatusCode != http.StatusSwitchingProtocols {
+		resp.Body = &idleBody{
+			ReadCloser: resp.Body,
+			ctx:        resp.Request.Context(),
+			idle:       timers.timeouts.Idle,
+			timer:      time.AfterFunc(timers.timeouts.Idle, func() { timers.cancel(errIdleTimeout) }),
+		}
+	}
+}
+
+// timeoutCause returns the phase timeout that canceled ctx in place of the
+// cancellation error it caused
+func timeoutCause(ctx context.Context, err error) error {
+	var timeout *phaseTimeout
+	if errors.As(context.Cause(ctx), &timeout) {
+		return timeout
+	}
+	return err
+}
+
+// idleBody cancels the upstream request when no read completes within idle
+type idleBody struct {
+	io.ReadCloser
+	ctx   context.Context
+	idle  time.Duration
+	timer *time.Timer
+}
+
+func (b *idleBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil {
+		return n, timeoutCause(b.ctx, err)
+	}
+	b.timer.Reset(b.idle)
+	return n, nil
+}
+
+func (b *idleBody) Close() error {
+	b.timer.Stop()
+	return b.ReadCloser.Close()
+}
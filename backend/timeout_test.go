@@ -0,0 +1,105 @@
+package backend
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// stall waits until the client gives up, or a second at most
+func stall(r *http.Request) {
+	select {
+	case <-r.Context().Done():
+	case <-time.After(time.Second):
+	}
+}
+
+func TestBackend_Timeouts(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/slow":
+			stall(r)
+		case "/stream":
+			w.Write([]byte("first"))
+			w.(http.Flusher).Flush()
+			stall(r)
+			w.Write([]byte("second"))
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name     string
+		config   Config
+		timeouts Timeouts
+		path     string
+		class    string
+		body     string
+	}{
+		{"request", Config{RequestTimeout: 50 * time.Millisecond}, Timeouts{}, "/slow", ErrorUpstreamTimeout, "Bad Gateway\n"},
+		{"route response", Config{}, Timeouts{Response: 50 * time.Millisecond}, "/slow", ErrorUpstreamTimeout, "Bad Gateway\n"},
+		{"shorter response", Config{ResponseTimeout: time.Minute}, Timeouts{Response: 50 * time.Millisecond}, "/slow", ErrorUpstreamTimeout, "Bad Gateway\n"},
+		{"backend idle", Config{IdleTimeout: 50 * time.Millisecond}, Timeouts{}, "/stream", "", "first"},
+		{"route idle", Config{IdleTimeout: time.Minute}, Timeouts{Idle: 50 * time.Millisecond}, "/stream", "", "first"},
+		{"within", Config{RequestTimeout: time.Second, IdleTimeout: time.Second}, Timeouts{Response: time.Second}, "/", "", "ok"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.URL = server.URL
+			b, err := NewBackendWithConfig(tt.config)
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			ctx := WithTimeouts(context.Background(), tt.timeouts)
+			rec := httptest.NewRecorder()
+			start := time.Now()
+			err = b.ServeRequest(rec, httptest.NewRequest(http.MethodGet, tt.path, nil).WithContext(ctx))
+			if elapsed := time.Since(start); elapsed > 500*time.Millisecond {
+				t.Errorf("Expected the timeout to cut the request short, took %v", elapsed)
+			}
+			if class := ClassifyError(ctx, err); class != tt.class {
+				t.Errorf("Expected class %q, got %q (%v)", tt.class, class, err)
+			}
+			if rec.Body.String() != tt.body {
+				t.Errorf("Expected body %q, got %q", tt.body, rec.Body.String())
+			}
+		})
+	}
+}
+
+func TestBackend_TimeoutResponseTime(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stall(r)
+	}))
+	defer server.Close()
+
+	// A request cut off by the upstream timeouts counts towards the
+	// response time
+	for _, config := range []Config{{RequestTimeout: 50 * time.Millisecond}, {ResponseTimeout: 50 * time.Millisecond}} {
+		config.URL = server.URL
+		b, _ := NewBackendWithConfig(config)
+		b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		if got := b.GetResponseTime(); got < 50*time.Millisecond {
+			t.Errorf("Expected a timed-out request to record at least 50ms, got %v", got)
+		}
+	}
+
+	// One the client gave up on does not
+	b, _ := NewBackendWithConfig(Config{URL: server.URL, RequestTimeout: time.Second})
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	b.ServeRequest(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	if got := b.GetResponseTime(); got != 0 {
+		t.Errorf("Expected a canceled request not to be recorded, got %v", got)
+	}
+}
+
+func TestTimeouts_Shorter(t *testing.T) {
+	got := Timeouts{Response: time.Second, Idle: time.Second}.shorter(Timeouts{Idle: time.Millisecond})
+	if got != (Timeouts{Response: time.Second, Idle: time.Millisecond}) {
+		t.Errorf("Expected the shorter timeout per phase, got %+v", got)
+	}
+}
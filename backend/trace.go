@@ -0,0 +1,128 @@
+package backend
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptrace"
+	"sync/atomic"
+	"time"
+)
+
+// Phase is a step of a proxied request timed with httptrace
+type Phase int
+
+// Upstream phases; DNS, connect and TLS only happen on new connections
+const (
+	PhaseDNS     Phase = iota
+	PhaseConnect       // TCP connect, from the first attempt to the connection
+	PhaseTLS           // TLS handshake with https backends
+	PhaseTTFB          // from the request written to the first response byte
+	numPhases
+)
+
+// Phases lists the timed phases in order
+var Phases = []Phase{PhaseDNS, PhaseConnect, PhaseTLS, PhaseTTFB}
+
+var phaseNames = [numPhases]string{"dns", "connect", "tls", "ttfb"}
+
+// String returns the phase name used in metrics
+func (p Phase) String() string {
+	return phaseNames[p]
+}
+
+// PhaseStats counts the requests that went through a phase and the time
+// spent in it
+type PhaseStats struct {
+	Count int64         `json:"count"`
+	Total time.Duration `json:"total"`
+}
+
+// Mean returns the average time spent in the phase
+func (s PhaseStats) Mean() time.Duration {
+	if s.Count == 0 {
+		return 0
+	}
+	return s.Total / time.Duration(s.Count)
+}
+
+// PhaseTimings holds the timings of every phase of a backend. Slow DNS,
+// connect or TLS point at the network, a slow TTFB at the backend itself.
+type PhaseTimings struct {
+	DNS     PhaseStats `json:"dns"`
+	Connect PhaseStats `json:"connect"`
+	TLS     PhaseStats `json:"tls"`
+	TTFB    PhaseStats `json:"ttfb"`
+}
+
+// phaseCounter accumulates one phase of a backend
+type phaseCounter struct {
+	count atomic.Int64
+	nanos atomic.Int64
+}
+
+// PhaseStats returns the timings of one phase
+func (b *Backend) PhaseStats(p Phase) PhaseStats {
+	return PhaseStats{Count: b.phases[p].count.Load(), Total: time.Duration(b.phases[p].nanos.Load())}
+}
+
+// PhaseTimings returns the timings of every phase
+func (b *Backend) PhaseTimings() PhaseTimings {
+	return PhaseTimings{
+		DNS:     b.PhaseStats(PhaseDNS),
+		Connect: b.PhaseStats(PhaseConnect),
+		TLS:     b.PhaseStats(PhaseTLS),
+		TTFB:    b.PhaseStats(PhaseTTFB),
+	}
+}
+
+// traceEpoch anchors the monotonic clock readings stored in phaseTrace
+var traceEpoch = time.Now()
+
+func monotonic() int64 {
+	return int64(time.Since(traceEpoch))
+}
+
+// phaseTrace times the phases of one outgoing request. The hooks run on the
+// transport's dial, write and read goroutines, so the start times are
+// atomics, cleared once the phase is recorded.
+type phaseTrace struct {
+	backend                                *Backend
+	dnsStart, connectStart, tlsStart, sent atomic.Int64
+}
+
+// traceRequest attaches the phase timers to the outgoing request
+func (b *Backend) traceRequest(out *http.Request) {
+	t := &phaseTrace{backend: b}
+	*out = *out.WithContext(httptrace.WithClientTrace(out.Context(), &httptrace.ClientTrace{
+		DNSStart: func(httptrace.DNSStartInfo) { t.dnsStart.Store(monotonic()) },
+		DNSDone: func(info httptrace.DNSDoneInfo) {
+			t.done(&t.dnsStart, PhaseDNS, info.Err)
+		},
+		// Dual-stack dials race several connects; time from the first one
+		ConnectStart: func(string, string) { t.connectStart.CompareAndSwap(0, monotonic()) },
+		ConnectDone: func(_, _ string, err error) {
+			t.done(&t.connectStart, PhaseConnect, err)
+		},
+		TLSHandshakeStart: func() { t.tlsStart.Store(monotonic()) },
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			t.done(&t.tlsStart, PhaseTLS, err)
+		},
+		WroteRequest: func(info httptrace.WroteRequestInfo) {
+			if info.Err == nil {
+				t.sent.Store(monotonic())
+			}
+		},
+		GotFirstResponseByte: func() { t.done(&t.sent, PhaseTTFB, nil) },
+	}))
+}
+
+// done records the phase started at *start, unless it failed
+func (t *phaseTrace) done(start *atomic.Int64, p Phase, err error) {
+	began := start.Swap(0)
+	if began == 0 || err != nil {
+		return
+	}
+	counter := &t.backend.phases[p]
+	counter.count.Add(1)
+	counter.nanos.Add(monotonic() - began)
+}
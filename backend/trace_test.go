@@ -0,0 +1,53 @@
+package backend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBackend_PhaseTimings(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	// A host name, so the address is resolved
+	url := strings.Replace(server.URL, "127.0.0.1", "localhost", 1)
+	b, err := NewBackendWithConfig(Config{URL: url, TLS: TLSConfig{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	for range 2 {
+		rr := httptest.NewRecorder()
+		b.Serve(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rr.Code != http.StatusOK {
+			t.Fatalf("Expected 200, got %d", rr.Code)
+		}
+	}
+
+	timings := b.PhaseTimings()
+	tests := []struct {
+		phase Phase
+		stats PhaseStats
+		count int64
+	}{
+		{PhaseDNS, timings.DNS, 1},
+		{PhaseConnect, timings.Connect, 1},
+		{PhaseTLS, timings.TLS, 1},
+		{PhaseTTFB, timings.TTFB, 2},
+	}
+	for _, tt := range tests {
+		if tt.stats.Count != tt.count || tt.stats.Total <= 0 {
+			t.Errorf("Expected %d timed %s phases, got %+v", tt.count, tt.phase, tt.stats)
+		}
+		if b.PhaseStats(tt.phase) != tt.stats {
+			t.Errorf("Expected PhaseStats(%s) to match PhaseTimings", tt.phase)
+		}
+	}
+	if mean := timings.TTFB.Mean(); mean < 10*time.Millisecond {
+		t.Errorf("Expected the TTFB to include the backend's 10ms, got %s", mean)
+	}
+}
@@ -0,0 +1,277 @@
+package backend
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// TransportConfig tunes the HTTP connection pool towards backends; zero
+// fields keep the defaults of http.DefaultTransport
+type TransportConfig struct {
+	MaxIdleConns        int           `json:"maxIdleConns,omitempty"`
+	MaxIdleConnsPerHost int           `json:"maxIdleConnsPerHost,omitempty"`
+	MaxConnsPerHost     int           `json:"maxConnsPerHost,omitempty"`
+	IdleConnTimeout     time.Duration `json:"idleConnTimeout,omitempty"`
+	DisableCompression  *bool         `json:"disableCompression,omitempty"`
+	ForceAttemptHTTP2   *bool         `json:"forceAttemptHTTP2,omitempty"`
+	// H2C speaks HTTP/2 without TLS to http backends, as gRPC servers
+	// expect; https backends must then negotiate HTTP/2 as well
+	H2C *bool `json:"h2c,omitempty"`
+
+	// ReapInterval is how often backends are checked for idle connections
+	// beyond MaxIdlePerBackend (0 disables the reaper; top-level only)
+	ReapInterval time.Duration `json:"reapInterval,omitempty"`
+	// MaxIdlePerBackend is the number of idle connections a backend may keep
+	// between bursts; above it the reaper closes the idle connections of its
+	// transport (0 = no limit)
+	MaxIdlePerBackend int `json:"maxIdlePerBackend,omitempty"`
+}
+
+// IsZero reports whether no transport option is set
+func (c TransportConfig) IsZero() bool {
+	return c.MaxIdleConns == 0 && c.MaxIdleConnsPerHost == 0 && c.MaxConnsPerHost == 0 &&
+		c.IdleConnTimeout == 0 && c.DisableCompression == nil && c.ForceAttemptHTTP2 == nil &&
+		c.H2C == nil && c.ReapInterval == 0 && c.MaxIdlePerBackend == 0
+}
+
+// Validate checks the pool limits
+func (c TransportConfig) Validate() error {
+	switch {
+	case c.MaxIdleConns < 0 || c.MaxIdleConnsPerHost < 0 || c.MaxConnsPerHost < 0 || c.MaxIdlePerBackend < 0:
+		return fmt.Errorf("connection limits must not be negative")
+	case c.IdleConnTimeout < 0:
+		return fmt.Errorf("idleConnTimeout must not be negative")
+	case c.ReapInterval < 0:
+		return fmt.Errorf("reapInterval must not be negative")
+	}
+	return nil
+}
+
+// merge returns c with the fields set in override replacing its own
+func (c TransportConfig) merge(override TransportConfig) TransportConfig {
+	if override.MaxIdleConns != 0 {
+		c.MaxIdleConns = override.MaxIdleConns
+	}
+	if override.MaxIdleConnsPerHost != 0 {
+		c.MaxIdleConnsPerHost = override.MaxIdleConnsPerHost
+	}
+	if override.MaxConnsPerHost != 0 {
+		c.MaxConnsPerHost = override.MaxConnsPerHost
+	}
+	if override.IdleConnTimeout != 0 {
+		c.IdleConnTimeout = override.IdleConnTimeout
+	}
+	if override.DisableCompression != nil {
+		c.DisableCompression = override.DisableCompression
+	}
+	if override.ForceAttemptHTTP2 != nil {
+		c.ForceAttemptHTTP2 = override.ForceAttemptHTTP2
+	}
+	if override.H2C != nil {
+		c.H2C = override.H2C
+	}
+	if override.MaxIdlePerBackend != 0 {
+		c.MaxIdlePerBackend = override.MaxIdlePerBackend
+	}
+	return c
+}
+
+// newTransport clones http.DefaultTransport with c applied and its
+// connections counted
+func (c TransportConfig) newTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if c.MaxIdleConns != 0 {
+		t.MaxIdleConns = c.MaxIdleConns
+	}
+	if c.MaxIdleConnsPerHost != 0 {
+		t.MaxIdleConnsPerHost = c.MaxIdleConnsPerHost
+	}
+	if c.MaxConnsPerHost != 0 {
+		t.MaxConnsPerHost = c.MaxConnsPerHost
+	}
+	if c.IdleConnTimeout != 0 {
+		t.IdleConnTimeout = c.IdleConnTimeout
+	}
+	if c.DisableCompression != nil {
+		t.DisableCompression = *c.DisableCompression
+	}
+	if c.ForceAttemptHTTP2 != nil {
+		t.ForceAttemptHTTP2 = *c.ForceAttemptHTTP2
+	}
+	if c.H2C != nil && *c.H2C {
+		// Without HTTP/1 in the set, http:// requests use HTTP/2 with prior
+		// knowledge; every request is a stream on a shared connection
+		var protocols http.Protocols
+		protocols.SetUnencryptedHTTP2(true)
+		protocols.SetHTTP2(true)
+		t.Protocols = &protocols
+	}
+	t.DialContext = countConns(t.DialContext)
+	return t
+}
+
+var (
+	sharedMu       sync.Mutex
+	sharedSettings TransportConfig
+	sharedConn     *http.Transport
+)
+
+// ConfigureTransport sets up the transport shared by backends created
+// afterwards. Backends with dedicated settings (timeouts, TLS or a transport
+// override) get their own transport starting from the same settings.
+func ConfigureTransport(c TransportConfig) error {
+	if err := c.Validate(); err != nil {
+		return err
+	}
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	sharedSettings = c
+	sharedConn = c.newTransport()
+	return nil
+}
+
+// ReapInterval returns how often idle connections are reaped, from the
+// settings passed to ConfigureTransport
+func ReapInterval() time.Duration {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	return sharedSettings.ReapInterval
+}
+
+// sharedTransport returns the shared transport and its settings
+func sharedTransport() (*http.Transport, TransportConfig) {
+	sharedMu.Lock()
+	defer sharedMu.Unlock()
+	if sharedConn == nil {
+		sharedConn = sharedSettings.newTransport()
+	}
+	return sharedConn, sharedSettings
+}
+
+// connStats counts the connections to one dialed address, across the
+// transports that reach it
+type connStats struct {
+	open       atomic.Int64
+	dials      atomic.Int64
+	dialErrors atomic.Int64
+}
+
+// connections holds the connStats per dialed address
+var connections sync.Map // address -> *connStats
+
+// statsFor returns the connection counters of an address
+func statsFor(addr string) *connStats {
+	if stats, ok := connections.Load(addr); ok {
+		return stats.(*connStats)
+	}
+	stats, _ := connections.LoadOrStore(addr, new(connStats))
+	return stats.(*connStats)
+}
+
+// countConns wraps a dial function so dials, dial errors and open
+// connections are counted per address
+func countConns(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		stats := statsFor(addr)
+		stats.dials.Add(1)
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			stats.dialErrors.Add(1)
+			return nil, err
+		}
+		stats.open.Add(1)
+		return &countedConn{Conn: conn, open: &stats.open}, nil
+	}
+}
+
+type countedConn struct {
+	net.Conn
+	open   *atomic.Int64
+	closed atomic.Bool
+}
+
+func (c *countedConn) Close() error {
+	if !c.closed.Swap(true) {
+		c.open.Add(-1)
+	}
+	return c.Conn.Close()
+}
+
+// TransportStats describes the upstream connections of a backend
+type TransportStats struct {
+	Open int
+	// Idle estimates the pooled connections not carrying a request
+	Idle       int
+	Dials      int64
+	DialErrors int64
+	// TLSHandshakes counts completed handshakes and TLSHandshakeTime their
+	// total duration
+	TLSHandshakes    int64
+	TLSHandshakeTime time.Duration
+}
+
+// TransportStats returns the connection counters of the backend
+func (b *Backend) TransportStats() TransportStats {
+	stats := b.connStats()
+	open := int(stats.open.Load())
+	handshakes := b.PhaseStats(PhaseTLS)
+	return TransportStats{
+		Open:             open,
+		Idle:             max(open-b.InFlight(), 0),
+		Dials:            stats.dials.Load(),
+		DialErrors:       stats.dialErrors.Load(),
+		TLSHandshakes:    handshakes.Count,
+		TLSHandshakeTime: handshakes.Total,
+	}
+}
+
+// ReapIdle closes the idle connections of the backend's transport when the
+// backend keeps more than MaxIdlePerBackend, and reports whether it did. A
+// shared transport loses the idle connections to its other backends too.
+func (b *Backend) ReapIdle() bool {
+	limit := b.idleLimit
+	if limit <= 0 || b.IdleConnections() <= limit {
+		return false
+	}
+	closer, ok := b.ReverseProxy.Transport.(interface{ CloseIdleConnections() })
+	if !ok {
+		return false
+	}
+	closer.CloseIdleConnections()
+	return true
+}
+
+// connStats returns the connection counters of the backend's address
+func (b *Backend) connStats() *connStats {
+	return statsFor(dialAddr(b.URL.Scheme, b.URL.Host))
+}
+
+// OpenConnections returns the number of connections open to the backend,
+// across the transports that reach it
+func (b *Backend) OpenConnections() int {
+	return int(b.connStats().open.Load())
+}
+
+// IdleConnections estimates the pooled connections to the backend not
+// carrying a request
+func (b *Backend) IdleConnections() int {
+	return max(b.OpenConnections()-b.InFlight(), 0)
+}
+
+// dialAddr returns the address the transport dials for a URL host
+func dialAddr(scheme, host string) string {
+	if _, _, err := net.SplitHostPort(host); err == nil {
+		return host
+	}
+	host = strings.Trim(host, "[]")
+	if scheme == "https" {
+		return net.JoinHostPort(host, "443")
+	}
+	return net.JoinHostPort(host, "80")
+}
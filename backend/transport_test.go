@@ -0,0 +1,394 @@
+package backend
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestTransportConfig_Merge(t *testing.T) {
+	off := false
+	shared := TransportConfig{MaxIdleConnsPerHost: 64, IdleConnTimeout: time.Minute}
+	got := shared.merge(TransportConfig{MaxIdleConnsPerHost: 8, ForceAttemptHTTP2: &off})
+	if got.MaxIdleConnsPerHost != 8 || got.IdleConnTimeout != time.Minute || got.ForceAttemptHTTP2 != &off {
+		t.Errorf("Expected the override on top of the shared settings, got %+v", got)
+	}
+
+	tr := got.newTransport()
+	if tr.MaxIdleConnsPerHost != 8 || tr.IdleConnTimeout != time.Minute || tr.ForceAttemptHTTP2 {
+		t.Errorf("Expected the settings on the transport, got %d/%v/%v", tr.MaxIdleConnsPerHost, tr.IdleConnTimeout, tr.ForceAttemptHTTP2)
+	}
+}
+
+func TestConfigureTransport(t *testing.T) {
+	defer ConfigureTransport(TransportConfig{})
+
+	if err := ConfigureTransport(TransportConfig{MaxConnsPerHost: -1}); err == nil {
+		t.Error("Expected an error for a negative limit")
+	}
+	if err := ConfigureTransport(TransportConfig{MaxIdleConnsPerHost: 32}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	a, _ := NewBackend("http://localhost:8081")
+	b, _ := NewBackend("http://localhost:8082")
+	if a.ReverseProxy.Transport != b.ReverseProxy.Transport {
+		t.Error("Expected backends without overrides to share a transport")
+	}
+	if tr := a.ReverseProxy.Transport.(*http.Transport); tr.MaxIdleConnsPerHost != 32 {
+		t.Errorf("Expected 32 idle connections per host, got %d", tr.MaxIdleConnsPerHost)
+	}
+
+	c, _ := NewBackendWithConfig(Config{URL: "http://localhost:8083", Transport: TransportConfig{IdleConnTimeout: time.Second}})
+	tr := c.ReverseProxy.Transport.(*http.Transport)
+	if tr == a.ReverseProxy.Transport || tr.MaxIdleConnsPerHost != 32 || tr.IdleConnTimeout != time.Second {
+		t.Errorf("Expected a dedicated transport with the shared settings and the override, got %d/%v", tr.MaxIdleConnsPerHost, tr.IdleConnTimeout)
+	}
+}
+
+func TestBackend_OpenConnections(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{IdleConnTimeout: time.Minute}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if b.OpenConnections() != 1 || b.IdleConnections() != 1 {
+		t.Errorf("Expected 1 open idle connection, got %d open, %d idle", b.OpenConnections(), b.IdleConnections())
+	}
+	if b.InFlight() != 0 {
+		t.Errorf("Expected the kept-alive connection not to count as in flight, got %d", b.InFlight())
+	}
+
+	b.ReverseProxy.Transport.(*http.Transport).CloseIdleConnections()
+	if b.OpenConnections() != 0 {
+		t.Errorf("Expected no open connections, got %d", b.OpenConnections())
+	}
+}
+
+// waitCounts waits for the in-flight requests and open connections of b to
+// reach the given numbers
+func waitCounts(t *testing.T, b *Backend, inFlight, open int) {
+	t.Helper()
+	deadline := time.Now().Add(2 * time.Second)
+	for b.InFlight() != inFlight || b.OpenConnections() != open {
+		if time.Now().After(deadline) {
+			t.Fatalf("Expected %d in flight over %d connections, got %d over %d", inFlight, open, b.InFlight(), b.OpenConnections())
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+}
+
+func TestBackend_InFlightQueuedForConnection(t *testing.T) {
+	arrived, release := make(chan struct{}, 2), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		arrived <- struct{}{}
+		<-release
+	}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{MaxConnsPerHost: 1, IdleConnTimeout: time.Minute}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	var wg sync.WaitGroup
+	for range 2 {
+		wg.Go(func() {
+			b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		})
+	}
+
+	// The second request waits for the only connection the transport allows
+	<-arrived
+	waitCounts(t, b, 2, 1)
+	close(release)
+	wg.Wait()
+	waitCounts(t, b, 0, 1)
+}
+
+func TestBackend_InFlightRetriedOnNewConnection(t *testing.T) {
+	var mu sync.Mutex
+	seen := make(map[string]int)
+	arrived, release := make(chan struct{}), make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		seen[r.RemoteAddr]++
+		n := seen[r.RemoteAddr]
+		mu.Unlock()
+		switch {
+		case r.URL.Path == "/first":
+		case n > 1:
+			// Drop the kept-alive connection under the next request, which
+			// the transport retries on a new one
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		default:
+			close(arrived)
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{IdleConnTimeout: time.Minute}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/first", nil))
+	done := make(chan int)
+	go func() {
+		rr := httptest.NewRecorder()
+		b.Serve(rr, httptest.NewRequest(http.MethodGet, "/retried", nil))
+		done <- rr.Code
+	}()
+
+	<-arrived
+	waitCounts(t, b, 1, 1)
+	if stats := b.TransportStats(); stats.Dials != 2 {
+		t.Errorf("Expected the retry to dial again, got %d dials", stats.Dials)
+	}
+	close(release)
+	if code := <-done; code != http.StatusOK {
+		t.Errorf("Expected the retried request to succeed, got %d", code)
+	}
+	waitCounts(t, b, 0, 1)
+}
+
+func TestBackend_InFlightAfterErrorsAndCancellation(t *testing.T) {
+	arrived := make(chan struct{}, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/drop" {
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+			return
+		}
+		arrived <- struct{}{}
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{IdleConnTimeout: time.Minute}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	defer b.Retire()
+	b.SetErrorPolicy(ErrorPolicy{KeepUp: true})
+
+	rr := httptest.NewRecorder()
+	b.Serve(rr, httptest.NewRequest(http.MethodGet, "/drop", nil))
+	if rr.Code != http.StatusBadGateway {
+		t.Errorf("Expected 502 for a dropped connection, got %d", rr.Code)
+	}
+	waitCounts(t, b, 0, 0)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/wait", nil).WithContext(ctx))
+		close(done)
+	}()
+	<-arrived
+	waitCounts(t, b, 1, 1)
+	cancel()
+	<-done
+	waitCounts(t, b, 0, 0)
+}
+
+func TestBackend_TransportStats(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	down.Close()
+
+	up, _ := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{IdleConnTimeout: time.Minute}})
+	up.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	up.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if stats := up.TransportStats(); stats.Dials != 1 || stats.DialErrors != 0 || stats.Open != 1 || stats.Idle != 1 {
+		t.Errorf("Expected 1 dial reused by both requests, got %+v", stats)
+	}
+
+	refused, _ := NewBackendWithConfig(Config{URL: down.URL, Transport: TransportConfig{IdleConnTimeout: time.Minute}})
+	refused.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if stats := refused.TransportStats(); stats.Dials != 1 || stats.DialErrors != 1 || stats.Open != 0 {
+		t.Errorf("Expected 1 failed dial, got %+v", stats)
+	}
+}
+
+func TestBackend_TLSHandshakes(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	b, err := NewBackendWithConfig(Config{URL: server.URL, TLS: TLSConfig{InsecureSkipVerify: true}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	rr := httptest.NewRecorder()
+	b.Serve(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Code != http.StatusOK {
+		t.Fatalf("Expected 200, got %d", rr.Code)
+	}
+	b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	if stats := b.TransportStats(); stats.TLSHandshakes != 1 || stats.TLSHandshakeTime <= 0 {
+		t.Errorf("Expected 1 timed handshake for the reused connection, got %+v", stats)
+	}
+}
+
+// writePEM writes PEM blocks of the given type to a file in dir
+func writePEM(t *testing.T, dir, name, blockType string, der ...[]byte) string {
+	t.Helper()
+	var buf bytes.Buffer
+	for _, d := range der {
+		pem.Encode(&buf, &pem.Block{Type: blockType, Bytes: d})
+	}
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestBackend_MutualTLS(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-balancer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	clientCert, _ := x509.ParseCertificate(clientDER)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Client", r.TLS.PeerCertificates[0].Subject.CommonName)
+		w.Header().Set("X-Server-Name", r.TLS.ServerName)
+	}))
+	server.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	server.StartTLS()
+	defer server.Close()
+	caFile := writePEM(t, dir, "ca.pem", "CERTIFICATE", server.Certificate().Raw)
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+
+	tests := []struct {
+		name   string
+		tls    TLSConfig
+		status int
+	}{
+		{"system roots", TLSConfig{}, http.StatusBadGateway},
+		{"no client certificate", TLSConfig{CAFile: caFile}, http.StatusBadGateway},
+		{"mutual tls", TLSConfig{CAFile: caFile, CertFile: certFile, KeyFile: keyFile, ServerName: "example.com"}, http.StatusOK},
+		{"insecure", TLSConfig{InsecureSkipVerify: true, CertFile: certFile, KeyFile: keyFile}, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := NewBackendWithConfig(Config{URL: server.URL, TLS: tt.tls})
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			rr := httptest.NewRecorder()
+			b.Serve(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rr.Code != tt.status {
+				t.Fatalf("Expected %d, got %d", tt.status, rr.Code)
+			}
+			if rr.Code != http.StatusOK {
+				return
+			}
+			if got := rr.Header().Get("X-Client"); got != "go-balancer" {
+				t.Errorf("Expected the client certificate to be presented, got %q", got)
+			}
+			if tt.tls.ServerName != "" && rr.Header().Get("X-Server-Name") != tt.tls.ServerName {
+				t.Errorf("Expected SNI %q, got %q", tt.tls.ServerName, rr.Header().Get("X-Server-Name"))
+			}
+		})
+	}
+}
+
+func TestBackend_H2C(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Trailer", "Grpc-Status")
+		w.Header().Set("Content-Type", "application/grpc")
+		w.Write([]byte(r.Proto))
+		w.Header().Set("Grpc-Status", "0")
+	}))
+	server.Config.Protocols = new(http.Protocols)
+	server.Config.Protocols.SetUnencryptedHTTP2(true)
+	server.Start()
+	defer server.Close()
+
+	on := true
+	b, err := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{H2C: &on}})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	for range 3 {
+		rr := httptest.NewRecorder()
+		b.Serve(rr, httptest.NewRequest(http.MethodPost, "/pkg.Service/Method", nil))
+		if rr.Code != http.StatusOK || rr.Body.String() != "HTTP/2.0" {
+			t.Fatalf("Expected 200 over HTTP/2.0, got %d %q", rr.Code, rr.Body.String())
+		}
+		if got := rr.Result().Trailer.Get("Grpc-Status"); got != "0" {
+			t.Errorf("Expected the Grpc-Status trailer to be passed on, got %q", got)
+		}
+	}
+	if stats := b.TransportStats(); stats.Dials != 1 {
+		t.Errorf("Expected the requests to share 1 connection, got %d dials", stats.Dials)
+	}
+}
+
+func TestBackend_ReapIdle(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer server.Close()
+
+	b, _ := NewBackendWithConfig(Config{URL: server.URL, Transport: TransportConfig{MaxIdleConnsPerHost: 8, IdleConnTimeout: time.Minute, MaxIdlePerBackend: 1}})
+	done := make(chan struct{})
+	for range 3 {
+		go func() {
+			b.Serve(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+			done <- struct{}{}
+		}()
+	}
+	for range 3 {
+		<-done
+	}
+	if b.IdleConnections() != 3 {
+		t.Fatalf("Expected 3 idle connections, got %d", b.IdleConnections())
+	}
+	if !b.ReapIdle() {
+		t.Error("Expected connections beyond the limit to be reaped")
+	}
+	if b.OpenConnections() != 0 {
+		t.Errorf("Expected no open connections after reaping, got %d", b.OpenConnections())
+	}
+	if b.ReapIdle() {
+		t.Error("Expected nothing to reap below the limit")
+	}
+}
@@ -0,0 +1,177 @@
+package balancer
+
+import (
+	"sort"
+	"sync/atomic"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// availability splits the backends that are alive, enabled and not draining
+// into primaries and backups; strategies select from it without filtering
+// the whole pool on every request. Both lists keep the order of the backend
+// list.
+type availability struct {
+	primaries []*backend.Backend
+	backups   []*backend.Backend
+}
+
+// backendIndex is built with each backend list. It maps members to their
+// position, so a state change updates the snapshots in place of a rebuild,
+// and holds the availability of every "key=value" label of the pool and of
+// the subset selection is restricted to (nil without one).
+type backendIndex struct {
+	order    map[*backend.Backend]int
+	labels   map[string]*atomic.Pointer[availability]
+	selector backend.Subset
+	subset   *atomic.Pointer[availability]
+}
+
+// setBackends installs the backend list and watches its members for state
+// changes; callers hold lb.mu. The slice must not be modified afterwards.
+func (lb *LoadBalancer) setBackends(backends []*backend.Backend) {
+	lb.backends.Store(&backends)
+	policy := lb.errorPolicy.Load()
+	for _, b := range backends {
+		b.SetErrorPolicy(*policy)
+		b.OnStateChange(func() { lb.updateAvailable(b) })
+	}
+	lb.refreshAvailable()
+}
+
+// refreshAvailable rebuilds the index and the snapshots of eligible
+// backends. It must not take lb.mu: backends report changes while it is
+// held. availMu orders concurrent updates so the last one stored saw the
+// latest state.
+func (lb *LoadBalancer) refreshAvailable() {
+	lb.availMu.Lock()
+	defer lb.availMu.Unlock()
+	members := lb.GetBackends()
+	index := &backendIndex{
+		order:  make(map[*backend.Backend]int, len(members)),
+		labels: make(map[string]*atomic.Pointer[availability]),
+	}
+	next := &availability{primaries: make([]*backend.Backend, 0, len(members))}
+	subsets := make(map[string]*availability)
+	selected := &availability{}
+	if selector := lb.subset.Load(); selector != nil && !selector.IsZero() {
+		index.selector = *selector
+		index.subset = new(atomic.Pointer[availability])
+	}
+	for i, b := range members {
+		index.order[b] = i
+		eligible := isEligible(b)
+		if eligible && index.subset != nil && index.selector.Matches(b) {
+			selected.add(b)
+		}
+		for k, v := range b.Labels() {
+			label := k + "=" + v
+			subset, ok := subsets[label]
+			if !ok {
+				subset = &availability{}
+				subsets[label] = subset
+			}
+			if eligible {
+				subset.add(b)
+			}
+		}
+		if eligible {
+			next.add(b)
+		}
+	}
+	for label, subset := range subsets {
+		index.labels[label] = new(atomic.Pointer[availability])
+		index.labels[label].Store(subset)
+	}
+	if index.subset != nil {
+		index.subset.Store(selected)
+	}
+	lb.index.Store(index)
+	lb.available.Store(next)
+	lb.queue.signal()
+}
+
+// updateAvailable adds b to or removes it from the snapshots after it
+// changed state, copying only the lists it belongs to
+func (lb *LoadBalancer) updateAvailable(b *backend.Backend) {
+	lb.availMu.Lock()
+	defer lb.availMu.Unlock()
+	index := lb.index.Load()
+	if _, ok := index.order[b]; !ok {
+		// No longer a member since the list was replaced
+		return
+	}
+	eligible := isEligible(b)
+	next, changed := lb.available.Load().with(b, eligible, index.order)
+	if !changed {
+		return
+	}
+	for k, v := range b.Labels() {
+		if subset := index.labels[k+"="+v]; subset != nil {
+			if updated, ok := subset.Load().with(b, eligible, index.order); ok {
+				subset.Store(updated)
+			}
+		}
+	}
+	if index.subset != nil && index.selector.Matches(b) {
+		if updated, ok := index.subset.Load().with(b, eligible, index.order); ok {
+			index.subset.Store(updated)
+		}
+	}
+	lb.available.Store(next)
+	lb.queue.signal()
+}
+
+// isEligible reports whether b may take new requests, connection limits
+// aside
+func isEligible(b *backend.Backend) bool {
+	return b.IsAlive() && !b.IsDraining() && !b.IsDisabled() && !b.IsEjected()
+}
+
+// add appends b to the list it belongs to
+func (a *availability) add(b *backend.Backend) {
+	if b.IsBackup() {
+		a.backups = append(a.backups, b)
+	} else {
+		a.primaries = append(a.primaries, b)
+	}
+}
+
+// with returns a copy of a with b included or left out, and whether that
+// changed anything. The lists are copied, never modified, since requests
+// may be selecting from them.
+func (a *availability) with(b *backend.Backend, include bool, order map[*backend.Backend]int) (*availability, bool) {
+	next := *a
+	list := &next.primaries
+	if b.IsBackup() {
+		list = &next.backups
+	}
+	pos := order[b]
+	i := sort.Search(len(*list), func(i int) bool { return order[(*list)[i]] >= pos })
+	present := i < len(*list) && (*list)[i] == b
+	if present == include {
+		return a, false
+	}
+	updated := make([]*backend.Backend, 0, len(*list)+1)
+	updated = append(updated, (*list)[:i]...)
+	if include {
+		updated = append(updated, b)
+		updated = append(updated, (*list)[i:]...)
+	} else {
+		updated = append(updated, (*list)[i+1:]...)
+	}
+	*list = updated
+	return &next, true
+}
+
+// AvailableWithLabel returns the backends labeled key=value that can take
+// new requests, primaries and backups apart, without scanning the pool.
+// The slices are shared and must not be modified.
+func (lb *LoadBalancer) AvailableWithLabel(key, value string) (primaries, backups []*backend.Backend) {
+	subset := lb.index.Load().labels[key+"="+value]
+	if subset == nil {
+		return nil, nil
+	}
+	avail := subset.Load()
+	return avail.primaries, avail.backups
+}
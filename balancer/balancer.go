@@ -2,48 +2,181 @@ package balancer
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"net/http"
+	"runtime/debug"
 	"sync"
 	"sync/atomic"
 	"time"
 
+	"github.com/TaiTitans/go-balancer/accesslog"
+	"github.com/TaiTitans/go-balancer/audit"
 	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/chaos"
 	constants "github.com/TaiTitans/go-balancer/const"
+	"github.com/TaiTitans/go-balancer/features"
 	"github.com/TaiTitans/go-balancer/healthcheck"
+	"github.com/TaiTitans/go-balancer/internal/sharded"
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/sticky"
 	"github.com/TaiTitans/go-balancer/strategy"
+	"github.com/TaiTitans/go-balancer/topk"
+	"github.com/TaiTitans/go-balancer/tracing"
 )
 
 // LoadBalancer represents the main load balancer
 type LoadBalancer struct {
-	backends      []*backend.Backend
-	strategy      strategy.Strategy
+	// backends and strategy are read lock-free on the request path; writers
+	// swap in a new immutable value while holding mu
+	backends      atomic.Pointer[[]*backend.Backend]
+	strategy      atomic.Pointer[strategy.Strategy]
 	healthChecker *healthcheck.HealthChecker
 	mu            sync.RWMutex
 	metrics       *Metrics
+	slowThreshold time.Duration
+	audit         *audit.Log
+	logger        *slog.Logger
+	prom          *promMetrics
+	exemplars     bool
+	tracer        *tracing.Tracer
+	topClients    *topk.TopK
+	topPaths      *topk.TopK
+	// requireHealthy keeps new backends out of rotation until they pass a
+	// health probe
+	requireHealthy bool
+	// disabled holds the URLs operators took out of rotation; it outlives
+	// the backends so reloads don't bring them back
+	disabled map[string]bool
+	// sticky pins client sessions to backends (nil disables affinity)
+	sticky       *sticky.Affinity
+	stickyErrors atomic.Int64
+	// chaos injects faults for resilience testing (nil disables it)
+	chaos *chaos.Injector
+	// Feature flags consulted per request (nil flags are always on)
+	chaosFlag  *features.Flag
+	stickyFlag *features.Flag
+	topFlag    *features.Flag
+	slowFlag   *features.Flag
+	// maintenance answers every request with 503 while set
+	maintenance atomic.Bool
+	// started is set by Start; draining rejects new requests during
+	// shutdown (see Drain)
+	started      atomic.Bool
+	draining     atomic.Bool
+	stopChecking context.CancelFunc
+	hooks        hooks
+	// available is the copy-on-write snapshot of the backends eligible for
+	// new requests, updated when a backend changes state; index locates
+	// members and holds the same snapshot per label (see available.go)
+	available atomic.Pointer[availability]
+	index     atomic.Pointer[backendIndex]
+	availMu   sync.Mutex
+	// queue holds requests waiting for a backend to free up
+	queue waitQueue
+	// maxInFlight caps the requests admitted at once (0 = unlimited), or
+	// adaptive when set; admitted counts them and limitRejections the ones
+	// turned away
+	maxInFlight     int64
+	adaptive        *adaptiveLimit
+	admitted        atomic.Int64
+	limitRejections atomic.Int64
+	// maxRequestBytes caps request bodies (0 = unlimited)
+	maxRequestBytes int64
+	// errorPolicy is applied to every backend of the pool
+	errorPolicy atomic.Pointer[backend.ErrorPolicy]
+	// subset restricts selection to the backends carrying its labels
+	subset atomic.Pointer[backend.Subset]
+	// outliers ejects backends standing out from the pool (nil disables it)
+	outliers *outlierDetector
 }
 
 // Metrics tracks load balancer performance
 type Metrics struct {
-	TotalRequests  int64
-	FailedRequests int64
-	TotalBytes     int64
+	TotalRequests  sharded.Counter
+	FailedRequests sharded.Counter
+	// TotalBytes counts the response bytes of proxied requests sent to
+	// clients, RequestBytes the request body bytes forwarded to backends
+	TotalBytes     sharded.Counter
+	RequestBytes   sharded.Counter
 	mu             sync.RWMutex
 	StartTime      time.Time
+	ResetTime      time.Time
+	rates          *rateCounter
+	errors         map[string]*sharded.Counter
+	ClientCanceled sharded.Counter
 }
 
 // Config holds the load balancer configuration
 type Config struct {
-	BackendURLs         []string
+	BackendURLs []string
+	// Backends carries per-backend settings; it is used instead of BackendURLs when set
+	Backends            []backend.Config
 	Strategy            strategy.Strategy
 	HealthCheckInterval time.Duration
 	HealthCheckTimeout  time.Duration
+	// SlowRequestThreshold logs any request slower than this (0 disables)
+	SlowRequestThreshold time.Duration
+	// AuditLog records runtime mutations such as strategy changes (optional)
+	AuditLog *audit.Log
+	// MetricsRegistry receives the Prometheus metrics (a new one is created if nil)
+	MetricsRegistry *metrics.Registry
+	// TraceExemplars attaches incoming W3C trace IDs to latency histograms
+	TraceExemplars bool
+	// Tracer records a span per request and propagates it to the backend
+	// (optional)
+	Tracer *tracing.Tracer
+	// RequireHealthy only routes to a backend (initial or added later, e.g. by
+	// discovery) after its first passing health probe
+	RequireHealthy bool
+	// GracePeriod tolerates failed probes of newly added backends for this long
+	GracePeriod time.Duration
+	// Sticky pins client sessions to backends through a shared store (optional)
+	Sticky *sticky.Affinity
+	// Features registers the balancer's kill switches (optional)
+	Features *features.Registry
+	// Chaos injects faults into proxied requests (optional)
+	Chaos *chaos.Injector
+	// Logger receives the balancer's log records (logging.Logger() if nil);
+	// library code never writes to the standard log package
+	Logger *slog.Logger
+	// Queue lets requests wait for a backend instead of failing at once
+	Queue QueueConfig
+	// MaxInFlight caps the requests handled at once across all backends
+	// (0 = unlimited); per-backend caps are set by backend.Config.MaxConnections
+	MaxInFlight int
+	// AdaptiveLimit lets the in-flight cap follow backend latency instead
+	// of staying at MaxInFlight
+	AdaptiveLimit AdaptiveLimitConfig
+	// MaxRequestBytes caps request bodies; larger ones are refused with 413
+	// (0 = unlimited)
+	MaxRequestBytes int64
+	// ErrorPolicy answers failed proxy attempts instead of 502 Bad Gateway
+	// and can keep them from marking backends down
+	ErrorPolicy backend.ErrorPolicy
+	// HealthCheck sets the probe request and the answer that passes it for
+	// every backend; backends override it with their own health settings
+	HealthCheck healthcheck.Config
+	// OutlierDetection ejects backends failing or answering slower than
+	// the rest of the pool for a while
+	OutlierDetection OutlierConfig
+	// Subset sends requests only to the backends carrying its labels, or
+	// prefers them when it falls back to the pool
+	Subset backend.Subset
 }
 
-// NewLoadBalancer creates a new load balancer instance
+// NewLoadBalancer creates a new load balancer instance from a Config; see New
+// for the functional options equivalent
 func NewLoadBalancer(config Config) (*LoadBalancer, error) {
-	if len(config.BackendURLs) == 0 {
+	backendConfigs := config.Backends
+	if len(backendConfigs) == 0 {
+		for _, urlStr := range config.BackendURLs {
+			backendConfigs = append(backendConfigs, backend.Config{URL: urlStr})
+		}
+	}
+	if len(backendConfigs) == 0 {
 		return nil, fmt.Errorf("no backend URLs provided")
 	}
 
@@ -60,174 +193,505 @@ func NewLoadBalancer(config Config) (*LoadBalancer, error) {
 	}
 
 	// Create backends
-	backends := make([]*backend.Backend, 0, len(config.BackendURLs))
-	for _, urlStr := range config.BackendURLs {
-		b, err := backend.NewBackend(urlStr)
+	backends := make([]*backend.Backend, 0, len(backendConfigs))
+	for _, bc := range backendConfigs {
+		b, err := backend.NewBackendWithConfig(bc)
 		if err != nil {
-			return nil, fmt.Errorf("failed to create backend for %s: %w", urlStr, err)
+			return nil, fmt.Errorf("failed to create backend for %s: %w", bc.URL, err)
+		}
+		if config.RequireHealthy {
+			b.SetAlive(false)
 		}
 		backends = append(backends, b)
 	}
 
+	now := time.Now()
 	lb := &LoadBalancer{
-		backends: backends,
-		strategy: config.Strategy,
 		metrics: &Metrics{
-			StartTime: time.Now(),
+			StartTime: now,
+			ResetTime: now,
+			rates:     newRateCounter(now),
+			errors:    newErrorCounts(),
 		},
+		slowThreshold: config.SlowRequestThreshold,
+		audit:         config.AuditLog,
+		exemplars:     config.TraceExemplars,
+		tracer:        config.Tracer,
+		topClients:    topk.New(topTracked, topk.DefaultWidth, topk.DefaultDepth),
+		topPaths:      topk.New(topTracked, topk.DefaultWidth, topk.DefaultDepth),
+
+		requireHealthy: config.RequireHealthy,
+		sticky:         config.Sticky,
+		chaos:          config.Chaos,
+		topFlag:        config.Features.Register(features.TopStats, "Track top clients and paths for /admin/stats/top", true),
+
+		maxInFlight:     int64(config.MaxInFlight),
+		maxRequestBytes: config.MaxRequestBytes,
+	}
+	if config.Sticky != nil {
+		lb.stickyFlag = config.Features.Register(features.StickySessions, "Pin client sessions to backends", true)
+	}
+	if config.Chaos != nil {
+		lb.chaosFlag = config.Features.Register(features.Chaos, "Inject faults configured through /admin/chaos", true)
+	}
+	if config.SlowRequestThreshold > 0 {
+		lb.slowFlag = config.Features.Register(features.SlowRequestLog, "Log requests slower than the threshold", true)
+	}
+
+	if config.OutlierDetection.Enabled {
+		lb.outliers = &outlierDetector{config: config.OutlierDetection.withDefaults()}
+	}
+	if config.AdaptiveLimit.Enabled {
+		lb.adaptive = newAdaptiveLimit(config.AdaptiveLimit, config.MaxInFlight)
 	}
 
+	lb.strategy.Store(&config.Strategy)
+	lb.queue.config.Store(&config.Queue)
+	lb.errorPolicy.Store(&config.ErrorPolicy)
+	lb.subset.Store(&config.Subset)
+	lb.setBackends(backends)
+
+	if config.MetricsRegistry == nil {
+		config.MetricsRegistry = metrics.NewRegistry()
+	}
+	lb.prom = newPromMetrics(config.MetricsRegistry, lb)
+
 	// Create health checker
 	lb.healthChecker = healthcheck.NewHealthChecker(
 		backends,
 		config.HealthCheckInterval,
 		config.HealthCheckTimeout,
 	)
+	lb.healthChecker.SetConfig(config.HealthCheck)
+	lb.healthChecker.RegisterMetrics(config.MetricsRegistry)
+	lb.healthChecker.SetGracePeriod(config.GracePeriod)
+	lb.healthChecker.OnStateChange(lb.fireStateChange)
+	if config.Logger != nil {
+		lb.logger = logging.WithContext(config.Logger)
+		lb.healthChecker.SetLogger(lb.logger)
+	}
 
 	return lb, nil
 }
 
 // Start starts the load balancer and health checker
 func (lb *LoadBalancer) Start(ctx context.Context) {
-	log.Printf("Starting load balancer with strategy: %s", lb.strategy.Name())
-	log.Printf("Managing %d backend(s)", len(lb.backends))
+	lb.log().InfoContext(ctx, "starting load balancer",
+		"strategy", lb.GetStrategy().Name(),
+		"backends", len(lb.GetBackends()))
 
+	ctx, cancel := context.WithCancel(ctx)
+	lb.mu.Lock()
+	lb.stopChecking = cancel
+	lb.mu.Unlock()
 	go lb.healthChecker.Start(ctx)
+	if interval := backend.ReapInterval(); interval > 0 {
+		go lb.reapIdle(ctx, interval)
+	}
+	if lb.outliers != nil {
+		go lb.detectOutliers(ctx)
+	}
+	lb.started.Store(true)
+	lb.fireStart(ctx)
+}
+
+// reapIdle closes the idle upstream connections of backends keeping more
+// than their limit, every interval until ctx is done
+func (lb *LoadBalancer) reapIdle(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for _, b := range lb.GetBackends() {
+				if b.ReapIdle() {
+					lb.prom.reaped.With(b.String()).Inc()
+					lb.log().DebugContext(ctx, "closed idle upstream connections", "backend", b.String())
+				}
+			}
+		}
+	}
 }
 
 // ServeHTTP implements the http.Handler interface
 func (lb *LoadBalancer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
-	atomic.AddInt64(&lb.metrics.TotalRequests, 1)
+	start := time.Now()
+	lb.metrics.TotalRequests.Add(1)
+	lb.recordTop(r)
+	var span *tracing.Span
+	if lb.tracer != nil {
+		var rec *statusRecorder
+		span, rec = lb.startSpan(w, r)
+		w = rec
+		defer lb.endSpan(span, rec)
+	}
+	var target string
+	defer lb.recoverPanic(w, r, &target)
 
-	// Select a backend using the strategy
-	lb.mu.RLock()
-	selectedBackend := lb.strategy.SelectBackend(lb.backends)
-	lb.mu.RUnlock()
+	if lb.draining.Load() {
+		w.Header().Set("Connection", "close")
+		http.Error(w, "Service shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	if lb.maintenance.Load() {
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, "Service under maintenance", http.StatusServiceUnavailable)
+		return
+	}
 
+	if lb.maxRequestBytes > 0 {
+		// Refuse declared oversized bodies before a backend is involved;
+		// chunked ones fail once they cross the limit while proxied
+		if r.ContentLength > lb.maxRequestBytes {
+			lb.recordFailure("", backend.ErrorRequestTooLarge)
+			http.Error(w, "Request Entity Too Large", http.StatusRequestEntityTooLarge)
+			return
+		}
+		r.Body = http.MaxBytesReader(w, r.Body, lb.maxRequestBytes)
+	}
+
+	if limit := lb.inFlightLimit(); limit > 0 {
+		if lb.admitted.Add(1) > limit {
+			lb.admitted.Add(-1)
+			lb.limitRejections.Add(1)
+			lb.prom.limits.With("global").Inc()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "Service overloaded", http.StatusServiceUnavailable)
+			return
+		}
+		defer lb.admitted.Add(-1)
+	}
+
+	// Select a backend using the session affinity or the strategy, and
+	// reserve a slot on it
+	var session *stickySession
+	if lb.sticky != nil && lb.stickyFlag.Enabled() {
+		found := lb.lookupSticky(r)
+		session = &found
+	}
+	pick := func() *backend.Backend {
+		if session != nil {
+			return lb.reserve(func() *backend.Backend { return lb.selectSticky(*session) })
+		}
+		return lb.reserve(lb.selectBackend)
+	}
+	selectedBackend := pick()
 	if selectedBackend == nil {
-		atomic.AddInt64(&lb.metrics.FailedRequests, 1)
-		http.Error(w, "Service unavailable", http.StatusServiceUnavailable)
-		log.Println("No available backends")
+		var status int
+		var reason string
+		selectedBackend, status, reason = lb.queue.wait(r.Context(), pick)
+		if selectedBackend == nil && reason == queueCanceled {
+			// The client gave up while waiting; nobody reads the answer
+			lb.recordFailure("", backend.ErrorClientCanceled)
+			lb.prom.queue.With(reason).Inc()
+			w.WriteHeader(backend.StatusClientClosedRequest)
+			return
+		}
+		if selectedBackend == nil {
+			lb.recordFailure("", backend.ErrorNoBackend)
+			lb.metrics.rates.add(time.Now(), true)
+			lb.prom.noBackend.Inc()
+			if reason != "" {
+				lb.prom.queue.With(reason).Inc()
+			}
+			if status == http.StatusTooManyRequests {
+				w.Header().Set("Retry-After", "1")
+				http.Error(w, "Too many requests", status)
+			} else {
+				http.Error(w, "Service unavailable", status)
+			}
+			lb.log().WarnContext(r.Context(), "no available backends",
+				"method", r.Method, "path", r.URL.Path, "queue", reason)
+			return
+		}
+	}
+	if session != nil {
+		lb.pinSticky(w, r, *session, selectedBackend)
+	}
+	selected := time.Now()
+	target = selectedBackend.GetURL().String()
+	// Wake queued requests once the slot is released, even when the client
+	// aborts the response midway
+	defer lb.queue.signal()
+
+	lb.log().InfoContext(r.Context(), "forwarding request",
+		"backend", selectedBackend.GetURL().String(),
+		"inFlight", selectedBackend.InFlight(),
+		"path", r.URL.Path)
+	accesslog.SetBackend(r.Context(), selectedBackend.GetURL().String())
+	if span != nil {
+		span.SetAttribute("lb.backend", target)
+		r = span.Inject(r)
+	}
+
+	// Injected faults replace (or delay) the proxied answer
+	if lb.chaos != nil && lb.chaosFlag.Enabled() && lb.chaos.Inject(w, r, selectedBackend.GetURL().String()) {
+		selectedBackend.Release()
 		return
 	}
 
-	log.Printf("Forwarding request to %s (active connections: %d)",
-		selectedBackend.GetURL(), selectedBackend.GetConnections())
+	// Proxy through the backend's ReverseProxy, releasing the reserved slot
+	rec := &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+	var body *countingBody
+	if r.Body != nil && r.Body != http.NoBody {
+		body = &countingBody{ReadCloser: r.Body}
+		r.Body = body
+	}
+	proxyErr := selectedBackend.ServeAcquired(rec, r)
+	end := time.Now()
+	lb.recordBytes(selectedBackend, body, rec.bytes)
+
+	class := ""
+	switch {
+	case proxyErr != nil:
+		class = backend.ClassifyError(r.Context(), proxyErr)
+	case rec.statusCode >= http.StatusInternalServerError:
+		class = backend.ErrorUpstream5xx
+	}
+	if class != "" {
+		lb.recordFailure(selectedBackend.GetURL().String(), class)
+		if span != nil {
+			span.SetAttribute("error.type", class)
+		}
+	}
+	failed := class != "" && class != backend.ErrorClientCanceled && class != backend.ErrorRequestTooLarge
+	lb.metrics.rates.add(end, failed)
+	if class != backend.ErrorClientCanceled {
+		if lb.adaptive != nil {
+			lb.adaptive.observe(selected, end.Sub(selected), failed)
+		}
+		if lb.outliers != nil {
+			lb.outliers.observe(selectedBackend, end.Sub(selected), failed)
+		}
+	}
 
-	// Use the backend's Serve method which already has ReverseProxy configured
-	selectedBackend.Serve(w, r)
+	traceID := ""
+	if lb.exemplars {
+		traceID = tracing.TraceIDFromRequest(r)
+	}
+	lb.prom.observe(selectedBackend.GetURL().String(), rec.statusCode, end.Sub(selected), traceID)
+
+	total := end.Sub(start)
+	if lb.slowThreshold > 0 && total >= lb.slowThreshold && lb.slowFlag.Enabled() {
+		lb.log().WarnContext(r.Context(), "slow request",
+			"method", r.Method,
+			"path", r.URL.Path,
+			"backend", selectedBackend.GetURL().String(),
+			"status", rec.statusCode,
+			"total", total,
+			"selection", selected.Sub(start),
+			"upstream", end.Sub(selected),
+			"threshold", lb.slowThreshold,
+		)
+	}
 }
 
-// GetBackends returns all backends
+// selectBackend picks a backend of the subset, if any, and else of the
+// whole pool
+func (lb *LoadBalancer) selectBackend() *backend.Backend {
+	if index := lb.index.Load(); index.subset != nil {
+		if b := lb.selectFrom(index.subset.Load()); b != nil || !index.selector.Fallback {
+			return b
+		}
+	}
+	return lb.selectFrom(lb.available.Load())
+}
+
+// selectFrom picks a primary backend of avail, falling back to backups
+// only when no primary is available
+func (lb *LoadBalancer) selectFrom(avail *availability) *backend.Backend {
+	s := lb.GetStrategy()
+
+	if b := s.SelectBackend(avail.primaries); b != nil && b.GetURL() != nil {
+		return b
+	}
+	if len(avail.backups) == 0 {
+		return nil
+	}
+	if b := s.SelectBackend(avail.backups); b != nil && b.GetURL() != nil {
+		return b
+	}
+	return nil
+}
+
+// recoverPanic answers 502 when the proxy path of a request panics, e.g. in
+// a custom strategy or ModifyResponse, so only that request fails.
+// http.ErrAbortHandler is passed on so the server drops the connection.
+func (lb *LoadBalancer) recoverPanic(w http.ResponseWriter, r *http.Request, target *string) {
+	err := recover()
+	if err == nil {
+		return
+	}
+	if err == http.ErrAbortHandler {
+		// The response was cut short, usually because the client went away
+		if errors.Is(r.Context().Err(), context.Canceled) {
+			lb.recordFailure(*target, backend.ErrorClientCanceled)
+		}
+		panic(err)
+	}
+	lb.recordFailure(*target, backend.ErrorPanic)
+	lb.metrics.rates.add(time.Now(), true)
+	lb.log().ErrorContext(r.Context(), "panic in proxy path",
+		"error", err,
+		"backend", *target,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"stack", string(debug.Stack()))
+	http.Error(w, "Bad Gateway", http.StatusBadGateway)
+}
+
+// maxReserveAttempts bounds how often a request selects again after the
+// chosen backend filled up before it could reserve a slot
+const maxReserveAttempts = 3
+
+// reserve selects a backend with pick and reserves a request slot on it
+func (lb *LoadBalancer) reserve(pick func() *backend.Backend) *backend.Backend {
+	for range maxReserveAttempts {
+		b := pick()
+		if b == nil {
+			return nil
+		}
+		if b.TryAcquire() {
+			return b
+		}
+		lb.prom.limits.With("backend").Inc()
+	}
+	return nil
+}
+
+// statusRecorder wraps http.ResponseWriter to capture the status code
+type statusRecorder struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	bytes       int64
+}
+
+func (sr *statusRecorder) WriteHeader(code int) {
+	if !sr.wroteHeader {
+		sr.statusCode = code
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(code)
+}
+
+func (sr *statusRecorder) Write(p []byte) (int, error) {
+	sr.wroteHeader = true
+	n, err := sr.ResponseWriter.Write(p)
+	sr.bytes += int64(n)
+	return n, err
+}
+
+func (sr *statusRecorder) Flush() {
+	if f, ok := sr.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
+
+// GetBackends returns all backends; the slice is shared and must not be
+// modified
 func (lb *LoadBalancer) GetBackends() []*backend.Backend {
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
-	return lb.backends
+	if p := lb.backends.Load(); p != nil {
+		return *p
+	}
+	return nil
 }
 
 // GetStrategy returns the current strategy
 func (lb *LoadBalancer) GetStrategy() strategy.Strategy {
-	return lb.strategy
+	return *lb.strategy.Load()
 }
 
 // SetStrategy sets a new load balancing strategy
 func (lb *LoadBalancer) SetStrategy(s strategy.Strategy) {
+	lb.SetStrategyAs(s, audit.SystemActor)
+}
+
+// SetStrategyAs changes the load balancing strategy, recording actor as the
+// author of the change in the audit log
+func (lb *LoadBalancer) SetStrategyAs(s strategy.Strategy, actor string) {
 	lb.mu.Lock()
 	defer lb.mu.Unlock()
-	lb.strategy = s
-	log.Printf("Strategy changed to: %s", s.Name())
+	previous := lb.GetStrategy().Name()
+	lb.strategy.Store(&s)
+	lb.log().Info("strategy changed", "strategy", s.Name(), "previous", previous)
+	lb.audit.Record(actor, "strategy.change", s.Name(), "from "+previous)
 }
 
-// GetStats returns statistics about the backends
-func (lb *LoadBalancer) GetStats() map[string]interface{} {
-	lb.mu.RLock()
-	defer lb.mu.RUnlock()
+// ResetStats zeroes request counters and rate windows, e.g. between benchmark runs
+func (lb *LoadBalancer) ResetStats() {
+	now := time.Now()
+	lb.metrics.TotalRequests.Reset()
+	lb.metrics.FailedRequests.Reset()
+	lb.metrics.ClientCanceled.Reset()
+	lb.metrics.TotalBytes.Reset()
+	lb.metrics.RequestBytes.Reset()
+	for _, count := range lb.metrics.errors {
+		count.Reset()
+	}
+	lb.metrics.rates.reset(now)
+	lb.topClients.Reset()
+	lb.topPaths.Reset()
 
-	stats := make(map[string]interface{})
-	backendStats := make([]map[string]interface{}, 0, len(lb.backends))
+	lb.metrics.mu.Lock()
+	lb.metrics.ResetTime = now
+	lb.metrics.mu.Unlock()
 
-	totalAlive := 0
-	totalConnections := 0
-	for _, b := range lb.backends {
-		alive := b.IsAlive()
-		if alive {
-			totalAlive++
-		}
-		connections := b.GetConnections()
-		totalConnections += connections
+	lb.log().Info("statistics counters reset")
+	lb.audit.Record(audit.SystemActor, "stats.reset", "", "")
+}
 
-		backendStats = append(backendStats, map[string]interface{}{
-			"url":          b.GetURL().String(),
-			"alive":        alive,
-			"connections":  connections,
-			"responseTime": b.GetResponseTime().String(),
-			"failCount":    b.GetFailCount(),
-		})
+// HandleResetStats returns an HTTP handler that resets counters on POST
+func (lb *LoadBalancer) HandleResetStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", "POST")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		lb.ResetStats()
+		w.WriteHeader(http.StatusNoContent)
 	}
+}
 
-	uptime := time.Since(lb.metrics.StartTime)
-	totalReqs := atomic.LoadInt64(&lb.metrics.TotalRequests)
-	failedReqs := atomic.LoadInt64(&lb.metrics.FailedRequests)
-
-	stats["strategy"] = lb.strategy.Name()
-	stats["totalBackends"] = len(lb.backends)
-	stats["aliveBackends"] = totalAlive
-	stats["totalConnections"] = totalConnections
-	stats["totalRequests"] = totalReqs
-	stats["failedRequests"] = failedReqs
-	stats["successRate"] = calculateSuccessRate(totalReqs, failedReqs)
-	stats["uptime"] = uptime.String()
-	stats["backends"] = backendStats
-
-	return stats
+func newErrorCounts() map[string]*sharded.Counter {
+	counts := make(map[string]*sharded.Counter, len(backend.ErrorClasses))
+	for _, class := range backend.ErrorClasses {
+		counts[class] = new(sharded.Counter)
+	}
+	return counts
 }
 
-func calculateSuccessRate(total, failed int64) string {
-	if total == 0 {
-		return "N/A"
+// recordFailure counts a failed request under its failure class; requests
+// canceled by their client are counted apart from the failed ones
+func (lb *LoadBalancer) recordFailure(backendURL, class string) {
+	if class == backend.ErrorClientCanceled {
+		lb.metrics.ClientCanceled.Add(1)
+	} else {
+		lb.metrics.FailedRequests.Add(1)
 	}
-	rate := float64(total-failed) / float64(total) * 100
-	return fmt.Sprintf("%.2f%%", rate)
+	if count, ok := lb.metrics.errors[class]; ok {
+		count.Add(1)
+	}
+	lb.prom.errors.With(backendURL, class).Inc()
 }
 
-// HandleStats returns an HTTP handler for stats endpoint
-func (lb *LoadBalancer) HandleStats() http.HandlerFunc {
-	return func(w http.ResponseWriter, r *http.Request) {
-		stats := lb.GetStats()
-
-		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
-		w.WriteHeader(http.StatusOK)
-
-		fmt.Fprintf(w, "╔════════════════════════════════════════╗\n")
-		fmt.Fprintf(w, "║   Load Balancer Statistics             ║\n")
-		fmt.Fprintf(w, "╚════════════════════════════════════════╝\n\n")
-
-		fmt.Fprintf(w, "Strategy:         %s\n", stats["strategy"])
-		fmt.Fprintf(w, "Uptime:           %s\n", stats["uptime"])
-		fmt.Fprintf(w, "Total Backends:   %d\n", stats["totalBackends"])
-		fmt.Fprintf(w, "Alive Backends:   %d\n", stats["aliveBackends"])
-		fmt.Fprintf(w, "Total Requests:   %d\n", stats["totalRequests"])
-		fmt.Fprintf(w, "Failed Requests:  %d\n", stats["failedRequests"])
-		fmt.Fprintf(w, "Success Rate:     %s\n", stats["successRate"])
-		fmt.Fprintf(w, "Active Connections: %d\n\n", stats["totalConnections"])
-
-		fmt.Fprintf(w, "Backend Details:\n")
-		fmt.Fprintf(w, "════════════════════════════════════════\n")
-
-		if backends, ok := stats["backends"].([]map[string]interface{}); ok {
-			for i, b := range backends {
-				fmt.Fprintf(w, "\n[%d] %s\n", i+1, b["url"])
-				if b["alive"].(bool) {
-					fmt.Fprintf(w, "    Status:       ✓ Healthy\n")
-				} else {
-					fmt.Fprintf(w, "    Status:       ✗ Down\n")
-				}
-				fmt.Fprintf(w, "    Connections:  %d\n", b["connections"])
-				fmt.Fprintf(w, "    Response Time: %s\n", b["responseTime"])
-				fmt.Fprintf(w, "    Fail Count:   %d\n", b["failCount"])
-			}
-		}
+// errorCounts returns failed request counts per failure class
+func (lb *LoadBalancer) errorCounts() map[string]int64 {
+	counts := make(map[string]int64, len(lb.metrics.errors))
+	lb.fillErrorCounts(counts)
+	return counts
+}
 
-		fmt.Fprintf(w, "\n════════════════════════════════════════\n")
+// fillErrorCounts stores the failed request counts per failure class in counts
+func (lb *LoadBalancer) fillErrorCounts(counts map[string]int64) {
+	for class, count := range lb.metrics.errors {
+		counts[class] = count.Load()
 	}
 }
@@ -1,13 +1,30 @@
 package balancer
 
 import (
+	"bytes"
 	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
 	"net/http"
 	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/middleware"
+	"github.com/TaiTitans/go-balancer/sticky"
 	"github.com/TaiTitans/go-balancer/strategy"
+	"github.com/TaiTitans/go-balancer/tracing"
 )
 
 func TestNewLoadBalancer(t *testing.T) {
@@ -65,6 +82,47 @@ func TestNewLoadBalancer(t *testing.T) {
 	}
 }
 
+func TestNew(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(slog.NewJSONHandler(&buf, nil))
+
+	lb, err := New(
+		WithBackends("http://localhost:8081"),
+		WithBackendConfigs(backend.Config{URL: "http://localhost:8082", Weight: 3}),
+		WithStrategy(strategy.NewLeastConnections()),
+		WithHealthCheck(time.Minute, time.Second),
+		WithLogger(logger),
+	)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if n := len(lb.GetBackends()); n != 2 {
+		t.Errorf("Expected 2 backends, got %d", n)
+	}
+	if name := lb.GetStrategy().Name(); name != strategy.NewLeastConnections().Name() {
+		t.Errorf("Expected least connections, got %s", name)
+	}
+	if w := lb.GetBackends()[1].GetWeight(); w != 3 {
+		t.Errorf("Expected weight 3, got %d", w)
+	}
+
+	lb.SetMaintenance(true)
+	if !strings.Contains(buf.String(), `"msg":"maintenance mode on"`) {
+		t.Errorf("Expected the maintenance change on the given logger, got %q", buf.String())
+	}
+
+	if _, err := New(WithStrategy(strategy.NewRoundRobin())); err == nil {
+		t.Error("Expected an error without backends")
+	}
+	lb, err = New(WithBackends("http://localhost:8081"))
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if name := lb.GetStrategy().Name(); name != strategy.NewRoundRobin().Name() {
+		t.Errorf("Expected round robin by default, got %s", name)
+	}
+}
+
 func TestLoadBalancer_ServeHTTP(t *testing.T) {
 	// Create test backend server
 	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -108,6 +166,9 @@ func TestLoadBalancer_ServeHTTP(t *testing.T) {
 	if rr.Body.String() != "backend response" {
 		t.Errorf("Expected body 'backend response', got '%s'", rr.Body.String())
 	}
+	if phases := lb.GetStats().Backends[0].Phases; phases.Connect.Count != 1 || phases.TTFB.Count != 1 {
+		t.Errorf("Expected the connect and TTFB phases of the request in stats, got %+v", phases)
+	}
 }
 
 func TestLoadBalancer_GetStats(t *testing.T) {
@@ -125,12 +186,84 @@ func TestLoadBalancer_GetStats(t *testing.T) {
 
 	stats := lb.GetStats()
 
-	if stats["totalBackends"] != 2 {
-		t.Errorf("Expected 2 backends, got %v", stats["totalBackends"])
+	if stats.TotalBackends != 2 || len(stats.Backends) != 2 {
+		t.Errorf("Expected 2 backends, got %d", stats.TotalBackends)
+	}
+
+	if stats.Strategy != "RoundRobin" {
+		t.Errorf("Expected RoundRobin strategy, got %v", stats.Strategy)
+	}
+}
+
+func TestLoadBalancer_StatsOutput(t *testing.T) {
+	lb, err := New(WithBackends("http://localhost:8081"), WithQueue(4, time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	data, err := json.Marshal(lb.GetStats())
+	if err != nil {
+		t.Fatalf("Failed to marshal stats: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		t.Fatalf("Failed to decode stats: %v", err)
+	}
+	for _, key := range []string{"strategy", "totalBackends", "rates", "errors", "backends", "queue"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("Expected %q in the JSON stats, got %s", key, data)
+		}
+	}
+	if _, ok := decoded["chaos"]; ok {
+		t.Errorf("Expected no chaos stats without an injector, got %s", data)
+	}
+
+	rr := httptest.NewRecorder()
+	lb.HandleStats().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/stats", nil))
+	for _, want := range []string{"Strategy:         RoundRobin", "Success Rate:     N/A", "Queue:            0/4 waiting", "[1] http://localhost:8081"} {
+		if !strings.Contains(rr.Body.String(), want) {
+			t.Errorf("Expected %q in the stats page, got:\n%s", want, rr.Body.String())
+		}
+	}
+
+	for _, req := range []*http.Request{
+		httptest.NewRequest(http.MethodGet, "/stats?format=json", nil),
+		func() *http.Request {
+			r := httptest.NewRequest(http.MethodGet, "/stats", nil)
+			r.Header.Set("Accept", "application/json")
+			return r
+		}(),
+	} {
+		rr := httptest.NewRecorder()
+		lb.HandleStats().ServeHTTP(rr, req)
+		var served Stats
+		if ct := rr.Header().Get("Content-Type"); ct != "application/json" {
+			t.Errorf("Expected JSON for %s (Accept %q), got %q", req.URL, req.Header.Get("Accept"), ct)
+		} else if err := json.Unmarshal(rr.Body.Bytes(), &served); err != nil || served.TotalBackends != 1 {
+			t.Errorf("Expected the stats as JSON, got %v: %s", err, rr.Body.String())
+		}
+	}
+}
+
+func TestLoadBalancer_StatsSnapshot(t *testing.T) {
+	lb, err := New(
+		WithBackends("http://localhost:8081", "http://localhost:8082"),
+		WithQueue(4, time.Second),
+		WithMaxInFlight(10),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	var stats Stats
+	lb.StatsSnapshot(&stats)
+	if len(stats.Backends) != 2 || stats.Backends[1].URL != "http://localhost:8082" || stats.Queue == nil {
+		t.Errorf("Expected a snapshot of 2 backends and the queue, got %+v", stats)
 	}
 
-	if stats["strategy"] != "RoundRobin" {
-		t.Errorf("Expected RoundRobin strategy, got %v", stats["strategy"])
+	allocs := testing.AllocsPerRun(100, func() { lb.StatsSnapshot(&stats) })
+	if allocs != 0 {
+		t.Errorf("Expected no allocations when reusing the snapshot, got %v", allocs)
 	}
 }
 
@@ -184,3 +317,1807 @@ func BenchmarkLoadBalancer_ServeHTTP(b *testing.B) {
 		lb.ServeHTTP(rr, req)
 	}
 }
+
+func TestLoadBalancer_SlowRequestLog(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.WriteHeader(http.StatusAccepted)
+	}))
+	defer backend.Close()
+
+	var buf bytes.Buffer
+	previous := slog.Default()
+	slog.SetDefault(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer slog.SetDefault(previous)
+
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs:          []string{backend.URL},
+		Strategy:             strategy.NewRoundRobin(),
+		SlowRequestThreshold: 10 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/slow", nil)
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+
+	// slog.SetDefault also redirects the standard logger, so take the last line
+	lines := bytes.Split(bytes.TrimSpace(buf.Bytes()), []byte("\n"))
+	var entry map[string]interface{}
+	if err := json.Unmarshal(lines[len(lines)-1], &entry); err != nil {
+		t.Fatalf("Expected a structured slow request log line, got %q", buf.String())
+	}
+	if entry["msg"] != "slow request" {
+		t.Errorf("Expected slow request message, got %v", entry["msg"])
+	}
+	if entry["path"] != "/slow" {
+		t.Errorf("Expected path /slow, got %v", entry["path"])
+	}
+	if entry["backend"] != backend.URL {
+		t.Errorf("Expected backend %s, got %v", backend.URL, entry["backend"])
+	}
+	if entry["status"] != float64(http.StatusAccepted) {
+		t.Errorf("Expected status %d, got %v", http.StatusAccepted, entry["status"])
+	}
+}
+
+func TestRateCounter(t *testing.T) {
+	start := time.Unix(1_000_000, 0)
+	rc := newRateCounter(start.Add(-time.Hour))
+
+	// 120 requests over the last two minutes, a quarter of them failing
+	for i := 0; i < 120; i++ {
+		rc.add(start.Add(time.Duration(i)*time.Second), i%4 == 0)
+	}
+	now := start.Add(119 * time.Second)
+
+	rps, errorRate := rc.rates(now, time.Minute)
+	if rps != 1 {
+		t.Errorf("Expected 1 rps over 1m, got %v", rps)
+	}
+	if errorRate != 0.25 {
+		t.Errorf("Expected 0.25 error rate, got %v", errorRate)
+	}
+
+	rps, _ = rc.rates(now, 5*time.Minute)
+	if rps != 120.0/300.0 {
+		t.Errorf("Expected %v rps over 5m, got %v", 120.0/300.0, rps)
+	}
+
+	rc.reset(now)
+	if rps, _ := rc.rates(now, time.Minute); rps != 0 {
+		t.Errorf("Expected 0 rps after reset, got %v", rps)
+	}
+
+	// Concurrent requests in the same second are all counted
+	rc.reset(start.Add(-time.Hour))
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for range 1000 {
+				rc.add(start, false)
+			}
+		}()
+	}
+	wg.Wait()
+	if rps, _ := rc.rates(start, time.Second); rps != 8000 {
+		t.Errorf("Expected 8000 requests in the second, got %v", rps)
+	}
+}
+
+func BenchmarkRateCounter(b *testing.B) {
+	rc := newRateCounter(time.Now())
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			i++
+			rc.add(time.Now(), i%16 == 0)
+		}
+	})
+}
+
+func TestLoadBalancer_ResetStats(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs: []string{"http://localhost:8081"},
+		Strategy:    strategy.NewRoundRobin(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	// No backend is alive, so every request fails with 503
+	lb.GetBackends()[0].SetAlive(false)
+	for i := 0; i < 3; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	stats := lb.GetStats()
+	if stats.FailedRequests != 3 {
+		t.Errorf("Expected 3 failed requests, got %v", stats.FailedRequests)
+	}
+	if stats.Rates["1m"].ErrorRate != 1.0 {
+		t.Errorf("Expected 1m error rate of 1.0, got %v", stats.Rates["1m"])
+	}
+
+	rr := httptest.NewRecorder()
+	lb.HandleResetStats().ServeHTTP(rr, httptest.NewRequest(http.MethodPost, "/admin/stats/reset", nil))
+	if rr.Code != http.StatusNoContent {
+		t.Errorf("Expected status %d, got %d", http.StatusNoContent, rr.Code)
+	}
+
+	stats = lb.GetStats()
+	if stats.TotalRequests != 0 || stats.FailedRequests != 0 {
+		t.Errorf("Counters not reset: %v / %v", stats.TotalRequests, stats.FailedRequests)
+	}
+}
+
+func TestLoadBalancer_ErrorTaxonomy(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	// A closed server gives us an address that refuses connections
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refusedURL := refused.URL
+	refused.Close()
+
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs: []string{failing.URL, refusedURL},
+		Strategy:    strategy.NewRoundRobin(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	// Round robin hits the 5xx backend, then the refusing one (which gets marked down),
+	// then the 5xx backend again; finally mark everything down for a no-backend failure
+	for i := 0; i < 3; i++ {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	for _, b := range lb.GetBackends() {
+		b.SetAlive(false)
+	}
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	errs := lb.GetStats().Errors
+	if errs["upstream_5xx"] != 2 {
+		t.Errorf("Expected 2 upstream_5xx, got %d", errs["upstream_5xx"])
+	}
+	if errs["connection_refused"] != 1 {
+		t.Errorf("Expected 1 connection_refused, got %d", errs["connection_refused"])
+	}
+	if errs["no_backend"] != 1 {
+		t.Errorf("Expected 1 no_backend, got %d", errs["no_backend"])
+	}
+	if failed := lb.GetStats().FailedRequests; failed != 4 {
+		t.Errorf("Expected 4 failed requests, got %v", failed)
+	}
+}
+
+func TestLoadBalancer_TopStats(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs: []string{"http://localhost:8081"},
+		Strategy:    strategy.NewRoundRobin(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	lb.GetBackends()[0].SetAlive(false)
+
+	for i := 0; i < 5; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/hot", nil)
+		req.RemoteAddr = "10.0.0.1:1234"
+		lb.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/cold", nil)
+	req.RemoteAddr = "10.0.0.2:1234"
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+
+	clients := lb.TopClients(1)
+	if len(clients) != 1 || clients[0].Key != "10.0.0.1" || clients[0].Count != 5 {
+		t.Errorf("Expected top client 10.0.0.1 with 5 requests, got %v", clients)
+	}
+	paths := lb.TopPaths(2)
+	if len(paths) != 2 || paths[0].Key != "/hot" || paths[1].Key != "/cold" {
+		t.Errorf("Expected top paths [/hot /cold], got %v", paths)
+	}
+
+	rr := httptest.NewRecorder()
+	lb.HandleTopStats().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/admin/stats/top?n=0", nil))
+	if rr.Code != http.StatusBadRequest {
+		t.Errorf("Expected status %d for n=0, got %d", http.StatusBadRequest, rr.Code)
+	}
+}
+
+func TestLoadBalancer_SetBackends(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs: []string{"http://localhost:8081", "http://localhost:8082"},
+		Strategy:    strategy.NewRoundRobin(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	kept := lb.GetBackends()[1]
+	kept.SetAlive(false)
+
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:8082"}, {URL: "http://localhost:8083"}}); err != nil {
+		t.Fatalf("SetBackends failed: %v", err)
+	}
+
+	backends := lb.GetBackends()
+	if len(backends) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(backends))
+	}
+	if backends[0] != kept || backends[0].IsAlive() {
+		t.Error("Expected unchanged backend to keep its state")
+	}
+	if backends[1].GetURL().String() != "http://localhost:8083" {
+		t.Errorf("Expected new backend http://localhost:8083, got %s", backends[1].GetURL())
+	}
+
+	if err := lb.SetBackends(nil); err == nil {
+		t.Error("Expected error for empty backend list")
+	}
+}
+
+func TestLoadBalancer_SetBackendsReweightsInPlace(t *testing.T) {
+	lb, err := New(WithBackendConfigs(backend.Config{URL: "http://localhost:8081", MaxConnections: 2}))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	b := lb.GetBackends()[0]
+	b.TryAcquire()
+
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:8081", MaxConnections: 2, Weight: 4}}); err != nil {
+		t.Fatalf("SetBackends failed: %v", err)
+	}
+	if lb.GetBackends()[0] != b {
+		t.Fatal("Expected a weight change to keep the backend")
+	}
+	if b.GetWeight() != 4 || b.InFlight() != 1 {
+		t.Errorf("Expected weight 4 with the in-flight request kept, got weight %d, %d in flight", b.GetWeight(), b.InFlight())
+	}
+
+	// Any other change recreates it
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:8081", MaxConnections: 3, Weight: 4}}); err != nil {
+		t.Fatalf("SetBackends failed: %v", err)
+	}
+	if lb.GetBackends()[0] == b {
+		t.Error("Expected a new connection limit to recreate the backend")
+	}
+}
+
+func TestLoadBalancer_AddRemoveBackend(t *testing.T) {
+	lb, err := New(WithBackends("http://localhost:8081"))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	initial := lb.GetBackends()[0]
+	var added, removed []string
+	lb.OnBackendAdded(func(b *backend.Backend) { added = append(added, b.GetURL().String()) })
+	lb.OnBackendRemoved(func(b *backend.Backend) { removed = append(removed, b.GetURL().String()) })
+
+	if err := lb.AddBackend("http://localhost:8082", WithWeight(3), WithLabels(map[string]string{"zone": "b"})); err != nil {
+		t.Fatalf("AddBackend failed: %v", err)
+	}
+	backends := lb.GetBackends()
+	if len(backends) != 2 || backends[0] != initial {
+		t.Fatalf("Expected the existing backend kept and one added, got %d backends", len(backends))
+	}
+	if w := backends[1].GetWeight(); w != 3 {
+		t.Errorf("Expected weight 3, got %d", w)
+	}
+	if primaries, _ := lb.AvailableWithLabel("zone", "b"); len(primaries) != 1 {
+		t.Errorf("Expected the added backend indexed by label, got %d", len(primaries))
+	}
+	if len(added) != 1 || added[0] != "http://localhost:8082" {
+		t.Errorf("Expected the added hook for http://localhost:8082, got %v", added)
+	}
+	if err := lb.AddBackend("http://localhost:8082"); !errors.Is(err, ErrBackendExists) {
+		t.Errorf("Expected ErrBackendExists, got %v", err)
+	}
+	if err := lb.AddBackend("://localhost:8083"); err == nil {
+		t.Error("Expected error for an invalid URL")
+	}
+
+	if err := lb.RemoveBackend("http://localhost:8081"); err != nil {
+		t.Fatalf("RemoveBackend failed: %v", err)
+	}
+	backends = lb.GetBackends()
+	if len(backends) != 1 || backends[0].GetURL().String() != "http://localhost:8082" {
+		t.Fatalf("Expected only http://localhost:8082 left, got %d backends", len(backends))
+	}
+	if n := len(lb.available.Load().primaries); n != 1 {
+		t.Errorf("Expected 1 available backend, got %d", n)
+	}
+	if len(removed) != 1 || removed[0] != "http://localhost:8081" {
+		t.Errorf("Expected the removed hook for http://localhost:8081, got %v", removed)
+	}
+	// A removed backend's state no longer reaches the snapshots
+	initial.SetAlive(false)
+	initial.SetAlive(true)
+	if n := len(lb.available.Load().primaries); n != 1 {
+		t.Errorf("Expected the removed backend to stay out, got %d available", n)
+	}
+
+	if err := lb.RemoveBackend("http://localhost:8081"); !errors.Is(err, ErrBackendNotFound) {
+		t.Errorf("Expected ErrBackendNotFound, got %v", err)
+	}
+	if err := lb.RemoveBackend("http://localhost:8082"); !errors.Is(err, ErrLastBackend) {
+		t.Errorf("Expected ErrLastBackend, got %v", err)
+	}
+}
+
+func TestLoadBalancer_RequireHealthy(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs:    []string{"http://localhost:8081"},
+		Strategy:       strategy.NewRoundRobin(),
+		RequireHealthy: true,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	initial := lb.GetBackends()[0]
+	if initial.IsAlive() {
+		t.Error("Expected initial backend to wait for a passing probe")
+	}
+	initial.SetAlive(true)
+
+	// A changed setting recreates the backend but keeps its health state
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:8081", Weight: 2}, {URL: "http://localhost:8082"}}); err != nil {
+		t.Fatalf("SetBackends failed: %v", err)
+	}
+	backends := lb.GetBackends()
+	if !backends[0].IsAlive() {
+		t.Error("Expected reconfigured backend to keep its health state")
+	}
+	if backends[1].IsAlive() {
+		t.Error("Expected newly added backend to wait for a passing probe")
+	}
+
+	lb.SetHealthPolicy(false, 0)
+	lb.SetBackends([]backend.Config{{URL: "http://localhost:8081", Weight: 2}, {URL: "http://localhost:8083"}})
+	if !lb.GetBackends()[1].IsAlive() {
+		t.Error("Expected new backend to serve immediately with the registered policy")
+	}
+}
+
+func TestLoadBalancer_AvailableSnapshot(t *testing.T) {
+	lb, err := New(WithBackends("http://localhost:8081", "http://localhost:8082"))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	backends := lb.GetBackends()
+	if n := len(lb.available.Load().primaries); n != 2 {
+		t.Errorf("Expected 2 available backends, got %d", n)
+	}
+
+	backends[0].SetAlive(false)
+	for i := 0; i < 4; i++ {
+		if b := lb.selectBackend(); b != backends[1] {
+			t.Errorf("Expected the alive backend, got %v", b)
+		}
+	}
+	backends[1].SetDraining(true)
+	if b := lb.selectBackend(); b != nil {
+		t.Errorf("Expected no backend, got %s", b.GetURL())
+	}
+	backends[0].SetAlive(true)
+	if b := lb.selectBackend(); b != backends[0] {
+		t.Errorf("Expected the recovered backend, got %v", b)
+	}
+
+	allocs := testing.AllocsPerRun(100, func() { lb.selectBackend() })
+	if allocs != 0 {
+		t.Errorf("Expected selection without allocations, got %v", allocs)
+	}
+}
+
+func TestLoadBalancer_AvailableIndex(t *testing.T) {
+	var configs []backend.Config
+	for i, zone := range []string{"a", "b", "a", "b"} {
+		configs = append(configs, backend.Config{
+			URL:    "http://localhost:" + strconv.Itoa(8081+i),
+			Labels: map[string]string{"zone": zone},
+			Backup: i == 3,
+		})
+	}
+	lb, err := NewLoadBalancer(Config{Backends: configs, Strategy: strategy.NewRoundRobin()})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	backends := lb.GetBackends()
+
+	urls := func(list []*backend.Backend) string {
+		var out []string
+		for _, b := range list {
+			out = append(out, b.String())
+		}
+		return strings.Join(out, ",")
+	}
+	check := func(label string, wantPrimaries, wantBackups []*backend.Backend) {
+		t.Helper()
+		primaries, backups := lb.AvailableWithLabel("zone", label)
+		if urls(primaries) != urls(wantPrimaries) || urls(backups) != urls(wantBackups) {
+			t.Errorf("zone=%s: expected %s / %s, got %s / %s", label,
+				urls(wantPrimaries), urls(wantBackups), urls(primaries), urls(backups))
+		}
+	}
+	check("a", []*backend.Backend{backends[0], backends[2]}, nil)
+	check("b", []*backend.Backend{backends[1]}, []*backend.Backend{backends[3]})
+
+	backends[0].SetAlive(false)
+	backends[3].SetDisabled(true)
+	check("a", []*backend.Backend{backends[2]}, nil)
+	check("b", []*backend.Backend{backends[1]}, nil)
+	if got := urls(lb.available.Load().primaries); got != urls(backends[1:3]) {
+		t.Errorf("Expected the remaining primaries, got %s", got)
+	}
+
+	// Recovered backends take their place in the list again
+	backends[0].SetAlive(true)
+	check("a", []*backend.Backend{backends[0], backends[2]}, nil)
+	if got := urls(lb.available.Load().primaries); got != urls(backends[:3]) {
+		t.Errorf("Expected the primaries in list order, got %s", got)
+	}
+
+	// Backends no longer in the list don't touch the snapshots
+	if err := lb.SetBackends(configs[1:2]); err != nil {
+		t.Fatalf("Failed to set backends: %v", err)
+	}
+	backends[2].SetAlive(false)
+	backends[2].SetAlive(true)
+	if got := urls(lb.available.Load().primaries); got != backends[1].String() {
+		t.Errorf("Expected only the remaining member, got %s", got)
+	}
+	if primaries, _ := lb.AvailableWithLabel("zone", "a"); primaries != nil {
+		t.Errorf("Expected no zone=a backends, got %s", urls(primaries))
+	}
+}
+
+func TestLoadBalancer_Subset(t *testing.T) {
+	var configs []backend.Config
+	for i, zone := range []string{"a", "b", "a"} {
+		configs = append(configs, backend.Config{
+			URL:    "http://localhost:" + strconv.Itoa(8081+i),
+			Labels: map[string]string{"zone": zone, "tier": "web"},
+		})
+	}
+	lb, err := New(WithBackendConfigs(configs...), WithSubset(backend.Subset{Labels: map[string]string{"zone": "a", "tier": "web"}}))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	backends := lb.GetBackends()
+
+	for range 4 {
+		if b := lb.selectBackend(); b != backends[0] && b != backends[2] {
+			t.Fatalf("Expected a zone=a backend, got %v", b)
+		}
+	}
+	if stats := lb.GetStats(); stats.Subset != "tier=web,zone=a" {
+		t.Errorf("Expected the subset in stats, got %q", stats.Subset)
+	}
+
+	// Without fallback requests fail once the subset is down
+	backends[0].SetAlive(false)
+	backends[2].SetAlive(false)
+	if b := lb.selectBackend(); b != nil {
+		t.Errorf("Expected no backend outside the subset, got %s", b)
+	}
+
+	// With fallback the rest of the pool serves until the subset recovers
+	lb.SetSubset(backend.Subset{Labels: map[string]string{"zone": "a"}, Fallback: true})
+	if b := lb.selectBackend(); b != backends[1] {
+		t.Errorf("Expected the fallback backend, got %v", b)
+	}
+	backends[2].SetAlive(true)
+	if b := lb.selectBackend(); b != backends[2] {
+		t.Errorf("Expected the recovered subset member, got %v", b)
+	}
+
+	lb.SetSubset(backend.Subset{})
+	seen := make(map[*backend.Backend]bool)
+	for range 4 {
+		seen[lb.selectBackend()] = true
+	}
+	if !seen[backends[1]] || !seen[backends[2]] {
+		t.Errorf("Expected the whole pool without a subset, got %v", seen)
+	}
+}
+
+func BenchmarkLoadBalancer_SelectBackend(b *testing.B) {
+	configs := make([]backend.Config, 5000)
+	for i := range configs {
+		configs[i] = backend.Config{URL: fmt.Sprintf("http://10.0.%d.%d:8080", i/250, i%250+1)}
+	}
+	for _, s := range []strategy.Strategy{strategy.NewRoundRobin(), strategy.NewRandom(), strategy.NewWeightedRoundRobin(nil)} {
+		lb, err := NewLoadBalancer(Config{Backends: configs, Strategy: s})
+		if err != nil {
+			b.Fatalf("Failed to create load balancer: %v", err)
+		}
+		// Some backends down, as in a large pool
+		for i, be := range lb.GetBackends() {
+			if i%100 == 0 {
+				be.SetAlive(false)
+			}
+		}
+		b.Run(s.Name(), func(b *testing.B) {
+			b.ReportAllocs()
+			for b.Loop() {
+				if lb.selectBackend() == nil {
+					b.Fatal("Expected a backend")
+				}
+			}
+		})
+	}
+}
+
+func TestLoadBalancer_ConcurrentPoolUpdates(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 50; i++ {
+			configs := []backend.Config{{URL: server.URL, Weight: i%3 + 1}}
+			if err := lb.SetBackends(configs); err != nil {
+				t.Errorf("Failed to set backends: %v", err)
+			}
+			lb.SetStrategy(strategy.NewRandom())
+		}
+	}()
+	for i := 0; i < 50; i++ {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status 200 during pool updates, got %d", rec.Code)
+		}
+	}
+	<-done
+}
+
+func TestLoadBalancer_BackupBackends(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		Backends: []backend.Config{
+			{URL: "http://primary:8081"},
+			{URL: "http://backup:8082", Backup: true},
+		},
+		Strategy: strategy.NewRoundRobin(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	primary, backup := lb.GetBackends()[0], lb.GetBackends()[1]
+
+	for i := 0; i < 4; i++ {
+		if b := lb.selectBackend(); b != primary {
+			t.Fatalf("Expected primary while it is available, got %v", b.GetURL())
+		}
+	}
+
+	primary.SetAlive(false)
+	if b := lb.selectBackend(); b != backup {
+		t.Errorf("Expected backup when no primary is available, got %v", b)
+	}
+
+	backup.SetAlive(false)
+	if b := lb.selectBackend(); b != nil {
+		t.Errorf("Expected no backend, got %v", b.GetURL())
+	}
+}
+
+func TestLoadBalancer_MaxConnections(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		Backends: []backend.Config{{URL: "http://localhost:8081", MaxConnections: 1}},
+		Strategy: strategy.NewLeastConnections(),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	b := lb.GetBackends()[0]
+	b.IncrementConnections()
+	if got := lb.selectBackend(); got != nil {
+		t.Errorf("Expected no backend at its connection limit, got %v", got.GetURL())
+	}
+	b.DecrementConnections()
+	if got := lb.selectBackend(); got != b {
+		t.Error("Expected backend below its connection limit")
+	}
+}
+
+// panickingStrategy stands in for a buggy custom strategy
+type panickingStrategy struct{}
+
+func (panickingStrategy) SelectBackend([]*backend.Backend) *backend.Backend { panic("boom") }
+func (panickingStrategy) Name() string                                      { return "panicking" }
+
+func TestLoadBalancer_RecoversPanics(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tests := []struct {
+		name  string
+		setup func(lb *LoadBalancer)
+	}{
+		{"strategy", func(lb *LoadBalancer) { lb.SetStrategy(panickingStrategy{}) }},
+		{"modify response", func(lb *LoadBalancer) {
+			lb.GetBackends()[0].ReverseProxy.ModifyResponse = func(*http.Response) error { panic("boom") }
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lb, err := New(WithBackends(server.URL))
+			if err != nil {
+				t.Fatalf("Failed to create load balancer: %v", err)
+			}
+			tt.setup(lb)
+
+			rec := httptest.NewRecorder()
+			lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+			if rec.Code != http.StatusBadGateway {
+				t.Errorf("Expected 502, got %d", rec.Code)
+			}
+			if got := lb.errorCounts()[backend.ErrorPanic]; got != 1 {
+				t.Errorf("Expected 1 panic counted, got %d", got)
+			}
+			if got := lb.InFlight(); got != 0 {
+				t.Errorf("Expected the backend slot to be released, got %d in flight", got)
+			}
+		})
+	}
+}
+
+func TestLoadBalancer_StreamsResponses(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "10")
+		w.Write([]byte("first"))
+		w.(http.Flusher).Flush()
+		<-release
+		w.Write([]byte("-rest"))
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackendConfigs(backend.Config{URL: server.URL, FlushInterval: 10 * time.Millisecond}))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	front := httptest.NewServer(middleware.Chain(lb, middleware.RequestID, middleware.Logger, middleware.Recovery))
+	defer front.Close()
+	defer close(release)
+
+	// The backend holds the rest back, so anything read arrived streamed
+	client := &http.Client{Timeout: 2 * time.Second}
+	resp, err := client.Get(front.URL)
+	if err != nil {
+		t.Fatalf("Expected the response to start before the backend finished it: %v", err)
+	}
+	defer resp.Body.Close()
+
+	buf := make([]byte, 5)
+	if _, err := io.ReadFull(resp.Body, buf); err != nil || string(buf) != "first" {
+		t.Errorf("Expected the first chunk before the backend finished the response, got %q (%v)", buf, err)
+	}
+}
+
+func TestLoadBalancer_H2CBalancesPerRequest(t *testing.T) {
+	h2c := func() *http.Protocols {
+		p := new(http.Protocols)
+		p.SetUnencryptedHTTP2(true)
+		return p
+	}
+	var hits [2]atomic.Int64
+	var urls []string
+	for i := range hits {
+		server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits[i].Add(1)
+			w.Header().Set("Trailer", "Grpc-Status")
+			w.Write([]byte(r.Proto))
+			w.Header().Set("Grpc-Status", "0")
+		}))
+		server.Config.Protocols = h2c()
+		server.Start()
+		defer server.Close()
+		urls = append(urls, server.URL)
+	}
+
+	on := true
+	lb, err := New(
+		WithBackendConfigs(backend.Config{URL: urls[0], Transport: backend.TransportConfig{H2C: &on}},
+			backend.Config{URL: urls[1], Transport: backend.TransportConfig{H2C: &on}}),
+		WithStrategy(strategy.NewRoundRobin()),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	front := httptest.NewUnstartedServer(middleware.Chain(lb, middleware.RequestID, middleware.Logger, middleware.Recovery))
+	front.Config.Protocols = h2c()
+	front.Start()
+	defer front.Close()
+
+	// One client connection multiplexes every request, like a gRPC channel
+	client := &http.Client{Transport: &http.Transport{Protocols: h2c()}}
+	for range 4 {
+		resp, err := client.Post(front.URL+"/pkg.Service/Method", "application/grpc", nil)
+		if err != nil {
+			t.Fatalf("Request failed: %v", err)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		if string(body) != "HTTP/2.0" {
+			t.Errorf("Expected the backend to be reached over HTTP/2.0, got %q", body)
+		}
+		if got := resp.Trailer.Get("Grpc-Status"); got != "0" {
+			t.Errorf("Expected the Grpc-Status trailer, got %q", got)
+		}
+	}
+	if hits[0].Load() != 2 || hits[1].Load() != 2 {
+		t.Errorf("Expected the requests of one connection spread 2/2, got %d/%d", hits[0].Load(), hits[1].Load())
+	}
+}
+
+func TestLoadBalancer_MaxRequestBytes(t *testing.T) {
+	var reached atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		reached.Add(1)
+		io.Copy(io.Discard, r.Body)
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL), WithMaxRequestBytes(8))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	front := httptest.NewServer(lb)
+	defer front.Close()
+
+	tests := []struct {
+		name    string
+		body    io.Reader
+		want    int
+		reached int32
+	}{
+		{"within limit", strings.NewReader("12345678"), http.StatusOK, 1},
+		{"declared too large", strings.NewReader("123456789"), http.StatusRequestEntityTooLarge, 0},
+		// A reader of unknown length is sent chunked
+		{"chunked too large", io.MultiReader(strings.NewReader("123456789")), http.StatusRequestEntityTooLarge, 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			reached.Store(0)
+			resp, err := http.Post(front.URL, "text/plain", tt.body)
+			if err != nil {
+				t.Fatalf("Request failed: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tt.want {
+				t.Errorf("Expected %d, got %d", tt.want, resp.StatusCode)
+			}
+			if got := reached.Load(); got > tt.reached {
+				t.Errorf("Expected the backend to be reached at most %d times, got %d", tt.reached, got)
+			}
+		})
+	}
+	if b := lb.GetBackends()[0]; !b.IsAlive() || b.GetFailCount() != 0 {
+		t.Errorf("Expected oversized requests not to count against the backend, got %d failures", b.GetFailCount())
+	}
+}
+
+func TestLoadBalancer_MaxInFlight(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL), WithMaxInFlight(1))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	first := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		first <- rec.Code
+	}()
+	deadline := time.Now().Add(2 * time.Second)
+	for lb.InFlight() == 0 && time.Now().Before(deadline) {
+		time.Sleep(time.Millisecond)
+	}
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 over the in-flight limit, got %d", rec.Code)
+	}
+	close(release)
+	if code := <-first; code != http.StatusOK {
+		t.Errorf("Expected 200 for the admitted request, got %d", code)
+	}
+	if got := lb.GetStats().LimitRejected; got != 1 {
+		t.Errorf("Expected 1 limit rejection, got %v", got)
+	}
+}
+
+func TestAdaptiveLimit(t *testing.T) {
+	a := newAdaptiveLimit(AdaptiveLimitConfig{Enabled: true, MinLimit: 5, Latency: 100 * time.Millisecond}, 20)
+	if got := a.current(); got != 20 {
+		t.Fatalf("Expected the limit to start at maxInFlight 20, got %d", got)
+	}
+
+	// Slow answers to requests started together shrink the limit once
+	start := time.Now()
+	for range 10 {
+		a.observe(start, time.Second, false)
+	}
+	if got := a.current(); got != 18 {
+		t.Errorf("Expected one decrease to 18, got %d", got)
+	}
+	for i := range 50 {
+		a.observe(start.Add(time.Duration(i+2)*time.Second), 0, true)
+	}
+	if got := a.current(); got != 5 {
+		t.Errorf("Expected failures to shrink the limit to minLimit 5, got %d", got)
+	}
+
+	// Fast answers raise it by about one per round of answers, up to maxLimit
+	later := start.Add(time.Minute)
+	for range 6 {
+		a.observe(later, time.Millisecond, false)
+	}
+	if got := a.current(); got != 6 {
+		t.Errorf("Expected 6 after a round of fast answers, got %d", got)
+	}
+	for range 1000 {
+		a.observe(later, time.Millisecond, false)
+	}
+	if got := a.current(); got != 20 {
+		t.Errorf("Expected the limit capped at 20, got %d", got)
+	}
+}
+
+func TestLoadBalancer_AdaptiveLimit(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL), WithAdaptiveLimit(AdaptiveLimitConfig{MinLimit: 2, MaxLimit: 4, Backoff: 0.5}))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	for range 3 {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+	stats := lb.GetStats()
+	if !stats.AdaptiveLimit || stats.MaxInFlight != 2 {
+		t.Errorf("Expected failing answers to shrink the adaptive limit to 2, got %v %d", stats.AdaptiveLimit, stats.MaxInFlight)
+	}
+}
+
+func TestLoadBalancer_Bytes(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		w.Write([]byte("hello"))
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPost, "/", strings.NewReader("hello world")))
+	lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+	stats := lb.GetStats()
+	if stats.BytesIn != 11 || stats.BytesOut != 10 {
+		t.Errorf("Expected 11 bytes in and 10 out, got %d and %d", stats.BytesIn, stats.BytesOut)
+	}
+	if b := stats.Backends[0]; b.BytesIn != 11 || b.BytesOut != 10 {
+		t.Errorf("Expected the backend to count 11 bytes in and 10 out, got %d and %d", b.BytesIn, b.BytesOut)
+	}
+	if got := formatBytes(1536); got != "1.5 KiB" {
+		t.Errorf("formatBytes(1536) = %q, want 1.5 KiB", got)
+	}
+}
+
+func TestLoadBalancer_Tracing(t *testing.T) {
+	var received string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		received = r.Header.Get(tracing.TraceparentHeader)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	exporter := &spanRecorder{}
+	lb, err := New(WithBackends(server.URL), WithTracer(tracing.NewTracer(1, exporter)))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	req := httptest.NewRequest(http.MethodGet, "/traced", nil)
+	req.Header.Set(tracing.TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	lb.ServeHTTP(httptest.NewRecorder(), req)
+
+	if len(exporter.spans) != 1 {
+		t.Fatalf("Expected 1 exported span, got %d", len(exporter.spans))
+	}
+	span := exporter.spans[0]
+	if span.ParentSpanID != "00f067aa0ba902b7" || span.Context.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" {
+		t.Errorf("Expected the span to continue the incoming trace, got %+v", span.Context)
+	}
+	if received != span.Context.Traceparent() {
+		t.Errorf("Expected the backend to receive %q, got %q", span.Context.Traceparent(), received)
+	}
+	attrs := make(map[string]any)
+	for _, a := range span.Attributes {
+		attrs[a.Key] = a.Value
+	}
+	if attrs["lb.backend"] != server.URL || attrs["http.response.status_code"] != http.StatusBadGateway {
+		t.Errorf("Unexpected attributes %v", attrs)
+	}
+	if span.Error == "" {
+		t.Error("Expected a 502 to mark the span as failed")
+	}
+}
+
+// spanRecorder keeps the exported spans
+type spanRecorder struct{ spans []*tracing.Span }
+
+func (r *spanRecorder) Export(s *tracing.Span) { r.spans = append(r.spans, s) }
+func (r *spanRecorder) Close() error           { return nil }
+
+func TestLoadBalancer_OutlierDetection(t *testing.T) {
+	ok := func(w http.ResponseWriter, r *http.Request) {}
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+	}))
+	defer slow.Close()
+	fast1 := httptest.NewServer(http.HandlerFunc(ok))
+	defer fast1.Close()
+	fast2 := httptest.NewServer(http.HandlerFunc(ok))
+	defer fast2.Close()
+
+	lb, err := New(
+		WithBackends(failing.URL, slow.URL, fast1.URL, fast2.URL),
+		WithOutlierDetection(OutlierConfig{MinRequests: 5, MaxEjectionPercent: 50, LatencyFactor: 5}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	for range 40 {
+		lb.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+	}
+
+	now := time.Now()
+	lb.analyzeOutliers(now)
+	ejected := map[string]bool{}
+	for _, b := range lb.GetBackends() {
+		if b.IsEjected() {
+			ejected[b.String()] = true
+		}
+	}
+	if len(ejected) != 2 || !ejected[failing.URL] || !ejected[slow.URL] {
+		t.Fatalf("Expected the failing and the slow backend ejected, got %v", ejected)
+	}
+	if got := lb.GetStats().OutlierEjections; got != 2 {
+		t.Errorf("Expected 2 ejections, got %d", got)
+	}
+	for range 10 {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusOK {
+			t.Fatalf("Expected ejected backends out of rotation, got %d", rec.Code)
+		}
+	}
+
+	// Ejections end after BaseEjectionTime
+	lb.analyzeOutliers(now.Add(time.Minute))
+	for _, b := range lb.GetBackends() {
+		if b.IsEjected() {
+			t.Errorf("Expected %s back after its ejection time", b)
+		}
+	}
+}
+
+func TestLoadBalancer_ClientCancel(t *testing.T) {
+	received := make(chan struct{})
+	upstreamCanceled := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(received)
+		<-r.Context().Done()
+		close(upstreamCanceled)
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rec := httptest.NewRecorder()
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil).WithContext(ctx))
+	}()
+	<-received
+	cancel()
+
+	select {
+	case <-upstreamCanceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected the upstream request to be canceled with the client")
+	}
+	<-done
+
+	if rec.Code != backend.StatusClientClosedRequest {
+		t.Errorf("Expected status %d, got %d", backend.StatusClientClosedRequest, rec.Code)
+	}
+	b := lb.GetBackends()[0]
+	if b.InFlight() != 0 || b.GetFailCount() != 0 || !b.IsAlive() {
+		t.Errorf("Expected the backend untouched, got %d in flight and %d failures", b.InFlight(), b.GetFailCount())
+	}
+	stats := lb.GetStats()
+	if stats.ClientCanceled != 1 || stats.FailedRequests != 0 {
+		t.Errorf("Expected 1 canceled and no failed request, got %d and %d", stats.ClientCanceled, stats.FailedRequests)
+	}
+}
+
+func TestLoadBalancer_ErrorHandler(t *testing.T) {
+	refused := httptest.NewServer(http.NotFoundHandler())
+	refused.Close()
+
+	var handled []string
+	lb, err := New(
+		WithBackends(refused.URL),
+		WithErrorHandler(func(w http.ResponseWriter, r *http.Request, err error) {
+			handled = append(handled, backend.ClassifyError(r.Context(), err))
+			http.Error(w, "try again later", http.StatusServiceUnavailable)
+		}),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	// Backends added later get the handler too
+	other := httptest.NewServer(http.NotFoundHandler())
+	other.Close()
+	if err := lb.SetBackends([]backend.Config{{URL: refused.URL}, {URL: other.URL}}); err != nil {
+		t.Fatalf("Failed to set backends: %v", err)
+	}
+	for range 2 {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != "try again later\n" {
+			t.Errorf("Expected the custom answer, got %d %q", rec.Code, rec.Body.String())
+		}
+	}
+	if len(handled) != 2 || handled[1] != backend.ErrorConnRefused {
+		t.Errorf("Expected 2 refused connections handled, got %v", handled)
+	}
+}
+
+func TestLoadBalancer_Queue(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow" {
+			<-release
+		}
+	}))
+	defer server.Close()
+
+	lb, err := New(
+		WithBackendConfigs(backend.Config{URL: server.URL, MaxConnections: 1}),
+		WithQueue(1, 5*time.Second),
+	)
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	b := lb.GetBackends()[0]
+
+	serve := func(path string) chan int {
+		code := make(chan int, 1)
+		go func() {
+			rec := httptest.NewRecorder()
+			lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+			code <- rec.Code
+		}()
+		return code
+	}
+	waitFor := func(cond func() bool) {
+		deadline := time.Now().Add(2 * time.Second)
+		for !cond() && time.Now().Before(deadline) {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	slow := serve("/slow")
+	waitFor(func() bool { return b.InFlight() == 1 })
+	queued := serve("/")
+	waitFor(func() bool { return lb.queue.length.Load() == 1 })
+
+	if code := <-serve("/"); code != http.StatusTooManyRequests {
+		t.Errorf("Expected 429 with a full queue, got %d", code)
+	}
+
+	close(release)
+	if code := <-slow; code != http.StatusOK {
+		t.Errorf("Expected 200 for the slow request, got %d", code)
+	}
+	if code := <-queued; code != http.StatusOK {
+		t.Errorf("Expected the queued request to be served once the backend freed up, got %d", code)
+	}
+
+	lb.SetQueue(QueueConfig{MaxLength: 1, Timeout: 10 * time.Millisecond})
+	b.IncrementConnections()
+	if code := <-serve("/"); code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 after the queue timeout, got %d", code)
+	}
+
+	stats := lb.GetStats().Queue
+	if stats == nil || stats.Queued != 2 || stats.Rejected != 1 || stats.TimedOut != 1 {
+		t.Errorf("Expected 2 queued, 1 rejected and 1 timed out, got %v", stats)
+	}
+}
+
+func TestLoadBalancer_Drain(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs:         []string{server.URL},
+		Strategy:            strategy.NewRoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	inFlight := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		inFlight <- rec.Code
+	}()
+	for lb.InFlight() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// The drain times out while the request is still running
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := lb.Drain(ctx); err == nil {
+		t.Error("Expected drain to time out with a request in flight")
+	}
+	if !lb.IsDraining() {
+		t.Error("Expected the load balancer to be draining")
+	}
+
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected 503 for a new request while draining, got %d", rec.Code)
+	}
+
+	close(release)
+	if code := <-inFlight; code != http.StatusOK {
+		t.Errorf("Expected the in-flight request to complete with 200, got %d", code)
+	}
+	if err := lb.Drain(context.Background()); err != nil {
+		t.Errorf("Expected drain to complete, got %v", err)
+	}
+}
+
+func TestLoadBalancer_DrainWaitsForQueue(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL), WithQueue(1, 5*time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	lb.GetBackends()[0].SetAlive(false)
+
+	code := make(chan int, 1)
+	go func() {
+		rec := httptest.NewRecorder()
+		lb.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/", nil))
+		code <- rec.Code
+	}()
+	for lb.queue.length.Load() == 0 {
+		time.Sleep(time.Millisecond)
+	}
+
+	// Nothing is proxied yet, but the queued request may still be
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	if err := lb.Drain(ctx); err == nil {
+		t.Error("Expected drain to wait for the queued request")
+	}
+	lb.GetBackends()[0].SetAlive(true)
+	if c := <-code; c != http.StatusOK {
+		t.Errorf("Expected the queued request to complete, got %d", c)
+	}
+	if err := lb.Drain(context.Background()); err != nil {
+		t.Errorf("Expected drain to complete, got %v", err)
+	}
+}
+
+func TestLoadBalancer_Readyz(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs:         []string{"http://backend1:80", "http://backend2:80"},
+		Strategy:            strategy.NewRoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	readyz := func() (int, Readiness) {
+		rec := httptest.NewRecorder()
+		lb.HandleReadyz()(rec, httptest.NewRequest(http.MethodGet, "/readyz", nil))
+		var r Readiness
+		json.Unmarshal(rec.Body.Bytes(), &r)
+		return rec.Code, r
+	}
+
+	if code, r := readyz(); code != http.StatusServiceUnavailable || r.Reason != "not started" {
+		t.Errorf("Expected 503 before Start, got %d %+v", code, r)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	lb.started.Store(true)
+	if code, r := readyz(); code != http.StatusOK || r.HealthyBackends != 2 {
+		t.Errorf("Expected 200 with 2 healthy backends, got %d %+v", code, r)
+	}
+
+	for _, b := range lb.GetBackends() {
+		b.SetAlive(false)
+	}
+	if code, r := readyz(); code != http.StatusServiceUnavailable || r.Reason != "no healthy backends" {
+		t.Errorf("Expected 503 without healthy backends, got %d %+v", code, r)
+	}
+
+	lb.GetBackends()[0].SetAlive(true)
+	lb.Drain(ctx)
+	if code, r := readyz(); code != http.StatusServiceUnavailable || r.Reason != "draining" {
+		t.Errorf("Expected 503 while draining, got %d %+v", code, r)
+	}
+
+	rec := httptest.NewRecorder()
+	lb.HandleLivez()(rec, httptest.NewRequest(http.MethodGet, "/livez", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected livez to stay 200 while draining, got %d", rec.Code)
+	}
+}
+
+func TestLoadBalancer_SetBackendEnabled(t *testing.T) {
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs:         []string{"http://backend1:80", "http://backend2:80"},
+		Strategy:            strategy.NewRoundRobin(),
+		HealthCheckInterval: 10 * time.Second,
+		HealthCheckTimeout:  5 * time.Second,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	if err := lb.SetBackendEnabled("http://missing:80", false); err == nil {
+		t.Error("Expected an error for an unknown backend")
+	}
+	if err := lb.SetBackendEnabled("http://backend1:80", false); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	b := lb.GetBackends()[0]
+	b.SetAlive(true) // a passing health probe doesn't put it back
+	if b.IsAvailable() {
+		t.Error("Expected a disabled backend to be unavailable")
+	}
+
+	// Removed and re-added by reloads, the backend stays disabled
+	lb.SetBackends([]backend.Config{{URL: "http://backend2:80"}})
+	lb.SetBackends([]backend.Config{{URL: "http://backend1:80", Weight: 2}, {URL: "http://backend2:80"}})
+	if !lb.GetBackends()[0].IsDisabled() {
+		t.Error("Expected backend1 to stay disabled across reloads")
+	}
+	if got := lb.DisabledBackends(); len(got) != 1 || got[0] != "http://backend1:80" {
+		t.Errorf("Expected [http://backend1:80], got %v", got)
+	}
+
+	lb.SetBackendEnabled("http://backend1:80", true)
+	if !lb.GetBackends()[0].IsAvailable() || len(lb.DisabledBackends()) != 0 {
+		t.Error("Expected backend1 to be back in rotation")
+	}
+}
+
+func TestLoadBalancer_StateRoundTrip(t *testing.T) {
+	newLB := func() *LoadBalancer {
+		lb, err := NewLoadBalancer(Config{
+			BackendURLs:         []string{"http://backend1:80", "http://backend2:80", "http://backend3:80"},
+			Strategy:            strategy.NewRoundRobin(),
+			HealthCheckInterval: 10 * time.Second,
+			HealthCheckTimeout:  5 * time.Second,
+		})
+		if err != nil {
+			t.Fatalf("Failed to create load balancer: %v", err)
+		}
+		return lb
+	}
+
+	lb := newLB()
+	backends := lb.GetBackends()
+	backends[0].SetAlive(false)
+	backends[1].SetWeight(5)
+	backends[1].SetDraining(true)
+	lb.SetBackendEnabled("http://backend3:80", false)
+
+	path := t.TempDir() + "/state.json"
+	if err := SaveState(path, lb.State()); err != nil {
+		t.Fatalf("Failed to save state: %v", err)
+	}
+	st, err := LoadState(path)
+	if err != nil {
+		t.Fatalf("Failed to load state: %v", err)
+	}
+
+	restarted := newLB()
+	if n, err := restarted.RestoreState(st); err != nil || n != 3 {
+		t.Errorf("Expected 3 restored backends, got %d (%v)", n, err)
+	}
+	got := restarted.GetBackends()
+	if got[0].IsAlive() {
+		t.Error("Expected backend1 to stay down after restart")
+	}
+	if got[1].GetWeight() != 5 || !got[1].IsDraining() {
+		t.Errorf("Expected backend2 weight 5 and draining, got %d %v", got[1].GetWeight(), got[1].IsDraining())
+	}
+	if !got[2].IsDisabled() {
+		t.Error("Expected backend3 to stay disabled")
+	}
+
+	if _, err := LoadState(t.TempDir() + "/missing.json"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("Expected fs.ErrNotExist for a missing file, got %v", err)
+	}
+}
+
+func TestLoadBalancer_StateStickySessions(t *testing.T) {
+	newLB := func() *LoadBalancer {
+		lb, err := NewLoadBalancer(Config{
+			BackendURLs: []string{"http://backend1:80", "http://backend2:80"},
+			Strategy:    strategy.NewRoundRobin(),
+			Sticky:      sticky.NewAffinity(sticky.NewMemoryStore(), "", 0),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create load balancer: %v", err)
+		}
+		return lb
+	}
+
+	lb := newLB()
+	rec := httptest.NewRecorder()
+	pinned := pickSticky(lb, rec, httptest.NewRequest("GET", "/", nil))
+	cookies := rec.Result().Cookies()
+	if pinned == nil || len(cookies) != 1 {
+		t.Fatalf("Expected a session cookie, got %v", cookies)
+	}
+	st := lb.State()
+	if len(st.Sessions) != 1 || st.Sessions[0].Backend != pinned.GetURL().String() {
+		t.Fatalf("Expected the session saved, got %+v", st.Sessions)
+	}
+
+	// The session keeps its backend on the restarted instance, whose next
+	// pick is the other backend
+	restarted := newLB()
+	if _, err := restarted.RestoreState(st); err != nil {
+		t.Fatalf("Failed to restore state: %v", err)
+	}
+	restarted.selectBackend()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(cookies[0])
+	rec = httptest.NewRecorder()
+	if b := pickSticky(restarted, rec, req); b == nil || b.GetURL().String() != pinned.GetURL().String() || len(rec.Result().Cookies()) != 0 {
+		t.Errorf("Expected the restored session on %s without a new cookie, got %v", pinned.GetURL(), b)
+	}
+}
+
+func TestLoadBalancer_ImportState(t *testing.T) {
+	newLB := func(urls ...string) *LoadBalancer {
+		lb, err := NewLoadBalancer(Config{BackendURLs: urls, Strategy: strategy.NewRoundRobin()})
+		if err != nil {
+			t.Fatalf("Failed to create load balancer: %v", err)
+		}
+		return lb
+	}
+
+	source := newLB("http://backend1:80", "http://backend2:80")
+	source.GetBackends()[0].SetWeight(7)
+	source.GetBackends()[1].SetDraining(true)
+	snapshot := source.State()
+
+	// Merging only touches backends with matching URLs
+	other := newLB("http://backend2:80", "http://backend3:80")
+	if n, err := other.ImportState(snapshot, false, "alice"); err != nil || n != 1 {
+		t.Errorf("Expected 1 merged backend, got %d (%v)", n, err)
+	}
+	if got := other.GetBackends(); len(got) != 2 || !got[0].IsDraining() {
+		t.Errorf("Expected backend2 draining among 2 backends, got %d backends", len(got))
+	}
+
+	// Replacing adopts the snapshot's pool
+	replacement := newLB("http://backend3:80")
+	replacement.SetBackendEnabled("http://backend3:80", false)
+	if n, err := replacement.ImportState(snapshot, true, "alice"); err != nil || n != 2 {
+		t.Fatalf("Expected 2 imported backends, got %d (%v)", n, err)
+	}
+	got := replacement.GetBackends()
+	if len(got) != 2 || got[0].GetWeight() != 7 || !got[1].IsDraining() {
+		t.Errorf("Expected backend1 weight 7 and backend2 draining, got %+v", replacement.State().Backends)
+	}
+	if len(replacement.DisabledBackends()) != 0 {
+		t.Errorf("Expected the snapshot's disable flags, got %v", replacement.DisabledBackends())
+	}
+
+	snapshot.Backends[0].Config = nil
+	if _, err := replacement.ImportState(snapshot, true, "alice"); err == nil {
+		t.Error("Expected an error replacing the pool without backend configs")
+	}
+	snapshot.Version = StateVersion + 1
+	if _, err := replacement.ImportState(snapshot, false, "alice"); err == nil {
+		t.Error("Expected an error for an unknown state version")
+	}
+}
+
+// pickSticky selects and pins the backend of the session of r like
+// ServeHTTP, without reserving or proxying
+func pickSticky(lb *LoadBalancer, w http.ResponseWriter, r *http.Request) *backend.Backend {
+	session := lb.lookupSticky(r)
+	b := lb.selectSticky(session)
+	if b != nil {
+		lb.pinSticky(w, r, session, b)
+	}
+	return b
+}
+
+func TestLoadBalancer_StickySessions(t *testing.T) {
+	// Two instances sharing a store route a session the same way
+	store := sticky.NewMemoryStore()
+	newLB := func() *LoadBalancer {
+		lb, err := NewLoadBalancer(Config{
+			BackendURLs: []string{"http://backend1:80", "http://backend2:80"},
+			Strategy:    strategy.NewRoundRobin(),
+			Sticky:      sticky.NewAffinity(store, "", 0),
+		})
+		if err != nil {
+			t.Fatalf("Failed to create load balancer: %v", err)
+		}
+		return lb
+	}
+	lbA, lbB := newLB(), newLB()
+
+	rec := httptest.NewRecorder()
+	first := pickSticky(lbA, rec, httptest.NewRequest("GET", "/", nil))
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Name != sticky.DefaultCookie {
+		t.Fatalf("Expected a %s cookie, got %v", sticky.DefaultCookie, cookies)
+	}
+
+	pinned := func(lb *LoadBalancer) *backend.Backend {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(cookies[0])
+		rec := httptest.NewRecorder()
+		b := pickSticky(lb, rec, req)
+		if len(rec.Result().Cookies()) != 0 {
+			t.Error("Expected no new cookie for a known session")
+		}
+		return b
+	}
+	for i := 0; i < 4; i++ {
+		for _, lb := range []*LoadBalancer{lbA, lbB} {
+			if b := pinned(lb); b == nil || b.GetURL().String() != first.GetURL().String() {
+				t.Fatalf("Expected session pinned to %s, got %v", first.GetURL(), b)
+			}
+		}
+	}
+
+	// An unavailable backend moves the session
+	lbB.findBackend(first.GetURL().String()).SetAlive(false)
+	moved := pinned(lbB)
+	if moved == nil || moved.GetURL().String() == first.GetURL().String() {
+		t.Fatalf("Expected session to move off %s, got %v", first.GetURL(), moved)
+	}
+	if b := pinned(lbA); b.GetURL().String() != moved.GetURL().String() {
+		t.Errorf("Expected other instance to follow the move to %s, got %s", moved.GetURL(), b.GetURL())
+	}
+}
+
+func TestLoadBalancer_StickyBackupsAndUnknownSessions(t *testing.T) {
+	store := sticky.NewMemoryStore()
+	lb, err := NewLoadBalancer(Config{
+		Backends: []backend.Config{
+			{URL: "http://primary:8081"},
+			{URL: "http://backup1:8082", Backup: true},
+			{URL: "http://backup2:8083", Backup: true},
+		},
+		Strategy: strategy.NewRoundRobin(),
+		Sticky:   sticky.NewAffinity(store, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	primary := lb.GetBackends()[0]
+
+	// A made-up session ID is replaced, not stored
+	forged := &http.Cookie{Name: sticky.DefaultCookie, Value: "forged"}
+	req := httptest.NewRequest("GET", "/", nil)
+	req.AddCookie(forged)
+	rec := httptest.NewRecorder()
+	pickSticky(lb, rec, req)
+	cookies := rec.Result().Cookies()
+	if len(cookies) != 1 || cookies[0].Value == "forged" {
+		t.Fatalf("Expected a new session cookie, got %v", cookies)
+	}
+	if _, found, _ := store.Get(context.Background(), "forged"); found {
+		t.Error("Expected the client's session ID not to be stored")
+	}
+
+	selectWith := func(c *http.Cookie) *backend.Backend {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.AddCookie(c)
+		return pickSticky(lb, httptest.NewRecorder(), req)
+	}
+
+	// A session pinned to a backup stays there while the primary is down
+	primary.SetAlive(false)
+	pinned := selectWith(cookies[0])
+	if pinned == nil || !pinned.IsBackup() {
+		t.Fatalf("Expected a backup while the primary is down, got %v", pinned)
+	}
+	for range 4 {
+		if b := selectWith(cookies[0]); b != pinned {
+			t.Fatalf("Expected the session to stay on %s, got %v", pinned.GetURL(), b)
+		}
+	}
+
+	primary.SetAlive(true)
+	if b := selectWith(cookies[0]); b != primary {
+		t.Errorf("Expected the session back on the primary, got %v", b)
+	}
+}
+
+// turnStrategy hands out the backends in turns, full ones included, as a
+// strategy racing other requests for the last slot would
+type turnStrategy struct{ next atomic.Int64 }
+
+func (s *turnStrategy) SelectBackend(backends []*backend.Backend) *backend.Backend {
+	return backends[int(s.next.Add(1)-1)%len(backends)]
+}
+func (s *turnStrategy) Name() string { return "turns" }
+
+func TestLoadBalancer_StickyPinsReservedBackend(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer upstream.Close()
+	store := sticky.NewMemoryStore()
+	lb, err := NewLoadBalancer(Config{
+		Backends: []backend.Config{
+			{URL: "http://full:8081", MaxConnections: 1},
+			{URL: upstream.URL},
+		},
+		Strategy: &turnStrategy{},
+		Sticky:   sticky.NewAffinity(store, "", 0),
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	full := lb.GetBackends()[0]
+	full.TryAcquire()
+	defer full.Release()
+
+	// The full backend is selected first, but only the one reserved after
+	// it gets the session
+	rec := httptest.NewRecorder()
+	lb.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	cookies := rec.Result().Cookies()
+	if rec.Code != http.StatusOK || len(cookies) != 1 {
+		t.Fatalf("Expected 200 with one session cookie, got %d with %v", rec.Code, cookies)
+	}
+	if pinned, _, _ := store.Get(context.Background(), cookies[0].Value); pinned != upstream.URL {
+		t.Errorf("Expected the session pinned to %s, got %q", upstream.URL, pinned)
+	}
+	if store.Len() != 1 {
+		t.Errorf("Expected one stored session, got %d", store.Len())
+	}
+}
+
+func TestLoadBalancer_Chaos(t *testing.T) {
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer upstream.Close()
+
+	injector, err := chaos.New([]chaos.Rule{
+		{PathPrefix: "/fail", ErrorRate: 1},
+		{PathPrefix: "/drop", DropRate: 1},
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create injector: %v", err)
+	}
+	flags := features.NewRegistry(nil)
+	lb, err := NewLoadBalancer(Config{
+		BackendURLs: []string{upstream.URL},
+		Strategy:    strategy.NewRoundRobin(),
+		Chaos:       injector,
+		Features:    flags,
+	})
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	server := httptest.NewServer(middleware.Recovery(lb))
+	defer server.Close()
+
+	status := func(path string) int {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			return 0
+		}
+		resp.Body.Close()
+		return resp.StatusCode
+	}
+	if got := status("/fail"); got != http.StatusServiceUnavailable {
+		t.Errorf("Expected an injected 503, got %d", got)
+	}
+	if got := status("/drop"); got != 0 {
+		t.Errorf("Expected a dropped connection, got %d", got)
+	}
+	if got := status("/ok"); got != http.StatusOK {
+		t.Errorf("Expected 200 for an unmatched path, got %d", got)
+	}
+
+	// The chaos kill switch bypasses the injector
+	flags.Set(features.Chaos, false, "")
+	if got := status("/fail"); got != http.StatusOK {
+		t.Errorf("Expected 200 with chaos switched off, got %d", got)
+	}
+	// The client retries a GET dropped on a reused connection
+	if stats := lb.GetStats().Chaos; stats == nil || stats.Errored != 1 || stats.Dropped < 1 {
+		t.Errorf("Expected 1 errored and dropped requests, got %+v", stats)
+	}
+}
+
+func TestLoadBalancer_Hooks(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	lb, err := New(WithBackends(server.URL), WithRequireHealthy(0), WithHealthCheck(time.Hour, time.Second))
+	if err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+
+	events := make(chan string, 10)
+	lb.OnStart(func(ctx context.Context) { events <- "start" })
+	lb.OnStop(func() { events <- "stop" })
+	lb.OnBackendAdded(func(b *backend.Backend) { events <- "added " + b.GetURL().String() })
+	lb.OnBackendRemoved(func(b *backend.Backend) { events <- "removed " + b.GetURL().String() })
+	lb.OnBackendStateChange(func(b *backend.Backend, alive bool) {
+		if alive {
+			events <- "up " + b.GetURL().String()
+		}
+	})
+	lb.OnConfigReload(func() { events <- "reload" })
+
+	expect := func(want string) {
+		t.Helper()
+		select {
+		case got := <-events:
+			if got != want {
+				t.Errorf("Expected event %q, got %q", want, got)
+			}
+		case <-time.After(2 * time.Second):
+			t.Fatalf("Expected event %q, got none", want)
+		}
+	}
+
+	lb.Start(context.Background())
+	expect("start")
+	// The first probe brings the backend up
+	expect("up " + server.URL)
+
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:9999"}}); err != nil {
+		t.Fatalf("Failed to set backends: %v", err)
+	}
+	expect("added http://localhost:9999")
+	expect("removed " + server.URL)
+
+	// Changed settings replace the backend without a membership change
+	if err := lb.SetBackends([]backend.Config{{URL: "http://localhost:9999", Weight: 2}}); err != nil {
+		t.Fatalf("Failed to set backends: %v", err)
+	}
+	lb.ConfigReloaded()
+	expect("reload")
+
+	if err := lb.Drain(context.Background()); err != nil {
+		t.Fatalf("Expected drain to succeed, got %v", err)
+	}
+	lb.Drain(context.Background())
+	expect("stop")
+	select {
+	case got := <-events:
+		t.Errorf("Expected no more events, got %q", got)
+	default:
+	}
+}
+
+func TestGroup(t *testing.T) {
+	upstreamA := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("a"))
+	}))
+	defer upstreamA.Close()
+	upstreamB := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("b"))
+	}))
+	defer upstreamB.Close()
+
+	group := NewGroup()
+	for name, url := range map[string]string{"a": upstreamA.URL, "b": upstreamB.URL} {
+		lb, err := NewLoadBalancer(Config{BackendURLs: []string{url}, Strategy: strategy.NewRoundRobin()})
+		if err != nil {
+			t.Fatalf("Failed to create load balancer: %v", err)
+		}
+		if err := group.Add(name, lb); err != nil {
+			t.Fatalf("Failed to add %s: %v", name, err)
+		}
+	}
+	if err := group.Add("a", group.Get("b")); err == nil {
+		t.Error("Expected an error adding a duplicate name")
+	}
+	if len(group.Names()) != 2 || group.Get("missing") != nil {
+		t.Errorf("Expected members a and b, got %v", group.Names())
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	group.Start(ctx)
+
+	// Each member proxies to its own pool
+	for _, name := range []string{"a", "b"} {
+		rec := httptest.NewRecorder()
+		group.Get(name).ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		if rec.Body.String() != name {
+			t.Errorf("Expected %s to answer %q, got %q", name, name, rec.Body.String())
+		}
+	}
+
+	if err := group.Drain(ctx); err != nil {
+		t.Errorf("Expected a clean drain, got %v", err)
+	}
+	if !group.Get("a").IsDraining() || !group.Get("b").IsDraining() {
+		t.Error("Expected every member to be draining")
+	}
+}
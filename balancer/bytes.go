@@ -0,0 +1,33 @@
+package balancer
+
+import (
+	"io"
+	"sync/atomic"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// countingBody counts the request body bytes read by the proxy; the
+// transport may still be sending the body after the answer arrived
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// recordBytes adds the body bytes forwarded to b (body may be nil) and the
+// response bytes returned to the client to b's and the balancer's totals
+func (lb *LoadBalancer) recordBytes(b *backend.Backend, body *countingBody, out int64) {
+	var in int64
+	if body != nil {
+		in = body.n.Load()
+	}
+	b.AddBytes(in, out)
+	lb.metrics.RequestBytes.Add(in)
+	lb.metrics.TotalBytes.Add(out)
+}
@@ -0,0 +1,68 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// drainPoll is how often Drain checks for in-flight requests
+const drainPoll = 50 * time.Millisecond
+
+// Drain prepares for shutdown: new requests are rejected with 503 (and
+// readiness fails), in-flight proxied requests and those waiting in the
+// queue get until ctx is done to finish, then health checks stop. It
+// returns an error if requests were still in flight when ctx ended
+func (lb *LoadBalancer) Drain(ctx context.Context) error {
+	first := !lb.draining.Swap(true)
+	if first {
+		lb.log().InfoContext(ctx, "draining: rejecting new requests", "inFlight", lb.InFlight())
+	}
+	defer func() {
+		lb.stopHealthChecks()
+		if first {
+			lb.fireStop()
+		}
+	}()
+
+	ticker := time.NewTicker(drainPoll)
+	defer ticker.Stop()
+	for {
+		// Queued requests were admitted before draining and may still be
+		// proxied
+		inFlight := lb.InFlight() + int(lb.queue.length.Load())
+		if inFlight == 0 {
+			lb.log().InfoContext(ctx, "drained: no requests in flight")
+			return nil
+		}
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%d request(s) still in flight: %w", inFlight, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// IsDraining reports whether Drain was called
+func (lb *LoadBalancer) IsDraining() bool {
+	return lb.draining.Load()
+}
+
+// InFlight returns the number of requests currently proxied to backends
+func (lb *LoadBalancer) InFlight() int {
+	total := 0
+	for _, b := range lb.GetBackends() {
+		total += b.InFlight()
+	}
+	return total
+}
+
+// stopHealthChecks stops the health check loop started by Start
+func (lb *LoadBalancer) stopHealthChecks() {
+	lb.mu.RLock()
+	stop := lb.stopChecking
+	lb.mu.RUnlock()
+	if stop != nil {
+		stop()
+	}
+}
@@ -0,0 +1,74 @@
+package balancer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// Group holds several independent load balancers running in one process,
+// e.g. a sidecar fronting multiple local services. Each member keeps its own
+// pool, strategy and health checks.
+type Group struct {
+	mu      sync.RWMutex
+	members map[string]*LoadBalancer
+	names   []string
+}
+
+// NewGroup creates an empty group
+func NewGroup() *Group {
+	return &Group{members: make(map[string]*LoadBalancer)}
+}
+
+// Add registers lb under name
+func (g *Group) Add(name string, lb *LoadBalancer) error {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if _, ok := g.members[name]; ok {
+		return fmt.Errorf("load balancer %q already exists", name)
+	}
+	g.members[name] = lb
+	g.names = append(g.names, name)
+	return nil
+}
+
+// Get returns the load balancer registered under name, or nil
+func (g *Group) Get(name string) *LoadBalancer {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return g.members[name]
+}
+
+// Names returns the member names in the order they were added
+func (g *Group) Names() []string {
+	g.mu.RLock()
+	defer g.mu.RUnlock()
+	return append([]string(nil), g.names...)
+}
+
+// Start starts every member
+func (g *Group) Start(ctx context.Context) {
+	for _, name := range g.Names() {
+		g.Get(name).Start(ctx)
+	}
+}
+
+// Drain drains every member concurrently (see LoadBalancer.Drain) and
+// reports the members that still had requests in flight when ctx ended
+func (g *Group) Drain(ctx context.Context) error {
+	names := g.Names()
+	errs := make([]error, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := g.Get(name).Drain(ctx); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", name, err)
+			}
+		}()
+	}
+	wg.Wait()
+	return errors.Join(errs...)
+}
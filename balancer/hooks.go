@@ -0,0 +1,121 @@
+package balancer
+
+import (
+	"context"
+	"sync"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// hooks holds the lifecycle callbacks registered by integrators. Callbacks
+// run synchronously on the goroutine causing the event, in registration
+// order, so they should return quickly.
+type hooks struct {
+	mu      sync.RWMutex
+	start   []func(context.Context)
+	stop    []func()
+	added   []func(*backend.Backend)
+	removed []func(*backend.Backend)
+	state   []func(b *backend.Backend, alive bool)
+	reload  []func()
+}
+
+// OnStart registers fn to run when Start is called, with Start's context
+func (lb *LoadBalancer) OnStart(fn func(ctx context.Context)) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.start = append(lb.hooks.start, fn)
+}
+
+// OnStop registers fn to run once Drain has finished and health checks
+// have stopped
+func (lb *LoadBalancer) OnStop(fn func()) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.stop = append(lb.hooks.stop, fn)
+}
+
+// OnBackendAdded registers fn to run for each backend URL joining the pool
+// through SetBackends
+func (lb *LoadBalancer) OnBackendAdded(fn func(b *backend.Backend)) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.added = append(lb.hooks.added, fn)
+}
+
+// OnBackendRemoved registers fn to run for each backend URL leaving the
+// pool through SetBackends
+func (lb *LoadBalancer) OnBackendRemoved(fn func(b *backend.Backend)) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.removed = append(lb.hooks.removed, fn)
+}
+
+// OnBackendStateChange registers fn to run when a health probe marks a
+// backend up or down
+func (lb *LoadBalancer) OnBackendStateChange(fn func(b *backend.Backend, alive bool)) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.state = append(lb.hooks.state, fn)
+}
+
+// OnConfigReload registers fn to run when ConfigReloaded is called
+func (lb *LoadBalancer) OnConfigReload(fn func()) {
+	lb.hooks.mu.Lock()
+	defer lb.hooks.mu.Unlock()
+	lb.hooks.reload = append(lb.hooks.reload, fn)
+}
+
+// ConfigReloaded tells the OnConfigReload hooks that the embedding
+// application applied a new configuration
+func (lb *LoadBalancer) ConfigReloaded() {
+	lb.hooks.mu.RLock()
+	fns := lb.hooks.reload
+	lb.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func (lb *LoadBalancer) fireStart(ctx context.Context) {
+	lb.hooks.mu.RLock()
+	fns := lb.hooks.start
+	lb.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn(ctx)
+	}
+}
+
+func (lb *LoadBalancer) fireStop() {
+	lb.hooks.mu.RLock()
+	fns := lb.hooks.stop
+	lb.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn()
+	}
+}
+
+func (lb *LoadBalancer) fireMembership(added, removed []*backend.Backend) {
+	lb.hooks.mu.RLock()
+	onAdded, onRemoved := lb.hooks.added, lb.hooks.removed
+	lb.hooks.mu.RUnlock()
+	for _, b := range added {
+		for _, fn := range onAdded {
+			fn(b)
+		}
+	}
+	for _, b := range removed {
+		for _, fn := range onRemoved {
+			fn(b)
+		}
+	}
+}
+
+func (lb *LoadBalancer) fireStateChange(b *backend.Backend, alive bool) {
+	lb.hooks.mu.RLock()
+	fns := lb.hooks.state
+	lb.hooks.mu.RUnlock()
+	for _, fn := range fns {
+		fn(b, alive)
+	}
+}
@@ -0,0 +1,123 @@
+package balancer
+
+import (
+	"fmt"
+	"sync"
+	"time"
+)
+
+// AdaptiveLimitConfig replaces the fixed MaxInFlight cap with one that
+// follows the latency of the backends: it grows by about one request per
+// round of answers within Latency and shrinks by Backoff whenever an answer
+// is slower or fails (AIMD), so the balancer sheds load before the backends
+// collapse under it
+type AdaptiveLimitConfig struct {
+	// Enabled adjusts the in-flight limit at runtime
+	Enabled bool `json:"enabled,omitempty"`
+	// MinLimit and MaxLimit bound the limit (defaults DefaultMinLimit and
+	// MaxInFlight, or DefaultMaxLimit without one); it starts at MaxLimit
+	MinLimit int `json:"minLimit,omitempty"`
+	MaxLimit int `json:"maxLimit,omitempty"`
+	// Latency is the slowest answer still counted as healthy
+	// (0 = DefaultLimitLatency)
+	Latency time.Duration `json:"latency,omitempty"`
+	// Backoff multiplies the limit on a slow or failed answer
+	// (0 = DefaultLimitBackoff)
+	Backoff float64 `json:"backoff,omitempty"`
+}
+
+// Defaults of the adaptive limit
+const (
+	DefaultMinLimit     = 10
+	DefaultMaxLimit     = 1000
+	DefaultLimitLatency = time.Second
+	DefaultLimitBackoff = 0.9
+)
+
+// Validate checks the limit bounds and backoff
+func (c AdaptiveLimitConfig) Validate() error {
+	if c.MinLimit < 0 || c.MaxLimit < 0 {
+		return fmt.Errorf("minLimit and maxLimit must not be negative")
+	}
+	if c.MaxLimit > 0 && c.MinLimit > c.MaxLimit {
+		return fmt.Errorf("minLimit %d exceeds maxLimit %d", c.MinLimit, c.MaxLimit)
+	}
+	if c.Latency < 0 {
+		return fmt.Errorf("latency must not be negative")
+	}
+	if c.Backoff < 0 || c.Backoff >= 1 {
+		return fmt.Errorf("backoff must be between 0 and 1")
+	}
+	return nil
+}
+
+// adaptiveLimit is an AIMD concurrency limit. A decrease only applies to
+// requests started after the previous one, so a burst of slow answers to
+// the same overload shrinks the limit once rather than to its minimum.
+type adaptiveLimit struct {
+	min, max float64
+	latency  time.Duration
+	backoff  float64
+
+	mu        sync.Mutex
+	limit     float64
+	decreased time.Time
+}
+
+// newAdaptiveLimit applies the defaults of c; maxInFlight, when set, is the
+// default upper bound
+func newAdaptiveLimit(c AdaptiveLimitConfig, maxInFlight int) *adaptiveLimit {
+	if c.MaxLimit == 0 {
+		c.MaxLimit = maxInFlight
+	}
+	if c.MaxLimit == 0 {
+		c.MaxLimit = DefaultMaxLimit
+	}
+	if c.MinLimit == 0 {
+		c.MinLimit = min(DefaultMinLimit, c.MaxLimit)
+	}
+	if c.Latency == 0 {
+		c.Latency = DefaultLimitLatency
+	}
+	if c.Backoff == 0 {
+		c.Backoff = DefaultLimitBackoff
+	}
+	return &adaptiveLimit{
+		min:     float64(c.MinLimit),
+		max:     float64(c.MaxLimit),
+		latency: c.Latency,
+		backoff: c.Backoff,
+		limit:   float64(c.MaxLimit),
+	}
+}
+
+// current returns the number of requests that may be in flight
+func (a *adaptiveLimit) current() int64 {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return int64(a.limit)
+}
+
+// observe adjusts the limit to a request started at start that took
+// elapsed; failed marks answers showing overload such as timeouts and 5xx
+func (a *adaptiveLimit) observe(start time.Time, elapsed time.Duration, failed bool) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if failed || elapsed > a.latency {
+		if start.Before(a.decreased) {
+			return
+		}
+		a.limit = max(a.limit*a.backoff, a.min)
+		a.decreased = start.Add(elapsed)
+		return
+	}
+	a.limit = min(a.limit+1/a.limit, a.max)
+}
+
+// inFlightLimit returns the cap on admitted requests (0 = unlimited)
+func (lb *LoadBalancer) inFlightLimit() int64 {
+	if lb.adaptive != nil {
+		return lb.adaptive.current()
+	}
+	return lb.maxInFlight
+}
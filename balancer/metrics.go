@@ -0,0 +1,154 @@
+package balancer
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/metrics"
+)
+
+// promMetrics holds the Prometheus metric families exported by the load balancer
+type promMetrics struct {
+	registry  *metrics.Registry
+	requests  *metrics.CounterVec
+	duration  *metrics.HistogramVec
+	noBackend *metrics.Counter
+	errors    *metrics.CounterVec
+	queue     *metrics.CounterVec
+	limits    *metrics.CounterVec
+	reaped    *metrics.CounterVec
+	ejections *metrics.CounterVec
+}
+
+func newPromMetrics(reg *metrics.Registry, lb *LoadBalancer) *promMetrics {
+	pm := &promMetrics{
+		registry: reg,
+		requests: reg.NewCounterVec(metrics.RequestsTotal,
+			"Total number of proxied requests by backend and status code", "backend", "code"),
+		duration: reg.NewHistogramVec(metrics.RequestDurationSeconds,
+			"Latency of proxied requests by backend", metrics.DefaultLatencyBuckets, "backend"),
+		noBackend: reg.NewCounterVec(metrics.NoBackendTotal,
+			"Requests rejected with 503 because no backend was available").With(),
+		errors: reg.NewCounterVec(metrics.ErrorsTotal,
+			"Failed requests by backend and failure class", "backend", "class"),
+		queue: reg.NewCounterVec(metrics.QueueRejectedTotal,
+			"Requests that waited for a backend in vain, by reason (full, timeout, canceled)", "reason"),
+	}
+	pm.ejections = reg.NewCounterVec(metrics.OutlierEjectionsTotal,
+		"Backends ejected by outlier detection, by reason (errors, latency)", "backend", "reason")
+	pm.limits = reg.NewCounterVec(metrics.LimitRejectedTotal,
+		"Requests or backend reservations refused by a concurrency limit (global, backend)", "limit")
+	reg.NewGaugeFunc(metrics.InFlightLimit, "Requests the balancer admits at once, following latency with an adaptive limit",
+		nil, func(emit func(float64, ...string)) {
+			if limit := lb.inFlightLimit(); limit > 0 {
+				emit(float64(limit))
+			}
+		})
+	reg.NewGaugeFunc(metrics.QueueLength, "Requests waiting for a backend",
+		nil, func(emit func(float64, ...string)) {
+			emit(float64(lb.queue.length.Load()))
+		})
+
+	reg.NewGaugeFunc(metrics.BackendUp, "Backend health status (1=healthy, 0=down)",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				up := 0.0
+				if b.IsAlive() {
+					up = 1
+				}
+				emit(up, b.String())
+			}
+		})
+	reg.NewGaugeFunc(metrics.BackendInFlight, "Requests in flight per backend",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				emit(float64(b.InFlight()), b.String())
+			}
+		})
+	reg.NewGaugeFunc(metrics.BackendConnections, "Open upstream connections per backend",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				emit(float64(b.OpenConnections()), b.String())
+			}
+		})
+	reg.NewGaugeFunc(metrics.BackendIdleConnections, "Pooled upstream connections per backend not carrying a request",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				emit(float64(b.IdleConnections()), b.String())
+			}
+		})
+	reg.NewCounterFunc(metrics.BackendDialsTotal, "Upstream connections dialed per backend",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				emit(float64(b.TransportStats().Dials), b.String())
+			}
+		})
+	reg.NewCounterFunc(metrics.BackendDialErrorsTotal, "Failed upstream dials per backend",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				emit(float64(b.TransportStats().DialErrors), b.String())
+			}
+		})
+	reg.NewCounterFunc(metrics.TLSHandshakesTotal, "TLS handshakes with https backends",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				if b.URL.Scheme == "https" {
+					emit(float64(b.TransportStats().TLSHandshakes), b.String())
+				}
+			}
+		})
+	reg.NewCounterFunc(metrics.TLSHandshakeSeconds, "Total time spent in TLS handshakes with https backends",
+		[]string{"backend"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				if b.URL.Scheme == "https" {
+					emit(b.TransportStats().TLSHandshakeTime.Seconds(), b.String())
+				}
+			}
+		})
+	reg.NewCounterFunc(metrics.BackendBytesTotal, "Body bytes of proxied requests per backend, by direction (in from clients, out to clients)",
+		[]string{"backend", "direction"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				in, out := b.Bytes()
+				emit(float64(in), b.String(), "in")
+				emit(float64(out), b.String(), "out")
+			}
+		})
+	reg.NewCounterFunc(metrics.BackendPhasesTotal, "Proxied requests timed per upstream phase (dns, connect, tls, ttfb)",
+		[]string{"backend", "phase"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				for _, p := range backend.Phases {
+					emit(float64(b.PhaseStats(p).Count), b.String(), p.String())
+				}
+			}
+		})
+	reg.NewCounterFunc(metrics.BackendPhaseSeconds, "Time spent per upstream phase; divide by the phase count for the mean",
+		[]string{"backend", "phase"}, func(emit func(float64, ...string)) {
+			for _, b := range lb.GetBackends() {
+				for _, p := range backend.Phases {
+					emit(b.PhaseStats(p).Total.Seconds(), b.String(), p.String())
+				}
+			}
+		})
+	pm.reaped = reg.NewCounterVec(metrics.IdleReapedTotal,
+		"Times the idle connections of a backend's transport were closed for exceeding maxIdlePerBackend", "backend")
+
+	return pm
+}
+
+// observe records a completed proxied request, attaching traceID as an exemplar when set
+func (pm *promMetrics) observe(backendURL string, code int, elapsed time.Duration, traceID string) {
+	pm.requests.With(backendURL, strconv.Itoa(code)).Inc()
+	pm.duration.With(backendURL).ObserveWithExemplar(elapsed.Seconds(), traceID)
+}
+
+// MetricsRegistry returns the registry holding the load balancer's Prometheus metrics
+func (lb *LoadBalancer) MetricsRegistry() *metrics.Registry {
+	return lb.prom.registry
+}
+
+// HandleMetrics returns an HTTP handler exposing Prometheus metrics
+func (lb *LoadBalancer) HandleMetrics() http.Handler {
+	return lb.prom.registry.Handler()
+}
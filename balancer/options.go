@@ -0,0 +1,227 @@
+package balancer
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/healthcheck"
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/sticky"
+	"github.com/TaiTitans/go-balancer/strategy"
+	"github.com/TaiTitans/go-balancer/tracing"
+)
+
+// Option configures a load balancer created with New
+type Option func(*Config)
+
+// New creates a load balancer from options, for embedding in other
+// services:
+//
+//	lb, err := balancer.New(
+//		balancer.WithBackends("http://10.0.0.1:8080", "http://10.0.0.2:8080"),
+//		balancer.WithStrategy(strategy.NewLeastConnections()),
+//		balancer.WithLogger(logger),
+//	)
+//
+// Without WithStrategy requests are distributed round-robin.
+func New(opts ...Option) (*LoadBalancer, error) {
+	config := Config{Strategy: strategy.NewRoundRobin()}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return NewLoadBalancer(config)
+}
+
+// WithBackends adds backends by URL
+func WithBackends(urls ...string) Option {
+	return func(c *Config) {
+		for _, u := range urls {
+			c.Backends = append(c.Backends, backend.Config{URL: u})
+		}
+	}
+}
+
+// WithBackendConfigs adds backends with per-backend settings
+func WithBackendConfigs(configs ...backend.Config) Option {
+	return func(c *Config) { c.Backends = append(c.Backends, configs...) }
+}
+
+// WithStrategy sets the load balancing strategy
+func WithStrategy(s strategy.Strategy) Option {
+	return func(c *Config) { c.Strategy = s }
+}
+
+// WithHealthCheck sets the health probe interval and timeout
+func WithHealthCheck(interval, timeout time.Duration) Option {
+	return func(c *Config) {
+		c.HealthCheckInterval = interval
+		c.HealthCheckTimeout = timeout
+	}
+}
+
+// WithHealthCheckConfig sets the probe request and the answer that passes
+// it, e.g. a /healthz path with an auth header
+func WithHealthCheckConfig(c healthcheck.Config) Option {
+	return func(cfg *Config) { cfg.HealthCheck = c }
+}
+
+// WithRequireHealthy only routes to backends after their first passing
+// probe, tolerating failed probes of new backends for grace
+func WithRequireHealthy(grace time.Duration) Option {
+	return func(c *Config) {
+		c.RequireHealthy = true
+		c.GracePeriod = grace
+	}
+}
+
+// WithSlowRequestThreshold logs requests slower than d
+func WithSlowRequestThreshold(d time.Duration) Option {
+	return func(c *Config) { c.SlowRequestThreshold = d }
+}
+
+// WithLogger sets the logger of the load balancer and its health checker
+func WithLogger(l *slog.Logger) Option {
+	return func(c *Config) { c.Logger = l }
+}
+
+// WithAuditLog records runtime mutations in l
+func WithAuditLog(l *audit.Log) Option {
+	return func(c *Config) { c.AuditLog = l }
+}
+
+// WithMetricsRegistry registers the Prometheus metrics in r
+func WithMetricsRegistry(r *metrics.Registry) Option {
+	return func(c *Config) { c.MetricsRegistry = r }
+}
+
+// WithTraceExemplars attaches incoming W3C trace IDs to latency histograms
+func WithTraceExemplars() Option {
+	return func(c *Config) { c.TraceExemplars = true }
+}
+
+// WithTracer records a span per request and propagates it to the backend
+func WithTracer(t *tracing.Tracer) Option {
+	return func(c *Config) { c.Tracer = t }
+}
+
+// WithSticky pins client sessions to backends
+func WithSticky(a *sticky.Affinity) Option {
+	return func(c *Config) { c.Sticky = a }
+}
+
+// WithFeatures registers the balancer's kill switches in r
+func WithFeatures(r *features.Registry) Option {
+	return func(c *Config) { c.Features = r }
+}
+
+// WithQueue lets up to maxLength requests wait up to timeout for a backend
+// when none can take them
+func WithQueue(maxLength int, timeout time.Duration) Option {
+	return func(c *Config) { c.Queue = QueueConfig{MaxLength: maxLength, Timeout: timeout} }
+}
+
+// WithMaxInFlight caps the requests handled at once across all backends
+func WithMaxInFlight(n int) Option {
+	return func(c *Config) { c.MaxInFlight = n }
+}
+
+// WithAdaptiveLimit lets the in-flight cap follow backend latency between
+// the bounds of c
+func WithAdaptiveLimit(c AdaptiveLimitConfig) Option {
+	return func(cfg *Config) {
+		c.Enabled = true
+		cfg.AdaptiveLimit = c
+	}
+}
+
+// WithOutlierDetection ejects backends failing or answering slower than the
+// rest of the pool, as set by c
+func WithOutlierDetection(c OutlierConfig) Option {
+	return func(cfg *Config) {
+		c.Enabled = true
+		cfg.OutlierDetection = c
+	}
+}
+
+// WithMaxRequestBytes refuses request bodies larger than n bytes with 413
+func WithMaxRequestBytes(n int64) Option {
+	return func(c *Config) { c.MaxRequestBytes = n }
+}
+
+// WithErrorHandler answers failed proxy attempts with h instead of 502 Bad
+// Gateway
+func WithErrorHandler(h backend.ErrorHandler) Option {
+	return func(c *Config) { c.ErrorPolicy.Handler = h }
+}
+
+// WithErrorPolicy sets how failed proxy attempts are answered and counted
+func WithErrorPolicy(p backend.ErrorPolicy) Option {
+	return func(c *Config) { c.ErrorPolicy = p }
+}
+
+// WithSubset restricts selection to the backends carrying the labels of s
+func WithSubset(s backend.Subset) Option {
+	return func(c *Config) { c.Subset = s }
+}
+
+// WithChaos injects faults into proxied requests
+func WithChaos(in *chaos.Injector) Option {
+	return func(c *Config) { c.Chaos = in }
+}
+
+// BackendOption configures a backend added with AddBackend
+type BackendOption func(*backend.Config)
+
+// WithWeight sets the backend weight
+func WithWeight(weight int) BackendOption {
+	return func(c *backend.Config) { c.Weight = weight }
+}
+
+// WithHealthPath sets the path probed by health checks
+func WithHealthPath(path string) BackendOption {
+	return func(c *backend.Config) { c.HealthPath = path }
+}
+
+// WithMaxConnections caps the concurrent requests proxied to the backend
+func WithMaxConnections(n int) BackendOption {
+	return func(c *backend.Config) { c.MaxConnections = n }
+}
+
+// WithLabels sets the backend labels
+func WithLabels(labels map[string]string) BackendOption {
+	return func(c *backend.Config) { c.Labels = labels }
+}
+
+// AsBackup makes the backend serve only when no primary is available
+func AsBackup() BackendOption {
+	return func(c *backend.Config) { c.Backup = true }
+}
+
+// WithPriority puts the backend in a failover tier; lower tiers serve first
+// with the failover strategy
+func WithPriority(priority int) BackendOption {
+	return func(c *backend.Config) { c.Priority = priority }
+}
+
+// WithConfig starts from a full backend configuration; its URL is replaced
+// by the one passed to AddBackend
+func WithConfig(cfg backend.Config) BackendOption {
+	return func(c *backend.Config) {
+		u := c.URL
+		*c = cfg
+		c.URL = u
+	}
+}
+
+// log returns the configured logger, or the process-wide one
+func (lb *LoadBalancer) log() *slog.Logger {
+	if lb.logger != nil {
+		return lb.logger
+	}
+	return logging.Logger()
+}
@@ -0,0 +1,276 @@
+package balancer
+
+import (
+	"cmp"
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// OutlierConfig ejects backends whose error rate or latency stands out from
+// the rest of the pool, like Envoy's outlier detection: every Interval the
+// requests of the past interval are compared, and outliers leave rotation
+// for BaseEjectionTime times the number of their recent ejections
+type OutlierConfig struct {
+	// Enabled turns outlier detection on
+	Enabled bool `json:"enabled,omitempty"`
+	// Interval is the analysis period (0 = DefaultOutlierInterval)
+	Interval time.Duration `json:"interval,omitempty"`
+	// MinRequests is the fewest requests in an interval for a backend to be
+	// judged (0 = DefaultOutlierMinRequests)
+	MinRequests int `json:"minRequests,omitempty"`
+	// ErrorRateDeviation ejects a backend whose share of failed requests
+	// exceeds the average of the others by this much (0 = 0.3; negative
+	// disables the check)
+	ErrorRateDeviation float64 `json:"errorRateDeviation,omitempty"`
+	// LatencyPercentile (0-1, 0 = 0.99) is compared to LatencyFactor times
+	// the average of the others (0 = 3; negative disables the check)
+	LatencyPercentile float64 `json:"latencyPercentile,omitempty"`
+	LatencyFactor     float64 `json:"latencyFactor,omitempty"`
+	// BaseEjectionTime is the first ejection's length (0 = 30s); repeated
+	// ejections last longer, up to MaxEjectionTime (0 = 5m)
+	BaseEjectionTime time.Duration `json:"baseEjectionTime,omitempty"`
+	MaxEjectionTime  time.Duration `json:"maxEjectionTime,omitempty"`
+	// MaxEjectionPercent caps the share of the pool ejected at once (0 =
+	// 10); at least one backend may always be ejected
+	MaxEjectionPercent int `json:"maxEjectionPercent,omitempty"`
+}
+
+// Defaults of outlier detection
+const (
+	DefaultOutlierInterval    = 10 * time.Second
+	DefaultOutlierMinRequests = 20
+)
+
+// Validate checks the outlier detection settings
+func (c OutlierConfig) Validate() error {
+	switch {
+	case c.Interval < 0 || c.BaseEjectionTime < 0 || c.MaxEjectionTime < 0:
+		return fmt.Errorf("interval and ejection times must not be negative")
+	case c.MinRequests < 0:
+		return fmt.Errorf("minRequests must not be negative")
+	case c.LatencyPercentile < 0 || c.LatencyPercentile > 1:
+		return fmt.Errorf("latencyPercentile %v is out of range (0-1)", c.LatencyPercentile)
+	case c.MaxEjectionPercent < 0 || c.MaxEjectionPercent > 100:
+		return fmt.Errorf("maxEjectionPercent %d is out of range (0-100)", c.MaxEjectionPercent)
+	}
+	return nil
+}
+
+// withDefaults fills in the unset settings
+func (c OutlierConfig) withDefaults() OutlierConfig {
+	if c.Interval == 0 {
+		c.Interval = DefaultOutlierInterval
+	}
+	if c.MinRequests == 0 {
+		c.MinRequests = DefaultOutlierMinRequests
+	}
+	if c.ErrorRateDeviation == 0 {
+		c.ErrorRateDeviation = 0.3
+	}
+	if c.LatencyPercentile == 0 {
+		c.LatencyPercentile = 0.99
+	}
+	if c.LatencyFactor == 0 {
+		c.LatencyFactor = 3
+	}
+	if c.BaseEjectionTime == 0 {
+		c.BaseEjectionTime = 30 * time.Second
+	}
+	if c.MaxEjectionTime == 0 {
+		c.MaxEjectionTime = 5 * time.Minute
+	}
+	if c.MaxEjectionPercent == 0 {
+		c.MaxEjectionPercent = 10
+	}
+	return c
+}
+
+// outlierSamples bounds the latencies kept per backend and interval; more
+// are sampled uniformly
+const outlierSamples = 1024
+
+// Ejection reasons
+const (
+	ejectErrors  = "errors"
+	ejectLatency = "latency"
+)
+
+// outlierDetector collects the outcome of proxied requests per backend and
+// ejects the outliers every interval
+type outlierDetector struct {
+	config  OutlierConfig
+	windows sync.Map // *backend.Backend -> *outlierWindow
+
+	ejections atomic.Int64
+}
+
+// outlierWindow is a backend's record over the current interval
+type outlierWindow struct {
+	mu        sync.Mutex
+	requests  int
+	failures  int
+	latencies []time.Duration
+	// ejections counts recent ejections, lengthening the next one; until
+	// is when the current one ends
+	ejections int
+	until     time.Time
+}
+
+// observe records a proxied request
+func (d *outlierDetector) observe(b *backend.Backend, elapsed time.Duration, failed bool) {
+	w, ok := d.windows.Load(b)
+	if !ok {
+		w, _ = d.windows.LoadOrStore(b, &outlierWindow{})
+	}
+	win := w.(*outlierWindow)
+	win.mu.Lock()
+	defer win.mu.Unlock()
+	win.requests++
+	if failed {
+		win.failures++
+	}
+	if len(win.latencies) < outlierSamples {
+		win.latencies = append(win.latencies, elapsed)
+	} else if i := rand.IntN(win.requests); i < outlierSamples {
+		win.latencies[i] = elapsed
+	}
+}
+
+// outlierCandidate is a backend judged in an interval
+type outlierCandidate struct {
+	backend   *backend.Backend
+	window    *outlierWindow
+	errorRate float64
+	latency   time.Duration
+	reason    string
+	deviation float64
+}
+
+// detectOutliers analyzes the backends every interval until ctx is done
+func (lb *LoadBalancer) detectOutliers(ctx context.Context) {
+	ticker := time.NewTicker(lb.outliers.config.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case now := <-ticker.C:
+			lb.analyzeOutliers(now)
+		}
+	}
+}
+
+// analyzeOutliers returns backends whose ejection ended, ejects the
+// outliers of the past interval and starts a new one
+func (lb *LoadBalancer) analyzeOutliers(now time.Time) {
+	d := lb.outliers
+	backends := lb.GetBackends()
+	current := make(map[*backend.Backend]bool, len(backends))
+	for _, b := range backends {
+		current[b] = true
+	}
+
+	ejected := 0
+	var candidates []*outlierCandidate
+	d.windows.Range(func(k, v any) bool {
+		b, w := k.(*backend.Backend), v.(*outlierWindow)
+		if !current[b] {
+			d.windows.Delete(b)
+			return true
+		}
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		if b.IsEjected() && !now.Before(w.until) {
+			b.SetEjected(false)
+			lb.log().Info("outlier ejection ended", "backend", b.String())
+		}
+		if b.IsEjected() {
+			ejected++
+		} else if w.requests >= d.config.MinRequests {
+			candidates = append(candidates, &outlierCandidate{
+				backend:   b,
+				window:    w,
+				errorRate: float64(w.failures) / float64(w.requests),
+				latency:   percentile(w.latencies, d.config.LatencyPercentile),
+			})
+		}
+		w.requests, w.failures, w.latencies = 0, 0, w.latencies[:0]
+		return true
+	})
+	if len(candidates) < 2 {
+		return
+	}
+
+	var errorSum float64
+	var latencySum time.Duration
+	for _, c := range candidates {
+		errorSum += c.errorRate
+		latencySum += c.latency
+	}
+	others := float64(len(candidates) - 1)
+	var outliers []*outlierCandidate
+	for _, c := range candidates {
+		errorAvg := (errorSum - c.errorRate) / others
+		latencyAvg := float64(latencySum-c.latency) / others
+		switch {
+		case d.config.ErrorRateDeviation > 0 && c.errorRate-errorAvg > d.config.ErrorRateDeviation:
+			c.reason, c.deviation = ejectErrors, c.errorRate-errorAvg
+		case d.config.LatencyFactor > 0 && latencyAvg > 0 && float64(c.latency) > d.config.LatencyFactor*latencyAvg:
+			c.reason, c.deviation = ejectLatency, float64(c.latency)/latencyAvg
+		default:
+			// A backend judged sound forgets an earlier ejection
+			c.window.ejections = max(c.window.ejections-1, 0)
+			continue
+		}
+		outliers = append(outliers, c)
+	}
+
+	// Eject the worst first, within MaxEjectionPercent
+	slices.SortFunc(outliers, func(a, b *outlierCandidate) int {
+		if a.reason != b.reason {
+			if a.reason == ejectErrors {
+				return -1
+			}
+			return 1
+		}
+		return cmp.Compare(b.deviation, a.deviation)
+	})
+	limit := max(len(backends)*d.config.MaxEjectionPercent/100, 1)
+	for _, c := range outliers {
+		if ejected >= limit {
+			lb.log().Warn("outlier not ejected: maxEjectionPercent reached",
+				"backend", c.backend.String(), "reason", c.reason)
+			continue
+		}
+		ejected++
+		c.window.ejections++
+		duration := min(d.config.BaseEjectionTime*time.Duration(c.window.ejections), d.config.MaxEjectionTime)
+		c.window.until = now.Add(duration)
+		c.backend.SetEjected(true)
+		d.ejections.Add(1)
+		lb.prom.ejections.With(c.backend.String(), c.reason).Inc()
+		lb.log().Warn("ejected outlier backend",
+			"backend", c.backend.String(),
+			"reason", c.reason,
+			"errorRate", c.errorRate,
+			"latency", c.latency,
+			"duration", duration)
+	}
+}
+
+// percentile returns the p-th percentile (0-1) of samples, reordering them
+func percentile(samples []time.Duration, p float64) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	slices.Sort(samples)
+	i := int(p * float64(len(samples)-1))
+	return samples[i]
+}
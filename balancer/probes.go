@@ -0,0 +1,73 @@
+package balancer
+
+import (
+	"encoding/json"
+	"net/http"
+)
+
+// Readiness describes whether the load balancer should receive traffic
+type Readiness struct {
+	Ready           bool   `json:"ready"`
+	Reason          string `json:"reason,omitempty"`
+	Started         bool   `json:"started"`
+	Draining        bool   `json:"draining"`
+	HealthyBackends int    `json:"healthyBackends"`
+	TotalBackends   int    `json:"totalBackends"`
+}
+
+// Readiness reports whether the load balancer is started, not draining and
+// has at least one healthy, enabled backend (primary or backup) to send
+// traffic to
+func (lb *LoadBalancer) Readiness() Readiness {
+	backends := lb.GetBackends()
+	r := Readiness{
+		Started:       lb.started.Load(),
+		Draining:      lb.draining.Load(),
+		TotalBackends: len(backends),
+	}
+	for _, b := range backends {
+		if b.IsAlive() && !b.IsDraining() && !b.IsDisabled() && !b.IsEjected() {
+			r.HealthyBackends++
+		}
+	}
+
+	switch {
+	case !r.Started:
+		r.Reason = "not started"
+	case r.Draining:
+		r.Reason = "draining"
+	case r.HealthyBackends == 0:
+		r.Reason = "no healthy backends"
+	default:
+		r.Ready = true
+	}
+	return r
+}
+
+// HandleLivez returns a liveness handler: it answers 200 while the process
+// can serve HTTP, whatever the state of the backends
+func (lb *LoadBalancer) HandleLivez() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		writeProbe(w, http.StatusOK, map[string]string{"status": "alive"})
+	}
+}
+
+// HandleReadyz returns a readiness handler answering 200 when Readiness is
+// ready and 503 otherwise
+func (lb *LoadBalancer) HandleReadyz() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		readiness := lb.Readiness()
+		status := http.StatusOK
+		if !readiness.Ready {
+			status = http.StatusServiceUnavailable
+		}
+		writeProbe(w, status, readiness)
+	}
+}
+
+func writeProbe(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
@@ -0,0 +1,149 @@
+package balancer
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// QueueConfig lets requests wait for a backend when none can take them, e.g.
+// while every backend is at maxConnections or a flapping pool recovers,
+// instead of answering bursts of 503s
+type QueueConfig struct {
+	// MaxLength bounds the waiting requests; more are refused with 429
+	// (0 disables queueing)
+	MaxLength int `json:"maxLength,omitempty"`
+	// Timeout is the longest a request waits before a 503 (0 = DefaultQueueTimeout)
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// DefaultQueueTimeout is the wait of queued requests when no timeout is set
+const DefaultQueueTimeout = time.Second
+
+// Enabled reports whether requests may wait for a backend
+func (c QueueConfig) Enabled() bool {
+	return c.MaxLength > 0
+}
+
+// Validate checks the queue limits
+func (c QueueConfig) Validate() error {
+	if c.MaxLength < 0 {
+		return fmt.Errorf("maxLength must not be negative")
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// waitQueue holds requests waiting for a backend. Waiters are woken together
+// whenever a request finishes or the pool changes and race to select again.
+type waitQueue struct {
+	config atomic.Pointer[QueueConfig]
+	length atomic.Int64
+
+	mu   sync.Mutex
+	wake chan struct{}
+
+	queued   atomic.Int64
+	rejected atomic.Int64
+	timedOut atomic.Int64
+}
+
+// Queue outcomes besides getting a backend
+const (
+	queueFull     = "full"
+	queueTimeout  = "timeout"
+	queueCanceled = "canceled"
+)
+
+// wait selects a backend with pick, waiting for one to free up if the queue
+// allows it. Without a backend it returns the status to answer and the
+// reason the wait failed (empty when queueing is disabled).
+func (q *waitQueue) wait(ctx context.Context, pick func() *backend.Backend) (*backend.Backend, int, string) {
+	config := q.config.Load()
+	if config == nil || !config.Enabled() {
+		return nil, http.StatusServiceUnavailable, ""
+	}
+	if q.length.Add(1) > int64(config.MaxLength) {
+		q.length.Add(-1)
+		q.rejected.Add(1)
+		return nil, http.StatusTooManyRequests, queueFull
+	}
+	defer q.length.Add(-1)
+	q.queued.Add(1)
+
+	timeout := config.Timeout
+	if timeout == 0 {
+		timeout = DefaultQueueTimeout
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	for {
+		// Take the wake channel before selecting so a backend freed in
+		// between isn't missed
+		wake := q.wakeChan()
+		if b := pick(); b != nil {
+			return b, 0, ""
+		}
+		select {
+		case <-wake:
+		case <-timer.C:
+			q.timedOut.Add(1)
+			return nil, http.StatusServiceUnavailable, queueTimeout
+		case <-ctx.Done():
+			return nil, http.StatusServiceUnavailable, queueCanceled
+		}
+	}
+}
+
+func (q *waitQueue) wakeChan() <-chan struct{} {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.wake == nil {
+		q.wake = make(chan struct{})
+	}
+	return q.wake
+}
+
+// signal wakes the waiting requests; it is cheap when none wait
+func (q *waitQueue) signal() {
+	if q.length.Load() == 0 {
+		return
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if q.wake != nil {
+		close(q.wake)
+		q.wake = nil
+	}
+}
+
+// stats returns the queue counters for GetStats
+func (q *waitQueue) stats() QueueStats {
+	config := q.config.Load()
+	if config == nil {
+		config = &QueueConfig{}
+	}
+	return QueueStats{
+		Length:    q.length.Load(),
+		MaxLength: config.MaxLength,
+		Queued:    q.queued.Load(),
+		Rejected:  q.rejected.Load(),
+		TimedOut:  q.timedOut.Load(),
+	}
+}
+
+// SetQueue changes how requests wait when no backend can take them
+func (lb *LoadBalancer) SetQueue(c QueueConfig) {
+	prev := lb.queue.config.Swap(&c)
+	if prev == nil || *prev == c {
+		return
+	}
+	lb.log().Info("wait queue changed", "maxLength", c.MaxLength, "timeout", c.Timeout)
+}
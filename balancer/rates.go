@@ -0,0 +1,123 @@
+package balancer
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// rateBuckets is the number of one-second buckets kept (15 minutes)
+const rateBuckets = 15 * 60
+
+// Rate windows reported in stats
+var rateWindows = []struct {
+	name   string
+	window time.Duration
+}{
+	{"1m", time.Minute},
+	{"5m", 5 * time.Minute},
+	{"15m", 15 * time.Minute},
+}
+
+// rateBucket counts requests and failures within a single second. Each word
+// packs the second (above countBits) with its count, so moving a bucket to
+// a new second and counting in it are one compare-and-swap: requests don't
+// serialize on a lock.
+type rateBucket struct {
+	requests atomic.Uint64
+	failures atomic.Uint64
+}
+
+// countBits is the width of the count in a bucket word; counts saturate at
+// countMask (16M requests in one second)
+const (
+	countBits = 24
+	countMask = 1<<countBits - 1
+)
+
+// rateCounter tracks per-second request and failure counts over a rolling window
+type rateCounter struct {
+	buckets [rateBuckets]rateBucket
+	since   atomic.Int64 // UnixNano the window starts at
+}
+
+func newRateCounter(now time.Time) *rateCounter {
+	rc := &rateCounter{}
+	rc.since.Store(now.UnixNano())
+	return rc
+}
+
+// add records one request at time now
+func (rc *rateCounter) add(now time.Time, failed bool) {
+	sec := uint64(now.Unix())
+	b := &rc.buckets[sec%rateBuckets]
+	bump(&b.requests, sec)
+	if failed {
+		bump(&b.failures, sec)
+	}
+}
+
+// bump counts one in word for sec, restarting the count when the word holds
+// an older second; a word already moved to a newer second is left alone
+func bump(word *atomic.Uint64, sec uint64) {
+	for {
+		old := word.Load()
+		next := sec<<countBits | 1
+		switch current := old >> countBits; {
+		case current == sec:
+			if old&countMask == countMask {
+				return
+			}
+			next = old + 1
+		case current > sec:
+			return
+		}
+		if word.CompareAndSwap(old, next) {
+			return
+		}
+	}
+}
+
+// countIn returns the count of word if its second is within [oldest, newest]
+func countIn(word *atomic.Uint64, oldest, newest uint64) int64 {
+	v := word.Load()
+	if sec := v >> countBits; sec < oldest || sec > newest {
+		return 0
+	}
+	return int64(v & countMask)
+}
+
+// rates returns requests per second and the failure ratio over the window ending at now
+func (rc *rateCounter) rates(now time.Time, window time.Duration) (rps, errorRate float64) {
+	// Don't dilute the rate with time before the counter started
+	if elapsed := now.Sub(time.Unix(0, rc.since.Load())); elapsed < window {
+		window = elapsed
+	}
+	if window < time.Second {
+		window = time.Second
+	}
+
+	nowSec := uint64(now.Unix())
+	oldest := nowSec - uint64(window/time.Second) + 1
+	var requests, failures int64
+	for i := range rc.buckets {
+		b := &rc.buckets[i]
+		requests += countIn(&b.requests, oldest, nowSec)
+		failures += countIn(&b.failures, oldest, nowSec)
+	}
+
+	rps = float64(requests) / window.Seconds()
+	if requests > 0 {
+		errorRate = float64(failures) / float64(requests)
+	}
+	return rps, errorRate
+}
+
+// reset clears all buckets and restarts the window at now; requests racing
+// with it may be lost or kept
+func (rc *rateCounter) reset(now time.Time) {
+	for i := range rc.buckets {
+		rc.buckets[i].requests.Store(0)
+		rc.buckets[i].failures.Store(0)
+	}
+	rc.since.Store(now.UnixNano())
+}
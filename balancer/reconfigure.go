@@ -0,0 +1,337 @@
+package balancer
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/healthcheck"
+)
+
+// Errors returned by AddBackend and RemoveBackend
+var (
+	ErrBackendExists   = errors.New("backend already in the pool")
+	ErrBackendNotFound = errors.New("backend not found")
+	ErrLastBackend     = errors.New("cannot remove the last backend")
+)
+
+// SetBackends replaces the backend pool; backends whose URL and settings are
+// unchanged keep their state (health, connections, counters)
+func (lb *LoadBalancer) SetBackends(configs []backend.Config) error {
+	if len(configs) == 0 {
+		return fmt.Errorf("no backend URLs provided")
+	}
+
+	lb.mu.Lock()
+	existing := make(map[string]*backend.Backend, len(lb.GetBackends()))
+	for _, b := range lb.GetBackends() {
+		existing[b.GetURL().String()] = b
+	}
+
+	next := make([]*backend.Backend, 0, len(configs))
+	var added, reweighted []string
+	// joined are the URLs new to the pool, as opposed to replaced ones
+	var joined []*backend.Backend
+	replaced := make(map[string]bool)
+	for _, bc := range configs {
+		u, err := url.Parse(bc.URL)
+		if err != nil {
+			lb.mu.Unlock()
+			return fmt.Errorf("failed to create backend for %s: %w", bc.URL, err)
+		}
+		if b, ok := existing[u.String()]; ok && reflect.DeepEqual(b.Config(), bc) {
+			next = append(next, b)
+			delete(existing, u.String())
+			continue
+		}
+		// A new weight alone is applied in place, so the backend keeps its
+		// in-flight requests and connection limit accounting
+		if b, ok := existing[u.String()]; ok && onlyWeightDiffers(b.Config(), bc) && b.SetWeight(bc.Weight) == nil {
+			next = append(next, b)
+			delete(existing, u.String())
+			reweighted = append(reweighted, bc.URL)
+			continue
+		}
+		b, err := backend.NewBackendWithConfig(bc)
+		if err != nil {
+			lb.mu.Unlock()
+			return fmt.Errorf("failed to create backend for %s: %w", bc.URL, err)
+		}
+		if old, ok := existing[u.String()]; ok {
+			// Settings changed: keep the known health state
+			b.SetAlive(old.IsAlive())
+			replaced[u.String()] = true
+		} else {
+			if lb.requireHealthy {
+				b.SetAlive(false)
+			}
+			joined = append(joined, b)
+		}
+		next = append(next, b)
+		added = append(added, bc.URL)
+	}
+	for _, b := range next {
+		b.SetDisabled(lb.disabled[b.GetURL().String()])
+	}
+	lb.setBackends(next)
+	lb.mu.Unlock()
+
+	lb.healthChecker.SetBackends(next)
+	for _, b := range existing {
+		b.Retire()
+	}
+
+	var removed []string
+	left := make([]*backend.Backend, 0, len(existing))
+	for u, b := range existing {
+		removed = append(removed, u)
+		if !replaced[u] {
+			left = append(left, b)
+		}
+	}
+	if len(added) == 0 && len(removed) == 0 && len(reweighted) == 0 {
+		return nil
+	}
+	detail := fmt.Sprintf("added=[%s] removed=[%s]", strings.Join(added, ","), strings.Join(removed, ","))
+	if len(reweighted) > 0 {
+		detail += fmt.Sprintf(" reweighted=[%s]", strings.Join(reweighted, ","))
+	}
+	lb.log().Info("backends updated", "added", added, "removed", removed, "reweighted", reweighted)
+	lb.audit.Record(audit.SystemActor, "backends.update", "", detail)
+	lb.fireMembership(joined, left)
+	return nil
+}
+
+// AddBackend adds a backend to the pool and to the health checks without
+// touching the other backends. With a health requirement (see
+// SetHealthPolicy) it only gets traffic after passing a probe.
+func (lb *LoadBalancer) AddBackend(backendURL string, opts ...BackendOption) error {
+	cfg := backend.Config{URL: backendURL}
+	for _, opt := range opts {
+		opt(&cfg)
+	}
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return fmt.Errorf("invalid backend URL %s: %w", backendURL, err)
+	}
+	key := u.String()
+	b, err := backend.NewBackendWithConfig(cfg)
+	if err != nil {
+		return fmt.Errorf("failed to create backend for %s: %w", backendURL, err)
+	}
+
+	lb.mu.Lock()
+	current := lb.GetBackends()
+	for _, existing := range current {
+		if existing.GetURL().String() == key {
+			lb.mu.Unlock()
+			return fmt.Errorf("backend %s: %w", backendURL, ErrBackendExists)
+		}
+	}
+	if lb.requireHealthy {
+		b.SetAlive(false)
+	}
+	b.SetDisabled(lb.disabled[key])
+	next := append(current[:len(current):len(current)], b)
+	lb.setBackends(next)
+	lb.mu.Unlock()
+
+	lb.healthChecker.SetBackends(next)
+	lb.log().Info("backend added", "backend", key)
+	lb.audit.Record(audit.SystemActor, "backend.add", "", key)
+	lb.fireMembership([]*backend.Backend{b}, nil)
+	return nil
+}
+
+// RemoveBackend takes a backend out of the pool and the health checks.
+// Requests already proxied to it complete; the last backend can't be
+// removed.
+func (lb *LoadBalancer) RemoveBackend(backendURL string) error {
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return fmt.Errorf("invalid backend URL %s: %w", backendURL, err)
+	}
+	key := u.String()
+
+	lb.mu.Lock()
+	current := lb.GetBackends()
+	next := make([]*backend.Backend, 0, len(current))
+	var target *backend.Backend
+	for _, b := range current {
+		if b.GetURL().String() == key {
+			target = b
+			continue
+		}
+		next = append(next, b)
+	}
+	switch {
+	case target == nil:
+		lb.mu.Unlock()
+		return fmt.Errorf("backend %s: %w", backendURL, ErrBackendNotFound)
+	case len(next) == 0:
+		lb.mu.Unlock()
+		return fmt.Errorf("backend %s: %w", backendURL, ErrLastBackend)
+	}
+	lb.setBackends(next)
+	lb.mu.Unlock()
+
+	lb.healthChecker.SetBackends(next)
+	target.Retire()
+	lb.log().Info("backend removed", "backend", key)
+	lb.audit.Record(audit.SystemActor, "backend.remove", "", key)
+	lb.fireMembership(nil, []*backend.Backend{target})
+	return nil
+}
+
+// onlyWeightDiffers reports whether next only sets another weight than
+// current
+func onlyWeightDiffers(current, next backend.Config) bool {
+	if next.Weight <= 0 {
+		return false
+	}
+	current.Weight = next.Weight
+	return reflect.DeepEqual(current, next)
+}
+
+// SetBackendEnabled takes the backend with the given URL out of rotation
+// (or puts it back) independently of its health; the choice is kept across
+// SetBackends calls
+func (lb *LoadBalancer) SetBackendEnabled(backendURL string, enabled bool) error {
+	u, err := url.Parse(backendURL)
+	if err != nil {
+		return fmt.Errorf("invalid backend URL %s: %w", backendURL, err)
+	}
+	key := u.String()
+
+	lb.mu.Lock()
+	var target *backend.Backend
+	for _, b := range lb.GetBackends() {
+		if b.GetURL().String() == key {
+			target = b
+		}
+	}
+	if target == nil {
+		lb.mu.Unlock()
+		return fmt.Errorf("backend %s not found", backendURL)
+	}
+	changed := target.IsDisabled() == enabled
+	if enabled {
+		delete(lb.disabled, key)
+	} else {
+		if lb.disabled == nil {
+			lb.disabled = make(map[string]bool)
+		}
+		lb.disabled[key] = true
+	}
+	target.SetDisabled(!enabled)
+	lb.mu.Unlock()
+
+	if !changed {
+		return nil
+	}
+	state := "disabled"
+	action := "backend.disable"
+	if enabled {
+		state = "enabled"
+		action = "backend.enable"
+	}
+	lb.log().Info("backend "+state, "backend", key)
+	lb.audit.Record(audit.SystemActor, action, "", key)
+	return nil
+}
+
+// DisabledBackends returns the URLs taken out of rotation with
+// SetBackendEnabled, including ones not currently in the pool
+func (lb *LoadBalancer) DisabledBackends() []string {
+	lb.mu.RLock()
+	defer lb.mu.RUnlock()
+	urls := make([]string, 0, len(lb.disabled))
+	for u := range lb.disabled {
+		urls = append(urls, u)
+	}
+	sort.Strings(urls)
+	return urls
+}
+
+// SetHealthPolicy controls how backends added at runtime combine with health
+// checks: with requireHealthy they only get traffic after passing a probe,
+// and their failed probes are tolerated for grace after they are added
+func (lb *LoadBalancer) SetHealthPolicy(requireHealthy bool, grace time.Duration) {
+	lb.mu.Lock()
+	lb.requireHealthy = requireHealthy
+	lb.mu.Unlock()
+	lb.healthChecker.SetGracePeriod(grace)
+}
+
+// SetMaintenance toggles maintenance mode, in which every proxied request is
+// answered with 503 Service Unavailable
+func (lb *LoadBalancer) SetMaintenance(enabled bool) {
+	if lb.maintenance.Swap(enabled) == enabled {
+		return
+	}
+	state := "off"
+	if enabled {
+		state = "on"
+	}
+	lb.log().Info("maintenance mode " + state)
+	lb.audit.Record(audit.SystemActor, "maintenance.change", "", state)
+}
+
+// InMaintenance reports whether maintenance mode is on
+func (lb *LoadBalancer) InMaintenance() bool {
+	return lb.maintenance.Load()
+}
+
+// SetHealthCheck changes the health probe interval and timeout at runtime
+func (lb *LoadBalancer) SetHealthCheck(interval, timeout time.Duration) {
+	prevInterval, prevTimeout := lb.healthChecker.Timings()
+	if prevInterval == interval && prevTimeout == timeout {
+		return
+	}
+	lb.healthChecker.SetTimings(interval, timeout)
+	lb.log().Info("health check changed", "interval", interval, "timeout", timeout)
+	lb.audit.Record(audit.SystemActor, "healthcheck.change", "",
+		fmt.Sprintf("interval %v -> %v, timeout %v -> %v", prevInterval, interval, prevTimeout, timeout))
+}
+
+// SetHealthCheckConfig changes the probe request and the answer that passes
+// it at runtime
+func (lb *LoadBalancer) SetHealthCheckConfig(c healthcheck.Config) {
+	if reflect.DeepEqual(lb.healthChecker.Config(), c) {
+		return
+	}
+	lb.healthChecker.SetConfig(c)
+	lb.log().Info("health check request changed", "path", c.Path, "method", c.Method, "expectedStatus", c.ExpectedStatus)
+	lb.audit.Record(audit.SystemActor, "healthcheck.change", "", fmt.Sprintf("path=%q method=%q", c.Path, c.Method))
+}
+
+// SetSubset changes the backends requests are restricted to or prefer; a
+// zero subset selects from the whole pool
+func (lb *LoadBalancer) SetSubset(s backend.Subset) {
+	if lb.subset.Load().Equal(s) {
+		return
+	}
+	lb.subset.Store(&s)
+	lb.refreshAvailable()
+}
+
+// Subset returns the subset requests are restricted to
+func (lb *LoadBalancer) Subset() backend.Subset {
+	return *lb.subset.Load()
+}
+
+// SetErrorPolicy changes how every backend answers failed proxy attempts
+func (lb *LoadBalancer) SetErrorPolicy(p backend.ErrorPolicy) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+	lb.errorPolicy.Store(&p)
+	for _, b := range lb.GetBackends() {
+		b.SetErrorPolicy(p)
+	}
+}
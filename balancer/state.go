@@ -0,0 +1,199 @@
+package balancer
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/sticky"
+)
+
+// StateVersion is the format version of saved state files
+const StateVersion = 1
+
+// ErrStateSkipped marks saved values that were skipped while the rest of the
+// state was applied
+var ErrStateSkipped = errors.New("saved state skipped")
+
+// State is the runtime knowledge worth keeping across restarts or moving to
+// another instance: the backends with their learned health and operator
+// changes (weights, drain and disable flags), and the sticky sessions of an
+// in-memory store
+type State struct {
+	Version  int            `json:"version"`
+	Saved    time.Time      `json:"saved"`
+	Backends []BackendState `json:"backends"`
+	// Disabled lists every disabled URL, including ones not in the pool
+	Disabled []string `json:"disabled,omitempty"`
+	// Sessions holds the sessions of the memory sticky store; other stores
+	// keep theirs outside the process
+	Sessions []sticky.Session `json:"sessions,omitempty"`
+}
+
+// BackendState is the saved state of one backend
+type BackendState struct {
+	URL      string `json:"url"`
+	Alive    bool   `json:"alive"`
+	Weight   int    `json:"weight,omitempty"` // runtime override, 0 when unset
+	Draining bool   `json:"draining,omitempty"`
+	// Config holds the backend settings, used when a snapshot replaces the
+	// pool (see ImportState)
+	Config *backend.Config `json:"config,omitempty"`
+}
+
+// State captures the current runtime state
+func (lb *LoadBalancer) State() State {
+	backends := lb.GetBackends()
+	st := State{
+		Version:  StateVersion,
+		Saved:    time.Now(),
+		Backends: make([]BackendState, 0, len(backends)),
+		Disabled: lb.DisabledBackends(),
+	}
+	if store := lb.memorySessions(); store != nil {
+		st.Sessions = store.Sessions()
+	}
+	for _, b := range backends {
+		cfg := b.Config()
+		st.Backends = append(st.Backends, BackendState{
+			URL:      b.GetURL().String(),
+			Alive:    b.IsAlive(),
+			Weight:   b.WeightOverride(),
+			Draining: b.IsDraining(),
+			Config:   &cfg,
+		})
+	}
+	return st
+}
+
+// RestoreState applies saved state to the backends with matching URLs and
+// returns how many matched; backends restored as down are re-probed when
+// health checks start. Saved values that no longer apply (e.g. a weight out
+// of range) are skipped and reported in the error.
+func (lb *LoadBalancer) RestoreState(st State) (int, error) {
+	restored, err := lb.restoreState(st)
+	lb.log().Info("restored state", "saved", st.Saved.Format(time.RFC3339), "backends", restored)
+	lb.audit.Record(audit.SystemActor, "state.restore", "", fmt.Sprintf("backends=%d disabled=%d", restored, len(lb.DisabledBackends())))
+	return restored, err
+}
+
+// ImportState applies a snapshot exported by another instance on behalf of
+// actor. With replace, the pool becomes the snapshot's backends first;
+// otherwise only backends with matching URLs are updated. It returns how
+// many backends were restored; skipped saved values are reported as in
+// RestoreState.
+func (lb *LoadBalancer) ImportState(st State, replace bool, actor string) (int, error) {
+	if st.Version != StateVersion {
+		return 0, fmt.Errorf("unsupported state version %d", st.Version)
+	}
+	if replace {
+		configs := make([]backend.Config, 0, len(st.Backends))
+		for _, bs := range st.Backends {
+			if bs.Config == nil {
+				return 0, fmt.Errorf("backend %s has no config to replace the pool with", bs.URL)
+			}
+			configs = append(configs, *bs.Config)
+		}
+		// The snapshot's disable flags replace the local ones
+		lb.mu.Lock()
+		lb.disabled = make(map[string]bool)
+		lb.mu.Unlock()
+		if err := lb.SetBackends(configs); err != nil {
+			return 0, err
+		}
+	}
+
+	restored, err := lb.restoreState(st)
+	lb.log().Info("imported state", "saved", st.Saved.Format(time.RFC3339), "backends", restored)
+	lb.audit.Record(actor, "state.import", "", fmt.Sprintf("backends=%d replace=%v", restored, replace))
+	return restored, err
+}
+
+// restoreState applies st to the backends with matching URLs
+func (lb *LoadBalancer) restoreState(st State) (int, error) {
+	lb.mu.Lock()
+	defer lb.mu.Unlock()
+
+	if len(st.Disabled) > 0 {
+		lb.disabled = make(map[string]bool, len(st.Disabled))
+		for _, u := range st.Disabled {
+			lb.disabled[u] = true
+		}
+	}
+	if store := lb.memorySessions(); store != nil {
+		store.Restore(st.Sessions)
+	}
+	saved := make(map[string]BackendState, len(st.Backends))
+	for _, bs := range st.Backends {
+		saved[bs.URL] = bs
+	}
+
+	restored := 0
+	var errs []error
+	for _, b := range lb.GetBackends() {
+		u := b.GetURL().String()
+		b.SetDisabled(lb.disabled[u])
+		bs, ok := saved[u]
+		if !ok {
+			continue
+		}
+		b.SetAlive(bs.Alive)
+		b.SetDraining(bs.Draining)
+		if bs.Weight > 0 {
+			if err := b.SetWeight(bs.Weight); err != nil {
+				errs = append(errs, fmt.Errorf("%w: weight of %s: %w", ErrStateSkipped, u, err))
+			}
+		}
+		restored++
+	}
+	return restored, errors.Join(errs...)
+}
+
+// memorySessions returns the memory store of sticky sessions, or nil
+// without affinity or with a store outside the process
+func (lb *LoadBalancer) memorySessions() *sticky.MemoryStore {
+	if lb.sticky == nil {
+		return nil
+	}
+	store, _ := lb.sticky.Store().(*sticky.MemoryStore)
+	return store
+}
+
+// SaveState writes st to path atomically
+func SaveState(path string, st State) error {
+	sort.Slice(st.Backends, func(i, j int) bool { return st.Backends[i].URL < st.Backends[j].URL })
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode state: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return fmt.Errorf("failed to write state: %w", err)
+	}
+	return nil
+}
+
+// LoadState reads a state file written by SaveState; a missing file is
+// reported with an error wrapping fs.ErrNotExist
+func LoadState(path string) (State, error) {
+	var st State
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return st, fmt.Errorf("failed to read state: %w", err)
+	}
+	if err := json.Unmarshal(data, &st); err != nil {
+		return st, fmt.Errorf("failed to decode state: %w", err)
+	}
+	if st.Version != StateVersion {
+		return st, fmt.Errorf("unsupported state version %d", st.Version)
+	}
+	return st, nil
+}
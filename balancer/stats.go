@@ -0,0 +1,366 @@
+package balancer
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/chaos"
+)
+
+// Stats is a snapshot of the load balancer statistics
+type Stats struct {
+	Strategy string `json:"strategy"`
+	// Subset lists the labels selection is restricted to, e.g. "zone=a"
+	Subset      string `json:"subset,omitempty"`
+	Maintenance bool   `json:"maintenance"`
+	Draining    bool   `json:"draining"`
+	// StickyStoreErrors counts failed sticky store lookups (sticky sessions only)
+	StickyStoreErrors int64        `json:"stickyStoreErrors,omitempty"`
+	Chaos             *chaos.Stats `json:"chaos,omitempty"`
+	// MaxInFlight and LimitRejected are set when MaxInFlight limits the
+	// balancer; with AdaptiveLimit MaxInFlight is the current limit
+	MaxInFlight   int64       `json:"maxInFlight,omitempty"`
+	AdaptiveLimit bool        `json:"adaptiveLimit,omitempty"`
+	LimitRejected int64       `json:"limitRejected,omitempty"`
+	Queue         *QueueStats `json:"queue,omitempty"`
+	// OutlierEjections counts the ejections by outlier detection
+	OutlierEjections int64 `json:"outlierEjections,omitempty"`
+
+	TotalBackends    int   `json:"totalBackends"`
+	AliveBackends    int   `json:"aliveBackends"`
+	TotalInFlight    int   `json:"totalInFlight"`
+	TotalConnections int   `json:"totalConnections"`
+	TotalRequests    int64 `json:"totalRequests"`
+	FailedRequests   int64 `json:"failedRequests"`
+	// ClientCanceled counts requests abandoned by their client, which aren't
+	// failures
+	ClientCanceled int64 `json:"clientCanceled"`
+	// BytesIn and BytesOut total the request body bytes forwarded to
+	// backends and the response bytes sent to clients
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+	// SuccessRate is the share (0-1) of requests that didn't fail; 1 without requests
+	SuccessRate   float64       `json:"successRate"`
+	Uptime        time.Duration `json:"uptime"`
+	CountersSince time.Time     `json:"countersSince"`
+	// Rates holds the request and error rates per window ("1m", "5m", "15m")
+	Rates map[string]RateStats `json:"rates"`
+	// Errors counts failed requests per failure class
+	Errors   map[string]int64 `json:"errors"`
+	Backends []BackendStats   `json:"backends"`
+}
+
+// RateStats is the request rate and error share over a window
+type RateStats struct {
+	RPS       float64 `json:"rps"`
+	ErrorRate float64 `json:"errorRate"`
+}
+
+// QueueStats describes the wait queue
+type QueueStats struct {
+	Length    int64 `json:"length"`
+	MaxLength int   `json:"maxLength"`
+	Queued    int64 `json:"queued"`
+	Rejected  int64 `json:"rejected"`
+	TimedOut  int64 `json:"timedOut"`
+}
+
+// BackendStats describes one backend
+type BackendStats struct {
+	URL   string `json:"url"`
+	Alive bool   `json:"alive"`
+	// InFlight counts proxied requests; Connections the open upstream
+	// connections, IdleConnections those not carrying a request
+	InFlight        int           `json:"inFlight"`
+	Connections     int           `json:"connections"`
+	IdleConnections int           `json:"idleConnections"`
+	ResponseTime    time.Duration `json:"responseTime"`
+	MinResponseTime time.Duration `json:"minResponseTime"`
+	MaxResponseTime time.Duration `json:"maxResponseTime"`
+	FailCount       int           `json:"failCount"`
+	// BytesIn and BytesOut count the request and response bytes of the
+	// requests proxied to the backend since it was added
+	BytesIn  int64 `json:"bytesIn"`
+	BytesOut int64 `json:"bytesOut"`
+
+	ProbeSuccessRate    float64       `json:"probeSuccessRate"`
+	ConsecutiveFailures int64         `json:"consecutiveFailures"`
+	LastProbeDuration   time.Duration `json:"lastProbeDuration"`
+
+	Weight         int               `json:"weight"`
+	Backup         bool              `json:"backup"`
+	Priority       int               `json:"priority,omitempty"`
+	MaxConnections int               `json:"maxConnections"`
+	LimitRejected  int64             `json:"limitRejected"`
+	Labels         map[string]string `json:"labels,omitempty"`
+	Draining       bool              `json:"draining"`
+	Disabled       bool              `json:"disabled"`
+	// Ejected is set while outlier detection keeps the backend out
+	Ejected bool `json:"ejected,omitempty"`
+
+	// Phases times DNS, connect, TLS and time to first byte, to tell a slow
+	// network from a slow backend
+	Phases backend.PhaseTimings `json:"phases"`
+
+	// HalfOpenProbes counts the probes of a backend marked down by proxy
+	// errors, HalfOpenRecovered those that returned it to rotation
+	HalfOpenProbes    int64 `json:"halfOpenProbes"`
+	HalfOpenRecovered int64 `json:"halfOpenRecovered"`
+}
+
+// GetStats returns statistics about the load balancer and its backends
+func (lb *LoadBalancer) GetStats() Stats {
+	stats := Stats{Backends: make([]BackendStats, 0, len(lb.GetBackends()))}
+	lb.StatsSnapshot(&stats)
+	return stats
+}
+
+// StatsSnapshot fills into with the current statistics. It reuses the
+// backend slice, maps and pointers already in into, so a scraper passing the
+// same Stats on every call doesn't allocate once it has grown to the pool.
+// Labels are shared with the backends and must not be modified.
+func (lb *LoadBalancer) StatsSnapshot(into *Stats) {
+	backends := lb.GetBackends()
+	into.Strategy = lb.GetStrategy().Name()
+	into.Subset = lb.Subset().String()
+	into.Maintenance = lb.maintenance.Load()
+	into.Draining = lb.draining.Load()
+	into.TotalBackends = len(backends)
+	into.AliveBackends, into.TotalInFlight, into.TotalConnections = 0, 0, 0
+
+	into.Backends = into.Backends[:0]
+	for _, b := range backends {
+		alive := b.IsAlive()
+		if alive {
+			into.AliveBackends++
+		}
+		probe := lb.healthChecker.ProbeStats(b)
+		fastest, slowest := b.ResponseTimeRange()
+		bs := BackendStats{
+			URL:                 b.String(),
+			Alive:               alive,
+			InFlight:            b.InFlight(),
+			Connections:         b.OpenConnections(),
+			IdleConnections:     b.IdleConnections(),
+			ResponseTime:        b.GetResponseTime(),
+			MinResponseTime:     fastest,
+			MaxResponseTime:     slowest,
+			FailCount:           b.GetFailCount(),
+			ProbeSuccessRate:    probe.SuccessRate(),
+			ConsecutiveFailures: probe.ConsecutiveFailures,
+			LastProbeDuration:   probe.LastDuration,
+			Weight:              b.GetWeight(),
+			Backup:              b.IsBackup(),
+			Priority:            b.Priority(),
+			MaxConnections:      b.Config().MaxConnections,
+			LimitRejected:       b.LimitRejections(),
+			Labels:              b.Labels(),
+			Draining:            b.IsDraining(),
+			Disabled:            b.IsDisabled(),
+			Ejected:             b.IsEjected(),
+			Phases:              b.PhaseTimings(),
+		}
+		bs.HalfOpenProbes, bs.HalfOpenRecovered = b.HalfOpenProbes()
+		bs.BytesIn, bs.BytesOut = b.Bytes()
+		into.TotalInFlight += bs.InFlight
+		into.TotalConnections += bs.Connections
+		into.Backends = append(into.Backends, bs)
+	}
+
+	now := time.Now()
+	into.Uptime = now.Sub(lb.metrics.StartTime)
+	into.TotalRequests = lb.metrics.TotalRequests.Load()
+	into.FailedRequests = lb.metrics.FailedRequests.Load()
+	into.ClientCanceled = lb.metrics.ClientCanceled.Load()
+	into.BytesIn = lb.metrics.RequestBytes.Load()
+	into.BytesOut = lb.metrics.TotalBytes.Load()
+	into.SuccessRate = successRate(into.TotalRequests, into.FailedRequests)
+
+	lb.metrics.mu.RLock()
+	into.CountersSince = lb.metrics.ResetTime
+	lb.metrics.mu.RUnlock()
+
+	if into.Rates == nil {
+		into.Rates = make(map[string]RateStats, len(rateWindows))
+	}
+	for _, rw := range rateWindows {
+		rps, errorRate := lb.metrics.rates.rates(now, rw.window)
+		into.Rates[rw.name] = RateStats{RPS: rps, ErrorRate: errorRate}
+	}
+	if into.Errors == nil {
+		into.Errors = make(map[string]int64, len(lb.metrics.errors))
+	}
+	lb.fillErrorCounts(into.Errors)
+
+	into.StickyStoreErrors = 0
+	if lb.sticky != nil {
+		into.StickyStoreErrors = lb.stickyErrors.Load()
+	}
+	if lb.chaos == nil {
+		into.Chaos = nil
+	} else {
+		if into.Chaos == nil {
+			into.Chaos = new(chaos.Stats)
+		}
+		*into.Chaos = lb.chaos.Stats()
+	}
+	into.OutlierEjections = 0
+	if lb.outliers != nil {
+		into.OutlierEjections = lb.outliers.ejections.Load()
+	}
+	into.MaxInFlight, into.LimitRejected = 0, 0
+	into.AdaptiveLimit = lb.adaptive != nil
+	if limit := lb.inFlightLimit(); limit > 0 {
+		into.MaxInFlight = limit
+		into.LimitRejected = lb.limitRejections.Load()
+	}
+	if config := lb.queue.config.Load(); config == nil || !config.Enabled() {
+		into.Queue = nil
+	} else {
+		if into.Queue == nil {
+			into.Queue = new(QueueStats)
+		}
+		*into.Queue = lb.queue.stats()
+	}
+}
+
+// successRate returns the share of requests that didn't fail
+func successRate(total, failed int64) float64 {
+	if total == 0 {
+		return 1
+	}
+	return float64(total-failed) / float64(total)
+}
+
+// HandleStats returns an HTTP handler for stats endpoint. It writes the
+// Stats as JSON for ?format=json or an Accept header asking for it, and as
+// text otherwise.
+func (lb *LoadBalancer) HandleStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		stats := lb.GetStats()
+
+		w.Header().Set("Vary", "Accept")
+		if wantsJSON(r) {
+			w.Header().Set("Content-Type", "application/json")
+			w.Header().Set("Cache-Control", "no-store")
+			json.NewEncoder(w).Encode(stats)
+			return
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "╔════════════════════════════════════════╗\n")
+		fmt.Fprintf(w, "║   Load Balancer Statistics             ║\n")
+		fmt.Fprintf(w, "╚════════════════════════════════════════╝\n\n")
+
+		fmt.Fprintf(w, "Strategy:         %s\n", stats.Strategy)
+		if stats.Subset != "" {
+			fmt.Fprintf(w, "Subset:           %s\n", stats.Subset)
+		}
+		if stats.Maintenance {
+			fmt.Fprintf(w, "Maintenance:      ON (all requests answered with 503)\n")
+		}
+		if injected := stats.Chaos; injected != nil {
+			fmt.Fprintf(w, "Chaos:            %d delayed, %d dropped, %d errored\n", injected.Delayed, injected.Dropped, injected.Errored)
+		}
+		if queue := stats.Queue; queue != nil {
+			fmt.Fprintf(w, "Queue:            %d/%d waiting, %d queued, %d rejected, %d timed out\n",
+				queue.Length, queue.MaxLength, queue.Queued, queue.Rejected, queue.TimedOut)
+		}
+		fmt.Fprintf(w, "Uptime:           %s\n", stats.Uptime)
+		fmt.Fprintf(w, "Total Backends:   %d\n", stats.TotalBackends)
+		fmt.Fprintf(w, "Alive Backends:   %d\n", stats.AliveBackends)
+		fmt.Fprintf(w, "Total Requests:   %d\n", stats.TotalRequests)
+		fmt.Fprintf(w, "Failed Requests:  %d\n", stats.FailedRequests)
+		fmt.Fprintf(w, "Client Canceled:  %d\n", stats.ClientCanceled)
+		fmt.Fprintf(w, "Traffic:          %s in, %s out\n", formatBytes(stats.BytesIn), formatBytes(stats.BytesOut))
+		if stats.TotalRequests == 0 {
+			fmt.Fprintf(w, "Success Rate:     N/A\n")
+		} else {
+			fmt.Fprintf(w, "Success Rate:     %.2f%%\n", stats.SuccessRate*100)
+		}
+		fmt.Fprintf(w, "In-flight Requests: %d\n", stats.TotalInFlight)
+		fmt.Fprintf(w, "Upstream Connections: %d\n", stats.TotalConnections)
+		fmt.Fprintf(w, "Counters Since:   %s\n\n", stats.CountersSince.Format(time.RFC3339))
+
+		fmt.Fprintf(w, "Rates:            RPS       Error Rate\n")
+		for _, rw := range rateWindows {
+			r := stats.Rates[rw.name]
+			fmt.Fprintf(w, "  %-4s            %-9.2f %.2f%%\n", rw.name, r.RPS, r.ErrorRate*100)
+		}
+		fmt.Fprintf(w, "\n")
+
+		fmt.Fprintf(w, "Errors by Class:\n")
+		for _, class := range backend.ErrorClasses {
+			if stats.Errors[class] > 0 {
+				fmt.Fprintf(w, "  %-20s %d\n", class, stats.Errors[class])
+			}
+		}
+		fmt.Fprintf(w, "\n")
+
+		fmt.Fprintf(w, "Backend Details:\n")
+		fmt.Fprintf(w, "════════════════════════════════════════\n")
+
+		for i, b := range stats.Backends {
+			if b.Backup {
+				fmt.Fprintf(w, "\n[%d] %s (backup)\n", i+1, b.URL)
+			} else {
+				fmt.Fprintf(w, "\n[%d] %s\n", i+1, b.URL)
+			}
+			if b.Disabled {
+				fmt.Fprintf(w, "    Status:       ‖ Disabled\n")
+			} else if b.Alive && b.Draining {
+				fmt.Fprintf(w, "    Status:       ✓ Healthy (draining)\n")
+			} else if b.Alive {
+				fmt.Fprintf(w, "    Status:       ✓ Healthy\n")
+			} else {
+				fmt.Fprintf(w, "    Status:       ✗ Down\n")
+			}
+			fmt.Fprintf(w, "    In-flight:    %d\n", b.InFlight)
+			fmt.Fprintf(w, "    Connections:  %d\n", b.Connections)
+			fmt.Fprintf(w, "    Response Time: %s (min %s, max %s)\n", b.ResponseTime, b.MinResponseTime, b.MaxResponseTime)
+			fmt.Fprintf(w, "    Fail Count:   %d\n", b.FailCount)
+			fmt.Fprintf(w, "    Traffic:      %s in, %s out\n", formatBytes(b.BytesIn), formatBytes(b.BytesOut))
+			if b.HalfOpenProbes > 0 {
+				fmt.Fprintf(w, "    Half-open:    %d probes, %d recovered\n", b.HalfOpenProbes, b.HalfOpenRecovered)
+			}
+			fmt.Fprintf(w, "    Probes:       %.1f%% ok, %d consecutive failures, last %s\n",
+				b.ProbeSuccessRate*100, b.ConsecutiveFailures, b.LastProbeDuration)
+			fmt.Fprintf(w, "    Phases:       dns %s, connect %s, tls %s, ttfb %s (mean)\n",
+				b.Phases.DNS.Mean(), b.Phases.Connect.Mean(), b.Phases.TLS.Mean(), b.Phases.TTFB.Mean())
+		}
+
+		fmt.Fprintf(w, "\n════════════════════════════════════════\n")
+	}
+}
+
+// formatBytes formats n bytes with a binary unit, e.g. 1.5 MiB
+func formatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
+
+// wantsJSON reports whether r asks for JSON through ?format= or its Accept
+// header; the format parameter wins
+func wantsJSON(r *http.Request) bool {
+	switch r.URL.Query().Get("format") {
+	case "json":
+		return true
+	case "text":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/json")
+}
@@ -0,0 +1,99 @@
+package balancer
+
+import (
+	"net/http"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// stickySession is the session of a request and the backend it is pinned
+// to, both empty for a new session
+type stickySession struct {
+	id     string
+	pinned string
+}
+
+// lookupSticky reads the session of r from the store, once per request.
+// Unknown sessions get a new server-generated ID (see sticky.Lookup), so
+// clients can't fill the store with IDs of their choosing.
+func (lb *LoadBalancer) lookupSticky(r *http.Request) stickySession {
+	id, pinned, err := lb.sticky.Lookup(r)
+	if err != nil {
+		lb.logStickyError(r, err)
+	}
+	return stickySession{id: id, pinned: pinned}
+}
+
+// selectSticky returns the backend session is pinned to, or a newly selected
+// one when the session is new or its backend is unavailable. It may run
+// again when the backend can't be reserved; pinSticky records the one that
+// was.
+func (lb *LoadBalancer) selectSticky(session stickySession) *backend.Backend {
+	if session.pinned != "" {
+		// A session pinned to a backup stays there until a primary recovers,
+		// and one pinned outside the subset until a member is available
+		if b := lb.findBackend(session.pinned); b != nil && b.IsAvailable() && (!b.IsBackup() || !lb.primaryAvailable()) && lb.inSubset(b) {
+			return b
+		}
+	}
+	return lb.selectBackend()
+}
+
+// pinSticky pins session to the backend reserved for the request, setting
+// the cookie of a new session, or extends the pin when it kept its backend.
+// Store failures only cost the affinity.
+func (lb *LoadBalancer) pinSticky(w http.ResponseWriter, r *http.Request, session stickySession, b *backend.Backend) {
+	target := b.GetURL().String()
+	var err error
+	if session.id != "" && target == session.pinned {
+		err = lb.sticky.Touch(r.Context(), session.id, target)
+	} else {
+		err = lb.sticky.Assign(w, r, session.id, target)
+	}
+	if err != nil {
+		lb.logStickyError(r, err)
+	}
+}
+
+// inSubset reports whether b may keep serving a session: without a subset
+// or as its member, or while a subset falling back to the pool has no
+// available member
+func (lb *LoadBalancer) inSubset(b *backend.Backend) bool {
+	index := lb.index.Load()
+	if index.subset == nil || index.selector.Matches(b) {
+		return true
+	}
+	avail := index.subset.Load()
+	return index.selector.Fallback && len(avail.primaries) == 0 && len(avail.backups) == 0
+}
+
+// primaryAvailable reports whether a new session would get a primary: one
+// of the subset, or of the pool when the subset falls back to it
+func (lb *LoadBalancer) primaryAvailable() bool {
+	index := lb.index.Load()
+	if index.subset != nil {
+		avail := index.subset.Load()
+		if len(avail.primaries) > 0 {
+			return true
+		}
+		if !index.selector.Fallback || len(avail.backups) > 0 {
+			return false
+		}
+	}
+	return len(lb.available.Load().primaries) > 0
+}
+
+// findBackend returns the backend with the given URL, or nil
+func (lb *LoadBalancer) findBackend(rawURL string) *backend.Backend {
+	for _, b := range lb.GetBackends() {
+		if b.GetURL().String() == rawURL {
+			return b
+		}
+	}
+	return nil
+}
+
+func (lb *LoadBalancer) logStickyError(r *http.Request, err error) {
+	lb.stickyErrors.Add(1)
+	lb.log().WarnContext(r.Context(), "sticky session store failed", "error", err)
+}
@@ -0,0 +1,66 @@
+package balancer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strconv"
+
+	"github.com/TaiTitans/go-balancer/topk"
+)
+
+// topTracked is how many clients and paths are tracked; /admin/stats/top shows at most this many
+const topTracked = 50
+
+// defaultTopN is the number of entries shown by /admin/stats/top without ?n=
+const defaultTopN = 10
+
+// recordTop counts the request's client IP and path in the top-N tables
+func (lb *LoadBalancer) recordTop(r *http.Request) {
+	if !lb.topFlag.Enabled() {
+		return
+	}
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client = host
+	}
+	lb.topClients.Add(client)
+	lb.topPaths.Add(r.URL.Path)
+}
+
+// TopClients returns the n most active client IPs (approximate counts)
+func (lb *LoadBalancer) TopClients(n int) []topk.Item {
+	return lb.topClients.Top(n)
+}
+
+// TopPaths returns the n most requested paths (approximate counts)
+func (lb *LoadBalancer) TopPaths(n int) []topk.Item {
+	return lb.topPaths.Top(n)
+}
+
+// HandleTopStats returns an HTTP handler listing the top clients and paths
+func (lb *LoadBalancer) HandleTopStats() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		n := defaultTopN
+		if v := r.URL.Query().Get("n"); v != "" {
+			parsed, err := strconv.Atoi(v)
+			if err != nil || parsed <= 0 {
+				http.Error(w, "n must be a positive integer", http.StatusBadRequest)
+				return
+			}
+			n = min(parsed, topTracked)
+		}
+
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(http.StatusOK)
+
+		fmt.Fprintf(w, "Top Clients (approximate):\n")
+		for i, item := range lb.TopClients(n) {
+			fmt.Fprintf(w, "  %2d. %-40s %d\n", i+1, item.Key, item.Count)
+		}
+		fmt.Fprintf(w, "\nTop Paths (approximate):\n")
+		for i, item := range lb.TopPaths(n) {
+			fmt.Fprintf(w, "  %2d. %-40s %d\n", i+1, item.Key, item.Count)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package balancer
+
+import (
+	"net"
+	"net/http"
+
+	"github.com/TaiTitans/go-balancer/tracing"
+)
+
+// startSpan begins the span of r and returns w wrapped to record the status
+// of every answer, the rejected ones included
+func (lb *LoadBalancer) startSpan(w http.ResponseWriter, r *http.Request) (*tracing.Span, *statusRecorder) {
+	span := lb.tracer.Start(r)
+	span.SetAttribute("http.request.method", r.Method)
+	span.SetAttribute("url.path", r.URL.Path)
+	span.SetAttribute("server.address", r.Host)
+	client := r.RemoteAddr
+	if host, _, err := net.SplitHostPort(r.RemoteAddr); err == nil {
+		client = host
+	}
+	span.SetAttribute("client.address", client)
+	return span, &statusRecorder{ResponseWriter: w, statusCode: http.StatusOK}
+}
+
+// endSpan records the status of the answer and ends the span; server
+// errors mark it as failed
+func (lb *LoadBalancer) endSpan(span *tracing.Span, rec *statusRecorder) {
+	span.SetAttribute("http.response.status_code", rec.statusCode)
+	if rec.statusCode >= http.StatusInternalServerError {
+		span.SetError(http.StatusText(rec.statusCode))
+	}
+	span.End()
+}
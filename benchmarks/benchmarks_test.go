@@ -0,0 +1,177 @@
+package benchmarks
+
+import (
+	"context"
+	"io"
+	"log"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/accesslog"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/internal/testutil"
+	"github.com/TaiTitans/go-balancer/middleware"
+	"github.com/TaiTitans/go-balancer/mockbackend"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+// strategies builds each strategy for a backend pool
+var strategies = []struct {
+	name string
+	new  func([]*backend.Backend) strategy.Strategy
+}{
+	{"RoundRobin", func([]*backend.Backend) strategy.Strategy { return strategy.NewRoundRobin() }},
+	{"WeightedRoundRobin", func(backends []*backend.Backend) strategy.Strategy {
+		weights := make(map[*backend.Backend]int, len(backends))
+		for i, b := range backends {
+			weights[b] = i%4 + 1
+		}
+		return strategy.NewWeightedRoundRobin(weights)
+	}},
+	{"LeastConnections", func([]*backend.Backend) strategy.Strategy { return strategy.NewLeastConnections() }},
+	{"Random", func([]*backend.Backend) strategy.Strategy { return strategy.NewRandom() }},
+	{"IPHash", func([]*backend.Backend) strategy.Strategy { return strategy.NewIPHash() }},
+}
+
+func pool(b *testing.B, n int) []*backend.Backend {
+	backends := make([]*backend.Backend, n)
+	for i := range backends {
+		be, err := backend.NewBackend("http://10.0." + strconv.Itoa(i/250) + "." + strconv.Itoa(i%250+1) + ":8080")
+		if err != nil {
+			b.Fatalf("Failed to create backend: %v", err)
+		}
+		backends[i] = be
+	}
+	return backends
+}
+
+func BenchmarkStrategy(b *testing.B) {
+	for _, s := range strategies {
+		for _, n := range []int{4, 64, 5000} {
+			b.Run(s.name+"/"+strconv.Itoa(n), func(b *testing.B) {
+				backends := pool(b, n)
+				st := s.new(backends)
+				b.ReportAllocs()
+				b.RunParallel(func(pb *testing.PB) {
+					for pb.Next() {
+						if st.SelectBackend(backends) == nil {
+							b.Error("Expected a backend")
+							return
+						}
+					}
+				})
+			})
+		}
+	}
+}
+
+func BenchmarkMiddleware(b *testing.B) {
+	log.SetOutput(io.Discard)
+	b.Cleanup(func() { log.SetOutput(os.Stderr) })
+
+	ok := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	middlewares := []struct {
+		name string
+		wrap func(http.Handler) http.Handler
+	}{
+		{"RequestID", middleware.RequestID},
+		{"AccessLog", accesslog.Middleware(accesslog.NewWriterSink(io.Discard))},
+		{"Logger", middleware.Logger},
+		{"Recovery", middleware.Recovery},
+		{"CORS", middleware.CORS},
+		{"Chain", func(h http.Handler) http.Handler {
+			return middleware.Chain(h,
+				middleware.RequestID,
+				accesslog.Middleware(accesslog.NewWriterSink(io.Discard)),
+				middleware.Logger,
+				middleware.Recovery,
+				middleware.CORS,
+			)
+		}},
+	}
+	for _, m := range middlewares {
+		b.Run(m.name, func(b *testing.B) {
+			h := m.wrap(ok)
+			req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+			b.ReportAllocs()
+			b.RunParallel(func(pb *testing.PB) {
+				w := &testutil.DiscardWriter{}
+				for pb.Next() {
+					h.ServeHTTP(w, req)
+				}
+			})
+		})
+	}
+}
+
+// startBalancer proxies to n fake backends behaving as opts describes
+func startBalancer(tb testing.TB, n int, opts mockbackend.Options, s strategy.Strategy) *balancer.LoadBalancer {
+	tb.Helper()
+	lb, err := balancer.New(
+		balancer.WithBackends(testutil.Backends(tb, n, opts)...),
+		balancer.WithStrategy(s),
+		balancer.WithHealthCheck(time.Minute, time.Second),
+		balancer.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	if err != nil {
+		tb.Fatalf("Failed to create load balancer: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	tb.Cleanup(cancel)
+	lb.Start(ctx)
+	return lb
+}
+
+func BenchmarkProxy(b *testing.B) {
+	for _, s := range strategies {
+		b.Run(s.name, func(b *testing.B) {
+			// The weights don't matter here: backends created by the
+			// balancer get the default weight
+			lb := startBalancer(b, 4, mockbackend.Options{ResponseSize: 1024}, s.new(nil))
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			b.ReportAllocs()
+			b.ResetTimer()
+			b.RunParallel(func(pb *testing.PB) {
+				w := &testutil.DiscardWriter{}
+				for pb.Next() {
+					lb.ServeHTTP(w, req)
+				}
+			})
+		})
+	}
+}
+
+// TestProxy_Load runs a short load through the balancer against backends
+// failing some requests and checks every request got the backend's answer,
+// guarding the hot path against regressions that drop or misroute requests
+func TestProxy_Load(t *testing.T) {
+	if testing.Short() {
+		t.Skip("skipping load run in short mode")
+	}
+	lb := startBalancer(t, 4, mockbackend.Options{
+		Latency:     time.Millisecond,
+		Jitter:      time.Millisecond,
+		ErrorRate:   0.1,
+		ErrorStatus: http.StatusInternalServerError,
+	}, strategy.NewLeastConnections())
+
+	result := testutil.Load(lb, testutil.LoadOptions{Concurrency: 8, Requests: 400})
+
+	answered := result.Statuses[http.StatusOK] + result.Statuses[http.StatusInternalServerError]
+	if answered != result.Requests {
+		t.Errorf("Expected every request to reach a backend, got %v", result.Statuses)
+	}
+	if rate := result.ErrorRate(); rate > 0.25 {
+		t.Errorf("Expected an error rate near 0.1, got %.2f", rate)
+	}
+	t.Logf("p50 %v, p99 %v, %d requests in %v",
+		result.Percentile(0.5), result.Percentile(0.99), result.Requests, result.Elapsed)
+}
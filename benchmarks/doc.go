@@ -0,0 +1,5 @@
+// Package benchmarks measures the request hot path: every strategy's
+// selection, each middleware of the default chain, and full proxying through
+// the balancer to fake backends. Run it with "make bench" and compare runs
+// with benchstat to catch regressions.
+package benchmarks
@@ -0,0 +1,221 @@
+// Package chaos injects faults into proxied requests for resilience testing:
+// artificial latency, dropped connections and synthetic error responses for
+// the requests matching a rule. It is opt-in and controlled at runtime
+// through the admin API.
+package chaos
+
+import (
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultErrorStatus answers requests picked by ErrorRate
+const DefaultErrorStatus = http.StatusServiceUnavailable
+
+// Config enables the injector and sets its initial rules
+type Config struct {
+	Enabled bool   `json:"enabled"`
+	Rules   []Rule `json:"rules,omitempty"`
+}
+
+// Rule injects faults into the requests it matches; empty match fields
+// match every request
+type Rule struct {
+	Name string `json:"name,omitempty"`
+	// Host is an exact host or "*.example.com"
+	Host       string `json:"host,omitempty"`
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	// Backend matches the URL of the backend selected for the request
+	Backend string `json:"backend,omitempty"`
+	// Latency delays matching requests, varied by up to ±Jitter
+	Latency time.Duration `json:"latency,omitempty"`
+	Jitter  time.Duration `json:"jitter,omitempty"`
+	// DropRate is the share of requests (0-1) whose connection is closed
+	// without an answer
+	DropRate float64 `json:"dropRate,omitempty"`
+	// ErrorRate is the share of requests (0-1) answered with ErrorStatus
+	// instead of being proxied
+	ErrorRate   float64 `json:"errorRate,omitempty"`
+	ErrorStatus int     `json:"errorStatus,omitempty"`
+}
+
+// Validate checks the rule ranges
+func (r Rule) Validate() error {
+	switch {
+	case r.Latency < 0:
+		return fmt.Errorf("latency must not be negative")
+	case r.Jitter < 0:
+		return fmt.Errorf("jitter must not be negative")
+	case r.DropRate < 0 || r.DropRate > 1:
+		return fmt.Errorf("dropRate %v is out of range (0-1)", r.DropRate)
+	case r.ErrorRate < 0 || r.ErrorRate > 1:
+		return fmt.Errorf("errorRate %v is out of range (0-1)", r.ErrorRate)
+	case r.DropRate+r.ErrorRate > 1:
+		return fmt.Errorf("dropRate and errorRate must not add up to more than 1")
+	case r.ErrorStatus != 0 && (r.ErrorStatus < 400 || r.ErrorStatus > 599):
+		return fmt.Errorf("errorStatus %d must be 4xx or 5xx", r.ErrorStatus)
+	case r.Latency == 0 && r.Jitter == 0 && r.DropRate == 0 && r.ErrorRate == 0:
+		return fmt.Errorf("rule injects nothing (set latency, dropRate or errorRate)")
+	}
+	return nil
+}
+
+// matches reports whether the rule applies to r sent to backendURL
+func (r Rule) matches(req *http.Request, backendURL string) bool {
+	if r.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.PathPrefix) {
+		return false
+	}
+	if r.Backend != "" && r.Backend != backendURL {
+		return false
+	}
+	if r.Host != "" {
+		host := req.Host
+		if h, _, err := net.SplitHostPort(host); err == nil {
+			host = h
+		}
+		if suffix, ok := strings.CutPrefix(r.Host, "*"); ok {
+			return strings.HasSuffix(strings.ToLower(host), strings.ToLower(suffix))
+		}
+		return strings.EqualFold(host, r.Host)
+	}
+	return true
+}
+
+// Stats counts injected faults
+type Stats struct {
+	Delayed int64 `json:"delayed"`
+	Dropped int64 `json:"dropped"`
+	Errored int64 `json:"errored"`
+}
+
+// Injector applies the fault rules
+type Injector struct {
+	mu    sync.RWMutex
+	rules []Rule
+	audit *audit.Log
+
+	delayed atomic.Int64
+	dropped atomic.Int64
+	errored atomic.Int64
+	// random returns a number in [0, 1)
+	random func() float64
+}
+
+// New creates an injector with the given rules; rule changes are recorded
+// in auditLog (optional)
+func New(rules []Rule, auditLog *audit.Log) (*Injector, error) {
+	in := &Injector{audit: auditLog, random: rand.Float64}
+	if err := validate(rules); err != nil {
+		return nil, err
+	}
+	in.rules = rules
+	return in, nil
+}
+
+func validate(rules []Rule) error {
+	for i, r := range rules {
+		if err := r.Validate(); err != nil {
+			return fmt.Errorf("rule %d: %w", i, err)
+		}
+	}
+	return nil
+}
+
+// Rules returns the active rules
+func (in *Injector) Rules() []Rule {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	return append([]Rule(nil), in.rules...)
+}
+
+// SetRules replaces the rules on behalf of actor; an empty list stops
+// injecting faults
+func (in *Injector) SetRules(rules []Rule, actor string) error {
+	if err := validate(rules); err != nil {
+		return err
+	}
+	in.mu.Lock()
+	in.rules = append([]Rule(nil), rules...)
+	in.mu.Unlock()
+
+	if len(rules) == 0 {
+		logging.Logger().Info("fault injection rules cleared")
+		in.audit.Record(actor, "chaos.clear", "", "")
+		return nil
+	}
+	logging.Logger().Warn("fault injection active", "rules", len(rules))
+	in.audit.Record(actor, "chaos.update", "", fmt.Sprintf("rules=%d", len(rules)))
+	return nil
+}
+
+// Stats returns the fault counts
+func (in *Injector) Stats() Stats {
+	return Stats{Delayed: in.delayed.Load(), Dropped: in.dropped.Load(), Errored: in.errored.Load()}
+}
+
+// Inject applies the first rule matching r sent to backendURL. It returns
+// true when the request has been dealt with (answered with an error, or
+// given up by the client during the delay) and must not be proxied; a
+// dropped request aborts the handler with http.ErrAbortHandler.
+func (in *Injector) Inject(w http.ResponseWriter, r *http.Request, backendURL string) bool {
+	rule, ok := in.match(r, backendURL)
+	if !ok {
+		return false
+	}
+
+	if delay := in.delay(rule); delay > 0 {
+		in.delayed.Add(1)
+		timer := time.NewTimer(delay)
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			timer.Stop()
+			return true
+		}
+	}
+
+	roll := in.random()
+	switch {
+	case roll < rule.DropRate:
+		in.dropped.Add(1)
+		panic(http.ErrAbortHandler)
+	case roll < rule.DropRate+rule.ErrorRate:
+		in.errored.Add(1)
+		status := rule.ErrorStatus
+		if status == 0 {
+			status = DefaultErrorStatus
+		}
+		w.Header().Set("X-Chaos-Injected", "true")
+		http.Error(w, http.StatusText(status), status)
+		return true
+	}
+	return false
+}
+
+func (in *Injector) match(r *http.Request, backendURL string) (Rule, bool) {
+	in.mu.RLock()
+	defer in.mu.RUnlock()
+	for _, rule := range in.rules {
+		if rule.matches(r, backendURL) {
+			return rule, true
+		}
+	}
+	return Rule{}, false
+}
+
+func (in *Injector) delay(rule Rule) time.Duration {
+	d := rule.Latency
+	if rule.Jitter > 0 {
+		d += time.Duration((in.random()*2 - 1) * float64(rule.Jitter))
+	}
+	return max(d, 0)
+}
@@ -0,0 +1,123 @@
+package chaos
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+)
+
+func TestRule_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		rule  Rule
+		valid bool
+	}{
+		{"latency", Rule{Latency: time.Second}, true},
+		{"errors", Rule{PathPrefix: "/api/", ErrorRate: 0.5, ErrorStatus: 502}, true},
+		{"nothing", Rule{PathPrefix: "/api/"}, false},
+		{"negative latency", Rule{Latency: -time.Second}, false},
+		{"drop rate", Rule{DropRate: 1.5}, false},
+		{"rates sum", Rule{DropRate: 0.6, ErrorRate: 0.6}, false},
+		{"status", Rule{ErrorRate: 1, ErrorStatus: 200}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.rule.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestRule_Matches(t *testing.T) {
+	tests := []struct {
+		name    string
+		rule    Rule
+		target  string
+		backend string
+		want    bool
+	}{
+		{"everything", Rule{}, "http://example.com/", "http://b1", true},
+		{"path", Rule{PathPrefix: "/api/"}, "http://example.com/api/users", "http://b1", true},
+		{"other path", Rule{PathPrefix: "/api/"}, "http://example.com/web", "http://b1", false},
+		{"host", Rule{Host: "example.com"}, "http://example.com:8080/", "http://b1", true},
+		{"wildcard host", Rule{Host: "*.example.com"}, "http://api.example.com/", "http://b1", true},
+		{"other host", Rule{Host: "*.example.com"}, "http://example.org/", "http://b1", false},
+		{"backend", Rule{Backend: "http://b2"}, "http://example.com/", "http://b1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.rule.matches(httptest.NewRequest("GET", tt.target, nil), tt.backend); got != tt.want {
+				t.Errorf("Expected %v, got %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestInjector_Inject(t *testing.T) {
+	auditLog, _ := audit.New("", 0)
+	in, err := New([]Rule{
+		{PathPrefix: "/error", ErrorRate: 0.5, ErrorStatus: http.StatusBadGateway},
+		{PathPrefix: "/drop", DropRate: 0.5},
+		{PathPrefix: "/slow", Latency: 20 * time.Millisecond},
+	}, auditLog)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	roll := 0.4
+	in.random = func() float64 { return roll }
+
+	rec := httptest.NewRecorder()
+	if !in.Inject(rec, httptest.NewRequest("GET", "/error", nil), "http://b1") || rec.Code != http.StatusBadGateway {
+		t.Errorf("Expected an injected 502, got %d", rec.Code)
+	}
+	roll = 0.6
+	if in.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/error", nil), "http://b1") {
+		t.Error("Expected the request above the error rate to be proxied")
+	}
+
+	func() {
+		defer func() {
+			if p := recover(); p != http.ErrAbortHandler {
+				t.Errorf("Expected http.ErrAbortHandler, got %v", p)
+			}
+		}()
+		roll = 0.1
+		in.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/drop", nil), "http://b1")
+	}()
+
+	start := time.Now()
+	if in.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil), "http://b1") {
+		t.Error("Expected a delayed request to be proxied")
+	}
+	if elapsed := time.Since(start); elapsed < 20*time.Millisecond {
+		t.Errorf("Expected a delay of 20ms, got %v", elapsed)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if !in.Inject(httptest.NewRecorder(), httptest.NewRequest("GET", "/slow", nil).WithContext(ctx), "http://b1") {
+		t.Error("Expected a request canceled during the delay not to be proxied")
+	}
+
+	if stats := in.Stats(); stats != (Stats{Delayed: 2, Dropped: 1, Errored: 1}) {
+		t.Errorf("Expected 2 delayed, 1 dropped, 1 errored, got %+v", stats)
+	}
+
+	if err := in.SetRules([]Rule{{ErrorRate: 2}}, "alice"); err == nil {
+		t.Error("Expected an error for an invalid rule")
+	}
+	if err := in.SetRules(nil, "alice"); err != nil || len(in.Rules()) != 0 {
+		t.Errorf("Expected the rules to be cleared, got %v (%v)", in.Rules(), err)
+	}
+	if entries := auditLog.Recent(1); len(entries) != 1 || entries[0].Action != "chaos.clear" {
+		t.Errorf("Expected a chaos.clear audit entry, got %+v", entries)
+	}
+}
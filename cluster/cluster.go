@@ -0,0 +1,399 @@
+// Package cluster lets several balancer instances share backend health over
+// a lightweight UDP gossip protocol, so a backend seen failing by one
+// instance is avoided by the others before their own probes fire
+package cluster
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Defaults for unset Config fields
+const (
+	DefaultInterval = time.Second
+	DefaultFanout   = 3
+	// maxMessage is the largest gossip datagram accepted
+	maxMessage = 64 * 1024
+	// deadAfter is how many intervals a silent member is kept
+	deadAfter = 10
+)
+
+// Config enables cluster mode
+type Config struct {
+	// Bind is the UDP host:port gossip listens on; cluster mode is off when empty
+	Bind string `json:"bind,omitempty"`
+	// Advertise is the address peers use to reach this node (Bind when empty)
+	Advertise string `json:"advertise,omitempty"`
+	// Peers are seed members (host:port) contacted to join the cluster
+	Peers []string `json:"peers,omitempty"`
+	// Secret authenticates gossip with HMAC-SHA256; all members must share
+	// it. It is required: unauthenticated gossip would let anyone reaching
+	// Bind mark backends down.
+	Secret   string        `json:"secret,omitempty"`
+	Interval time.Duration `json:"interval,omitempty"`
+	Fanout   int           `json:"fanout,omitempty"`
+	// NodeName identifies this node (hostname plus advertise address when empty)
+	NodeName string `json:"nodeName,omitempty"`
+}
+
+// Enabled reports whether cluster mode is configured
+func (c Config) Enabled() bool {
+	return c.Bind != ""
+}
+
+// Observation is the health of a backend as last seen by a node. Version is
+// a Lamport clock ordering observations independently of member clocks;
+// Time is informational.
+type Observation struct {
+	URL     string    `json:"url"`
+	Alive   bool      `json:"alive"`
+	Version uint64    `json:"version"`
+	Time    time.Time `json:"time"`
+	Node    string    `json:"node"`
+}
+
+// newer reports whether o supersedes current; equal versions are settled by
+// node name so every member picks the same one
+func (o Observation) newer(current Observation) bool {
+	if o.Version != current.Version {
+		return o.Version > current.Version
+	}
+	return o.Node > current.Node
+}
+
+// Member is a known cluster node
+type Member struct {
+	Name     string    `json:"name"`
+	Addr     string    `json:"addr"`
+	LastSeen time.Time `json:"lastSeen"`
+	Seed     bool      `json:"seed,omitempty"`
+}
+
+// Backends gives access to the local backend pool
+type Backends interface {
+	GetBackends() []*backend.Backend
+}
+
+// message is a gossip datagram
+type message struct {
+	Node         string        `json:"node"`
+	Addr         string        `json:"addr"`
+	Peers        []string      `json:"peers,omitempty"`
+	Observations []Observation `json:"observations,omitempty"`
+}
+
+// Cluster gossips backend health with its peers
+type Cluster struct {
+	cfg      Config
+	backends Backends
+	conn     *net.UDPConn
+
+	mu       sync.Mutex
+	members  map[string]*Member     // by address
+	state    map[string]Observation // newest observation per backend URL
+	lastSeen map[string]bool        // local alive state at the previous tick
+	clock    uint64                 // Lamport clock versioning observations
+}
+
+// New creates a cluster member sharing the health of backends
+func New(cfg Config, backends Backends) (*Cluster, error) {
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("cluster bind address is required")
+	}
+	if cfg.Secret == "" {
+		return nil, fmt.Errorf("cluster secret is required")
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultInterval
+	}
+	if cfg.Fanout <= 0 {
+		cfg.Fanout = DefaultFanout
+	}
+	if cfg.Advertise == "" {
+		cfg.Advertise = cfg.Bind
+	}
+	if cfg.NodeName == "" {
+		host, _ := os.Hostname()
+		cfg.NodeName = host + "/" + cfg.Advertise
+	}
+
+	c := &Cluster{
+		cfg:      cfg,
+		backends: backends,
+		members:  make(map[string]*Member),
+		state:    make(map[string]Observation),
+		lastSeen: make(map[string]bool),
+	}
+	for _, peer := range cfg.Peers {
+		if peer != cfg.Advertise {
+			c.members[peer] = &Member{Addr: peer, Seed: true}
+		}
+	}
+	return c, nil
+}
+
+// Start binds the gossip socket and runs until ctx is done
+func (c *Cluster) Start(ctx context.Context) error {
+	addr, err := net.ResolveUDPAddr("udp", c.cfg.Bind)
+	if err != nil {
+		return fmt.Errorf("invalid cluster bind address: %w", err)
+	}
+	c.conn, err = net.ListenUDP("udp", addr)
+	if err != nil {
+		return fmt.Errorf("failed to bind cluster socket: %w", err)
+	}
+	if c.cfg.Advertise == c.cfg.Bind && addr.Port == 0 {
+		c.mu.Lock()
+		c.cfg.Advertise = c.conn.LocalAddr().String()
+		c.mu.Unlock()
+	}
+	logging.Logger().Info("cluster node gossiping", "node", c.cfg.NodeName, "addr", c.conn.LocalAddr().String(), "peers", len(c.cfg.Peers))
+
+	go c.receive()
+	go func() {
+		<-ctx.Done()
+		c.conn.Close()
+	}()
+	go c.run(ctx)
+	return nil
+}
+
+// Addr returns the address peers reach this node at
+func (c *Cluster) Addr() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.cfg.Advertise
+}
+
+// Members returns the known peers, sorted by address
+func (c *Cluster) Members() []Member {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	members := make([]Member, 0, len(c.members))
+	for _, m := range c.members {
+		members = append(members, *m)
+	}
+	sort.Slice(members, func(i, j int) bool { return members[i].Addr < members[j].Addr })
+	return members
+}
+
+// Observations returns the newest known observation per backend
+func (c *Cluster) Observations() []Observation {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	out := make([]Observation, 0, len(c.state))
+	for _, o := range c.state {
+		out = append(out, o)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].URL < out[j].URL })
+	return out
+}
+
+func (c *Cluster) run(ctx context.Context) {
+	ticker := time.NewTicker(c.cfg.Interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.observe(time.Now())
+			c.expire(time.Now())
+			c.gossip()
+		}
+	}
+}
+
+// observe turns local health changes into observations and forgets the
+// backends no longer in the pool
+func (c *Cluster) observe(now time.Time) {
+	backends := c.backends.GetBackends()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	current := make(map[string]bool, len(backends))
+	for _, b := range backends {
+		u := b.GetURL().String()
+		current[u] = true
+		alive := b.IsAlive()
+		previous, seen := c.lastSeen[u]
+		c.lastSeen[u] = alive
+		if !seen || previous == alive {
+			// Only changes are news; the initial state is not an observation
+			continue
+		}
+		if current, ok := c.state[u]; ok && current.Alive == alive {
+			// Already known, e.g. applied from a peer
+			continue
+		}
+		c.clock++
+		c.state[u] = Observation{URL: u, Alive: alive, Version: c.clock, Time: now, Node: c.cfg.NodeName}
+	}
+	for u := range c.lastSeen {
+		if !current[u] {
+			delete(c.lastSeen, u)
+			delete(c.state, u)
+		}
+	}
+}
+
+// merge applies observations from a peer that are newer than the known ones;
+// observations of backends outside the local pool are ignored
+func (c *Cluster) merge(observations []Observation) {
+	byURL := make(map[string]*backend.Backend)
+	for _, b := range c.backends.GetBackends() {
+		byURL[b.GetURL().String()] = b
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, o := range observations {
+		c.clock = max(c.clock, o.Version)
+		b, ok := byURL[o.URL]
+		if !ok {
+			continue
+		}
+		if current, ok := c.state[o.URL]; ok && !o.newer(current) {
+			continue
+		}
+		c.state[o.URL] = o
+		if b.IsAlive() == o.Alive {
+			continue
+		}
+		b.SetAlive(o.Alive)
+		c.lastSeen[o.URL] = o.Alive
+		state := "down"
+		if o.Alive {
+			state = "up"
+		}
+		logging.Logger().Info("backend marked by cluster peer", "backend", o.URL, "state", state, "node", o.Node)
+	}
+}
+
+// expire forgets members that stopped gossiping (seeds are kept)
+func (c *Cluster) expire(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for addr, m := range c.members {
+		if !m.Seed && now.Sub(m.LastSeen) > deadAfter*c.cfg.Interval {
+			logging.Logger().Info("cluster member left", "member", m.Name, "addr", addr)
+			delete(c.members, addr)
+		}
+	}
+}
+
+// gossip sends the known state to Fanout random members
+func (c *Cluster) gossip() {
+	c.mu.Lock()
+	msg := message{Node: c.cfg.NodeName, Addr: c.cfg.Advertise}
+	addrs := make([]string, 0, len(c.members))
+	for addr := range c.members {
+		addrs = append(addrs, addr)
+	}
+	msg.Peers = addrs
+	for _, o := range c.state {
+		msg.Observations = append(msg.Observations, o)
+	}
+	c.mu.Unlock()
+
+	if len(addrs) == 0 {
+		return
+	}
+	data, err := c.seal(msg)
+	if err != nil {
+		logging.Logger().Error("failed to encode gossip", "error", err)
+		return
+	}
+	rand.Shuffle(len(addrs), func(i, j int) { addrs[i], addrs[j] = addrs[j], addrs[i] })
+	if len(addrs) > c.cfg.Fanout {
+		addrs = addrs[:c.cfg.Fanout]
+	}
+	for _, addr := range addrs {
+		udpAddr, err := net.ResolveUDPAddr("udp", addr)
+		if err != nil {
+			continue
+		}
+		c.conn.WriteToUDP(data, udpAddr)
+	}
+}
+
+func (c *Cluster) receive() {
+	buf := make([]byte, maxMessage)
+	for {
+		n, from, err := c.conn.ReadFromUDP(buf)
+		if err != nil {
+			return
+		}
+		msg, err := c.open(buf[:n])
+		if err != nil {
+			logging.Logger().Debug("dropped cluster message", "from", from, "error", err)
+			continue
+		}
+		c.join(msg)
+		c.merge(msg.Observations)
+	}
+}
+
+// join records the sender and the peers it knows
+func (c *Cluster) join(msg message) {
+	now := time.Now()
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if msg.Addr != "" && msg.Addr != c.cfg.Advertise {
+		m, ok := c.members[msg.Addr]
+		if !ok {
+			m = &Member{Addr: msg.Addr}
+			c.members[msg.Addr] = m
+			logging.Logger().Info("cluster member joined", "member", msg.Node, "addr", msg.Addr)
+		}
+		m.Name = msg.Node
+		m.LastSeen = now
+	}
+	for _, peer := range msg.Peers {
+		if _, ok := c.members[peer]; !ok && peer != c.cfg.Advertise {
+			c.members[peer] = &Member{Addr: peer, LastSeen: now}
+		}
+	}
+}
+
+// seal encodes msg, prefixed with its HMAC
+func (c *Cluster) seal(msg message) ([]byte, error) {
+	payload, err := json.Marshal(msg)
+	if err != nil {
+		return nil, err
+	}
+	if len(payload) > maxMessage-sha256.Size {
+		return nil, fmt.Errorf("gossip message of %d bytes is too large", len(payload))
+	}
+	mac := hmac.New(sha256.New, []byte(c.cfg.Secret))
+	mac.Write(payload)
+	return append(mac.Sum(nil), payload...), nil
+}
+
+// open verifies and decodes a datagram
+func (c *Cluster) open(data []byte) (message, error) {
+	var msg message
+	if len(data) < sha256.Size {
+		return msg, fmt.Errorf("message too short")
+	}
+	mac := hmac.New(sha256.New, []byte(c.cfg.Secret))
+	mac.Write(data[sha256.Size:])
+	if !hmac.Equal(mac.Sum(nil), data[:sha256.Size]) {
+		return msg, fmt.Errorf("invalid signature")
+	}
+	if err := json.Unmarshal(data[sha256.Size:], &msg); err != nil {
+		return msg, fmt.Errorf("invalid message: %w", err)
+	}
+	return msg, nil
+}
@@ -0,0 +1,141 @@
+package cluster
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+type pool []*backend.Backend
+
+func (p pool) GetBackends() []*backend.Backend { return p }
+
+func newPool(t *testing.T, urls ...string) pool {
+	t.Helper()
+	var p pool
+	for _, u := range urls {
+		b, err := backend.NewBackend(u)
+		if err != nil {
+			t.Fatalf("Failed to create backend: %v", err)
+		}
+		p = append(p, b)
+	}
+	return p
+}
+
+func startNode(t *testing.T, ctx context.Context, backends pool, secret string, peers ...string) *Cluster {
+	t.Helper()
+	c, err := New(Config{Bind: "127.0.0.1:0", Peers: peers, Secret: secret, Interval: 20 * time.Millisecond}, backends)
+	if err != nil {
+		t.Fatalf("Failed to create cluster: %v", err)
+	}
+	if err := c.Start(ctx); err != nil {
+		t.Fatalf("Failed to start cluster: %v", err)
+	}
+	return c
+}
+
+func waitFor(cond func() bool) bool {
+	deadline := time.Now().Add(2 * time.Second)
+	for time.Now().Before(deadline) {
+		if cond() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+func TestCluster_SharesHealth(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	poolA := newPool(t, "http://backend1:80", "http://backend2:80")
+	poolB := newPool(t, "http://backend1:80", "http://backend2:80")
+	a := startNode(t, ctx, poolA, "secret")
+	b := startNode(t, ctx, poolB, "secret", a.Addr())
+
+	if !waitFor(func() bool { return len(a.Members()) == 1 }) {
+		t.Fatal("Expected node B to join node A")
+	}
+
+	// B sees backend1 fail; A stops using it without probing
+	poolB[0].SetAlive(false)
+	if !waitFor(func() bool { return !poolA[0].IsAlive() }) {
+		t.Fatal("Expected backend1 to be marked down on node A")
+	}
+	if !poolA[1].IsAlive() {
+		t.Error("Expected backend2 to stay up on node A")
+	}
+
+	// Recovery is shared the same way
+	poolB[0].SetAlive(true)
+	if !waitFor(func() bool { return poolA[0].IsAlive() }) {
+		t.Fatal("Expected backend1 to be marked up again on node A")
+	}
+	if obs := b.Observations(); len(obs) != 1 || !obs[0].Alive {
+		t.Errorf("Expected one alive observation on node B, got %+v", obs)
+	}
+}
+
+func TestCluster_RejectsWrongSecret(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	poolA := newPool(t, "http://backend1:80")
+	poolB := newPool(t, "http://backend1:80")
+	a := startNode(t, ctx, poolA, "secret")
+	startNode(t, ctx, poolB, "other", a.Addr())
+
+	poolB[0].SetAlive(false)
+	time.Sleep(200 * time.Millisecond)
+	if !poolA[0].IsAlive() || len(a.Members()) != 0 {
+		t.Error("Expected messages signed with another secret to be ignored")
+	}
+}
+
+func TestCluster_NewerObservationWins(t *testing.T) {
+	backends := newPool(t, "http://backend1:80")
+	c, _ := New(Config{Bind: "127.0.0.1:0", Secret: "secret"}, backends)
+
+	// Versions decide, not the (possibly skewed) member clocks
+	now := time.Now()
+	c.merge([]Observation{{URL: "http://backend1:80", Alive: false, Version: 2, Time: now.Add(-time.Hour), Node: "a"}})
+	c.merge([]Observation{{URL: "http://backend1:80", Alive: true, Version: 1, Time: now, Node: "b"}})
+	if backends[0].IsAlive() {
+		t.Error("Expected an older observation to be ignored")
+	}
+
+	// A local change after merging outranks what was merged
+	backends[0].SetAlive(true)
+	c.lastSeen["http://backend1:80"] = false
+	c.observe(now)
+	if obs := c.Observations(); len(obs) != 1 || !obs[0].Alive || obs[0].Version != 3 {
+		t.Errorf("Expected a local observation with version 3, got %+v", obs)
+	}
+}
+
+func TestCluster_RequiresSecret(t *testing.T) {
+	if _, err := New(Config{Bind: "127.0.0.1:0"}, newPool(t)); err == nil {
+		t.Error("Expected cluster mode without a secret to be refused")
+	}
+}
+
+func TestCluster_PrunesRemovedBackends(t *testing.T) {
+	backends := newPool(t, "http://backend1:80", "http://backend2:80")
+	c, _ := New(Config{Bind: "127.0.0.1:0", Secret: "secret"}, backends)
+	c.observe(time.Now())
+	c.merge([]Observation{{URL: "http://backend2:80", Alive: false, Version: 1, Node: "a"}})
+
+	c.backends = backends[:1]
+	c.observe(time.Now())
+	if len(c.Observations()) != 0 || len(c.lastSeen) != 1 {
+		t.Errorf("Expected the removed backend forgotten, got %+v and %v", c.Observations(), c.lastSeen)
+	}
+	c.merge([]Observation{{URL: "http://backend2:80", Alive: false, Version: 2, Node: "a"}})
+	if len(c.Observations()) != 0 {
+		t.Errorf("Expected observations of unknown backends to be ignored, got %+v", c.Observations())
+	}
+}
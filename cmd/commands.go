@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/config"
+	"github.com/TaiTitans/go-balancer/healthcheck"
+)
+
+// usage prints the available subcommands and the shared flags
+func usage() {
+	out := flag.CommandLine.Output()
+	fmt.Fprintf(out, "Usage: %s [command] [flags]\n\n", os.Args[0])
+	fmt.Fprintln(out, "Commands:")
+	fmt.Fprintln(out, "  run             Start the load balancer (default)")
+	fmt.Fprintln(out, "  validate        Check the configuration and exit")
+	fmt.Fprintln(out, "  check-backends  Probe every configured backend once and exit")
+	fmt.Fprintln(out, "  version         Print build information and exit")
+	fmt.Fprintln(out, "  dashboard       Print a Grafana dashboard for the exported metrics")
+	fmt.Fprintln(out, "  mock-backend    Serve a test backend with simulated latency and errors")
+	fmt.Fprintln(out, "  soak            Run against chaotic backends for hours, checking invariants")
+	fmt.Fprintln(out, "  service         Install, remove, start or stop the Windows service")
+	fmt.Fprintln(out, "\nrun, validate and check-backends accept these flags:")
+	flag.PrintDefaults()
+	fmt.Fprintln(out, "\nEnvironment variables override the config file; explicitly set flags override both:")
+	for _, v := range config.EnvUsage() {
+		fmt.Fprintf(out, "  %-36s %s\n", v[0], v[1])
+	}
+}
+
+// parseConfigArgs parses the shared flags of a subcommand; a single
+// positional argument is taken as the config path
+func parseConfigArgs(args []string) {
+	flag.CommandLine.Parse(args)
+	if flag.NArg() == 1 && *configPath == "" {
+		flag.Set("config", flag.Arg(0))
+	} else if flag.NArg() > 0 {
+		fmt.Fprintf(os.Stderr, "Unexpected arguments: %v\n", flag.Args())
+		os.Exit(2)
+	}
+}
+
+// runValidate implements the "validate" subcommand, exiting non-zero when
+// the config cannot be loaded or has problems
+func runValidate(args []string) {
+	parseConfigArgs(args)
+
+	cfg, err := loadStartupConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		var verr *config.ValidationError
+		if errors.As(err, &verr) {
+			fmt.Fprintf(os.Stderr, "Invalid configuration: %d problem(s):\n", len(verr.Problems))
+			for _, problem := range verr.Problems {
+				fmt.Fprintf(os.Stderr, "  - %s\n", problem)
+			}
+		} else {
+			fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		}
+		os.Exit(1)
+	}
+
+	pools := cfg.ResolvedPools()
+	backends := 0
+	for _, pool := range pools {
+		backends += len(pool.Backends)
+	}
+	fmt.Printf("Configuration is valid: %d pool(s), %d backend(s), strategy %s\n",
+		len(pools), backends, primaryStrategy(cfg))
+}
+
+// runCheckBackends implements the "check-backends" subcommand: every backend
+// (discovered ones included) is probed once and the command fails if any is down
+func runCheckBackends(args []string) {
+	parseConfigArgs(args)
+
+	cfg, err := loadStartupConfig()
+	if err == nil {
+		err = cfg.Validate()
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Failed to load config: %v\n", err)
+		os.Exit(1)
+	}
+
+	var configs []config.BackendConfig
+	for _, pool := range cfg.ResolvedPools() {
+		if pool.Name == config.DefaultPoolName && cfg.Discovering() {
+			ctx, cancel := context.WithCancel(context.Background())
+			_, discovered, err := startDiscovery(ctx, cfg.Discovery, pool.Backends)
+			cancel()
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "Failed to start discovery: %v\n", err)
+				os.Exit(1)
+			}
+			configs = append(configs, discovered...)
+			continue
+		}
+		configs = append(configs, pool.Backends...)
+	}
+
+	down := 0
+	for _, bc := range configs {
+		b, err := backend.NewBackendWithConfig(bc)
+		if err == nil {
+			b.SetHealthDefaults(cfg.HealthCheck.Probe())
+			var duration time.Duration
+			duration, err = healthcheck.Probe(context.Background(), b, cfg.HealthCheck.Timeout)
+			if err == nil {
+				fmt.Printf("  UP    %s (%v)\n", b.HealthURL(), duration.Round(time.Millisecond))
+				continue
+			}
+		}
+		down++
+		fmt.Printf("  DOWN  %s: %v\n", bc.URL, err)
+	}
+
+	fmt.Printf("%d/%d backend(s) healthy\n", len(configs)-down, len(configs))
+	if down > 0 {
+		os.Exit(1)
+	}
+}
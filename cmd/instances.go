@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/config"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+// startInstances adds the load balancers of cfg.Instances to group and starts
+// them, each serving its pool on its own port through the middleware chain
+// wrap. They share the health check settings, audit log and feature flags of
+// the primary load balancer.
+func startInstances(ctx context.Context, cfg *config.Config, group *balancer.Group, auditLog *audit.Log, flags *features.Registry, wrap func(http.Handler) http.Handler, tlsConfig *tls.Config) ([]*http.Server, error) {
+	servers := make([]*http.Server, 0, len(cfg.Instances))
+	listeners := make([]net.Listener, 0, len(cfg.Instances))
+	closeAll := func() {
+		for _, l := range listeners {
+			l.Close()
+		}
+	}
+
+	for _, inst := range cfg.Instances {
+		pool, ok := cfg.Pool(inst.Pool)
+		if !ok {
+			closeAll()
+			return nil, fmt.Errorf("instance %s: pool %q does not exist", inst.Name, inst.Pool)
+		}
+		lb, err := newPoolBalancer(cfg, pool, pool.Strategy.Type, auditLog, flags)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("instance %s: %w", inst.Name, err)
+		}
+		if err := group.Add(inst.Name, lb); err != nil {
+			closeAll()
+			return nil, err
+		}
+
+		server := &http.Server{
+			Addr:         fmt.Sprintf(":%d", inst.Port),
+			Handler:      wrap(lb),
+			ReadTimeout:  cfg.Server.ReadTimeout,
+			WriteTimeout: cfg.Server.WriteTimeout,
+			IdleTimeout:  cfg.Server.IdleTimeout,
+			TLSConfig:    tlsConfig,
+			Protocols:    serverProtocols(cfg),
+		}
+		listener, err := net.Listen("tcp", server.Addr)
+		if err != nil {
+			closeAll()
+			return nil, fmt.Errorf("instance %s: %w", inst.Name, err)
+		}
+		servers = append(servers, server)
+		listeners = append(listeners, listener)
+	}
+
+	group.Start(ctx)
+	for i, server := range servers {
+		go func() {
+			serve := server.Serve
+			if tlsConfig != nil {
+				serve = func(l net.Listener) error { return server.ServeTLS(l, "", "") }
+			}
+			if err := serve(listeners[i]); err != nil && err != http.ErrServerClosed {
+				log.Fatalf("Instance %s server error: %v", cfg.Instances[i].Name, err)
+			}
+		}()
+	}
+	return servers, nil
+}
+
+// reloadInstances applies the pools of a new config to the running instances;
+// added or removed instances need a restart
+func reloadInstances(group *balancer.Group, active, next *config.Config) error {
+	for _, inst := range next.Instances {
+		lb := group.Get(inst.Name)
+		pool, ok := next.Pool(inst.Pool)
+		if lb == nil || !ok {
+			continue
+		}
+		if err := lb.SetBackends(pool.Backends); err != nil {
+			return fmt.Errorf("instance %s: %w", inst.Name, err)
+		}
+		lb.SetQueue(next.Queue)
+		errorPolicy, err := next.PoolErrorPage(inst.Pool).Policy()
+		if err != nil {
+			return fmt.Errorf("instance %s: %w", inst.Name, err)
+		}
+		lb.SetErrorPolicy(errorPolicy)
+		lb.SetSubset(pool.Strategy.Subset)
+		if previous, ok := instancePool(active, inst.Name); ok && strings.EqualFold(previous.Strategy.Type, pool.Strategy.Type) {
+			continue
+		}
+		strat, err := strategy.New(pool.Strategy.Type)
+		if err != nil {
+			return fmt.Errorf("instance %s: %w", inst.Name, err)
+		}
+		lb.SetStrategy(strat)
+	}
+	return nil
+}
+
+// instancePool returns the pool served by the named instance of cfg
+func instancePool(cfg *config.Config, name string) (config.PoolConfig, bool) {
+	for _, inst := range cfg.Instances {
+		if inst.Name == name {
+			return cfg.Pool(inst.Pool)
+		}
+	}
+	return config.PoolConfig{}, false
+}
+
+// newPoolBalancer creates a load balancer for a pool besides the primary
+// one, sharing the health check settings, audit log and feature flags of the
+// primary load balancer
+func newPoolBalancer(cfg *config.Config, pool config.PoolConfig, strategyName string, auditLog *audit.Log, flags *features.Registry) (*balancer.LoadBalancer, error) {
+	strat, err := strategy.New(strategyName)
+	if err != nil {
+		return nil, err
+	}
+	errorPolicy, err := cfg.PoolErrorPage(pool.Name).Policy()
+	if err != nil {
+		return nil, err
+	}
+	lb, err := balancer.NewLoadBalancer(balancer.Config{
+		Backends:             pool.Backends,
+		Strategy:             strat,
+		HealthCheckInterval:  cfg.HealthCheck.Interval,
+		HealthCheckTimeout:   cfg.HealthCheck.Timeout,
+		HealthCheck:          cfg.HealthCheck.Probe(),
+		SlowRequestThreshold: *slowThreshold,
+		AuditLog:             auditLog,
+		TraceExemplars:       *exemplarsFlag,
+		Tracer:               tracer,
+		Features:             flags,
+		Queue:                cfg.Queue,
+		OutlierDetection:     cfg.OutlierDetection,
+		MaxInFlight:          cfg.Server.MaxInFlight,
+		AdaptiveLimit:        cfg.Server.AdaptiveLimit,
+		MaxRequestBytes:      cfg.Server.MaxRequestBytes,
+		ErrorPolicy:          errorPolicy,
+		Subset:               pool.Strategy.Subset,
+	})
+	if err == nil && notifier != nil {
+		lb.OnBackendStateChange(notifier.BackendStateChanged(pool.Name))
+	}
+	return lb, err
+}
+
+// unservedPools returns the names of the pools of cfg that neither a route
+// (or, without routes, the primary load balancer) nor an instance serves
+func unservedPools(cfg *config.Config) []string {
+	served := make(map[string]bool)
+	for _, name := range cfg.BlueGreen.Pools {
+		served[name] = true
+	}
+	if len(cfg.Routes) == 0 {
+		if pool, ok := cfg.PrimaryPool(); ok {
+			served[pool.Name] = true
+		}
+	}
+	for _, r := range cfg.Routes {
+		served[r.Pool] = true
+	}
+	for _, inst := range cfg.Instances {
+		served[inst.Pool] = true
+	}
+	var names []string
+	for _, pool := range cfg.ResolvedPools() {
+		if !served[pool.Name] {
+			names = append(names, pool.Name)
+		}
+	}
+	return names
+}
+
+// serverProtocols returns the protocols served on the load balancer ports:
+// the defaults, plus HTTP/2 cleartext with server.h2c
+func serverProtocols(cfg *config.Config) *http.Protocols {
+	if !cfg.Server.H2C {
+		return nil
+	}
+	var protocols http.Protocols
+	protocols.SetHTTP1(true)
+	protocols.SetHTTP2(true)
+	protocols.SetUnencryptedHTTP2(true)
+	return &protocols
+}
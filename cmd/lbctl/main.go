@@ -0,0 +1,557 @@
+// Command lbctl controls a running go-balancer through its admin API
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/admin"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/internal/jsonconf"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+)
+
+var (
+	addrFlag    = flag.String("addr", envOr("LBCTL_ADDR", "http://localhost:8080"), "Admin API address (defaults to $LBCTL_ADDR)")
+	tokenFlag   = flag.String("token", os.Getenv("LBCTL_TOKEN"), "Admin bearer token (defaults to $LBCTL_TOKEN)")
+	outputFlag  = flag.String("o", "table", "Output format (table, json)")
+	timeoutFlag = flag.Duration("timeout", 10*time.Second, "Request timeout")
+)
+
+const usageText = `Usage: lbctl [flags] <command>
+
+Commands:
+  backends list                 List backends
+  backend add <url> [weight]    Add a backend
+  backend remove <url>          Remove a backend
+  backend drain <url>           Stop sending new requests to a backend
+  backend undrain <url>         Resume sending requests to a backend
+  backend disable <url>         Take a backend out of rotation for maintenance
+  backend enable <url>          Put a disabled backend back into rotation
+  backend weight <url> <n>      Change a backend's weight
+  strategy get                  Show the load balancing strategy
+  strategy set <name>           Switch the load balancing strategy
+  maintenance on|off|status     Toggle or show maintenance mode
+  flags list                    Show the feature flags
+  flag on|off <name>            Switch a feature off during an incident, or back on
+  schedules list                Show the scheduled weight changes
+  schedule add <url> <weight> [duration] [HH:MM]
+                                Ramp a backend's weight over duration, now or daily at HH:MM
+  schedule cancel <id>          Remove a scheduled weight change
+  state export [file]           Write the pool state snapshot as JSON
+  state import <file|-> [replace]
+                                Apply a snapshot; replace adopts its backends
+  chaos status                  Show the fault injection rules and counts
+  chaos set <file|->            Replace the fault injection rules with a JSON list
+  chaos clear                   Stop injecting faults
+  bluegreen status              Show the active blue/green pool
+  bluegreen switch <pool>       Send new traffic to a pool; in-flight requests drain
+  bluegreen toggle              Send new traffic to the inactive pool
+  reload                        Re-read and apply the config
+
+Flags:
+`
+
+func main() {
+	flag.Usage = func() {
+		fmt.Fprint(flag.CommandLine.Output(), usageText)
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if *outputFlag != "table" && *outputFlag != "json" {
+		fail(fmt.Errorf("unknown output format %q (table, json)", *outputFlag))
+	}
+	if flag.NArg() == 0 {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	client := admin.NewClient(*addrFlag, *tokenFlag, *timeoutFlag)
+	ctx, cancel := context.WithTimeout(context.Background(), *timeoutFlag)
+	defer cancel()
+
+	if err := run(ctx, client, flag.Args()); err != nil {
+		fail(err)
+	}
+}
+
+// run dispatches a command
+func run(ctx context.Context, client *admin.Client, args []string) error {
+	command, args := args[0], args[1:]
+	switch command {
+	case "backends":
+		if len(args) != 1 || args[0] != "list" {
+			return usageError("backends list")
+		}
+		backends, err := client.Backends(ctx)
+		if err != nil {
+			return err
+		}
+		printBackends(backends...)
+
+	case "backend":
+		return runBackend(ctx, client, args)
+
+	case "strategy":
+		switch {
+		case len(args) == 1 && args[0] == "get":
+			name, err := client.Strategy(ctx)
+			if err != nil {
+				return err
+			}
+			printValue("strategy", name)
+		case len(args) == 2 && args[0] == "set":
+			name, err := client.SetStrategy(ctx, args[1])
+			if err != nil {
+				return err
+			}
+			printValue("strategy", name)
+		default:
+			return usageError("strategy get|set <name>")
+		}
+
+	case "maintenance":
+		if len(args) != 1 {
+			return usageError("maintenance on|off|status")
+		}
+		switch args[0] {
+		case "on", "off":
+			if err := client.SetMaintenance(ctx, args[0] == "on"); err != nil {
+				return err
+			}
+		case "status":
+		default:
+			return usageError("maintenance on|off|status")
+		}
+		enabled, err := client.Maintenance(ctx)
+		if err != nil {
+			return err
+		}
+		printValue("maintenance", enabled)
+
+	case "flags":
+		if len(args) != 1 || args[0] != "list" {
+			return usageError("flags list")
+		}
+		flags, err := client.Flags(ctx)
+		if err != nil {
+			return err
+		}
+		printFlags(flags...)
+
+	case "flag":
+		if len(args) != 2 || (args[0] != "on" && args[0] != "off") {
+			return usageError("flag on|off <name>")
+		}
+		state, err := client.SetFlag(ctx, args[1], args[0] == "on")
+		if err != nil {
+			return err
+		}
+		printFlags(state)
+
+	case "schedules":
+		if len(args) != 1 || args[0] != "list" {
+			return usageError("schedules list")
+		}
+		jobs, err := client.Schedules(ctx)
+		if err != nil {
+			return err
+		}
+		printSchedules(jobs...)
+
+	case "schedule":
+		return runSchedule(ctx, client, args)
+
+	case "state":
+		return runState(ctx, client, args)
+
+	case "chaos":
+		var status admin.ChaosStatus
+		var err error
+		switch {
+		case len(args) == 1 && args[0] == "status":
+			status, err = client.Chaos(ctx)
+		case len(args) == 2 && args[0] == "set":
+			var rules []chaos.Rule
+			if rules, err = readRules(args[1]); err != nil {
+				return err
+			}
+			status, err = client.SetChaos(ctx, rules)
+		case len(args) == 1 && args[0] == "clear":
+			status, err = client.ClearChaos(ctx)
+		default:
+			return usageError("chaos status|set <file|->|clear")
+		}
+		if err != nil {
+			return err
+		}
+		printChaos(status)
+
+	case "bluegreen":
+		var err error
+		switch {
+		case len(args) == 1 && args[0] == "status":
+		case len(args) == 2 && args[0] == "switch":
+			_, err = client.SetBlueGreen(ctx, args[1])
+		case len(args) == 1 && args[0] == "toggle":
+			_, err = client.ToggleBlueGreen(ctx)
+		default:
+			return usageError("bluegreen status|switch <pool>|toggle")
+		}
+		if err != nil {
+			return err
+		}
+		status, err := client.BlueGreen(ctx)
+		if err != nil {
+			return err
+		}
+		printBlueGreen(status)
+
+	case "reload":
+		if err := client.Reload(ctx); err != nil {
+			return err
+		}
+		printValue("status", "reloaded")
+
+	default:
+		return fmt.Errorf("unknown command %q (run lbctl -h for help)", command)
+	}
+	return nil
+}
+
+// runBackend implements the "backend" commands acting on a single backend
+func runBackend(ctx context.Context, client *admin.Client, args []string) error {
+	if len(args) < 2 {
+		return usageError("backend add|remove|drain|undrain|disable|enable|weight <url>")
+	}
+	action, target := args[0], args[1]
+
+	var status admin.BackendStatus
+	var err error
+	switch {
+	case action == "add" && len(args) <= 3:
+		cfg := backend.Config{URL: target}
+		if len(args) == 3 {
+			if cfg.Weight, err = strconv.Atoi(args[2]); err != nil {
+				return fmt.Errorf("invalid weight %q", args[2])
+			}
+		}
+		status, err = client.AddBackend(ctx, cfg)
+	case action == "remove" && len(args) == 2:
+		if err := client.RemoveBackend(ctx, target); err != nil {
+			return err
+		}
+		printValue("removed", target)
+		return nil
+	case action == "drain" && len(args) == 2:
+		status, err = client.SetDraining(ctx, target, true)
+	case action == "undrain" && len(args) == 2:
+		status, err = client.SetDraining(ctx, target, false)
+	case action == "disable" && len(args) == 2:
+		status, err = client.SetEnabled(ctx, target, false)
+	case action == "enable" && len(args) == 2:
+		status, err = client.SetEnabled(ctx, target, true)
+	case action == "weight" && len(args) == 3:
+		weight, convErr := strconv.Atoi(args[2])
+		if convErr != nil {
+			return fmt.Errorf("invalid weight %q", args[2])
+		}
+		status, err = client.SetWeight(ctx, target, weight)
+	default:
+		return usageError("backend add <url> [weight] | remove|drain|undrain|disable|enable <url> | weight <url> <n>")
+	}
+	if err != nil {
+		return err
+	}
+	printBackends(status)
+	return nil
+}
+
+// runSchedule implements the "schedule" commands
+func runSchedule(ctx context.Context, client *admin.Client, args []string) error {
+	const usage = "schedule add <url> <weight> [duration] [HH:MM] | cancel <id>"
+	switch {
+	case len(args) >= 3 && len(args) <= 5 && args[0] == "add":
+		cfg := schedule.Config{Backend: args[1]}
+		var err error
+		if cfg.To, err = strconv.Atoi(args[2]); err != nil {
+			return fmt.Errorf("invalid weight %q", args[2])
+		}
+		if len(args) >= 4 {
+			if cfg.Duration, err = time.ParseDuration(args[3]); err != nil {
+				return fmt.Errorf("invalid duration %q", args[3])
+			}
+		}
+		if len(args) == 5 {
+			cfg.Daily = args[4]
+		}
+		job, err := client.AddSchedule(ctx, cfg)
+		if err != nil {
+			return err
+		}
+		printSchedules(job)
+	case len(args) == 2 && args[0] == "cancel":
+		id, err := strconv.Atoi(args[1])
+		if err != nil {
+			return fmt.Errorf("invalid id %q", args[1])
+		}
+		if err := client.CancelSchedule(ctx, id); err != nil {
+			return err
+		}
+		printValue("cancelled", id)
+	default:
+		return usageError(usage)
+	}
+	return nil
+}
+
+// runState implements the "state" commands exporting and importing pool
+// snapshots
+func runState(ctx context.Context, client *admin.Client, args []string) error {
+	const usage = "state export [file] | import <file|-> [replace]"
+	switch {
+	case len(args) >= 1 && len(args) <= 2 && args[0] == "export":
+		st, err := client.State(ctx)
+		if err != nil {
+			return err
+		}
+		if len(args) == 1 {
+			printJSON(st)
+			return nil
+		}
+		data, err := json.MarshalIndent(st, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(args[1], data, 0o600); err != nil {
+			return err
+		}
+		printValue("exported", len(st.Backends))
+	case (len(args) == 2 || (len(args) == 3 && args[2] == "replace")) && args[0] == "import":
+		data, err := readInput(args[1])
+		if err != nil {
+			return err
+		}
+		var st balancer.State
+		if err := json.Unmarshal(data, &st); err != nil {
+			return fmt.Errorf("invalid snapshot: %w", err)
+		}
+		result, err := client.ImportState(ctx, st, len(args) == 3)
+		if err != nil {
+			return err
+		}
+		if *outputFlag == "json" {
+			printJSON(result)
+			return nil
+		}
+		fmt.Printf("restored: %d\n", result.Restored)
+		printBackends(result.Backends...)
+	default:
+		return usageError(usage)
+	}
+	return nil
+}
+
+// readInput reads a file, or stdin when path is "-"
+func readInput(path string) ([]byte, error) {
+	if path == "-" {
+		return io.ReadAll(os.Stdin)
+	}
+	return os.ReadFile(path)
+}
+
+// readRules reads a JSON list of fault injection rules from a file, or from
+// stdin when path is "-"
+func readRules(path string) ([]chaos.Rule, error) {
+	data, err := readInput(path)
+	if err != nil {
+		return nil, err
+	}
+	var raw interface{}
+	var rules []chaos.Rule
+	if err := json.Unmarshal(data, &raw); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	if err := jsonconf.Decode(raw, &rules); err != nil {
+		return nil, fmt.Errorf("invalid rules: %w", err)
+	}
+	return rules, nil
+}
+
+// printBackends writes backends as a table or JSON
+func printBackends(backends ...admin.BackendStatus) {
+	if *outputFlag == "json" {
+		printJSON(backends)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "URL\tSTATUS\tWEIGHT\tIN-FLIGHT\tCONNECTIONS\tFAILS\tBACKUP")
+	for _, b := range backends {
+		status := "up"
+		switch {
+		case !b.Enabled:
+			status = "disabled"
+		case !b.Alive:
+			status = "down"
+		case b.Draining:
+			status = "draining"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%d\t%d\t%v\n", b.URL, status, b.Weight, b.InFlight, b.Connections, b.FailCount, b.Backup)
+	}
+	tw.Flush()
+}
+
+// printFlags writes feature flags as a table or JSON
+func printFlags(flags ...features.State) {
+	if *outputFlag == "json" {
+		printJSON(flags)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tSTATE\tDEFAULT\tDESCRIPTION")
+	for _, f := range flags {
+		state := "off"
+		switch {
+		case f.Enabled && !f.Effective:
+			state = "on (bypassed)"
+		case f.Enabled:
+			state = "on"
+		}
+		def := "off"
+		if f.Default {
+			def = "on"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", f.Name, state, def, f.Description)
+	}
+	tw.Flush()
+}
+
+// printSchedules writes scheduled weight changes as a table or JSON
+func printSchedules(jobs ...schedule.Job) {
+	if *outputFlag == "json" {
+		printJSON(jobs)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tNAME\tTARGET\tWEIGHT\tDURATION\tWHEN\tSTATE")
+	for _, j := range jobs {
+		target := j.Backend
+		if target == "" {
+			target = fmt.Sprint(j.Labels)
+		}
+		weight := strconv.Itoa(j.To)
+		if j.From != 0 {
+			weight = fmt.Sprintf("%d->%d", j.From, j.To)
+		}
+		when := "once"
+		switch {
+		case j.Daily != "":
+			when = "daily " + j.Daily
+		case !j.Start.IsZero():
+			when = j.Start.Format(time.RFC3339)
+		}
+		state := "waiting"
+		switch {
+		case j.Done:
+			state = "done"
+		case j.Active:
+			state = "ramping"
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%v\t%s\t%s\n", j.ID, j.Name, target, weight, j.Duration, when, state)
+	}
+	tw.Flush()
+}
+
+// printBlueGreen writes the blue/green pools as a table or JSON
+func printBlueGreen(status router.SwitchStatus) {
+	if *outputFlag == "json" {
+		printJSON(status)
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "POOL\tACTIVE\tIN-FLIGHT")
+	for _, t := range status.Targets {
+		fmt.Fprintf(tw, "%s\t%v\t%d\n", t.Name, t.Name == status.Active, t.InFlight)
+	}
+	tw.Flush()
+}
+
+// printChaos writes the fault injection state as a table or JSON
+func printChaos(status admin.ChaosStatus) {
+	if *outputFlag == "json" {
+		printJSON(status)
+		return
+	}
+	if !status.Enabled {
+		fmt.Println("fault injection: disabled")
+		return
+	}
+	fmt.Printf("fault injection: %d rule(s); %d delayed, %d dropped, %d errored\n",
+		len(status.Rules), status.Stats.Delayed, status.Stats.Dropped, status.Stats.Errored)
+	if len(status.Rules) == 0 {
+		return
+	}
+	tw := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tHOST\tPATH\tBACKEND\tLATENCY\tDROP\tERROR")
+	for _, r := range status.Rules {
+		latency := r.Latency.String()
+		if r.Jitter > 0 {
+			latency += "±" + r.Jitter.String()
+		}
+		errorRate := fmt.Sprintf("%.0f%%", r.ErrorRate*100)
+		if r.ErrorStatus != 0 {
+			errorRate += fmt.Sprintf(" (%d)", r.ErrorStatus)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%.0f%%\t%s\n", orDash(r.Name), orDash(r.Host), orDash(r.PathPrefix), orDash(r.Backend), latency, r.DropRate*100, errorRate)
+	}
+	tw.Flush()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// printValue writes a single result as "key: value" or a JSON object
+func printValue(key string, value interface{}) {
+	if *outputFlag == "json" {
+		printJSON(map[string]interface{}{key: value})
+		return
+	}
+	fmt.Printf("%s: %v\n", key, value)
+}
+
+func printJSON(v interface{}) {
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func usageError(usage string) error {
+	return fmt.Errorf("usage: lbctl %s", usage)
+}
+
+func fail(err error) {
+	fmt.Fprintf(os.Stderr, "lbctl: %v\n", err)
+	os.Exit(1)
+}
+
+func envOr(key, fallback string) string {
+	if v := os.Getenv(key); v != "" {
+		return v
+	}
+	return fallback
+}
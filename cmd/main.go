@@ -1,94 +1,543 @@
 package main
 
 import (
+	"cmp"
 	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
+	"io/fs"
 	"log"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"reflect"
+	"slices"
 	"strings"
 	"syscall"
 	"time"
 
+	"github.com/TaiTitans/go-balancer/accesslog"
+	"github.com/TaiTitans/go-balancer/admin"
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
 	"github.com/TaiTitans/go-balancer/balancer"
-	constants "github.com/TaiTitans/go-balancer/const"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/cluster"
+	"github.com/TaiTitans/go-balancer/config"
+	"github.com/TaiTitans/go-balancer/dashboard"
+	"github.com/TaiTitans/go-balancer/debugtap"
+	"github.com/TaiTitans/go-balancer/discovery"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/metrics"
 	"github.com/TaiTitans/go-balancer/middleware"
+	"github.com/TaiTitans/go-balancer/notify"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+	"github.com/TaiTitans/go-balancer/sticky"
 	"github.com/TaiTitans/go-balancer/strategy"
+	"github.com/TaiTitans/go-balancer/systemd"
+	"github.com/TaiTitans/go-balancer/tracing"
+	"github.com/TaiTitans/go-balancer/version"
 )
 
 var (
+	configPath     = flag.String("config", "", "Path or http(s) URL of a JSON or YAML config file; explicitly set flags override its values")
+	watchConfig    = flag.Bool("watch-config", false, "Reload the -config file automatically when it changes (URLs are polled)")
+	profileFlag    = flag.String("profile", "", "Config profile overlay merged over -config, e.g. prod loads config.prod.json (defaults to $GO_BALANCER_PROFILE)")
+	configPoll     = flag.Duration("config-poll", config.DefaultRemotePoll, "Polling interval for a -config URL with -watch-config")
+	configKey      = flag.String("config-public-key", "", "Base64 Ed25519 public key verifying a -config URL's signature (env:// and file:// references allowed)")
+	configSecret   = flag.String("config-hmac-secret", "", "HMAC-SHA256 secret verifying a -config URL's signature (env:// and file:// references allowed)")
 	port           = flag.Int("port", 8080, "Load balancer port")
 	backendsFlag   = flag.String("backends", "http://localhost:8081,http://localhost:8082,http://localhost:8083", "Comma-separated list of backend URLs")
-	strategyFlag   = flag.String("strategy", "roundrobin", "Load balancing strategy (roundrobin, leastconnections, random)")
+	backendsFile   = flag.String("backends-file", "", "JSON, YAML or text file listing backends, watched and applied on change (replaces -backends)")
+	strategyFlag   = flag.String("strategy", strategy.RoundRobinName, "Load balancing strategy ("+strings.Join(strategy.Names(), ", ")+")")
 	healthInterval = flag.Duration("health-interval", 10*time.Second, "Health check interval")
 	healthTimeout  = flag.Duration("health-timeout", 5*time.Second, "Health check timeout")
+	drainTimeout   = flag.Duration("drain-timeout", 30*time.Second, "How long shutdown waits for in-flight requests before closing connections")
+	slowThreshold  = flag.Duration("slow-threshold", 0, "Log requests slower than this duration (0 disables)")
+	accessLogSink  = flag.String("access-log", "none", "Access log sink (none, stdout, file, syslog, http)")
+	accessLogDest  = flag.String("access-log-target", "", "Access log target: file path, syslog address or ndjson URL")
+	accessLogFmt   = flag.String("access-log-format", "json", "Access log line format (json, common, combined)")
+	auditLogPath   = flag.String("audit-log", "", "Append-only audit log file for runtime changes (in-memory only when empty)")
+	debugTapDir    = flag.String("debug-tap-dir", "", "Directory the debug tap may write its outputFile to (file output is refused when empty)")
+	metricsFlag    = flag.Bool("metrics", true, "Expose Prometheus metrics at /metrics")
+	exemplarsFlag  = flag.Bool("exemplars", false, "Attach W3C traceparent trace IDs as exemplars to latency histograms")
+	pushMode       = flag.String("push-mode", "none", "Push metrics instead of only serving /metrics (none, pushgateway, remotewrite)")
+	pushURL        = flag.String("push-url", "", "Pushgateway base URL or remote-write endpoint")
+	pushInterval   = flag.Duration("push-interval", metrics.DefaultPushInterval, "Interval between metric pushes")
+	pushJob        = flag.String("push-job", "go-balancer", "Job label for pushed metrics")
+	adminPort      = flag.Int("admin-port", 0, "Serve the admin API on this port instead of the load balancer port (requires an admin token)")
+	adminToken     = flag.String("admin-token", "", "Bearer token protecting admin endpoints, or an env:// / file:// reference (admin endpoints are disabled when empty)")
+	versionFlag    = flag.Bool("version", false, "Print build information and exit")
+	clusterBind    = flag.String("cluster-bind", "", "UDP host:port for sharing backend health with other instances (cluster mode is off when empty)")
+	clusterPeers   = flag.String("cluster-peers", "", "Comma-separated host:port of cluster members to join")
+	clusterSecret  = flag.String("cluster-secret", "", "Shared secret authenticating cluster gossip (required with -cluster-bind), or an env:// / file:// reference")
+	stickyStore    = flag.String("sticky", "none", "Sticky session store: none, memory, redis")
+	stickyRedis    = flag.String("sticky-redis", "", "Redis host:port holding shared sticky sessions")
+	chaosEnabled   = flag.Bool("chaos", false, "Enable fault injection controlled through /admin/chaos (resilience testing only)")
+	stateFile      = flag.String("state-file", "", "Save backend health, weights, drain/disable flags and memory sticky sessions here on shutdown and restore them on start")
+	historySize    = flag.Int("config-history", config.DefaultHistorySize, "Number of applied configs kept for rollback via the admin API")
+	historyDir     = flag.String("config-history-dir", "", "Directory persisting applied configs across restarts (memory only when empty)")
+	tlsCert        = flag.String("tls-cert", "", "Certificate file (PEM) to serve HTTPS with; requires -tls-key")
+	tlsKey         = flag.String("tls-key", "", "Private key file (PEM) of -tls-cert")
+	tlsMinVersion  = flag.String("tls-min-version", "1.2", "Oldest TLS version accepted with -tls-cert: 1.0, 1.1, 1.2, 1.3")
+	h2c            = flag.Bool("h2c", false, "Accept HTTP/2 cleartext (e.g. gRPC) clients and speak it to http backends")
+	maxConns       = flag.Int("max-connections", 0, "Concurrent requests per backend without its own maxConnections (0 = unlimited)")
+	queueSize      = flag.Int("queue-size", 0, "Requests that may wait for a backend when all are full or down (0 answers 503 at once)")
+	queueTimeout   = flag.Duration("queue-timeout", balancer.DefaultQueueTimeout, "Longest a queued request waits before a 503")
 )
 
 func main() {
-	flag.Parse()
+	flag.Usage = usage
+	command, args := "run", os.Args[1:]
+	if len(args) > 0 && !strings.HasPrefix(args[0], "-") {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "run":
+		runServer(args)
+	case "validate":
+		runValidate(args)
+	case "check-backends":
+		runCheckBackends(args)
+	case "version":
+		fmt.Println(version.Get())
+	case "dashboard":
+		runDashboard(args)
+	case "mock-backend":
+		runMockBackend(args)
+	case "soak":
+		runSoak(args)
+	case "service":
+		runService(args)
+	case "help":
+		usage()
+	default:
+		fmt.Fprintf(os.Stderr, "Unknown command %q\n\n", command)
+		usage()
+		os.Exit(2)
+	}
+}
+
+// runServer implements the "run" subcommand (the default), starting the load balancer
+func runServer(args []string) {
+	flag.CommandLine.Parse(args)
+
+	if *versionFlag {
+		fmt.Println(version.Get())
+		return
+	}
+
+	cfg, err := loadStartupConfig()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		log.Fatalf("Invalid configuration: %v", err)
+	}
+	logging.Configure(cfg.Logging.Level, cfg.Logging.Format, logOutput)
 
-	// Parse backend URLs
-	backendURLs := parseBackendURLs(*backendsFlag)
-	if len(backendURLs) == 0 {
+	// Create context for graceful shutdown
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Discovered backends replace the static list of the default pool
+	initialBackends := backendConfigsOf(cfg)
+	var provider discovery.Provider
+	if cfg.Discovering() {
+		provider, initialBackends, err = startDiscovery(ctx, cfg.Discovery, initialBackends)
+		if err != nil {
+			log.Fatalf("Failed to start discovery: %v", err)
+		}
+	}
+	if len(initialBackends) == 0 {
 		log.Fatal("No backend URLs provided")
 	}
+	backendURLs := make([]string, 0, len(initialBackends))
+	for _, b := range initialBackends {
+		backendURLs = append(backendURLs, b.URL)
+	}
 
-	// Select strategy
-	var strat strategy.Strategy
-	switch strings.ToLower(*strategyFlag) {
-	case constants.RoundRobinStrategy:
-		strat = strategy.NewRoundRobin()
-	case constants.LeastConnectionsStrategy:
-		strat = strategy.NewLeastConnections()
-	case constants.RandomStrategy:
-		strat = strategy.NewRandom()
-	default:
-		log.Fatalf("Unknown strategy: %s", *strategyFlag)
+	strat, err := strategy.New(primaryStrategy(cfg))
+	if err != nil {
+		log.Fatal(err)
+	}
+	if unserved := unservedPools(cfg); len(unserved) > 0 {
+		logging.Logger().Warn("pools are neither routed nor served by an instance", "pools", strings.Join(unserved, ", "))
+	}
+
+	// Audit log for runtime mutations
+	auditLog, err := audit.New(*auditLogPath, audit.DefaultMaxRecent)
+	if err != nil {
+		log.Fatalf("Failed to open audit log: %v", err)
+	}
+	defer auditLog.Close()
+
+	// Kill switches for optional features, flipped through the admin API
+	flags := features.NewRegistry(auditLog)
+
+	// Connection pool shared by the backends created from here on
+	if err := backend.ConfigureTransport(cfg.Transport); err != nil {
+		log.Fatalf("Invalid transport settings: %v", err)
+	}
+	if err := backend.SetTrustedProxies(cfg.TrustedProxies); err != nil {
+		log.Fatalf("Invalid trusted proxies: %v", err)
+	}
+
+	errorPolicy, err := primaryErrorPolicy(cfg)
+	if err != nil {
+		log.Fatalf("Invalid error page: %v", err)
+	}
+
+	// Spans of proxied requests, exported to an OTLP collector
+	tracingConfig := cfg.Tracing
+	tracingConfig.Headers = make(map[string]string, len(cfg.Tracing.Headers))
+	for name, value := range cfg.Tracing.Headers {
+		if tracingConfig.Headers[name], err = config.ResolveSecret(value); err != nil {
+			log.Fatalf("Invalid tracing header %s: %v", name, err)
+		}
+	}
+	if tracer, err = tracing.New(tracingConfig); err != nil {
+		log.Fatalf("Failed to configure tracing: %v", err)
+	}
+	defer tracer.Close()
+
+	// Webhooks told when a backend goes down or recovers
+	webhooks := make([]notify.Config, len(cfg.Notifications))
+	for i, n := range cfg.Notifications {
+		n.Headers = make(map[string]string, len(cfg.Notifications[i].Headers))
+		for name, value := range cfg.Notifications[i].Headers {
+			if n.Headers[name], err = config.ResolveSecret(value); err != nil {
+				log.Fatalf("Invalid notification header %s: %v", name, err)
+			}
+		}
+		if n.RoutingKey, err = config.ResolveSecret(n.RoutingKey); err != nil {
+			log.Fatalf("Invalid notification routing key: %v", err)
+		}
+		webhooks[i] = n
+	}
+	if notifier, err = notify.New(webhooks); err != nil {
+		log.Fatalf("Failed to configure notifications: %v", err)
 	}
 
 	// Configure the load balancer
-	config := balancer.Config{
-		BackendURLs:         backendURLs,
-		Strategy:            strat,
-		HealthCheckInterval: *healthInterval,
-		HealthCheckTimeout:  *healthTimeout,
+	lbConfig := balancer.Config{
+		Backends:             initialBackends,
+		Strategy:             strat,
+		HealthCheckInterval:  cfg.HealthCheck.Interval,
+		HealthCheckTimeout:   cfg.HealthCheck.Timeout,
+		HealthCheck:          cfg.HealthCheck.Probe(),
+		SlowRequestThreshold: *slowThreshold,
+		AuditLog:             auditLog,
+		TraceExemplars:       *exemplarsFlag,
+		Tracer:               tracer,
+		Features:             flags,
+		Queue:                cfg.Queue,
+		OutlierDetection:     cfg.OutlierDetection,
+		MaxInFlight:          cfg.Server.MaxInFlight,
+		AdaptiveLimit:        cfg.Server.AdaptiveLimit,
+		MaxRequestBytes:      cfg.Server.MaxRequestBytes,
+		ErrorPolicy:          errorPolicy,
+		Subset:               primarySubset(cfg),
+	}
+	if cfg.Discovering() {
+		lbConfig.RequireHealthy = cfg.Discovery.RequireHealthy()
+		lbConfig.GracePeriod = cfg.Discovery.GracePeriod
+	}
+	if cfg.Sticky.Enabled() {
+		lbConfig.Sticky, err = sticky.New(cfg.Sticky)
+		if err != nil {
+			log.Fatalf("Failed to configure sticky sessions: %v", err)
+		}
+	}
+
+	var injector *chaos.Injector
+	if cfg.Chaos.Enabled {
+		injector, err = chaos.New(cfg.Chaos.Rules, auditLog)
+		if err != nil {
+			log.Fatalf("Failed to configure fault injection: %v", err)
+		}
+		lbConfig.Chaos = injector
+		logging.Logger().Warn("fault injection is enabled; do not use this instance for real traffic")
 	}
 
 	// Create load balancer
-	lb, err := balancer.NewLoadBalancer(config)
+	lb, err := balancer.NewLoadBalancer(lbConfig)
 	if err != nil {
 		log.Fatalf("Failed to create load balancer: %v", err)
 	}
+	if notifier != nil {
+		primary, _ := cfg.PrimaryPool()
+		lb.OnBackendStateChange(notifier.BackendStateChanged(primary.Name))
+	}
 
-	// Create context for graceful shutdown
-	ctx, cancel := context.WithCancel(context.Background())
-	defer cancel()
+	// Restore what the previous run learned before health checks start
+	if *stateFile != "" {
+		state, err := balancer.LoadState(*stateFile)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			logging.Logger().Info("no saved state", "path", *stateFile)
+		case err != nil:
+			logging.Logger().Warn("ignoring saved state", "error", err)
+		default:
+			if _, err := lb.RestoreState(state); err != nil {
+				logging.Logger().Warn("saved state not fully restored", "error", err)
+			}
+		}
+	}
 
 	// Start the load balancer
 	lb.Start(ctx)
 
+	// Share backend health with the other instances of a cluster
+	var members *cluster.Cluster
+	if cfg.Cluster.Enabled() {
+		members, err = cluster.New(cfg.Cluster, lb)
+		if err == nil {
+			err = members.Start(ctx)
+		}
+		if err != nil {
+			log.Fatalf("Failed to start cluster mode: %v", err)
+		}
+	}
+
+	// Scheduled weight changes from the config and the admin API
+	schedules := schedule.New(lb.GetBackends, auditLog)
+	schedules.SetConfigured(cfg.Schedules)
+	go schedules.Run(ctx, schedule.DefaultInterval)
+
+	// Every applied config is versioned so a bad reload can be rolled back
+	history, err := config.NewHistory(*historySize, *historyDir)
+	if err != nil {
+		log.Fatalf("Failed to open config history: %v", err)
+	}
+	history.Record(cfg, "startup")
+	// Additional load balancers serving other pools on their own ports
+	instances := balancer.NewGroup()
+	// Routes send requests to the load balancers of several pools
+	routed := balancer.NewGroup()
+	var blueGreen *router.Switch
+	if cfg.BlueGreen.Enabled() {
+		if blueGreen, err = startBlueGreen(cfg, lb, routed, auditLog, flags); err != nil {
+			log.Fatalf("Failed to configure blue/green pools: %v", err)
+		}
+	}
+	var routes *router.Router
+	if len(cfg.Routes) > 0 {
+		if routes, err = startRoutes(cfg, lb, routed, blueGreen, auditLog, flags); err != nil {
+			log.Fatalf("Failed to configure routes: %v", err)
+		}
+	}
+	routed.Start(ctx)
+	apply := reloader(lb, instances, routes, routed, blueGreen, flags, schedules, injector, cfg)
+	applyFrom := func(source string) func(*config.Config) error {
+		return func(next *config.Config) error {
+			if err := apply(next); err != nil {
+				return err
+			}
+			history.Record(next, source)
+			return nil
+		}
+	}
+	configSource := "file"
+	if remoteConfig != nil {
+		configSource = "remote"
+	}
+
+	if provider != nil {
+		go discovery.Reconcile(provider.Updates(), lb)
+	}
+	// Some providers (etcd with a config key) also carry the balancer config itself
+	if source, ok := provider.(discovery.ConfigSource); ok && source.Configs() != nil {
+		go reconcileConfig(source.Configs(), applyFrom("discovery"))
+	}
+
+	// Reload backends, strategy and health check timings when the file changes,
+	// on SIGHUP or on demand through the admin API
+	var reload func() error
+	if *watchConfig && *configPath == "" {
+		log.Fatal("-watch-config requires -config")
+	}
+	if *configPath != "" {
+		watcher := config.NewWatcher(*configPath, cfg,
+			func() (*config.Config, error) { return loadConfig(*configPath) },
+			applyFrom(configSource))
+		if profile := configProfile(); profile != "" {
+			watcher.Also(config.ProfilePath(*configPath, profile))
+		}
+		reload = watcher.Reload
+		if remoteConfig != nil {
+			reload = func() error {
+				if _, err := remoteConfig.Fetch(ctx); err != nil {
+					return err
+				}
+				return watcher.Reload()
+			}
+		}
+		if *watchConfig && remoteConfig != nil {
+			go remoteConfig.Poll(ctx, *configPoll, func() { watcher.Reload() })
+		} else if *watchConfig {
+			go func() {
+				if err := watcher.Run(ctx); err != nil {
+					logging.Logger().Error("config watcher stopped", "error", err)
+				}
+			}()
+		}
+		go reloadOnHangup(ctx, reload)
+	}
+
+	// Push metrics for short-lived or NAT-ed deployments
+	pushConfig := cfg.Metrics.Push
+	if pushConfig.Instance == "" {
+		pushConfig.Instance, _ = os.Hostname()
+	}
+	pusher, err := metrics.NewPusher(lb.MetricsRegistry(), pushConfig)
+	if err != nil {
+		log.Fatalf("Failed to configure metrics push: %v", err)
+	}
+	if pusher != nil {
+		go pusher.Run(ctx)
+	}
+
+	// Access log sink
+	accessSink, err := accesslog.NewSink(cfg.AccessLog)
+	if err != nil {
+		log.Fatalf("Failed to create access log sink: %v", err)
+	}
+	if accessSink != nil {
+		defer accessSink.Close()
+	}
+
+	// Debug tap for capturing requests on demand
+	tap := debugtap.New()
+	tap.SetOutputDir(*debugTapDir)
+
 	// Create HTTP server with middleware
 	mux := http.NewServeMux()
-	mux.Handle("/", lb)
+	tapFlag := flags.Register(features.DebugTap, "Capture requests armed through /debug/tap", true)
+	var front http.Handler = lb
+	if routes != nil {
+		front = routes
+	} else if blueGreen != nil {
+		front = blueGreen
+	}
+	mux.Handle("/", features.Gate(tapFlag, tap.Middleware)(front))
 	mux.Handle("/stats", lb.HandleStats())
-	mux.HandleFunc("/health", healthHandler)
+	mux.Handle("/version", version.Handler())
+	mux.Handle("/health", healthHandler(lb))
+	mux.Handle("/livez", lb.HandleLivez())
+	mux.Handle("/readyz", lb.HandleReadyz())
+	if *metricsFlag {
+		mux.Handle("/metrics", lb.HandleMetrics())
+	}
+
+	// Sockets passed by systemd socket activation replace the configured ports
+	mainListener, adminListener, err := systemdListeners()
+	if err != nil {
+		log.Fatalf("Failed to use systemd sockets: %v", err)
+	}
+
+	// Admin API, on its own listener when admin.port is set (or systemd
+	// passed an "admin" socket)
+	var adminServer *http.Server
+	if adminListener != nil && cfg.Admin.Token == "" {
+		logging.Logger().Warn("ignoring the systemd admin socket because no admin token is set")
+		adminListener.Close()
+		adminListener = nil
+	}
+	if cfg.Admin.Token != "" {
+		api := admin.New(admin.Options{
+			LoadBalancer: lb,
+			Token:        cfg.Admin.Token,
+			Audit:        auditLog,
+			NewStrategy:  strategy.New,
+			Reload:       reload,
+			Features:     flags,
+			Schedules:    schedules,
+			Chaos:        injector,
+			BlueGreen:    blueGreen,
+		})
+		api.Handle("/debug/tap", tap.Handler())
+		api.Handle("/admin/audit", auditLog.Handler())
+		api.Handle("/admin/stats/reset", lb.HandleResetStats())
+		api.Handle("/admin/stats/top", lb.HandleTopStats())
+		api.Handle("/admin/config/", history.Handler(apply))
+		if members != nil {
+			api.Handle("GET /admin/cluster", clusterHandler(members))
+		}
+		if cfg.Admin.Port != 0 || adminListener != nil {
+			adminServer = &http.Server{
+				Addr:              fmt.Sprintf(":%d", cfg.Admin.Port),
+				Handler:           middleware.Chain(api.Handler(), middleware.RequestID, middleware.Logger, middleware.Recovery),
+				ReadHeaderTimeout: cfg.Server.ReadTimeout,
+			}
+			if adminListener == nil {
+				if adminListener, err = net.Listen("tcp", adminServer.Addr); err != nil {
+					log.Fatalf("Admin server error: %v", err)
+				}
+			}
+			go func() {
+				if err := adminServer.Serve(adminListener); err != nil && err != http.ErrServerClosed {
+					log.Fatalf("Admin server error: %v", err)
+				}
+			}()
+		} else {
+			mux.Handle("/admin/", api.Handler())
+			mux.Handle("/debug/tap", api.Handler())
+		}
+	}
 
 	// Apply middleware
-	handler := middleware.Chain(
-		mux,
-		middleware.Logger,
-		middleware.Recovery,
-		middleware.CORS,
-	)
+	cors := features.Gate(flags.Register(features.CORS, "Add CORS headers", true), middleware.CORS)
+	chain := func(h http.Handler, cors func(http.Handler) http.Handler) http.Handler {
+		return middleware.Chain(
+			h,
+			middleware.RequestID,
+			features.Gate(flags.Register(features.AccessLog, "Write access log entries", true), accesslog.Middleware(accessSink)),
+			middleware.Logger,
+			middleware.Recovery,
+			cors,
+		)
+	}
+	wrap := func(h http.Handler) http.Handler { return chain(h, cors) }
+	handler := wrap(mux)
+	if routes != nil {
+		// Routes may override the CORS setting
+		handler = chain(mux, routes.CORS(cors))
+	}
+	applyFlagConfig(flags, nil, cfg.Features)
 
+	var tlsConfig *tls.Config
+	scheme := "http"
+	if cfg.Server.TLS.Enabled() {
+		if tlsConfig, err = cfg.Server.TLS.Build(); err != nil {
+			log.Fatalf("Invalid server TLS settings: %v", err)
+		}
+		scheme = "https"
+	}
 	server := &http.Server{
-		Addr:         fmt.Sprintf(":%d", *port),
+		Addr:         fmt.Sprintf(":%d", cfg.Server.Port),
 		Handler:      handler,
-		ReadTimeout:  15 * time.Second,
-		WriteTimeout: 15 * time.Second,
-		IdleTimeout:  60 * time.Second,
+		ReadTimeout:  cfg.Server.ReadTimeout,
+		WriteTimeout: cfg.Server.WriteTimeout,
+		IdleTimeout:  cfg.Server.IdleTimeout,
+		TLSConfig:    tlsConfig,
+		Protocols:    serverProtocols(cfg),
+	}
+	if mainListener == nil {
+		if mainListener, err = net.Listen("tcp", server.Addr); err != nil {
+			log.Fatalf("Server error: %v", err)
+		}
+	}
+	instanceServers, err := startInstances(ctx, cfg, instances, auditLog, flags, wrap, tlsConfig)
+	if err != nil {
+		log.Fatalf("Failed to start instances: %v", err)
 	}
 
 	// Start server in goroutine
@@ -96,15 +545,64 @@ func main() {
 		log.Printf("╔════════════════════════════════════════╗")
 		log.Printf("║   Go Load Balancer                     ║")
 		log.Printf("╚════════════════════════════════════════╝")
-		log.Printf("Port:          %d", *port)
+		log.Printf("Version:       %s", version.Get())
+		log.Printf("Listen:        %s (%s)", mainListener.Addr(), scheme)
+		if *configPath != "" {
+			source := *configPath
+			if profile := configProfile(); profile != "" {
+				source += " + " + config.ProfilePath(*configPath, profile)
+			}
+			if *watchConfig {
+				log.Printf("Config:        %s (watching)", source)
+			} else {
+				log.Printf("Config:        %s", source)
+			}
+		} else if len(envSettings) > 0 {
+			log.Printf("Config:        environment (%d variable(s))", len(envSettings))
+		}
 		log.Printf("Strategy:      %s", strat.Name())
 		log.Printf("Backends:      %d", len(backendURLs))
-		log.Printf("Health Check:  %v", *healthInterval)
+		log.Printf("Health Check:  %v", cfg.HealthCheck.Interval)
+		if cfg.Sticky.Enabled() {
+			log.Printf("Sticky:        %s", cfg.Sticky.Store)
+		}
+		if len(cfg.Schedules) > 0 {
+			log.Printf("Schedules:     %d", len(cfg.Schedules))
+		}
+		for _, route := range cfg.Routes {
+			log.Printf("Route:         %s%s -> %s", route.Match.Host, cmp.Or(route.Match.PathPrefix, "/"), routeTarget(cfg, route))
+		}
+		if cfg.BlueGreen.Enabled() {
+			log.Printf("Blue/green:    %s active (pools %s)", cfg.BlueGreen.ActivePool(), strings.Join(cfg.BlueGreen.Pools, ", "))
+		}
+		for _, inst := range cfg.Instances {
+			log.Printf("Instance:      %s on :%d (pool %s)", inst.Name, inst.Port, inst.Pool)
+		}
+		if members != nil {
+			log.Printf("Cluster:       %s (%d seed peer(s))", members.Addr(), len(cfg.Cluster.Peers))
+		}
 		log.Printf("")
 		log.Printf("Endpoints:")
-		log.Printf("  - Load Balancer: http://localhost:%d/", *port)
-		log.Printf("  - Statistics:    http://localhost:%d/stats", *port)
-		log.Printf("  - Health:        http://localhost:%d/health", *port)
+		listenPort := portOf(mainListener, cfg.Server.Port)
+		log.Printf("  - Load Balancer: %s://localhost:%d/", scheme, listenPort)
+		log.Printf("  - Statistics:    %s://localhost:%d/stats", scheme, listenPort)
+		log.Printf("  - Health:        %s://localhost:%d/health", scheme, listenPort)
+		log.Printf("  - Probes:        %s://localhost:%d/livez, /readyz", scheme, listenPort)
+		log.Printf("  - Version:       %s://localhost:%d/version", scheme, listenPort)
+		if *metricsFlag {
+			log.Printf("  - Metrics:       %s://localhost:%d/metrics", scheme, listenPort)
+		}
+		if cfg.Admin.Token != "" {
+			adminPort := listenPort
+			if adminListener != nil {
+				adminPort = portOf(adminListener, cfg.Admin.Port)
+			}
+			log.Printf("  - Admin API:     http://localhost:%d/admin/backends", adminPort)
+			log.Printf("  - Debug Tap:     http://localhost:%d/debug/tap", adminPort)
+			log.Printf("  - Audit Log:     http://localhost:%d/admin/audit", adminPort)
+			log.Printf("  - Top talkers:   http://localhost:%d/admin/stats/top", adminPort)
+			log.Printf("  - Config:        http://localhost:%d/admin/config/versions", adminPort)
+		}
 		log.Printf("")
 		log.Printf("Backends:")
 		for i, url := range backendURLs {
@@ -112,17 +610,56 @@ func main() {
 		}
 		log.Printf("════════════════════════════════════════")
 
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		serve := server.Serve
+		if tlsConfig != nil {
+			serve = func(l net.Listener) error { return server.ServeTLS(l, "", "") }
+		}
+		if err := serve(mainListener); err != nil && err != http.ErrServerClosed {
 			log.Fatalf("Server error: %v", err)
 		}
 	}()
 
-	// Wait for interrupt signal
+	// Listeners are bound: tell systemd (Type=notify) we're up
+	if _, err := systemd.Notify(systemd.Ready); err != nil {
+		logging.Logger().Warn("systemd notification failed", "error", err)
+	}
+
+	// Wait for interrupt signal (or the Windows service being stopped)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
-	<-quit
+	select {
+	case <-quit:
+	case <-serviceStop:
+	}
 
-	log.Println("\nShutting down server...")
+	logging.Logger().Info("shutting down server")
+	systemd.Notify(systemd.Stopping)
+
+	// Stop routing new requests and let in-flight ones finish before
+	// closing connections
+	drainCtx, drainCancel := context.WithTimeout(context.Background(), cfg.Server.DrainTimeout)
+	instancesDrained := make(chan error, 1)
+	go func() { instancesDrained <- instances.Drain(drainCtx) }()
+	routedDrained := make(chan error, 1)
+	go func() { routedDrained <- routed.Drain(drainCtx) }()
+	if err := lb.Drain(drainCtx); err != nil {
+		logging.Logger().Warn("drain incomplete", "error", err)
+	}
+	if err := <-instancesDrained; err != nil {
+		logging.Logger().Warn("instance drain incomplete", "error", err)
+	}
+	if err := <-routedDrained; err != nil {
+		logging.Logger().Warn("route pool drain incomplete", "error", err)
+	}
+	drainCancel()
+
+	if *stateFile != "" {
+		if err := balancer.SaveState(*stateFile, lb.State()); err != nil {
+			logging.Logger().Error("failed to save state", "error", err)
+		} else {
+			logging.Logger().Info("state saved", "path", *stateFile)
+		}
+	}
 
 	// Graceful shutdown
 	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), 30*time.Second)
@@ -131,8 +668,514 @@ func main() {
 	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
 	}
+	for i, instanceServer := range instanceServers {
+		if err := instanceServer.Shutdown(shutdownCtx); err != nil {
+			logging.Logger().Warn("instance server forced to shutdown", "instance", cfg.Instances[i].Name, "error", err)
+		}
+	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			logging.Logger().Warn("admin server forced to shutdown", "error", err)
+		}
+	}
+
+	// Push final metric values so the last interval isn't lost
+	if pusher != nil {
+		if err := pusher.Push(shutdownCtx); err != nil {
+			logging.Logger().Warn("final metrics push failed", "error", err)
+		}
+	}
+
+	// Stop health checking before the notifier so no state change is sent
+	// to it while it shuts down
+	cancel()
+	notifier.Close()
+
+	logging.Logger().Info("server exited gracefully")
+}
+
+// runDashboard implements the "dashboard" subcommand, printing a Grafana dashboard
+func runDashboard(args []string) {
+	fs := flag.NewFlagSet("dashboard", flag.ExitOnError)
+	defaults := dashboard.DefaultOptions()
+	title := fs.String("title", defaults.Title, "Dashboard title")
+	uid := fs.String("uid", defaults.UID, "Dashboard UID")
+	datasource := fs.String("datasource", "", "Prometheus datasource UID (prompted on import when empty)")
+	out := fs.String("out", "", "Write the dashboard to this file instead of stdout")
+	fs.Parse(args)
+
+	data, err := dashboard.Generate(dashboard.Options{
+		Title:      *title,
+		UID:        *uid,
+		Datasource: *datasource,
+		Refresh:    defaults.Refresh,
+	})
+	if err != nil {
+		log.Fatalf("Failed to generate dashboard: %v", err)
+	}
+	data = append(data, '\n')
+
+	if *out == "" {
+		os.Stdout.Write(data)
+		return
+	}
+	if err := os.WriteFile(*out, data, 0o644); err != nil {
+		log.Fatalf("Failed to write dashboard: %v", err)
+	}
+	log.Printf("Dashboard written to %s", *out)
+}
+
+// systemdListeners returns the sockets passed by systemd socket activation:
+// the one named "admin" serves the admin API, any other the load balancer
+func systemdListeners() (main, admin net.Listener, err error) {
+	activated, err := systemd.Listeners()
+	if err != nil {
+		return nil, nil, err
+	}
+	for name, listeners := range activated {
+		for _, l := range listeners {
+			switch {
+			case name == "admin" && admin == nil:
+				admin = l
+			case name != "admin" && main == nil:
+				main = l
+			default:
+				logging.Logger().Warn("ignoring extra systemd socket", "name", name, "addr", l.Addr().String())
+				l.Close()
+			}
+		}
+	}
+	if admin != nil && main == nil {
+		admin.Close()
+		return nil, nil, fmt.Errorf("systemd passed an admin socket but no load balancer socket")
+	}
+	return main, admin, nil
+}
+
+// portOf returns the TCP port l listens on, or fallback
+func portOf(l net.Listener, fallback int) int {
+	if addr, ok := l.Addr().(*net.TCPAddr); ok {
+		return addr.Port
+	}
+	return fallback
+}
+
+// configProfile returns the selected profile: -profile, else $GO_BALANCER_PROFILE
+func configProfile() string {
+	if *profileFlag != "" {
+		return *profileFlag
+	}
+	return os.Getenv(config.ProfileEnv)
+}
+
+// remoteConfig is the source of a -config URL
+var remoteConfig *config.Remote
+
+// tracer records the spans of every load balancer (nil when tracing is off)
+var tracer *tracing.Tracer
+
+// notifier posts the backend state changes of every load balancer (nil
+// without webhooks)
+var notifier *notify.Notifier
+
+// openRemoteConfig performs the initial fetch of a -config URL
+func openRemoteConfig(url string) error {
+	publicKey, err := config.ResolveSecret(*configKey)
+	if err != nil {
+		return fmt.Errorf("-config-public-key: %w", err)
+	}
+	secret, err := config.ResolveSecret(*configSecret)
+	if err != nil {
+		return fmt.Errorf("-config-hmac-secret: %w", err)
+	}
+	remoteConfig, err = config.NewRemote(url, config.RemoteOptions{PublicKey: publicKey, HMACSecret: secret})
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+	_, err = remoteConfig.Fetch(ctx)
+	return err
+}
+
+// loadStartupConfig fetches (and verifies) a -config URL first, then loads
+// the config with flag overrides
+func loadStartupConfig() (*config.Config, error) {
+	if config.IsRemote(*configPath) {
+		if configProfile() != "" {
+			return nil, fmt.Errorf("config profiles apply to config files only; serve the rendered profile from the -config URL instead")
+		}
+		if err := openRemoteConfig(*configPath); err != nil {
+			return nil, err
+		}
+	}
+	return loadConfig(*configPath)
+}
+
+// envSettings are the GO_BALANCER_* variables applied by the last loadConfig
+var envSettings []string
+
+// loadConfig reads the config file or the last fetched remote config
+// (flag defaults when path is empty), then applies GO_BALANCER_* variables
+// and finally explicitly set flags
+func loadConfig(path string) (*config.Config, error) {
+	cfg := config.DefaultConfig()
+	switch {
+	case remoteConfig != nil:
+		loaded, err := config.Parse(remoteConfig.Body())
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	case path != "":
+		loaded, err := config.LoadProfile(path, configProfile())
+		if err != nil {
+			return nil, err
+		}
+		cfg = loaded
+	}
+
+	set := make(map[string]bool)
+	flag.Visit(func(f *flag.Flag) { set[f.Name] = true })
+	if path == "" {
+		if err := applyFlags(cfg, set, func(string) bool { return true }); err != nil {
+			return nil, err
+		}
+	}
+	applied, err := cfg.ApplyEnv(os.LookupEnv)
+	if err != nil {
+		return nil, err
+	}
+	envSettings = applied
+	if err := applyFlags(cfg, set, func(name string) bool { return set[name] }); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// applyFlags copies the flags selected by override into cfg; set holds the
+// explicitly set flags
+func applyFlags(cfg *config.Config, set map[string]bool, override func(name string) bool) error {
+	if override("port") {
+		cfg.Server.Port = *port
+	}
+	if override("drain-timeout") {
+		cfg.Server.DrainTimeout = *drainTimeout
+	}
+	if override("tls-cert") {
+		cfg.Server.TLS.CertFile = *tlsCert
+	}
+	if override("tls-key") {
+		cfg.Server.TLS.KeyFile = *tlsKey
+	}
+	if override("tls-min-version") {
+		cfg.Server.TLS.MinVersion = *tlsMinVersion
+	}
+	if set["h2c"] {
+		cfg.Server.H2C = *h2c
+		cfg.Transport.H2C = h2c
+	}
+	if override("backends") {
+		cfg.Backends = cfg.Backends[:0]
+		for _, u := range parseBackendURLs(*backendsFlag) {
+			cfg.Backends = append(cfg.Backends, config.BackendConfig{URL: u, Weight: 1})
+		}
+	}
+	if set["backends-file"] {
+		cfg.Discovery.Type = discovery.TypeFile
+		cfg.Discovery.File.Path = *backendsFile
+	}
+	if override("max-connections") {
+		cfg.SetMaxConnections(*maxConns)
+	}
+	if override("queue-size") {
+		cfg.Queue.MaxLength = *queueSize
+	}
+	if override("queue-timeout") {
+		cfg.Queue.Timeout = *queueTimeout
+	}
+	if override("strategy") {
+		cfg.Strategy.Type = *strategyFlag
+	}
+	if override("health-interval") {
+		cfg.HealthCheck.Interval = *healthInterval
+	}
+	if override("health-timeout") {
+		cfg.HealthCheck.Timeout = *healthTimeout
+	}
+	if override("access-log") || set["access-log-target"] {
+		sink := cfg.AccessLog.Sink
+		if override("access-log") {
+			sink = *accessLogSink
+		}
+		format := cfg.AccessLog.Format
+		cfg.AccessLog = accessLogConfig(sink, *accessLogDest)
+		cfg.AccessLog.Format = format
+	}
+	if override("access-log-format") {
+		cfg.AccessLog.Format = *accessLogFmt
+	}
+	if override("push-mode") {
+		cfg.Metrics.Push.Mode = *pushMode
+	}
+	if override("push-url") {
+		cfg.Metrics.Push.URL = *pushURL
+	}
+	if override("push-interval") {
+		cfg.Metrics.Push.Interval = *pushInterval
+	}
+	if override("push-job") {
+		cfg.Metrics.Push.Job = *pushJob
+	}
+	if set["admin-port"] {
+		cfg.Admin.Port = *adminPort
+	}
+	if override("cluster-bind") {
+		cfg.Cluster.Bind = *clusterBind
+	}
+	if override("cluster-peers") {
+		cfg.Cluster.Peers = parseBackendURLs(*clusterPeers)
+	}
+	if override("cluster-secret") {
+		secret, err := config.ResolveSecret(*clusterSecret)
+		if err != nil {
+			return fmt.Errorf("-cluster-secret: %w", err)
+		}
+		cfg.Cluster.Secret = secret
+	}
+	if override("sticky") {
+		cfg.Sticky.Store = *stickyStore
+	}
+	if set["sticky-redis"] {
+		cfg.Sticky.Redis.Addr = *stickyRedis
+	}
+	if override("chaos") {
+		cfg.Chaos.Enabled = *chaosEnabled
+	}
+	if override("admin-token") {
+		token, err := config.ResolveSecret(*adminToken)
+		if err != nil {
+			return fmt.Errorf("-admin-token: %w", err)
+		}
+		cfg.Admin.Token = token
+	}
+
+	return nil
+}
+
+// reloadOnHangup reloads the config whenever the process receives SIGHUP,
+// reporting the reload to systemd
+func reloadOnHangup(ctx context.Context, reload func() error) {
+	hup := make(chan os.Signal, 1)
+	signal.Notify(hup, syscall.SIGHUP)
+	defer signal.Stop(hup)
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-hup:
+			logging.Logger().Info("SIGHUP received, reloading config")
+			systemd.Notify(systemd.Reloading)
+			// Failures are logged by the reload and the last good config stays
+			reload()
+			systemd.Notify(systemd.Ready)
+		}
+	}
+}
+
+// reloader returns the watcher callback applying a new config to lb and the
+// instances; settings that only take effect at startup are reported instead
+// of applied
+func reloader(lb *balancer.LoadBalancer, instances *balancer.Group, routes *router.Router, routed *balancer.Group, blueGreen *router.Switch, flags *features.Registry, schedules *schedule.Scheduler, injector *chaos.Injector, initial *config.Config) func(*config.Config) error {
+	active := initial
+	return func(next *config.Config) error {
+		strat, err := strategy.New(primaryStrategy(next))
+		if err != nil {
+			return err
+		}
+		// Routes to pools without a load balancer fail before anything changes
+		var nextRoutes []router.Route
+		if routes != nil {
+			if nextRoutes, err = buildRoutes(next, lb, routed, blueGreen); err != nil {
+				return err
+			}
+		}
+		if !next.Discovering() {
+			if err := lb.SetBackends(backendConfigsOf(next)); err != nil {
+				return err
+			}
+		}
+		if !strings.EqualFold(primaryStrategy(next), primaryStrategy(active)) {
+			lb.SetStrategy(strat)
+		}
+		lb.SetSubset(primarySubset(next))
+		lb.SetHealthCheck(next.HealthCheck.Interval, next.HealthCheck.Timeout)
+		lb.SetHealthCheckConfig(next.HealthCheck.Probe())
+		lb.SetQueue(next.Queue)
+		if err := backend.SetTrustedProxies(next.TrustedProxies); err != nil {
+			return err
+		}
+		errorPolicy, err := primaryErrorPolicy(next)
+		if err != nil {
+			return err
+		}
+		lb.SetErrorPolicy(errorPolicy)
+		if next.Discovering() {
+			lb.SetHealthPolicy(next.Discovery.RequireHealthy(), next.Discovery.GracePeriod)
+		}
+		if err := reloadInstances(instances, active, next); err != nil {
+			return err
+		}
+		if err := reloadPools(routed, active, next); err != nil {
+			return err
+		}
+		if routes != nil {
+			if err := routes.SetRoutes(nextRoutes); err != nil {
+				return err
+			}
+		} else if len(next.Routes) > 0 {
+			logging.Logger().Warn("routes require a restart when the balancer was started without any")
+		}
+		// Like feature flags, a switch made through the admin API holds until
+		// the configured active pool changes
+		if blueGreen != nil && slices.Equal(next.BlueGreen.Pools, initial.BlueGreen.Pools) &&
+			next.BlueGreen.ActivePool() != active.BlueGreen.ActivePool() {
+			if _, err := blueGreen.Set(next.BlueGreen.ActivePool(), audit.SystemActor); err != nil {
+				return err
+			}
+		}
+		applyFlagConfig(flags, active.Features, next.Features)
+		schedules.SetConfigured(next.Schedules)
+		if injector != nil && !reflect.DeepEqual(next.Chaos.Rules, active.Chaos.Rules) {
+			if err := injector.SetRules(next.Chaos.Rules, audit.SystemActor); err != nil {
+				return err
+			}
+		}
+
+		if !reflect.DeepEqual(next.Server, initial.Server) ||
+			!reflect.DeepEqual(next.AccessLog, initial.AccessLog) ||
+			!reflect.DeepEqual(next.Metrics, initial.Metrics) ||
+			!reflect.DeepEqual(next.Cluster, initial.Cluster) ||
+			!reflect.DeepEqual(next.Tracing, initial.Tracing) ||
+			!reflect.DeepEqual(next.Notifications, initial.Notifications) ||
+			next.Sticky != initial.Sticky ||
+			next.Chaos.Enabled != initial.Chaos.Enabled ||
+			next.Admin != initial.Admin ||
+			!reflect.DeepEqual(next.Transport, initial.Transport) ||
+			!reflect.DeepEqual(next.Instances, initial.Instances) ||
+			!slices.Equal(next.BlueGreen.Pools, initial.BlueGreen.Pools) ||
+			!reflect.DeepEqual(providerSettings(next.Discovery), providerSettings(initial.Discovery)) {
+			logging.Logger().Warn("server, transport, accessLog, metrics, tracing, notifications, admin, cluster, sticky, chaos.enabled, discovery, instances (name, port, pool) and blueGreen.pools changes require a restart to take effect")
+		}
+		lb.ConfigReloaded()
+		for _, name := range instances.Names() {
+			instances.Get(name).ConfigReloaded()
+		}
+		for _, name := range routed.Names() {
+			routed.Get(name).ConfigReloaded()
+		}
+		active = next
+		return nil
+	}
+}
 
-	log.Println("Server exited gracefully")
+// applyFlagConfig applies the feature flags of a config that differ from the
+// previously applied ones, so flags switched through the admin API keep
+// their state across unrelated reloads
+func applyFlagConfig(flags *features.Registry, previous, next map[string]bool) {
+	for name, enabled := range next {
+		if was, ok := previous[name]; ok && was == enabled {
+			continue
+		}
+		if err := flags.Set(name, enabled, audit.SystemActor); err != nil {
+			logging.Logger().Warn("ignoring feature flag", "feature", name, "error", err)
+		}
+	}
+}
+
+// providerSettings returns the discovery settings that only apply at startup
+func providerSettings(cfg discovery.Config) discovery.Config {
+	cfg.HealthPolicy = ""
+	cfg.GracePeriod = 0
+	return cfg
+}
+
+// startDiscovery starts the configured provider and waits briefly for its
+// first answer, falling back to the static backends
+func startDiscovery(ctx context.Context, cfg discovery.Config, static []config.BackendConfig) (discovery.Provider, []config.BackendConfig, error) {
+	provider, err := discovery.New(cfg)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := provider.Start(ctx); err != nil {
+		return nil, nil, err
+	}
+
+	select {
+	case initial, ok := <-provider.Updates():
+		if ok {
+			return provider, initial, nil
+		}
+	case <-time.After(10 * time.Second):
+		logging.Logger().Warn("no discovery answer yet, starting with the static backends", "backends", len(static))
+	}
+	return provider, static, nil
+}
+
+// reconcileConfig parses, validates and applies every config pushed by a
+// provider; invalid configs are logged and the active config is kept
+func reconcileConfig(configs <-chan []byte, apply func(*config.Config) error) {
+	for data := range configs {
+		next, err := config.Parse(data)
+		if err == nil {
+			err = next.Validate()
+		}
+		if err == nil {
+			err = apply(next)
+		}
+		if err != nil {
+			logging.Logger().Error("rejected config from provider", "error", err)
+			continue
+		}
+		logging.Logger().Info("applied config from provider")
+	}
+}
+
+// backendConfigsOf returns the backends of the pool serving catch-all traffic
+// primaryErrorPolicy builds the error policy of the primary pool
+func primaryErrorPolicy(cfg *config.Config) (backend.ErrorPolicy, error) {
+	pool, _ := cfg.PrimaryPool()
+	return cfg.PoolErrorPage(pool.Name).Policy()
+}
+
+func backendConfigsOf(cfg *config.Config) []config.BackendConfig {
+	pool, _ := cfg.PrimaryPool()
+	return pool.Backends
+}
+
+func backendURLsOf(cfg *config.Config) []string {
+	backends := backendConfigsOf(cfg)
+	urls := make([]string, 0, len(backends))
+	for _, b := range backends {
+		urls = append(urls, b.URL)
+	}
+	return urls
+}
+
+// primarySubset returns the subset of the pool serving catch-all traffic
+func primarySubset(cfg *config.Config) backend.Subset {
+	if pool, ok := cfg.PrimaryPool(); ok {
+		return pool.Strategy.Subset
+	}
+	return cfg.Strategy.Subset
+}
+
+// primaryStrategy returns the strategy of the pool serving catch-all traffic
+func primaryStrategy(cfg *config.Config) string {
+	if pool, ok := cfg.PrimaryPool(); ok {
+		return pool.Strategy.Type
+	}
+	return cfg.Strategy.Type
 }
 
 func parseBackendURLs(backends string) []string {
@@ -153,8 +1196,50 @@ func parseBackendURLs(backends string) []string {
 	return result
 }
 
-func healthHandler(w http.ResponseWriter, r *http.Request) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(http.StatusOK)
-	fmt.Fprintf(w, `{"status":"healthy","timestamp":"%s"}`, time.Now().Format(time.RFC3339))
+func accessLogConfig(sink, target string) accesslog.Config {
+	cfg := accesslog.Config{Sink: sink}
+	switch strings.ToLower(sink) {
+	case accesslog.SinkFile:
+		cfg.Path = target
+		cfg.MaxSizeMB = 100
+		cfg.MaxBackups = 5
+	case accesslog.SinkSyslog:
+		if target != "" {
+			cfg.SyslogNetwork = "udp"
+			cfg.SyslogAddress = target
+		}
+	case accesslog.SinkHTTP:
+		cfg.URL = target
+	}
+	return cfg
+}
+
+// clusterHandler lists cluster members and the shared backend observations
+func clusterHandler(c *cluster.Cluster) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		encoder.Encode(map[string]interface{}{
+			"node":         c.Addr(),
+			"members":      c.Members(),
+			"observations": c.Observations(),
+		})
+	})
+}
+
+// healthHandler reports whether the load balancer accepts traffic; it fails
+// once shutdown starts draining
+func healthHandler(lb *balancer.LoadBalancer) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if lb.IsDraining() {
+			// Fail readiness so upstream load balancers stop routing here
+			w.WriteHeader(http.StatusServiceUnavailable)
+			fmt.Fprintf(w, `{"status":"draining","timestamp":"%s"}`, time.Now().Format(time.RFC3339))
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintf(w, `{"status":"healthy","timestamp":"%s"}`, time.Now().Format(time.RFC3339))
+	})
 }
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"flag"
+	"log"
+
+	"github.com/TaiTitans/go-balancer/mockbackend"
+)
+
+// runMockBackend implements the "mock-backend" subcommand, serving a test
+// backend with simulated latency and errors
+func runMockBackend(args []string) {
+	fs := flag.NewFlagSet("mock-backend", flag.ExitOnError)
+	port := fs.Int("port", 8081, "Port to listen on")
+	name := fs.String("name", "", "Server name (Backend-<port> when empty)")
+	latency := fs.Duration("latency", 0, "Latency added to every response")
+	jitter := fs.Duration("jitter", 0, "Random variation of the latency (±)")
+	errorRate := fs.Float64("error-rate", 0, "Share of requests (0-1) answered with -error-status")
+	errorStatus := fs.Int("error-status", 500, "Status code of simulated errors")
+	size := fs.Int("size", 0, "Pad response bodies to this many bytes")
+	quiet := fs.Bool("quiet", false, "Do not log every request")
+	resetRate := fs.Float64("reset-rate", 0, "Share of requests (0-1) whose connection is reset")
+	malformedRate := fs.Float64("malformed-rate", 0, "Share of requests (0-1) answered with a malformed response")
+	flap := fs.Duration("flap", 0, "Period at which /health flips between healthy and 503 (0 = stable)")
+	fs.Parse(args)
+
+	server, err := mockbackend.New(mockbackend.Options{
+		Name:         *name,
+		Port:         *port,
+		Latency:      *latency,
+		Jitter:       *jitter,
+		ErrorRate:    *errorRate,
+		ErrorStatus:  *errorStatus,
+		ResponseSize: *size,
+		Quiet:        *quiet,
+
+		ResetRate:     *resetRate,
+		MalformedRate: *malformedRate,
+		FlapInterval:  *flap,
+	})
+	if err != nil {
+		log.Fatalf("Invalid mock backend options: %v", err)
+	}
+	if err := server.ListenAndServe(); err != nil {
+		log.Fatalf("Server failed: %v", err)
+	}
+}
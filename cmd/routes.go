@@ -0,0 +1,186 @@
+package main
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/config"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+// routeTarget names the load balancer serving a route: its pool, plus the
+// strategy and subset when the route overrides the pool's
+func routeTarget(cfg *config.Config, r config.RouteConfig) string {
+	pool, _ := cfg.Pool(r.Pool)
+	target := r.Pool
+	if r.Strategy != "" && !strings.EqualFold(r.Strategy, pool.Strategy.Type) {
+		target += "/" + strings.ToLower(r.Strategy)
+	}
+	if !r.Subset.IsZero() && !r.Subset.Equal(pool.Strategy.Subset) {
+		target += "[" + r.Subset.String()
+		if r.Subset.Fallback {
+			target += "?"
+		}
+		target += "]"
+	}
+	return target
+}
+
+// targetPool returns the pool and strategy of the load balancer serving the
+// routes of cfg with the given target, or the blue/green pool of that name;
+// the subset of a route is set as the pool's
+func targetPool(cfg *config.Config, target string) (config.PoolConfig, string, bool) {
+	if slices.Contains(cfg.BlueGreen.Pools, target) {
+		pool, ok := cfg.Pool(target)
+		return pool, pool.Strategy.Type, ok
+	}
+	for _, r := range cfg.Routes {
+		if routeTarget(cfg, r) != target {
+			continue
+		}
+		pool, ok := cfg.Pool(r.Pool)
+		strategyName := pool.Strategy.Type
+		if r.Strategy != "" {
+			strategyName = r.Strategy
+		}
+		if !r.Subset.IsZero() {
+			pool.Strategy.Subset = r.Subset
+		}
+		return pool, strategyName, ok
+	}
+	return config.PoolConfig{}, "", false
+}
+
+// startBlueGreen creates a load balancer in pools for the blue/green pool
+// the primary load balancer doesn't serve, and returns the switch between
+// the two
+func startBlueGreen(cfg *config.Config, lb *balancer.LoadBalancer, pools *balancer.Group, auditLog *audit.Log, flags *features.Registry) (*router.Switch, error) {
+	primary, _ := cfg.PrimaryPool()
+	targets := make(map[string]http.Handler, len(cfg.BlueGreen.Pools))
+	for _, name := range cfg.BlueGreen.Pools {
+		if name == primary.Name {
+			targets[name] = lb
+			continue
+		}
+		if poolLB := pools.Get(name); poolLB != nil {
+			targets[name] = poolLB
+			continue
+		}
+		pool, strategyName, _ := targetPool(cfg, name)
+		poolLB, err := newPoolBalancer(cfg, pool, strategyName, auditLog, flags)
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: %w", name, err)
+		}
+		if err := pools.Add(name, poolLB); err != nil {
+			return nil, err
+		}
+		targets[name] = poolLB
+	}
+	return router.NewSwitch(targets, cfg.BlueGreen.ActivePool(), auditLog)
+}
+
+// startRoutes creates a load balancer in pools for every route target the
+// primary load balancer doesn't serve, and returns the router sending each
+// request to the load balancer of its route, or to the blue/green switch
+// (optional) for routes to its pools
+func startRoutes(cfg *config.Config, lb *balancer.LoadBalancer, pools *balancer.Group, blueGreen *router.Switch, auditLog *audit.Log, flags *features.Registry) (*router.Router, error) {
+	primary, _ := cfg.PrimaryPool()
+	for _, r := range cfg.Routes {
+		target := routeTarget(cfg, r)
+		if target == primary.Name || pools.Get(target) != nil {
+			continue
+		}
+		pool, strategyName, _ := targetPool(cfg, target)
+		poolLB, err := newPoolBalancer(cfg, pool, strategyName, auditLog, flags)
+		if err != nil {
+			return nil, fmt.Errorf("pool %s: %w", target, err)
+		}
+		if err := pools.Add(target, poolLB); err != nil {
+			return nil, err
+		}
+	}
+	routes, err := buildRoutes(cfg, lb, pools, blueGreen)
+	if err != nil {
+		return nil, err
+	}
+	return router.New(routes)
+}
+
+// buildRoutes returns the routes of cfg, served by the blue/green switch
+// (optional), the primary load balancer or the load balancers in pools
+func buildRoutes(cfg *config.Config, lb *balancer.LoadBalancer, pools *balancer.Group, blueGreen *router.Switch) ([]router.Route, error) {
+	primary, _ := cfg.PrimaryPool()
+	routes := make([]router.Route, 0, len(cfg.Routes))
+	for i, r := range cfg.Routes {
+		name := r.Name
+		if name == "" {
+			name = fmt.Sprintf("routes[%d]", i)
+		}
+		route := router.Route{
+			Name:        name,
+			Host:        r.Match.Host,
+			PathPrefix:  r.Match.PathPrefix,
+			Methods:     r.Match.Methods,
+			Headers:     r.Match.Headers,
+			Query:       r.Match.Query,
+			StripPrefix: r.Middleware.StripPrefix,
+			Rewrite:     r.Middleware.Rewrite,
+			SetHeaders:  r.Middleware.SetHeaders,
+			Timeout:     r.Middleware.Timeout,
+			CORS:        r.Middleware.CORS,
+
+			ResponseTimeout: r.Middleware.ResponseTimeout,
+			IdleTimeout:     r.Middleware.IdleTimeout,
+		}
+		target := routeTarget(cfg, r)
+		if blueGreen != nil && slices.Contains(blueGreen.Names(), target) {
+			route.Handler = blueGreen
+		} else if target == primary.Name {
+			route.Handler = lb
+		} else if poolLB := pools.Get(target); poolLB != nil {
+			route.Handler = poolLB
+		} else {
+			return nil, fmt.Errorf("route %s: pool %s was not routed at startup and needs a restart", name, target)
+		}
+		routes = append(routes, route)
+	}
+	return routes, nil
+}
+
+// reloadPools applies the pools and strategies of a new config to the load
+// balancers of the route targets and blue/green pools
+func reloadPools(pools *balancer.Group, active, next *config.Config) error {
+	for _, target := range pools.Names() {
+		poolLB := pools.Get(target)
+		pool, strategyName, ok := targetPool(next, target)
+		if !ok {
+			// No longer routed; it keeps running idle until a restart
+			continue
+		}
+		if err := poolLB.SetBackends(pool.Backends); err != nil {
+			return fmt.Errorf("pool %s: %w", target, err)
+		}
+		poolLB.SetQueue(next.Queue)
+		errorPolicy, err := next.PoolErrorPage(pool.Name).Policy()
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", target, err)
+		}
+		poolLB.SetErrorPolicy(errorPolicy)
+		poolLB.SetSubset(pool.Strategy.Subset)
+		if _, previous, ok := targetPool(active, target); ok && strings.EqualFold(previous, strategyName) {
+			continue
+		}
+		strat, err := strategy.New(strategyName)
+		if err != nil {
+			return fmt.Errorf("pool %s: %w", target, err)
+		}
+		poolLB.SetStrategy(strat)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+
+	"github.com/TaiTitans/go-balancer/winsvc"
+)
+
+// serviceStop is closed when the Windows service control manager stops the
+// service; it stays nil (never ready) otherwise
+var serviceStop <-chan struct{}
+
+// logOutput receives JSON logs (see logging.Configure); the event log when
+// running as a Windows service
+var logOutput io.Writer = os.Stdout
+
+// runService implements the "service" subcommand managing the Windows service:
+//
+//	service install [-name N] [-- run flags]   register the service
+//	service remove|start|stop [-name N]
+//	service run [-name N] [-- run flags]       entry point used by the service manager
+func runService(args []string) {
+	if len(args) == 0 {
+		fmt.Fprintln(os.Stderr, "Usage: go-balancer service install|remove|start|stop [-name N] [-- run flags]")
+		os.Exit(2)
+	}
+	action := args[0]
+	fs := flag.NewFlagSet("service "+action, flag.ExitOnError)
+	name := fs.String("name", winsvc.DefaultName, "Service name")
+	fs.Parse(args[1:])
+
+	var err error
+	switch action {
+	case "install":
+		var exe string
+		if exe, err = os.Executable(); err == nil {
+			exe, err = filepath.Abs(exe)
+		}
+		if err == nil {
+			runArgs := append([]string{"service", "run", "-name", *name, "--"}, fs.Args()...)
+			err = winsvc.Install(*name, exe, runArgs)
+		}
+		if err == nil {
+			fmt.Printf("Service %s installed; start it with: %s service start -name %s\n", *name, filepath.Base(exe), *name)
+		}
+	case "remove":
+		if err = winsvc.Remove(*name); err == nil {
+			fmt.Printf("Service %s removed\n", *name)
+		}
+	case "start":
+		err = winsvc.Start(*name)
+	case "stop":
+		err = winsvc.Stop(*name)
+	case "run":
+		err = runAsService(*name, fs.Args())
+	default:
+		err = fmt.Errorf("unknown service action %q", action)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "service %s: %v\n", action, err)
+		os.Exit(1)
+	}
+}
+
+// runAsService runs the load balancer under the service control manager,
+// logging to the event log
+func runAsService(name string, args []string) error {
+	events, err := winsvc.EventLog(name)
+	if err != nil {
+		return err
+	}
+	defer events.Close()
+	log.SetOutput(events)
+	log.SetFlags(0)
+	logOutput = events
+
+	return winsvc.Run(name, func(stop <-chan struct{}) {
+		serviceStop = stop
+		runServer(args)
+	})
+}
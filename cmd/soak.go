@@ -0,0 +1,91 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"io"
+	"log"
+	"log/slog"
+	"os"
+	"os/signal"
+	"runtime/pprof"
+	"sort"
+	"syscall"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/soak"
+)
+
+// runSoak implements the "soak" subcommand: the balancer runs against
+// chaotic in-process backends while invariants are checked, exiting
+// non-zero if any broke. Ctrl-C ends the run early with a report.
+func runSoak(args []string) {
+	defaults := soak.DefaultBackendOptions()
+	fs := flag.NewFlagSet("soak", flag.ExitOnError)
+	duration := fs.Duration("duration", time.Hour, "How long to send load")
+	backends := fs.Int("backends", 4, "Number of mock backends")
+	concurrency := fs.Int("concurrency", 16, "Number of concurrent clients")
+	checkInterval := fs.Duration("check-interval", 10*time.Second, "How often invariants are checked and progress is logged")
+	latency := fs.Duration("latency", defaults.Latency, "Backend latency")
+	jitter := fs.Duration("jitter", defaults.Jitter, "Random variation of the backend latency (±)")
+	errorRate := fs.Float64("error-rate", defaults.ErrorRate, "Share of requests (0-1) answered with 503")
+	resetRate := fs.Float64("reset-rate", defaults.ResetRate, "Share of requests (0-1) whose connection is reset")
+	malformedRate := fs.Float64("malformed-rate", defaults.MalformedRate, "Share of requests (0-1) answered with a malformed response")
+	flap := fs.Duration("flap", defaults.FlapInterval, "Period at which backend health flips (0 = stable)")
+	verbose := fs.Bool("verbose", false, "Log backend errors")
+	fs.Parse(args)
+
+	if !*verbose {
+		logging.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	}
+	backendOpts := defaults
+	backendOpts.Latency, backendOpts.Jitter = *latency, *jitter
+	backendOpts.ErrorRate, backendOpts.ResetRate, backendOpts.MalformedRate = *errorRate, *resetRate, *malformedRate
+	backendOpts.FlapInterval = *flap
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	log.Printf("[Soak] %d backends, %d clients, for %v", *backends, *concurrency, *duration)
+	report, err := soak.Run(ctx, soak.Options{
+		Duration:      *duration,
+		Backends:      *backends,
+		Concurrency:   *concurrency,
+		CheckInterval: *checkInterval,
+		Backend:       backendOpts,
+		Progress: func(p soak.Progress) {
+			log.Printf("[Soak] %v: %d requests, %d goroutines, %d violations",
+				p.Elapsed.Round(time.Second), p.Requests, p.Goroutines, p.Violations)
+		},
+	})
+	if err != nil {
+		log.Fatalf("[Soak] Failed to start: %v", err)
+	}
+
+	log.Printf("[Soak] Done after %v: %d requests", report.Elapsed.Round(time.Second), report.Requests)
+	statuses := make([]int, 0, len(report.Statuses))
+	for status := range report.Statuses {
+		statuses = append(statuses, status)
+	}
+	sort.Ints(statuses)
+	for _, status := range statuses {
+		if status == 0 {
+			log.Printf("[Soak]   no answer: %d", report.Statuses[status])
+		} else {
+			log.Printf("[Soak]   %d: %d", status, report.Statuses[status])
+		}
+	}
+	log.Printf("[Soak] Goroutines: %d before, %d after", report.BaselineGoroutines, report.Goroutines)
+
+	if !report.OK() {
+		for _, v := range report.Violations {
+			log.Printf("[Soak] ✗ %s", v)
+		}
+		if report.Goroutines > report.BaselineGoroutines {
+			pprof.Lookup("goroutine").WriteTo(os.Stderr, 1)
+		}
+		os.Exit(1)
+	}
+	log.Printf("[Soak] ✓ All invariants held")
+}
@@ -1,10 +1,28 @@
 package config
 
 import (
+	"bytes"
 	"encoding/json"
 	"fmt"
 	"os"
 	"time"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/TaiTitans/go-balancer/accesslog"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/cluster"
+	"github.com/TaiTitans/go-balancer/discovery"
+	"github.com/TaiTitans/go-balancer/healthcheck"
+	"github.com/TaiTitans/go-balancer/internal/jsonconf"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/notify"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+	"github.com/TaiTitans/go-balancer/sticky"
+	"github.com/TaiTitans/go-balancer/tracing"
 )
 
 // Config represents the application configuration
@@ -14,6 +32,43 @@ type Config struct {
 	HealthCheck HealthCheckConfig `json:"healthCheck"`
 	Strategy    StrategyConfig    `json:"strategy"`
 	Logging     LoggingConfig     `json:"logging"`
+	AccessLog   accesslog.Config  `json:"accessLog"`
+	Metrics     MetricsConfig     `json:"metrics"`
+	Admin       AdminConfig       `json:"admin"`
+	Discovery   discovery.Config  `json:"discovery"`
+	Cluster     cluster.Config    `json:"cluster"`
+	Sticky      sticky.Config     `json:"sticky"`
+	// Features sets feature flags at startup and on reload (see features.Known)
+	Features map[string]bool `json:"features,omitempty"`
+	// Chaos injects faults for resilience testing (off unless enabled)
+	Chaos chaos.Config `json:"chaos"`
+	// Transport tunes the connection pool shared by the backends
+	Transport backend.TransportConfig `json:"transport"`
+	// Queue lets requests wait for a busy pool instead of failing at once
+	Queue balancer.QueueConfig `json:"queue"`
+	// OutlierDetection ejects backends failing or answering slower than
+	// the rest of their pool
+	OutlierDetection balancer.OutlierConfig `json:"outlierDetection"`
+	// Schedules shift backend weights over time (see schedule.Config)
+	Schedules []schedule.Config `json:"schedules,omitempty"`
+	// Pools and Routes describe multi-pool setups; the flat Backends list (or
+	// the discovered backends) is the implicit "default" pool
+	Pools  []PoolConfig  `json:"pools,omitempty"`
+	Routes []RouteConfig `json:"routes,omitempty"`
+	// Instances run additional load balancers in the same process, each
+	// serving one pool on its own port
+	Instances []InstanceConfig `json:"instances,omitempty"`
+	// TrustedProxies lists the CIDRs of proxies in front of the balancer
+	// whose X-Forwarded-* and Forwarded headers are kept
+	TrustedProxies []string `json:"trustedProxies,omitempty"`
+	// ErrorPage replaces the 502 Bad Gateway answer to failed proxy attempts
+	ErrorPage backend.ErrorPage `json:"errorPage"`
+	// BlueGreen switches all new traffic between two pools at once
+	BlueGreen BlueGreenConfig `json:"blueGreen"`
+	// Tracing records a span per request and exports it over OTLP
+	Tracing tracing.Config `json:"tracing"`
+	// Notifications are webhooks told when a backend goes down or recovers
+	Notifications []notify.Config `json:"notifications,omitempty"`
 }
 
 // ServerConfig holds server-specific settings
@@ -22,24 +77,231 @@ type ServerConfig struct {
 	ReadTimeout  time.Duration `json:"readTimeout"`
 	WriteTimeout time.Duration `json:"writeTimeout"`
 	IdleTimeout  time.Duration `json:"idleTimeout"`
+	// DrainTimeout bounds how long shutdown waits for in-flight requests
+	DrainTimeout time.Duration `json:"drainTimeout"`
+	// MaxInFlight caps the requests handled at once (0 = unlimited)
+	MaxInFlight int `json:"maxInFlight,omitempty"`
+	// AdaptiveLimit lowers the in-flight cap while backends answer slowly
+	AdaptiveLimit balancer.AdaptiveLimitConfig `json:"adaptiveLimit"`
+	// MaxRequestBytes caps request bodies (0 = unlimited)
+	MaxRequestBytes int64 `json:"maxRequestBytes,omitempty"`
+	// TLS serves HTTPS on the load balancer listeners when set
+	TLS ServerTLSConfig `json:"tls"`
+	// H2C accepts HTTP/2 without TLS (prior knowledge) besides HTTP/1, as
+	// gRPC clients send it
+	H2C bool `json:"h2c,omitempty"`
 }
 
-// BackendConfig holds backend server configuration
-type BackendConfig struct {
-	URL    string `json:"url"`
-	Weight int    `json:"weight"`
-}
+// BackendConfig holds backend server configuration (URL, weight, health
+// overrides, limits, timeouts, TLS, labels and backup flag)
+type BackendConfig = backend.Config
 
 // HealthCheckConfig holds health check settings
 type HealthCheckConfig struct {
 	Interval time.Duration `json:"interval"`
 	Timeout  time.Duration `json:"timeout"`
 	Path     string        `json:"path"`
+
+	// Method, Headers, ExpectedStatus and ExpectedBody describe the probe
+	// request and the answer that passes it; backends override them with
+	// healthMethod, healthHeaders, healthStatus and healthBody
+	Method         string            `json:"method,omitempty"`
+	Headers        map[string]string `json:"headers,omitempty"`
+	ExpectedStatus []int             `json:"expectedStatus,omitempty"`
+	ExpectedBody   string            `json:"expectedBody,omitempty"`
+}
+
+// Probe returns the health check applied to every backend
+func (c HealthCheckConfig) Probe() healthcheck.Config {
+	return healthcheck.Config{
+		Path:           c.Path,
+		Method:         c.Method,
+		Headers:        c.Headers,
+		ExpectedStatus: c.ExpectedStatus,
+		ExpectedBody:   c.ExpectedBody,
+	}
 }
 
 // StrategyConfig holds load balancing strategy settings
 type StrategyConfig struct {
-	Type string `json:"type"` // roundrobin, leastconnections, random, weighted
+	Type string `json:"type"` // a registered strategy: roundrobin, leastconnections, random, weighted, ...
+	// Subset restricts the strategy to the backends carrying its labels,
+	// or makes it prefer them with fallback
+	Subset backend.Subset `json:"subset"`
+}
+
+// DefaultPoolName names the pool built from the top-level backends list
+const DefaultPoolName = "default"
+
+// PoolConfig is a named group of backends sharing a strategy
+type PoolConfig struct {
+	Name     string          `json:"name"`
+	Backends []BackendConfig `json:"backends"`
+	Strategy StrategyConfig  `json:"strategy"` // empty type inherits the top-level strategy
+	// ErrorPage overrides the top-level errorPage for this pool
+	ErrorPage *backend.ErrorPage `json:"errorPage,omitempty"`
+}
+
+// InstanceConfig is an additional load balancer with its own listener,
+// pool and strategy, e.g. one per local service in a sidecar
+type InstanceConfig struct {
+	Name string `json:"name"`
+	Port int    `json:"port"`
+	Pool string `json:"pool"`
+}
+
+// RouteConfig sends requests matching Match to a pool
+type RouteConfig struct {
+	Name     string     `json:"name"`
+	Match    RouteMatch `json:"match"`
+	Pool     string     `json:"pool"`
+	Strategy string     `json:"strategy,omitempty"` // overrides the pool strategy for this route
+	// Subset overrides the subset of the pool strategy for this route
+	Subset     backend.Subset  `json:"subset"`
+	Middleware RouteMiddleware `json:"middleware"`
+}
+
+// RouteMatch selects requests by host, path, method, headers and query
+// parameters; empty fields match anything
+type RouteMatch struct {
+	Host       string `json:"host,omitempty"`       // exact host or "*.example.com"
+	PathPrefix string `json:"pathPrefix,omitempty"` // e.g. "/api/"
+	// Methods, Headers and Query must all match as well, e.g. a header
+	// {"name": "X-Beta", "value": "true"} for a canary pool
+	Methods []string            `json:"methods,omitempty"`
+	Headers []router.ValueMatch `json:"headers,omitempty"`
+	Query   []router.ValueMatch `json:"query,omitempty"`
+}
+
+// catchAll reports whether the match selects every request
+func (m RouteMatch) catchAll() bool {
+	return m.Host == "" && (m.PathPrefix == "" || m.PathPrefix == "/") &&
+		len(m.Methods) == 0 && len(m.Headers) == 0 && len(m.Query) == 0
+}
+
+// RouteMiddleware holds per-route request handling options
+type RouteMiddleware struct {
+	StripPrefix bool              `json:"stripPrefix,omitempty"`
+	Rewrite     string            `json:"rewrite,omitempty"` // replaces match.pathPrefix, e.g. "/v2/"
+	SetHeaders  map[string]string `json:"setHeaders,omitempty"`
+	Timeout     time.Duration     `json:"timeout,omitempty"`
+	CORS        *bool             `json:"cors,omitempty"` // nil inherits the global setting
+	// ResponseTimeout and IdleTimeout bound the wait for response headers
+	// and the pauses in the response body, on top of the backend settings
+	ResponseTimeout time.Duration `json:"responseTimeout,omitempty"`
+	IdleTimeout     time.Duration `json:"idleTimeout,omitempty"`
+}
+
+// BlueGreenConfig names the two pools of a blue/green deployment; the
+// requests for either pool go to the active one, which the admin API
+// switches at runtime
+type BlueGreenConfig struct {
+	Pools  []string `json:"pools,omitempty"`  // e.g. ["blue", "green"]
+	Active string   `json:"active,omitempty"` // defaults to the first pool
+}
+
+// Enabled reports whether blue/green switching is configured
+func (b BlueGreenConfig) Enabled() bool {
+	return len(b.Pools) > 0
+}
+
+// ActivePool returns the pool receiving traffic at startup
+func (b BlueGreenConfig) ActivePool() string {
+	if b.Active == "" && len(b.Pools) > 0 {
+		return b.Pools[0]
+	}
+	return b.Active
+}
+
+// ResolvedPools returns every pool, including the implicit default pool for
+// the top-level backends, with strategies inherited from the top level
+func (c *Config) ResolvedPools() []PoolConfig {
+	pools := make([]PoolConfig, 0, len(c.Pools)+1)
+	if len(c.Backends) > 0 || c.Discovering() {
+		pools = append(pools, PoolConfig{Name: DefaultPoolName, Backends: c.Backends, Strategy: c.Strategy})
+	}
+	for _, p := range c.Pools {
+		if p.Strategy.Type == "" {
+			p.Strategy = c.Strategy
+		}
+		pools = append(pools, p)
+	}
+	return pools
+}
+
+// SetMaxConnections caps the backends of every pool, and the discovered
+// ones, that have no maxConnections of their own at n
+func (c *Config) SetMaxConnections(n int) {
+	for i := range c.Backends {
+		if c.Backends[i].MaxConnections == 0 {
+			c.Backends[i].MaxConnections = n
+		}
+	}
+	for _, p := range c.Pools {
+		for i := range p.Backends {
+			if p.Backends[i].MaxConnections == 0 {
+				p.Backends[i].MaxConnections = n
+			}
+		}
+	}
+	if c.Discovery.Defaults.MaxConnections == 0 {
+		c.Discovery.Defaults.MaxConnections = n
+	}
+}
+
+// Discovering reports whether a discovery provider feeds the default pool
+func (c *Config) Discovering() bool {
+	return c.Discovery.Type != "" && c.Discovery.Type != discovery.TypeNone
+}
+
+// Pool returns the resolved pool with the given name
+func (c *Config) Pool(name string) (PoolConfig, bool) {
+	for _, p := range c.ResolvedPools() {
+		if p.Name == name {
+			return p, true
+		}
+	}
+	return PoolConfig{}, false
+}
+
+// PoolErrorPage returns the error page of the named pool: its own, else the
+// top-level one
+func (c *Config) PoolErrorPage(name string) backend.ErrorPage {
+	if p, ok := c.Pool(name); ok && p.ErrorPage != nil {
+		return *p.ErrorPage
+	}
+	return c.ErrorPage
+}
+
+// ResolvedRoutes returns the routes, or a single catch-all route to the first
+// blue/green pool, else the first pool, when none are configured
+func (c *Config) ResolvedRoutes() []RouteConfig {
+	if len(c.Routes) > 0 {
+		return c.Routes
+	}
+	if c.BlueGreen.Enabled() {
+		return []RouteConfig{{Name: "default", Match: RouteMatch{PathPrefix: "/"}, Pool: c.BlueGreen.Pools[0]}}
+	}
+	pools := c.ResolvedPools()
+	if len(pools) == 0 {
+		return nil
+	}
+	return []RouteConfig{{Name: "default", Match: RouteMatch{PathPrefix: "/"}, Pool: pools[0].Name}}
+}
+
+// PrimaryPool returns the pool serving catch-all traffic: the pool of the
+// first route matching every request, else the first pool
+func (c *Config) PrimaryPool() (PoolConfig, bool) {
+	for _, r := range c.ResolvedRoutes() {
+		if r.Match.catchAll() {
+			return c.Pool(r.Pool)
+		}
+	}
+	pools := c.ResolvedPools()
+	if len(pools) == 0 {
+		return PoolConfig{}, false
+	}
+	return pools[0], true
 }
 
 // LoggingConfig holds logging settings
@@ -48,21 +310,101 @@ type LoggingConfig struct {
 	Format string `json:"format"` // text, json
 }
 
-// LoadConfig loads configuration from a JSON file
+// AdminConfig holds admin endpoint settings
+type AdminConfig struct {
+	Token string `json:"token,omitempty"` // bearer token; admin endpoints are disabled when empty
+	Port  int    `json:"port,omitempty"`  // separate admin listener; 0 serves admin endpoints on the main port
+}
+
+// MetricsConfig holds metrics export settings
+type MetricsConfig struct {
+	Push metrics.PushConfig `json:"push"`
+}
+
+// LoadConfig loads configuration from a JSON or YAML file; settings missing
+// from the file keep their DefaultConfig values and durations may be
+// written as "15s"
 func LoadConfig(filename string) (*Config, error) {
-	file, err := os.Open(filename)
+	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, fmt.Errorf("failed to open config file: %w", err)
 	}
-	defer file.Close()
 
-	var config Config
-	decoder := json.NewDecoder(file)
-	if err := decoder.Decode(&config); err != nil {
+	config, err := Parse(data)
+	if err != nil {
+		return nil, err
+	}
+	return config, nil
+}
+
+// Parse decodes a JSON or YAML configuration on top of DefaultConfig,
+// resolving env:// and file:// secret references in string values
+func Parse(data []byte) (*Config, error) {
+	return ParseLayers(data)
+}
+
+// ParseLayers merges JSON or YAML documents in order (see LoadProfile) and
+// decodes the result like Parse
+func ParseLayers(layers ...[]byte) (*Config, error) {
+	var raw interface{}
+	for i, data := range layers {
+		layer, err := decodeLayer(data)
+		if err != nil {
+			if i > 0 {
+				return nil, fmt.Errorf("failed to decode config overlay: %w", err)
+			}
+			return nil, fmt.Errorf("failed to decode config: %w", err)
+		}
+		if i == 0 {
+			raw = layer
+		} else {
+			raw = mergeLayers(raw, layer)
+		}
+	}
+	raw, err := resolveSecrets(raw, "")
+	if err != nil {
+		return nil, fmt.Errorf("failed to resolve secret: %w", err)
+	}
+	config := DefaultConfig()
+	if err := jsonconf.Decode(raw, config); err != nil {
 		return nil, fmt.Errorf("failed to decode config: %w", err)
 	}
 
-	return &config, nil
+	// A file defining pools or discovery without top-level backends doesn't want the default ones
+	if obj, ok := raw.(map[string]interface{}); ok {
+		_, hasPools := obj["pools"]
+		_, hasDiscovery := obj["discovery"]
+		_, hasBackends := obj["backends"]
+		if (hasPools || hasDiscovery) && !hasBackends {
+			config.Backends = nil
+		}
+	}
+
+	return config, nil
+}
+
+// decodeLayer decodes a document into generic values. A document starting
+// with "{" is JSON; anything else is read as YAML, which also covers
+// files without an extension and configs fetched from a URL.
+func decodeLayer(data []byte) (interface{}, error) {
+	var layer interface{}
+	if trimmed := bytes.TrimSpace(data); len(trimmed) > 0 && trimmed[0] == '{' {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		err := decoder.Decode(&layer)
+		return layer, err
+	}
+	if err := yaml.Unmarshal(data, &layer); err != nil {
+		return nil, err
+	}
+	if layer == nil {
+		// An empty document changes nothing
+		return map[string]interface{}{}, nil
+	}
+	if _, ok := layer.(map[string]interface{}); !ok {
+		return nil, fmt.Errorf("expected an object at the top level, got %T", layer)
+	}
+	return layer, nil
 }
 
 // DefaultConfig returns a default configuration
@@ -73,6 +415,7 @@ func DefaultConfig() *Config {
 			ReadTimeout:  15 * time.Second,
 			WriteTimeout: 15 * time.Second,
 			IdleTimeout:  60 * time.Second,
+			DrainTimeout: 30 * time.Second,
 		},
 		Backends: []BackendConfig{
 			{URL: "http://localhost:8081", Weight: 1},
@@ -91,6 +434,15 @@ func DefaultConfig() *Config {
 			Level:  "info",
 			Format: "text",
 		},
+		AccessLog: accesslog.Config{
+			Sink: accesslog.SinkNone,
+		},
+		Metrics: MetricsConfig{
+			Push: metrics.PushConfig{Mode: metrics.PushNone},
+		},
+		Discovery: discovery.Config{
+			Type: discovery.TypeNone,
+		},
 	}
 }
 
@@ -0,0 +1,201 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLoadConfig_Example(t *testing.T) {
+	cfg, err := LoadConfig(filepath.Join("..", "config.example.json"))
+	if err != nil {
+		t.Fatalf("Failed to load example config: %v", err)
+	}
+
+	if cfg.Server.ReadTimeout != 15*time.Second {
+		t.Errorf("Expected read timeout 15s, got %v", cfg.Server.ReadTimeout)
+	}
+	if cfg.HealthCheck.Interval != 10*time.Second {
+		t.Errorf("Expected health interval 10s, got %v", cfg.HealthCheck.Interval)
+	}
+	if len(cfg.Backends) != 3 || cfg.Backends[0].Weight != 3 {
+		t.Errorf("Unexpected backends: %+v", cfg.Backends)
+	}
+}
+
+func TestParse_DefaultsAndDurations(t *testing.T) {
+	tests := []struct {
+		name     string
+		json     string
+		port     int
+		interval time.Duration
+		push     time.Duration
+	}{
+		{"empty uses defaults", `{}`, 8080, 10 * time.Second, 0},
+		{"string durations", `{"server":{"port":9090},"healthCheck":{"interval":"2s"},"metrics":{"push":{"interval":"1m"}}}`, 9090, 2 * time.Second, time.Minute},
+		{"integer durations", `{"healthCheck":{"interval":3000000000}}`, 8080, 3 * time.Second, 0},
+		{"yaml", "server:\n  port: 9090\nhealthCheck:\n  interval: 2s\nmetrics:\n  push:\n    interval: 1m\n", 9090, 2 * time.Second, time.Minute},
+		{"empty yaml", "# nothing set\n", 8080, 10 * time.Second, 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := Parse([]byte(tt.json))
+			if err != nil {
+				t.Fatalf("Parse failed: %v", err)
+			}
+			if cfg.Server.Port != tt.port {
+				t.Errorf("Expected port %d, got %d", tt.port, cfg.Server.Port)
+			}
+			if cfg.HealthCheck.Interval != tt.interval {
+				t.Errorf("Expected interval %v, got %v", tt.interval, cfg.HealthCheck.Interval)
+			}
+			if cfg.Metrics.Push.Interval != tt.push {
+				t.Errorf("Expected push interval %v, got %v", tt.push, cfg.Metrics.Push.Interval)
+			}
+		})
+	}
+}
+
+func TestParse_InvalidDuration(t *testing.T) {
+	if _, err := Parse([]byte(`{"healthCheck":{"interval":"soon"}}`)); err == nil {
+		t.Error("Expected error for invalid duration")
+	}
+}
+
+func TestParse_YAML(t *testing.T) {
+	cfg, err := Parse([]byte(`
+backends:
+  - url: http://10.0.0.1:8080
+    weight: 3
+    labels: {zone: a}
+  - url: http://10.0.0.2:8080
+    backup: true
+    healthStatus: [200, 204]
+strategy:
+  type: leastconnections
+`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].Weight != 3 || cfg.Backends[0].Labels["zone"] != "a" {
+		t.Errorf("Unexpected backends: %+v", cfg.Backends)
+	}
+	if !cfg.Backends[1].Backup || len(cfg.Backends[1].HealthStatus) != 2 {
+		t.Errorf("Unexpected second backend: %+v", cfg.Backends[1])
+	}
+	if cfg.Strategy.Type != "leastconnections" {
+		t.Errorf("Expected leastconnections, got %s", cfg.Strategy.Type)
+	}
+
+	for _, doc := range []string{"- just\n- a list\n", "server: [unclosed\n"} {
+		if _, err := Parse([]byte(doc)); err == nil {
+			t.Errorf("Expected error for %q", doc)
+		}
+	}
+}
+
+func TestSaveConfig_RoundTrip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	want := DefaultConfig()
+	want.Server.Port = 9999
+	if err := SaveConfig(path, want); err != nil {
+		t.Fatalf("SaveConfig failed: %v", err)
+	}
+	got, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("LoadConfig failed: %v", err)
+	}
+	if got.Server.Port != 9999 || got.HealthCheck.Timeout != want.HealthCheck.Timeout {
+		t.Errorf("Round trip mismatch: %+v", got)
+	}
+	os.Remove(path)
+}
+
+func TestParse_PoolsAndRoutes(t *testing.T) {
+	cfg, err := Parse([]byte(`{
+		"strategy": {"type": "leastconnections"},
+		"pools": [
+			{"name": "api", "backends": [{"url": "http://api-1:8080"}], "strategy": {"type": "random"}},
+			{"name": "web", "backends": [{"url": "http://web-1:8080"}]}
+		],
+		"routes": [
+			{"name": "beta", "match": {"headers": [{"name": "X-Beta", "value": "true"}]}, "pool": "api"},
+			{"name": "api", "match": {"pathPrefix": "/api/"}, "pool": "api", "middleware": {"stripPrefix": true, "timeout": "5s"}},
+			{"name": "site", "match": {"pathPrefix": "/"}, "pool": "web"}
+		]
+	}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	if len(cfg.Backends) != 0 {
+		t.Errorf("Expected default backends to be dropped when pools are defined, got %d", len(cfg.Backends))
+	}
+	pools := cfg.ResolvedPools()
+	if len(pools) != 2 {
+		t.Fatalf("Expected 2 pools, got %d", len(pools))
+	}
+	if pools[1].Strategy.Type != "leastconnections" {
+		t.Errorf("Expected web pool to inherit strategy, got %q", pools[1].Strategy.Type)
+	}
+	if h := cfg.Routes[0].Match.Headers; len(h) != 1 || h[0].Name != "X-Beta" || h[0].Value != "true" {
+		t.Errorf("Expected the X-Beta header match, got %+v", h)
+	}
+	if cfg.Routes[1].Middleware.Timeout != 5*time.Second {
+		t.Errorf("Expected route timeout 5s, got %v", cfg.Routes[1].Middleware.Timeout)
+	}
+	if primary, ok := cfg.PrimaryPool(); !ok || primary.Name != "web" {
+		t.Errorf("Expected primary pool web, got %q", primary.Name)
+	}
+}
+
+func TestResolvedRoutes_ImplicitDefault(t *testing.T) {
+	cfg := DefaultConfig()
+	routes := cfg.ResolvedRoutes()
+	if len(routes) != 1 || routes[0].Pool != DefaultPoolName {
+		t.Errorf("Expected a catch-all route to the default pool, got %+v", routes)
+	}
+}
+
+func TestParse_BlueGreen(t *testing.T) {
+	cfg, err := Parse([]byte(`{
+		"pools": [
+			{"name": "blue", "backends": [{"url": "http://blue-1:8080"}]},
+			{"name": "green", "backends": [{"url": "http://green-1:8080"}]}
+		],
+		"blueGreen": {"pools": ["green", "blue"], "active": "blue"}
+	}`))
+	if err != nil {
+		t.Fatalf("Parse failed: %v", err)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+	if cfg.BlueGreen.ActivePool() != "blue" {
+		t.Errorf("Expected blue to be active, got %q", cfg.BlueGreen.ActivePool())
+	}
+	// The primary pool stays the first blue/green pool whichever is active
+	if primary, ok := cfg.PrimaryPool(); !ok || primary.Name != "green" {
+		t.Errorf("Expected primary pool green, got %q", primary.Name)
+	}
+}
+
+func TestConfig_SetMaxConnections(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Backends = []BackendConfig{{URL: "http://a:80"}, {URL: "http://b:80", MaxConnections: 5}}
+	cfg.Pools = []PoolConfig{{Name: "api", Backends: []BackendConfig{{URL: "http://api:80"}}}}
+	cfg.SetMaxConnections(20)
+
+	if cfg.Backends[0].MaxConnections != 20 || cfg.Backends[1].MaxConnections != 5 {
+		t.Errorf("Expected 20 and the backend's own 5, got %d and %d", cfg.Backends[0].MaxConnections, cfg.Backends[1].MaxConnections)
+	}
+	if cfg.Pools[0].Backends[0].MaxConnections != 20 || cfg.Discovery.Defaults.MaxConnections != 20 {
+		t.Errorf("Expected pool and discovered backends capped at 20, got %d and %d",
+			cfg.Pools[0].Backends[0].MaxConnections, cfg.Discovery.Defaults.MaxConnections)
+	}
+}
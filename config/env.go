@@ -0,0 +1,158 @@
+package config
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// EnvPrefix starts every environment variable read by ApplyEnv
+const EnvPrefix = "GO_BALANCER_"
+
+// envVar is a setting that can be given as an environment variable
+type envVar struct {
+	name  string // without EnvPrefix
+	usage string
+	apply func(c *Config, value string) error
+}
+
+// envVars lists the settings ApplyEnv reads, enough to run a container
+// without a config file
+var envVars = []envVar{
+	{"PORT", "Load balancer port", func(c *Config, v string) error { return setInt(&c.Server.Port, v) }},
+	{"READ_TIMEOUT", "Server read timeout", func(c *Config, v string) error { return setDuration(&c.Server.ReadTimeout, v) }},
+	{"WRITE_TIMEOUT", "Server write timeout", func(c *Config, v string) error { return setDuration(&c.Server.WriteTimeout, v) }},
+	{"IDLE_TIMEOUT", "Server idle timeout", func(c *Config, v string) error { return setDuration(&c.Server.IdleTimeout, v) }},
+	{"DRAIN_TIMEOUT", "How long shutdown waits for in-flight requests", func(c *Config, v string) error { return setDuration(&c.Server.DrainTimeout, v) }},
+	{"TLS_CERT_FILE", "Certificate file to serve HTTPS with", func(c *Config, v string) error { c.Server.TLS.CertFile = v; return nil }},
+	{"TLS_KEY_FILE", "Private key file of the certificate", func(c *Config, v string) error { c.Server.TLS.KeyFile = v; return nil }},
+	{"BACKENDS", "Comma-separated backend URLs (replaces the configured backends)", func(c *Config, v string) error {
+		c.Backends = nil
+		for _, u := range strings.Split(v, ",") {
+			if u = strings.TrimSpace(u); u != "" {
+				c.Backends = append(c.Backends, BackendConfig{URL: u, Weight: 1})
+			}
+		}
+		return nil
+	}},
+	{"BACKEND_TLS_CA_FILE", "PEM bundle verifying https backends", func(c *Config, v string) error {
+		eachHTTPSBackend(c, func(b *BackendConfig) { b.TLS.CAFile = v })
+		return nil
+	}},
+	{"BACKEND_TLS_CERT_FILE", "Client certificate presented to https backends", func(c *Config, v string) error {
+		eachHTTPSBackend(c, func(b *BackendConfig) { b.TLS.CertFile = v })
+		return nil
+	}},
+	{"BACKEND_TLS_KEY_FILE", "Key of BACKEND_TLS_CERT_FILE", func(c *Config, v string) error {
+		eachHTTPSBackend(c, func(b *BackendConfig) { b.TLS.KeyFile = v })
+		return nil
+	}},
+	{"BACKEND_TLS_SERVER_NAME", "Server name verified on https backends", func(c *Config, v string) error {
+		eachHTTPSBackend(c, func(b *BackendConfig) { b.TLS.ServerName = v })
+		return nil
+	}},
+	{"BACKEND_TLS_INSECURE", "Skip certificate verification of https backends", func(c *Config, v string) error {
+		insecure, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		eachHTTPSBackend(c, func(b *BackendConfig) { b.TLS.InsecureSkipVerify = insecure })
+		return nil
+	}},
+	{"MAX_CONNECTIONS", "Concurrent request cap of backends without their own maxConnections", func(c *Config, v string) error {
+		var n int
+		if err := setInt(&n, v); err != nil {
+			return err
+		}
+		c.SetMaxConnections(n)
+		return nil
+	}},
+	{"QUEUE_SIZE", "Requests that may wait for a busy pool (queue.maxLength)", func(c *Config, v string) error { return setInt(&c.Queue.MaxLength, v) }},
+	{"QUEUE_TIMEOUT", "Longest wait of a queued request", func(c *Config, v string) error { return setDuration(&c.Queue.Timeout, v) }},
+	{"TRUSTED_PROXIES", "Comma-separated CIDRs of proxies whose forwarding headers are kept", func(c *Config, v string) error {
+		c.TrustedProxies = nil
+		for _, p := range strings.Split(v, ",") {
+			if p = strings.TrimSpace(p); p != "" {
+				c.TrustedProxies = append(c.TrustedProxies, p)
+			}
+		}
+		return nil
+	}},
+	{"STRATEGY", "Load balancing strategy", func(c *Config, v string) error { c.Strategy.Type = v; return nil }},
+	{"HEALTH_INTERVAL", "Health check interval", func(c *Config, v string) error { return setDuration(&c.HealthCheck.Interval, v) }},
+	{"HEALTH_TIMEOUT", "Health check timeout", func(c *Config, v string) error { return setDuration(&c.HealthCheck.Timeout, v) }},
+	{"HEALTH_PATH", "Health check path", func(c *Config, v string) error { c.HealthCheck.Path = v; return nil }},
+	{"LOG_LEVEL", "Log level (debug, info, warn, error)", func(c *Config, v string) error { c.Logging.Level = v; return nil }},
+	{"LOG_FORMAT", "Log format (text, json)", func(c *Config, v string) error { c.Logging.Format = v; return nil }},
+	{"ACCESS_LOG", "Access log sink (none, stdout)", func(c *Config, v string) error { c.AccessLog.Sink = v; return nil }},
+	{"ACCESS_LOG_FORMAT", "Access log format (json, common, combined)", func(c *Config, v string) error { c.AccessLog.Format = v; return nil }},
+	{"ADMIN_PORT", "Separate admin API port", func(c *Config, v string) error { return setInt(&c.Admin.Port, v) }},
+	{"ADMIN_TOKEN", "Admin bearer token (env:// and file:// references allowed)", func(c *Config, v string) error {
+		token, err := ResolveSecret(v)
+		if err != nil {
+			return err
+		}
+		c.Admin.Token = token
+		return nil
+	}},
+}
+
+// EnvUsage returns the supported environment variables and their meaning,
+// sorted by name
+func EnvUsage() [][2]string {
+	usage := make([][2]string, 0, len(envVars))
+	for _, ev := range envVars {
+		usage = append(usage, [2]string{EnvPrefix + ev.name, ev.usage})
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i][0] < usage[j][0] })
+	return usage
+}
+
+// ApplyEnv overrides c with the GO_BALANCER_* variables found by lookup
+// (normally os.LookupEnv) and returns the names of those applied. Backend
+// TLS variables and MAX_CONNECTIONS apply to every backend, so BACKENDS is
+// read before them.
+func (c *Config) ApplyEnv(lookup func(string) (string, bool)) ([]string, error) {
+	var applied []string
+	for _, ev := range envVars {
+		name := EnvPrefix + ev.name
+		value, ok := lookup(name)
+		if !ok || value == "" {
+			continue
+		}
+		if err := ev.apply(c, value); err != nil {
+			return applied, fmt.Errorf("%s: %w", name, err)
+		}
+		applied = append(applied, name)
+	}
+	return applied, nil
+}
+
+func setInt(dst *int, v string) error {
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return fmt.Errorf("invalid number %q", v)
+	}
+	*dst = n
+	return nil
+}
+
+func setDuration(dst *time.Duration, v string) error {
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		return err
+	}
+	*dst = d
+	return nil
+}
+
+// eachHTTPSBackend applies set to the https backends
+func eachHTTPSBackend(c *Config, set func(*BackendConfig)) {
+	for i := range c.Backends {
+		if strings.HasPrefix(strings.ToLower(c.Backends[i].URL), "https://") {
+			set(&c.Backends[i])
+		}
+	}
+}
@@ -0,0 +1,88 @@
+package config
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfig_ApplyEnv(t *testing.T) {
+	env := map[string]string{
+		"GO_BALANCER_PORT":                "9000",
+		"GO_BALANCER_BACKENDS":            "https://api-1:8443, http://api-2:8080",
+		"GO_BALANCER_BACKEND_TLS_CA_FILE": "/run/secrets/ca.pem",
+		"GO_BALANCER_STRATEGY":            "leastconnections",
+		"GO_BALANCER_HEALTH_INTERVAL":     "5s",
+		"GO_BALANCER_LOG_FORMAT":          "json",
+		"GO_BALANCER_ADMIN_TOKEN":         "s3cret",
+		"GO_BALANCER_HEALTH_PATH":         "",
+		"GO_BALANCER_MAX_CONNECTIONS":     "50",
+		"GO_BALANCER_QUEUE_SIZE":          "100",
+	}
+	lookup := func(name string) (string, bool) {
+		v, ok := env[name]
+		return v, ok
+	}
+
+	cfg := DefaultConfig()
+	applied, err := cfg.ApplyEnv(lookup)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if len(applied) != 9 {
+		t.Errorf("Expected 9 applied variables, got %v", applied)
+	}
+	if cfg.Server.Port != 9000 {
+		t.Errorf("Expected port 9000, got %d", cfg.Server.Port)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[1].URL != "http://api-2:8080" {
+		t.Fatalf("Expected 2 backends, got %+v", cfg.Backends)
+	}
+	if cfg.Backends[0].TLS.CAFile != "/run/secrets/ca.pem" {
+		t.Errorf("Expected CA file on the https backend, got %q", cfg.Backends[0].TLS.CAFile)
+	}
+	if !cfg.Backends[1].TLS.IsZero() {
+		t.Errorf("Expected no TLS on the http backend, got %+v", cfg.Backends[1].TLS)
+	}
+	if cfg.Strategy.Type != "leastconnections" || cfg.HealthCheck.Interval != 5*time.Second {
+		t.Errorf("Expected strategy and interval from env, got %s and %v", cfg.Strategy.Type, cfg.HealthCheck.Interval)
+	}
+	if cfg.Logging.Format != "json" || cfg.Admin.Token != "s3cret" {
+		t.Errorf("Expected log format and token from env, got %s and %s", cfg.Logging.Format, cfg.Admin.Token)
+	}
+	if cfg.Backends[1].MaxConnections != 50 || cfg.Queue.MaxLength != 100 {
+		t.Errorf("Expected max connections 50 and queue size 100, got %d and %d", cfg.Backends[1].MaxConnections, cfg.Queue.MaxLength)
+	}
+	if cfg.HealthCheck.Path != DefaultConfig().HealthCheck.Path {
+		t.Errorf("Expected empty variable to be ignored, got path %q", cfg.HealthCheck.Path)
+	}
+	if err := cfg.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestConfig_ApplyEnvErrors(t *testing.T) {
+	tests := []struct {
+		name, value, want string
+	}{
+		{"GO_BALANCER_PORT", "eighty", "GO_BALANCER_PORT"},
+		{"GO_BALANCER_DRAIN_TIMEOUT", "10", "GO_BALANCER_DRAIN_TIMEOUT"},
+		{"GO_BALANCER_MAX_CONNECTIONS", "many", "GO_BALANCER_MAX_CONNECTIONS"},
+		{"GO_BALANCER_BACKEND_TLS_INSECURE", "maybe", "GO_BALANCER_BACKEND_TLS_INSECURE"},
+		{"GO_BALANCER_ADMIN_TOKEN", "env://GO_BALANCER_TEST_UNSET", "is not set"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lookup := func(name string) (string, bool) {
+				if name == tt.name {
+					return tt.value, true
+				}
+				return "", false
+			}
+			_, err := DefaultConfig().ApplyEnv(lookup)
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
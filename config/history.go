@@ -0,0 +1,279 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultHistorySize is the number of applied configs kept for rollback
+const DefaultHistorySize = 10
+
+// redacted replaces secrets in configs served by the admin API
+const redacted = "REDACTED"
+
+// Version is one applied configuration
+type Version struct {
+	ID      int       `json:"id"`
+	Applied time.Time `json:"applied"`
+	Source  string    `json:"source"` // startup, file, etcd, rollback:<id>...
+	Config  *Config   `json:"config,omitempty"`
+}
+
+// History keeps the last applied configurations in memory and, when a
+// directory is given, on disk so they survive restarts
+type History struct {
+	mu       sync.Mutex
+	versions []Version
+	max      int
+	dir      string
+	nextID   int
+}
+
+// NewHistory creates a history of up to max versions; versions previously
+// persisted in dir (when non-empty) are loaded
+func NewHistory(max int, dir string) (*History, error) {
+	if max <= 0 {
+		max = DefaultHistorySize
+	}
+	h := &History{max: max, dir: dir, nextID: 1}
+	if dir == "" {
+		return h, nil
+	}
+
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create config history directory: %w", err)
+	}
+	files, err := filepath.Glob(filepath.Join(dir, "v*.json"))
+	if err != nil {
+		return nil, err
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read config version: %w", err)
+		}
+		var v Version
+		if err := json.Unmarshal(data, &v); err != nil || v.Config == nil {
+			logging.Logger().Warn("ignoring unreadable config history file", "file", file)
+			continue
+		}
+		h.versions = append(h.versions, v)
+	}
+	sort.Slice(h.versions, func(i, j int) bool { return h.versions[i].ID < h.versions[j].ID })
+	if n := len(h.versions); n > 0 {
+		h.nextID = h.versions[n-1].ID + 1
+	}
+	h.prune()
+	return h, nil
+}
+
+// Record stores cfg as the newest applied version
+func (h *History) Record(cfg *Config, source string) Version {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	v := Version{ID: h.nextID, Applied: time.Now(), Source: source, Config: cfg}
+	h.nextID++
+	h.versions = append(h.versions, v)
+	if h.dir != "" {
+		if err := h.persist(v); err != nil {
+			logging.Logger().Error("failed to persist config version", "version", v.ID, "error", err)
+		}
+	}
+	h.prune()
+	return v
+}
+
+// List returns the kept versions, newest first
+func (h *History) List() []Version {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]Version, len(h.versions))
+	for i, v := range h.versions {
+		out[len(out)-1-i] = v
+	}
+	return out
+}
+
+// Get returns the version with the given ID
+func (h *History) Get(id int) (Version, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for _, v := range h.versions {
+		if v.ID == id {
+			return v, true
+		}
+	}
+	return Version{}, false
+}
+
+// Current returns the most recently applied version
+func (h *History) Current() (Version, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if len(h.versions) == 0 {
+		return Version{}, false
+	}
+	return h.versions[len(h.versions)-1], true
+}
+
+// Rollback validates and applies version id (the previous one when id is 0)
+// and records it as a new version
+func (h *History) Rollback(id int, apply func(*Config) error) (Version, error) {
+	if id == 0 {
+		list := h.List()
+		if len(list) < 2 {
+			return Version{}, fmt.Errorf("no previous version to roll back to")
+		}
+		id = list[1].ID
+	}
+	target, ok := h.Get(id)
+	if !ok {
+		return Version{}, fmt.Errorf("config version %d not found", id)
+	}
+	if err := target.Config.Validate(); err != nil {
+		return Version{}, err
+	}
+	if err := apply(target.Config); err != nil {
+		return Version{}, err
+	}
+	return h.Record(target.Config, fmt.Sprintf("rollback:%d", id)), nil
+}
+
+// prune drops the oldest versions beyond the limit; caller holds mu
+func (h *History) prune() {
+	for len(h.versions) > h.max {
+		old := h.versions[0]
+		h.versions = h.versions[1:]
+		if h.dir != "" {
+			os.Remove(h.versionPath(old.ID))
+		}
+	}
+}
+
+func (h *History) persist(v Version) error {
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := h.versionPath(v.ID) + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, h.versionPath(v.ID))
+}
+
+func (h *History) versionPath(id int) string {
+	return filepath.Join(h.dir, fmt.Sprintf("v%06d.json", id))
+}
+
+// Redacted returns a copy of the config with secrets replaced
+func (c *Config) Redacted() *Config {
+	out := *c
+	if out.Admin.Token != "" {
+		out.Admin.Token = redacted
+	}
+	if out.Discovery.Etcd.Password != "" {
+		out.Discovery.Etcd.Password = redacted
+	}
+	if out.Discovery.Eureka.Password != "" {
+		out.Discovery.Eureka.Password = redacted
+	}
+	if out.Cluster.Secret != "" {
+		out.Cluster.Secret = redacted
+	}
+	if out.Sticky.Redis.Password != "" {
+		out.Sticky.Redis.Password = redacted
+	}
+	return &out
+}
+
+// Handler serves the history for the admin API:
+//
+//	GET  /admin/config/versions            list versions (without configs)
+//	GET  /admin/config/versions/{id}       one version with its config
+//	POST /admin/config/rollback?version=ID roll back (previous version when omitted)
+//
+// Secrets are redacted from returned configs
+func (h *History) Handler(apply func(*Config) error) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path := strings.TrimSuffix(r.URL.Path, "/")
+		switch {
+		case path == "/admin/config/rollback":
+			if r.Method != http.MethodPost {
+				http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+				return
+			}
+			id := 0
+			if s := r.URL.Query().Get("version"); s != "" {
+				n, err := strconv.Atoi(s)
+				if err != nil || n <= 0 {
+					http.Error(w, "version must be a positive integer", http.StatusBadRequest)
+					return
+				}
+				id = n
+			}
+			v, err := h.Rollback(id, apply)
+			if err != nil {
+				http.Error(w, fmt.Sprintf("Rollback failed: %v", err), http.StatusConflict)
+				return
+			}
+			logging.Logger().Info("config rolled back", "version", v.ID, "source", v.Source)
+			writeVersion(w, v, false)
+
+		case path == "/admin/config/versions":
+			current, _ := h.Current()
+			type summary struct {
+				ID      int       `json:"id"`
+				Applied time.Time `json:"applied"`
+				Source  string    `json:"source"`
+				Current bool      `json:"current"`
+			}
+			list := h.List()
+			out := make([]summary, 0, len(list))
+			for _, v := range list {
+				out = append(out, summary{ID: v.ID, Applied: v.Applied, Source: v.Source, Current: v.ID == current.ID})
+			}
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(out)
+
+		case strings.HasPrefix(path, "/admin/config/versions/"):
+			id, err := strconv.Atoi(strings.TrimPrefix(path, "/admin/config/versions/"))
+			if err != nil {
+				http.Error(w, "invalid version", http.StatusBadRequest)
+				return
+			}
+			v, ok := h.Get(id)
+			if !ok {
+				http.Error(w, "version not found", http.StatusNotFound)
+				return
+			}
+			writeVersion(w, v, true)
+
+		default:
+			http.NotFound(w, r)
+		}
+	})
+}
+
+func writeVersion(w http.ResponseWriter, v Version, withConfig bool) {
+	if withConfig {
+		v.Config = v.Config.Redacted()
+	} else {
+		v.Config = nil
+	}
+	w.Header().Set("Content-Type", "application/json")
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
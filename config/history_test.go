@@ -0,0 +1,125 @@
+package config
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func configWithPort(port int) *Config {
+	cfg := DefaultConfig()
+	cfg.Server.Port = port
+	return cfg
+}
+
+func TestHistory_RecordAndPrune(t *testing.T) {
+	h, _ := NewHistory(2, "")
+	h.Record(configWithPort(8001), "startup")
+	h.Record(configWithPort(8002), "file")
+	h.Record(configWithPort(8003), "file")
+
+	list := h.List()
+	if len(list) != 2 {
+		t.Fatalf("Expected 2 versions, got %d", len(list))
+	}
+	if list[0].ID != 3 || list[1].ID != 2 {
+		t.Errorf("Expected versions [3 2], got [%d %d]", list[0].ID, list[1].ID)
+	}
+	if _, ok := h.Get(1); ok {
+		t.Error("Expected version 1 to be pruned")
+	}
+}
+
+func TestHistory_Persistence(t *testing.T) {
+	dir := t.TempDir()
+	h, err := NewHistory(5, dir)
+	if err != nil {
+		t.Fatalf("NewHistory failed: %v", err)
+	}
+	h.Record(configWithPort(8001), "startup")
+	h.Record(configWithPort(8002), "file")
+
+	reopened, err := NewHistory(5, dir)
+	if err != nil {
+		t.Fatalf("NewHistory failed: %v", err)
+	}
+	current, ok := reopened.Current()
+	if !ok || current.ID != 2 || current.Config.Server.Port != 8002 {
+		t.Errorf("Expected version 2 with port 8002 after reopening, got %+v", current)
+	}
+	if v := reopened.Record(configWithPort(8003), "file"); v.ID != 3 {
+		t.Errorf("Expected IDs to continue at 3, got %d", v.ID)
+	}
+}
+
+func TestHistory_Rollback(t *testing.T) {
+	h, _ := NewHistory(10, "")
+	h.Record(configWithPort(8001), "startup")
+	h.Record(configWithPort(8002), "file")
+
+	var applied *Config
+	apply := func(c *Config) error { applied = c; return nil }
+
+	v, err := h.Rollback(0, apply)
+	if err != nil {
+		t.Fatalf("Rollback failed: %v", err)
+	}
+	if applied == nil || applied.Server.Port != 8001 {
+		t.Errorf("Expected the previous config to be applied, got %+v", applied)
+	}
+	if v.ID != 3 || v.Source != "rollback:1" {
+		t.Errorf("Expected rollback recorded as version 3, got %d %q", v.ID, v.Source)
+	}
+
+	if _, err := h.Rollback(42, apply); err == nil {
+		t.Error("Expected error for unknown version")
+	}
+	invalid := configWithPort(0)
+	h.Record(invalid, "file")
+	if _, err := h.Rollback(4, apply); err == nil {
+		t.Error("Expected invalid config to be rejected")
+	}
+}
+
+func TestHistory_Handler(t *testing.T) {
+	h, _ := NewHistory(10, "")
+	secret := configWithPort(8001)
+	secret.Admin.Token = "s3cret"
+	h.Record(secret, "startup")
+	h.Record(configWithPort(8002), "file")
+	handler := h.Handler(func(*Config) error { return nil })
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config/versions", nil))
+	var list []struct {
+		ID      int  `json:"id"`
+		Current bool `json:"current"`
+	}
+	json.Unmarshal(rec.Body.Bytes(), &list)
+	if len(list) != 2 || !list[0].Current || list[0].ID != 2 {
+		t.Errorf("Unexpected version list: %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config/versions/1", nil))
+	if strings.Contains(rec.Body.String(), "s3cret") || !strings.Contains(rec.Body.String(), redacted) {
+		t.Errorf("Expected admin token to be redacted, got %s", rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/admin/config/rollback", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected 405 for GET rollback, got %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/admin/config/rollback?version=1", nil))
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"rollback:1"`) {
+		t.Errorf("Expected successful rollback, got %d %s", rec.Code, rec.Body.String())
+	}
+	if secret.Admin.Token != "s3cret" {
+		t.Error("Redaction must not modify the stored config")
+	}
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// ProfileEnv selects a profile when no -profile flag is given
+const ProfileEnv = "GO_BALANCER_PROFILE"
+
+// ProfilePath returns the overlay file of a profile: config.json with
+// profile "prod" becomes config.prod.json next to it
+func ProfilePath(path, profile string) string {
+	ext := filepath.Ext(path)
+	return strings.TrimSuffix(path, ext) + "." + profile + ext
+}
+
+// LoadProfile loads the base config file merged with the overlay of the
+// given profile; an empty profile loads the base file only. Overlay objects
+// are merged key by key into the base, other values (including lists)
+// replace it, and null removes a key
+func LoadProfile(path, profile string) (*Config, error) {
+	if profile == "" {
+		return LoadConfig(path)
+	}
+	base, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open config file: %w", err)
+	}
+	overlayPath := ProfilePath(path, profile)
+	overlay, err := os.ReadFile(overlayPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open profile %q: %w", profile, err)
+	}
+	return ParseLayers(base, overlay)
+}
+
+// mergeLayers deep-merges overlay into base
+func mergeLayers(base, overlay interface{}) interface{} {
+	baseObj, ok := base.(map[string]interface{})
+	overlayObj, ok2 := overlay.(map[string]interface{})
+	if !ok || !ok2 {
+		return overlay
+	}
+	for key, val := range overlayObj {
+		if val == nil {
+			delete(baseObj, key)
+			continue
+		}
+		if existing, found := baseObj[key]; found {
+			baseObj[key] = mergeLayers(existing, val)
+		} else {
+			baseObj[key] = val
+		}
+	}
+	return baseObj
+}
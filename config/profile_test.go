@@ -0,0 +1,98 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+const profileBase = `{
+  "server": {"port": 8080, "readTimeout": "15s"},
+  "backends": [{"url": "http://dev-1:8081"}],
+  "strategy": {"type": "roundrobin"},
+  "logging": {"level": "debug", "format": "text"}
+}`
+
+func TestProfilePath(t *testing.T) {
+	tests := []struct{ path, profile, want string }{
+		{"config.json", "prod", "config.prod.json"},
+		{"/etc/lb/config.json", "staging", "/etc/lb/config.staging.json"},
+		{"lbconfig", "dev", "lbconfig.dev"},
+	}
+	for _, tt := range tests {
+		if got := ProfilePath(tt.path, tt.profile); got != tt.want {
+			t.Errorf("ProfilePath(%q, %q): Expected %q, got %q", tt.path, tt.profile, tt.want, got)
+		}
+	}
+}
+
+func TestLoadProfile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	os.WriteFile(path, []byte(profileBase), 0o644)
+	os.WriteFile(ProfilePath(path, "prod"), []byte(`{
+	  "server": {"port": 80},
+	  "backends": [{"url": "http://prod-1:8081"}, {"url": "http://prod-2:8081"}],
+	  "logging": {"level": "warn"},
+	  "strategy": null
+	}`), 0o644)
+
+	cfg, err := LoadProfile(path, "prod")
+	if err != nil {
+		t.Fatalf("LoadProfile failed: %v", err)
+	}
+	if cfg.Server.Port != 80 || cfg.Server.ReadTimeout != 15*time.Second {
+		t.Errorf("Expected overridden port with inherited timeout, got %+v", cfg.Server)
+	}
+	if len(cfg.Backends) != 2 || cfg.Backends[0].URL != "http://prod-1:8081" {
+		t.Errorf("Expected the overlay's backend list to replace the base one, got %+v", cfg.Backends)
+	}
+	if cfg.Logging.Level != "warn" || cfg.Logging.Format != "text" {
+		t.Errorf("Expected merged logging settings, got %+v", cfg.Logging)
+	}
+	if cfg.Strategy.Type != "roundrobin" {
+		t.Errorf("Expected null to fall back to the default strategy, got %q", cfg.Strategy.Type)
+	}
+
+	base, err := LoadProfile(path, "")
+	if err != nil || base.Server.Port != 8080 {
+		t.Errorf("Expected the base config without a profile, got %+v, %v", base, err)
+	}
+
+	if _, err := LoadProfile(path, "qa"); err == nil || !strings.Contains(err.Error(), `profile "qa"`) {
+		t.Errorf("Expected error for missing profile, got %v", err)
+	}
+}
+
+func TestWatcher_ReloadsOnOverlayChange(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.json")
+	overlay := ProfilePath(path, "prod")
+	os.WriteFile(path, []byte(profileBase), 0o644)
+	os.WriteFile(overlay, []byte(`{"server": {"port": 80}}`), 0o644)
+
+	initial, _ := LoadProfile(path, "prod")
+	applied := make(chan *Config, 4)
+	w := NewWatcher(path, initial, func() (*Config, error) { return LoadProfile(path, "prod") },
+		func(c *Config) error { applied <- c; return nil })
+	w.Also(overlay)
+	w.SetDebounce(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	os.WriteFile(overlay, []byte(`{"server": {"port": 81}}`), 0o644)
+	select {
+	case c := <-applied:
+		if c.Server.Port != 81 {
+			t.Errorf("Expected port 81 from the edited overlay, got %d", c.Server.Port)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Overlay change was not applied")
+	}
+}
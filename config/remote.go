@@ -0,0 +1,194 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Remote config defaults
+const (
+	DefaultRemotePoll = 30 * time.Second
+	// SignatureHeader carries the config signature: base64 Ed25519 signature
+	// of the body, or "sha256=<hex>" HMAC-SHA256 of the body
+	SignatureHeader = "X-Config-Signature"
+	// maxRemoteConfigSize bounds the fetched document
+	maxRemoteConfigSize = 10 << 20
+)
+
+// RemoteOptions configures fetching and verification of a remote config
+type RemoteOptions struct {
+	// PublicKey is a base64 Ed25519 public key; when set, responses must
+	// carry a valid Ed25519 signature
+	PublicKey string
+	// HMACSecret, when set instead, requires a valid "sha256=<hex>" HMAC signature
+	HMACSecret string
+	Timeout    time.Duration
+}
+
+// Remote fetches a config document over HTTP(S) with conditional requests
+// (ETag / If-Modified-Since) and optional signature verification
+type Remote struct {
+	url       string
+	client    *http.Client
+	publicKey ed25519.PublicKey
+	secret    []byte
+
+	mu           sync.RWMutex
+	etag         string
+	lastModified string
+	body         []byte
+}
+
+// IsRemote reports whether a config path is an http(s) URL
+func IsRemote(path string) bool {
+	return strings.HasPrefix(path, "http://") || strings.HasPrefix(path, "https://")
+}
+
+// NewRemote creates a remote config source for url
+func NewRemote(url string, opts RemoteOptions) (*Remote, error) {
+	if !IsRemote(url) {
+		return nil, fmt.Errorf("remote config URL must use http or https: %s", url)
+	}
+	if opts.PublicKey != "" && opts.HMACSecret != "" {
+		return nil, fmt.Errorf("use either a config public key or an HMAC secret, not both")
+	}
+	if opts.Timeout <= 0 {
+		opts.Timeout = 10 * time.Second
+	}
+	r := &Remote{
+		url:    url,
+		client: &http.Client{Timeout: opts.Timeout},
+		secret: []byte(opts.HMACSecret),
+	}
+	if opts.PublicKey != "" {
+		key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(opts.PublicKey))
+		if err != nil || len(key) != ed25519.PublicKeySize {
+			return nil, fmt.Errorf("config public key must be a base64 Ed25519 public key")
+		}
+		r.publicKey = key
+	}
+	return r, nil
+}
+
+// Body returns the last fetched and verified document
+func (r *Remote) Body() []byte {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.body
+}
+
+// Fetch requests the document, sending the validators of the previous
+// response; changed is false when the server answered 304 or the body is
+// identical
+func (r *Remote) Fetch(ctx context.Context) (changed bool, err error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, r.url, nil)
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Accept", "application/json")
+	r.mu.RLock()
+	if r.etag != "" {
+		req.Header.Set("If-None-Match", r.etag)
+	}
+	if r.lastModified != "" {
+		req.Header.Set("If-Modified-Since", r.lastModified)
+	}
+	r.mu.RUnlock()
+
+	resp, err := r.client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch config: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusNotModified:
+		return false, nil
+	case http.StatusOK:
+	default:
+		return false, fmt.Errorf("failed to fetch config: %s returned %d", r.url, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteConfigSize+1))
+	if err != nil {
+		return false, fmt.Errorf("failed to read config: %w", err)
+	}
+	if len(body) > maxRemoteConfigSize {
+		return false, fmt.Errorf("remote config exceeds %d bytes", maxRemoteConfigSize)
+	}
+	if err := r.verify(body, resp.Header.Get(SignatureHeader)); err != nil {
+		return false, err
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.etag = resp.Header.Get("ETag")
+	r.lastModified = resp.Header.Get("Last-Modified")
+	changed = string(body) != string(r.body)
+	r.body = body
+	return changed, nil
+}
+
+// verify checks the body against the configured keys
+func (r *Remote) verify(body []byte, signature string) error {
+	if r.publicKey == nil && len(r.secret) == 0 {
+		return nil
+	}
+	if signature == "" {
+		return fmt.Errorf("remote config is not signed (missing %s header)", SignatureHeader)
+	}
+
+	if len(r.secret) > 0 {
+		got, ok := strings.CutPrefix(signature, "sha256=")
+		mac := hmac.New(sha256.New, r.secret)
+		mac.Write(body)
+		want := hex.EncodeToString(mac.Sum(nil))
+		if !ok || !hmac.Equal([]byte(got), []byte(want)) {
+			return fmt.Errorf("remote config HMAC signature mismatch")
+		}
+		return nil
+	}
+
+	sig, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil || !ed25519.Verify(r.publicKey, body, sig) {
+		return fmt.Errorf("remote config signature verification failed")
+	}
+	return nil
+}
+
+// Poll fetches the document every interval until ctx is canceled, calling
+// onChange after each change; failures keep the last good document
+func (r *Remote) Poll(ctx context.Context, interval time.Duration, onChange func()) {
+	if interval <= 0 {
+		interval = DefaultRemotePoll
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			changed, err := r.Fetch(ctx)
+			if err != nil {
+				logging.Logger().Warn("remote config fetch failed, keeping last good config", "error", err)
+				continue
+			}
+			if changed {
+				onChange()
+			}
+		}
+	}
+}
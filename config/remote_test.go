@@ -0,0 +1,145 @@
+package config
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRemote_ConditionalFetch(t *testing.T) {
+	body := `{"server":{"port":9000}}`
+	var fetches, notModified int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&fetches, 1)
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			atomic.AddInt32(&notModified, 1)
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Write([]byte(body))
+	}))
+	defer server.Close()
+
+	r, err := NewRemote(server.URL, RemoteOptions{})
+	if err != nil {
+		t.Fatalf("NewRemote failed: %v", err)
+	}
+	changed, err := r.Fetch(context.Background())
+	if err != nil || !changed {
+		t.Fatalf("Expected first fetch to change, got %v, %v", changed, err)
+	}
+	changed, err = r.Fetch(context.Background())
+	if err != nil || changed {
+		t.Errorf("Expected 304 to report no change, got %v, %v", changed, err)
+	}
+	if notModified != 1 || string(r.Body()) != body {
+		t.Errorf("Expected cached body after 304, got %q (304s: %d)", r.Body(), notModified)
+	}
+
+	cfg, err := Parse(r.Body())
+	if err != nil || cfg.Server.Port != 9000 {
+		t.Errorf("Expected port 9000 from remote config, got %+v, %v", cfg, err)
+	}
+}
+
+func TestRemote_Signatures(t *testing.T) {
+	body := []byte(`{"strategy":{"type":"random"}}`)
+	pub, priv, _ := ed25519.GenerateKey(nil)
+	mac := hmac.New(sha256.New, []byte("shared"))
+	mac.Write(body)
+	hmacSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+	edSig := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, body))
+
+	tests := []struct {
+		name      string
+		opts      RemoteOptions
+		signature string
+		wantErr   string
+	}{
+		{"unsigned allowed", RemoteOptions{}, "", ""},
+		{"ed25519 valid", RemoteOptions{PublicKey: base64.StdEncoding.EncodeToString(pub)}, edSig, ""},
+		{"ed25519 wrong", RemoteOptions{PublicKey: base64.StdEncoding.EncodeToString(pub)}, hmacSig, "verification failed"},
+		{"ed25519 missing", RemoteOptions{PublicKey: base64.StdEncoding.EncodeToString(pub)}, "", "not signed"},
+		{"hmac valid", RemoteOptions{HMACSecret: "shared"}, hmacSig, ""},
+		{"hmac wrong secret", RemoteOptions{HMACSecret: "other"}, hmacSig, "mismatch"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.signature != "" {
+					w.Header().Set(SignatureHeader, tt.signature)
+				}
+				w.Write(body)
+			}))
+			defer server.Close()
+
+			r, err := NewRemote(server.URL, tt.opts)
+			if err != nil {
+				t.Fatalf("NewRemote failed: %v", err)
+			}
+			_, err = r.Fetch(context.Background())
+			if tt.wantErr == "" && err != nil {
+				t.Errorf("Unexpected error: %v", err)
+			}
+			if tt.wantErr != "" && (err == nil || !strings.Contains(err.Error(), tt.wantErr)) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if tt.wantErr != "" && r.Body() != nil {
+				t.Error("A rejected document must not be kept")
+			}
+		})
+	}
+}
+
+func TestRemote_Poll(t *testing.T) {
+	var version atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if version.Load() == 0 {
+			w.Write([]byte(`{"server":{"port":9000}}`))
+			return
+		}
+		w.Write([]byte(`{"server":{"port":9001}}`))
+	}))
+	defer server.Close()
+
+	r, _ := NewRemote(server.URL, RemoteOptions{})
+	r.Fetch(context.Background())
+
+	changes := make(chan struct{}, 4)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go r.Poll(ctx, 10*time.Millisecond, func() { changes <- struct{}{} })
+
+	version.Store(1)
+	select {
+	case <-changes:
+		if !strings.Contains(string(r.Body()), "9001") {
+			t.Errorf("Expected the new document, got %s", r.Body())
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Expected a change notification")
+	}
+}
+
+func TestNewRemote_Invalid(t *testing.T) {
+	if _, err := NewRemote("ftp://example.com/config.json", RemoteOptions{}); err == nil {
+		t.Error("Expected error for non-http URL")
+	}
+	if _, err := NewRemote("https://example.com", RemoteOptions{PublicKey: "short"}); err == nil {
+		t.Error("Expected error for invalid public key")
+	}
+	if _, err := NewRemote("https://example.com", RemoteOptions{PublicKey: base64.StdEncoding.EncodeToString(make([]byte, 32)), HMACSecret: "x"}); err == nil {
+		t.Error("Expected error when both verification methods are set")
+	}
+}
@@ -0,0 +1,73 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Secret reference schemes accepted in any string config value
+const (
+	EnvScheme  = "env://"
+	FileScheme = "file://"
+)
+
+// ResolveSecret returns the value referenced by s: env://NAME reads an
+// environment variable and file:///path reads a file (without its trailing
+// newline). Other strings are returned unchanged
+func ResolveSecret(s string) (string, error) {
+	switch {
+	case strings.HasPrefix(s, EnvScheme):
+		name := strings.TrimPrefix(s, EnvScheme)
+		v, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("environment variable %s is not set", name)
+		}
+		return v, nil
+	case strings.HasPrefix(s, FileScheme):
+		path := strings.TrimPrefix(s, FileScheme)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("failed to read secret file: %w", err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+	}
+	return s, nil
+}
+
+// resolveSecrets replaces secret references throughout decoded JSON; path
+// tracks the location for error messages
+func resolveSecrets(v interface{}, path string) (interface{}, error) {
+	switch val := v.(type) {
+	case map[string]interface{}:
+		for k, child := range val {
+			resolved, err := resolveSecrets(child, joinPath(path, k))
+			if err != nil {
+				return nil, err
+			}
+			val[k] = resolved
+		}
+	case []interface{}:
+		for i, child := range val {
+			resolved, err := resolveSecrets(child, fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return nil, err
+			}
+			val[i] = resolved
+		}
+	case string:
+		resolved, err := ResolveSecret(val)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return resolved, nil
+	}
+	return v, nil
+}
+
+func joinPath(parent, key string) string {
+	if parent == "" {
+		return key
+	}
+	return parent + "." + key
+}
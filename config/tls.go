@@ -0,0 +1,163 @@
+package config
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ServerTLSConfig terminates TLS on the load balancer listeners
+type ServerTLSConfig struct {
+	CertFile string `json:"certFile,omitempty"`
+	KeyFile  string `json:"keyFile,omitempty"`
+	// MinVersion is the oldest accepted protocol, "1.0" to "1.3" (default
+	// "1.2")
+	MinVersion string `json:"minVersion,omitempty"`
+	// CipherSuites restricts the TLS 1.0-1.2 suites by name, e.g.
+	// "TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256" (default: Go's secure suites);
+	// TLS 1.3 suites are not configurable
+	CipherSuites []string `json:"cipherSuites,omitempty"`
+}
+
+// Enabled reports whether the listeners serve HTTPS
+func (c ServerTLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// tlsVersions maps MinVersion values to crypto/tls versions
+var tlsVersions = map[string]uint16{
+	"1.0": tls.VersionTLS10,
+	"1.1": tls.VersionTLS11,
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// Validate checks the settings without reading the files
+func (c ServerTLSConfig) Validate() error {
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return fmt.Errorf("certFile and keyFile must be set together")
+	}
+	if _, err := c.minVersion(); err != nil {
+		return err
+	}
+	_, err := c.cipherSuites()
+	return err
+}
+
+func (c ServerTLSConfig) minVersion() (uint16, error) {
+	if c.MinVersion == "" {
+		return tls.VersionTLS12, nil
+	}
+	v, ok := tlsVersions[c.MinVersion]
+	if !ok {
+		return 0, fmt.Errorf("minVersion %q is unknown (valid: 1.0, 1.1, 1.2, 1.3)", c.MinVersion)
+	}
+	return v, nil
+}
+
+func (c ServerTLSConfig) cipherSuites() ([]uint16, error) {
+	if len(c.CipherSuites) == 0 {
+		return nil, nil
+	}
+	known := make(map[string]uint16)
+	for _, s := range tls.CipherSuites() {
+		known[s.Name] = s.ID
+	}
+	ids := make([]uint16, 0, len(c.CipherSuites))
+	for _, name := range c.CipherSuites {
+		id, ok := known[name]
+		if !ok {
+			return nil, fmt.Errorf("cipher suite %q is unknown or insecure (valid: %s)", name, strings.Join(sortedKeys(known), ", "))
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
+}
+
+func sortedKeys(m map[string]uint16) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// certCheckInterval is how often the certificate files are checked for a
+// renewal
+const certCheckInterval = time.Minute
+
+// Build loads the certificate and creates the server TLS configuration.
+// Renewed certificate files are picked up within a minute without a
+// restart.
+func (c ServerTLSConfig) Build() (*tls.Config, error) {
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	minVersion, _ := c.minVersion()
+	suites, _ := c.cipherSuites()
+	loader := &certLoader{certFile: c.CertFile, keyFile: c.KeyFile}
+	if err := loader.load(); err != nil {
+		return nil, err
+	}
+	return &tls.Config{
+		MinVersion:     minVersion,
+		CipherSuites:   suites,
+		GetCertificate: loader.get,
+	}, nil
+}
+
+// certLoader serves a certificate pair, reloading it when the files change
+type certLoader struct {
+	certFile, keyFile string
+
+	mu      sync.Mutex
+	cert    *tls.Certificate
+	modTime time.Time
+	checked time.Time
+}
+
+// load reads the certificate pair
+func (l *certLoader) load() error {
+	cert, err := tls.LoadX509KeyPair(l.certFile, l.keyFile)
+	if err != nil {
+		return fmt.Errorf("failed to load certificate: %w", err)
+	}
+	l.cert = &cert
+	l.modTime = l.lastModified()
+	l.checked = time.Now()
+	return nil
+}
+
+// lastModified returns the newest modification time of the two files
+func (l *certLoader) lastModified() time.Time {
+	var latest time.Time
+	for _, path := range []string{l.certFile, l.keyFile} {
+		if info, err := os.Stat(path); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	return latest
+}
+
+// get returns the current certificate, reloading it at most once per
+// certCheckInterval when the files changed; a failed reload keeps the
+// previous certificate
+func (l *certLoader) get(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if time.Since(l.checked) >= certCheckInterval {
+		l.checked = time.Now()
+		if !l.lastModified().Equal(l.modTime) {
+			cert := l.cert
+			if err := l.load(); err != nil {
+				l.cert = cert
+			}
+		}
+	}
+	return l.cert, nil
+}
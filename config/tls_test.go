@@ -0,0 +1,132 @@
+package config
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// writeCertificate writes a self-signed certificate pair to dir
+func writeCertificate(t *testing.T, dir string) (certFile, keyFile string) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	certFile, keyFile = filepath.Join(dir, "cert.pem"), filepath.Join(dir, "key.pem")
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return certFile, keyFile
+}
+
+func TestServerTLSConfig_Build(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+
+	tests := []struct {
+		name       string
+		config     ServerTLSConfig
+		minVersion uint16
+		suites     int
+		wantErr    bool
+	}{
+		{"defaults", ServerTLSConfig{CertFile: certFile, KeyFile: keyFile}, tls.VersionTLS12, 0, false},
+		{"tls 1.3", ServerTLSConfig{CertFile: certFile, KeyFile: keyFile, MinVersion: "1.3"}, tls.VersionTLS13, 0, false},
+		{"cipher suites", ServerTLSConfig{
+			CertFile:     certFile,
+			KeyFile:      keyFile,
+			CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"},
+		}, tls.VersionTLS12, 2, false},
+		{"missing file", ServerTLSConfig{CertFile: certFile + ".missing", KeyFile: keyFile}, 0, 0, true},
+		{"key without cert", ServerTLSConfig{KeyFile: keyFile}, 0, 0, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg, err := tt.config.Build()
+			if tt.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Expected no error, got %v", err)
+			}
+			if cfg.MinVersion != tt.minVersion {
+				t.Errorf("Expected min version %x, got %x", tt.minVersion, cfg.MinVersion)
+			}
+			if len(cfg.CipherSuites) != tt.suites {
+				t.Errorf("Expected %d cipher suites, got %d", tt.suites, len(cfg.CipherSuites))
+			}
+			cert, err := cfg.GetCertificate(&tls.ClientHelloInfo{ServerName: "localhost"})
+			if err != nil || cert == nil {
+				t.Errorf("Expected a certificate, got %v (%v)", cert, err)
+			}
+		})
+	}
+}
+
+func TestServerTLSConfig_Handshake(t *testing.T) {
+	certFile, keyFile := writeCertificate(t, t.TempDir())
+	cfg, err := ServerTLSConfig{CertFile: certFile, KeyFile: keyFile}.Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			conn.(*tls.Conn).Handshake()
+			conn.Close()
+		}
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true, MaxVersion: tls.VersionTLS11})
+	if err == nil {
+		conn.Close()
+		t.Fatal("Expected TLS 1.1 to be refused")
+	}
+	conn, err = tls.Dial("tcp", ln.Addr().String(), &tls.Config{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("Expected handshake to succeed, got %v", err)
+	}
+	defer conn.Close()
+	if name := conn.ConnectionState().PeerCertificates[0].Subject.CommonName; name != "localhost" {
+		t.Errorf("Expected certificate for localhost, got %s", name)
+	}
+}
@@ -0,0 +1,535 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/TaiTitans/go-balancer/accesslog"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/discovery"
+	"github.com/TaiTitans/go-balancer/features"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/sticky"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+// MaxBackendWeight is the largest accepted backend weight
+const MaxBackendWeight = backend.MaxWeight
+
+// ValidationError lists every problem found in a configuration
+type ValidationError struct {
+	Problems []string
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%d config problem(s):\n  - %s", len(e.Problems), strings.Join(e.Problems, "\n  - "))
+}
+
+// Validate checks the configuration and returns a *ValidationError listing all
+// problems, or nil when the configuration is usable
+func (c *Config) Validate() error {
+	var problems []string
+	add := func(format string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	// Server
+	if c.Server.Port < 1 || c.Server.Port > 65535 {
+		add("server.port %d is out of range (1-65535)", c.Server.Port)
+	}
+	if c.Server.ReadTimeout < 0 {
+		add("server.readTimeout must not be negative")
+	}
+	if c.Server.WriteTimeout < 0 {
+		add("server.writeTimeout must not be negative")
+	}
+	if c.Server.IdleTimeout < 0 {
+		add("server.idleTimeout must not be negative")
+	}
+	if c.Server.DrainTimeout < 0 {
+		add("server.drainTimeout must not be negative")
+	}
+	if c.Server.MaxInFlight < 0 {
+		add("server.maxInFlight must not be negative")
+	}
+	if err := c.Server.AdaptiveLimit.Validate(); err != nil {
+		add("server.adaptiveLimit: %v", err)
+	}
+	if c.Server.MaxRequestBytes < 0 {
+		add("server.maxRequestBytes must not be negative")
+	}
+
+	// Admin
+	if c.Admin.Port != 0 {
+		if c.Admin.Port < 1 || c.Admin.Port > 65535 {
+			add("admin.port %d is out of range (1-65535)", c.Admin.Port)
+		} else if c.Admin.Port == c.Server.Port {
+			add("admin.port must differ from server.port")
+		}
+		if c.Admin.Token == "" {
+			add("admin.port requires admin.token")
+		}
+	}
+
+	// Backends and pools
+	discovering := c.Discovering()
+	if len(c.Backends) == 0 && len(c.Pools) == 0 && !discovering {
+		add("backends: at least one backend, pool or discovery provider is required")
+	}
+	validateBackends("backends", c.Backends, add)
+
+	poolNames := make(map[string]bool)
+	if len(c.Backends) > 0 || discovering {
+		poolNames[DefaultPoolName] = true
+	}
+	for i, p := range c.Pools {
+		field := fmt.Sprintf("pools[%d]", i)
+		switch {
+		case p.Name == "":
+			add("%s.name is empty", field)
+		case poolNames[p.Name] && p.Name == DefaultPoolName:
+			add("%s.name %q is reserved for the top-level backends list", field, p.Name)
+		case poolNames[p.Name]:
+			add("%s.name %q is used by another pool", field, p.Name)
+		}
+		poolNames[p.Name] = true
+		if len(p.Backends) == 0 {
+			add("%s.backends: at least one backend is required", field)
+		}
+		validateBackends(field+".backends", p.Backends, add)
+		if p.Strategy.Type != "" && !strategy.Registered(p.Strategy.Type) {
+			add("%s.strategy.type %q is unknown (valid: %s)", field, p.Strategy.Type, strings.Join(strategy.Names(), ", "))
+		}
+		if err := p.Strategy.Subset.Validate(); err != nil {
+			add("%s.strategy.subset: %v", field, err)
+		}
+		if p.ErrorPage != nil {
+			if err := p.ErrorPage.Validate(); err != nil {
+				add("%s.errorPage: %v", field, err)
+			}
+		}
+	}
+
+	// Routes
+	for i, r := range c.Routes {
+		field := fmt.Sprintf("routes[%d]", i)
+		if r.Pool == "" {
+			add("%s.pool is empty", field)
+		} else if !poolNames[r.Pool] {
+			add("%s.pool %q does not exist", field, r.Pool)
+		}
+		if r.Match.PathPrefix != "" && !strings.HasPrefix(r.Match.PathPrefix, "/") {
+			add("%s.match.pathPrefix %q must start with /", field, r.Match.PathPrefix)
+		}
+		if h := r.Match.Host; h != "" && (strings.ContainsAny(h, "/ ") || strings.Contains(strings.TrimPrefix(h, "*."), "*")) {
+			add("%s.match.host %q must be a host name or *.domain wildcard", field, h)
+		}
+		for _, m := range r.Match.Methods {
+			if m == "" || strings.ContainsAny(m, " \t\r\n") {
+				add("%s.match.methods: %q is not an HTTP method", field, m)
+			}
+		}
+		for _, m := range r.Match.Headers {
+			if err := m.Validate(); err != nil {
+				add("%s.match.headers: %v", field, err)
+			}
+		}
+		for _, m := range r.Match.Query {
+			if err := m.Validate(); err != nil {
+				add("%s.match.query: %v", field, err)
+			}
+		}
+		if r.Strategy != "" && !strategy.Registered(r.Strategy) {
+			add("%s.strategy %q is unknown (valid: %s)", field, r.Strategy, strings.Join(strategy.Names(), ", "))
+		}
+		if err := r.Subset.Validate(); err != nil {
+			add("%s.subset: %v", field, err)
+		}
+		if r.Middleware.StripPrefix && r.Match.PathPrefix == "" {
+			add("%s.middleware.stripPrefix requires match.pathPrefix", field)
+		}
+		if rw := r.Middleware.Rewrite; rw != "" {
+			switch {
+			case r.Match.PathPrefix == "":
+				add("%s.middleware.rewrite requires match.pathPrefix", field)
+			case !strings.HasPrefix(rw, "/"):
+				add("%s.middleware.rewrite %q must start with /", field, rw)
+			case r.Middleware.StripPrefix:
+				add("%s.middleware.rewrite and stripPrefix are exclusive", field)
+			}
+		}
+		if r.Middleware.Timeout < 0 || r.Middleware.ResponseTimeout < 0 || r.Middleware.IdleTimeout < 0 {
+			add("%s.middleware timeouts must not be negative", field)
+		}
+	}
+
+	// Blue/green
+	if bg := c.BlueGreen; bg.Enabled() {
+		if len(bg.Pools) != 2 || bg.Pools[0] == bg.Pools[1] {
+			add("blueGreen.pools must name two different pools, got %v", bg.Pools)
+		}
+		for _, name := range bg.Pools {
+			if !poolNames[name] {
+				add("blueGreen.pools: pool %q does not exist", name)
+			}
+		}
+		if !slices.Contains(bg.Pools, bg.ActivePool()) {
+			add("blueGreen.active %q is not one of blueGreen.pools", bg.Active)
+		}
+	} else if c.BlueGreen.Active != "" {
+		add("blueGreen.active requires blueGreen.pools")
+	}
+
+	// Instances
+	instanceNames := make(map[string]bool)
+	ports := map[int]string{c.Server.Port: "server.port"}
+	if c.Admin.Port != 0 {
+		ports[c.Admin.Port] = "admin.port"
+	}
+	for i, inst := range c.Instances {
+		field := fmt.Sprintf("instances[%d]", i)
+		switch {
+		case inst.Name == "":
+			add("%s.name is empty", field)
+		case instanceNames[inst.Name]:
+			add("%s.name %q is used by another instance", field, inst.Name)
+		}
+		instanceNames[inst.Name] = true
+		if inst.Port < 1 || inst.Port > 65535 {
+			add("%s.port %d is out of range (1-65535)", field, inst.Port)
+		} else if other, ok := ports[inst.Port]; ok {
+			add("%s.port %d is already used by %s", field, inst.Port, other)
+		} else {
+			ports[inst.Port] = field + ".port"
+		}
+		switch {
+		case inst.Pool == "":
+			add("%s.pool is empty", field)
+		case !poolNames[inst.Pool]:
+			add("%s.pool %q does not exist", field, inst.Pool)
+		case inst.Pool == DefaultPoolName && discovering:
+			add("%s.pool %q is fed by discovery and only served on server.port", field, inst.Pool)
+		}
+	}
+
+	// Cluster
+	if c.Cluster.Enabled() {
+		if _, _, err := net.SplitHostPort(c.Cluster.Bind); err != nil {
+			add("cluster.bind %q must be host:port", c.Cluster.Bind)
+		}
+		if c.Cluster.Secret == "" {
+			add("cluster.secret is required with cluster.bind")
+		}
+		if c.Cluster.Advertise != "" {
+			if _, _, err := net.SplitHostPort(c.Cluster.Advertise); err != nil {
+				add("cluster.advertise %q must be host:port", c.Cluster.Advertise)
+			}
+		}
+		for i, peer := range c.Cluster.Peers {
+			if _, _, err := net.SplitHostPort(peer); err != nil {
+				add("cluster.peers[%d] %q must be host:port", i, peer)
+			}
+		}
+		if c.Cluster.Interval < 0 {
+			add("cluster.interval must not be negative")
+		}
+		if c.Cluster.Fanout < 0 {
+			add("cluster.fanout must not be negative")
+		}
+	} else if len(c.Cluster.Peers) > 0 {
+		add("cluster.peers requires cluster.bind")
+	}
+
+	// Feature flags
+	for name := range c.Features {
+		if !slices.Contains(features.Known(), name) {
+			add("features.%s is unknown (valid: %s)", name, strings.Join(features.Known(), ", "))
+		}
+	}
+
+	// Fault injection
+	for i, rule := range c.Chaos.Rules {
+		if err := rule.Validate(); err != nil {
+			add("chaos.rules[%d]: %v", i, err)
+		}
+	}
+	if len(c.Chaos.Rules) > 0 && !c.Chaos.Enabled {
+		add("chaos.rules requires chaos.enabled")
+	}
+
+	// Scheduled weight changes
+	for i, sc := range c.Schedules {
+		if err := sc.Validate(); err != nil {
+			add("schedules[%d]: %v", i, err)
+		}
+	}
+
+	// Sticky sessions
+	switch strings.ToLower(c.Sticky.Store) {
+	case "", sticky.StoreNone, sticky.StoreMemory:
+	case sticky.StoreRedis:
+		if _, _, err := net.SplitHostPort(c.Sticky.Redis.Addr); err != nil {
+			add("sticky.redis.addr %q must be host:port", c.Sticky.Redis.Addr)
+		}
+		if c.Sticky.Redis.DB < 0 {
+			add("sticky.redis.db must not be negative")
+		}
+	default:
+		add("sticky.store %q is unknown (valid: none, memory, redis)", c.Sticky.Store)
+	}
+	if c.Sticky.TTL < 0 {
+		add("sticky.ttl must not be negative")
+	}
+
+	// Discovery
+	switch strings.ToLower(c.Discovery.HealthPolicy) {
+	case "", discovery.HealthPolicyRegistered, discovery.HealthPolicyHealthy:
+	default:
+		add("discovery.healthPolicy %q is unknown (valid: registered, healthy)", c.Discovery.HealthPolicy)
+	}
+	if c.Discovery.GracePeriod < 0 {
+		add("discovery.gracePeriod must not be negative")
+	}
+	switch strings.ToLower(c.Discovery.Type) {
+	case "", discovery.TypeNone:
+	case discovery.TypeFile:
+		if c.Discovery.File.Path == "" {
+			add("discovery.file.path is required")
+		}
+	case discovery.TypeDNS:
+		if c.Discovery.DNS.Name == "" {
+			add("discovery.dns.name is required")
+		}
+		if s := c.Discovery.DNS.Scheme; s != "" && s != "http" && s != "https" {
+			add("discovery.dns.scheme %q must be http or https", s)
+		}
+		if c.Discovery.DNS.Port < 0 || c.Discovery.DNS.Port > 65535 {
+			add("discovery.dns.port %d is out of range (0-65535)", c.Discovery.DNS.Port)
+		}
+		if c.Discovery.DNS.Refresh < 0 || c.Discovery.DNS.MinRefresh < 0 {
+			add("discovery.dns: refresh intervals must not be negative")
+		}
+	case discovery.TypeEtcd:
+		if len(c.Discovery.Etcd.Endpoints) == 0 {
+			add("discovery.etcd.endpoints: at least one endpoint is required")
+		}
+		for _, e := range c.Discovery.Etcd.Endpoints {
+			if u, err := url.Parse(e); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("discovery.etcd.endpoints: %q must be an http(s) URL", e)
+			}
+		}
+		if c.Discovery.Etcd.Prefix == "" {
+			add("discovery.etcd.prefix is required")
+		}
+	case discovery.TypeDocker:
+		if c.Discovery.Docker.Refresh < 0 {
+			add("discovery.docker.refresh must not be negative")
+		}
+	case discovery.TypeEureka:
+		if len(c.Discovery.Eureka.ServiceURLs) == 0 {
+			add("discovery.eureka.serviceUrls: at least one service URL is required")
+		}
+		for _, s := range c.Discovery.Eureka.ServiceURLs {
+			if u, err := url.Parse(s); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				add("discovery.eureka.serviceUrls: %q must be an http(s) URL", s)
+			}
+		}
+		if c.Discovery.Eureka.App == "" {
+			add("discovery.eureka.app is required")
+		}
+	default:
+		if !discovery.Registered(c.Discovery.Type) {
+			add("discovery.type %q is unknown (valid: none, %s)", c.Discovery.Type, strings.Join(discovery.Types(), ", "))
+		}
+	}
+
+	if err := c.Server.TLS.Validate(); err != nil {
+		add("server.tls: %v", err)
+	}
+
+	// Health check
+	hc := c.HealthCheck
+	if hc.Interval <= 0 {
+		add("healthCheck.interval must be positive, got %v", hc.Interval)
+	}
+	if hc.Timeout <= 0 {
+		add("healthCheck.timeout must be positive, got %v", hc.Timeout)
+	}
+	if hc.Interval > 0 && hc.Timeout > hc.Interval {
+		add("healthCheck.timeout %v exceeds healthCheck.interval %v; probes would overlap", hc.Timeout, hc.Interval)
+	}
+	if hc.Path != "" && !strings.HasPrefix(hc.Path, "/") {
+		add("healthCheck.path %q must start with /", hc.Path)
+	}
+	if err := hc.Probe().Validate(); err != nil {
+		add("healthCheck: %v", err)
+	}
+
+	// Transport
+	if err := c.Transport.Validate(); err != nil {
+		add("transport: %v", err)
+	}
+
+	// Queue
+	if err := c.Queue.Validate(); err != nil {
+		add("queue: %v", err)
+	}
+
+	// Outlier detection
+	if err := c.OutlierDetection.Validate(); err != nil {
+		add("outlierDetection: %v", err)
+	}
+
+	// Tracing
+	if err := c.Tracing.Validate(); err != nil {
+		add("tracing: %v", err)
+	}
+
+	// Notifications
+	for i, n := range c.Notifications {
+		if err := n.Validate(); err != nil {
+			add("notifications[%d]: %v", i, err)
+		}
+	}
+
+	// Error page
+	if err := c.ErrorPage.Validate(); err != nil {
+		add("errorPage: %v", err)
+	}
+
+	// Trusted proxies
+	if _, err := backend.ParseTrustedProxies(c.TrustedProxies); err != nil {
+		add("trustedProxies: %v", err)
+	}
+
+	// Strategy
+	if !strategy.Registered(c.Strategy.Type) {
+		add("strategy.type %q is unknown (valid: %s)", c.Strategy.Type, strings.Join(strategy.Names(), ", "))
+	}
+	if err := c.Strategy.Subset.Validate(); err != nil {
+		add("strategy.subset: %v", err)
+	}
+
+	// Logging
+	if c.Logging.Level != "" && !slices.Contains([]string{"debug", "info", "warn", "error"}, strings.ToLower(c.Logging.Level)) {
+		add("logging.level %q is unknown (valid: debug, info, warn, error)", c.Logging.Level)
+	}
+	if c.Logging.Format != "" && !slices.Contains([]string{"text", "json"}, strings.ToLower(c.Logging.Format)) {
+		add("logging.format %q is unknown (valid: text, json)", c.Logging.Format)
+	}
+
+	// Access log
+	switch strings.ToLower(c.AccessLog.Sink) {
+	case "", accesslog.SinkNone, accesslog.SinkStdout, accesslog.SinkSyslog:
+	case accesslog.SinkFile:
+		if c.AccessLog.Path == "" {
+			add("accessLog.path is required for the file sink")
+		}
+	case accesslog.SinkHTTP:
+		if c.AccessLog.URL == "" {
+			add("accessLog.url is required for the http sink")
+		}
+	default:
+		add("accessLog.sink %q is unknown (valid: none, stdout, file, syslog, http)", c.AccessLog.Sink)
+	}
+	if f := c.AccessLog.Format; f != "" && !slices.Contains([]string{accesslog.FormatJSON, accesslog.FormatCommon, accesslog.FormatCombined}, strings.ToLower(f)) {
+		add("accessLog.format %q is unknown (valid: json, common, combined)", f)
+	}
+
+	// Metrics push
+	switch strings.ToLower(c.Metrics.Push.Mode) {
+	case "", metrics.PushNone:
+	case metrics.PushGateway, metrics.PushRemoteWrite:
+		if c.Metrics.Push.URL == "" {
+			add("metrics.push.url is required for push mode %s", c.Metrics.Push.Mode)
+		}
+		if c.Metrics.Push.Interval < 0 {
+			add("metrics.push.interval must not be negative")
+		}
+		if err := c.Metrics.Push.ValidateLabels(); err != nil {
+			add("metrics.push: %v", err)
+		}
+	default:
+		add("metrics.push.mode %q is unknown (valid: none, pushgateway, remotewrite)", c.Metrics.Push.Mode)
+	}
+
+	if len(problems) > 0 {
+		return &ValidationError{Problems: problems}
+	}
+	return nil
+}
+
+// validateBackends checks URL syntax, duplicates and weights of one backend list
+func validateBackends(prefix string, backends []BackendConfig, add func(string, ...interface{})) {
+	seen := make(map[string]int)
+	for i, b := range backends {
+		field := fmt.Sprintf("%s[%d]", prefix, i)
+		u, err := url.Parse(b.URL)
+		switch {
+		case b.URL == "":
+			add("%s.url is empty", field)
+		case err != nil:
+			add("%s.url %q is not a valid URL: %v", field, b.URL, err)
+		case u.Scheme != "http" && u.Scheme != "https":
+			add("%s.url %q must use http or https (e.g. http://%s)", field, b.URL, strings.TrimPrefix(b.URL, u.Scheme+"://"))
+		case u.Host == "":
+			add("%s.url %q has no host", field, b.URL)
+		}
+
+		key := strings.TrimSuffix(strings.ToLower(b.URL), "/")
+		if prev, dup := seen[key]; dup && b.URL != "" {
+			add("%s.url %q duplicates %s[%d]", field, b.URL, prefix, prev)
+		} else {
+			seen[key] = i
+		}
+
+		if b.Weight < 0 || b.Weight > MaxBackendWeight {
+			add("%s.weight %d is out of range (0-%d, 0 means 1)", field, b.Weight, MaxBackendWeight)
+		}
+		if b.HealthPath != "" && !strings.HasPrefix(b.HealthPath, "/") {
+			add("%s.healthPath %q must start with /", field, b.HealthPath)
+		}
+		if b.HealthInterval < 0 {
+			add("%s.healthInterval must not be negative", field)
+		}
+		probe := backend.HealthCheck{Method: b.HealthMethod, ExpectedStatus: b.HealthStatus}
+		if err := probe.Validate(); err != nil {
+			add("%s health check: %v", field, err)
+		}
+		if b.Priority < 0 {
+			add("%s.priority must not be negative", field)
+		}
+		if b.MaxConnections < 0 {
+			add("%s.maxConnections must not be negative", field)
+		}
+		if b.MaxFails < 0 {
+			add("%s.maxFails must not be negative", field)
+		}
+		if b.FailWindow < 0 || b.FailTimeout < 0 {
+			add("%s: failWindow and failTimeout must not be negative", field)
+		}
+		if b.DialTimeout < 0 || b.ResponseTimeout < 0 || b.RequestTimeout < 0 || b.IdleTimeout < 0 {
+			add("%s: timeouts must not be negative", field)
+		}
+		if err := b.Transport.Validate(); err != nil {
+			add("%s.transport: %v", field, err)
+		}
+		if b.ResponseTimeAlpha < 0 || b.ResponseTimeAlpha > 1 {
+			add("%s.responseTimeAlpha %v is out of range (0-1, 0 means %v)", field, b.ResponseTimeAlpha, backend.DefaultResponseTimeAlpha)
+		}
+		if (b.TLS.CertFile == "") != (b.TLS.KeyFile == "") {
+			add("%s.tls: certFile and keyFile must be set together", field)
+		}
+		if !b.TLS.IsZero() && err == nil && u.Scheme == "http" {
+			add("%s.tls is set but url %q uses http", field, b.URL)
+		}
+		for k := range b.Labels {
+			if k == "" {
+				add("%s.labels: label names must not be empty", field)
+			}
+		}
+	}
+}
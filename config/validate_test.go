@@ -0,0 +1,155 @@
+package config
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/chaos"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/notify"
+	"github.com/TaiTitans/go-balancer/router"
+	"github.com/TaiTitans/go-balancer/schedule"
+)
+
+func TestValidate_Default(t *testing.T) {
+	if err := DefaultConfig().Validate(); err != nil {
+		t.Errorf("Expected default config to be valid, got %v", err)
+	}
+}
+
+func TestValidate_Problems(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(*Config)
+		want   string
+	}{
+		{"port", func(c *Config) { c.Server.Port = 70000 }, "server.port"},
+		{"admin port", func(c *Config) { c.Admin.Port = c.Server.Port; c.Admin.Token = "t" }, "admin.port must differ"},
+		{"admin port token", func(c *Config) { c.Admin.Port = 9090 }, "admin.port requires admin.token"},
+		{"cluster peer", func(c *Config) {
+			c.Cluster.Bind = ":7946"
+			c.Cluster.Peers = []string{"lb-2"}
+		}, `cluster.peers[0] "lb-2" must be host:port`},
+		{"cluster secret", func(c *Config) { c.Cluster.Bind = ":7946" }, "cluster.secret is required"},
+		{"push labels", func(c *Config) {
+			c.Metrics.Push = metrics.PushConfig{Mode: metrics.PushRemoteWrite, URL: "http://prometheus:9090", Labels: map[string]string{"__name__": "x"}}
+		}, `metrics.push: label name "__name__" is reserved`},
+		{"feature flag", func(c *Config) { c.Features = map[string]bool{"retries": false} }, "features.retries is unknown"},
+		{"transport", func(c *Config) { c.Transport.MaxIdleConnsPerHost = -1 }, "transport: connection limits must not be negative"},
+		{"reap interval", func(c *Config) { c.Transport.ReapInterval = -time.Second }, "transport: reapInterval must not be negative"},
+		{"max in flight", func(c *Config) { c.Server.MaxInFlight = -1 }, "server.maxInFlight must not be negative"},
+		{"adaptive limit", func(c *Config) { c.Server.AdaptiveLimit = balancer.AdaptiveLimitConfig{MinLimit: 50, MaxLimit: 10} }, "server.adaptiveLimit: minLimit 50 exceeds maxLimit 10"},
+		{"max request bytes", func(c *Config) { c.Server.MaxRequestBytes = -1 }, "server.maxRequestBytes must not be negative"},
+		{"tls key missing", func(c *Config) { c.Server.TLS.CertFile = "cert.pem" }, "server.tls: certFile and keyFile must be set together"},
+		{"tls min version", func(c *Config) { c.Server.TLS.MinVersion = "1.4" }, `minVersion "1.4" is unknown`},
+		{"tls cipher suite", func(c *Config) { c.Server.TLS.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"} }, `cipher suite "TLS_RSA_WITH_RC4_128_SHA" is unknown or insecure`},
+		{"queue", func(c *Config) { c.Queue.MaxLength = -1 }, "queue: maxLength must not be negative"},
+		{"access log format", func(c *Config) { c.AccessLog.Format = "apache" }, `accessLog.format "apache" is unknown`},
+		{"tracing endpoint", func(c *Config) { c.Tracing.Endpoint = "collector:4318" }, `tracing: endpoint "collector:4318" must be an http(s) URL`},
+		{"notification format", func(c *Config) {
+			c.Notifications = []notify.Config{{URL: "https://hooks.example.com", Format: "teams"}}
+		}, `notifications[0]: format "teams" is unknown`},
+		{"outlier detection", func(c *Config) { c.OutlierDetection.MaxEjectionPercent = 150 }, "outlierDetection: maxEjectionPercent 150 is out of range"},
+		{"backend transport", func(c *Config) { c.Backends[0].Transport.IdleConnTimeout = -time.Second }, "backends[0].transport: idleConnTimeout must not be negative"},
+		{"strategy subset", func(c *Config) { c.Strategy.Subset.Fallback = true }, "strategy.subset: fallback requires labels"},
+		{"backend priority", func(c *Config) { c.Backends[0].Priority = -1 }, "backends[0].priority must not be negative"},
+		{"response time alpha", func(c *Config) { c.Backends[0].ResponseTimeAlpha = 1.5 }, "backends[0].responseTimeAlpha 1.5 is out of range"},
+		{"health status", func(c *Config) { c.HealthCheck.ExpectedStatus = []int{2000} }, "healthCheck: expected status 2000 is out of range"},
+		{"backend health method", func(c *Config) { c.Backends[0].HealthMethod = "get" }, `backends[0] health check: method "get"`},
+		{"fail timeout", func(c *Config) { c.Backends[0].FailTimeout = -time.Second }, "backends[0]: failWindow and failTimeout must not be negative"},
+		{"instance port", func(c *Config) {
+			c.Pools = []PoolConfig{{Name: "api", Backends: []BackendConfig{{URL: "http://localhost:9001"}}}}
+			c.Instances = []InstanceConfig{{Name: "api", Port: c.Server.Port, Pool: "api"}}
+		}, "instances[0].port 8080 is already used by server.port"},
+		{"instance pool", func(c *Config) {
+			c.Instances = []InstanceConfig{{Name: "api", Port: 9001, Pool: "api"}}
+		}, `instances[0].pool "api" does not exist`},
+		{"chaos rate", func(c *Config) {
+			c.Chaos = chaos.Config{Enabled: true, Rules: []chaos.Rule{{ErrorRate: 1.5}}}
+		}, "chaos.rules[0]: errorRate 1.5 is out of range"},
+		{"chaos disabled", func(c *Config) {
+			c.Chaos.Rules = []chaos.Rule{{Latency: time.Second}}
+		}, "chaos.rules requires chaos.enabled"},
+		{"schedule", func(c *Config) {
+			c.Schedules = []schedule.Config{{Backend: "http://localhost:8081", To: 5, Daily: "7pm"}}
+		}, `schedules[0]: daily "7pm" must be HH:MM`},
+		{"sticky store", func(c *Config) { c.Sticky.Store = "memcached" }, `sticky.store "memcached"`},
+		{"sticky redis addr", func(c *Config) { c.Sticky.Store = "redis" }, `sticky.redis.addr "" must be host:port`},
+		{"url scheme", func(c *Config) { c.Backends[0].URL = "localhost:8081" }, "must use http or https"},
+		{"url host", func(c *Config) { c.Backends[0].URL = "http://" }, "has no host"},
+		{"duplicate", func(c *Config) { c.Backends[1].URL = c.Backends[0].URL + "/" }, "duplicates backends[0]"},
+		{"weight", func(c *Config) { c.Backends[0].Weight = -1 }, "weight"},
+		{"timeout > interval", func(c *Config) { c.HealthCheck.Timeout = time.Minute }, "exceeds healthCheck.interval"},
+		{"zero interval", func(c *Config) { c.HealthCheck.Interval = 0 }, "healthCheck.interval must be positive"},
+		{"strategy", func(c *Config) { c.Strategy.Type = "fastest" }, `strategy.type "fastest"`},
+		{"no backends", func(c *Config) { c.Backends = nil }, "at least one backend"},
+		{"push url", func(c *Config) { c.Metrics.Push.Mode = "pushgateway" }, "metrics.push.url"},
+		{"dns name", func(c *Config) { c.Discovery.Type = "dns" }, "discovery.dns.name is required"},
+		{"etcd prefix", func(c *Config) {
+			c.Discovery.Type = "etcd"
+			c.Discovery.Etcd.Endpoints = []string{"http://etcd:2379"}
+		}, "discovery.etcd.prefix is required"},
+		{"discovery type", func(c *Config) { c.Discovery.Type = "zookeeper" }, `discovery.type "zookeeper"`},
+		{"reserved pool name", func(c *Config) {
+			c.Pools = []PoolConfig{{Name: DefaultPoolName, Backends: []BackendConfig{{URL: "http://api:80"}}}}
+		}, "is reserved"},
+		{"empty pool", func(c *Config) { c.Pools = []PoolConfig{{Name: "api"}} }, "pools[0].backends: at least one"},
+		{"unknown route pool", func(c *Config) { c.Routes = []RouteConfig{{Pool: "missing"}} }, `pool "missing" does not exist`},
+		{"route path", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Match: RouteMatch{PathPrefix: "api"}}}
+		}, "must start with /"},
+		{"strip without prefix", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Middleware: RouteMiddleware{StripPrefix: true}}}
+		}, "stripPrefix requires"},
+		{"route header regex", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Match: RouteMatch{Headers: []router.ValueMatch{{Name: "X-Beta", Regex: "("}}}}}
+		}, "routes[0].match.headers: X-Beta"},
+		{"route query name", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Match: RouteMatch{Query: []router.ValueMatch{{Value: "1"}}}}}
+		}, "routes[0].match.query: name is empty"},
+		{"rewrite without prefix", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Middleware: RouteMiddleware{Rewrite: "/v2/"}}}
+		}, "rewrite requires"},
+		{"rewrite and strip", func(c *Config) {
+			c.Routes = []RouteConfig{{Pool: DefaultPoolName, Match: RouteMatch{PathPrefix: "/api/"}, Middleware: RouteMiddleware{StripPrefix: true, Rewrite: "/v2/"}}}
+		}, "are exclusive"},
+		{"blue/green single pool", func(c *Config) { c.BlueGreen.Pools = []string{DefaultPoolName} }, "blueGreen.pools must name two different pools"},
+		{"blue/green unknown pool", func(c *Config) { c.BlueGreen.Pools = []string{DefaultPoolName, "green"} }, `blueGreen.pools: pool "green" does not exist`},
+		{"blue/green active", func(c *Config) {
+			c.Pools = []PoolConfig{{Name: "green", Backends: []BackendConfig{{URL: "http://green:80"}}}}
+			c.BlueGreen = BlueGreenConfig{Pools: []string{DefaultPoolName, "green"}, Active: "blue"}
+		}, `blueGreen.active "blue" is not one of`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := DefaultConfig()
+			tt.modify(cfg)
+			err := cfg.Validate()
+			if err == nil {
+				t.Fatal("Expected validation error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Expected error containing %q, got %v", tt.want, err)
+			}
+		})
+	}
+}
+
+func TestValidate_ReportsAllProblems(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Server.Port = 0
+	cfg.Strategy.Type = "nope"
+	cfg.Backends[0].URL = "ftp://example.com"
+
+	var verr *ValidationError
+	if !errors.As(cfg.Validate(), &verr) {
+		t.Fatal("Expected a *ValidationError")
+	}
+	if len(verr.Problems) != 3 {
+		t.Errorf("Expected 3 problems, got %d: %v", len(verr.Problems), verr.Problems)
+	}
+}
@@ -0,0 +1,151 @@
+package config
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultDebounce is how long the watcher waits for writes to settle
+const DefaultDebounce = 500 * time.Millisecond
+
+// Watcher reloads a config file when it changes on disk, applying only
+// configurations that pass validation
+type Watcher struct {
+	path     string
+	extra    []string
+	debounce time.Duration
+	load     func() (*Config, error)
+	apply    func(*Config) error
+
+	mu      sync.RWMutex
+	current *Config
+	// reloading serializes reloads from the file watch, SIGHUP and the
+	// admin API
+	reloading sync.Mutex
+}
+
+// NewWatcher creates a watcher for path; load reads and merges the config
+// (LoadConfig when nil) and apply activates a validated config. initial is
+// the config currently in effect
+func NewWatcher(path string, initial *Config, load func() (*Config, error), apply func(*Config) error) *Watcher {
+	if load == nil {
+		load = func() (*Config, error) { return LoadConfig(path) }
+	}
+	return &Watcher{
+		path:     path,
+		debounce: DefaultDebounce,
+		load:     load,
+		apply:    apply,
+		current:  initial,
+	}
+}
+
+// Also watches an additional file (such as a profile overlay) that
+// contributes to the config; call it before Run
+func (w *Watcher) Also(path string) {
+	w.extra = append(w.extra, path)
+}
+
+// SetDebounce changes the settle delay before a reload
+func (w *Watcher) SetDebounce(d time.Duration) {
+	w.debounce = d
+}
+
+// Current returns the last successfully applied config
+func (w *Watcher) Current() *Config {
+	w.mu.RLock()
+	defer w.mu.RUnlock()
+	return w.current
+}
+
+// Run watches the file until ctx is canceled. The parent directory is watched
+// so editors that replace the file via rename are handled too
+func (w *Watcher) Run(ctx context.Context) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create config watcher: %w", err)
+	}
+	defer fw.Close()
+
+	targets := make(map[string]bool)
+	dirs := make(map[string]bool)
+	for _, path := range append([]string{w.path}, w.extra...) {
+		targets[filepath.Clean(path)] = true
+		dir := filepath.Dir(path)
+		if dirs[dir] {
+			continue
+		}
+		if err := fw.Add(dir); err != nil {
+			return fmt.Errorf("failed to watch %s: %w", path, err)
+		}
+		dirs[dir] = true
+	}
+
+	var timer *time.Timer
+	var fire <-chan time.Time
+	for {
+		select {
+		case <-ctx.Done():
+			if timer != nil {
+				timer.Stop()
+			}
+			return nil
+		case ev, ok := <-fw.Events:
+			if !ok {
+				return nil
+			}
+			if !targets[filepath.Clean(ev.Name)] || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+				continue
+			}
+			if timer == nil {
+				timer = time.NewTimer(w.debounce)
+			} else {
+				timer.Reset(w.debounce)
+			}
+			fire = timer.C
+		case err, ok := <-fw.Errors:
+			if !ok {
+				return nil
+			}
+			logging.Logger().Warn("config watch error", "error", err)
+		case <-fire:
+			fire = nil
+			w.Reload()
+		}
+	}
+}
+
+// Reload loads, validates and applies the config file once; on failure the
+// last good config stays active. Concurrent reloads run one at a time.
+func (w *Watcher) Reload() error {
+	w.reloading.Lock()
+	defer w.reloading.Unlock()
+	cfg, err := w.load()
+	if err != nil {
+		logging.Logger().Error("config reload rejected, keeping last good config", "error", err)
+		return err
+	}
+	if err := cfg.Validate(); err != nil {
+		logging.Logger().Error("config reload rejected, keeping last good config", "error", err)
+		return err
+	}
+	if w.apply != nil {
+		if err := w.apply(cfg); err != nil {
+			logging.Logger().Error("config reload failed to apply, keeping last good config", "error", err)
+			return err
+		}
+	}
+
+	w.mu.Lock()
+	w.current = cfg
+	w.mu.Unlock()
+	logging.Logger().Info("config reloaded", "path", w.path)
+	return nil
+}
@@ -0,0 +1,59 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWatcher_AppliesValidAndRejectsInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.json")
+	if err := os.WriteFile(path, []byte(`{"server":{"port":9000}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	initial, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	applied := make(chan *Config, 4)
+	w := NewWatcher(path, initial, nil, func(c *Config) error {
+		applied <- c
+		return nil
+	})
+	w.SetDebounce(20 * time.Millisecond)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go w.Run(ctx)
+	time.Sleep(50 * time.Millisecond)
+
+	// Invalid: port out of range, must be rejected
+	os.WriteFile(path, []byte(`{"server":{"port":0}}`), 0o644)
+	select {
+	case c := <-applied:
+		t.Fatalf("Invalid config was applied: port %d", c.Server.Port)
+	case <-time.After(200 * time.Millisecond):
+	}
+	if w.Current().Server.Port != 9000 {
+		t.Errorf("Expected last good port 9000, got %d", w.Current().Server.Port)
+	}
+
+	// Several quick writes are debounced into a single reload
+	for _, port := range []string{"9001", "9002", "9003"} {
+		os.WriteFile(path, []byte(`{"server":{"port":`+port+`}}`), 0o644)
+	}
+	select {
+	case c := <-applied:
+		if c.Server.Port != 9003 {
+			t.Errorf("Expected port 9003, got %d", c.Server.Port)
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("Valid config was not applied")
+	}
+	if w.Current().Server.Port != 9003 {
+		t.Errorf("Expected current port 9003, got %d", w.Current().Server.Port)
+	}
+}
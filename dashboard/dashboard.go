@@ -0,0 +1,248 @@
+package dashboard
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/TaiTitans/go-balancer/metrics"
+)
+
+// Options customizes the generated dashboard
+type Options struct {
+	Title      string
+	UID        string
+	Datasource string // Prometheus datasource name; empty creates an import-time input
+	Refresh    string
+}
+
+// DefaultOptions returns the options used by the dashboard subcommand
+func DefaultOptions() Options {
+	return Options{
+		Title:   "Go Load Balancer",
+		UID:     "go-balancer",
+		Refresh: "10s",
+	}
+}
+
+// panel describes a single time series panel
+type panel struct {
+	title   string
+	unit    string
+	exprs   []target
+	width   int
+	stacked bool
+}
+
+type target struct {
+	expr   string
+	legend string
+}
+
+// panels lists every panel, grouped into rows, using the exported metric names
+func panels() []struct {
+	row    string
+	panels []panel
+} {
+	rate := func(metric string) string { return fmt.Sprintf("rate(%s[$__rate_interval])", metric) }
+	quantile := func(q, metric string) string {
+		return fmt.Sprintf("histogram_quantile(%s, sum by (backend, le) (%s))", q, rate(metric+"_bucket"))
+	}
+
+	return []struct {
+		row    string
+		panels []panel
+	}{
+		{"Traffic", []panel{
+			{title: "Requests per second by backend", unit: "reqps", width: 12, stacked: true, exprs: []target{
+				{fmt.Sprintf("sum by (backend) (%s)", rate(metrics.RequestsTotal)), "{{backend}}"},
+			}},
+			{title: "Error ratio (5xx + no backend)", unit: "percentunit", width: 12, exprs: []target{
+				{fmt.Sprintf("(sum(%s) + sum(%s)) / (sum(%s) + sum(%s))",
+					rate(metrics.RequestsTotal+`{code=~"5.."}`), rate(metrics.NoBackendTotal),
+					rate(metrics.RequestsTotal), rate(metrics.NoBackendTotal)), "error ratio"},
+			}},
+			{title: "Requests by status code", unit: "reqps", width: 12, stacked: true, exprs: []target{
+				{fmt.Sprintf("sum by (code) (%s)", rate(metrics.RequestsTotal)), "{{code}}"},
+			}},
+			{title: "Rejected: no backend available", unit: "reqps", width: 12, exprs: []target{
+				{rate(metrics.NoBackendTotal), "no backend"},
+			}},
+			{title: "Failures by class", unit: "reqps", width: 24, stacked: true, exprs: []target{
+				{fmt.Sprintf("sum by (class) (%s)", rate(metrics.ErrorsTotal)), "{{class}}"},
+			}},
+		}},
+		{"Latency", []panel{
+			{title: "p50 latency by backend", unit: "s", width: 8, exprs: []target{
+				{quantile("0.5", metrics.RequestDurationSeconds), "{{backend}}"},
+			}},
+			{title: "p95 latency by backend", unit: "s", width: 8, exprs: []target{
+				{quantile("0.95", metrics.RequestDurationSeconds), "{{backend}}"},
+			}},
+			{title: "p99 latency by backend", unit: "s", width: 8, exprs: []target{
+				{quantile("0.99", metrics.RequestDurationSeconds), "{{backend}}"},
+			}},
+		}},
+		{"Backends", []panel{
+			{title: "Backend up", unit: "none", width: 8, exprs: []target{
+				{metrics.BackendUp, "{{backend}}"},
+			}},
+			{title: "In-flight requests", unit: "none", width: 8, stacked: true, exprs: []target{
+				{metrics.BackendInFlight, "{{backend}}"},
+			}},
+			{title: "Probe consecutive failures", unit: "none", width: 8, exprs: []target{
+				{metrics.HealthProbeFailureStreak, "{{backend}}"},
+			}},
+			{title: "Probe success ratio", unit: "percentunit", width: 12, exprs: []target{
+				{fmt.Sprintf(`sum by (backend) (%s) / sum by (backend) (%s)`,
+					rate(metrics.HealthProbesTotal+`{result="success"}`), rate(metrics.HealthProbesTotal)), "{{backend}}"},
+			}},
+			{title: "Probe duration p95", unit: "s", width: 12, exprs: []target{
+				{quantile("0.95", metrics.HealthProbeDuration), "{{backend}}"},
+			}},
+			{title: "Bandwidth by backend", unit: "Bps", width: 24, exprs: []target{
+				{rate(metrics.BackendBytesTotal + `{direction="out"}`), "{{backend}} out"},
+				{rate(metrics.BackendBytesTotal + `{direction="in"}`), "{{backend}} in"},
+			}},
+		}},
+		{"Connections", []panel{
+			{title: "Open upstream connections", unit: "none", width: 12, stacked: true, exprs: []target{
+				{metrics.BackendConnections, "{{backend}}"},
+			}},
+			{title: "Dials per second", unit: "ops", width: 12, exprs: []target{
+				{rate(metrics.BackendDialsTotal), "{{backend}}"},
+				{rate(metrics.BackendDialErrorsTotal), "{{backend}} errors"},
+			}},
+			{title: "Mean TLS handshake time", unit: "s", width: 12, exprs: []target{
+				{fmt.Sprintf("%s / %s", rate(metrics.TLSHandshakeSeconds), rate(metrics.TLSHandshakesTotal)), "{{backend}}"},
+			}},
+			{title: "Idle connection reaps", unit: "ops", width: 12, exprs: []target{
+				{rate(metrics.IdleReapedTotal), "{{backend}}"},
+			}},
+			{title: "Mean upstream phase time", unit: "s", width: 12, exprs: []target{
+				{fmt.Sprintf("sum by (phase) (%s) / sum by (phase) (%s)",
+					rate(metrics.BackendPhaseSeconds), rate(metrics.BackendPhasesTotal)), "{{phase}}"},
+			}},
+			{title: "Mean time to first byte by backend", unit: "s", width: 12, exprs: []target{
+				{fmt.Sprintf("%s / %s", rate(metrics.BackendPhaseSeconds+`{phase="ttfb"}`),
+					rate(metrics.BackendPhasesTotal+`{phase="ttfb"}`)), "{{backend}}"},
+			}},
+		}},
+	}
+}
+
+// Generate builds a Grafana dashboard JSON document ready for import
+func Generate(opts Options) ([]byte, error) {
+	if opts.Title == "" {
+		opts.Title = DefaultOptions().Title
+	}
+	if opts.Refresh == "" {
+		opts.Refresh = DefaultOptions().Refresh
+	}
+
+	datasource := map[string]interface{}{"type": "prometheus", "uid": "${DS_PROMETHEUS}"}
+	if opts.Datasource != "" {
+		datasource["uid"] = opts.Datasource
+	}
+
+	var out []interface{}
+	id, y := 1, 0
+	for _, group := range panels() {
+		out = append(out, map[string]interface{}{
+			"id":        id,
+			"type":      "row",
+			"title":     group.row,
+			"collapsed": false,
+			"gridPos":   gridPos(0, y, 24, 1),
+			"panels":    []interface{}{},
+		})
+		id++
+		y++
+
+		x, rowHeight := 0, 0
+		for _, p := range group.panels {
+			if x+p.width > 24 {
+				x = 0
+				y += rowHeight
+			}
+			out = append(out, timeseries(id, p, datasource, gridPos(x, y, p.width, 8)))
+			id++
+			x += p.width
+			rowHeight = 8
+		}
+		y += rowHeight
+	}
+
+	dash := map[string]interface{}{
+		"title":         opts.Title,
+		"uid":           opts.UID,
+		"tags":          []string{"go-balancer", "load-balancer"},
+		"timezone":      "browser",
+		"schemaVersion": 39,
+		"version":       1,
+		"editable":      true,
+		"refresh":       opts.Refresh,
+		"time":          map[string]string{"from": "now-1h", "to": "now"},
+		"templating": map[string]interface{}{
+			"list": []interface{}{},
+		},
+		"annotations": map[string]interface{}{
+			"list": []interface{}{},
+		},
+		"panels": out,
+	}
+	if opts.Datasource == "" {
+		dash["__inputs"] = []interface{}{map[string]interface{}{
+			"name":     "DS_PROMETHEUS",
+			"label":    "Prometheus",
+			"type":     "datasource",
+			"pluginId": "prometheus",
+		}}
+	}
+
+	return json.MarshalIndent(dash, "", "  ")
+}
+
+func timeseries(id int, p panel, datasource map[string]interface{}, pos map[string]int) map[string]interface{} {
+	targets := make([]interface{}, 0, len(p.exprs))
+	for i, t := range p.exprs {
+		targets = append(targets, map[string]interface{}{
+			"refId":        string(rune('A' + i)),
+			"datasource":   datasource,
+			"expr":         t.expr,
+			"legendFormat": t.legend,
+			"exemplar":     true,
+		})
+	}
+
+	stacking := "none"
+	if p.stacked {
+		stacking = "normal"
+	}
+
+	return map[string]interface{}{
+		"id":         id,
+		"type":       "timeseries",
+		"title":      p.title,
+		"datasource": datasource,
+		"gridPos":    pos,
+		"targets":    targets,
+		"fieldConfig": map[string]interface{}{
+			"defaults": map[string]interface{}{
+				"unit": p.unit,
+				"custom": map[string]interface{}{
+					"stacking":    map[string]string{"mode": stacking},
+					"fillOpacity": 10,
+				},
+			},
+			"overrides": []interface{}{},
+		},
+		"options": map[string]interface{}{
+			"legend":  map[string]interface{}{"displayMode": "list", "placement": "bottom"},
+			"tooltip": map[string]string{"mode": "multi"},
+		},
+	}
+}
+
+func gridPos(x, y, w, h int) map[string]int {
+	return map[string]int{"x": x, "y": y, "w": w, "h": h}
+}
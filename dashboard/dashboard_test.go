@@ -0,0 +1,76 @@
+package dashboard
+
+import (
+	"bytes"
+	"encoding/json"
+	"regexp"
+	"strings"
+	"testing"
+
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/metrics"
+	"github.com/TaiTitans/go-balancer/strategy"
+)
+
+func TestGenerate_MatchesExportedMetrics(t *testing.T) {
+	data, err := Generate(DefaultOptions())
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+
+	var dash struct {
+		Panels []struct {
+			Type    string `json:"type"`
+			Targets []struct {
+				Expr string `json:"expr"`
+			} `json:"targets"`
+		} `json:"panels"`
+	}
+	if err := json.Unmarshal(data, &dash); err != nil {
+		t.Fatalf("Dashboard is not valid JSON: %v", err)
+	}
+
+	// Render the families a real load balancer exports
+	reg := metrics.NewRegistry()
+	if _, err := balancer.NewLoadBalancer(balancer.Config{
+		BackendURLs:     []string{"http://localhost:8081"},
+		Strategy:        strategy.NewRoundRobin(),
+		MetricsRegistry: reg,
+	}); err != nil {
+		t.Fatalf("Failed to create load balancer: %v", err)
+	}
+	var exposition bytes.Buffer
+	reg.Write(&exposition, metrics.FormatText)
+
+	metricName := regexp.MustCompile(`lb_[a-z_]+`)
+	seen := 0
+	for _, p := range dash.Panels {
+		for _, target := range p.Targets {
+			for _, name := range metricName.FindAllString(target.Expr, -1) {
+				seen++
+				family := strings.TrimSuffix(name, "_bucket")
+				if !strings.Contains(exposition.String(), "# TYPE "+family+" ") {
+					t.Errorf("Panel query references %s, which is not exported", name)
+				}
+			}
+		}
+	}
+	if seen == 0 {
+		t.Error("Dashboard contains no metric queries")
+	}
+}
+
+func TestGenerate_Datasource(t *testing.T) {
+	opts := DefaultOptions()
+	opts.Datasource = "prom-main"
+	data, err := Generate(opts)
+	if err != nil {
+		t.Fatalf("Generate() error = %v", err)
+	}
+	if bytes.Contains(data, []byte("DS_PROMETHEUS")) {
+		t.Error("A fixed datasource should not require an import input")
+	}
+	if !bytes.Contains(data, []byte(`"prom-main"`)) {
+		t.Error("Datasource UID missing from dashboard")
+	}
+}
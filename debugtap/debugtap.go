@@ -0,0 +1,366 @@
+package debugtap
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultMaxEntries is the number of captured exchanges kept in memory
+const DefaultMaxEntries = 100
+
+// Filter selects which requests are captured by the tap
+type Filter struct {
+	Method     string `json:"method,omitempty"`
+	Host       string `json:"host,omitempty"`
+	PathPrefix string `json:"pathPrefix,omitempty"`
+	Header     string `json:"header,omitempty"`      // header name that must be present
+	HeaderVal  string `json:"headerValue,omitempty"` // optional exact value for Header
+}
+
+// Options configures an armed tap
+type Options struct {
+	Count        int    `json:"count"`                  // number of matching requests to capture
+	Filter       Filter `json:"filter"`                 // which requests to capture
+	MaxBodyBytes int    `json:"maxBodyBytes,omitempty"` // 0 disables body capture
+	OutputFile   string `json:"outputFile,omitempty"`   // optional file name in the output directory to append JSON lines to
+}
+
+// Entry is a single captured request/response exchange
+type Entry struct {
+	Time            time.Time   `json:"time"`
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	Host            string      `json:"host"`
+	RemoteAddr      string      `json:"remoteAddr"`
+	RequestHeaders  http.Header `json:"requestHeaders"`
+	RequestBody     string      `json:"requestBody,omitempty"`
+	Status          int         `json:"status"`
+	ResponseHeaders http.Header `json:"responseHeaders"`
+	ResponseBody    string      `json:"responseBody,omitempty"`
+	Duration        string      `json:"duration"`
+	Truncated       bool        `json:"truncated,omitempty"`
+}
+
+// Status describes the current state of the tap
+type Status struct {
+	Armed     bool    `json:"armed"`
+	Remaining int     `json:"remaining"`
+	Options   Options `json:"options"`
+	Entries   []Entry `json:"entries"`
+}
+
+// Tap captures the next N requests matching a filter for troubleshooting
+type Tap struct {
+	mu         sync.Mutex
+	opts       Options
+	remaining  int
+	entries    []Entry
+	maxEntries int
+	outputDir  string
+}
+
+// New creates a new, disarmed debug tap
+func New() *Tap {
+	return &Tap{maxEntries: DefaultMaxEntries}
+}
+
+// SetOutputDir sets the directory Options.OutputFile names a file in; output
+// files are refused while it is empty
+func (t *Tap) SetOutputDir(dir string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.outputDir = dir
+}
+
+// Arm starts capturing the next opts.Count matching requests
+func (t *Tap) Arm(opts Options) error {
+	if opts.Count <= 0 {
+		return fmt.Errorf("count must be positive")
+	}
+	if opts.MaxBodyBytes < 0 {
+		return fmt.Errorf("maxBodyBytes must not be negative")
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if opts.OutputFile != "" {
+		if t.outputDir == "" {
+			return fmt.Errorf("outputFile requires an output directory configured at startup")
+		}
+		if !validFileName(opts.OutputFile) {
+			return fmt.Errorf("outputFile %q must be a plain file name", opts.OutputFile)
+		}
+	}
+	t.opts = opts
+	t.remaining = opts.Count
+	t.entries = t.entries[:0]
+	return nil
+}
+
+// Disarm stops capturing; already captured entries are kept
+func (t *Tap) Disarm() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.remaining = 0
+}
+
+// Status returns the current tap state and a copy of the captured entries
+func (t *Tap) Status() Status {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	entries := make([]Entry, len(t.entries))
+	copy(entries, t.entries)
+	return Status{
+		Armed:     t.remaining > 0,
+		Remaining: t.remaining,
+		Options:   t.opts,
+		Entries:   entries,
+	}
+}
+
+// claim reserves a capture slot for the request if the tap is armed and matches
+func (t *Tap) claim(r *http.Request) (Options, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.remaining <= 0 || !t.opts.Filter.Match(r) {
+		return Options{}, false
+	}
+	t.remaining--
+	return t.opts, true
+}
+
+// record stores a captured entry and appends it to the output file if configured
+func (t *Tap) record(opts Options, e Entry) {
+	t.mu.Lock()
+	if len(t.entries) >= t.maxEntries {
+		t.entries = t.entries[1:]
+	}
+	t.entries = append(t.entries, e)
+	t.mu.Unlock()
+
+	if opts.OutputFile == "" {
+		return
+	}
+	t.mu.Lock()
+	filename := filepath.Join(t.outputDir, opts.OutputFile)
+	t.mu.Unlock()
+	if err := appendJSONLine(filename, e); err != nil {
+		logging.Logger().Error("failed to write debug tap", "file", filename, "error", err)
+	}
+}
+
+// validFileName reports whether name is a single path element, so it can't
+// leave the output directory
+func validFileName(name string) bool {
+	return name != "." && name != ".." && !strings.ContainsAny(name, `/\`) && !filepath.IsAbs(name) && filepath.VolumeName(name) == ""
+}
+
+// Match reports whether the request satisfies the filter
+func (f Filter) Match(r *http.Request) bool {
+	if f.Method != "" && !strings.EqualFold(f.Method, r.Method) {
+		return false
+	}
+	if f.Host != "" && !strings.EqualFold(f.Host, r.Host) {
+		return false
+	}
+	if f.PathPrefix != "" && !strings.HasPrefix(r.URL.Path, f.PathPrefix) {
+		return false
+	}
+	if f.Header != "" {
+		values, ok := r.Header[http.CanonicalHeaderKey(f.Header)]
+		if !ok {
+			return false
+		}
+		if f.HeaderVal != "" && !contains(values, f.HeaderVal) {
+			return false
+		}
+	}
+	return true
+}
+
+// Middleware captures matching requests passing through next while the tap is armed
+func (t *Tap) Middleware(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		opts, ok := t.claim(r)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		start := time.Now()
+		entry := Entry{
+			Time:           start,
+			Method:         r.Method,
+			URL:            r.URL.String(),
+			Host:           r.Host,
+			RemoteAddr:     r.RemoteAddr,
+			RequestHeaders: r.Header.Clone(),
+		}
+
+		var reqBody *cappedBuffer
+		if opts.MaxBodyBytes > 0 && r.Body != nil && r.Body != http.NoBody {
+			reqBody = &cappedBuffer{max: opts.MaxBodyBytes}
+			r.Body = &teeReadCloser{ReadCloser: r.Body, buf: reqBody}
+		}
+
+		cw := &captureWriter{
+			ResponseWriter: w,
+			statusCode:     http.StatusOK,
+			body:           &cappedBuffer{max: opts.MaxBodyBytes},
+		}
+		next.ServeHTTP(cw, r)
+
+		entry.Status = cw.statusCode
+		entry.ResponseHeaders = w.Header().Clone()
+		entry.Duration = time.Since(start).String()
+		if reqBody != nil {
+			entry.RequestBody = reqBody.String()
+			entry.Truncated = reqBody.truncated
+		}
+		if opts.MaxBodyBytes > 0 {
+			entry.ResponseBody = cw.body.String()
+			entry.Truncated = entry.Truncated || cw.body.truncated
+		}
+
+		t.record(opts, entry)
+	})
+}
+
+// Handler returns an HTTP handler for controlling the tap:
+// GET returns the status and captured entries, POST arms the tap with
+// a JSON Options body and DELETE disarms it.
+func (t *Tap) Handler() http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method {
+		case http.MethodGet:
+			writeJSON(w, http.StatusOK, t.Status())
+		case http.MethodPost:
+			var opts Options
+			if err := json.NewDecoder(r.Body).Decode(&opts); err != nil {
+				http.Error(w, fmt.Sprintf("invalid tap options: %v", err), http.StatusBadRequest)
+				return
+			}
+			if err := t.Arm(opts); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			writeJSON(w, http.StatusOK, t.Status())
+		case http.MethodDelete:
+			t.Disarm()
+			writeJSON(w, http.StatusOK, t.Status())
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		}
+	}
+}
+
+// captureWriter wraps http.ResponseWriter to capture status code and body
+type captureWriter struct {
+	http.ResponseWriter
+	statusCode  int
+	wroteHeader bool
+	body        *cappedBuffer
+}
+
+func (cw *captureWriter) WriteHeader(code int) {
+	if !cw.wroteHeader {
+		cw.statusCode = code
+		cw.wroteHeader = true
+	}
+	cw.ResponseWriter.WriteHeader(code)
+}
+
+func (cw *captureWriter) Write(p []byte) (int, error) {
+	cw.wroteHeader = true
+	cw.body.Write(p)
+	return cw.ResponseWriter.Write(p)
+}
+
+func (cw *captureWriter) Flush() {
+	if f, ok := cw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (cw *captureWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
+
+// cappedBuffer keeps at most max bytes and remembers whether more were seen
+type cappedBuffer struct {
+	buf       bytes.Buffer
+	max       int
+	truncated bool
+}
+
+func (cb *cappedBuffer) Write(p []byte) {
+	room := cb.max - cb.buf.Len()
+	if room <= 0 {
+		if len(p) > 0 {
+			cb.truncated = true
+		}
+		return
+	}
+	if len(p) > room {
+		p = p[:room]
+		cb.truncated = true
+	}
+	cb.buf.Write(p)
+}
+
+func (cb *cappedBuffer) String() string {
+	return cb.buf.String()
+}
+
+// teeReadCloser copies everything read from the body into a capped buffer
+type teeReadCloser struct {
+	io.ReadCloser
+	buf *cappedBuffer
+}
+
+func (t *teeReadCloser) Read(p []byte) (int, error) {
+	n, err := t.ReadCloser.Read(p)
+	if n > 0 {
+		t.buf.Write(p[:n])
+	}
+	return n, err
+}
+
+func appendJSONLine(filename string, v interface{}) error {
+	file, err := os.OpenFile(filename, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	return json.NewEncoder(file).Encode(v)
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	encoder.Encode(v)
+}
+
+func contains(values []string, want string) bool {
+	for _, v := range values {
+		if v == want {
+			return true
+		}
+	}
+	return false
+}
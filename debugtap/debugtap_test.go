@@ -0,0 +1,120 @@
+package debugtap
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func echoHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("X-Test", "yes")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte("echo:" + string(body)))
+	})
+}
+
+func TestTap_CapturesNextNMatching(t *testing.T) {
+	tap := New()
+	handler := tap.Middleware(echoHandler())
+
+	err := tap.Arm(Options{
+		Count:        2,
+		Filter:       Filter{PathPrefix: "/api"},
+		MaxBodyBytes: 8,
+	})
+	if err != nil {
+		t.Fatalf("Arm() error = %v", err)
+	}
+
+	paths := []string{"/other", "/api/a", "/api/b", "/api/c"}
+	for _, p := range paths {
+		req := httptest.NewRequest(http.MethodPost, p, strings.NewReader("hello world"))
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, req)
+		if rr.Body.String() != "echo:hello world" {
+			t.Errorf("Tap altered response body: %q", rr.Body.String())
+		}
+	}
+
+	status := tap.Status()
+	if status.Armed {
+		t.Error("Tap should be disarmed after capturing Count requests")
+	}
+	if len(status.Entries) != 2 {
+		t.Fatalf("Expected 2 entries, got %d", len(status.Entries))
+	}
+
+	e := status.Entries[0]
+	if e.URL != "/api/a" {
+		t.Errorf("Expected first entry /api/a, got %s", e.URL)
+	}
+	if e.Status != http.StatusCreated {
+		t.Errorf("Expected status %d, got %d", http.StatusCreated, e.Status)
+	}
+	if e.RequestBody != "hello wo" {
+		t.Errorf("Expected request body capped to 8 bytes, got %q", e.RequestBody)
+	}
+	if e.ResponseBody != "echo:hel" {
+		t.Errorf("Expected response body capped to 8 bytes, got %q", e.ResponseBody)
+	}
+	if !e.Truncated {
+		t.Error("Entry should be marked truncated")
+	}
+	if e.ResponseHeaders.Get("X-Test") != "yes" {
+		t.Error("Response headers were not captured")
+	}
+}
+
+func TestTap_ArmValidation(t *testing.T) {
+	tap := New()
+	if err := tap.Arm(Options{Count: 0}); err == nil {
+		t.Error("Arm() should reject a zero count")
+	}
+	if err := tap.Arm(Options{Count: 1, MaxBodyBytes: -1}); err == nil {
+		t.Error("Arm() should reject a negative body cap")
+	}
+	if err := tap.Arm(Options{Count: 1, OutputFile: "tap.jsonl"}); err == nil {
+		t.Error("Arm() should reject an output file without an output directory")
+	}
+
+	tap.SetOutputDir(t.TempDir())
+	for _, name := range []string{"..", "../tap.jsonl", "/tmp/tap.jsonl", "logs/tap.jsonl", `..\tap.jsonl`} {
+		if err := tap.Arm(Options{Count: 1, OutputFile: name}); err == nil {
+			t.Errorf("Arm() should reject output file %q", name)
+		}
+	}
+	if err := tap.Arm(Options{Count: 1, OutputFile: "tap.jsonl"}); err != nil {
+		t.Errorf("Arm() should accept a file name in the output directory, got %v", err)
+	}
+}
+
+func TestFilter_Match(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "http://example.com/api/users", nil)
+	req.Header.Set("X-Debug", "1")
+
+	tests := []struct {
+		name   string
+		filter Filter
+		want   bool
+	}{
+		{"empty filter", Filter{}, true},
+		{"method match", Filter{Method: "get"}, true},
+		{"method mismatch", Filter{Method: "POST"}, false},
+		{"host match", Filter{Host: "example.com"}, true},
+		{"path mismatch", Filter{PathPrefix: "/static"}, false},
+		{"header present", Filter{Header: "x-debug"}, true},
+		{"header value mismatch", Filter{Header: "X-Debug", HeaderVal: "2"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.filter.Match(req); got != tt.want {
+				t.Errorf("Match() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
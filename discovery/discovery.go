@@ -0,0 +1,80 @@
+package discovery
+
+import (
+	"encoding/json"
+	"maps"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// Discovery types
+const (
+	TypeNone = "none"
+	TypeDNS  = "dns"
+)
+
+// Health policies combining discovery with active health checks
+const (
+	// HealthPolicyRegistered routes to discovered endpoints immediately;
+	// failed probes take them out of rotation
+	HealthPolicyRegistered = "registered"
+	// HealthPolicyHealthy routes only to endpoints that are both registered
+	// and have passed a probe
+	HealthPolicyHealthy = "healthy"
+)
+
+// Config selects and configures a discovery provider
+type Config struct {
+	Type   string       `json:"type"` // none, file, dns, etcd, docker, eureka
+	File   FileConfig   `json:"file"`
+	DNS    DNSConfig    `json:"dns"`
+	Etcd   EtcdConfig   `json:"etcd"`
+	Docker DockerConfig `json:"docker"`
+	Eureka EurekaConfig `json:"eureka"`
+	// Options holds the settings of providers registered by third parties
+	Options json.RawMessage `json:"options,omitempty"`
+	// HealthPolicy is registered (default) or healthy
+	HealthPolicy string `json:"healthPolicy,omitempty"`
+	// GracePeriod tolerates failed probes of newly discovered endpoints
+	// (e.g. while they boot) for this long
+	GracePeriod time.Duration `json:"gracePeriod,omitempty"`
+	// Defaults are applied to every discovered backend (health path, limits, TLS...)
+	Defaults backend.Config `json:"defaults"`
+}
+
+// RequireHealthy reports whether discovered endpoints need a passing probe first
+func (c Config) RequireHealthy() bool {
+	return strings.EqualFold(c.HealthPolicy, HealthPolicyHealthy)
+}
+
+// withDefaults fills the per-backend settings of a discovered backend from
+// the configured defaults; URL, weight and backup flag come from discovery,
+// and discovered labels are added to the default ones
+func withDefaults(defaults backend.Config, found backend.Config) backend.Config {
+	out := defaults
+	out.URL = found.URL
+	if found.Weight > 0 {
+		out.Weight = found.Weight
+	}
+	out.Backup = found.Backup || defaults.Backup
+	if len(found.Labels) > 0 {
+		out.Labels = make(map[string]string, len(defaults.Labels)+len(found.Labels))
+		maps.Copy(out.Labels, defaults.Labels)
+		maps.Copy(out.Labels, found.Labels)
+	}
+	return out
+}
+
+// sortBackends orders a backend set by URL so equal sets compare equal
+func sortBackends(backends []backend.Config) {
+	sort.Slice(backends, func(i, j int) bool { return backends[i].URL < backends[j].URL })
+}
+
+// sameBackends reports whether two sorted backend sets are identical
+func sameBackends(a, b []backend.Config) bool {
+	return reflect.DeepEqual(a, b)
+}
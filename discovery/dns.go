@@ -0,0 +1,380 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DNS discovery defaults
+const (
+	DefaultDNSRefresh    = 30 * time.Second
+	DefaultDNSMinRefresh = 5 * time.Second
+)
+
+// DNSConfig configures DNS-based discovery
+type DNSConfig struct {
+	// Name is an SRV name (e.g. _http._tcp.api.example.com) or a host name;
+	// SRV records are preferred and A/AAAA records are the fallback
+	Name       string        `json:"name"`
+	Port       int           `json:"port"`       // port used with A/AAAA answers
+	Scheme     string        `json:"scheme"`     // http (default) or https
+	Refresh    time.Duration `json:"refresh"`    // re-resolve interval
+	RespectTTL bool          `json:"respectTTL"` // re-resolve when the answer's TTL expires instead
+	MinRefresh time.Duration `json:"minRefresh"` // floor for TTL-driven refreshes
+	Server     string        `json:"server"`     // nameserver host:port (defaults to /etc/resolv.conf)
+}
+
+// srvTarget is a single resolved endpoint
+type srvTarget struct {
+	host     string
+	port     int
+	priority uint16
+	weight   uint16
+}
+
+// dnsResolver performs the lookups; ttl is zero when unknown
+type dnsResolver interface {
+	lookupSRV(ctx context.Context, name string) ([]srvTarget, time.Duration, error)
+	lookupHost(ctx context.Context, name string) ([]string, time.Duration, error)
+}
+
+// DNS periodically resolves a name and publishes the resulting backend set
+type DNS struct {
+	cfg      DNSConfig
+	defaults backend.Config
+	resolver dnsResolver
+	updates  chan []backend.Config
+	last     []backend.Config
+}
+
+// NewDNS creates a DNS discovery provider
+func NewDNS(cfg DNSConfig, defaults backend.Config) (*DNS, error) {
+	if cfg.Name == "" {
+		return nil, fmt.Errorf("dns discovery requires a name")
+	}
+	if cfg.Scheme == "" {
+		cfg.Scheme = "http"
+	}
+	if cfg.Scheme != "http" && cfg.Scheme != "https" {
+		return nil, fmt.Errorf("dns discovery scheme must be http or https, got %q", cfg.Scheme)
+	}
+	if cfg.Port == 0 {
+		cfg.Port = 80
+		if cfg.Scheme == "https" {
+			cfg.Port = 443
+		}
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = DefaultDNSRefresh
+	}
+	if cfg.MinRefresh <= 0 {
+		cfg.MinRefresh = DefaultDNSMinRefresh
+	}
+
+	return &DNS{
+		cfg:      cfg,
+		defaults: defaults,
+		resolver: &rawResolver{server: cfg.Server},
+		updates:  make(chan []backend.Config, 1),
+	}, nil
+}
+
+// Updates delivers the backend set every time it changes
+func (d *DNS) Updates() <-chan []backend.Config {
+	return d.updates
+}
+
+// Start resolves immediately and then on every refresh until ctx is canceled
+func (d *DNS) Start(ctx context.Context) error {
+	go func() {
+		defer close(d.updates)
+		for {
+			wait := d.refresh(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(wait):
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh resolves once, publishes changes and returns the delay until the next lookup
+func (d *DNS) refresh(ctx context.Context) time.Duration {
+	lookupCtx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	backends, ttl, err := d.resolve(lookupCtx)
+	cancel()
+
+	if err != nil {
+		logging.Logger().Warn("dns discovery failed, keeping previous backends", "name", d.cfg.Name, "error", err)
+		return d.cfg.Refresh
+	}
+	if len(backends) == 0 {
+		logging.Logger().Warn("dns discovery returned no records, keeping previous backends", "name", d.cfg.Name)
+	} else if !sameBackends(backends, d.last) {
+		d.last = backends
+		select {
+		case d.updates <- backends:
+		case <-ctx.Done():
+		}
+	}
+
+	if d.cfg.RespectTTL && ttl > 0 {
+		return max(ttl, d.cfg.MinRefresh)
+	}
+	return d.cfg.Refresh
+}
+
+// resolve looks up SRV records first and falls back to A/AAAA
+func (d *DNS) resolve(ctx context.Context) ([]backend.Config, time.Duration, error) {
+	targets, ttl, srvErr := d.resolver.lookupSRV(ctx, d.cfg.Name)
+	if srvErr != nil || len(targets) == 0 {
+		hosts, hostTTL, err := d.resolver.lookupHost(ctx, d.cfg.Name)
+		if err != nil {
+			if srvErr != nil {
+				return nil, 0, fmt.Errorf("srv: %v; host: %w", srvErr, err)
+			}
+			return nil, 0, err
+		}
+		targets = targets[:0]
+		for _, h := range hosts {
+			targets = append(targets, srvTarget{host: h, port: d.cfg.Port})
+		}
+		ttl = hostTTL
+	}
+
+	// The lowest SRV priority serves traffic; higher priorities become backups
+	var best uint16
+	for i, t := range targets {
+		if i == 0 || t.priority < best {
+			best = t.priority
+		}
+	}
+
+	backends := make([]backend.Config, 0, len(targets))
+	for _, t := range targets {
+		found := backend.Config{
+			URL:    d.cfg.Scheme + "://" + net.JoinHostPort(strings.TrimSuffix(t.host, "."), strconv.Itoa(t.port)),
+			Weight: srvWeight(t.weight),
+			Backup: t.priority > best,
+		}
+		backends = append(backends, withDefaults(d.defaults, found))
+	}
+	sortBackends(backends)
+	return backends, ttl, nil
+}
+
+// srvWeight maps an SRV weight (0-65535) onto a backend weight (1-100); a
+// zero weight still gets the smallest share (RFC 2782)
+func srvWeight(w uint16) int {
+	return max(1, min(100, int(w)))
+}
+
+// rawResolver queries a nameserver directly so answer TTLs are available,
+// falling back to the system resolver when that is not possible
+type rawResolver struct {
+	server string
+}
+
+func (r *rawResolver) lookupSRV(ctx context.Context, name string) ([]srvTarget, time.Duration, error) {
+	msg, err := r.query(ctx, name, dnsmessage.TypeSRV)
+	if err != nil {
+		_, records, sysErr := net.DefaultResolver.LookupSRV(ctx, "", "", name)
+		if sysErr != nil {
+			return nil, 0, sysErr
+		}
+		targets := make([]srvTarget, 0, len(records))
+		for _, rec := range records {
+			targets = append(targets, srvTarget{host: rec.Target, port: int(rec.Port), priority: rec.Priority, weight: rec.Weight})
+		}
+		return targets, 0, nil
+	}
+
+	var targets []srvTarget
+	var ttl time.Duration
+	for _, ans := range msg.Answers {
+		srv, ok := ans.Body.(*dnsmessage.SRVResource)
+		if !ok {
+			continue
+		}
+		targets = append(targets, srvTarget{host: srv.Target.String(), port: int(srv.Port), priority: srv.Priority, weight: srv.Weight})
+		ttl = minTTL(ttl, ans.Header.TTL)
+	}
+	return targets, ttl, nil
+}
+
+func (r *rawResolver) lookupHost(ctx context.Context, name string) ([]string, time.Duration, error) {
+	var hosts []string
+	var ttl time.Duration
+	var queryErr error
+	for _, typ := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		msg, err := r.query(ctx, name, typ)
+		if err != nil {
+			queryErr = err
+			continue
+		}
+		for _, ans := range msg.Answers {
+			switch body := ans.Body.(type) {
+			case *dnsmessage.AResource:
+				hosts = append(hosts, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				hosts = append(hosts, net.IP(body.AAAA[:]).String())
+			default:
+				continue
+			}
+			ttl = minTTL(ttl, ans.Header.TTL)
+		}
+	}
+	if len(hosts) > 0 || queryErr == nil {
+		return hosts, ttl, nil
+	}
+
+	addrs, err := net.DefaultResolver.LookupHost(ctx, name)
+	if err != nil {
+		return nil, 0, err
+	}
+	return addrs, 0, nil
+}
+
+// query sends a single recursive question over UDP, advertising a large
+// buffer with EDNS0, and repeats it over TCP when the answer is truncated
+func (r *rawResolver) query(ctx context.Context, name string, typ dnsmessage.Type) (*dnsmessage.Message, error) {
+	server := r.server
+	if server == "" {
+		server = systemNameserver()
+		if server == "" {
+			return nil, fmt.Errorf("no nameserver configured")
+		}
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	qname, err := dnsmessage.NewName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(udpBufferSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	id := uint16(rand.Intn(1 << 16))
+	packet, err := (&dnsmessage.Message{
+		Header:      dnsmessage.Header{ID: id, RecursionDesired: true},
+		Questions:   []dnsmessage.Question{{Name: qname, Type: typ, Class: dnsmessage.ClassINET}},
+		Additionals: []dnsmessage.Resource{{Header: opt, Body: &dnsmessage.OPTResource{}}},
+	}).Pack()
+	if err != nil {
+		return nil, err
+	}
+
+	msg, err := exchange(ctx, "udp", server, packet, id)
+	if err == nil && msg.Header.Truncated {
+		msg, err = exchange(ctx, "tcp", server, packet, id)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if msg.Header.RCode != dnsmessage.RCodeSuccess {
+		return nil, fmt.Errorf("dns query for %s failed: %s", name, msg.Header.RCode)
+	}
+	return msg, nil
+}
+
+// udpBufferSize is the UDP payload size advertised with EDNS0
+const udpBufferSize = 4096
+
+// exchange sends packet to server over network ("udp" or "tcp", where
+// messages carry a two-byte length prefix) and returns the reply with the
+// same id
+func exchange(ctx context.Context, network, server string, packet []byte, id uint16) (*dnsmessage.Message, error) {
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, network, server)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if network == "tcp" {
+		framed := binary.BigEndian.AppendUint16(make([]byte, 0, 2+len(packet)), uint16(len(packet)))
+		if _, err := conn.Write(append(framed, packet...)); err != nil {
+			return nil, err
+		}
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return nil, err
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf); err != nil {
+			return nil, err
+		}
+		if msg.Header.ID != id {
+			return nil, fmt.Errorf("dns reply id mismatch")
+		}
+		return &msg, nil
+	}
+
+	if _, err := conn.Write(packet); err != nil {
+		return nil, err
+	}
+	buf := make([]byte, udpBufferSize)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		var msg dnsmessage.Message
+		if err := msg.Unpack(buf[:n]); err != nil || msg.Header.ID != id {
+			continue
+		}
+		return &msg, nil
+	}
+}
+
+// systemNameserver returns the first nameserver from /etc/resolv.conf
+func systemNameserver() string {
+	f, err := os.Open("/etc/resolv.conf")
+	if err != nil {
+		return ""
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			return net.JoinHostPort(fields[1], "53")
+		}
+	}
+	return ""
+}
+
+func minTTL(current time.Duration, ttl uint32) time.Duration {
+	d := time.Duration(ttl) * time.Second
+	if current == 0 || d < current {
+		return d
+	}
+	return current
+}
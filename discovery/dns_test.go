@@ -0,0 +1,224 @@
+package discovery
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+type fakeResolver struct {
+	srv     []srvTarget
+	srvErr  error
+	hosts   []string
+	hostErr error
+	ttl     time.Duration
+}
+
+func (f *fakeResolver) lookupSRV(ctx context.Context, name string) ([]srvTarget, time.Duration, error) {
+	return f.srv, f.ttl, f.srvErr
+}
+
+func (f *fakeResolver) lookupHost(ctx context.Context, name string) ([]string, time.Duration, error) {
+	return f.hosts, f.ttl, f.hostErr
+}
+
+func TestDNS_ResolveSRV(t *testing.T) {
+	d, err := NewDNS(DNSConfig{Name: "_http._tcp.api.example.com"}, backend.Config{HealthPath: "/healthz"})
+	if err != nil {
+		t.Fatalf("NewDNS failed: %v", err)
+	}
+	d.resolver = &fakeResolver{srv: []srvTarget{
+		{host: "b.example.com.", port: 8081, priority: 10, weight: 5},
+		{host: "a.example.com.", port: 8080, priority: 10, weight: 0},
+		{host: "standby.example.com.", port: 8080, priority: 20, weight: 1},
+	}}
+
+	backends, _, err := d.resolve(context.Background())
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	want := []backend.Config{
+		{URL: "http://a.example.com:8080", Weight: 1, HealthPath: "/healthz"},
+		{URL: "http://b.example.com:8081", Weight: 5, HealthPath: "/healthz"},
+		{URL: "http://standby.example.com:8080", Weight: 1, HealthPath: "/healthz", Backup: true},
+	}
+	if !sameBackends(backends, want) {
+		t.Errorf("Expected %+v, got %+v", want, backends)
+	}
+}
+
+func TestDNS_FallbackToHostRecords(t *testing.T) {
+	d, _ := NewDNS(DNSConfig{Name: "api.example.com", Port: 9000}, backend.Config{})
+	d.resolver = &fakeResolver{srvErr: errors.New("no such host"), hosts: []string{"10.0.0.2", "10.0.0.1", "::1"}}
+
+	backends, _, err := d.resolve(context.Background())
+	if err != nil {
+		t.Fatalf("resolve failed: %v", err)
+	}
+	if len(backends) != 3 || backends[0].URL != "http://10.0.0.1:9000" || backends[2].URL != "http://[::1]:9000" {
+		t.Errorf("Unexpected backends: %+v", backends)
+	}
+}
+
+func TestDNS_RefreshRespectsTTL(t *testing.T) {
+	d, _ := NewDNS(DNSConfig{Name: "api.example.com", Refresh: time.Minute, RespectTTL: true, MinRefresh: 2 * time.Second}, backend.Config{})
+	fake := &fakeResolver{srvErr: errors.New("none"), hosts: []string{"10.0.0.1"}, ttl: 10 * time.Second}
+	d.resolver = fake
+
+	if wait := d.refresh(context.Background()); wait != 10*time.Second {
+		t.Errorf("Expected refresh after TTL 10s, got %v", wait)
+	}
+	if got := <-d.Updates(); len(got) != 1 {
+		t.Errorf("Expected one backend update, got %+v", got)
+	}
+
+	// Unchanged answers publish nothing; tiny TTLs are floored
+	fake.ttl = time.Second
+	if wait := d.refresh(context.Background()); wait != 2*time.Second {
+		t.Errorf("Expected MinRefresh 2s, got %v", wait)
+	}
+	select {
+	case got := <-d.Updates():
+		t.Errorf("Unexpected update for unchanged answer: %+v", got)
+	default:
+	}
+
+	// Failures keep the previous set and use the regular interval
+	fake.hostErr = errors.New("timeout")
+	if wait := d.refresh(context.Background()); wait != time.Minute {
+		t.Errorf("Expected Refresh 1m after failure, got %v", wait)
+	}
+}
+
+func TestRawResolver_SRV(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer conn.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if req.Unpack(buf[:n]) != nil {
+			return
+		}
+		q := req.Questions[0]
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: req.Header.ID, Response: true},
+			Questions: req.Questions,
+			Answers: []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: 42},
+				Body:   &dnsmessage.SRVResource{Priority: 1, Weight: 10, Port: 8080, Target: dnsmessage.MustNewName("app.example.com.")},
+			}},
+		}
+		out, _ := resp.Pack()
+		conn.WriteTo(out, addr)
+	}()
+
+	r := &rawResolver{server: conn.LocalAddr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	targets, ttl, err := r.lookupSRV(ctx, "_http._tcp.example.com")
+	if err != nil {
+		t.Fatalf("lookupSRV failed: %v", err)
+	}
+	if len(targets) != 1 || targets[0].host != "app.example.com." || targets[0].port != 8080 {
+		t.Errorf("Unexpected targets: %+v", targets)
+	}
+	if ttl != 42*time.Second {
+		t.Errorf("Expected TTL 42s, got %v", ttl)
+	}
+}
+
+func TestRawResolver_Truncated(t *testing.T) {
+	udp, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("UDP not available: %v", err)
+	}
+	defer udp.Close()
+	tcp, err := net.Listen("tcp", udp.LocalAddr().String())
+	if err != nil {
+		t.Skipf("TCP port not available: %v", err)
+	}
+	defer tcp.Close()
+
+	reply := func(req dnsmessage.Message, truncated bool) []byte {
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: req.Header.ID, Response: true, Truncated: truncated},
+			Questions: req.Questions,
+		}
+		if !truncated {
+			resp.Answers = []dnsmessage.Resource{{
+				Header: dnsmessage.ResourceHeader{Name: req.Questions[0].Name, Type: dnsmessage.TypeSRV, Class: dnsmessage.ClassINET, TTL: 42},
+				Body:   &dnsmessage.SRVResource{Priority: 1, Weight: 10, Port: 8080, Target: dnsmessage.MustNewName("app.example.com.")},
+			}}
+		}
+		out, _ := resp.Pack()
+		return out
+	}
+
+	// UDP answers with TC set and no records
+	edns := make(chan bool, 1)
+	go func() {
+		buf := make([]byte, 512)
+		n, addr, err := udp.ReadFrom(buf)
+		if err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if req.Unpack(buf[:n]) != nil {
+			return
+		}
+		edns <- len(req.Additionals) == 1 && req.Additionals[0].Header.Type == dnsmessage.TypeOPT
+		udp.WriteTo(reply(req, true), addr)
+	}()
+	// TCP carries the full answer
+	go func() {
+		conn, err := tcp.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		var size [2]byte
+		if _, err := io.ReadFull(conn, size[:]); err != nil {
+			return
+		}
+		buf := make([]byte, binary.BigEndian.Uint16(size[:]))
+		if _, err := io.ReadFull(conn, buf); err != nil {
+			return
+		}
+		var req dnsmessage.Message
+		if req.Unpack(buf) != nil {
+			return
+		}
+		out := reply(req, false)
+		conn.Write(append(binary.BigEndian.AppendUint16(nil, uint16(len(out))), out...))
+	}()
+
+	r := &rawResolver{server: udp.LocalAddr().String()}
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+	targets, ttl, err := r.lookupSRV(ctx, "_http._tcp.example.com")
+	if err != nil {
+		t.Fatalf("lookupSRV failed: %v", err)
+	}
+	if !<-edns {
+		t.Error("Expected the query to carry an EDNS0 OPT record")
+	}
+	if len(targets) != 1 || targets[0].port != 8080 || ttl != 42*time.Second {
+		t.Errorf("Expected the answer retried over TCP, got %+v (ttl %v)", targets, ttl)
+	}
+}
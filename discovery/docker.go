@@ -0,0 +1,327 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// TypeDocker selects Docker label discovery
+const TypeDocker = "docker"
+
+// Docker discovery defaults
+const (
+	DefaultDockerHost    = "unix:///var/run/docker.sock"
+	DefaultDockerRefresh = 10 * time.Second
+)
+
+// Container labels read by Docker discovery
+const (
+	LabelEnable     = "go-balancer.enable"     // "true" to register the container
+	LabelPort       = "go-balancer.port"       // container port (defaults to the only exposed port)
+	LabelWeight     = "go-balancer.weight"     // backend weight
+	LabelScheme     = "go-balancer.scheme"     // http (default) or https
+	LabelBackup     = "go-balancer.backup"     // "true" for a backup backend
+	LabelHealthPath = "go-balancer.healthPath" // health check path override
+	// LabelPrefix starts the labels copied to the backend without it, e.g.
+	// go-balancer.label.zone=a becomes zone=a
+	LabelPrefix = "go-balancer.label."
+)
+
+// DockerConfig configures Docker label discovery
+type DockerConfig struct {
+	Host    string `json:"host"`    // daemon address: unix:///path, tcp://host:port or http(s)://host:port; defaults to $DOCKER_HOST
+	Network string `json:"network"` // network whose container IP is used (defaults to the first one)
+	// HostPorts uses published host ports instead of container IPs, for a
+	// balancer running outside the containers' network
+	HostPorts bool          `json:"hostPorts"`
+	Refresh   time.Duration `json:"refresh"`
+}
+
+// dockerContainer is the subset of GET /containers/json used here
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	State  string            `json:"State"`
+	Status string            `json:"Status"`
+	Labels map[string]string `json:"Labels"`
+	Ports  []struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	} `json:"Ports"`
+	NetworkSettings struct {
+		Networks map[string]struct {
+			IPAddress string `json:"IPAddress"`
+		} `json:"Networks"`
+	} `json:"NetworkSettings"`
+}
+
+// Docker polls the local Docker daemon for labeled containers
+type Docker struct {
+	cfg      DockerConfig
+	defaults backend.Config
+	client   *http.Client
+	baseURL  string
+	updates  chan []backend.Config
+	last     []backend.Config
+}
+
+// NewDocker creates a Docker discovery provider
+func NewDocker(cfg DockerConfig, defaults backend.Config) (*Docker, error) {
+	if cfg.Host == "" {
+		cfg.Host = os.Getenv("DOCKER_HOST")
+	}
+	if cfg.Host == "" {
+		cfg.Host = DefaultDockerHost
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = DefaultDockerRefresh
+	}
+
+	u, err := url.Parse(cfg.Host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %q: %w", cfg.Host, err)
+	}
+	d := &Docker{
+		cfg:      cfg,
+		defaults: defaults,
+		updates:  make(chan []backend.Config, 1),
+	}
+	switch u.Scheme {
+	case "unix":
+		socket := u.Path
+		transport := &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				var dialer net.Dialer
+				return dialer.DialContext(ctx, "unix", socket)
+			},
+		}
+		d.client = &http.Client{Transport: transport, Timeout: 10 * time.Second}
+		d.baseURL = "http://docker"
+	case "tcp", "http":
+		d.client = &http.Client{Timeout: 10 * time.Second}
+		d.baseURL = "http://" + u.Host
+	case "https":
+		d.client = &http.Client{Timeout: 10 * time.Second}
+		d.baseURL = "https://" + u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %q", u.Scheme)
+	}
+	return d, nil
+}
+
+// Updates delivers the backend set every time it changes
+func (d *Docker) Updates() <-chan []backend.Config {
+	return d.updates
+}
+
+// Start lists containers immediately and then on every refresh until ctx is canceled
+func (d *Docker) Start(ctx context.Context) error {
+	go func() {
+		defer close(d.updates)
+		for {
+			d.refresh(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(d.cfg.Refresh):
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh lists containers once and publishes changes
+func (d *Docker) refresh(ctx context.Context) {
+	containers, err := d.list(ctx)
+	if err != nil {
+		logging.Logger().Warn("docker discovery failed, keeping previous backends", "error", err)
+		return
+	}
+	backends := d.backends(containers)
+	if len(backends) == 0 {
+		logging.Logger().Warn("docker discovery found no labeled running containers, keeping previous backends", "label", LabelEnable+"=true")
+		return
+	}
+	if sameBackends(backends, d.last) {
+		return
+	}
+	d.last = backends
+	select {
+	case d.updates <- backends:
+	case <-ctx.Done():
+	}
+}
+
+// list returns the running containers carrying the enable label
+func (d *Docker) list(ctx context.Context) ([]dockerContainer, error) {
+	filters, _ := json.Marshal(map[string][]string{
+		"label":  {LabelEnable + "=true"},
+		"status": {"running"},
+	})
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		d.baseURL+"/containers/json?filters="+url.QueryEscape(string(filters)), nil)
+	if err != nil {
+		return nil, err
+	}
+	resp, err := d.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("docker returned %d: %s", resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode container list: %w", err)
+	}
+	return containers, nil
+}
+
+// backends converts labeled containers into a sorted backend set
+func (d *Docker) backends(containers []dockerContainer) []backend.Config {
+	backends := make([]backend.Config, 0, len(containers))
+	for _, c := range containers {
+		if c.Labels[LabelEnable] != "true" || c.State != "running" || strings.Contains(c.Status, "(unhealthy)") {
+			continue
+		}
+		found, err := d.containerBackend(c)
+		if err != nil {
+			logging.Logger().Warn("docker discovery skipping container", "container", containerName(c), "error", err)
+			continue
+		}
+		backends = append(backends, found)
+	}
+	sortBackends(backends)
+	return backends
+}
+
+// containerBackend builds the backend config of one container from its labels
+func (d *Docker) containerBackend(c dockerContainer) (backend.Config, error) {
+	port := 0
+	if p := c.Labels[LabelPort]; p != "" {
+		n, err := strconv.Atoi(p)
+		if err != nil || n <= 0 || n > 65535 {
+			return backend.Config{}, fmt.Errorf("invalid %s label %q", LabelPort, p)
+		}
+		port = n
+	}
+
+	var host string
+	if d.cfg.HostPorts {
+		for _, p := range c.Ports {
+			if p.Type == "tcp" && p.PublicPort != 0 && (port == 0 || p.PrivatePort == port) {
+				host = p.IP
+				port = p.PublicPort
+				break
+			}
+		}
+		if host == "" {
+			return backend.Config{}, fmt.Errorf("no published tcp port")
+		}
+		if host == "0.0.0.0" || host == "::" {
+			host = "127.0.0.1"
+		}
+	} else {
+		ip, err := d.containerIP(c)
+		if err != nil {
+			return backend.Config{}, err
+		}
+		host = ip
+		if port == 0 {
+			private := map[int]bool{}
+			for _, p := range c.Ports {
+				if p.Type == "tcp" {
+					private[p.PrivatePort] = true
+				}
+			}
+			if len(private) != 1 {
+				return backend.Config{}, fmt.Errorf("set the %s label to pick one of %d exposed ports", LabelPort, len(private))
+			}
+			for p := range private {
+				port = p
+			}
+		}
+	}
+
+	scheme := c.Labels[LabelScheme]
+	if scheme == "" {
+		scheme = "http"
+	}
+	if scheme != "http" && scheme != "https" {
+		return backend.Config{}, fmt.Errorf("invalid %s label %q", LabelScheme, scheme)
+	}
+
+	found := backend.Config{URL: scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))}
+	if w := c.Labels[LabelWeight]; w != "" {
+		n, err := strconv.Atoi(w)
+		if err != nil || n < 0 {
+			return backend.Config{}, fmt.Errorf("invalid %s label %q", LabelWeight, w)
+		}
+		found.Weight = n
+	}
+	found.Backup = c.Labels[LabelBackup] == "true"
+	for k, v := range c.Labels {
+		if name, ok := strings.CutPrefix(k, LabelPrefix); ok && name != "" {
+			if found.Labels == nil {
+				found.Labels = make(map[string]string)
+			}
+			found.Labels[name] = v
+		}
+	}
+
+	cfg := withDefaults(d.defaults, found)
+	if path := c.Labels[LabelHealthPath]; path != "" {
+		cfg.HealthPath = path
+	}
+	return cfg, nil
+}
+
+// containerIP returns the container's address on the configured network
+func (d *Docker) containerIP(c dockerContainer) (string, error) {
+	networks := c.NetworkSettings.Networks
+	if d.cfg.Network != "" {
+		if n, ok := networks[d.cfg.Network]; ok && n.IPAddress != "" {
+			return n.IPAddress, nil
+		}
+		return "", fmt.Errorf("not attached to network %q", d.cfg.Network)
+	}
+	names := make([]string, 0, len(networks))
+	for name := range networks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if ip := networks[name].IPAddress; ip != "" {
+			return ip, nil
+		}
+	}
+	return "", fmt.Errorf("no container IP address")
+}
+
+func containerName(c dockerContainer) string {
+	if len(c.Names) > 0 {
+		return strings.TrimPrefix(c.Names[0], "/")
+	}
+	if len(c.ID) > 12 {
+		return c.ID[:12]
+	}
+	return c.ID
+}
@@ -0,0 +1,103 @@
+package discovery
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+const dockerContainersJSON = `[
+  {"Id":"aaa","Names":["/api-1"],"State":"running","Status":"Up 2 minutes",
+   "Labels":{"go-balancer.enable":"true","go-balancer.weight":"3","go-balancer.label.zone":"a"},
+   "Ports":[{"PrivatePort":8080,"Type":"tcp"}],
+   "NetworkSettings":{"Networks":{"bridge":{"IPAddress":"172.17.0.2"}}}},
+  {"Id":"bbb","Names":["/api-2"],"State":"running","Status":"Up 1 minute",
+   "Labels":{"go-balancer.enable":"true","go-balancer.port":"9000","go-balancer.backup":"true","go-balancer.healthPath":"/ready"},
+   "Ports":[{"PrivatePort":9000,"Type":"tcp","IP":"0.0.0.0","PublicPort":32768},{"PrivatePort":9100,"Type":"tcp"}],
+   "NetworkSettings":{"Networks":{"bridge":{"IPAddress":"172.17.0.3"}}}},
+  {"Id":"ccc","Names":["/api-3"],"State":"running","Status":"Up 1 minute (unhealthy)",
+   "Labels":{"go-balancer.enable":"true"},
+   "Ports":[{"PrivatePort":8080,"Type":"tcp"}],
+   "NetworkSettings":{"Networks":{"bridge":{"IPAddress":"172.17.0.4"}}}},
+  {"Id":"ddd","Names":["/multi"],"State":"running","Status":"Up",
+   "Labels":{"go-balancer.enable":"true"},
+   "Ports":[{"PrivatePort":80,"Type":"tcp"},{"PrivatePort":443,"Type":"tcp"}],
+   "NetworkSettings":{"Networks":{"bridge":{"IPAddress":"172.17.0.5"}}}}
+]`
+
+func TestDocker_UnixSocket(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "docker.sock")
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" || !strings.Contains(r.URL.Query().Get("filters"), "go-balancer.enable=true") {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(dockerContainersJSON))
+	}))
+	server.Listener = listener
+	server.Start()
+	defer server.Close()
+
+	d, err := NewDocker(DockerConfig{Host: "unix://" + socket}, backend.Config{HealthPath: "/healthz"})
+	if err != nil {
+		t.Fatalf("NewDocker failed: %v", err)
+	}
+	d.refresh(context.Background())
+
+	want := []backend.Config{
+		{URL: "http://172.17.0.2:8080", Weight: 3, HealthPath: "/healthz", Labels: map[string]string{"zone": "a"}},
+		{URL: "http://172.17.0.3:9000", HealthPath: "/ready", Backup: true},
+	}
+	select {
+	case got := <-d.Updates():
+		if !sameBackends(got, want) {
+			t.Errorf("Expected %+v, got %+v", want, got)
+		}
+	default:
+		t.Fatal("Expected an update")
+	}
+
+	// An unchanged listing publishes nothing
+	d.refresh(context.Background())
+	select {
+	case got := <-d.Updates():
+		t.Errorf("Expected no update, got %+v", got)
+	default:
+	}
+}
+
+func TestDocker_HostPorts(t *testing.T) {
+	d, _ := NewDocker(DockerConfig{Host: "tcp://127.0.0.1:2375", HostPorts: true}, backend.Config{})
+	var c dockerContainer
+	c.Labels = map[string]string{LabelEnable: "true", LabelPort: "9000"}
+	c.Ports = append(c.Ports, struct {
+		IP          string `json:"IP"`
+		PrivatePort int    `json:"PrivatePort"`
+		PublicPort  int    `json:"PublicPort"`
+		Type        string `json:"Type"`
+	}{IP: "0.0.0.0", PrivatePort: 9000, PublicPort: 32768, Type: "tcp"})
+
+	got, err := d.containerBackend(c)
+	if err != nil {
+		t.Fatalf("containerBackend failed: %v", err)
+	}
+	if got.URL != "http://127.0.0.1:32768" {
+		t.Errorf("Expected http://127.0.0.1:32768, got %s", got.URL)
+	}
+}
+
+func TestNewDocker_InvalidHost(t *testing.T) {
+	if _, err := NewDocker(DockerConfig{Host: "ftp://docker"}, backend.Config{}); err == nil {
+		t.Error("Expected error for unsupported scheme")
+	}
+}
@@ -0,0 +1,426 @@
+package discovery
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// TypeEtcd selects etcd discovery
+const TypeEtcd = "etcd"
+
+// EtcdConfig configures etcd-based discovery through the etcd v3 JSON gateway
+type EtcdConfig struct {
+	Endpoints []string `json:"endpoints"` // e.g. http://127.0.0.1:2379
+	// Prefix holds one key per backend; values are a URL or a JSON backend config
+	Prefix string `json:"prefix"`
+	// ConfigKey optionally holds a full balancer config applied on change
+	ConfigKey string `json:"configKey,omitempty"`
+	Username  string `json:"username,omitempty"`
+	Password  string `json:"password,omitempty"`
+}
+
+// Etcd watches an etcd prefix for backend registrations
+type Etcd struct {
+	cfg      EtcdConfig
+	defaults backend.Config
+	client   *http.Client
+	updates  chan []backend.Config
+	configs  chan []byte
+
+	mu       sync.Mutex
+	token    string
+	endpoint int
+}
+
+// etcd gateway payloads; int64 values are JSON strings and bytes are base64
+type etcdKV struct {
+	Key         string `json:"key"`
+	Value       string `json:"value"`
+	ModRevision string `json:"mod_revision"`
+}
+
+type etcdHeader struct {
+	Revision string `json:"revision"`
+}
+
+type etcdRangeResponse struct {
+	Header etcdHeader `json:"header"`
+	Kvs    []etcdKV   `json:"kvs"`
+}
+
+type etcdWatchResponse struct {
+	Result struct {
+		Header   etcdHeader `json:"header"`
+		Canceled bool       `json:"canceled"`
+		Events   []struct {
+			Type string `json:"type"` // "PUT" is omitted as the default value
+			Kv   etcdKV `json:"kv"`
+		} `json:"events"`
+	} `json:"result"`
+	Error *struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// NewEtcd creates an etcd discovery provider
+func NewEtcd(cfg EtcdConfig, defaults backend.Config) (*Etcd, error) {
+	if len(cfg.Endpoints) == 0 {
+		return nil, fmt.Errorf("etcd discovery requires at least one endpoint")
+	}
+	if cfg.Prefix == "" {
+		return nil, fmt.Errorf("etcd discovery requires a prefix")
+	}
+	e := &Etcd{
+		cfg:      cfg,
+		defaults: defaults,
+		client:   &http.Client{},
+		updates:  make(chan []backend.Config, 1),
+	}
+	if cfg.ConfigKey != "" {
+		e.configs = make(chan []byte, 1)
+	}
+	return e, nil
+}
+
+// Updates delivers the backend set every time it changes
+func (e *Etcd) Updates() <-chan []backend.Config {
+	return e.updates
+}
+
+// Configs delivers the raw balancer config stored at ConfigKey; nil when unset
+func (e *Etcd) Configs() <-chan []byte {
+	return e.configs
+}
+
+// Start loads the prefix and then watches it until ctx is canceled,
+// reconnecting with backoff
+func (e *Etcd) Start(ctx context.Context) error {
+	go func() {
+		defer close(e.updates)
+		if e.configs != nil {
+			defer close(e.configs)
+		}
+
+		backoff := time.Second
+		for ctx.Err() == nil {
+			err := e.sync(ctx)
+			if ctx.Err() != nil {
+				return
+			}
+			logging.Logger().Warn("etcd watch interrupted", "retryIn", backoff, "error", err)
+			e.nextEndpoint()
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(backoff):
+			}
+			backoff = min(backoff*2, 30*time.Second)
+		}
+	}()
+	return nil
+}
+
+// sync publishes the current state and applies watch events until an error
+func (e *Etcd) sync(ctx context.Context) error {
+	registrations, rev, err := e.rangePrefix(ctx, e.cfg.Prefix)
+	if err != nil {
+		return err
+	}
+	e.publish(ctx, registrations)
+
+	configRev := ""
+	if e.cfg.ConfigKey != "" {
+		kvs, r, err := e.rangeKey(ctx, e.cfg.ConfigKey)
+		if err != nil {
+			return err
+		}
+		if len(kvs) > 0 {
+			e.publishConfig(ctx, kvs[0].Value)
+		}
+		configRev = r
+	}
+
+	errs := make(chan error, 2)
+	watchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	go func() {
+		errs <- e.watch(watchCtx, e.cfg.Prefix, prefixEnd(e.cfg.Prefix), rev, func(typ string, kv etcdKV) {
+			key := decode64(kv.Key)
+			if typ == "DELETE" {
+				delete(registrations, key)
+			} else {
+				registrations[key] = decode64(kv.Value)
+			}
+			e.publish(ctx, registrations)
+		})
+	}()
+	if e.cfg.ConfigKey != "" {
+		go func() {
+			errs <- e.watch(watchCtx, e.cfg.ConfigKey, "", configRev, func(typ string, kv etcdKV) {
+				if typ != "DELETE" {
+					e.publishConfig(ctx, kv.Value)
+				}
+			})
+		}()
+	}
+	return <-errs
+}
+
+// publish converts registrations into a backend set and sends it
+func (e *Etcd) publish(ctx context.Context, registrations map[string]string) {
+	backends := make([]backend.Config, 0, len(registrations))
+	for key, value := range registrations {
+		found, err := parseRegistration(value)
+		if err != nil {
+			logging.Logger().Warn("etcd discovery ignoring key", "key", key, "error", err)
+			continue
+		}
+		backends = append(backends, withDefaults(e.defaults, found))
+	}
+	if len(backends) == 0 {
+		logging.Logger().Warn("etcd discovery found no backends, keeping previous backends", "prefix", e.cfg.Prefix)
+		return
+	}
+	sortBackends(backends)
+
+	// Replace a pending update rather than blocking on a slow consumer
+	select {
+	case <-e.updates:
+	default:
+	}
+	select {
+	case e.updates <- backends:
+	case <-ctx.Done():
+	}
+}
+
+func (e *Etcd) publishConfig(ctx context.Context, value string) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		logging.Logger().Error("invalid etcd config value", "error", err)
+		return
+	}
+	select {
+	case <-e.configs:
+	default:
+	}
+	select {
+	case e.configs <- data:
+	case <-ctx.Done():
+	}
+}
+
+// parseRegistration accepts a bare URL or a JSON backend config
+func parseRegistration(value string) (backend.Config, error) {
+	value = strings.TrimSpace(value)
+	if strings.HasPrefix(value, "{") {
+		var cfg backend.Config
+		if err := json.Unmarshal([]byte(value), &cfg); err != nil {
+			return backend.Config{}, err
+		}
+		if cfg.URL == "" {
+			return backend.Config{}, fmt.Errorf("registration has no url")
+		}
+		return cfg, nil
+	}
+	if !strings.HasPrefix(value, "http://") && !strings.HasPrefix(value, "https://") {
+		return backend.Config{}, fmt.Errorf("value %q is not an http(s) URL", value)
+	}
+	return backend.Config{URL: value}, nil
+}
+
+// rangePrefix returns all keys under prefix (decoded) and the store revision
+func (e *Etcd) rangePrefix(ctx context.Context, prefix string) (map[string]string, string, error) {
+	var resp etcdRangeResponse
+	err := e.call(ctx, "/v3/kv/range", map[string]string{
+		"key":       encode64(prefix),
+		"range_end": encode64(prefixEnd(prefix)),
+	}, &resp)
+	if err != nil {
+		return nil, "", err
+	}
+	out := make(map[string]string, len(resp.Kvs))
+	for _, kv := range resp.Kvs {
+		out[decode64(kv.Key)] = decode64(kv.Value)
+	}
+	return out, resp.Header.Revision, nil
+}
+
+func (e *Etcd) rangeKey(ctx context.Context, key string) ([]etcdKV, string, error) {
+	var resp etcdRangeResponse
+	if err := e.call(ctx, "/v3/kv/range", map[string]string{"key": encode64(key)}, &resp); err != nil {
+		return nil, "", err
+	}
+	return resp.Kvs, resp.Header.Revision, nil
+}
+
+// watch streams events for [key, rangeEnd) after revision rev
+func (e *Etcd) watch(ctx context.Context, key, rangeEnd, rev string, onEvent func(typ string, kv etcdKV)) error {
+	start := int64(0)
+	if r, err := strconv.ParseInt(rev, 10, 64); err == nil {
+		start = r + 1
+	}
+	create := map[string]interface{}{
+		"key":            encode64(key),
+		"start_revision": strconv.FormatInt(start, 10),
+	}
+	if rangeEnd != "" {
+		create["range_end"] = encode64(rangeEnd)
+	}
+
+	resp, err := e.post(ctx, "/v3/watch", map[string]interface{}{"create_request": create})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	decoder := json.NewDecoder(bufio.NewReader(resp.Body))
+	for {
+		var msg etcdWatchResponse
+		if err := decoder.Decode(&msg); err != nil {
+			return fmt.Errorf("watch stream ended: %w", err)
+		}
+		if msg.Error != nil {
+			return fmt.Errorf("watch error: %s", msg.Error.Message)
+		}
+		if msg.Result.Canceled {
+			return fmt.Errorf("watch canceled by server")
+		}
+		for _, ev := range msg.Result.Events {
+			onEvent(ev.Type, ev.Kv)
+		}
+	}
+}
+
+// call posts a JSON request and decodes the JSON response
+func (e *Etcd) call(ctx context.Context, path string, body interface{}, out interface{}) error {
+	reqCtx, cancel := context.WithTimeout(ctx, 10*time.Second)
+	defer cancel()
+	resp, err := e.post(reqCtx, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// post sends a request to the current endpoint, authenticating when configured
+func (e *Etcd) post(ctx context.Context, path string, body interface{}) (*http.Response, error) {
+	token, err := e.authToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.currentEndpoint()+path, bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if token != "" {
+		req.Header.Set("Authorization", token)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		resp.Body.Close()
+		if resp.StatusCode == http.StatusUnauthorized {
+			e.mu.Lock()
+			e.token = ""
+			e.mu.Unlock()
+		}
+		return nil, fmt.Errorf("etcd %s returned %d: %s", path, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+	return resp, nil
+}
+
+// authToken returns a cached token, authenticating first when credentials are set
+func (e *Etcd) authToken(ctx context.Context) (string, error) {
+	if e.cfg.Username == "" {
+		return "", nil
+	}
+	e.mu.Lock()
+	token := e.token
+	e.mu.Unlock()
+	if token != "" {
+		return token, nil
+	}
+
+	payload, _ := json.Marshal(map[string]string{"name": e.cfg.Username, "password": e.cfg.Password})
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, e.currentEndpoint()+"/v3/auth/authenticate", bytes.NewReader(payload))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	var out struct {
+		Token string `json:"token"`
+	}
+	if resp.StatusCode != http.StatusOK || json.NewDecoder(resp.Body).Decode(&out) != nil || out.Token == "" {
+		return "", fmt.Errorf("etcd authentication failed (status %d)", resp.StatusCode)
+	}
+
+	e.mu.Lock()
+	e.token = out.Token
+	e.mu.Unlock()
+	return out.Token, nil
+}
+
+func (e *Etcd) currentEndpoint() string {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return strings.TrimSuffix(e.cfg.Endpoints[e.endpoint%len(e.cfg.Endpoints)], "/")
+}
+
+// nextEndpoint fails over to the next configured endpoint
+func (e *Etcd) nextEndpoint() {
+	e.mu.Lock()
+	e.endpoint++
+	e.token = ""
+	e.mu.Unlock()
+}
+
+// prefixEnd returns the range end covering every key with the given prefix
+func prefixEnd(prefix string) string {
+	end := []byte(prefix)
+	for i := len(end) - 1; i >= 0; i-- {
+		if end[i] < 0xff {
+			end[i]++
+			return string(end[:i+1])
+		}
+	}
+	return "\x00"
+}
+
+func encode64(s string) string {
+	return base64.StdEncoding.EncodeToString([]byte(s))
+}
+
+func decode64(s string) string {
+	b, _ := base64.StdEncoding.DecodeString(s)
+	return string(b)
+}
@@ -0,0 +1,167 @@
+package discovery
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// fakeEtcd serves the v3 JSON gateway endpoints used by the provider
+type fakeEtcd struct {
+	kvs    map[string]string
+	events chan string // raw watch messages
+}
+
+func (f *fakeEtcd) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case "/v3/kv/range":
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		key := decode64(req["key"])
+		end := decode64(req["range_end"])
+		kvs := []map[string]string{}
+		for k, v := range f.kvs {
+			if k == key || (end != "" && k >= key && k < end) {
+				kvs = append(kvs, map[string]string{"key": encode64(k), "value": encode64(v)})
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]interface{}{"header": map[string]string{"revision": "7"}, "kvs": kvs})
+	case "/v3/watch":
+		var req struct {
+			CreateRequest struct {
+				Key           string `json:"key"`
+				StartRevision string `json:"start_revision"`
+			} `json:"create_request"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.CreateRequest.StartRevision != "8" {
+			http.Error(w, "unexpected start revision "+req.CreateRequest.StartRevision, http.StatusBadRequest)
+			return
+		}
+		fmt.Fprintln(w, `{"result":{"header":{"revision":"7"},"created":true}}`)
+		w.(http.Flusher).Flush()
+		if decode64(req.CreateRequest.Key) != "/services/api/" {
+			<-r.Context().Done()
+			return
+		}
+		for {
+			select {
+			case msg := <-f.events:
+				fmt.Fprintln(w, msg)
+				w.(http.Flusher).Flush()
+			case <-r.Context().Done():
+				return
+			}
+		}
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func watchEvent(typ, key, value string) string {
+	kv := fmt.Sprintf(`{"key":%q,"value":%q}`, base64.StdEncoding.EncodeToString([]byte(key)), base64.StdEncoding.EncodeToString([]byte(value)))
+	if typ == "" {
+		return fmt.Sprintf(`{"result":{"events":[{"kv":%s}]}}`, kv)
+	}
+	return fmt.Sprintf(`{"result":{"events":[{"type":%q,"kv":%s}]}}`, typ, kv)
+}
+
+func nextUpdate(t *testing.T, updates <-chan []backend.Config) []backend.Config {
+	t.Helper()
+	select {
+	case got := <-updates:
+		return got
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for update")
+		return nil
+	}
+}
+
+func TestEtcd_WatchPrefix(t *testing.T) {
+	fake := &fakeEtcd{
+		kvs: map[string]string{
+			"/services/api/a": "http://10.0.0.1:8080",
+			"/services/api/b": `{"url":"http://10.0.0.2:8080","weight":3}`,
+			"/services/other": "http://10.9.9.9:8080",
+			"/balancer":       `{"strategy":{"type":"random"}}`,
+		},
+		events: make(chan string, 4),
+	}
+	server := httptest.NewServer(fake)
+	defer server.Close()
+
+	e, err := NewEtcd(EtcdConfig{Endpoints: []string{server.URL}, Prefix: "/services/api/", ConfigKey: "/balancer"},
+		backend.Config{HealthPath: "/healthz"})
+	if err != nil {
+		t.Fatalf("NewEtcd failed: %v", err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	e.Start(ctx)
+
+	want := []backend.Config{
+		{URL: "http://10.0.0.1:8080", HealthPath: "/healthz"},
+		{URL: "http://10.0.0.2:8080", Weight: 3, HealthPath: "/healthz"},
+	}
+	if got := nextUpdate(t, e.Updates()); !sameBackends(got, want) {
+		t.Errorf("Expected %+v, got %+v", want, got)
+	}
+	select {
+	case data := <-e.Configs():
+		if string(data) != `{"strategy":{"type":"random"}}` {
+			t.Errorf("Unexpected config: %s", data)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Timed out waiting for config")
+	}
+
+	fake.events <- watchEvent("", "/services/api/c", "http://10.0.0.3:8080")
+	if got := nextUpdate(t, e.Updates()); len(got) != 3 || got[2].URL != "http://10.0.0.3:8080" {
+		t.Errorf("Expected the added backend, got %+v", got)
+	}
+
+	fake.events <- watchEvent("DELETE", "/services/api/a", "")
+	if got := nextUpdate(t, e.Updates()); len(got) != 2 || got[0].URL != "http://10.0.0.2:8080" {
+		t.Errorf("Expected the deleted backend to be gone, got %+v", got)
+	}
+}
+
+func TestParseRegistration(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    string
+		wantErr bool
+	}{
+		{"http://10.0.0.1:80", "http://10.0.0.1:80", false},
+		{` {"url":"https://api:443","backup":true} `, "https://api:443", false},
+		{"10.0.0.1:80", "", true},
+		{`{"weight":2}`, "", true},
+		{`{broken`, "", true},
+	}
+	for _, tt := range tests {
+		got, err := parseRegistration(tt.value)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRegistration(%q): unexpected error %v", tt.value, err)
+			continue
+		}
+		if got.URL != tt.want {
+			t.Errorf("parseRegistration(%q): Expected %q, got %q", tt.value, tt.want, got.URL)
+		}
+	}
+}
+
+func TestPrefixEnd(t *testing.T) {
+	if got := prefixEnd("/a/"); got != "/a0" {
+		t.Errorf("Expected /a0, got %q", got)
+	}
+	if got := prefixEnd("a\xff"); got != "b" {
+		t.Errorf("Expected b, got %q", got)
+	}
+}
@@ -0,0 +1,232 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// TypeEureka selects Netflix Eureka discovery
+const TypeEureka = "eureka"
+
+// DefaultEurekaRefresh matches the Eureka client's registry fetch interval
+const DefaultEurekaRefresh = 30 * time.Second
+
+// EurekaConfig configures Eureka registry polling
+type EurekaConfig struct {
+	ServiceURLs []string      `json:"serviceUrls"` // e.g. http://eureka:8761/eureka
+	App         string        `json:"app"`         // application name as registered
+	PreferIP    bool          `json:"preferIp"`    // use ipAddr instead of hostName
+	Refresh     time.Duration `json:"refresh"`
+	Username    string        `json:"username,omitempty"`
+	Password    string        `json:"password,omitempty"`
+}
+
+// eurekaPort is Eureka's {"$": 8080, "@enabled": "true"} port encoding
+type eurekaPort struct {
+	Port    int    `json:"$"`
+	Enabled string `json:"@enabled"`
+}
+
+type eurekaInstance struct {
+	InstanceID     string            `json:"instanceId"`
+	HostName       string            `json:"hostName"`
+	IPAddr         string            `json:"ipAddr"`
+	Status         string            `json:"status"`
+	Port           eurekaPort        `json:"port"`
+	SecurePort     eurekaPort        `json:"securePort"`
+	HealthCheckURL string            `json:"healthCheckUrl"`
+	Metadata       map[string]string `json:"metadata"`
+}
+
+// Eureka polls a Eureka registry for the instances of one application
+type Eureka struct {
+	cfg      EurekaConfig
+	defaults backend.Config
+	client   *http.Client
+	updates  chan []backend.Config
+	last     []backend.Config
+	server   int
+}
+
+// NewEureka creates a Eureka discovery provider
+func NewEureka(cfg EurekaConfig, defaults backend.Config) (*Eureka, error) {
+	if len(cfg.ServiceURLs) == 0 {
+		return nil, fmt.Errorf("eureka discovery requires at least one service URL")
+	}
+	if cfg.App == "" {
+		return nil, fmt.Errorf("eureka discovery requires an app name")
+	}
+	if cfg.Refresh <= 0 {
+		cfg.Refresh = DefaultEurekaRefresh
+	}
+	return &Eureka{
+		cfg:      cfg,
+		defaults: defaults,
+		client:   &http.Client{Timeout: 10 * time.Second},
+		updates:  make(chan []backend.Config, 1),
+	}, nil
+}
+
+// Updates delivers the backend set every time it changes
+func (e *Eureka) Updates() <-chan []backend.Config {
+	return e.updates
+}
+
+// Start fetches the registry immediately and then on every refresh until ctx is canceled
+func (e *Eureka) Start(ctx context.Context) error {
+	go func() {
+		defer close(e.updates)
+		for {
+			e.refresh(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(e.cfg.Refresh):
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh fetches the application once, trying each server in turn, and publishes changes
+func (e *Eureka) refresh(ctx context.Context) {
+	var instances []eurekaInstance
+	var err error
+	for range e.cfg.ServiceURLs {
+		instances, err = e.fetch(ctx, e.cfg.ServiceURLs[e.server%len(e.cfg.ServiceURLs)])
+		if err == nil {
+			break
+		}
+		e.server++
+	}
+	if err != nil {
+		logging.Logger().Warn("eureka discovery failed, keeping previous backends", "app", e.cfg.App, "error", err)
+		return
+	}
+
+	backends := e.backends(instances)
+	if len(backends) == 0 {
+		logging.Logger().Warn("eureka discovery found no UP instances, keeping previous backends", "app", e.cfg.App)
+		return
+	}
+	if sameBackends(backends, e.last) {
+		return
+	}
+	e.last = backends
+	select {
+	case e.updates <- backends:
+	case <-ctx.Done():
+	}
+}
+
+// fetch returns the registered instances of the application
+func (e *Eureka) fetch(ctx context.Context, serviceURL string) ([]eurekaInstance, error) {
+	endpoint := strings.TrimSuffix(serviceURL, "/") + "/apps/" + url.PathEscape(strings.ToUpper(e.cfg.App))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if e.cfg.Username != "" {
+		req.SetBasicAuth(e.cfg.Username, e.cfg.Password)
+	}
+
+	resp, err := e.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return nil, fmt.Errorf("%s returned %d: %s", endpoint, resp.StatusCode, strings.TrimSpace(string(msg)))
+	}
+
+	var body struct {
+		Application struct {
+			// A single instance may be encoded as an object instead of an array
+			Instance json.RawMessage `json:"instance"`
+		} `json:"application"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode eureka response: %w", err)
+	}
+	raw := body.Application.Instance
+	if len(raw) == 0 {
+		return nil, nil
+	}
+	var instances []eurekaInstance
+	if raw[0] == '{' {
+		var one eurekaInstance
+		if err := json.Unmarshal(raw, &one); err != nil {
+			return nil, fmt.Errorf("failed to decode eureka instance: %w", err)
+		}
+		return []eurekaInstance{one}, nil
+	}
+	if err := json.Unmarshal(raw, &instances); err != nil {
+		return nil, fmt.Errorf("failed to decode eureka instances: %w", err)
+	}
+	return instances, nil
+}
+
+// backends converts UP instances into a sorted backend set
+func (e *Eureka) backends(instances []eurekaInstance) []backend.Config {
+	backends := make([]backend.Config, 0, len(instances))
+	for _, inst := range instances {
+		if inst.Status != "UP" {
+			continue
+		}
+		host := inst.HostName
+		if e.cfg.PreferIP || host == "" {
+			host = inst.IPAddr
+		}
+		scheme, port := "http", inst.Port.Port
+		if inst.SecurePort.Enabled == "true" {
+			scheme, port = "https", inst.SecurePort.Port
+		}
+		if host == "" || port <= 0 {
+			logging.Logger().Warn("eureka discovery skipping instance without address", "instance", inst.InstanceID)
+			continue
+		}
+
+		found := backend.Config{URL: scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))}
+		if w, err := strconv.Atoi(inst.Metadata["weight"]); err == nil && w > 0 {
+			found.Weight = w
+		}
+		found.Backup = inst.Metadata["backup"] == "true"
+		// The rest of the metadata (e.g. the zone of Spring Cloud
+		// instances) labels the backend
+		for k, v := range inst.Metadata {
+			if k != "weight" && k != "backup" {
+				if found.Labels == nil {
+					found.Labels = make(map[string]string)
+				}
+				found.Labels[k] = v
+			}
+		}
+
+		cfg := withDefaults(e.defaults, found)
+		// Spring Boot registers its actuator health URL; use it unless overridden
+		if cfg.HealthPath == "" && inst.HealthCheckURL != "" {
+			if u, err := url.Parse(inst.HealthCheckURL); err == nil && u.Path != "" {
+				cfg.HealthPath = u.Path
+			}
+		}
+		backends = append(backends, cfg)
+	}
+	sortBackends(backends)
+	return backends
+}
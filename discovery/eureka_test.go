@@ -0,0 +1,87 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+func TestEureka_Fetch(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		want []backend.Config
+	}{
+		{
+			name: "instance list",
+			body: `{"application":{"name":"ORDERS","instance":[
+				{"instanceId":"a","hostName":"orders-1","ipAddr":"10.0.0.1","status":"UP","port":{"$":8080,"@enabled":"true"},
+				 "securePort":{"$":443,"@enabled":"false"},"healthCheckUrl":"http://orders-1:8080/actuator/health","metadata":{"weight":"4","zone":"us-east-1a"}},
+				{"instanceId":"b","hostName":"orders-2","ipAddr":"10.0.0.2","status":"DOWN","port":{"$":8080,"@enabled":"true"}},
+				{"instanceId":"c","hostName":"orders-3","ipAddr":"10.0.0.3","status":"UP","port":{"$":8080,"@enabled":"false"},
+				 "securePort":{"$":8443,"@enabled":"true"},"metadata":{"backup":"true"}}]}}`,
+			want: []backend.Config{
+				{URL: "http://orders-1:8080", Weight: 4, HealthPath: "/actuator/health", Labels: map[string]string{"zone": "us-east-1a"}},
+				{URL: "https://orders-3:8443", Backup: true},
+			},
+		},
+		{
+			name: "single instance object",
+			body: `{"application":{"name":"ORDERS","instance":
+				{"instanceId":"a","hostName":"orders-1","status":"UP","port":{"$":8080,"@enabled":"true"}}}}`,
+			want: []backend.Config{{URL: "http://orders-1:8080"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path != "/eureka/apps/ORDERS" || r.Header.Get("Accept") != "application/json" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Write([]byte(tt.body))
+			}))
+			defer server.Close()
+
+			e, err := NewEureka(EurekaConfig{ServiceURLs: []string{server.URL + "/eureka/"}, App: "orders"}, backend.Config{})
+			if err != nil {
+				t.Fatalf("NewEureka failed: %v", err)
+			}
+			instances, err := e.fetch(context.Background(), e.cfg.ServiceURLs[0])
+			if err != nil {
+				t.Fatalf("fetch failed: %v", err)
+			}
+			if got := e.backends(instances); !sameBackends(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestEureka_FailsOverToNextServer(t *testing.T) {
+	down := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer down.Close()
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"application":{"instance":[{"ipAddr":"10.0.0.1","status":"UP","port":{"$":80,"@enabled":"true"}}]}}`))
+	}))
+	defer up.Close()
+
+	e, _ := NewEureka(EurekaConfig{ServiceURLs: []string{down.URL, up.URL}, App: "orders", PreferIP: true},
+		backend.Config{HealthPath: "/health"})
+	e.refresh(context.Background())
+
+	select {
+	case got := <-e.Updates():
+		if len(got) != 1 || got[0].URL != "http://10.0.0.1:80" || got[0].HealthPath != "/health" {
+			t.Errorf("Unexpected backends: %+v", got)
+		}
+	default:
+		t.Fatal("Expected an update")
+	}
+}
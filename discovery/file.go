@@ -0,0 +1,248 @@
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"gopkg.in/yaml.v3"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/internal/jsonconf"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// TypeFile selects the watched backends file
+const TypeFile = "file"
+
+// fileDebounce is how long the provider waits for writes to settle
+const fileDebounce = 500 * time.Millisecond
+
+// FileConfig configures file-based discovery
+type FileConfig struct {
+	// Path to a JSON or YAML (.yaml/.yml) file holding either a list of
+	// backends or an object with a "backends" list; entries are URLs or
+	// backend configs. A .txt file lists one "URL [weight]" per line
+	Path string `json:"path"`
+}
+
+// File watches a backends file and publishes its contents on every change
+type File struct {
+	cfg      FileConfig
+	defaults backend.Config
+	debounce time.Duration
+	updates  chan []backend.Config
+	last     []backend.Config
+}
+
+// NewFile creates a file discovery provider
+func NewFile(cfg FileConfig, defaults backend.Config) (*File, error) {
+	if cfg.Path == "" {
+		return nil, fmt.Errorf("file discovery requires a path")
+	}
+	return &File{
+		cfg:      cfg,
+		defaults: defaults,
+		debounce: fileDebounce,
+		updates:  make(chan []backend.Config, 1),
+	}, nil
+}
+
+// Updates delivers the backend set every time it changes
+func (f *File) Updates() <-chan []backend.Config {
+	return f.updates
+}
+
+// Start reads the file and then watches it until ctx is canceled. The parent
+// directory is watched so files replaced via rename are picked up too
+func (f *File) Start(ctx context.Context) error {
+	fw, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create backends file watcher: %w", err)
+	}
+	if err := fw.Add(filepath.Dir(f.cfg.Path)); err != nil {
+		fw.Close()
+		return fmt.Errorf("failed to watch %s: %w", f.cfg.Path, err)
+	}
+
+	go func() {
+		defer close(f.updates)
+		defer fw.Close()
+
+		f.refresh(ctx)
+		target := filepath.Clean(f.cfg.Path)
+		var timer *time.Timer
+		var fire <-chan time.Time
+		for {
+			select {
+			case <-ctx.Done():
+				if timer != nil {
+					timer.Stop()
+				}
+				return
+			case ev, ok := <-fw.Events:
+				if !ok {
+					return
+				}
+				if filepath.Clean(ev.Name) != target || !ev.Has(fsnotify.Write|fsnotify.Create|fsnotify.Rename) {
+					continue
+				}
+				if timer == nil {
+					timer = time.NewTimer(f.debounce)
+				} else {
+					timer.Reset(f.debounce)
+				}
+				fire = timer.C
+			case err, ok := <-fw.Errors:
+				if !ok {
+					return
+				}
+				logging.Logger().Warn("backends file watch error", "error", err)
+			case <-fire:
+				fire = nil
+				f.refresh(ctx)
+			}
+		}
+	}()
+	return nil
+}
+
+// refresh reads the file once and publishes changes; unreadable, invalid or
+// empty files keep the previous backends
+func (f *File) refresh(ctx context.Context) {
+	backends, err := f.read()
+	if err != nil {
+		logging.Logger().Error("backends file rejected, keeping previous backends", "error", err)
+		return
+	}
+	if len(backends) == 0 {
+		logging.Logger().Warn("backends file lists no backends, keeping previous backends", "path", f.cfg.Path)
+		return
+	}
+	if sameBackends(backends, f.last) {
+		return
+	}
+	f.last = backends
+	logging.Logger().Info("loaded backends file", "backends", len(backends), "path", f.cfg.Path)
+	select {
+	case <-f.updates:
+	default:
+	}
+	select {
+	case f.updates <- backends:
+	case <-ctx.Done():
+	}
+}
+
+// read parses the backends file
+func (f *File) read() ([]backend.Config, error) {
+	data, err := os.ReadFile(f.cfg.Path)
+	if err != nil {
+		return nil, err
+	}
+	if isText(f.cfg.Path) {
+		return parseBackendsText(data, f.defaults)
+	}
+	return parseBackendsFile(data, isYAML(f.cfg.Path), f.defaults)
+}
+
+// parseBackendsFile decodes a backends document; per-backend settings
+// override the defaults
+func parseBackendsFile(data []byte, yamlFormat bool, defaults backend.Config) ([]backend.Config, error) {
+	var raw interface{}
+	if yamlFormat {
+		if err := yaml.Unmarshal(data, &raw); err != nil {
+			return nil, fmt.Errorf("failed to decode backends file: %w", err)
+		}
+	} else {
+		decoder := json.NewDecoder(bytes.NewReader(data))
+		decoder.UseNumber()
+		if err := decoder.Decode(&raw); err != nil {
+			return nil, fmt.Errorf("failed to decode backends file: %w", err)
+		}
+	}
+
+	if obj, ok := raw.(map[string]interface{}); ok {
+		raw = obj["backends"]
+	}
+	entries, ok := raw.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("backends file must be a list or an object with a backends list")
+	}
+	return decodeBackends(entries, defaults)
+}
+
+// parseBackendsText decodes a plain text backends file: one URL per line,
+// optionally followed by its weight; blank lines and # comments are skipped
+func parseBackendsText(data []byte, defaults backend.Config) ([]backend.Config, error) {
+	var entries []interface{}
+	for i, line := range strings.Split(string(data), "\n") {
+		line, _, _ = strings.Cut(line, "#")
+		fields := strings.Fields(line)
+		switch len(fields) {
+		case 0:
+			continue
+		case 1:
+			entries = append(entries, fields[0])
+		case 2:
+			weight, err := strconv.Atoi(fields[1])
+			if err != nil || weight < 1 {
+				return nil, fmt.Errorf("line %d: weight %q must be a positive integer", i+1, fields[1])
+			}
+			entries = append(entries, map[string]interface{}{"url": fields[0], "weight": weight})
+		default:
+			return nil, fmt.Errorf("line %d: expected a URL and an optional weight", i+1)
+		}
+	}
+	return decodeBackends(entries, defaults)
+}
+
+// decodeBackends turns the URL and object entries of a backends file into
+// backend configs
+func decodeBackends(entries []interface{}, defaults backend.Config) ([]backend.Config, error) {
+	backends := make([]backend.Config, 0, len(entries))
+	seen := make(map[string]bool, len(entries))
+	for i, entry := range entries {
+		cfg := defaults
+		cfg.Labels = nil
+		switch v := entry.(type) {
+		case string:
+			cfg.URL = strings.TrimSpace(v)
+		case map[string]interface{}:
+			if err := jsonconf.Decode(v, &cfg); err != nil {
+				return nil, fmt.Errorf("backends[%d]: %w", i, err)
+			}
+			if cfg.Labels == nil {
+				cfg.Labels = defaults.Labels
+			}
+		default:
+			return nil, fmt.Errorf("backends[%d]: must be a URL or an object", i)
+		}
+		if !strings.HasPrefix(cfg.URL, "http://") && !strings.HasPrefix(cfg.URL, "https://") {
+			return nil, fmt.Errorf("backends[%d]: url %q must use http or https", i, cfg.URL)
+		}
+		if seen[cfg.URL] {
+			return nil, fmt.Errorf("backends[%d]: duplicate url %s", i, cfg.URL)
+		}
+		seen[cfg.URL] = true
+		backends = append(backends, cfg)
+	}
+	sortBackends(backends)
+	return backends, nil
+}
+
+func isYAML(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return ext == ".yaml" || ext == ".yml"
+}
+
+func isText(path string) bool {
+	return strings.EqualFold(filepath.Ext(path), ".txt")
+}
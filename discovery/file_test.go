@@ -0,0 +1,156 @@
+package discovery
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+func TestParseBackendsFile(t *testing.T) {
+	defaults := backend.Config{HealthPath: "/healthz", MaxFails: 2}
+	tests := []struct {
+		name    string
+		data    string
+		yaml    bool
+		want    []backend.Config
+		wantErr string
+	}{
+		{
+			name: "json list",
+			data: `["http://b:80", {"url": "http://a:80", "weight": 3, "healthInterval": "5s"}]`,
+			want: []backend.Config{
+				{URL: "http://a:80", Weight: 3, HealthPath: "/healthz", MaxFails: 2, HealthInterval: 5 * time.Second},
+				{URL: "http://b:80", HealthPath: "/healthz", MaxFails: 2},
+			},
+		},
+		{
+			name: "yaml object",
+			yaml: true,
+			data: "backends:\n  - http://a:80\n  - url: http://b:80\n    backup: true\n    healthPath: /ready\n",
+			want: []backend.Config{
+				{URL: "http://a:80", HealthPath: "/healthz", MaxFails: 2},
+				{URL: "http://b:80", HealthPath: "/ready", MaxFails: 2, Backup: true},
+			},
+		},
+		{name: "bad url", data: `["a:80"]`, wantErr: "must use http or https"},
+		{name: "duplicate", data: `["http://a:80", {"url": "http://a:80"}]`, wantErr: "duplicate url"},
+		{name: "wrong shape", data: `{"servers": []}`, wantErr: "must be a list"},
+		{name: "syntax", data: `[`, wantErr: "failed to decode"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBackendsFile([]byte(tt.data), tt.yaml, defaults)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !sameBackends(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestParseBackendsText(t *testing.T) {
+	defaults := backend.Config{HealthPath: "/healthz"}
+	tests := []struct {
+		name    string
+		data    string
+		want    []backend.Config
+		wantErr string
+	}{
+		{
+			name: "urls and weights",
+			data: "# web tier\nhttp://b:80 3\n\n  http://a:80   # canary\n",
+			want: []backend.Config{
+				{URL: "http://a:80", HealthPath: "/healthz"},
+				{URL: "http://b:80", Weight: 3, HealthPath: "/healthz"},
+			},
+		},
+		{name: "weight", data: "http://a:80 heavy", wantErr: "line 1: weight"},
+		{name: "zero weight", data: "http://a:80\nhttp://b:80 0", wantErr: "line 2: weight"},
+		{name: "extra fields", data: "http://a:80 1 backup", wantErr: "line 1: expected a URL"},
+		{name: "bad url", data: "a:80", wantErr: "must use http or https"},
+		{name: "duplicate", data: "http://a:80\nhttp://a:80 2", wantErr: "duplicate url"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseBackendsText([]byte(tt.data), defaults)
+			if tt.wantErr != "" {
+				if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+					t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !sameBackends(got, tt.want) {
+				t.Errorf("Expected %+v, got %+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestFile_WatchesChanges(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.json")
+	if err := os.WriteFile(path, []byte(`["http://a:80"]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, err := NewFile(FileConfig{Path: path}, backend.Config{})
+	if err != nil {
+		t.Fatalf("NewFile failed: %v", err)
+	}
+	f.debounce = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := f.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+
+	if got := nextUpdate(t, f.Updates()); len(got) != 1 || got[0].URL != "http://a:80" {
+		t.Errorf("Unexpected initial backends: %+v", got)
+	}
+
+	// An invalid edit keeps the previous set; the next valid one is applied
+	os.WriteFile(path, []byte(`[`), 0o644)
+	time.Sleep(100 * time.Millisecond)
+	os.WriteFile(path, []byte(`["http://a:80", "http://b:80"]`), 0o644)
+	if got := nextUpdate(t, f.Updates()); len(got) != 2 {
+		t.Errorf("Expected two backends after edit, got %+v", got)
+	}
+}
+
+func TestFile_WatchesTextFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "backends.txt")
+	if err := os.WriteFile(path, []byte("http://a:80\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _ := NewFile(FileConfig{Path: path}, backend.Config{})
+	f.debounce = 20 * time.Millisecond
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	if err := f.Start(ctx); err != nil {
+		t.Fatalf("Start failed: %v", err)
+	}
+	nextUpdate(t, f.Updates())
+
+	os.WriteFile(path, []byte("http://a:80 5\n"), 0o644)
+	if got := nextUpdate(t, f.Updates()); len(got) != 1 || got[0].Weight != 5 {
+		t.Errorf("Expected the new weight after edit, got %+v", got)
+	}
+}
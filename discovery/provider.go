@@ -0,0 +1,115 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Provider discovers backends and publishes the full backend set whenever it
+// changes. Updates is closed once the context passed to Start is canceled.
+type Provider interface {
+	Start(ctx context.Context) error
+	Updates() <-chan []backend.Config
+}
+
+// ConfigSource is implemented by providers that also deliver balancer
+// configs (raw JSON), such as etcd with a config key
+type ConfigSource interface {
+	Configs() <-chan []byte
+}
+
+// Factory creates a provider from the discovery config; third-party providers
+// read their settings from Config.Options
+type Factory func(cfg Config) (Provider, error)
+
+// Target receives discovered backend sets; *balancer.LoadBalancer implements it
+type Target interface {
+	SetBackends(backends []backend.Config) error
+}
+
+var (
+	registryMu sync.RWMutex
+	registry   = make(map[string]Factory)
+)
+
+// Register makes a provider available under the given discovery type. It
+// panics if the type is empty, already registered or factory is nil.
+func Register(typ string, factory Factory) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	typ = strings.ToLower(typ)
+	if typ == "" || typ == TypeNone {
+		panic("discovery: Register with empty type")
+	}
+	if factory == nil {
+		panic("discovery: Register factory is nil for " + typ)
+	}
+	if _, dup := registry[typ]; dup {
+		panic("discovery: Register called twice for " + typ)
+	}
+	registry[typ] = factory
+}
+
+// Registered reports whether a provider is registered for the type
+func Registered(typ string) bool {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	_, ok := registry[strings.ToLower(typ)]
+	return ok
+}
+
+// Types returns the registered discovery types, sorted
+func Types() []string {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+	types := make([]string, 0, len(registry))
+	for typ := range registry {
+		types = append(types, typ)
+	}
+	sort.Strings(types)
+	return types
+}
+
+// New creates the provider selected by cfg.Type
+func New(cfg Config) (Provider, error) {
+	registryMu.RLock()
+	factory, ok := registry[strings.ToLower(cfg.Type)]
+	registryMu.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown discovery type: %s", cfg.Type)
+	}
+	return factory(cfg)
+}
+
+// Reconcile applies every backend set published on updates to target until
+// the channel is closed
+func Reconcile(updates <-chan []backend.Config, target Target) {
+	for backends := range updates {
+		if err := target.SetBackends(backends); err != nil {
+			logging.Logger().Error("failed to apply discovered backends", "error", err)
+		}
+	}
+}
+
+func init() {
+	Register(TypeFile, func(cfg Config) (Provider, error) { return providerOf(NewFile(cfg.File, cfg.Defaults)) })
+	Register(TypeDNS, func(cfg Config) (Provider, error) { return providerOf(NewDNS(cfg.DNS, cfg.Defaults)) })
+	Register(TypeEtcd, func(cfg Config) (Provider, error) { return providerOf(NewEtcd(cfg.Etcd, cfg.Defaults)) })
+	Register(TypeDocker, func(cfg Config) (Provider, error) { return providerOf(NewDocker(cfg.Docker, cfg.Defaults)) })
+	Register(TypeEureka, func(cfg Config) (Provider, error) { return providerOf(NewEureka(cfg.Eureka, cfg.Defaults)) })
+}
+
+// providerOf avoids returning a typed nil Provider when construction fails
+func providerOf[P Provider](p P, err error) (Provider, error) {
+	if err != nil {
+		return nil, err
+	}
+	return p, nil
+}
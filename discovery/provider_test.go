@@ -0,0 +1,88 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// staticProvider publishes the URLs listed in its options once
+type staticProvider struct {
+	urls    []string
+	updates chan []backend.Config
+}
+
+func (s *staticProvider) Start(ctx context.Context) error {
+	backends := make([]backend.Config, 0, len(s.urls))
+	for _, u := range s.urls {
+		backends = append(backends, backend.Config{URL: u})
+	}
+	s.updates <- backends
+	close(s.updates)
+	return nil
+}
+
+func (s *staticProvider) Updates() <-chan []backend.Config {
+	return s.updates
+}
+
+type recordingTarget struct {
+	sets [][]backend.Config
+}
+
+func (r *recordingTarget) SetBackends(backends []backend.Config) error {
+	r.sets = append(r.sets, backends)
+	return nil
+}
+
+func TestRegistry_ThirdPartyProvider(t *testing.T) {
+	Register("static-test", func(cfg Config) (Provider, error) {
+		var opts struct {
+			URLs []string `json:"urls"`
+		}
+		if err := json.Unmarshal(cfg.Options, &opts); err != nil {
+			return nil, err
+		}
+		return &staticProvider{urls: opts.URLs, updates: make(chan []backend.Config, 1)}, nil
+	})
+
+	if !Registered("STATIC-TEST") {
+		t.Error("Expected type lookup to be case-insensitive")
+	}
+
+	p, err := New(Config{Type: "static-test", Options: json.RawMessage(`{"urls":["http://a:80","http://b:80"]}`)})
+	if err != nil {
+		t.Fatalf("New failed: %v", err)
+	}
+	p.Start(context.Background())
+
+	target := &recordingTarget{}
+	Reconcile(p.Updates(), target)
+	if len(target.sets) != 1 || len(target.sets[0]) != 2 {
+		t.Errorf("Expected one update with two backends, got %+v", target.sets)
+	}
+}
+
+func TestRegistry_BuiltinsAndErrors(t *testing.T) {
+	for _, typ := range []string{TypeFile, TypeDNS, TypeEtcd, TypeDocker, TypeEureka} {
+		if !Registered(typ) {
+			t.Errorf("Expected %s to be registered", typ)
+		}
+	}
+
+	if _, err := New(Config{Type: "zookeeper"}); err == nil {
+		t.Error("Expected error for unknown type")
+	}
+	if p, err := New(Config{Type: TypeDNS}); err == nil || p != nil {
+		t.Errorf("Expected nil provider and error for invalid dns config, got %v, %v", p, err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic on duplicate registration")
+		}
+	}()
+	Register(TypeDNS, func(cfg Config) (Provider, error) { return nil, nil })
+}
@@ -14,20 +14,16 @@ import (
 )
 
 func main() {
-	// Configure the load balancer
-	config := balancer.Config{
-		BackendURLs: []string{
+	// Create load balancer
+	lb, err := balancer.New(
+		balancer.WithBackends(
 			"http://localhost:8081",
 			"http://localhost:8082",
 			"http://localhost:8083",
-		},
-		Strategy:            strategy.NewRoundRobin(), // Try: NewLeastConnections(), NewRandom()
-		HealthCheckInterval: 10 * time.Second,
-		HealthCheckTimeout:  5 * time.Second,
-	}
-
-	// Create load balancer
-	lb, err := balancer.NewLoadBalancer(config)
+		),
+		balancer.WithStrategy(strategy.NewRoundRobin()), // Try: NewLeastConnections(), NewRandom()
+		balancer.WithHealthCheck(10*time.Second, 5*time.Second),
+	)
 	if err != nil {
 		log.Fatalf("Failed to create load balancer: %v", err)
 	}
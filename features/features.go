@@ -0,0 +1,167 @@
+// Package features is a registry of runtime feature flags. Components
+// register the optional behaviour they implement and check its flag per
+// request, so operators can switch a misbehaving feature off instantly
+// through the admin API instead of redeploying.
+package features
+
+import (
+	"fmt"
+	"net/http"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Well-known flags
+const (
+	// Passthrough disables every other flag while on, leaving the plain
+	// strategy-and-proxy request path
+	Passthrough    = "passthrough"
+	StickySessions = "sticky-sessions"
+	AccessLog      = "access-log"
+	CORS           = "cors"
+	DebugTap       = "debug-tap"
+	TopStats       = "top-stats"
+	SlowRequestLog = "slow-request-log"
+	Chaos          = "chaos"
+)
+
+// Known returns the names of the well-known flags, sorted
+func Known() []string {
+	names := []string{Passthrough, StickySessions, AccessLog, CORS, DebugTap, TopStats, SlowRequestLog, Chaos}
+	sort.Strings(names)
+	return names
+}
+
+// Flag is a feature switch; a nil *Flag is always enabled
+type Flag struct {
+	name        string
+	description string
+	defaultOn   bool
+	enabled     atomic.Bool
+	registry    *Registry
+}
+
+// Enabled reports whether the feature should be used for the current request
+func (f *Flag) Enabled() bool {
+	if f == nil {
+		return true
+	}
+	if f.name != Passthrough && f.registry.passthrough.enabled.Load() {
+		return false
+	}
+	return f.enabled.Load()
+}
+
+// State describes a flag for the admin API
+type State struct {
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	Enabled     bool   `json:"enabled"`
+	Default     bool   `json:"default"`
+	// Effective is false when the flag is on but overridden by passthrough
+	Effective bool `json:"effective"`
+}
+
+// Registry holds the flags of the process
+type Registry struct {
+	mu          sync.RWMutex
+	flags       map[string]*Flag
+	passthrough *Flag
+	audit       *audit.Log
+}
+
+// NewRegistry creates a registry with the passthrough flag; changes are
+// recorded in auditLog (optional)
+func NewRegistry(auditLog *audit.Log) *Registry {
+	r := &Registry{flags: make(map[string]*Flag), audit: auditLog}
+	r.passthrough = r.Register(Passthrough, "Bypass every optional feature", false)
+	return r
+}
+
+// Register adds a flag, or returns the existing one with that name. It is
+// safe to call on a nil *Registry, returning a nil (always enabled) flag.
+func (r *Registry) Register(name, description string, enabled bool) *Flag {
+	if r == nil {
+		return nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if f, ok := r.flags[name]; ok {
+		return f
+	}
+	f := &Flag{name: name, description: description, defaultOn: enabled, registry: r}
+	f.enabled.Store(enabled)
+	r.flags[name] = f
+	return f
+}
+
+// Set switches a registered flag on or off on behalf of actor
+func (r *Registry) Set(name string, enabled bool, actor string) error {
+	r.mu.RLock()
+	f, ok := r.flags[name]
+	r.mu.RUnlock()
+	if !ok {
+		return fmt.Errorf("unknown feature flag %q", name)
+	}
+	if f.enabled.Swap(enabled) == enabled {
+		return nil
+	}
+	state, action := "off", "feature.disable"
+	if enabled {
+		state, action = "on", "feature.enable"
+	}
+	logging.Logger().Info("feature switched", "feature", name, "state", state)
+	r.audit.Record(actor, action, "", name)
+	return nil
+}
+
+// Get returns the state of a flag
+func (r *Registry) Get(name string) (State, bool) {
+	r.mu.RLock()
+	f, ok := r.flags[name]
+	r.mu.RUnlock()
+	if !ok {
+		return State{}, false
+	}
+	return f.state(), true
+}
+
+// List returns the state of every flag, sorted by name
+func (r *Registry) List() []State {
+	r.mu.RLock()
+	out := make([]State, 0, len(r.flags))
+	for _, f := range r.flags {
+		out = append(out, f.state())
+	}
+	r.mu.RUnlock()
+	sort.Slice(out, func(i, j int) bool { return out[i].Name < out[j].Name })
+	return out
+}
+
+func (f *Flag) state() State {
+	return State{
+		Name:        f.name,
+		Description: f.description,
+		Enabled:     f.enabled.Load(),
+		Default:     f.defaultOn,
+		Effective:   f.Enabled(),
+	}
+}
+
+// Gate applies mw only to requests arriving while f is enabled
+func Gate(f *Flag, mw func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		wrapped := mw(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if f.Enabled() {
+				wrapped.ServeHTTP(w, r)
+			} else {
+				next.ServeHTTP(w, r)
+			}
+		})
+	}
+}
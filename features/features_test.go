@@ -0,0 +1,79 @@
+package features
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/TaiTitans/go-balancer/audit"
+)
+
+func TestRegistry_SetAndPassthrough(t *testing.T) {
+	auditLog, _ := audit.New("", 0)
+	r := NewRegistry(auditLog)
+	cors := r.Register(CORS, "Add CORS headers", true)
+	if again := r.Register(CORS, "", false); again != cors {
+		t.Error("Expected registering twice to return the same flag")
+	}
+	if !cors.Enabled() {
+		t.Fatal("Expected cors to be enabled by default")
+	}
+
+	if err := r.Set(CORS, false, "alice"); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	if cors.Enabled() {
+		t.Error("Expected cors to be disabled")
+	}
+	if entries := auditLog.Recent(10); len(entries) != 1 || entries[0].Action != "feature.disable" || entries[0].Actor != "alice" {
+		t.Errorf("Expected one feature.disable entry by alice, got %+v", entries)
+	}
+
+	r.Set(CORS, true, "alice")
+	r.Set(Passthrough, true, "alice")
+	if cors.Enabled() {
+		t.Error("Expected passthrough to bypass cors")
+	}
+	if state, _ := r.Get(CORS); !state.Enabled || state.Effective {
+		t.Errorf("Expected cors on but not effective, got %+v", state)
+	}
+	if len(r.List()) != 2 {
+		t.Errorf("Expected 2 flags, got %d", len(r.List()))
+	}
+
+	if err := r.Set("retries", false, "alice"); err == nil {
+		t.Error("Expected error for an unknown flag")
+	}
+}
+
+func TestNilRegistry(t *testing.T) {
+	var r *Registry
+	if f := r.Register(TopStats, "", false); !f.Enabled() {
+		t.Error("Expected flags of a nil registry to be enabled")
+	}
+}
+
+func TestGate(t *testing.T) {
+	r := NewRegistry(nil)
+	flag := r.Register(CORS, "", true)
+	mw := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			w.Header().Set("X-Gated", "yes")
+			next.ServeHTTP(w, req)
+		})
+	}
+	handler := Gate(flag, mw)(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {}))
+
+	serve := func() string {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+		return rec.Header().Get("X-Gated")
+	}
+	if serve() != "yes" {
+		t.Error("Expected middleware to run while enabled")
+	}
+	r.Set(CORS, false, "")
+	if serve() != "" {
+		t.Error("Expected middleware to be skipped while disabled")
+	}
+}
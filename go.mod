@@ -1,3 +1,10 @@
 module github.com/TaiTitans/go-balancer
 
 go 1.25.4
+
+require (
+	github.com/fsnotify/fsnotify v1.10.1
+	golang.org/x/net v0.57.0
+	golang.org/x/sys v0.47.0
+	gopkg.in/yaml.v3 v3.0.1
+)
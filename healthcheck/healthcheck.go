@@ -2,93 +2,404 @@ package healthcheck
 
 import (
 	"context"
-	"log"
-	"net"
+	"log/slog"
 	"net/http"
+	"sync"
 	"time"
 
 	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/metrics"
 )
 
 // HealthChecker performs health checks on backends
 type HealthChecker struct {
+	mu       sync.RWMutex
 	backends []*backend.Backend
 	interval time.Duration
 	timeout  time.Duration
-	client   *http.Client
+	reset    chan struct{}
+	started  map[*backend.Backend]time.Time
+	joined   map[*backend.Backend]time.Time
+	grace    time.Duration
+	config   Config
+	logger   *slog.Logger
+	onChange func(b *backend.Backend, alive bool)
+
+	statsMu sync.RWMutex
+	stats   map[*backend.Backend]*ProbeStats
+	probes  *metrics.CounterVec
+	latency *metrics.HistogramVec
+}
+
+// ProbeStats summarizes active health probes for a single backend
+type ProbeStats struct {
+	Total               int64
+	Successes           int64
+	ConsecutiveFailures int64
+	LastDuration        time.Duration
+	LastProbe           time.Time
+}
+
+// SuccessRate returns the fraction of successful probes (1 when nothing was probed yet)
+func (ps ProbeStats) SuccessRate() float64 {
+	if ps.Total == 0 {
+		return 1
+	}
+	return float64(ps.Successes) / float64(ps.Total)
 }
 
+// Config is the health check applied to every backend; a backend's own
+// health settings override it field by field
+type Config = backend.HealthCheck
+
 // NewHealthChecker creates a new health checker
 func NewHealthChecker(backends []*backend.Backend, interval, timeout time.Duration) *HealthChecker {
 	return &HealthChecker{
 		backends: backends,
 		interval: interval,
 		timeout:  timeout,
-		client: &http.Client{
-			Timeout: timeout,
-			Transport: &http.Transport{
-				DialContext: (&net.Dialer{
-					Timeout: timeout,
-				}).DialContext,
-			},
-		},
+		stats:    make(map[*backend.Backend]*ProbeStats),
+		reset:    make(chan struct{}, 1),
+		started:  make(map[*backend.Backend]time.Time),
+		joined:   make(map[*backend.Backend]time.Time),
+	}
+}
+
+// SetLogger sets the logger of the checker (logging.Logger() if nil)
+func (hc *HealthChecker) SetLogger(l *slog.Logger) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.logger = l
+}
+
+func (hc *HealthChecker) log() *slog.Logger {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	if hc.logger != nil {
+		return hc.logger
 	}
+	return logging.Logger()
+}
+
+// OnStateChange sets fn to be called when a probe marks a backend up or down
+func (hc *HealthChecker) OnStateChange(fn func(b *backend.Backend, alive bool)) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.onChange = fn
+}
+
+// setAlive updates the health of b, reporting changes to the OnStateChange
+// callback
+func (hc *HealthChecker) setAlive(b *backend.Backend, alive bool) {
+	was := b.IsAlive()
+	b.SetAlive(alive)
+	if was == alive {
+		return
+	}
+	hc.mu.RLock()
+	fn := hc.onChange
+	hc.mu.RUnlock()
+	if fn != nil {
+		fn(b, alive)
+	}
+}
+
+// SetBackends replaces the set of checked backends; backends that were not
+// checked before are probed right away and start their grace period
+func (hc *HealthChecker) SetBackends(backends []*backend.Backend) {
+	keep := make(map[*backend.Backend]bool, len(backends))
+	for _, b := range backends {
+		keep[b] = true
+	}
+
+	now := time.Now()
+	hc.mu.Lock()
+	previous := make(map[*backend.Backend]bool, len(hc.backends))
+	for _, b := range hc.backends {
+		previous[b] = true
+	}
+	for _, b := range backends {
+		if !previous[b] {
+			hc.joined[b] = now
+		}
+		b.SetHealthDefaults(hc.config)
+	}
+	for b := range hc.joined {
+		if !keep[b] {
+			delete(hc.joined, b)
+		}
+	}
+	for b := range hc.started {
+		if !keep[b] {
+			delete(hc.started, b)
+		}
+	}
+	hc.backends = backends
+	hc.mu.Unlock()
+
+	hc.statsMu.Lock()
+	for b := range hc.stats {
+		if !keep[b] {
+			delete(hc.stats, b)
+		}
+	}
+	hc.statsMu.Unlock()
+
+	hc.signalReset()
+}
+
+// signalReset asks a running loop to recompute its tick interval
+func (hc *HealthChecker) signalReset() {
+	select {
+	case hc.reset <- struct{}{}:
+	default:
+	}
+}
+
+// SetTimings changes the probe interval and timeout; a running loop picks
+// up the new interval immediately
+func (hc *HealthChecker) SetTimings(interval, timeout time.Duration) {
+	hc.mu.Lock()
+	changed := hc.interval != interval || hc.timeout != timeout
+	hc.interval = interval
+	hc.timeout = timeout
+	hc.mu.Unlock()
+
+	if changed {
+		hc.signalReset()
+	}
+}
+
+// SetConfig changes the health check of every backend, including ones
+// added later
+func (hc *HealthChecker) SetConfig(c Config) {
+	hc.mu.Lock()
+	defer hc.mu.Unlock()
+	hc.config = c
+	for _, b := range hc.backends {
+		b.SetHealthDefaults(c)
+	}
+}
+
+// Config returns the health check applied to every backend
+func (hc *HealthChecker) Config() Config {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.config
+}
+
+// SetGracePeriod sets how long failed probes of a newly added backend are
+// tolerated without marking it down (0 disables)
+func (hc *HealthChecker) SetGracePeriod(grace time.Duration) {
+	hc.mu.Lock()
+	hc.grace = grace
+	hc.mu.Unlock()
+}
+
+// inGrace reports whether b was added less than the grace period ago
+func (hc *HealthChecker) inGrace(b *backend.Backend) bool {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	joined, ok := hc.joined[b]
+	return ok && time.Since(joined) < hc.grace
+}
+
+// Timings returns the current probe interval and timeout
+func (hc *HealthChecker) Timings() (interval, timeout time.Duration) {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.interval, hc.timeout
+}
+
+// getBackends returns the currently checked backends
+func (hc *HealthChecker) getBackends() []*backend.Backend {
+	hc.mu.RLock()
+	defer hc.mu.RUnlock()
+	return hc.backends
+}
+
+// RegisterMetrics exports probe metrics into reg, separate from request metrics
+func (hc *HealthChecker) RegisterMetrics(reg *metrics.Registry) {
+	hc.probes = reg.NewCounterVec(metrics.HealthProbesTotal,
+		"Active health probes by backend and result", "backend", "result")
+	hc.latency = reg.NewHistogramVec(metrics.HealthProbeDuration,
+		"Duration of active health probes by backend", metrics.DefaultLatencyBuckets, "backend")
+	reg.NewGaugeFunc(metrics.HealthProbeFailureStreak,
+		"Consecutive failed health probes per backend", []string{"backend"},
+		func(emit func(float64, ...string)) {
+			for _, b := range hc.getBackends() {
+				emit(float64(hc.ProbeStats(b).ConsecutiveFailures), b.GetURL().String())
+			}
+		})
+	reg.NewGaugeFunc(metrics.HealthProbeSuccessRatio,
+		"Fraction of successful health probes per backend", []string{"backend"},
+		func(emit func(float64, ...string)) {
+			for _, b := range hc.getBackends() {
+				emit(hc.ProbeStats(b).SuccessRate(), b.GetURL().String())
+			}
+		})
+}
+
+// ProbeStats returns a snapshot of the probe statistics for b
+func (hc *HealthChecker) ProbeStats(b *backend.Backend) ProbeStats {
+	hc.statsMu.RLock()
+	defer hc.statsMu.RUnlock()
+	if ps, ok := hc.stats[b]; ok {
+		return *ps
+	}
+	return ProbeStats{}
+}
+
+// recordProbe updates probe statistics and metrics after a check
+func (hc *HealthChecker) recordProbe(b *backend.Backend, healthy bool, duration time.Duration) {
+	hc.statsMu.Lock()
+	ps, ok := hc.stats[b]
+	if !ok {
+		ps = &ProbeStats{}
+		hc.stats[b] = ps
+	}
+	ps.Total++
+	if healthy {
+		ps.Successes++
+		ps.ConsecutiveFailures = 0
+	} else {
+		ps.ConsecutiveFailures++
+	}
+	ps.LastDuration = duration
+	ps.LastProbe = time.Now()
+	hc.statsMu.Unlock()
+
+	if hc.probes == nil {
+		return
+	}
+	result := "failure"
+	if healthy {
+		result = "success"
+	}
+	hc.probes.With(b.GetURL().String(), result).Inc()
+	hc.latency.With(b.GetURL().String()).Observe(duration.Seconds())
 }
 
 // Start begins the health check loop
 func (hc *HealthChecker) Start(ctx context.Context) {
-	ticker := time.NewTicker(hc.interval)
+	ticker := time.NewTicker(hc.tickInterval())
 	defer ticker.Stop()
 
 	// Perform initial health check
-	hc.checkAll()
+	hc.checkAll(ctx)
 
 	for {
 		select {
 		case <-ctx.Done():
-			log.Println("Health checker stopped")
+			hc.log().InfoContext(ctx, "health checker stopped")
 			return
 		case <-ticker.C:
-			hc.checkAll()
+			hc.checkAll(ctx)
+		case <-hc.reset:
+			ticker.Reset(hc.tickInterval())
+			// Probe newly added backends without waiting for the next tick
+			hc.checkAll(ctx)
 		}
 	}
 }
 
-// checkAll checks all backends
-func (hc *HealthChecker) checkAll() {
+// tickInterval is the global interval, or the shortest per-backend override
+func (hc *HealthChecker) tickInterval() time.Duration {
+	interval, _ := hc.Timings()
+	for _, b := range hc.getBackends() {
+		if bi := b.HealthInterval(); bi > 0 && bi < interval {
+			interval = bi
+		}
+	}
+	return interval
+}
+
+// checkAll checks every backend whose probe interval has elapsed; probes
+// still running when ctx ends are cancelled
+func (hc *HealthChecker) checkAll(ctx context.Context) {
+	now := time.Now()
+	global, _ := hc.Timings()
+	tick := hc.tickInterval()
+
+	hc.mu.Lock()
+	due := make([]*backend.Backend, 0, len(hc.backends))
 	for _, b := range hc.backends {
-		go hc.check(b)
+		interval := b.HealthInterval()
+		if interval <= 0 {
+			interval = global
+		}
+		// Half a tick of slack absorbs ticker jitter
+		if last, ok := hc.started[b]; ok && now.Sub(last)+tick/2 < interval {
+			continue
+		}
+		hc.started[b] = now
+		due = append(due, b)
+	}
+	hc.mu.Unlock()
+
+	for _, b := range due {
+		go hc.check(ctx, b)
 	}
 }
 
 // check performs a health check on a single backend
-func (hc *HealthChecker) check(b *backend.Backend) {
-	start := time.Now()
+func (hc *HealthChecker) check(ctx context.Context, b *backend.Backend) {
+	hc.mu.RLock()
+	timeout := hc.timeout
+	hc.mu.RUnlock()
 
-	req, err := http.NewRequest(http.MethodGet, b.GetURL().String(), nil)
+	duration, err := probe(ctx, timeout, b)
+	if ctx.Err() != nil {
+		// Stopped while probing: the result says nothing about b
+		return
+	}
 	if err != nil {
-		b.SetAlive(false)
-		log.Printf("Failed to create request for %s: %v", b.GetURL(), err)
+		hc.fail(ctx, b, duration, err.Error())
 		return
 	}
+	hc.setAlive(b, true)
+	b.UpdateResponseTime(duration)
+	hc.recordProbe(b, true, duration)
+	hc.log().InfoContext(ctx, "backend is healthy", "backend", b.GetURL().String(), "responseTime", duration)
+}
+
+// Probe performs a single health check of b without changing its state
+func Probe(ctx context.Context, b *backend.Backend, timeout time.Duration) (time.Duration, error) {
+	return probe(ctx, timeout, b)
+}
 
-	resp, err := hc.client.Do(req)
-	duration := time.Since(start)
+// probe runs b's health check (see backend.HealthCheck); by default 2xx and
+// 3xx responses are healthy. It goes through b's own transport so probes use
+// the same TLS settings (CA, client certificate, server name) as traffic.
+func probe(ctx context.Context, timeout time.Duration, b *backend.Backend) (time.Duration, error) {
+	start := time.Now()
 
+	check := b.HealthCheck()
+	req, err := check.NewRequest(ctx, b.URL)
 	if err != nil {
-		b.SetAlive(false)
-		log.Printf("Backend %s is down: %v", b.GetURL(), err)
-		return
+		return time.Since(start), err
+	}
+
+	// Don't leave probe connections in the pool traffic draws from
+	req.Close = true
+	client := &http.Client{Timeout: timeout, Transport: b.ReverseProxy.Transport}
+	resp, err := client.Do(req)
+	if err != nil {
+		return time.Since(start), err
 	}
 	defer resp.Body.Close()
+	err = check.Check(resp)
+	return time.Since(start), err
+}
 
-	// Consider 2xx and 3xx as healthy
-	if resp.StatusCode >= 200 && resp.StatusCode < 400 {
-		b.SetAlive(true)
-		b.UpdateResponseTime(duration)
-		log.Printf("Backend %s is healthy (response time: %v)", b.GetURL(), duration)
-	} else {
-		b.SetAlive(false)
-		log.Printf("Backend %s returned status %d", b.GetURL(), resp.StatusCode)
+// fail records a failed probe and marks b down unless it is in its grace period
+func (hc *HealthChecker) fail(ctx context.Context, b *backend.Backend, duration time.Duration, reason string) {
+	hc.recordProbe(b, false, duration)
+	if hc.inGrace(b) {
+		hc.log().InfoContext(ctx, "backend failed its health check during its grace period", "backend", b.GetURL().String(), "reason", reason)
+		return
 	}
+	hc.setAlive(b, false)
+	hc.log().WarnContext(ctx, "backend is down", "backend", b.GetURL().String(), "reason", reason)
 }
@@ -0,0 +1,326 @@
+package healthcheck
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/metrics"
+)
+
+func TestHealthChecker_ProbeStats(t *testing.T) {
+	healthy := true
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !healthy {
+			w.WriteHeader(http.StatusInternalServerError)
+		}
+	}))
+	defer server.Close()
+
+	b, _ := backend.NewBackend(server.URL)
+	hc := NewHealthChecker([]*backend.Backend{b}, time.Second, time.Second)
+	reg := metrics.NewRegistry()
+	hc.RegisterMetrics(reg)
+
+	hc.check(context.Background(), b)
+	healthy = false
+	hc.check(context.Background(), b)
+	hc.check(context.Background(), b)
+
+	ps := hc.ProbeStats(b)
+	if ps.Total != 3 || ps.Successes != 1 {
+		t.Errorf("Expected 3 probes with 1 success, got %+v", ps)
+	}
+	if ps.ConsecutiveFailures != 2 {
+		t.Errorf("Expected 2 consecutive failures, got %d", ps.ConsecutiveFailures)
+	}
+	if b.IsAlive() {
+		t.Error("Backend should be marked down after a failed probe")
+	}
+
+	var buf bytes.Buffer
+	reg.Write(&buf, metrics.FormatText)
+	for _, want := range []string{
+		`lb_health_probes_total{backend="` + server.URL + `",result="failure"} 2`,
+		`lb_health_probe_consecutive_failures{backend="` + server.URL + `"} 2`,
+		`lb_health_probe_duration_seconds_count{backend="` + server.URL + `"} 3`,
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("Metrics missing %q", want)
+		}
+	}
+
+	healthy = true
+	hc.check(context.Background(), b)
+	if hc.ProbeStats(b).ConsecutiveFailures != 0 {
+		t.Error("A successful probe should reset consecutive failures")
+	}
+}
+
+func TestHealthChecker_PerBackendOverrides(t *testing.T) {
+	paths := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths <- r.URL.Path
+	}))
+	defer server.Close()
+
+	fast, _ := backend.NewBackendWithConfig(backend.Config{URL: server.URL, HealthPath: "/healthz", HealthInterval: time.Second})
+	slow, _ := backend.NewBackend(server.URL + "/slow")
+	hc := NewHealthChecker([]*backend.Backend{fast, slow}, 10*time.Second, time.Second)
+
+	if hc.tickInterval() != time.Second {
+		t.Errorf("Expected tick interval 1s, got %v", hc.tickInterval())
+	}
+
+	// First round probes both, then only the overdue one is probed
+	hc.checkAll(context.Background())
+	got := map[string]bool{<-paths: true, <-paths: true}
+	if !got["/healthz"] || !got["/slow"] {
+		t.Errorf("Expected probes to /healthz and /slow, got %v", got)
+	}
+
+	hc.mu.Lock()
+	hc.started[fast] = time.Now().Add(-2 * time.Second)
+	hc.mu.Unlock()
+	hc.checkAll(context.Background())
+	if p := <-paths; p != "/healthz" {
+		t.Errorf("Expected only /healthz to be due, got %s", p)
+	}
+	select {
+	case p := <-paths:
+		t.Errorf("Unexpected probe to %s", p)
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestHealthChecker_GracePeriod(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	initial, _ := backend.NewBackend(server.URL)
+	hc := NewHealthChecker([]*backend.Backend{initial}, time.Second, time.Second)
+	hc.SetGracePeriod(time.Minute)
+
+	added, _ := backend.NewBackend(server.URL)
+	hc.SetBackends([]*backend.Backend{initial, added})
+
+	hc.check(context.Background(), added)
+	if !added.IsAlive() {
+		t.Error("A newly added backend should stay up during its grace period")
+	}
+	if hc.ProbeStats(added).ConsecutiveFailures != 1 {
+		t.Error("Failed probes should still be recorded during the grace period")
+	}
+
+	hc.check(context.Background(), initial)
+	if initial.IsAlive() {
+		t.Error("Backends present from the start have no grace period")
+	}
+
+	hc.SetGracePeriod(0)
+	hc.check(context.Background(), added)
+	if added.IsAlive() {
+		t.Error("Backend should be marked down once the grace period is over")
+	}
+}
+
+func TestProbe(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/down" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	up, _ := backend.NewBackendWithConfig(backend.Config{URL: server.URL, HealthPath: "/up"})
+	if _, err := Probe(context.Background(), up, time.Second); err != nil {
+		t.Errorf("Expected a healthy probe, got %v", err)
+	}
+
+	down, _ := backend.NewBackendWithConfig(backend.Config{URL: server.URL, HealthPath: "/down"})
+	down.SetAlive(true)
+	if _, err := Probe(context.Background(), down, time.Second); err == nil || !strings.Contains(err.Error(), "503") {
+		t.Errorf("Expected a 503 probe error, got %v", err)
+	}
+	if !down.IsAlive() {
+		t.Error("Probe should not change the backend state")
+	}
+}
+
+// writePEM writes a PEM block of the given type to a file in dir
+func writePEM(t *testing.T, dir, name, blockType string, der []byte) string {
+	t.Helper()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: blockType, Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestProbe_TLS(t *testing.T) {
+	dir := t.TempDir()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "go-balancer"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	clientDER, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, _ := x509.MarshalECPrivateKey(key)
+	clientCert, _ := x509.ParseCertificate(clientDER)
+	clientCAs := x509.NewCertPool()
+	clientCAs.AddCert(clientCert)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	privateCA := httptest.NewTLSServer(handler)
+	defer privateCA.Close()
+	mutual := httptest.NewUnstartedServer(handler)
+	mutual.TLS = &tls.Config{ClientAuth: tls.RequireAndVerifyClientCert, ClientCAs: clientCAs}
+	mutual.StartTLS()
+	defer mutual.Close()
+
+	certFile := writePEM(t, dir, "client.pem", "CERTIFICATE", clientDER)
+	keyFile := writePEM(t, dir, "client-key.pem", "EC PRIVATE KEY", keyDER)
+	tests := []struct {
+		name    string
+		url     string
+		tls     backend.TLSConfig
+		healthy bool
+	}{
+		{"private ca", privateCA.URL, backend.TLSConfig{CAFile: writePEM(t, dir, "ca.pem", "CERTIFICATE", privateCA.Certificate().Raw)}, true},
+		{"private ca untrusted", privateCA.URL, backend.TLSConfig{}, false},
+		{"client certificate", mutual.URL, backend.TLSConfig{
+			CAFile:   writePEM(t, dir, "mutual-ca.pem", "CERTIFICATE", mutual.Certificate().Raw),
+			CertFile: certFile,
+			KeyFile:  keyFile,
+		}, true},
+		{"client certificate missing", mutual.URL, backend.TLSConfig{InsecureSkipVerify: true}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			b, err := backend.NewBackendWithConfig(backend.Config{URL: tt.url, TLS: tt.tls})
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			if _, err := Probe(context.Background(), b, time.Second); (err == nil) != tt.healthy {
+				t.Errorf("Expected healthy = %v, got %v", tt.healthy, err)
+			}
+		})
+	}
+}
+
+func TestHealthChecker_Config(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/healthz" || r.Method != http.MethodHead && r.Method != http.MethodGet {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.WriteHeader(http.StatusAccepted)
+		if r.Method == http.MethodGet {
+			w.Write([]byte(`{"status": "ready", "role": "` + r.Header.Get("X-Role") + `"}`))
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name    string
+		config  Config
+		backend backend.Config
+		wantErr string
+	}{
+		{
+			name:    "root by default",
+			wantErr: "404",
+		},
+		{
+			name:    "missing header",
+			config:  Config{Path: "/healthz"},
+			wantErr: "401",
+		},
+		{
+			name:   "path and header",
+			config: Config{Path: "/healthz", Headers: map[string]string{"Authorization": "Bearer secret"}},
+		},
+		{
+			name:    "unexpected status",
+			config:  Config{Path: "/healthz", Headers: map[string]string{"Authorization": "Bearer secret"}, ExpectedStatus: []int{200}},
+			wantErr: "returned status 202, expected [200]",
+		},
+		{
+			name:   "expected body",
+			config: Config{Path: "/healthz", Headers: map[string]string{"Authorization": "Bearer secret"}, ExpectedBody: `"ready"`},
+		},
+		{
+			name:    "body without the substring",
+			config:  Config{Path: "/healthz", Method: http.MethodHead, Headers: map[string]string{"Authorization": "Bearer secret"}, ExpectedBody: `"ready"`},
+			wantErr: `body does not contain "\"ready\""`,
+		},
+		{
+			name:   "backend overrides",
+			config: Config{Path: "/", Headers: map[string]string{"Authorization": "Bearer secret"}, ExpectedBody: "primary"},
+			backend: backend.Config{
+				HealthPath:    "/healthz",
+				HealthHeaders: map[string]string{"X-Role": "primary"},
+				HealthStatus:  []int{202},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := tt.backend
+			cfg.URL = server.URL
+			b, err := backend.NewBackendWithConfig(cfg)
+			if err != nil {
+				t.Fatalf("Failed to create backend: %v", err)
+			}
+			hc := NewHealthChecker(nil, time.Second, time.Second)
+			hc.SetConfig(tt.config)
+			hc.SetBackends([]*backend.Backend{b})
+
+			hc.check(context.Background(), b)
+			_, err = Probe(context.Background(), b, time.Second)
+			if tt.wantErr == "" {
+				if err != nil || !b.IsAlive() {
+					t.Errorf("Expected a passing probe, got %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("Expected error containing %q, got %v", tt.wantErr, err)
+			}
+			if b.IsAlive() {
+				t.Error("Expected a failed probe to mark the backend down")
+			}
+		})
+	}
+}
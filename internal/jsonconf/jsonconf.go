@@ -0,0 +1,95 @@
+// Package jsonconf decodes loosely typed configuration documents (JSON, or
+// YAML converted to generic values) into typed structs
+package jsonconf
+
+import (
+	"encoding/json"
+	"reflect"
+	"strings"
+	"time"
+)
+
+var durationType = reflect.TypeOf(time.Duration(0))
+
+// Decode stores generic decoded values (maps, slices, scalars) into out,
+// accepting duration strings for time.Duration fields
+func Decode(raw interface{}, out interface{}) error {
+	data, err := json.Marshal(NormalizeDurations(raw, reflect.TypeOf(out)))
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, out)
+}
+
+// NormalizeDurations walks decoded JSON alongside the target type and converts
+// duration strings such as "15s" into nanoseconds, so time.Duration fields accept
+// both human-readable strings and plain integers
+func NormalizeDurations(v interface{}, t reflect.Type) interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for i := 0; i < t.NumField(); i++ {
+			f := t.Field(i)
+			if !f.IsExported() {
+				continue
+			}
+			name := jsonName(f)
+			if name == "-" {
+				continue
+			}
+			for key, val := range obj {
+				if strings.EqualFold(key, name) {
+					obj[key] = NormalizeDurations(val, f.Type)
+				}
+			}
+		}
+		return obj
+	case reflect.Slice, reflect.Array:
+		arr, ok := v.([]interface{})
+		if !ok {
+			return v
+		}
+		for i := range arr {
+			arr[i] = NormalizeDurations(arr[i], t.Elem())
+		}
+		return arr
+	case reflect.Map:
+		obj, ok := v.(map[string]interface{})
+		if !ok {
+			return v
+		}
+		for k := range obj {
+			obj[k] = NormalizeDurations(obj[k], t.Elem())
+		}
+		return obj
+	}
+
+	if t == durationType {
+		if s, ok := v.(string); ok {
+			if d, err := time.ParseDuration(s); err == nil {
+				return int64(d)
+			}
+		}
+	}
+	return v
+}
+
+// jsonName returns the JSON key of a struct field
+func jsonName(f reflect.StructField) string {
+	tag := f.Tag.Get("json")
+	if tag == "" {
+		return f.Name
+	}
+	name, _, _ := strings.Cut(tag, ",")
+	if name == "" {
+		return f.Name
+	}
+	return name
+}
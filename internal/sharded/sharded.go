@@ -0,0 +1,83 @@
+// Package sharded provides counters split across cache-line sized shards,
+// so cores incrementing the same hot counter don't contend on one cache
+// line. Reads sum the shards and are correspondingly slower.
+package sharded
+
+import (
+	"runtime"
+	"sync/atomic"
+	"unsafe"
+)
+
+// maxShards bounds the memory of a counter on very large machines
+const maxShards = 64
+
+// shard is padded to a cache line
+type shard struct {
+	n atomic.Int64
+	_ [56]byte
+}
+
+// Counter is an int64 counter; the zero value is ready to use
+type Counter struct {
+	shards atomic.Pointer[[]shard]
+}
+
+// Add adds delta to the counter
+func (c *Counter) Add(delta int64) {
+	shards := c.get()
+	shards[shardIndex(len(shards))].n.Add(delta)
+}
+
+// Load returns the sum of the shards
+func (c *Counter) Load() int64 {
+	p := c.shards.Load()
+	if p == nil {
+		return 0
+	}
+	var total int64
+	for i := range *p {
+		total += (*p)[i].n.Load()
+	}
+	return total
+}
+
+// Reset sets the counter to zero; adds racing with it may be lost or kept
+func (c *Counter) Reset() {
+	p := c.shards.Load()
+	if p == nil {
+		return
+	}
+	for i := range *p {
+		(*p)[i].n.Store(0)
+	}
+}
+
+// shardIndex picks a shard from the address of the calling goroutine's
+// stack: goroutines live on different stacks, so concurrent callers spread
+// over the shards without the cost of a random number
+func shardIndex(n int) int {
+	var marker byte
+	return int(uintptr(unsafe.Pointer(&marker))>>13) & (n - 1)
+}
+
+// get returns the shards, allocating them on first use
+func (c *Counter) get() []shard {
+	if p := c.shards.Load(); p != nil {
+		return *p
+	}
+	shards := make([]shard, shardCount())
+	if c.shards.CompareAndSwap(nil, &shards) {
+		return shards
+	}
+	return *c.shards.Load()
+}
+
+// shardCount is the power of two at or above GOMAXPROCS, up to maxShards
+func shardCount() int {
+	n := 1
+	for n < runtime.GOMAXPROCS(0) && n < maxShards {
+		n <<= 1
+	}
+	return n
+}
@@ -0,0 +1,54 @@
+package sharded
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+func TestCounter(t *testing.T) {
+	var c Counter
+	if c.Load() != 0 {
+		t.Errorf("Expected 0, got %d", c.Load())
+	}
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 1000; j++ {
+				c.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+	c.Add(-500)
+	if c.Load() != 7500 {
+		t.Errorf("Expected 7500, got %d", c.Load())
+	}
+
+	c.Reset()
+	if c.Load() != 0 {
+		t.Errorf("Expected 0 after reset, got %d", c.Load())
+	}
+}
+
+func BenchmarkCounter(b *testing.B) {
+	b.Run("sharded", func(b *testing.B) {
+		var c Counter
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Add(1)
+			}
+		})
+	})
+	b.Run("atomic", func(b *testing.B) {
+		var c atomic.Int64
+		b.RunParallel(func(pb *testing.PB) {
+			for pb.Next() {
+				c.Add(1)
+			}
+		})
+	})
+}
@@ -0,0 +1,140 @@
+// Package testutil holds helpers shared by tests and benchmarks: fake
+// backends with configurable latency and error rates, a response writer that
+// discards everything, and an in-process load generator.
+package testutil
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/mockbackend"
+)
+
+// Backends starts n mock backends answering after opts.Latency (±Jitter)
+// and failing opts.ErrorRate of the requests, and returns their URLs. They
+// are closed when the test ends.
+func Backends(tb testing.TB, n int, opts mockbackend.Options) []string {
+	tb.Helper()
+	opts.Quiet = true
+	urls := make([]string, n)
+	for i := range urls {
+		s, err := mockbackend.New(opts)
+		if err != nil {
+			tb.Fatalf("Failed to create mock backend: %v", err)
+		}
+		server := httptest.NewServer(s)
+		tb.Cleanup(server.Close)
+		urls[i] = server.URL
+	}
+	return urls
+}
+
+// DiscardWriter is a ResponseWriter dropping the response, so benchmarks
+// measure the handler rather than a recorder's buffer
+type DiscardWriter struct {
+	header http.Header
+}
+
+// Header returns the (discarded) response headers
+func (w *DiscardWriter) Header() http.Header {
+	if w.header == nil {
+		w.header = http.Header{}
+	}
+	return w.header
+}
+
+func (w *DiscardWriter) Write(p []byte) (int, error) { return io.Discard.Write(p) }
+func (w *DiscardWriter) WriteHeader(int)             {}
+func (w *DiscardWriter) Flush()                      {}
+
+// LoadOptions describes a load run
+type LoadOptions struct {
+	// Concurrency is the number of workers sending requests (default 1)
+	Concurrency int
+	// Requests is the total number of requests to send
+	Requests int
+	// NewRequest builds the i-th request (default GET /)
+	NewRequest func(i int) *http.Request
+}
+
+// LoadResult summarizes a load run
+type LoadResult struct {
+	Requests int
+	// Statuses counts the responses by status code
+	Statuses map[int]int
+	Elapsed  time.Duration
+	// Latencies are sorted ascending
+	Latencies []time.Duration
+}
+
+// Load sends opts.Requests requests to h from opts.Concurrency workers, in
+// process, and records their status codes and latencies
+func Load(h http.Handler, opts LoadOptions) LoadResult {
+	concurrency := max(opts.Concurrency, 1)
+	newRequest := opts.NewRequest
+	if newRequest == nil {
+		newRequest = func(int) *http.Request { return httptest.NewRequest(http.MethodGet, "/", nil) }
+	}
+
+	statuses := make([]int, opts.Requests)
+	latencies := make([]time.Duration, opts.Requests)
+	var next atomic.Int64
+	var wg sync.WaitGroup
+	start := time.Now()
+	for range concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(next.Add(1) - 1)
+				if i >= opts.Requests {
+					return
+				}
+				rec := httptest.NewRecorder()
+				began := time.Now()
+				h.ServeHTTP(rec, newRequest(i))
+				latencies[i] = time.Since(began)
+				statuses[i] = rec.Code
+			}
+		}()
+	}
+	wg.Wait()
+
+	result := LoadResult{Requests: opts.Requests, Statuses: make(map[int]int), Elapsed: time.Since(start)}
+	for _, code := range statuses {
+		result.Statuses[code]++
+	}
+	slices.Sort(latencies)
+	result.Latencies = latencies
+	return result
+}
+
+// Percentile returns the latency below which the fraction p (0-1) of the
+// requests completed
+func (r LoadResult) Percentile(p float64) time.Duration {
+	if len(r.Latencies) == 0 {
+		return 0
+	}
+	i := int(p * float64(len(r.Latencies)-1))
+	return r.Latencies[min(max(i, 0), len(r.Latencies)-1)]
+}
+
+// ErrorRate returns the share of responses with a 5xx status
+func (r LoadResult) ErrorRate() float64 {
+	if r.Requests == 0 {
+		return 0
+	}
+	failed := 0
+	for code, n := range r.Statuses {
+		if code >= http.StatusInternalServerError {
+			failed += n
+		}
+	}
+	return float64(failed) / float64(r.Requests)
+}
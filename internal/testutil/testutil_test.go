@@ -0,0 +1,55 @@
+package testutil
+
+import (
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/mockbackend"
+)
+
+func TestLoad(t *testing.T) {
+	h := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/fail" {
+			w.WriteHeader(http.StatusBadGateway)
+		}
+	})
+
+	result := Load(h, LoadOptions{
+		Concurrency: 4,
+		Requests:    100,
+		NewRequest: func(i int) *http.Request {
+			path := "/"
+			if i%4 == 0 {
+				path = "/fail"
+			}
+			req, _ := http.NewRequest(http.MethodGet, "http://lb"+path, nil)
+			return req
+		},
+	})
+
+	if result.Statuses[http.StatusBadGateway] != 25 || result.Statuses[http.StatusOK] != 75 {
+		t.Errorf("Expected 75 OK and 25 failures, got %v", result.Statuses)
+	}
+	if result.ErrorRate() != 0.25 {
+		t.Errorf("Expected an error rate of 0.25, got %v", result.ErrorRate())
+	}
+	if len(result.Latencies) != 100 || result.Percentile(0.5) > result.Percentile(0.99) {
+		t.Errorf("Expected 100 sorted latencies, got %d", len(result.Latencies))
+	}
+}
+
+func TestBackends(t *testing.T) {
+	urls := Backends(t, 2, mockbackend.Options{Latency: time.Millisecond, ErrorRate: 1, ErrorStatus: http.StatusServiceUnavailable})
+	if len(urls) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(urls))
+	}
+	resp, err := http.Get(urls[1])
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("Expected the configured error status, got %d", resp.StatusCode)
+	}
+}
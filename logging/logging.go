@@ -0,0 +1,117 @@
+package logging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"io"
+	"log/slog"
+	"strings"
+	"sync/atomic"
+)
+
+// RequestIDAttr is the attribute key carrying the request ID in log records
+const RequestIDAttr = "request_id"
+
+// RequestIDHeader carries the request ID between clients, the balancer and
+// backends
+const RequestIDHeader = "X-Request-ID"
+
+type requestIDKey struct{}
+
+// WithRequestID returns a copy of ctx carrying the request ID
+func WithRequestID(ctx context.Context, id string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+// RequestID returns the request ID stored in ctx, or ""
+func RequestID(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+// NewRequestID generates a random 128-bit request ID
+func NewRequestID() string {
+	var b [16]byte
+	rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
+
+// ContextHandler adds the request ID found in the context to every record
+type ContextHandler struct {
+	slog.Handler
+}
+
+// Handle adds the request ID attribute, if any, before delegating
+func (h ContextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if id := RequestID(ctx); id != "" {
+		r.AddAttrs(slog.String(RequestIDAttr, id))
+	}
+	return h.Handler.Handle(ctx, r)
+}
+
+// WithAttrs keeps the context handler wrapping the derived handler
+func (h ContextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return ContextHandler{h.Handler.WithAttrs(attrs)}
+}
+
+// WithGroup keeps the context handler wrapping the derived handler
+func (h ContextHandler) WithGroup(name string) slog.Handler {
+	return ContextHandler{h.Handler.WithGroup(name)}
+}
+
+var logger atomic.Pointer[slog.Logger]
+
+// SetLogger replaces the logger used by the balancer's packages; its handler
+// is wrapped so request IDs are added from the context
+func SetLogger(l *slog.Logger) {
+	if l == nil {
+		logger.Store(nil)
+		return
+	}
+	logger.Store(WithContext(l))
+}
+
+// WithContext returns l with its handler wrapped in a ContextHandler, unless
+// it already is
+func WithContext(l *slog.Logger) *slog.Logger {
+	if _, ok := l.Handler().(ContextHandler); ok {
+		return l
+	}
+	return slog.New(ContextHandler{l.Handler()})
+}
+
+// Logger returns the context-aware logger; without SetLogger it wraps slog.Default()
+func Logger() *slog.Logger {
+	if l := logger.Load(); l != nil {
+		return l
+	}
+	return slog.New(ContextHandler{slog.Default().Handler()})
+}
+
+// ParseLevel converts debug, info, warn or error to a slog level; anything
+// else is info
+func ParseLevel(s string) slog.Level {
+	var level slog.Level
+	if err := level.UnmarshalText([]byte(s)); err != nil {
+		return slog.LevelInfo
+	}
+	return level
+}
+
+// Configure applies the log level and format to the whole process. The
+// json format writes every line, including those of the standard log
+// package, as a JSON object to w; text keeps the standard log output.
+func Configure(level, format string, w io.Writer) {
+	lvl := ParseLevel(level)
+	if strings.EqualFold(format, "json") {
+		l := slog.New(slog.NewJSONHandler(w, &slog.HandlerOptions{Level: lvl}))
+		slog.SetDefault(l)
+		SetLogger(l)
+		return
+	}
+	slog.SetLogLoggerLevel(lvl)
+}
@@ -0,0 +1,90 @@
+package logging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"log"
+	"log/slog"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestContextHandler_AddsRequestID(t *testing.T) {
+	var buf bytes.Buffer
+	SetLogger(slog.New(slog.NewJSONHandler(&buf, nil)))
+	defer SetLogger(nil)
+
+	ctx := WithRequestID(context.Background(), "abc123")
+	Logger().With("component", "test").InfoContext(ctx, "hello")
+
+	var record map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+		t.Fatalf("Failed to parse log line: %v", err)
+	}
+	if record[RequestIDAttr] != "abc123" {
+		t.Errorf("Expected request_id abc123, got %v", record[RequestIDAttr])
+	}
+
+	buf.Reset()
+	Logger().InfoContext(context.Background(), "no id")
+	if bytes.Contains(buf.Bytes(), []byte(RequestIDAttr)) {
+		t.Errorf("Expected no request_id without one in context, got %s", buf.String())
+	}
+}
+
+func TestNewRequestID(t *testing.T) {
+	a, b := NewRequestID(), NewRequestID()
+	if len(a) != 32 {
+		t.Errorf("Expected 32 hex chars, got %d", len(a))
+	}
+	if a == b {
+		t.Error("Expected distinct request IDs")
+	}
+}
+
+func TestConfigure_JSON(t *testing.T) {
+	previous := slog.Default()
+	defer func() {
+		slog.SetDefault(previous)
+		SetLogger(nil)
+		log.SetOutput(os.Stderr)
+		log.SetFlags(log.LstdFlags)
+	}()
+
+	var buf bytes.Buffer
+	Configure("info", "json", &buf)
+	log.Printf("[Test] from the standard logger")
+	Logger().Debug("filtered by level")
+	Logger().Warn("from slog")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 log lines, got %d: %q", len(lines), buf.String())
+	}
+	for i, want := range []string{"[Test] from the standard logger", "from slog"} {
+		var record map[string]interface{}
+		if err := json.Unmarshal([]byte(lines[i]), &record); err != nil {
+			t.Fatalf("Expected JSON log line, got %q", lines[i])
+		}
+		if record["msg"] != want {
+			t.Errorf("Expected msg %q, got %v", want, record["msg"])
+		}
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"debug": slog.LevelDebug,
+		"WARN":  slog.LevelWarn,
+		"error": slog.LevelError,
+		"":      slog.LevelInfo,
+		"loud":  slog.LevelInfo,
+	}
+	for in, want := range tests {
+		if got := ParseLevel(in); got != want {
+			t.Errorf("Expected %v for %q, got %v", want, in, got)
+		}
+	}
+}
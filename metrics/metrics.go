@@ -0,0 +1,216 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Exposition content types
+const (
+	ContentTypeText        = "text/plain; version=0.0.4; charset=utf-8"
+	ContentTypeOpenMetrics = "application/openmetrics-text; version=1.0.0; charset=utf-8"
+)
+
+// DefaultLatencyBuckets are histogram buckets (seconds) suited to HTTP latencies
+var DefaultLatencyBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// Format selects the exposition format
+type Format int
+
+const (
+	// FormatText is the classic Prometheus text format (no exemplars)
+	FormatText Format = iota
+	// FormatOpenMetrics is the OpenMetrics text format, which carries exemplars
+	FormatOpenMetrics
+)
+
+// family is anything the registry can expose
+type family interface {
+	write(w *bufio.Writer, format Format)
+	gather(emit func(Sample))
+}
+
+// Label is a single name/value pair attached to a sample
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Sample is a single flattened time series value, as used by push exporters;
+// histogram families produce _bucket, _sum and _count samples
+type Sample struct {
+	Name   string
+	Labels []Label
+	Value  float64
+}
+
+// Gather returns the current value of every series in the registry
+func (r *Registry) Gather() []Sample {
+	r.mu.RLock()
+	families := make([]family, len(r.families))
+	copy(families, r.families)
+	r.mu.RUnlock()
+
+	var samples []Sample
+	for _, f := range families {
+		f.gather(func(s Sample) {
+			samples = append(samples, s)
+		})
+	}
+	return samples
+}
+
+func makeLabels(names, values []string, extra ...Label) []Label {
+	labels := make([]Label, 0, len(names)+len(extra))
+	for i, n := range names {
+		labels = append(labels, Label{Name: n, Value: values[i]})
+	}
+	return append(labels, extra...)
+}
+
+// Registry holds metric families and renders them for scraping
+type Registry struct {
+	mu       sync.RWMutex
+	families []family
+	names    map[string]bool
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{names: make(map[string]bool)}
+}
+
+func (r *Registry) register(name string, f family) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.names[name] {
+		panic(fmt.Sprintf("metrics: duplicate registration of %s", name))
+	}
+	r.names[name] = true
+	r.families = append(r.families, f)
+}
+
+// Write renders all registered families in the given format
+func (r *Registry) Write(w io.Writer, format Format) error {
+	r.mu.RLock()
+	families := make([]family, len(r.families))
+	copy(families, r.families)
+	r.mu.RUnlock()
+
+	bw := bufio.NewWriter(w)
+	for _, f := range families {
+		f.write(bw, format)
+	}
+	if format == FormatOpenMetrics {
+		bw.WriteString("# EOF\n")
+	}
+	return bw.Flush()
+}
+
+// Handler returns an HTTP handler serving the registry, negotiating
+// OpenMetrics (with exemplars) when the scraper asks for it
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		format := FormatText
+		contentType := ContentTypeText
+		if strings.Contains(req.Header.Get("Accept"), "application/openmetrics-text") {
+			format = FormatOpenMetrics
+			contentType = ContentTypeOpenMetrics
+		}
+		w.Header().Set("Content-Type", contentType)
+		r.Write(w, format)
+	})
+}
+
+// desc is the shared description of a labeled family
+type desc struct {
+	name   string
+	help   string
+	typ    string
+	labels []string
+}
+
+func (d *desc) writeHeader(w *bufio.Writer, format Format) {
+	name := d.name
+	if format == FormatOpenMetrics && d.typ == "counter" {
+		name = strings.TrimSuffix(name, "_total")
+	}
+	fmt.Fprintf(w, "# HELP %s %s\n", name, escapeHelp(d.help))
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, d.typ)
+}
+
+func (d *desc) key(values []string) string {
+	if len(values) != len(d.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", d.name, len(d.labels), len(values)))
+	}
+	return strings.Join(values, "\xff")
+}
+
+// labelPairs renders {a="x",b="y"} including any extra trailing pair
+func labelPairs(names, values []string, extraName, extraValue string) string {
+	if len(names) == 0 && extraName == "" {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, n := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(n)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(values[i]))
+		sb.WriteByte('"')
+	}
+	if extraName != "" {
+		if len(names) > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(extraName)
+		sb.WriteString(`="`)
+		sb.WriteString(escapeLabel(extraValue))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+// sortedKeys returns map keys in a stable order so output is deterministic
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func formatFloat(v float64) string {
+	switch {
+	case math.IsInf(v, 1):
+		return "+Inf"
+	case math.IsInf(v, -1):
+		return "-Inf"
+	case math.IsNaN(v):
+		return "NaN"
+	}
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func formatTimestamp(t time.Time) string {
+	return strconv.FormatFloat(float64(t.UnixNano())/1e9, 'f', 3, 64)
+}
+
+var helpEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`)
+var labelEscaper = strings.NewReplacer(`\`, `\\`, "\n", `\n`, `"`, `\"`)
+
+func escapeHelp(s string) string  { return helpEscaper.Replace(s) }
+func escapeLabel(s string) string { return labelEscaper.Replace(s) }
@@ -0,0 +1,94 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_TextFormat(t *testing.T) {
+	reg := NewRegistry()
+	reqs := reg.NewCounterVec("test_requests_total", "Requests", "code")
+	reqs.With("200").Add(3)
+	reqs.With("500").Inc()
+
+	reg.NewGaugeFunc("test_up", "Up", []string{"backend"}, func(emit func(float64, ...string)) {
+		emit(1, `http://a"b`)
+	})
+	reg.NewCounterFunc("test_dials_total", "Dials", []string{"backend"}, func(emit func(float64, ...string)) {
+		emit(7, "a")
+	})
+
+	var buf bytes.Buffer
+	reg.Write(&buf, FormatText)
+	out := buf.String()
+
+	for _, want := range []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{code="200"} 3`,
+		`test_requests_total{code="500"} 1`,
+		`test_up{backend="http://a\"b"} 1`,
+		"# TYPE test_dials_total counter",
+		`test_dials_total{backend="a"} 7`,
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("Output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "# EOF") {
+		t.Error("Text format must not contain # EOF")
+	}
+}
+
+func TestHistogram_Exemplars(t *testing.T) {
+	reg := NewRegistry()
+	h := reg.NewHistogramVec("test_latency_seconds", "Latency", []float64{0.1, 1}, "backend")
+	h.With("a").ObserveWithExemplar(0.05, "4bf92f3577b34da6a3ce929d0e0e4736")
+	h.With("a").Observe(0.5)
+	h.With("a").Observe(5)
+
+	var text bytes.Buffer
+	reg.Write(&text, FormatText)
+	if strings.Contains(text.String(), "trace_id") {
+		t.Error("Exemplars must only be rendered in OpenMetrics format")
+	}
+	for _, want := range []string{
+		`test_latency_seconds_bucket{backend="a",le="0.1"} 1`,
+		`test_latency_seconds_bucket{backend="a",le="1"} 2`,
+		`test_latency_seconds_bucket{backend="a",le="+Inf"} 3`,
+		`test_latency_seconds_count{backend="a"} 3`,
+	} {
+		if !strings.Contains(text.String(), want) {
+			t.Errorf("Output missing %q:\n%s", want, text.String())
+		}
+	}
+
+	rr := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/metrics", nil)
+	req.Header.Set("Accept", "application/openmetrics-text; version=1.0.0")
+	reg.Handler().ServeHTTP(rr, req)
+
+	out := rr.Body.String()
+	if rr.Header().Get("Content-Type") != ContentTypeOpenMetrics {
+		t.Errorf("Unexpected content type %q", rr.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(out, `le="0.1"} 1 # {trace_id="4bf92f3577b34da6a3ce929d0e0e4736"} 0.05 `) {
+		t.Errorf("Expected exemplar on the 0.1 bucket:\n%s", out)
+	}
+	if !strings.HasSuffix(out, "# EOF\n") {
+		t.Error("OpenMetrics output must end with # EOF")
+	}
+}
+
+func TestRegistry_DuplicatePanics(t *testing.T) {
+	reg := NewRegistry()
+	reg.NewCounterVec("dup_total", "x")
+	defer func() {
+		if recover() == nil {
+			t.Error("Registering a duplicate name should panic")
+		}
+	}()
+	reg.NewGaugeVec("dup_total", "x")
+}
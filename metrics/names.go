@@ -0,0 +1,31 @@
+package metrics
+
+// Names of the metric families exported by the load balancer, shared with
+// the dashboard generator so panels always match what is exposed
+const (
+	RequestsTotal            = "lb_requests_total"
+	RequestDurationSeconds   = "lb_request_duration_seconds"
+	NoBackendTotal           = "lb_no_backend_total"
+	ErrorsTotal              = "lb_errors_total"
+	BackendUp                = "lb_backend_up"
+	BackendInFlight          = "lb_backend_in_flight_requests"
+	BackendConnections       = "lb_backend_connections"
+	BackendIdleConnections   = "lb_backend_idle_connections"
+	QueueLength              = "lb_queue_length"
+	QueueRejectedTotal       = "lb_queue_rejected_total"
+	LimitRejectedTotal       = "lb_limit_rejected_total"
+	InFlightLimit            = "lb_in_flight_limit"
+	OutlierEjectionsTotal    = "lb_outlier_ejections_total"
+	HealthProbesTotal        = "lb_health_probes_total"
+	HealthProbeDuration      = "lb_health_probe_duration_seconds"
+	HealthProbeFailureStreak = "lb_health_probe_consecutive_failures"
+	HealthProbeSuccessRatio  = "lb_health_probe_success_ratio"
+	BackendDialsTotal        = "lb_backend_dials_total"
+	BackendDialErrorsTotal   = "lb_backend_dial_errors_total"
+	TLSHandshakesTotal       = "lb_backend_tls_handshakes_total"
+	TLSHandshakeSeconds      = "lb_backend_tls_handshake_seconds_total"
+	IdleReapedTotal          = "lb_idle_reaped_total"
+	BackendPhasesTotal       = "lb_backend_phases_total"
+	BackendPhaseSeconds      = "lb_backend_phase_seconds_total"
+	BackendBytesTotal        = "lb_backend_bytes_total"
+)
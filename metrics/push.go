@@ -0,0 +1,255 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Push modes
+const (
+	PushNone        = "none"
+	PushGateway     = "pushgateway"
+	PushRemoteWrite = "remotewrite"
+)
+
+// DefaultPushInterval is used when PushConfig.Interval is zero
+const DefaultPushInterval = 15 * time.Second
+
+// PushConfig configures pushing metrics instead of (or in addition to) being scraped
+type PushConfig struct {
+	Mode     string            `json:"mode"` // none, pushgateway, remotewrite
+	URL      string            `json:"url"`
+	Interval time.Duration     `json:"interval"`
+	Job      string            `json:"job"`
+	Instance string            `json:"instance"`
+	Labels   map[string]string `json:"labels"` // extra labels added to every remote-write series
+}
+
+// ValidateLabels checks the extra labels: they must be valid Prometheus
+// label names and not ones the pusher sets itself (job, instance and the
+// reserved __ names such as __name__)
+func (c PushConfig) ValidateLabels() error {
+	for name := range c.Labels {
+		switch {
+		case !validLabelName(name):
+			return fmt.Errorf("label name %q is invalid", name)
+		case strings.HasPrefix(name, "__"):
+			return fmt.Errorf("label name %q is reserved", name)
+		case name == "job" || name == "instance":
+			return fmt.Errorf("label %q is set from %s", name, name)
+		}
+	}
+	return nil
+}
+
+// validLabelName reports whether name matches [a-zA-Z_][a-zA-Z0-9_]*
+func validLabelName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for i, r := range name {
+		if r != '_' && (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (i == 0 || r < '0' || r > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// Pusher periodically pushes a registry to a Pushgateway or remote-write endpoint
+type Pusher struct {
+	registry *Registry
+	cfg      PushConfig
+	client   *http.Client
+}
+
+// NewPusher validates cfg and creates a pusher; it returns nil for mode "none"
+func NewPusher(reg *Registry, cfg PushConfig) (*Pusher, error) {
+	switch strings.ToLower(cfg.Mode) {
+	case "", PushNone:
+		return nil, nil
+	case PushGateway, PushRemoteWrite:
+	default:
+		return nil, fmt.Errorf("unknown metrics push mode: %s", cfg.Mode)
+	}
+	if cfg.URL == "" {
+		return nil, fmt.Errorf("metrics push mode %s requires a url", cfg.Mode)
+	}
+	if err := cfg.ValidateLabels(); err != nil {
+		return nil, fmt.Errorf("metrics push: %w", err)
+	}
+	if cfg.Interval <= 0 {
+		cfg.Interval = DefaultPushInterval
+	}
+	if cfg.Job == "" {
+		cfg.Job = "go-balancer"
+	}
+	cfg.Mode = strings.ToLower(cfg.Mode)
+
+	return &Pusher{
+		registry: reg,
+		cfg:      cfg,
+		client:   &http.Client{Timeout: 10 * time.Second},
+	}, nil
+}
+
+// Run pushes on every interval until ctx is canceled
+func (p *Pusher) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := p.Push(ctx); err != nil {
+				logging.Logger().Warn("metrics push failed", "error", err)
+			}
+		}
+	}
+}
+
+// Push sends the current registry contents once
+func (p *Pusher) Push(ctx context.Context) error {
+	var req *http.Request
+	var err error
+
+	switch p.cfg.Mode {
+	case PushGateway:
+		var buf bytes.Buffer
+		if err := p.registry.Write(&buf, FormatText); err != nil {
+			return err
+		}
+		req, err = http.NewRequestWithContext(ctx, http.MethodPut, p.gatewayURL(), &buf)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", ContentTypeText)
+	case PushRemoteWrite:
+		body := snappyEncode(encodeWriteRequest(p.registry.Gather(), p.remoteLabels(), time.Now()))
+		req, err = http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/x-protobuf")
+		req.Header.Set("Content-Encoding", "snappy")
+		req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, resp.Body)
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("%s returned status %d", p.cfg.Mode, resp.StatusCode)
+	}
+	return nil
+}
+
+// gatewayURL builds <url>/metrics/job/<job>[/instance/<instance>]
+func (p *Pusher) gatewayURL() string {
+	u := strings.TrimSuffix(p.cfg.URL, "/") + "/metrics/job/" + url.PathEscape(p.cfg.Job)
+	if p.cfg.Instance != "" {
+		u += "/instance/" + url.PathEscape(p.cfg.Instance)
+	}
+	return u
+}
+
+func (p *Pusher) remoteLabels() []Label {
+	labels := []Label{{Name: "job", Value: p.cfg.Job}}
+	if p.cfg.Instance != "" {
+		labels = append(labels, Label{Name: "instance", Value: p.cfg.Instance})
+	}
+	for k, v := range p.cfg.Labels {
+		labels = append(labels, Label{Name: k, Value: v})
+	}
+	return labels
+}
+
+// encodeWriteRequest encodes samples as a Prometheus remote-write WriteRequest
+// protobuf. The extra labels override metric labels of the same name, so no
+// series carries a name twice.
+func encodeWriteRequest(samples []Sample, extra []Label, now time.Time) []byte {
+	ts := now.UnixMilli()
+	overridden := make(map[string]bool, len(extra))
+	for _, l := range extra {
+		overridden[l.Name] = true
+	}
+	var out []byte
+	for _, s := range samples {
+		labels := make([]Label, 0, len(s.Labels)+len(extra)+1)
+		labels = append(labels, Label{Name: "__name__", Value: s.Name})
+		for _, l := range s.Labels {
+			if !overridden[l.Name] && l.Name != "__name__" {
+				labels = append(labels, l)
+			}
+		}
+		labels = append(labels, extra...)
+		sort.Slice(labels, func(i, j int) bool { return labels[i].Name < labels[j].Name })
+
+		var series []byte
+		for _, l := range labels {
+			var label []byte
+			label = appendBytesField(label, 1, []byte(l.Name))
+			label = appendBytesField(label, 2, []byte(l.Value))
+			series = appendBytesField(series, 1, label)
+		}
+
+		var sample []byte
+		sample = appendTag(sample, 1, 1) // double value, fixed64
+		sample = binary.LittleEndian.AppendUint64(sample, math.Float64bits(s.Value))
+		sample = appendTag(sample, 2, 0) // int64 timestamp, varint
+		sample = binary.AppendUvarint(sample, uint64(ts))
+		series = appendBytesField(series, 2, sample)
+
+		out = appendBytesField(out, 1, series)
+	}
+	return out
+}
+
+func appendTag(b []byte, field, wireType int) []byte {
+	return binary.AppendUvarint(b, uint64(field<<3|wireType))
+}
+
+func appendBytesField(b []byte, field int, v []byte) []byte {
+	b = appendTag(b, field, 2)
+	b = binary.AppendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
+
+// snappyEncode produces a valid snappy block using literal elements only;
+// remote-write receivers require snappy framing but not compression ratio
+func snappyEncode(src []byte) []byte {
+	dst := binary.AppendUvarint(nil, uint64(len(src)))
+	for len(src) > 0 {
+		n := len(src)
+		if n > 65536 {
+			n = 65536
+		}
+		switch l := n - 1; {
+		case l < 60:
+			dst = append(dst, byte(l)<<2)
+		case l < 1<<8:
+			dst = append(dst, 60<<2, byte(l))
+		default:
+			dst = append(dst, 61<<2, byte(l), byte(l>>8))
+		}
+		dst = append(dst, src[:n]...)
+		src = src[n:]
+	}
+	return dst
+}
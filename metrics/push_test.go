@@ -0,0 +1,147 @@
+package metrics
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// snappyDecodeLiterals decodes the literal-only blocks produced by snappyEncode
+func snappyDecodeLiterals(t *testing.T, src []byte) []byte {
+	t.Helper()
+	want, n := binary.Uvarint(src)
+	src = src[n:]
+	var out []byte
+	for len(src) > 0 {
+		tag := src[0]
+		if tag&3 != 0 {
+			t.Fatalf("Unexpected non-literal element tag %x", tag)
+		}
+		var l int
+		switch tag >> 2 {
+		case 60:
+			l, src = int(src[1]), src[2:]
+		case 61:
+			l, src = int(src[1])|int(src[2])<<8, src[3:]
+		default:
+			l, src = int(tag>>2), src[1:]
+		}
+		out = append(out, src[:l+1]...)
+		src = src[l+1:]
+	}
+	if uint64(len(out)) != want {
+		t.Fatalf("Decoded %d bytes, header says %d", len(out), want)
+	}
+	return out
+}
+
+func TestSnappyEncode_RoundTrip(t *testing.T) {
+	for _, size := range []int{0, 1, 59, 60, 255, 256, 70000} {
+		src := bytes.Repeat([]byte{'x'}, size)
+		if got := snappyDecodeLiterals(t, snappyEncode(src)); !bytes.Equal(got, src) {
+			t.Errorf("Round trip of %d bytes failed", size)
+		}
+	}
+}
+
+func TestPusher_Pushgateway(t *testing.T) {
+	var gotPath, gotMethod string
+	var gotBody []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath, gotMethod = r.URL.Path, r.Method
+		gotBody, _ = io.ReadAll(r.Body)
+	}))
+	defer server.Close()
+
+	reg := NewRegistry()
+	reg.NewCounterVec("push_total", "x").With().Add(7)
+
+	p, err := NewPusher(reg, PushConfig{Mode: PushGateway, URL: server.URL, Job: "lb", Instance: "node-1"})
+	if err != nil {
+		t.Fatalf("NewPusher() error = %v", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if gotMethod != http.MethodPut || gotPath != "/metrics/job/lb/instance/node-1" {
+		t.Errorf("Unexpected request %s %s", gotMethod, gotPath)
+	}
+	if !bytes.Contains(gotBody, []byte("push_total 7")) {
+		t.Errorf("Body missing sample: %s", gotBody)
+	}
+}
+
+func TestPusher_RemoteWrite(t *testing.T) {
+	var header http.Header
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	reg := NewRegistry()
+	reg.NewGaugeVec("rw_gauge", "x", "backend").With("a").Set(1.5)
+
+	p, err := NewPusher(reg, PushConfig{Mode: PushRemoteWrite, URL: server.URL, Interval: time.Second})
+	if err != nil {
+		t.Fatalf("NewPusher() error = %v", err)
+	}
+	if err := p.Push(context.Background()); err != nil {
+		t.Fatalf("Push() error = %v", err)
+	}
+
+	if header.Get("Content-Encoding") != "snappy" || header.Get("X-Prometheus-Remote-Write-Version") == "" {
+		t.Errorf("Missing remote-write headers: %v", header)
+	}
+	decoded := snappyDecodeLiterals(t, body)
+	for _, want := range [][]byte{[]byte("__name__"), []byte("rw_gauge"), []byte("backend"), []byte("go-balancer")} {
+		if !bytes.Contains(decoded, want) {
+			t.Errorf("WriteRequest missing %q", want)
+		}
+	}
+}
+
+func TestNewPusher_Validation(t *testing.T) {
+	reg := NewRegistry()
+	if p, err := NewPusher(reg, PushConfig{Mode: PushNone}); p != nil || err != nil {
+		t.Error("Mode none should return a nil pusher")
+	}
+	if _, err := NewPusher(reg, PushConfig{Mode: "carrier"}); err == nil {
+		t.Error("Unknown mode should be rejected")
+	}
+	if _, err := NewPusher(reg, PushConfig{Mode: PushGateway}); err == nil {
+		t.Error("Missing url should be rejected")
+	}
+	for _, name := range []string{"__name__", "job", "instance", "1zone", "zone-a", ""} {
+		cfg := PushConfig{Mode: PushRemoteWrite, URL: "http://prometheus:9090", Labels: map[string]string{name: "x"}}
+		if _, err := NewPusher(reg, cfg); err == nil {
+			t.Errorf("Label %q should be rejected", name)
+		}
+	}
+	if err := (PushConfig{Labels: map[string]string{"zone": "a", "_dc2": "b"}}).ValidateLabels(); err != nil {
+		t.Errorf("Expected valid labels, got %v", err)
+	}
+}
+
+func TestEncodeWriteRequest_OverridesDuplicateLabels(t *testing.T) {
+	samples := []Sample{{Name: "up", Labels: []Label{{Name: "instance", Value: "from-metric"}, {Name: "backend", Value: "a"}}, Value: 1}}
+	out := encodeWriteRequest(samples, []Label{{Name: "job", Value: "lb"}, {Name: "instance", Value: "lb-1"}}, time.Now())
+
+	if n := bytes.Count(out, []byte("instance")); n != 1 {
+		t.Errorf("Expected the instance label once, got %d times", n)
+	}
+	if !bytes.Contains(out, []byte("lb-1")) || bytes.Contains(out, []byte("from-metric")) {
+		t.Error("Expected the pusher's instance to override the metric's")
+	}
+	if !bytes.Contains(out, []byte("backend")) {
+		t.Error("Expected the other metric labels to be kept")
+	}
+}
@@ -0,0 +1,430 @@
+package metrics
+
+import (
+	"bufio"
+	"fmt"
+	"math"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/internal/sharded"
+)
+
+// Counter is a monotonically increasing value. Increments by one, the hot
+// path of request counters, go to a sharded integer count; fractional adds
+// to a float.
+type Counter struct {
+	ints sharded.Counter
+	bits uint64
+}
+
+// Inc adds one to the counter
+func (c *Counter) Inc() {
+	c.ints.Add(1)
+}
+
+// Add adds v (which must not be negative) to the counter
+func (c *Counter) Add(v float64) {
+	addFloat(&c.bits, v)
+}
+
+// Value returns the current counter value
+func (c *Counter) Value() float64 {
+	return float64(c.ints.Load()) + math.Float64frombits(atomic.LoadUint64(&c.bits))
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	bits uint64
+}
+
+// Inc adds one to the gauge
+func (g *Gauge) Inc() {
+	g.Add(1)
+}
+
+// Add adds v to the gauge
+func (g *Gauge) Add(v float64) {
+	addFloat(&g.bits, v)
+}
+
+// Set replaces the gauge value
+func (g *Gauge) Set(v float64) {
+	atomic.StoreUint64(&g.bits, math.Float64bits(v))
+}
+
+// Value returns the current gauge value
+func (g *Gauge) Value() float64 {
+	return math.Float64frombits(atomic.LoadUint64(&g.bits))
+}
+
+// addFloat atomically adds v to the float64 stored as bits
+func addFloat(bits *uint64, v float64) {
+	for {
+		old := atomic.LoadUint64(bits)
+		next := math.Float64bits(math.Float64frombits(old) + v)
+		if atomic.CompareAndSwapUint64(bits, old, next) {
+			return
+		}
+	}
+}
+
+// CounterVec is a family of counters partitioned by label values
+type CounterVec struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*counterSeries
+}
+
+type counterSeries struct {
+	values  []string
+	counter *Counter
+}
+
+// NewCounterVec registers a counter family; name should end in _total
+func (r *Registry) NewCounterVec(name, help string, labels ...string) *CounterVec {
+	v := &CounterVec{
+		desc:   desc{name: name, help: help, typ: "counter", labels: labels},
+		series: make(map[string]*counterSeries),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With returns the counter for the given label values, creating it if needed
+func (v *CounterVec) With(values ...string) *Counter {
+	key := v.key(values)
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.counter
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.counter
+	}
+	s = &counterSeries{values: append([]string(nil), values...), counter: &Counter{}}
+	v.series[key] = s
+	return s.counter
+}
+
+// Delete removes the series for the given label values
+func (v *CounterVec) Delete(values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.series, v.key(values))
+}
+
+// Reset removes all series
+func (v *CounterVec) Reset() {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	v.series = make(map[string]*counterSeries)
+}
+
+func (v *CounterVec) write(w *bufio.Writer, format Format) {
+	v.writeHeader(w, format)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, labelPairs(v.labels, s.values, "", ""), formatFloat(s.counter.Value()))
+	}
+}
+
+func (v *CounterVec) gather(emit func(Sample)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		emit(Sample{Name: v.name, Labels: makeLabels(v.labels, s.values), Value: s.counter.Value()})
+	}
+}
+
+// GaugeVec is a family of gauges partitioned by label values
+type GaugeVec struct {
+	desc
+	mu     sync.RWMutex
+	series map[string]*gaugeSeries
+}
+
+type gaugeSeries struct {
+	values []string
+	gauge  *Gauge
+}
+
+// NewGaugeVec registers a gauge family
+func (r *Registry) NewGaugeVec(name, help string, labels ...string) *GaugeVec {
+	v := &GaugeVec{
+		desc:   desc{name: name, help: help, typ: "gauge", labels: labels},
+		series: make(map[string]*gaugeSeries),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With returns the gauge for the given label values, creating it if needed
+func (v *GaugeVec) With(values ...string) *Gauge {
+	key := v.key(values)
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.gauge
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.gauge
+	}
+	s = &gaugeSeries{values: append([]string(nil), values...), gauge: &Gauge{}}
+	v.series[key] = s
+	return s.gauge
+}
+
+// Delete removes the series for the given label values
+func (v *GaugeVec) Delete(values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.series, v.key(values))
+}
+
+func (v *GaugeVec) write(w *bufio.Writer, format Format) {
+	v.writeHeader(w, format)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		fmt.Fprintf(w, "%s%s %s\n", v.name, labelPairs(v.labels, s.values, "", ""), formatFloat(s.gauge.Value()))
+	}
+}
+
+func (v *GaugeVec) gather(emit func(Sample)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		emit(Sample{Name: v.name, Labels: makeLabels(v.labels, s.values), Value: s.gauge.Value()})
+	}
+}
+
+// GaugeFunc computes gauge samples at scrape time
+type GaugeFunc struct {
+	desc
+	collect func(emit func(value float64, labelValues ...string))
+}
+
+// NewGaugeFunc registers a gauge family whose samples are produced by collect on every scrape
+func (r *Registry) NewGaugeFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) *GaugeFunc {
+	g := &GaugeFunc{
+		desc:    desc{name: name, help: help, typ: "gauge", labels: labels},
+		collect: collect,
+	}
+	r.register(name, g)
+	return g
+}
+
+func (g *GaugeFunc) write(w *bufio.Writer, format Format) {
+	g.writeHeader(w, format)
+	g.collect(func(value float64, labelValues ...string) {
+		g.key(labelValues)
+		fmt.Fprintf(w, "%s%s %s\n", g.name, labelPairs(g.labels, labelValues, "", ""), formatFloat(value))
+	})
+}
+
+func (g *GaugeFunc) gather(emit func(Sample)) {
+	g.collect(func(value float64, labelValues ...string) {
+		g.key(labelValues)
+		emit(Sample{Name: g.name, Labels: makeLabels(g.labels, labelValues), Value: value})
+	})
+}
+
+// CounterFunc computes counter samples at scrape time from totals kept
+// elsewhere
+type CounterFunc struct {
+	GaugeFunc
+}
+
+// NewCounterFunc registers a counter family whose samples are produced by collect on every scrape
+func (r *Registry) NewCounterFunc(name, help string, labels []string, collect func(emit func(value float64, labelValues ...string))) *CounterFunc {
+	c := &CounterFunc{GaugeFunc{
+		desc:    desc{name: name, help: help, typ: "counter", labels: labels},
+		collect: collect,
+	}}
+	r.register(name, c)
+	return c
+}
+
+// Exemplar links an observation to a trace
+type Exemplar struct {
+	TraceID string
+	Value   float64
+	Time    time.Time
+}
+
+// Histogram counts observations into buckets
+type Histogram struct {
+	mu        sync.Mutex
+	upper     []float64
+	counts    []uint64
+	exemplars []*Exemplar
+	sum       float64
+	count     uint64
+}
+
+// Observe records a value
+func (h *Histogram) Observe(v float64) {
+	h.ObserveWithExemplar(v, "")
+}
+
+// ObserveWithExemplar records a value and, when traceID is non-empty,
+// attaches it as the exemplar of the bucket the value falls into
+func (h *Histogram) ObserveWithExemplar(v float64, traceID string) {
+	idx := len(h.upper)
+	for i, u := range h.upper {
+		if v <= u {
+			idx = i
+			break
+		}
+	}
+
+	h.mu.Lock()
+	h.counts[idx]++
+	h.sum += v
+	h.count++
+	if traceID != "" {
+		h.exemplars[idx] = &Exemplar{TraceID: traceID, Value: v, Time: time.Now()}
+	}
+	h.mu.Unlock()
+}
+
+// HistogramVec is a family of histograms partitioned by label values
+type HistogramVec struct {
+	desc
+	buckets []float64
+	mu      sync.RWMutex
+	series  map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	values []string
+	hist   *Histogram
+}
+
+// NewHistogramVec registers a histogram family with the given upper bounds
+func (r *Registry) NewHistogramVec(name, help string, buckets []float64, labels ...string) *HistogramVec {
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	v := &HistogramVec{
+		desc:    desc{name: name, help: help, typ: "histogram", labels: labels},
+		buckets: append([]float64(nil), buckets...),
+		series:  make(map[string]*histogramSeries),
+	}
+	r.register(name, v)
+	return v
+}
+
+// With returns the histogram for the given label values, creating it if needed
+func (v *HistogramVec) With(values ...string) *Histogram {
+	key := v.key(values)
+	v.mu.RLock()
+	s, ok := v.series[key]
+	v.mu.RUnlock()
+	if ok {
+		return s.hist
+	}
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	if s, ok := v.series[key]; ok {
+		return s.hist
+	}
+	s = &histogramSeries{
+		values: append([]string(nil), values...),
+		hist: &Histogram{
+			upper:     v.buckets,
+			counts:    make([]uint64, len(v.buckets)+1),
+			exemplars: make([]*Exemplar, len(v.buckets)+1),
+		},
+	}
+	v.series[key] = s
+	return s.hist
+}
+
+// Delete removes the series for the given label values
+func (v *HistogramVec) Delete(values ...string) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+	delete(v.series, v.key(values))
+}
+
+func (v *HistogramVec) write(w *bufio.Writer, format Format) {
+	v.writeHeader(w, format)
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		h := s.hist
+
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		exemplars := append([]*Exemplar(nil), h.exemplars...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i := range counts {
+			cumulative += counts[i]
+			le := math.Inf(1)
+			if i < len(v.buckets) {
+				le = v.buckets[i]
+			}
+			fmt.Fprintf(w, "%s_bucket%s %d", v.name, labelPairs(v.labels, s.values, "le", formatFloat(le)), cumulative)
+			if format == FormatOpenMetrics && exemplars[i] != nil {
+				e := exemplars[i]
+				fmt.Fprintf(w, ` # {trace_id="%s"} %s %s`, escapeLabel(e.TraceID), formatFloat(e.Value), formatTimestamp(e.Time))
+			}
+			w.WriteByte('\n')
+		}
+		fmt.Fprintf(w, "%s_sum%s %s\n", v.name, labelPairs(v.labels, s.values, "", ""), formatFloat(sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", v.name, labelPairs(v.labels, s.values, "", ""), count)
+	}
+}
+
+func (v *HistogramVec) gather(emit func(Sample)) {
+	v.mu.RLock()
+	defer v.mu.RUnlock()
+
+	for _, k := range sortedKeys(v.series) {
+		s := v.series[k]
+		h := s.hist
+
+		h.mu.Lock()
+		counts := append([]uint64(nil), h.counts...)
+		sum, count := h.sum, h.count
+		h.mu.Unlock()
+
+		var cumulative uint64
+		for i := range counts {
+			cumulative += counts[i]
+			le := math.Inf(1)
+			if i < len(v.buckets) {
+				le = v.buckets[i]
+			}
+			emit(Sample{
+				Name:   v.name + "_bucket",
+				Labels: makeLabels(v.labels, s.values, Label{Name: "le", Value: formatFloat(le)}),
+				Value:  float64(cumulative),
+			})
+		}
+		emit(Sample{Name: v.name + "_sum", Labels: makeLabels(v.labels, s.values), Value: sum})
+		emit(Sample{Name: v.name + "_count", Labels: makeLabels(v.labels, s.values), Value: float64(count)})
+	}
+}
@@ -1,12 +1,19 @@
 package middleware
 
 import (
-	"log"
+	"crypto/subtle"
 	"net/http"
+	"strings"
 	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
 )
 
-// Logger logs HTTP requests
+// RequestIDHeader carries the request ID between clients, the balancer and backends
+const RequestIDHeader = logging.RequestIDHeader
+
+// Logger logs HTTP requests through logging.Logger(), with the request ID
+// when RequestID runs first
 func Logger(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		start := time.Now()
@@ -16,17 +23,45 @@ func Logger(next http.Handler) http.Handler {
 
 		next.ServeHTTP(rw, r)
 
-		duration := time.Since(start)
-		log.Printf("[%s] %s %s - %d - %v",
-			r.Method,
-			r.RemoteAddr,
-			r.URL.Path,
-			rw.statusCode,
-			duration,
+		logging.Logger().InfoContext(r.Context(), "request",
+			"method", r.Method,
+			"remote", r.RemoteAddr,
+			"path", r.URL.Path,
+			"status", rw.statusCode,
+			"duration", time.Since(start),
 		)
 	})
 }
 
+// RequestID reuses the incoming X-Request-ID (or generates one), stores it in
+// the request context for log correlation, forwards it to the backend and
+// echoes it in the response, error responses included
+func RequestID(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		id := r.Header.Get(RequestIDHeader)
+		if !validRequestID(id) {
+			id = logging.NewRequestID()
+			r.Header.Set(RequestIDHeader, id)
+		}
+		w.Header().Set(RequestIDHeader, id)
+		next.ServeHTTP(w, r.WithContext(logging.WithRequestID(r.Context(), id)))
+	})
+}
+
+// validRequestID accepts incoming IDs of up to 128 printable ASCII
+// characters, so they can't garble log lines
+func validRequestID(id string) bool {
+	if id == "" || len(id) > 128 {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		if id[i] <= ' ' || id[i] > '~' {
+			return false
+		}
+	}
+	return true
+}
+
 // responseWriter wraps http.ResponseWriter to capture status code
 type responseWriter struct {
 	http.ResponseWriter
@@ -38,12 +73,29 @@ func (rw *responseWriter) WriteHeader(code int) {
 	rw.ResponseWriter.WriteHeader(code)
 }
 
-// Recovery recovers from panics and returns 500
+// Flush passes flushes on so streamed responses reach the client as they
+// are proxied
+func (rw *responseWriter) Flush() {
+	if f, ok := rw.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+// Unwrap exposes the underlying writer to http.ResponseController
+func (rw *responseWriter) Unwrap() http.ResponseWriter {
+	return rw.ResponseWriter
+}
+
+// Recovery recovers from panics and returns 500; http.ErrAbortHandler is
+// passed on so the server drops the connection as intended
 func Recovery(next http.Handler) http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
 		defer func() {
 			if err := recover(); err != nil {
-				log.Printf("Panic recovered: %v", err)
+				if err == http.ErrAbortHandler {
+					panic(err)
+				}
+				logging.Logger().ErrorContext(r.Context(), "panic recovered", "error", err)
 				http.Error(w, "Internal Server Error", http.StatusInternalServerError)
 			}
 		}()
@@ -79,6 +131,21 @@ func RateLimiter(requestsPerSecond int) func(http.Handler) http.Handler {
 	}
 }
 
+// TokenAuth requires requests to carry "Authorization: Bearer <token>"
+func TokenAuth(token string) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			provided := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+			if token == "" || subtle.ConstantTimeCompare([]byte(provided), []byte(token)) != 1 {
+				w.Header().Set("WWW-Authenticate", `Bearer realm="go-balancer"`)
+				http.Error(w, "Unauthorized", http.StatusUnauthorized)
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
 // Chain chains multiple middleware
 func Chain(h http.Handler, middleware ...func(http.Handler) http.Handler) http.Handler {
 	for i := len(middleware) - 1; i >= 0; i-- {
@@ -0,0 +1,303 @@
+// Package mockbackend implements a configurable test backend for demos and
+// strategy experiments: it answers with a JSON body of a chosen size after
+// a simulated latency, and fails a chosen share of requests. For soak
+// testing it can also reset connections, send malformed responses and flap
+// its health.
+package mockbackend
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// SlowDelay is how long /slow waits before answering
+const SlowDelay = 2 * time.Second
+
+// Options configures a mock backend
+type Options struct {
+	Name string
+	Port int
+	// Latency is added to every response, varied by up to ±Jitter
+	Latency time.Duration
+	Jitter  time.Duration
+	// ErrorRate is the share of requests (0-1) answered with ErrorStatus
+	ErrorRate   float64
+	ErrorStatus int
+	// ResponseSize pads the response body to at least this many bytes
+	ResponseSize int
+	// Quiet disables the per-request log line
+	Quiet bool
+
+	// ResetRate is the share of requests (0-1) whose connection is reset
+	// instead of answered
+	ResetRate float64
+	// MalformedRate is the share of requests (0-1) answered with a broken
+	// HTTP response
+	MalformedRate float64
+	// FlapInterval alternates /health between healthy and 503 every
+	// interval (0 = always healthy)
+	FlapInterval time.Duration
+}
+
+// Validate checks the option ranges
+func (o Options) Validate() error {
+	switch {
+	case o.Latency < 0:
+		return fmt.Errorf("latency must not be negative")
+	case o.Jitter < 0:
+		return fmt.Errorf("jitter must not be negative")
+	case o.ErrorRate < 0 || o.ErrorRate > 1:
+		return fmt.Errorf("error rate %v is out of range (0-1)", o.ErrorRate)
+	case o.ErrorStatus != 0 && (o.ErrorStatus < 400 || o.ErrorStatus > 599):
+		return fmt.Errorf("error status %d must be 4xx or 5xx", o.ErrorStatus)
+	case o.ResponseSize < 0:
+		return fmt.Errorf("response size must not be negative")
+	case o.ResetRate < 0 || o.ResetRate > 1:
+		return fmt.Errorf("reset rate %v is out of range (0-1)", o.ResetRate)
+	case o.MalformedRate < 0 || o.MalformedRate > 1:
+		return fmt.Errorf("malformed rate %v is out of range (0-1)", o.MalformedRate)
+	case o.FlapInterval < 0:
+		return fmt.Errorf("flap interval must not be negative")
+	}
+	return nil
+}
+
+// Server is a mock backend
+type Server struct {
+	opts     Options
+	mux      *http.ServeMux
+	requests atomic.Int64
+	errors   atomic.Int64
+	// delay returns the simulated latency of one request
+	delay func() time.Duration
+	// fail decides whether a request gets the error status
+	fail func() bool
+}
+
+// New creates a mock backend
+func New(opts Options) (*Server, error) {
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.Name == "" {
+		opts.Name = fmt.Sprintf("Backend-%d", opts.Port)
+	}
+	if opts.ErrorStatus == 0 {
+		opts.ErrorStatus = http.StatusInternalServerError
+	}
+
+	s := &Server{opts: opts, mux: http.NewServeMux()}
+	s.delay = func() time.Duration {
+		d := opts.Latency
+		if opts.Jitter > 0 {
+			d += time.Duration(rand.Int63n(int64(2*opts.Jitter)+1)) - opts.Jitter
+		}
+		return max(d, 0)
+	}
+	s.fail = func() bool {
+		return opts.ErrorRate > 0 && rand.Float64() < opts.ErrorRate
+	}
+
+	s.mux.HandleFunc("/", s.handleRoot)
+	s.mux.HandleFunc("/health", s.handleHealth)
+	s.mux.HandleFunc("/status", s.handleStatus)
+	s.mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		if !sleep(r, SlowDelay) {
+			return
+		}
+		s.respond(w, r, http.StatusOK)
+	})
+	s.mux.HandleFunc("/error", func(w http.ResponseWriter, r *http.Request) {
+		s.respond(w, r, http.StatusInternalServerError)
+	})
+	return s, nil
+}
+
+// Options returns the effective options
+func (s *Server) Options() Options {
+	return s.opts
+}
+
+// ServeHTTP implements http.Handler
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.mux.ServeHTTP(w, r)
+}
+
+func (s *Server) handleRoot(w http.ResponseWriter, r *http.Request) {
+	if !sleep(r, s.delay()) {
+		return
+	}
+	if s.opts.ResetRate > 0 && rand.Float64() < s.opts.ResetRate {
+		s.hijack(w, func(conn net.Conn) {
+			// Without lingering the close sends a TCP reset
+			if tcp, ok := conn.(*net.TCPConn); ok {
+				tcp.SetLinger(0)
+			}
+		})
+		return
+	}
+	if s.opts.MalformedRate > 0 && rand.Float64() < s.opts.MalformedRate {
+		s.hijack(w, func(conn net.Conn) {
+			fmt.Fprintf(conn, "HTTP/1.1 2OO OK\r\nContent-Length: 64\r\n\r\ntruncated")
+		})
+		return
+	}
+	status := http.StatusOK
+	if s.fail() {
+		status = s.opts.ErrorStatus
+	}
+	s.respond(w, r, status)
+}
+
+// hijack takes over the connection, lets misbehave write to it, and closes
+// it; it counts as an error
+func (s *Server) hijack(w http.ResponseWriter, misbehave func(conn net.Conn)) {
+	s.requests.Add(1)
+	s.errors.Add(1)
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		// Not served over a connection, e.g. in tests
+		w.WriteHeader(http.StatusBadGateway)
+		return
+	}
+	conn, _, err := hj.Hijack()
+	if err != nil {
+		return
+	}
+	misbehave(conn)
+	conn.Close()
+}
+
+// handleHealth never simulates latency or errors, so injected failures
+// exercise passive rather than active health checking; only FlapInterval
+// fails it
+func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
+	if s.flapping() {
+		writeJSON(w, s.opts.Name, http.StatusServiceUnavailable, map[string]interface{}{
+			"status": "flapping",
+			"server": s.opts.Name,
+		})
+		return
+	}
+	writeJSON(w, s.opts.Name, http.StatusOK, map[string]interface{}{
+		"status":    "healthy",
+		"server":    s.opts.Name,
+		"port":      s.opts.Port,
+		"timestamp": time.Now().Format(time.RFC3339),
+	})
+}
+
+// flapping reports whether /health is in the failing half of its period
+func (s *Server) flapping() bool {
+	return s.opts.FlapInterval > 0 && time.Now().UnixNano()/int64(s.opts.FlapInterval)%2 == 1
+}
+
+func (s *Server) handleStatus(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, s.opts.Name, http.StatusOK, map[string]interface{}{
+		"status":    "ok",
+		"server":    s.opts.Name,
+		"port":      s.opts.Port,
+		"requests":  s.requests.Load(),
+		"errors":    s.errors.Load(),
+		"latency":   s.opts.Latency.String(),
+		"jitter":    s.opts.Jitter.String(),
+		"errorRate": s.opts.ErrorRate,
+	})
+}
+
+// respond writes the request echo, padded to ResponseSize
+func (s *Server) respond(w http.ResponseWriter, r *http.Request, status int) {
+	s.requests.Add(1)
+	if status >= http.StatusBadRequest {
+		s.errors.Add(1)
+	}
+	if !s.opts.Quiet {
+		logging.Logger().InfoContext(r.Context(), "request",
+			"server", s.opts.Name,
+			"method", r.Method,
+			"path", r.URL.Path,
+			"remote", r.RemoteAddr,
+			"status", status,
+		)
+	}
+
+	body := map[string]interface{}{
+		"server":      s.opts.Name,
+		"port":        s.opts.Port,
+		"timestamp":   time.Now().Format(time.RFC3339),
+		"path":        r.URL.Path,
+		"method":      r.Method,
+		"remote_addr": r.RemoteAddr,
+		"status":      status,
+	}
+	if s.opts.ResponseSize > 0 {
+		base, _ := json.Marshal(body)
+		// `,"padding":""` adds 13 bytes around the padding itself
+		if pad := s.opts.ResponseSize - len(base) - 13; pad > 0 {
+			body["padding"] = strings.Repeat("x", pad)
+		}
+	}
+	writeJSON(w, s.opts.Name, status, body)
+}
+
+func writeJSON(w http.ResponseWriter, name string, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("X-Backend-Server", name)
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+// sleep waits for d, returning false if the client went away first
+func sleep(r *http.Request, d time.Duration) bool {
+	if d <= 0 {
+		return true
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-r.Context().Done():
+		return false
+	}
+}
+
+// ListenAndServe serves the mock backend on its port until the server fails
+func (s *Server) ListenAndServe() error {
+	attrs := []any{
+		"name", s.opts.Name,
+		"port", s.opts.Port,
+		"latency", s.opts.Latency,
+		"jitter", s.opts.Jitter,
+		"errorRate", s.opts.ErrorRate,
+		"errorStatus", s.opts.ErrorStatus,
+	}
+	if s.opts.ResetRate > 0 || s.opts.MalformedRate > 0 {
+		attrs = append(attrs, "resetRate", s.opts.ResetRate, "malformedRate", s.opts.MalformedRate)
+	}
+	if s.opts.FlapInterval > 0 {
+		attrs = append(attrs, "flapInterval", s.opts.FlapInterval)
+	}
+	if s.opts.ResponseSize > 0 {
+		attrs = append(attrs, "responseSize", s.opts.ResponseSize)
+	}
+	attrs = append(attrs, "health", fmt.Sprintf("http://localhost:%d/health", s.opts.Port))
+	logging.Logger().Info("starting mock backend", attrs...)
+
+	server := &http.Server{
+		Addr:         fmt.Sprintf(":%d", s.opts.Port),
+		Handler:      s,
+		ReadTimeout:  15 * time.Second,
+		WriteTimeout: 15*time.Second + s.opts.Latency + s.opts.Jitter,
+		IdleTimeout:  60 * time.Second,
+	}
+	return server.ListenAndServe()
+}
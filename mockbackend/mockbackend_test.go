@@ -0,0 +1,138 @@
+package mockbackend
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    Options
+		wantErr bool
+	}{
+		{"defaults", Options{}, false},
+		{"full", Options{Latency: time.Millisecond, Jitter: time.Millisecond, ErrorRate: 0.5, ErrorStatus: 503, ResponseSize: 1024}, false},
+		{"negative latency", Options{Latency: -1}, true},
+		{"negative jitter", Options{Jitter: -1}, true},
+		{"error rate", Options{ErrorRate: 1.5}, true},
+		{"error status", Options{ErrorStatus: 200}, true},
+		{"negative size", Options{ResponseSize: -1}, true},
+		{"chaos", Options{ResetRate: 0.1, MalformedRate: 0.1, FlapInterval: time.Second}, false},
+		{"reset rate", Options{ResetRate: 2}, true},
+		{"malformed rate", Options{MalformedRate: -0.1}, true},
+		{"negative flap interval", Options{FlapInterval: -1}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.opts.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Expected error %v, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func serve(s *Server, path string) *httptest.ResponseRecorder {
+	rec := httptest.NewRecorder()
+	s.ServeHTTP(rec, httptest.NewRequest("GET", path, nil))
+	return rec
+}
+
+func TestServer(t *testing.T) {
+	s, err := New(Options{Port: 8081, ErrorRate: 1, ErrorStatus: 503, ResponseSize: 2048, Quiet: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+
+	rec := serve(s, "/")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status 503, got %d", rec.Code)
+	}
+	if got := rec.Body.Len(); got < 2048 || got > 2049 {
+		t.Errorf("Expected a body of 2048 bytes, got %d", got)
+	}
+	if got := rec.Header().Get("X-Backend-Server"); got != "Backend-8081" {
+		t.Errorf("Expected X-Backend-Server Backend-8081, got %s", got)
+	}
+
+	// Health probes are never failed
+	if rec := serve(s, "/health"); rec.Code != http.StatusOK {
+		t.Errorf("Expected healthy /health, got %d", rec.Code)
+	}
+	if rec := serve(s, "/error"); rec.Code != http.StatusInternalServerError {
+		t.Errorf("Expected status 500 from /error, got %d", rec.Code)
+	}
+	if s.requests.Load() != 2 || s.errors.Load() != 2 {
+		t.Errorf("Expected 2 requests and 2 errors, got %d and %d", s.requests.Load(), s.errors.Load())
+	}
+}
+
+func TestServer_Latency(t *testing.T) {
+	s, err := New(Options{Latency: 20 * time.Millisecond, Jitter: 10 * time.Millisecond, Quiet: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	for i := 0; i < 100; i++ {
+		if d := s.delay(); d < 10*time.Millisecond || d > 30*time.Millisecond {
+			t.Fatalf("Expected latency within 20ms±10ms, got %v", d)
+		}
+	}
+
+	start := time.Now()
+	if rec := serve(s, "/"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if elapsed := time.Since(start); elapsed < 10*time.Millisecond {
+		t.Errorf("Expected the response to be delayed, took %v", elapsed)
+	}
+}
+
+func TestServer_Chaos(t *testing.T) {
+	tests := []struct {
+		name string
+		opts Options
+	}{
+		{"reset", Options{ResetRate: 1, Quiet: true}},
+		{"malformed", Options{MalformedRate: 1, Quiet: true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s, err := New(tt.opts)
+			if err != nil {
+				t.Fatalf("Failed to create server: %v", err)
+			}
+			server := httptest.NewServer(s)
+			defer server.Close()
+
+			resp, err := http.Get(server.URL + "/")
+			if err == nil {
+				resp.Body.Close()
+				t.Fatalf("Expected a broken response, got status %d", resp.StatusCode)
+			}
+			if s.errors.Load() != 1 {
+				t.Errorf("Expected the request to count as an error, got %d", s.errors.Load())
+			}
+			// Health probes stay unaffected
+			if rec := serve(s, "/health"); rec.Code != http.StatusOK {
+				t.Errorf("Expected healthy /health, got %d", rec.Code)
+			}
+		})
+	}
+}
+
+func TestServer_Flapping(t *testing.T) {
+	s, err := New(Options{FlapInterval: 20 * time.Millisecond, Quiet: true})
+	if err != nil {
+		t.Fatalf("Failed to create server: %v", err)
+	}
+	seen := make(map[int]bool)
+	for i := 0; i < 10; i++ {
+		seen[serve(s, "/health").Code] = true
+		time.Sleep(10 * time.Millisecond)
+	}
+	if !seen[http.StatusOK] || !seen[http.StatusServiceUnavailable] {
+		t.Errorf("Expected /health to alternate between 200 and 503, got %v", seen)
+	}
+}
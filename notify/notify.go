@@ -0,0 +1,331 @@
+// Package notify posts backend health changes to webhooks (generic JSON,
+// Slack incoming webhooks or PagerDuty Events v2), so operators are alerted
+// without scraping logs.
+package notify
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Webhook body formats
+const (
+	FormatJSON      = "json"
+	FormatSlack     = "slack"
+	FormatPagerDuty = "pagerduty"
+)
+
+// Defaults of the webhooks
+const (
+	DefaultTimeout = 5 * time.Second
+	// PagerDutyURL is the Events v2 endpoint used when a pagerduty webhook
+	// has no URL
+	PagerDutyURL = "https://events.pagerduty.com/v2/enqueue"
+)
+
+// Delivery settings: events wait in a bounded queue and each webhook is
+// tried a few times with growing pauses. Close gives the queued events
+// drainTimeout to go out before abandoning them.
+const (
+	queueSize    = 256
+	maxAttempts  = 3
+	retryBackoff = time.Second
+	drainTimeout = 10 * time.Second
+)
+
+// Config describes one webhook
+type Config struct {
+	// URL receives the POST (PagerDutyURL by default for pagerduty)
+	URL string `json:"url,omitempty"`
+	// Format of the body: json (default), slack or pagerduty
+	Format string `json:"format,omitempty"`
+	// Headers are sent with every notification, e.g. an API token
+	Headers map[string]string `json:"headers,omitempty"`
+	// RoutingKey is the PagerDuty integration key (pagerduty only)
+	RoutingKey string `json:"routingKey,omitempty"`
+	// Timeout bounds each attempt (0 = DefaultTimeout)
+	Timeout time.Duration `json:"timeout,omitempty"`
+}
+
+// Validate checks the URL, format and PagerDuty routing key
+func (c Config) Validate() error {
+	switch strings.ToLower(c.Format) {
+	case "", FormatJSON, FormatSlack:
+		if c.URL == "" {
+			return fmt.Errorf("url is required")
+		}
+	case FormatPagerDuty:
+		if c.RoutingKey == "" {
+			return fmt.Errorf("routingKey is required for the pagerduty format")
+		}
+	default:
+		return fmt.Errorf("format %q is unknown (valid: json, slack, pagerduty)", c.Format)
+	}
+	if c.URL != "" {
+		u, err := url.Parse(c.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("url %q must be an http(s) URL", c.URL)
+		}
+	}
+	if c.Timeout < 0 {
+		return fmt.Errorf("timeout must not be negative")
+	}
+	return nil
+}
+
+// Event is a backend going down or recovering
+type Event struct {
+	// Pool names the load balancer the backend belongs to
+	Pool    string    `json:"pool"`
+	Backend string    `json:"backend"`
+	Alive   bool      `json:"alive"`
+	Time    time.Time `json:"time"`
+	// Host is the machine running the balancer
+	Host string `json:"host,omitempty"`
+}
+
+// State returns "up" or "down"
+func (e Event) State() string {
+	if e.Alive {
+		return "up"
+	}
+	return "down"
+}
+
+// Notifier delivers events to its webhooks in the background
+type Notifier struct {
+	webhooks []Config
+	client   *http.Client
+	host     string
+	events   chan Event
+	stop     chan struct{}
+	done     chan struct{}
+	close    sync.Once
+	dropped  atomic.Int64
+
+	// ctx aborts deliveries still running when the drain times out
+	ctx          context.Context
+	abort        context.CancelFunc
+	drainTimeout time.Duration
+}
+
+// New starts a notifier for the webhooks; it returns nil without any
+func New(webhooks []Config) (*Notifier, error) {
+	if len(webhooks) == 0 {
+		return nil, nil
+	}
+	for i, w := range webhooks {
+		if err := w.Validate(); err != nil {
+			return nil, fmt.Errorf("webhook %d: %w", i, err)
+		}
+	}
+	host, err := os.Hostname()
+	if err != nil {
+		host = "go-balancer"
+	}
+	ctx, abort := context.WithCancel(context.Background())
+	n := &Notifier{
+		webhooks:     webhooks,
+		client:       &http.Client{},
+		host:         host,
+		events:       make(chan Event, queueSize),
+		stop:         make(chan struct{}),
+		done:         make(chan struct{}),
+		ctx:          ctx,
+		abort:        abort,
+		drainTimeout: drainTimeout,
+	}
+	go n.run()
+	return n, nil
+}
+
+// BackendStateChanged returns a callback for
+// balancer.LoadBalancer.OnBackendStateChange reporting the backends of pool
+func (n *Notifier) BackendStateChanged(pool string) func(b *backend.Backend, alive bool) {
+	return func(b *backend.Backend, alive bool) {
+		n.Notify(Event{Pool: pool, Backend: b.String(), Alive: alive})
+	}
+}
+
+// Notify queues e for delivery without blocking; it is dropped when the
+// queue is full or the notifier is closed
+func (n *Notifier) Notify(e Event) {
+	select {
+	case <-n.stop:
+		return
+	default:
+	}
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+	if e.Host == "" {
+		e.Host = n.host
+	}
+	select {
+	case n.events <- e:
+	default:
+		n.dropped.Add(1)
+		logging.Logger().Warn("notification dropped: queue full", "backend", e.Backend, "state", e.State())
+	}
+}
+
+// Dropped returns the number of events dropped because the queue was full
+func (n *Notifier) Dropped() int64 {
+	return n.dropped.Load()
+}
+
+// Close delivers the queued events and stops the notifier; deliveries still
+// running after drainTimeout are abandoned
+func (n *Notifier) Close() error {
+	if n == nil {
+		return nil
+	}
+	n.close.Do(func() {
+		close(n.stop)
+		timer := time.NewTimer(n.drainTimeout)
+		defer timer.Stop()
+		select {
+		case <-n.done:
+		case <-timer.C:
+			logging.Logger().Warn("abandoning undelivered notifications", "queued", len(n.events))
+			n.abort()
+			<-n.done
+		}
+		n.abort()
+	})
+	return nil
+}
+
+// run delivers events until the notifier is closed, then the ones still
+// queued; the events channel is never closed so a late Notify can't panic
+func (n *Notifier) run() {
+	defer close(n.done)
+	for {
+		select {
+		case e := <-n.events:
+			n.send(e)
+		case <-n.stop:
+			for n.ctx.Err() == nil {
+				select {
+				case e := <-n.events:
+					n.send(e)
+				default:
+					return
+				}
+			}
+			return
+		}
+	}
+}
+
+// send delivers e to every webhook
+func (n *Notifier) send(e Event) {
+	for _, w := range n.webhooks {
+		n.deliver(w, e)
+	}
+}
+
+// deliver posts e to w, retrying failed attempts
+func (n *Notifier) deliver(w Config, e Event) {
+	body, err := encode(w, e)
+	if err != nil {
+		logging.Logger().Error("failed to encode notification", "error", err)
+		return
+	}
+	target := w.URL
+	if target == "" {
+		target = PagerDutyURL
+	}
+	timeout := w.Timeout
+	if timeout == 0 {
+		timeout = DefaultTimeout
+	}
+
+	for attempt := 1; ; attempt++ {
+		err = n.post(target, w.Headers, body, timeout)
+		if err == nil {
+			return
+		}
+		if attempt == maxAttempts || n.ctx.Err() != nil {
+			break
+		}
+		select {
+		case <-time.After(retryBackoff * time.Duration(attempt)):
+		case <-n.ctx.Done():
+		}
+	}
+	logging.Logger().Warn("failed to deliver notification",
+		"url", target, "backend", e.Backend, "state", e.State(), "error", err)
+}
+
+func (n *Notifier) post(target string, headers map[string]string, body []byte, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(n.ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, target, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("webhook answered %s", resp.Status)
+	}
+	return nil
+}
+
+// encode builds the body of e in the format of w
+func encode(w Config, e Event) ([]byte, error) {
+	summary := fmt.Sprintf("Backend %s of pool %s is %s", e.Backend, e.Pool, strings.ToUpper(e.State()))
+	switch strings.ToLower(w.Format) {
+	case FormatSlack:
+		icon := ":red_circle:"
+		if e.Alive {
+			icon = ":large_green_circle:"
+		}
+		return json.Marshal(map[string]string{"text": icon + " " + summary})
+	case FormatPagerDuty:
+		// Recoveries resolve the incident their outage triggered
+		event := map[string]any{
+			"routing_key":  w.RoutingKey,
+			"dedup_key":    "go-balancer/" + e.Pool + "/" + e.Backend,
+			"event_action": "trigger",
+		}
+		if e.Alive {
+			event["event_action"] = "resolve"
+		} else {
+			event["payload"] = map[string]any{
+				"summary":   summary,
+				"source":    e.Host,
+				"severity":  "critical",
+				"component": e.Backend,
+				"group":     e.Pool,
+				"timestamp": e.Time.UTC().Format(time.RFC3339),
+			}
+		}
+		return json.Marshal(event)
+	default:
+		return json.Marshal(struct {
+			Event
+			State string `json:"state"`
+		}{e, e.State()})
+	}
+}
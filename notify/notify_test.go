@@ -0,0 +1,150 @@
+package notify
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// recorder collects the bodies posted to it, failing the first fail posts
+type recorder struct {
+	mu     sync.Mutex
+	fail   int
+	bodies []map[string]any
+	auth   string
+}
+
+func (rec *recorder) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	if rec.fail > 0 {
+		rec.fail--
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var body map[string]any
+	json.NewDecoder(r.Body).Decode(&body)
+	rec.bodies = append(rec.bodies, body)
+	rec.auth = r.Header.Get("Authorization")
+}
+
+func TestNotifier_JSONAndSlack(t *testing.T) {
+	hook, chat := &recorder{}, &recorder{}
+	hookServer, chatServer := httptest.NewServer(hook), httptest.NewServer(chat)
+	defer hookServer.Close()
+	defer chatServer.Close()
+
+	n, err := New([]Config{
+		{URL: hookServer.URL, Headers: map[string]string{"Authorization": "Bearer t"}},
+		{URL: chatServer.URL, Format: FormatSlack},
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	b, _ := backend.NewBackend("http://localhost:9001")
+	changed := n.BackendStateChanged("api")
+	changed(b, false)
+	changed(b, true)
+	n.Close()
+
+	if len(hook.bodies) != 2 || len(chat.bodies) != 2 {
+		t.Fatalf("Expected 2 notifications per webhook, got %d and %d", len(hook.bodies), len(chat.bodies))
+	}
+	if hook.auth != "Bearer t" {
+		t.Errorf("Expected the configured headers, got Authorization %q", hook.auth)
+	}
+	down := hook.bodies[0]
+	if down["pool"] != "api" || down["backend"] != b.String() || down["state"] != "down" || down["alive"] != false {
+		t.Errorf("Unexpected JSON body %v", down)
+	}
+	if hook.bodies[1]["state"] != "up" {
+		t.Errorf("Expected the recovery second, got %v", hook.bodies[1])
+	}
+	if text, _ := chat.bodies[0]["text"].(string); !strings.Contains(text, "is DOWN") {
+		t.Errorf("Unexpected Slack text %q", text)
+	}
+}
+
+func TestNotifier_PagerDuty(t *testing.T) {
+	rec := &recorder{fail: 1}
+	server := httptest.NewServer(rec)
+	defer server.Close()
+
+	n, err := New([]Config{{URL: server.URL, Format: FormatPagerDuty, RoutingKey: "key"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	n.Notify(Event{Pool: "api", Backend: "http://localhost:9001", Alive: false})
+	n.Notify(Event{Pool: "api", Backend: "http://localhost:9001", Alive: true})
+	n.Close()
+
+	// The refused first attempt is retried
+	if len(rec.bodies) != 2 {
+		t.Fatalf("Expected 2 events, got %d", len(rec.bodies))
+	}
+	trigger, resolve := rec.bodies[0], rec.bodies[1]
+	if trigger["event_action"] != "trigger" || trigger["routing_key"] != "key" || trigger["payload"] == nil {
+		t.Errorf("Unexpected trigger %v", trigger)
+	}
+	if resolve["event_action"] != "resolve" || resolve["dedup_key"] != trigger["dedup_key"] {
+		t.Errorf("Expected the recovery to resolve the incident, got %v", resolve)
+	}
+}
+
+func TestNotifier_Close(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	n, err := New([]Config{{URL: server.URL, Timeout: time.Minute}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	n.drainTimeout = 50 * time.Millisecond
+	n.Notify(Event{Pool: "api", Backend: "http://localhost:9001"})
+
+	start := time.Now()
+	n.Close()
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("Expected Close to give up on the hanging webhook, took %v", elapsed)
+	}
+	// A state change racing shutdown is dropped, not a panic
+	n.Notify(Event{Pool: "api", Backend: "http://localhost:9001", Alive: true})
+	n.Close()
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name string
+		c    Config
+		want string
+	}{
+		{"url missing", Config{}, "url is required"},
+		{"bad url", Config{URL: "hooks.example.com"}, "must be an http(s) URL"},
+		{"unknown format", Config{URL: "https://hooks.example.com", Format: "teams"}, `format "teams" is unknown`},
+		{"routing key missing", Config{Format: FormatPagerDuty}, "routingKey is required"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.c.Validate()
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Validate() = %v, want %q", err, tt.want)
+			}
+		})
+	}
+	if err := (Config{Format: FormatPagerDuty, RoutingKey: "key"}).Validate(); err != nil {
+		t.Errorf("Expected PagerDuty without URL to be valid, got %v", err)
+	}
+	if n, err := New(nil); n != nil || err != nil {
+		t.Errorf("Expected no notifier without webhooks, got %v, %v", n, err)
+	}
+}
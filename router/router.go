@@ -0,0 +1,322 @@
+// Package router sends each request to the handler of the first route
+// matching its host, path, method, headers and query parameters, so one
+// listener can serve several pools, e.g. a canary pool for beta users: the
+// load balancers of the pools sit behind the router, which strips or
+// rewrites the matched path prefix on the way.
+package router
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/middleware"
+)
+
+// Route sends the requests matching all of its conditions to Handler
+type Route struct {
+	Name string
+	// Host is an exact host or a "*.example.com" wildcard matching any
+	// subdomain (empty = any host)
+	Host string
+	// PathPrefix is matched against the start of the path; "/api/" also
+	// matches "/api" (empty = any path)
+	PathPrefix string
+	// Methods restricts the route to these request methods (empty = any)
+	Methods []string
+	// Headers and Query must all match the request headers and query
+	// parameters
+	Headers []ValueMatch
+	Query   []ValueMatch
+	// StripPrefix removes PathPrefix from the forwarded path
+	StripPrefix bool
+	// Rewrite replaces PathPrefix in the forwarded path, e.g. "/api/" to
+	// "/v2/"
+	Rewrite string
+	// SetHeaders are set on the forwarded request
+	SetHeaders map[string]string
+	// Timeout bounds the whole request (0 = none)
+	Timeout time.Duration
+	// ResponseTimeout bounds the wait for the backend's response headers
+	// and IdleTimeout the pauses while its body is read (0 = the backend's)
+	ResponseTimeout time.Duration
+	IdleTimeout     time.Duration
+	// CORS overrides the global CORS setting for the route (nil inherits
+	// it); see Router.CORS
+	CORS *bool
+	// Handler serves the matching requests, typically a load balancer
+	Handler http.Handler
+}
+
+// ValueMatch matches a request header or query parameter by name: its
+// value must equal Value or match the regular expression Regex, or with
+// neither set the header or parameter must be present
+type ValueMatch struct {
+	Name  string `json:"name"`
+	Value string `json:"value,omitempty"`
+	Regex string `json:"regex,omitempty"`
+}
+
+// Validate checks the name and compiles the regular expression
+func (m ValueMatch) Validate() error {
+	switch {
+	case m.Name == "":
+		return fmt.Errorf("name is empty")
+	case m.Value != "" && m.Regex != "":
+		return fmt.Errorf("%s: value and regex are exclusive", m.Name)
+	}
+	if m.Regex != "" {
+		if _, err := regexp.Compile(m.Regex); err != nil {
+			return fmt.Errorf("%s: %w", m.Name, err)
+		}
+	}
+	return nil
+}
+
+// valueMatcher is a ValueMatch with its regular expression compiled
+type valueMatcher struct {
+	ValueMatch
+	re *regexp.Regexp
+}
+
+// matches reports whether one of the values passes
+func (m valueMatcher) matches(values []string) bool {
+	for _, v := range values {
+		switch {
+		case m.re != nil:
+			if m.re.MatchString(v) {
+				return true
+			}
+		case m.Value != "":
+			if v == m.Value {
+				return true
+			}
+		default:
+			return true
+		}
+	}
+	return false
+}
+
+func compileMatches(matches []ValueMatch) []valueMatcher {
+	compiled := make([]valueMatcher, len(matches))
+	for i, m := range matches {
+		compiled[i].ValueMatch = m
+		if m.Regex != "" {
+			compiled[i].re = regexp.MustCompile(m.Regex)
+		}
+	}
+	return compiled
+}
+
+// Validate checks the match and rewrite settings of the route
+func (r Route) Validate() error {
+	for _, m := range r.Headers {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("route %q: header %w", r.Name, err)
+		}
+	}
+	for _, m := range r.Query {
+		if err := m.Validate(); err != nil {
+			return fmt.Errorf("route %q: query parameter %w", r.Name, err)
+		}
+	}
+	switch {
+	case r.Handler == nil:
+		return fmt.Errorf("route %q has no handler", r.Name)
+	case r.PathPrefix != "" && !strings.HasPrefix(r.PathPrefix, "/"):
+		return fmt.Errorf("route %q: path prefix %q must start with /", r.Name, r.PathPrefix)
+	case (r.StripPrefix || r.Rewrite != "") && r.PathPrefix == "":
+		return fmt.Errorf("route %q: stripping or rewriting requires a path prefix", r.Name)
+	case r.StripPrefix && r.Rewrite != "":
+		return fmt.Errorf("route %q: strip prefix and rewrite are exclusive", r.Name)
+	case r.Rewrite != "" && !strings.HasPrefix(r.Rewrite, "/"):
+		return fmt.Errorf("route %q: rewrite %q must start with /", r.Name, r.Rewrite)
+	case r.Timeout < 0 || r.ResponseTimeout < 0 || r.IdleTimeout < 0:
+		return fmt.Errorf("route %q: timeouts must not be negative", r.Name)
+	}
+	return nil
+}
+
+// compiledRoute is a route with its header and query matches compiled
+type compiledRoute struct {
+	Route
+	headers, query []valueMatcher
+}
+
+// matches reports whether req is sent to the route
+func (r *compiledRoute) matches(req *http.Request) bool {
+	if r.PathPrefix != "" && !strings.HasPrefix(req.URL.Path, r.PathPrefix) && req.URL.Path+"/" != r.PathPrefix {
+		return false
+	}
+	if len(r.Methods) > 0 && !slices.ContainsFunc(r.Methods, func(m string) bool { return strings.EqualFold(m, req.Method) }) {
+		return false
+	}
+	for _, m := range r.headers {
+		if !m.matches(req.Header.Values(m.Name)) {
+			return false
+		}
+	}
+	if len(r.query) > 0 {
+		query := req.URL.Query()
+		for _, m := range r.query {
+			if !m.matches(query[m.Name]) {
+				return false
+			}
+		}
+	}
+	return r.matchesHost(req)
+}
+
+// matchesHost reports whether the host of req matches the route
+func (r *compiledRoute) matchesHost(req *http.Request) bool {
+	if r.Host == "" {
+		return true
+	}
+	host := req.Host
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	if suffix, ok := strings.CutPrefix(r.Host, "*"); ok {
+		return strings.HasSuffix(strings.ToLower(host), strings.ToLower(suffix))
+	}
+	return strings.EqualFold(host, r.Host)
+}
+
+// rewritePath returns path with the matched prefix replaced by replacement
+func rewritePath(path, prefix, replacement string) string {
+	rest := strings.TrimPrefix(path, strings.TrimSuffix(prefix, "/"))
+	rest = strings.TrimPrefix(rest, "/")
+	if rest == "" && !strings.HasSuffix(path, "/") && replacement != "/" {
+		return strings.TrimSuffix(replacement, "/")
+	}
+	return strings.TrimSuffix(replacement, "/") + "/" + rest
+}
+
+// forward returns the request as the route's handler sees it
+func (r Route) forward(req *http.Request) *http.Request {
+	replacement := r.Rewrite
+	if r.StripPrefix {
+		replacement = "/"
+	}
+	if replacement == "" && len(r.SetHeaders) == 0 {
+		return req
+	}
+	out := new(http.Request)
+	*out = *req
+	if replacement != "" {
+		out.URL = new(url.URL)
+		*out.URL = *req.URL
+		out.URL.Path = rewritePath(req.URL.Path, r.PathPrefix, replacement)
+		if req.URL.RawPath != "" {
+			out.URL.RawPath = rewritePath(req.URL.RawPath, r.PathPrefix, replacement)
+		}
+		out.RequestURI = out.URL.RequestURI()
+	}
+	if len(r.SetHeaders) > 0 {
+		out.Header = req.Header.Clone()
+		for k, v := range r.SetHeaders {
+			out.Header.Set(k, v)
+		}
+	}
+	return out
+}
+
+// Router dispatches requests to the first matching route; requests no
+// route matches get 404
+type Router struct {
+	routes atomic.Pointer[[]*compiledRoute]
+}
+
+// New creates a router serving routes in order
+func New(routes []Route) (*Router, error) {
+	rt := &Router{}
+	if err := rt.SetRoutes(routes); err != nil {
+		return nil, err
+	}
+	return rt, nil
+}
+
+// SetRoutes replaces the routes; requests in flight finish on the old ones
+func (rt *Router) SetRoutes(routes []Route) error {
+	for _, r := range routes {
+		if err := r.Validate(); err != nil {
+			return err
+		}
+	}
+	compiled := make([]*compiledRoute, len(routes))
+	for i, r := range routes {
+		compiled[i] = &compiledRoute{Route: r, headers: compileMatches(r.Headers), query: compileMatches(r.Query)}
+	}
+	rt.routes.Store(&compiled)
+	return nil
+}
+
+// Routes returns the current routes
+func (rt *Router) Routes() []Route {
+	compiled := *rt.routes.Load()
+	routes := make([]Route, len(compiled))
+	for i, r := range compiled {
+		routes[i] = r.Route
+	}
+	return routes
+}
+
+// Match returns the route serving req
+func (rt *Router) Match(req *http.Request) (Route, bool) {
+	for _, r := range *rt.routes.Load() {
+		if r.matches(req) {
+			return r.Route, true
+		}
+	}
+	return Route{}, false
+}
+
+// ServeHTTP sends the request to its route
+func (rt *Router) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	route, ok := rt.Match(req)
+	if !ok {
+		http.Error(w, "No route for this request", http.StatusNotFound)
+		return
+	}
+	if route.Timeout > 0 {
+		ctx, cancel := context.WithTimeout(req.Context(), route.Timeout)
+		defer cancel()
+		req = req.WithContext(ctx)
+	}
+	if route.ResponseTimeout > 0 || route.IdleTimeout > 0 {
+		req = req.WithContext(backend.WithTimeouts(req.Context(), backend.Timeouts{
+			Response: route.ResponseTimeout,
+			Idle:     route.IdleTimeout,
+		}))
+	}
+	route.Handler.ServeHTTP(w, route.forward(req))
+}
+
+// CORS returns a middleware applying the CORS override of the route each
+// request matches: middleware.CORS for routes that enable it, nothing for
+// routes that disable it, and inherited otherwise
+func (rt *Router) CORS(inherited func(http.Handler) http.Handler) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		withCORS, inherit := middleware.CORS(next), inherited(next)
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			route, ok := rt.Match(req)
+			switch {
+			case !ok || route.CORS == nil:
+				inherit.ServeHTTP(w, req)
+			case *route.CORS:
+				withCORS.ServeHTTP(w, req)
+			default:
+				next.ServeHTTP(w, req)
+			}
+		})
+	}
+}
@@ -0,0 +1,184 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// echo answers with the name of the route and the path it received
+func echo(name string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Route", name)
+		w.Header().Set("X-Path", r.URL.Path)
+		w.Header().Set("X-Tenant", r.Header.Get("X-Tenant"))
+		if _, ok := r.Context().Deadline(); ok {
+			w.Header().Set("X-Deadline", "yes")
+		}
+	})
+}
+
+func TestRouter(t *testing.T) {
+	rt, err := New([]Route{
+		{Name: "api", PathPrefix: "/api/", StripPrefix: true, SetHeaders: map[string]string{"X-Tenant": "a"}, Handler: echo("api")},
+		{Name: "v1", PathPrefix: "/v1/", Rewrite: "/v2/", Timeout: time.Second, Handler: echo("v1")},
+		{Name: "admin", Host: "admin.example.com", Handler: echo("admin")},
+		{Name: "tenants", Host: "*.example.com", PathPrefix: "/static/", Handler: echo("tenants")},
+		{Name: "static", PathPrefix: "/static/", Handler: echo("static")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := []struct {
+		host, path string
+		route      string
+		forwarded  string
+	}{
+		{"lb", "/api/users?x=1", "api", "/users"},
+		{"lb", "/api", "api", "/"},
+		{"lb", "/api/", "api", "/"},
+		{"lb", "/v1/orders", "v1", "/v2/orders"},
+		{"lb", "/v1", "v1", "/v2"},
+		{"admin.example.com:8080", "/static/app.js", "admin", "/static/app.js"},
+		{"shop.example.com", "/static/app.js", "tenants", "/static/app.js"},
+		{"example.com", "/static/app.js", "static", "/static/app.js"},
+		{"lb", "/other", "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.host+tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.Host = tt.host
+			rr := httptest.NewRecorder()
+			rt.ServeHTTP(rr, req)
+			if tt.route == "" {
+				if rr.Code != http.StatusNotFound {
+					t.Errorf("Expected 404, got %d", rr.Code)
+				}
+				return
+			}
+			if got := rr.Header().Get("X-Route"); got != tt.route {
+				t.Errorf("Expected route %s, got %q", tt.route, got)
+			}
+			if got := rr.Header().Get("X-Path"); got != tt.forwarded {
+				t.Errorf("Expected path %s, got %s", tt.forwarded, got)
+			}
+		})
+	}
+
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/api/x", nil))
+	if rr.Header().Get("X-Tenant") != "a" {
+		t.Error("Expected the route headers on the forwarded request")
+	}
+	rr = httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/v1/x", nil))
+	if rr.Header().Get("X-Deadline") != "yes" {
+		t.Error("Expected the route timeout on the forwarded request")
+	}
+}
+
+func TestRouter_Conditions(t *testing.T) {
+	rt, err := New([]Route{
+		{Name: "beta", Headers: []ValueMatch{{Name: "X-Beta", Value: "true"}}, Handler: echo("beta")},
+		{Name: "mobile", Headers: []ValueMatch{{Name: "User-Agent", Regex: "(?i)android|iphone"}}, Handler: echo("mobile")},
+		{Name: "preview", Query: []ValueMatch{{Name: "preview"}}, Handler: echo("preview")},
+		{Name: "writes", Methods: []string{"POST", "put"}, PathPrefix: "/api/", Handler: echo("writes")},
+		{Name: "default", Handler: echo("default")},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create router: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		method  string
+		target  string
+		headers map[string]string
+		want    string
+	}{
+		{"beta header", "GET", "/", map[string]string{"X-Beta": "true"}, "beta"},
+		{"beta header other value", "GET", "/", map[string]string{"X-Beta": "false"}, "default"},
+		{"user agent regex", "GET", "/", map[string]string{"User-Agent": "Mozilla/5.0 (iPhone)"}, "mobile"},
+		{"query present", "GET", "/page?preview", nil, "preview"},
+		{"query absent", "GET", "/page?other=1", nil, "default"},
+		{"method", "PUT", "/api/items", nil, "writes"},
+		{"other method", "GET", "/api/items", nil, "default"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(tt.method, tt.target, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			rr := httptest.NewRecorder()
+			rt.ServeHTTP(rr, req)
+			if got := rr.Header().Get("X-Route"); got != tt.want {
+				t.Errorf("Expected route %s, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestRouter_SetRoutes(t *testing.T) {
+	rt, _ := New([]Route{{Name: "a", Handler: echo("a")}})
+
+	invalid := []Route{
+		{Name: "no handler"},
+		{Name: "prefix", PathPrefix: "api", Handler: echo("x")},
+		{Name: "strip", StripPrefix: true, Handler: echo("x")},
+		{Name: "both", PathPrefix: "/a/", StripPrefix: true, Rewrite: "/b/", Handler: echo("x")},
+		{Name: "rewrite", PathPrefix: "/a/", Rewrite: "b", Handler: echo("x")},
+		{Name: "regex", Headers: []ValueMatch{{Name: "X-Beta", Regex: "("}}, Handler: echo("x")},
+		{Name: "value and regex", Query: []ValueMatch{{Name: "v", Value: "1", Regex: "1"}}, Handler: echo("x")},
+	}
+	for _, r := range invalid {
+		if err := rt.SetRoutes([]Route{r}); err == nil {
+			t.Errorf("Expected error for route %s", r.Name)
+		}
+	}
+
+	if err := rt.SetRoutes([]Route{{Name: "b", Handler: echo("b")}}); err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	rr := httptest.NewRecorder()
+	rt.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if got := rr.Header().Get("X-Route"); got != "b" {
+		t.Errorf("Expected the new routes to serve, got %q", got)
+	}
+}
+
+func TestRouter_CORS(t *testing.T) {
+	on, off := true, false
+	rt, _ := New([]Route{
+		{Name: "on", PathPrefix: "/on/", CORS: &on, Handler: echo("on")},
+		{Name: "off", PathPrefix: "/off/", CORS: &off, Handler: echo("off")},
+		{Name: "inherit", Handler: echo("inherit")},
+	})
+	inherited := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Access-Control-Allow-Origin", "inherited")
+			next.ServeHTTP(w, r)
+		})
+	}
+	handler := rt.CORS(inherited)(rt)
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/on/x", "*"},
+		{"/off/x", ""},
+		{"/x", "inherited"},
+	}
+	for _, tt := range tests {
+		rr := httptest.NewRecorder()
+		handler.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if got := rr.Header().Get("Access-Control-Allow-Origin"); got != tt.want {
+			t.Errorf("%s: expected Access-Control-Allow-Origin %q, got %q", tt.path, tt.want, got)
+		}
+	}
+}
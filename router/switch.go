@@ -0,0 +1,124 @@
+package router
+
+import (
+	"fmt"
+	"net/http"
+	"slices"
+	"sync"
+	"sync/atomic"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Switch sends every request to the active one of several named handlers,
+// e.g. the load balancers of a blue and a green pool. Switching is atomic:
+// new requests reach the new handler at once, while the requests already
+// in flight finish on the old one.
+type Switch struct {
+	names    []string
+	handlers map[string]http.Handler
+	active   atomic.Pointer[switchTarget]
+	audit    *audit.Log
+	// mu serializes switches so each one records the right previous target
+	mu sync.Mutex
+}
+
+type switchTarget struct {
+	name    string
+	handler http.Handler
+}
+
+// SwitchStatus describes a switch and the requests each target still serves
+type SwitchStatus struct {
+	Active  string         `json:"active"`
+	Targets []TargetStatus `json:"targets"`
+}
+
+// TargetStatus is one target of a switch
+type TargetStatus struct {
+	Name string `json:"name"`
+	// InFlight counts the requests the target is serving, when its handler
+	// reports them (as a load balancer does); -1 otherwise
+	InFlight int `json:"inFlight"`
+}
+
+// NewSwitch creates a switch sending requests to active among targets;
+// switches are recorded in auditLog (optional)
+func NewSwitch(targets map[string]http.Handler, active string, auditLog *audit.Log) (*Switch, error) {
+	if len(targets) < 2 {
+		return nil, fmt.Errorf("a switch needs at least 2 targets, got %d", len(targets))
+	}
+	s := &Switch{handlers: make(map[string]http.Handler, len(targets)), audit: auditLog}
+	for name, h := range targets {
+		if h == nil {
+			return nil, fmt.Errorf("target %q has no handler", name)
+		}
+		s.names = append(s.names, name)
+		s.handlers[name] = h
+	}
+	slices.Sort(s.names)
+	if _, ok := s.handlers[active]; !ok {
+		return nil, fmt.Errorf("active target %q is not one of %v", active, s.names)
+	}
+	s.active.Store(&switchTarget{name: active, handler: s.handlers[active]})
+	return s, nil
+}
+
+// Names returns the target names, sorted
+func (s *Switch) Names() []string {
+	return slices.Clone(s.names)
+}
+
+// Active returns the name of the target receiving new requests
+func (s *Switch) Active() string {
+	return s.active.Load().name
+}
+
+// Set makes name the active target and returns the previous one
+func (s *Switch) Set(name, actor string) (previous string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.set(name, actor)
+}
+
+// Toggle activates the target after the active one in name order, the
+// other pool of a blue/green pair, and returns the previous one
+func (s *Switch) Toggle(actor string) (previous string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	i := slices.Index(s.names, s.Active())
+	return s.set(s.names[(i+1)%len(s.names)], actor)
+}
+
+// set switches to name; the caller holds s.mu
+func (s *Switch) set(name, actor string) (string, error) {
+	h, ok := s.handlers[name]
+	if !ok {
+		return "", fmt.Errorf("unknown target %q (valid: %v)", name, s.names)
+	}
+	previous := s.active.Swap(&switchTarget{name: name, handler: h}).name
+	if previous != name {
+		logging.Logger().Info("traffic switched", "from", previous, "to", name)
+		s.audit.Record(actor, "switch.set", name, "from "+previous)
+	}
+	return previous, nil
+}
+
+// Status returns the active target and the in-flight requests per target
+func (s *Switch) Status() SwitchStatus {
+	status := SwitchStatus{Active: s.Active(), Targets: make([]TargetStatus, 0, len(s.names))}
+	for _, name := range s.names {
+		inFlight := -1
+		if counter, ok := s.handlers[name].(interface{ InFlight() int }); ok {
+			inFlight = counter.InFlight()
+		}
+		status.Targets = append(status.Targets, TargetStatus{Name: name, InFlight: inFlight})
+	}
+	return status
+}
+
+// ServeHTTP sends the request to the active target
+func (s *Switch) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	s.active.Load().handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,96 @@
+package router
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+// inFlightHandler answers with its name and reports a fixed in-flight count
+type inFlightHandler struct {
+	name     string
+	inFlight int
+}
+
+func (h inFlightHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Route", h.name)
+}
+
+func (h inFlightHandler) InFlight() int { return h.inFlight }
+
+func TestSwitch(t *testing.T) {
+	if _, err := NewSwitch(map[string]http.Handler{"blue": echo("blue")}, "blue", nil); err == nil {
+		t.Error("Expected error for a single target")
+	}
+	if _, err := NewSwitch(map[string]http.Handler{"blue": echo("blue"), "green": echo("green")}, "red", nil); err == nil {
+		t.Error("Expected error for an unknown active target")
+	}
+
+	s, err := NewSwitch(map[string]http.Handler{
+		"blue":  inFlightHandler{name: "blue", inFlight: 3},
+		"green": echo("green"),
+	}, "blue", nil)
+	if err != nil {
+		t.Fatalf("Failed to create switch: %v", err)
+	}
+	served := func() string {
+		rr := httptest.NewRecorder()
+		s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+		return rr.Header().Get("X-Route")
+	}
+
+	if got := served(); got != "blue" {
+		t.Errorf("Expected blue to serve, got %q", got)
+	}
+	if previous, err := s.Toggle("test"); err != nil || previous != "blue" {
+		t.Errorf("Expected toggle from blue, got %q (%v)", previous, err)
+	}
+	if got := served(); got != "green" || s.Active() != "green" {
+		t.Errorf("Expected green to serve after the toggle, got %q", got)
+	}
+	if _, err := s.Set("red", "test"); err == nil {
+		t.Error("Expected error for an unknown target")
+	}
+	if previous, err := s.Set("blue", "test"); err != nil || previous != "green" {
+		t.Errorf("Expected switch from green, got %q (%v)", previous, err)
+	}
+
+	status := s.Status()
+	if status.Active != "blue" || len(status.Targets) != 2 ||
+		status.Targets[0] != (TargetStatus{Name: "blue", InFlight: 3}) ||
+		status.Targets[1] != (TargetStatus{Name: "green", InFlight: -1}) {
+		t.Errorf("Unexpected status %+v", status)
+	}
+}
+
+func TestSwitch_InFlightFinishOnOldTarget(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	var blueServed atomic.Int64
+	blue := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+		blueServed.Add(1)
+	})
+	s, _ := NewSwitch(map[string]http.Handler{"blue": blue, "green": echo("green")}, "blue", nil)
+
+	done := make(chan struct{})
+	go func() {
+		s.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+		close(done)
+	}()
+	<-started
+	s.Set("green", "test")
+
+	rr := httptest.NewRecorder()
+	s.ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/", nil))
+	if rr.Header().Get("X-Route") != "green" {
+		t.Error("Expected new requests on green while blue finishes")
+	}
+	close(release)
+	<-done
+	if blueServed.Load() != 1 {
+		t.Error("Expected the in-flight request to finish on blue")
+	}
+}
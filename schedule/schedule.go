@@ -0,0 +1,330 @@
+// Package schedule shifts backend weights over time. A scheduled change
+// ramps the weight of the matching backends from one value to another over
+// a duration, once or every day, so a canary can be ramped up or a pool
+// drained for the night without anyone at the keyboard.
+package schedule
+
+import (
+	"context"
+	"fmt"
+	"maps"
+	"math"
+	"reflect"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// DefaultInterval is how often the scheduler re-evaluates its changes
+const DefaultInterval = 10 * time.Second
+
+// Sources of a scheduled change
+const (
+	SourceConfig = "config"
+	SourceAPI    = "api"
+)
+
+// Config describes a scheduled weight change
+type Config struct {
+	Name string `json:"name"`
+	// Backend selects one backend by URL; Labels selects every backend
+	// carrying all of the given labels
+	Backend string            `json:"backend,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	// From is the starting weight; 0 starts from each backend's weight when
+	// the change begins
+	From int `json:"from,omitempty"`
+	To   int `json:"to"`
+	// Start runs the change once at that time (now when zero); Daily runs it
+	// every day at "HH:MM" local time
+	Start time.Time `json:"start,omitempty"`
+	Daily string    `json:"daily,omitempty"`
+	// Duration spreads the change evenly; 0 applies To at once
+	Duration time.Duration `json:"duration,omitempty"`
+}
+
+// Validate checks a scheduled change
+func (c Config) Validate() error {
+	switch {
+	case c.Backend == "" && len(c.Labels) == 0:
+		return fmt.Errorf("backend or labels is required")
+	case c.Backend != "" && len(c.Labels) > 0:
+		return fmt.Errorf("backend and labels are mutually exclusive")
+	case c.To < 1 || c.To > backend.MaxWeight:
+		return fmt.Errorf("to %d is out of range (1-%d)", c.To, backend.MaxWeight)
+	case c.From != 0 && (c.From < 1 || c.From > backend.MaxWeight):
+		return fmt.Errorf("from %d is out of range (1-%d)", c.From, backend.MaxWeight)
+	case c.Duration < 0:
+		return fmt.Errorf("duration must not be negative")
+	case c.Daily != "" && !c.Start.IsZero():
+		return fmt.Errorf("start and daily are mutually exclusive")
+	}
+	if c.Daily != "" {
+		if _, err := time.Parse("15:04", c.Daily); err != nil {
+			return fmt.Errorf("daily %q must be HH:MM", c.Daily)
+		}
+		if c.Duration >= 24*time.Hour {
+			return fmt.Errorf("duration of a daily change must be under 24h")
+		}
+	}
+	return nil
+}
+
+// matches reports whether the change applies to b
+func (c Config) matches(b *backend.Backend) bool {
+	if c.Backend != "" {
+		return b.GetURL().String() == c.Backend
+	}
+	return b.HasLabels(c.Labels)
+}
+
+// Job is the admin view of a scheduled change
+type Job struct {
+	ID int `json:"id"`
+	Config
+	Source string `json:"source"`
+	// Active is true while a run is ramping; Done once a one-time change
+	// has completed
+	Active bool `json:"active"`
+	Done   bool `json:"done"`
+	// Next is the start of the upcoming run
+	Next time.Time `json:"next,omitempty"`
+}
+
+// job is a scheduled change and the state of its current run
+type job struct {
+	Job
+	config   Config    // as given, before the name was defaulted
+	daily    time.Time // parsed Daily
+	created  time.Time
+	run      time.Time      // start of the current run
+	from     map[string]int // starting weight per backend URL in the current run
+	finished time.Time      // start of the last completed run
+}
+
+// Scheduler applies scheduled weight changes to the backends of a load
+// balancer
+type Scheduler struct {
+	mu       sync.Mutex
+	jobs     []*job
+	nextID   int
+	backends func() []*backend.Backend
+	audit    *audit.Log
+	now      func() time.Time
+}
+
+// New creates a scheduler acting on the backends returned by backends;
+// weight changes are recorded in auditLog (optional)
+func New(backends func() []*backend.Backend, auditLog *audit.Log) *Scheduler {
+	return &Scheduler{nextID: 1, backends: backends, audit: auditLog, now: time.Now}
+}
+
+// Add schedules a change on behalf of actor
+func (s *Scheduler) Add(cfg Config, actor string) (Job, error) {
+	if err := cfg.Validate(); err != nil {
+		return Job{}, err
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	j := s.add(cfg, SourceAPI)
+	s.audit.Record(actor, "schedule.add", j.Name, describe(cfg))
+	logging.Logger().Info("schedule added", "schedule", j.Name, "plan", describe(cfg))
+	return j.view(s.now()), nil
+}
+
+// add registers a validated change; the caller holds s.mu
+func (s *Scheduler) add(cfg Config, source string) *job {
+	j := &job{Job: Job{ID: s.nextID, Config: cfg, Source: source}, config: cfg, created: s.now()}
+	if j.Name == "" {
+		j.Name = fmt.Sprintf("schedule-%d", j.ID)
+	}
+	if cfg.Daily != "" {
+		j.daily, _ = time.Parse("15:04", cfg.Daily)
+	}
+	s.nextID++
+	s.jobs = append(s.jobs, j)
+	return j
+}
+
+// Cancel removes a change on behalf of actor; weights stay where the change
+// left them
+func (s *Scheduler) Cancel(id int, actor string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i, j := range s.jobs {
+		if j.ID == id {
+			s.jobs = append(s.jobs[:i], s.jobs[i+1:]...)
+			s.audit.Record(actor, "schedule.cancel", j.Name, "")
+			logging.Logger().Info("schedule cancelled", "schedule", j.Name)
+			return nil
+		}
+	}
+	return fmt.Errorf("schedule %d not found", id)
+}
+
+// SetConfigured replaces the changes that came from the config file. Changes
+// that are still configured identically keep their progress, so a reload
+// does not restart them.
+func (s *Scheduler) SetConfigured(cfgs []Config) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	kept := make([]*job, 0, len(s.jobs)+len(cfgs))
+	remaining := append([]Config(nil), cfgs...)
+	for _, j := range s.jobs {
+		if j.Source != SourceConfig {
+			kept = append(kept, j)
+			continue
+		}
+		for i, cfg := range remaining {
+			if reflect.DeepEqual(cfg, j.config) {
+				kept = append(kept, j)
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				break
+			}
+		}
+	}
+	s.jobs = kept
+	for _, cfg := range remaining {
+		s.add(cfg, SourceConfig)
+	}
+}
+
+// List returns the scheduled changes, sorted by ID
+func (s *Scheduler) List() []Job {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	out := make([]Job, 0, len(s.jobs))
+	for _, j := range s.jobs {
+		out = append(out, j.view(now))
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].ID < out[j].ID })
+	return out
+}
+
+// Run applies the scheduled changes every interval until ctx is cancelled
+func (s *Scheduler) Run(ctx context.Context, interval time.Duration) {
+	if interval <= 0 {
+		interval = DefaultInterval
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		s.tick()
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// tick moves every running change to the weight it should have by now
+func (s *Scheduler) tick() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	now := s.now()
+	backends := s.backends()
+	for _, j := range s.jobs {
+		start := j.runStart(now)
+		if j.Done || now.Before(start) || start.Equal(j.finished) {
+			continue
+		}
+		// Daily runs that ended before the change was scheduled are skipped
+		if j.Daily != "" && start.Add(j.Duration).Before(j.created) {
+			continue
+		}
+		if !start.Equal(j.run) {
+			j.run, j.from = start, make(map[string]int)
+			logging.Logger().Info("schedule started", "schedule", j.Name)
+		}
+
+		progress := 1.0
+		if elapsed := now.Sub(start); elapsed < j.Duration {
+			progress = float64(elapsed) / float64(j.Duration)
+		}
+		for _, b := range backends {
+			if j.matches(b) {
+				s.apply(j, b, progress)
+			}
+		}
+		if progress >= 1 {
+			j.finished = start
+			j.Done = j.Daily == ""
+			logging.Logger().Info("schedule finished", "schedule", j.Name)
+		}
+	}
+}
+
+// apply sets b to the weight of j at progress (0-1)
+func (s *Scheduler) apply(j *job, b *backend.Backend, progress float64) {
+	url := b.GetURL().String()
+	from := j.From
+	if from == 0 {
+		if _, ok := j.from[url]; !ok {
+			j.from[url] = b.GetWeight()
+		}
+		from = j.from[url]
+	}
+	weight := from + int(math.Round(float64(j.To-from)*progress))
+	previous := b.GetWeight()
+	if weight == previous {
+		return
+	}
+	if err := b.SetWeight(weight); err != nil {
+		logging.Logger().Error("schedule failed", "schedule", j.Name, "error", err)
+		return
+	}
+	s.audit.Record(audit.SystemActor, "schedule.weight", url, fmt.Sprintf("%s: %d -> %d", j.Name, previous, weight))
+}
+
+// runStart returns the start of the current (or, for a one-time change in
+// the future, the upcoming) run
+func (j *job) runStart(now time.Time) time.Time {
+	if j.Daily == "" {
+		if j.Start.IsZero() {
+			return j.created
+		}
+		return j.Start
+	}
+	start := time.Date(now.Year(), now.Month(), now.Day(), j.daily.Hour(), j.daily.Minute(), 0, 0, now.Location())
+	if start.After(now) {
+		start = start.AddDate(0, 0, -1)
+	}
+	return start
+}
+
+func (j *job) view(now time.Time) Job {
+	v := j.Job
+	v.Labels = maps.Clone(j.Labels)
+	start := j.runStart(now)
+	v.Active = !j.Done && !now.Before(start) && !start.Equal(j.finished) && now.Before(start.Add(j.Duration))
+	switch {
+	case j.Done:
+	case now.Before(start):
+		v.Next = start
+	case j.Daily != "":
+		v.Next = start.AddDate(0, 0, 1)
+	}
+	return v
+}
+
+// describe summarizes a change for the log and audit trail
+func describe(cfg Config) string {
+	target := cfg.Backend
+	if target == "" {
+		target = fmt.Sprintf("labels %v", cfg.Labels)
+	}
+	when := "now"
+	switch {
+	case cfg.Daily != "":
+		when = "daily at " + cfg.Daily
+	case !cfg.Start.IsZero():
+		when = "at " + cfg.Start.Format(time.RFC3339)
+	}
+	return fmt.Sprintf("%s -> weight %d over %v, %s", target, cfg.To, cfg.Duration, when)
+}
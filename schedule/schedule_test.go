@@ -0,0 +1,153 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/audit"
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+func newBackend(t *testing.T, url string, weight int, labels map[string]string) *backend.Backend {
+	t.Helper()
+	b, err := backend.NewBackendWithConfig(backend.Config{URL: url, Weight: weight, Labels: labels})
+	if err != nil {
+		t.Fatalf("Failed to create backend: %v", err)
+	}
+	return b
+}
+
+func TestConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		cfg   Config
+		valid bool
+	}{
+		{"ramp", Config{Backend: "http://a", To: 50, Duration: time.Hour}, true},
+		{"daily by label", Config{Labels: map[string]string{"pool": "blue"}, To: 1, Daily: "22:30"}, true},
+		{"no target", Config{To: 5}, false},
+		{"both targets", Config{Backend: "http://a", Labels: map[string]string{"a": "b"}, To: 5}, false},
+		{"weight too high", Config{Backend: "http://a", To: backend.MaxWeight + 1}, false},
+		{"bad from", Config{Backend: "http://a", From: -1, To: 5}, false},
+		{"bad daily", Config{Backend: "http://a", To: 5, Daily: "25:00"}, false},
+		{"start and daily", Config{Backend: "http://a", To: 5, Daily: "01:00", Start: time.Now()}, false},
+		{"daily too long", Config{Backend: "http://a", To: 5, Daily: "01:00", Duration: 24 * time.Hour}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.cfg.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected an error")
+			}
+		})
+	}
+}
+
+func TestScheduler_Ramp(t *testing.T) {
+	canary := newBackend(t, "http://canary:8080", 1, nil)
+	stable := newBackend(t, "http://stable:8080", 50, nil)
+	auditLog, _ := audit.New("", 0)
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.UTC)
+	s := New(func() []*backend.Backend { return []*backend.Backend{canary, stable} }, auditLog)
+	s.now = func() time.Time { return now }
+
+	job, err := s.Add(Config{Name: "canary", Backend: "http://canary:8080", To: 51, Duration: 2 * time.Hour}, "alice")
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+
+	s.tick()
+	if canary.GetWeight() != 1 {
+		t.Errorf("Expected weight 1 at the start, got %d", canary.GetWeight())
+	}
+	now = now.Add(time.Hour)
+	s.tick()
+	if canary.GetWeight() != 26 {
+		t.Errorf("Expected weight 26 halfway, got %d", canary.GetWeight())
+	}
+	if jobs := s.List(); !jobs[0].Active || jobs[0].Done {
+		t.Errorf("Expected an active job, got %+v", jobs[0])
+	}
+	now = now.Add(2 * time.Hour)
+	s.tick()
+	if canary.GetWeight() != 51 || stable.GetWeight() != 50 {
+		t.Errorf("Expected weights 51/50, got %d/%d", canary.GetWeight(), stable.GetWeight())
+	}
+	if jobs := s.List(); jobs[0].Active || !jobs[0].Done {
+		t.Errorf("Expected a finished job, got %+v", jobs[0])
+	}
+
+	// Finished one-time changes leave manual weights alone
+	canary.SetWeight(10)
+	s.tick()
+	if canary.GetWeight() != 10 {
+		t.Errorf("Expected weight 10, got %d", canary.GetWeight())
+	}
+
+	var adds, weights int
+	for _, e := range auditLog.Recent(10) {
+		switch e.Action {
+		case "schedule.add":
+			adds++
+		case "schedule.weight":
+			weights++
+		}
+	}
+	if adds != 1 || weights != 2 {
+		t.Errorf("Expected 1 add and 2 weight entries, got %d and %d", adds, weights)
+	}
+
+	if err := s.Cancel(job.ID, "alice"); err != nil {
+		t.Errorf("Expected no error, got %v", err)
+	}
+	if err := s.Cancel(job.ID, "alice"); err == nil {
+		t.Error("Expected an error cancelling twice")
+	}
+}
+
+func TestScheduler_Daily(t *testing.T) {
+	blue := newBackend(t, "http://blue:8080", 40, map[string]string{"pool": "blue"})
+	green := newBackend(t, "http://green:8080", 40, map[string]string{"pool": "green"})
+
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	s := New(func() []*backend.Backend { return []*backend.Backend{blue, green} }, nil)
+	s.now = func() time.Time { return now }
+	s.SetConfigured([]Config{{Labels: map[string]string{"pool": "blue"}, To: 1, Daily: "22:00"}})
+
+	// The 22:00 run of the previous day ended before the change existed
+	s.tick()
+	if blue.GetWeight() != 40 {
+		t.Errorf("Expected weight 40 before the first run, got %d", blue.GetWeight())
+	}
+	if next := s.List()[0].Next; !next.Equal(time.Date(2026, 3, 1, 22, 0, 0, 0, time.Local)) {
+		t.Errorf("Expected next run at 22:00, got %v", next)
+	}
+
+	now = time.Date(2026, 3, 1, 22, 0, 30, 0, time.Local)
+	s.tick()
+	if blue.GetWeight() != 1 || green.GetWeight() != 40 {
+		t.Errorf("Expected weights 1/40, got %d/%d", blue.GetWeight(), green.GetWeight())
+	}
+
+	// Reloading the same config keeps the finished run, the next day runs again
+	blue.SetWeight(40)
+	s.SetConfigured([]Config{{Labels: map[string]string{"pool": "blue"}, To: 1, Daily: "22:00"}})
+	s.tick()
+	if blue.GetWeight() != 40 {
+		t.Errorf("Expected the run not to repeat after a reload, got weight %d", blue.GetWeight())
+	}
+	now = now.Add(24 * time.Hour)
+	s.tick()
+	if blue.GetWeight() != 1 {
+		t.Errorf("Expected weight 1 on the next day, got %d", blue.GetWeight())
+	}
+
+	s.SetConfigured(nil)
+	if len(s.List()) != 0 {
+		t.Errorf("Expected no schedules, got %d", len(s.List()))
+	}
+}
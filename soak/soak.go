@@ -0,0 +1,362 @@
+// Package soak runs the load balancer against chaotic mock backends for a
+// long time while checking invariants that must hold however the backends
+// misbehave: no panics, no negative request or connection counts, and no
+// goroutines or connections left behind once the run is over.
+package soak
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"log/slog"
+	"math/rand"
+	"net"
+	"net/http"
+	"runtime"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/backend"
+	"github.com/TaiTitans/go-balancer/balancer"
+	"github.com/TaiTitans/go-balancer/mockbackend"
+)
+
+// Options configures a soak run
+type Options struct {
+	// Duration of the load; the run also ends when its context is done
+	Duration time.Duration
+	// Backends is the number of mock backends (default 4)
+	Backends int
+	// Concurrency is the number of clients sending requests (default 16)
+	Concurrency int
+	// CheckInterval is how often the invariants are checked (default 10s)
+	CheckInterval time.Duration
+	// HealthInterval is how often backends are probed (default 250ms), fast
+	// enough to follow flapping backends
+	HealthInterval time.Duration
+	// Backend is the misbehaviour of every backend; Name and Port are ignored
+	Backend mockbackend.Options
+	// Progress receives a snapshot after every check (optional)
+	Progress func(Progress)
+}
+
+// DefaultBackendOptions mixes every kind of misbehaviour: random latency,
+// error statuses, connection resets, malformed responses and flapping health
+func DefaultBackendOptions() mockbackend.Options {
+	return mockbackend.Options{
+		Latency:       5 * time.Millisecond,
+		Jitter:        5 * time.Millisecond,
+		ErrorRate:     0.02,
+		ErrorStatus:   http.StatusServiceUnavailable,
+		ResetRate:     0.01,
+		MalformedRate: 0.01,
+		FlapInterval:  3 * time.Second,
+	}
+}
+
+// Progress is reported after every check
+type Progress struct {
+	Elapsed    time.Duration
+	Requests   int64
+	Goroutines int
+	Violations int
+}
+
+// Report summarizes a soak run
+type Report struct {
+	Elapsed  time.Duration
+	Requests int64
+	// Statuses counts the answers by status; 0 counts requests that got
+	// none, mostly ones the client canceled
+	Statuses map[int]int64
+	// BaselineGoroutines were running before the run, Goroutines after it
+	BaselineGoroutines int
+	Goroutines         int
+	// Violations lists every broken invariant once
+	Violations []string
+}
+
+// OK reports whether every invariant held
+func (r Report) OK() bool {
+	return len(r.Violations) == 0
+}
+
+// settleTimeout bounds the wait for connections and goroutines to wind
+// down after the run
+const settleTimeout = 10 * time.Second
+
+// run holds the state of one soak run
+type run struct {
+	opts Options
+	lb   *balancer.LoadBalancer
+
+	mu         sync.Mutex
+	statuses   map[int]int64
+	violations []string
+	requests   atomic.Int64
+	// panics counts panics reported by the HTTP servers
+	panics atomic.Int64
+}
+
+// Run starts the backends and the balancer, sends load until the duration
+// elapses or ctx is done, then shuts everything down and checks that it
+// left nothing behind. Broken invariants are listed in the report; the
+// error is only set when the run could not be set up.
+func Run(ctx context.Context, opts Options) (Report, error) {
+	if opts.Backends <= 0 {
+		opts.Backends = 4
+	}
+	if opts.Concurrency <= 0 {
+		opts.Concurrency = 16
+	}
+	if opts.CheckInterval <= 0 {
+		opts.CheckInterval = 10 * time.Second
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = 250 * time.Millisecond
+	}
+	if err := opts.Backend.Validate(); err != nil {
+		return Report{}, fmt.Errorf("backend options: %w", err)
+	}
+
+	baseline := runtime.NumGoroutine()
+	r := &run{opts: opts, statuses: make(map[int]int64)}
+	errorLog := log.New(&panicWatcher{panics: &r.panics}, "", 0)
+
+	var servers []*http.Server
+	closeAll := func() {
+		for _, s := range servers {
+			s.Close()
+		}
+	}
+	configs := make([]backend.Config, opts.Backends)
+	for i := range configs {
+		mockOpts := opts.Backend
+		mockOpts.Name, mockOpts.Quiet = fmt.Sprintf("soak-%d", i+1), true
+		mock, err := mockbackend.New(mockOpts)
+		if err != nil {
+			closeAll()
+			return Report{}, err
+		}
+		addr, err := serve(mock, errorLog, &servers)
+		if err != nil {
+			closeAll()
+			return Report{}, err
+		}
+		configs[i] = backend.Config{
+			URL:            "http://" + addr,
+			HealthPath:     "/health",
+			MaxConnections: max(2*opts.Concurrency/opts.Backends, 1),
+		}
+	}
+
+	lb, err := balancer.New(
+		balancer.WithBackendConfigs(configs...),
+		balancer.WithHealthCheck(opts.HealthInterval, opts.HealthInterval),
+		balancer.WithQueue(opts.Concurrency, 200*time.Millisecond),
+		balancer.WithLogger(slog.New(slog.NewTextHandler(io.Discard, nil))),
+	)
+	if err != nil {
+		closeAll()
+		return Report{}, err
+	}
+	r.lb = lb
+	lbCtx, stopLB := context.WithCancel(context.Background())
+	defer stopLB()
+	lb.Start(lbCtx)
+	front, err := serve(lb, errorLog, &servers)
+	if err != nil {
+		closeAll()
+		return Report{}, err
+	}
+
+	start := time.Now()
+	loadCtx, stopLoad := context.WithTimeout(ctx, opts.Duration)
+	defer stopLoad()
+	client := &http.Client{Transport: &http.Transport{MaxIdleConnsPerHost: opts.Concurrency}}
+	var wg sync.WaitGroup
+	for range opts.Concurrency {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			r.client(loadCtx, client, "http://"+front)
+		}()
+	}
+
+	ticker := time.NewTicker(opts.CheckInterval)
+	for done := false; !done; {
+		select {
+		case <-loadCtx.Done():
+			done = true
+		case <-ticker.C:
+			r.check()
+			if opts.Progress != nil {
+				opts.Progress(Progress{
+					Elapsed:    time.Since(start),
+					Requests:   r.requests.Load(),
+					Goroutines: runtime.NumGoroutine(),
+					Violations: r.violationCount(),
+				})
+			}
+		}
+	}
+	ticker.Stop()
+	wg.Wait()
+	elapsed := time.Since(start)
+	r.check()
+
+	// Once the clients stopped nothing may be left in flight
+	client.CloseIdleConnections()
+	drainCtx, cancelDrain := context.WithTimeout(context.Background(), settleTimeout)
+	if err := lb.Drain(drainCtx); err != nil {
+		r.violate("drain: %v", err)
+	}
+	cancelDrain()
+	if stats := lb.GetStats(); stats.Queue != nil && stats.Queue.Length != 0 {
+		r.violate("%d requests left in the wait queue", stats.Queue.Length)
+	}
+	closeAll()
+	stopLB()
+
+	goroutines := r.settle(baseline)
+	report := Report{
+		Elapsed:            elapsed,
+		Requests:           r.requests.Load(),
+		Statuses:           r.statuses,
+		BaselineGoroutines: baseline,
+		Goroutines:         goroutines,
+		Violations:         r.violations,
+	}
+	return report, nil
+}
+
+// serve serves h on a loopback port and returns its address
+func serve(h http.Handler, errorLog *log.Logger, servers *[]*http.Server) (string, error) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", err
+	}
+	s := &http.Server{Handler: h, ErrorLog: errorLog, ReadHeaderTimeout: 10 * time.Second}
+	*servers = append(*servers, s)
+	go s.Serve(ln)
+	return ln.Addr().String(), nil
+}
+
+// client sends requests until ctx is done. One in ten gives up after a
+// few milliseconds, so canceled requests are part of the mix.
+func (r *run) client(ctx context.Context, client *http.Client, url string) {
+	body := bytes.Repeat([]byte("x"), 1024)
+	for ctx.Err() == nil {
+		timeout := 5 * time.Second
+		if rand.Intn(10) == 0 {
+			timeout = time.Duration(rand.Int63n(int64(20 * time.Millisecond)))
+		}
+		reqCtx, cancel := context.WithTimeout(ctx, timeout)
+		method, reader := http.MethodGet, io.Reader(nil)
+		if rand.Intn(20) == 0 {
+			method, reader = http.MethodPost, bytes.NewReader(body)
+		}
+		req, _ := http.NewRequestWithContext(reqCtx, method, url+"/", reader)
+		status := 0
+		if resp, err := client.Do(req); err == nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+			status = resp.StatusCode
+		}
+		cancel()
+		r.requests.Add(1)
+		r.mu.Lock()
+		r.statuses[status]++
+		r.mu.Unlock()
+	}
+}
+
+// check verifies the invariants that hold at any time
+func (r *run) check() {
+	if n := r.panics.Load(); n > 0 {
+		r.violate("HTTP servers recovered panics")
+	}
+	stats := r.lb.GetStats()
+	if n := stats.Errors[backend.ErrorPanic]; n > 0 {
+		r.violate("proxy path recovered panics")
+	}
+	if stats.TotalInFlight < 0 {
+		r.violate("negative total in-flight count")
+	}
+	if stats.FailedRequests > stats.TotalRequests {
+		r.violate("more failed requests than requests")
+	}
+	if stats.Queue != nil && stats.Queue.Length < 0 {
+		r.violate("negative wait queue length")
+	}
+	for _, b := range stats.Backends {
+		if b.InFlight < 0 {
+			r.violate("negative in-flight count on %s", b.URL)
+		}
+		if b.Connections < 0 {
+			r.violate("negative connection count on %s", b.URL)
+		}
+		if b.MaxConnections > 0 && b.InFlight > b.MaxConnections {
+			r.violate("%s over its connection limit", b.URL)
+		}
+	}
+}
+
+// settle waits for the upstream connections and goroutines of the run to
+// wind down, reporting those that don't, and returns the goroutine count
+func (r *run) settle(baseline int) int {
+	deadline := time.Now().Add(settleTimeout)
+	for {
+		open := 0
+		for _, b := range r.lb.GetBackends() {
+			open += b.OpenConnections()
+		}
+		goroutines := runtime.NumGoroutine()
+		if open == 0 && goroutines <= baseline {
+			return goroutines
+		}
+		if time.Now().After(deadline) {
+			if open != 0 {
+				r.violate("%d upstream connections counted open after shutdown", open)
+			}
+			if goroutines > baseline {
+				r.violate("%d goroutines left running (baseline %d)", goroutines-baseline, baseline)
+			}
+			return goroutines
+		}
+		time.Sleep(50 * time.Millisecond)
+	}
+}
+
+// violate records a broken invariant once
+func (r *run) violate(format string, args ...interface{}) {
+	msg := fmt.Sprintf(format, args...)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if !slices.Contains(r.violations, msg) {
+		r.violations = append(r.violations, msg)
+	}
+}
+
+func (r *run) violationCount() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.violations)
+}
+
+// panicWatcher counts the panics in an http.Server error log
+type panicWatcher struct {
+	panics *atomic.Int64
+}
+
+func (w *panicWatcher) Write(p []byte) (int, error) {
+	if strings.Contains(string(p), "panic") {
+		w.panics.Add(1)
+	}
+	return len(p), nil
+}
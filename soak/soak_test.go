@@ -0,0 +1,54 @@
+package soak
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+	"github.com/TaiTitans/go-balancer/mockbackend"
+)
+
+func TestRun(t *testing.T) {
+	logging.SetLogger(slog.New(slog.NewTextHandler(io.Discard, nil)))
+	defer logging.SetLogger(nil)
+
+	opts := Options{
+		Duration:       time.Second,
+		Backends:       3,
+		Concurrency:    8,
+		CheckInterval:  100 * time.Millisecond,
+		HealthInterval: 50 * time.Millisecond,
+		Backend:        DefaultBackendOptions(),
+	}
+	opts.Backend.ResetRate, opts.Backend.MalformedRate = 0.05, 0.05
+	opts.Backend.FlapInterval = 300 * time.Millisecond
+	checks := 0
+	opts.Progress = func(Progress) { checks++ }
+
+	report, err := Run(context.Background(), opts)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !report.OK() {
+		t.Errorf("Expected every invariant to hold, got %v", report.Violations)
+	}
+	if report.Requests == 0 || report.Statuses[http.StatusOK] == 0 {
+		t.Errorf("Expected successful requests, got %d requests with %v", report.Requests, report.Statuses)
+	}
+	if report.Statuses[http.StatusBadGateway] == 0 {
+		t.Errorf("Expected the injected failures to surface as 502, got %v", report.Statuses)
+	}
+	if checks < 5 {
+		t.Errorf("Expected progress after every check, got %d", checks)
+	}
+}
+
+func TestRun_InvalidOptions(t *testing.T) {
+	if _, err := Run(context.Background(), Options{Backend: mockbackend.Options{ResetRate: 2}}); err == nil {
+		t.Error("Expected an error for an invalid reset rate")
+	}
+}
@@ -0,0 +1,109 @@
+package sticky
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+// sweepEvery is how many writes pass between sweeps of expired sessions
+const sweepEvery = 1024
+
+// MemoryStore keeps sessions in process memory, not shared between
+// instances; they survive a restart only when saved with Sessions and
+// restored with Restore (see balancer.State)
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string]memoryEntry
+	writes   int
+}
+
+type memoryEntry struct {
+	backendURL string
+	expires    time.Time
+}
+
+// Session is a stored session, as saved across restarts
+type Session struct {
+	ID      string    `json:"id"`
+	Backend string    `json:"backend"`
+	Expires time.Time `json:"expires"`
+}
+
+// NewMemoryStore creates an empty in-memory store
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string]memoryEntry)}
+}
+
+// Get returns the backend of an unexpired session
+func (s *MemoryStore) Get(ctx context.Context, session string) (string, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.sessions[session]
+	if !ok || time.Now().After(e.expires) {
+		return "", false, nil
+	}
+	return e.backendURL, true, nil
+}
+
+// Set assigns a session to a backend for ttl
+func (s *MemoryStore) Set(ctx context.Context, session, backendURL string, ttl time.Duration) error {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.sessions[session] = memoryEntry{backendURL: backendURL, expires: now.Add(ttl)}
+	s.writes++
+	if s.writes%sweepEvery == 0 {
+		for id, e := range s.sessions {
+			if now.After(e.expires) {
+				delete(s.sessions, id)
+			}
+		}
+	}
+	return nil
+}
+
+// Delete forgets a session
+func (s *MemoryStore) Delete(ctx context.Context, session string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, session)
+	return nil
+}
+
+// Len returns the number of stored sessions, expired ones included
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.sessions)
+}
+
+// Sessions returns the unexpired sessions, sorted by ID
+func (s *MemoryStore) Sessions() []Session {
+	now := time.Now()
+	s.mu.Lock()
+	sessions := make([]Session, 0, len(s.sessions))
+	for id, e := range s.sessions {
+		if !now.After(e.expires) {
+			sessions = append(sessions, Session{ID: id, Backend: e.backendURL, Expires: e.expires})
+		}
+	}
+	s.mu.Unlock()
+	slices.SortFunc(sessions, func(a, b Session) int { return strings.Compare(a.ID, b.ID) })
+	return sessions
+}
+
+// Restore adds the unexpired sessions with their expiry, replacing stored
+// sessions with the same IDs
+func (s *MemoryStore) Restore(sessions []Session) {
+	now := time.Now()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, session := range sessions {
+		if session.ID != "" && now.Before(session.Expires) {
+			s.sessions[session.ID] = memoryEntry{backendURL: session.Backend, expires: session.Expires}
+		}
+	}
+}
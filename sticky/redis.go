@@ -0,0 +1,200 @@
+package sticky
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Redis defaults
+const (
+	DefaultRedisPrefix  = "go-balancer:sticky:"
+	DefaultRedisTimeout = time.Second
+	// redisIdleConns is how many connections are kept for reuse
+	redisIdleConns = 8
+)
+
+// RedisConfig locates the Redis server holding shared sessions
+type RedisConfig struct {
+	Addr     string        `json:"addr"` // host:port
+	Password string        `json:"password,omitempty"`
+	DB       int           `json:"db,omitempty"`
+	Prefix   string        `json:"prefix,omitempty"` // key prefix
+	Timeout  time.Duration `json:"timeout,omitempty"`
+	TLS      bool          `json:"tls,omitempty"`
+}
+
+// RedisStore keeps sessions in Redis so every balancer instance sharing the
+// server routes a session the same way, across restarts
+type RedisStore struct {
+	cfg  RedisConfig
+	idle chan *redisConn
+}
+
+// errRedisNil is the reply to GET for a missing key
+var errRedisNil = errors.New("redis: nil")
+
+// NewRedisStore creates a Redis-backed store; connections are opened lazily
+func NewRedisStore(cfg RedisConfig) (*RedisStore, error) {
+	if cfg.Addr == "" {
+		return nil, fmt.Errorf("sticky redis address is required")
+	}
+	if cfg.Prefix == "" {
+		cfg.Prefix = DefaultRedisPrefix
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = DefaultRedisTimeout
+	}
+	return &RedisStore{cfg: cfg, idle: make(chan *redisConn, redisIdleConns)}, nil
+}
+
+// Get returns the backend of a session
+func (s *RedisStore) Get(ctx context.Context, session string) (string, bool, error) {
+	reply, err := s.do(ctx, "GET", s.cfg.Prefix+session)
+	if errors.Is(err, errRedisNil) {
+		return "", false, nil
+	}
+	if err != nil {
+		return "", false, err
+	}
+	return reply, true, nil
+}
+
+// Set assigns a session to a backend for ttl
+func (s *RedisStore) Set(ctx context.Context, session, backendURL string, ttl time.Duration) error {
+	_, err := s.do(ctx, "SET", s.cfg.Prefix+session, backendURL, "PX", strconv.FormatInt(ttl.Milliseconds(), 10))
+	return err
+}
+
+// Delete forgets a session
+func (s *RedisStore) Delete(ctx context.Context, session string) error {
+	_, err := s.do(ctx, "DEL", s.cfg.Prefix+session)
+	return err
+}
+
+// do runs one command on a pooled connection
+func (s *RedisStore) do(ctx context.Context, args ...string) (string, error) {
+	conn, err := s.get(ctx)
+	if err != nil {
+		return "", err
+	}
+	reply, err := conn.do(ctx, s.cfg.Timeout, args...)
+	if err != nil && !errors.Is(err, errRedisNil) {
+		conn.Close()
+		return "", err
+	}
+	s.put(conn)
+	return reply, err
+}
+
+func (s *RedisStore) get(ctx context.Context) (*redisConn, error) {
+	select {
+	case conn := <-s.idle:
+		return conn, nil
+	default:
+	}
+
+	dialer := &net.Dialer{Timeout: s.cfg.Timeout}
+	var raw net.Conn
+	var err error
+	if s.cfg.TLS {
+		raw, err = dialTLS(ctx, dialer, s.cfg.Addr)
+	} else {
+		raw, err = dialer.DialContext(ctx, "tcp", s.cfg.Addr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to redis: %w", err)
+	}
+	conn := &redisConn{Conn: raw, r: bufio.NewReader(raw)}
+	if s.cfg.Password != "" {
+		if _, err := conn.do(ctx, s.cfg.Timeout, "AUTH", s.cfg.Password); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis AUTH failed: %w", err)
+		}
+	}
+	if s.cfg.DB != 0 {
+		if _, err := conn.do(ctx, s.cfg.Timeout, "SELECT", strconv.Itoa(s.cfg.DB)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("redis SELECT failed: %w", err)
+		}
+	}
+	return conn, nil
+}
+
+func (s *RedisStore) put(conn *redisConn) {
+	select {
+	case s.idle <- conn:
+	default:
+		conn.Close()
+	}
+}
+
+func dialTLS(ctx context.Context, dialer *net.Dialer, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+	d := &tls.Dialer{NetDialer: dialer, Config: &tls.Config{ServerName: host}}
+	return d.DialContext(ctx, "tcp", addr)
+}
+
+// redisConn speaks RESP on a single connection
+type redisConn struct {
+	net.Conn
+	r *bufio.Reader
+}
+
+// do writes a command and reads a simple, bulk or integer reply
+func (c *redisConn) do(ctx context.Context, timeout time.Duration, args ...string) (string, error) {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	c.SetDeadline(deadline)
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&b, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+	if _, err := io.WriteString(c, b.String()); err != nil {
+		return "", err
+	}
+
+	line, err := c.r.ReadString('\n')
+	if err != nil {
+		return "", err
+	}
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return "", fmt.Errorf("redis: empty reply")
+	}
+	switch line[0] {
+	case '+', ':':
+		return line[1:], nil
+	case '-':
+		return "", fmt.Errorf("redis: %s", line[1:])
+	case '$':
+		n, err := strconv.Atoi(line[1:])
+		if err != nil {
+			return "", fmt.Errorf("redis: invalid bulk length %q", line[1:])
+		}
+		if n < 0 {
+			return "", errRedisNil
+		}
+		buf := make([]byte, n+2)
+		if _, err := io.ReadFull(c.r, buf); err != nil {
+			return "", err
+		}
+		return string(buf[:n]), nil
+	default:
+		return "", fmt.Errorf("redis: unexpected reply %q", line)
+	}
+}
@@ -0,0 +1,143 @@
+// Package sticky implements cookie-based session affinity backed by a
+// pluggable store, so sessions keep their backend across restarts and
+// across balancer instances sharing the store
+package sticky
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// Store types
+const (
+	StoreNone   = "none"
+	StoreMemory = "memory"
+	StoreRedis  = "redis"
+)
+
+// Defaults for unset Config fields
+const (
+	DefaultCookie = "GOLB_SESSION"
+	DefaultTTL    = 30 * time.Minute
+)
+
+// Config enables session affinity
+type Config struct {
+	Store  string        `json:"store"`            // none, memory, redis
+	Cookie string        `json:"cookie,omitempty"` // session cookie name
+	TTL    time.Duration `json:"ttl,omitempty"`    // idle time before a session is forgotten
+	Redis  RedisConfig   `json:"redis"`
+}
+
+// Enabled reports whether affinity is configured
+func (c Config) Enabled() bool {
+	return c.Store != "" && !strings.EqualFold(c.Store, StoreNone)
+}
+
+// Store maps session IDs to backend URLs
+type Store interface {
+	// Get returns the backend URL of a session, if known
+	Get(ctx context.Context, session string) (string, bool, error)
+	// Set assigns a session to a backend for ttl
+	Set(ctx context.Context, session, backendURL string, ttl time.Duration) error
+	// Delete forgets a session
+	Delete(ctx context.Context, session string) error
+}
+
+// Affinity pins sessions, identified by a cookie, to backends
+type Affinity struct {
+	store  Store
+	cookie string
+	ttl    time.Duration
+}
+
+// New creates the affinity configured by cfg
+func New(cfg Config) (*Affinity, error) {
+	var store Store
+	switch strings.ToLower(cfg.Store) {
+	case StoreMemory:
+		store = NewMemoryStore()
+	case StoreRedis:
+		s, err := NewRedisStore(cfg.Redis)
+		if err != nil {
+			return nil, err
+		}
+		store = s
+	default:
+		return nil, fmt.Errorf("unknown sticky session store: %s", cfg.Store)
+	}
+	return NewAffinity(store, cfg.Cookie, cfg.TTL), nil
+}
+
+// NewAffinity creates an affinity over store; empty cookie and zero ttl use
+// the defaults
+func NewAffinity(store Store, cookie string, ttl time.Duration) *Affinity {
+	if cookie == "" {
+		cookie = DefaultCookie
+	}
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return &Affinity{store: store, cookie: cookie, ttl: ttl}
+}
+
+// Store returns the store sessions are kept in
+func (a *Affinity) Store() Store {
+	return a.store
+}
+
+// Lookup returns the session of r and the backend URL it is pinned to. Both
+// are empty for new sessions and for IDs the store doesn't know (e.g.
+// expired or made up by the client), so Assign issues a fresh ID.
+func (a *Affinity) Lookup(r *http.Request) (session, backendURL string, err error) {
+	c, err := r.Cookie(a.cookie)
+	if err != nil || c.Value == "" {
+		return "", "", nil
+	}
+	backendURL, found, err := a.store.Get(r.Context(), c.Value)
+	if err != nil {
+		return c.Value, "", err
+	}
+	if !found {
+		return "", "", nil
+	}
+	return c.Value, backendURL, nil
+}
+
+// Assign pins session to backendURL, starting a new session with a cookie
+// on w when session is empty
+func (a *Affinity) Assign(w http.ResponseWriter, r *http.Request, session, backendURL string) error {
+	if session == "" {
+		var err error
+		if session, err = newSessionID(); err != nil {
+			return err
+		}
+		http.SetCookie(w, &http.Cookie{
+			Name:     a.cookie,
+			Value:    session,
+			Path:     "/",
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteLaxMode,
+		})
+	}
+	return a.store.Set(r.Context(), session, backendURL, a.ttl)
+}
+
+// Touch extends a session that kept its backend
+func (a *Affinity) Touch(ctx context.Context, session, backendURL string) error {
+	return a.store.Set(ctx, session, backendURL, a.ttl)
+}
+
+func newSessionID() (string, error) {
+	buf := make([]byte, 16)
+	if _, err := rand.Read(buf); err != nil {
+		return "", fmt.Errorf("failed to create session ID: %w", err)
+	}
+	return hex.EncodeToString(buf), nil
+}
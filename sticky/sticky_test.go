@@ -0,0 +1,212 @@
+package sticky
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestMemoryStore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expected unknown session to be missing")
+	}
+	s.Set(ctx, "a", "http://backend1:80", time.Minute)
+	if got, ok, _ := s.Get(ctx, "a"); !ok || got != "http://backend1:80" {
+		t.Errorf("Expected http://backend1:80, got %q (%v)", got, ok)
+	}
+
+	s.Set(ctx, "b", "http://backend2:80", -time.Second)
+	if _, ok, _ := s.Get(ctx, "b"); ok {
+		t.Error("Expected expired session to be missing")
+	}
+
+	s.Delete(ctx, "a")
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expected deleted session to be missing")
+	}
+}
+
+func TestMemoryStore_SessionsRestore(t *testing.T) {
+	ctx := context.Background()
+	s := NewMemoryStore()
+	s.Set(ctx, "b", "http://backend2:80", time.Minute)
+	s.Set(ctx, "a", "http://backend1:80", time.Minute)
+	s.Set(ctx, "expired", "http://backend1:80", -time.Second)
+
+	saved := s.Sessions()
+	if len(saved) != 2 || saved[0].ID != "a" || saved[1].ID != "b" {
+		t.Fatalf("Expected sessions a and b, got %+v", saved)
+	}
+
+	restarted := NewMemoryStore()
+	restarted.Restore(append(saved, Session{ID: "old", Backend: "http://backend1:80", Expires: time.Now().Add(-time.Second)}))
+	if got, ok, _ := restarted.Get(ctx, "b"); !ok || got != "http://backend2:80" {
+		t.Errorf("Expected b restored to http://backend2:80, got %q (%v)", got, ok)
+	}
+	if restarted.Len() != 2 {
+		t.Errorf("Expected only unexpired sessions restored, got %d", restarted.Len())
+	}
+}
+
+// fakeRedis serves GET, SET, DEL and AUTH from a map
+type fakeRedis struct {
+	mu       sync.Mutex
+	data     map[string]string
+	ttls     map[string]string
+	password string
+}
+
+func startFakeRedis(t *testing.T, password string) (*fakeRedis, string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	f := &fakeRedis{data: make(map[string]string), ttls: make(map[string]string), password: password}
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, ln.Addr().String()
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+	authed := f.password == ""
+	for {
+		args, err := readCommand(r)
+		if err != nil {
+			return
+		}
+		f.mu.Lock()
+		var reply string
+		switch cmd := strings.ToUpper(args[0]); {
+		case cmd == "AUTH":
+			if args[1] == f.password {
+				authed = true
+				reply = "+OK\r\n"
+			} else {
+				reply = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			reply = "-NOAUTH Authentication required\r\n"
+		case cmd == "GET":
+			if v, ok := f.data[args[1]]; ok {
+				reply = fmt.Sprintf("$%d\r\n%s\r\n", len(v), v)
+			} else {
+				reply = "$-1\r\n"
+			}
+		case cmd == "SET":
+			f.data[args[1]] = args[2]
+			f.ttls[args[1]] = args[4]
+			reply = "+OK\r\n"
+		case cmd == "DEL":
+			delete(f.data, args[1])
+			reply = ":1\r\n"
+		default:
+			reply = "-ERR unknown command\r\n"
+		}
+		f.mu.Unlock()
+		io.WriteString(conn, reply)
+	}
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+	n, err := strconv.Atoi(strings.TrimSpace(line[1:]))
+	if err != nil {
+		return nil, err
+	}
+	args := make([]string, n)
+	for i := range args {
+		if _, err := r.ReadString('\n'); err != nil {
+			return nil, err
+		}
+		arg, err := r.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		args[i] = strings.TrimSuffix(arg, "\r\n")
+	}
+	return args, nil
+}
+
+func TestRedisStore(t *testing.T) {
+	ctx := context.Background()
+	fake, addr := startFakeRedis(t, "secret")
+	s, err := NewRedisStore(RedisConfig{Addr: addr, Password: "secret"})
+	if err != nil {
+		t.Fatalf("Failed to create store: %v", err)
+	}
+
+	if _, ok, err := s.Get(ctx, "a"); ok || err != nil {
+		t.Errorf("Expected unknown session to be missing, got %v (%v)", ok, err)
+	}
+	if err := s.Set(ctx, "a", "http://backend1:80", 90*time.Second); err != nil {
+		t.Fatalf("Failed to set session: %v", err)
+	}
+	if got, ok, err := s.Get(ctx, "a"); !ok || err != nil || got != "http://backend1:80" {
+		t.Errorf("Expected http://backend1:80, got %q (%v, %v)", got, ok, err)
+	}
+	fake.mu.Lock()
+	ttl := fake.ttls[DefaultRedisPrefix+"a"]
+	fake.mu.Unlock()
+	if ttl != "90000" {
+		t.Errorf("Expected PX 90000, got %s", ttl)
+	}
+	if err := s.Delete(ctx, "a"); err != nil {
+		t.Fatalf("Failed to delete session: %v", err)
+	}
+	if _, ok, _ := s.Get(ctx, "a"); ok {
+		t.Error("Expected deleted session to be missing")
+	}
+
+	wrong, _ := NewRedisStore(RedisConfig{Addr: addr, Password: "wrong"})
+	if _, _, err := wrong.Get(ctx, "a"); err == nil || !strings.Contains(err.Error(), "AUTH") {
+		t.Errorf("Expected AUTH error, got %v", err)
+	}
+}
+
+func TestNew(t *testing.T) {
+	tests := []struct {
+		name    string
+		cfg     Config
+		wantErr bool
+	}{
+		{"memory", Config{Store: StoreMemory}, false},
+		{"redis", Config{Store: StoreRedis, Redis: RedisConfig{Addr: "localhost:6379"}}, false},
+		{"redis without addr", Config{Store: StoreRedis}, true},
+		{"unknown", Config{Store: "memcached"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := New(tt.cfg)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if err == nil && a.cookie != DefaultCookie {
+				t.Errorf("Expected cookie %s, got %s", DefaultCookie, a.cookie)
+			}
+		})
+	}
+}
@@ -1,9 +1,21 @@
 package strategy
 
+// Names selecting the built-in strategies in the registry (-strategy,
+// strategy.type in the config file)
+const (
+	RoundRobinName       = "roundrobin"
+	LeastConnectionsName = "leastconnections"
+	RandomName           = "random"
+	WeightedName         = "weighted"
+	FailoverName         = "failover"
+)
+
+// Names the built-in strategies report through Strategy.Name
 const (
 	RoundRobinStrategy         = "RoundRobin"
 	WeightedRoundRobinStrategy = "WeightedRoundRobin"
 	LeastConnectionsStrategy   = "LeastConnections"
 	RandomStrategy             = "Random"
 	IPHashStrategy             = "IPHash"
+	FailoverStrategy           = "Failover"
 )
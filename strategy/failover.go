@@ -0,0 +1,95 @@
+package strategy
+
+import (
+	"slices"
+	"sync/atomic"
+
+	"github.com/TaiTitans/go-balancer/backend"
+)
+
+// Failover sends every request to the lowest priority tier that has an
+// available backend, so standby tiers (e.g. another datacenter) only serve
+// while all preferred ones are down, draining or full. Backends set their
+// tier with backend.Config.Priority.
+type Failover struct {
+	tier  Factory
+	table atomic.Pointer[tierTable]
+}
+
+// tierTable groups a slice of backends by priority, each tier with its own
+// strategy instance so their state (e.g. a round-robin position) survives
+// switching tiers. It is rebuilt when the slice or backend.Generation
+// changes.
+type tierTable struct {
+	array      **backend.Backend // &backends[0] of the list it was built for
+	length     int
+	generation uint64
+	tiers      [][]*backend.Backend // by ascending priority
+	strategies []Strategy
+}
+
+// NewFailover creates a failover strategy choosing within a tier with
+// strategies made by tier (round robin when nil)
+func NewFailover(tier Factory) *Failover {
+	if tier == nil {
+		tier = func() Strategy { return NewRoundRobin() }
+	}
+	return &Failover{tier: tier}
+}
+
+// SelectBackend selects a backend from the first tier able to take the
+// request
+func (f *Failover) SelectBackend(backends []*backend.Backend) *backend.Backend {
+	if len(backends) == 0 {
+		return nil
+	}
+
+	t := f.tiers(backends)
+	for i, tier := range t.tiers {
+		if b := t.strategies[i].SelectBackend(tier); b != nil {
+			return b
+		}
+	}
+	return nil
+}
+
+// tiers returns the table for backends, building it if the cached one is
+// stale; like eligible, it compares the list by identity, so a replaced
+// list with the same first backend and length is regrouped
+func (f *Failover) tiers(backends []*backend.Backend) *tierTable {
+	generation := backend.Generation()
+	if t := f.table.Load(); t != nil && t.array == &backends[0] && t.length == len(backends) && t.generation == generation {
+		return t
+	}
+
+	sorted := slices.Clone(backends)
+	slices.SortStableFunc(sorted, func(a, b *backend.Backend) int {
+		return a.Priority() - b.Priority()
+	})
+	t := &tierTable{array: &backends[0], length: len(backends), generation: generation}
+	for start := 0; start < len(sorted); {
+		end := start + 1
+		for end < len(sorted) && sorted[end].Priority() == sorted[start].Priority() {
+			end++
+		}
+		t.tiers = append(t.tiers, sorted[start:end:end])
+		start = end
+	}
+
+	// Keep the strategies of an unchanged tier layout
+	if prev := f.table.Load(); prev != nil && len(prev.strategies) == len(t.tiers) {
+		t.strategies = prev.strategies
+	} else {
+		t.strategies = make([]Strategy, len(t.tiers))
+		for i := range t.strategies {
+			t.strategies[i] = f.tier()
+		}
+	}
+	f.table.Store(t)
+	return t
+}
+
+// Name returns the strategy name
+func (f *Failover) Name() string {
+	return FailoverStrategy
+}
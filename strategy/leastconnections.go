@@ -1,38 +1,64 @@
 package strategy
 
 import (
+	"sync/atomic"
+
 	"github.com/TaiTitans/go-balancer/backend"
 )
 
 // LeastConnections implements least connections load balancing strategy
-type LeastConnections struct{}
+type LeastConnections struct {
+	// next rotates through backends tied for the fewest connections
+	next  uint64
+	table atomic.Pointer[eligibleTable]
+}
 
 // NewLeastConnections creates a new least connections strategy
 func NewLeastConnections() *LeastConnections {
 	return &LeastConnections{}
 }
 
-// SelectBackend selects the backend with the least active connections
+// SelectBackend selects the backend with the least active connections, or
+// nil if none is available. Backends tied for the fewest connections take
+// turns. Unlike the other strategies it compares every eligible backend.
 func (lc *LeastConnections) SelectBackend(backends []*backend.Backend) *backend.Backend {
 	if len(backends) == 0 {
 		return nil
 	}
 
-	selected := &backend.Backend{}
-	minConnections := -1
+	t := eligible(&lc.table, backends)
+	var selected *backend.Backend
+	minConnections, ties := 0, 0
 
-	for _, b := range backends {
-		if !b.IsAlive() {
+	for _, b := range t.backends {
+		if !b.HasCapacity() {
 			continue
 		}
 
-		connections := b.GetConnections()
-		if minConnections == -1 || connections < minConnections {
-			minConnections = connections
+		connections := b.InFlight()
+		switch {
+		case selected == nil || connections < minConnections:
+			minConnections, ties = connections, 1
 			selected = b
+		case connections == minConnections:
+			ties++
 		}
 	}
+	if ties <= 1 {
+		return selected
+	}
 
+	// Pick the n-th of the tied backends; if counts moved in the meantime,
+	// keep the first one found
+	n := int((atomic.AddUint64(&lc.next, 1) - 1) % uint64(ties))
+	for _, b := range t.backends {
+		if b.HasCapacity() && b.InFlight() == minConnections {
+			if n == 0 {
+				return b
+			}
+			n--
+		}
+	}
 	return selected
 }
 
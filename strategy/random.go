@@ -2,6 +2,7 @@ package strategy
 
 import (
 	"math/rand"
+	"sync/atomic"
 	"time"
 
 	"github.com/TaiTitans/go-balancer/backend"
@@ -9,7 +10,8 @@ import (
 
 // Random implements random load balancing strategy
 type Random struct {
-	rng *rand.Rand
+	rng   *rand.Rand
+	table atomic.Pointer[eligibleTable]
 }
 
 // NewRandom creates a new random strategy
@@ -25,21 +27,13 @@ func (r *Random) SelectBackend(backends []*backend.Backend) *backend.Backend {
 		return nil
 	}
 
-	// Find alive backends
-	aliveBackends := []*backend.Backend{}
-	for _, b := range backends {
-		if b.IsAlive() {
-			aliveBackends = append(aliveBackends, b)
-		}
-	}
-
-	if len(aliveBackends) == 0 {
+	t := eligible(&r.table, backends)
+	if len(t.backends) == 0 {
 		return nil
 	}
 
 	// Select random backend
-	idx := r.rng.Intn(len(aliveBackends))
-	return aliveBackends[idx]
+	return t.from(uint64(r.rng.Intn(len(t.backends))))
 }
 
 // Name returns the strategy name
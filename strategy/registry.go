@@ -0,0 +1,76 @@
+package strategy
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Factory creates a fresh instance of a strategy; every load balancer gets
+// its own
+type Factory func() Strategy
+
+var registry = struct {
+	sync.RWMutex
+	factories map[string]Factory
+}{factories: make(map[string]Factory)}
+
+func init() {
+	Register(RoundRobinName, func() Strategy { return NewRoundRobin() })
+	Register(LeastConnectionsName, func() Strategy { return NewLeastConnections() })
+	Register(RandomName, func() Strategy { return NewRandom() })
+	Register(WeightedName, func() Strategy { return NewWeightedRoundRobin(nil) })
+	Register(FailoverName, func() Strategy { return NewFailover(nil) })
+}
+
+// Register makes a strategy selectable by name, case-insensitively, in New
+// and therefore in -strategy and the config file. It is meant to be called
+// from an init function and panics if name is empty, factory is nil or the
+// name is already taken.
+func Register(name string, factory Factory) {
+	key := strings.ToLower(strings.TrimSpace(name))
+	if key == "" {
+		panic("strategy: Register with an empty name")
+	}
+	if factory == nil {
+		panic("strategy: Register " + name + " with a nil factory")
+	}
+	registry.Lock()
+	defer registry.Unlock()
+	if _, dup := registry.factories[key]; dup {
+		panic("strategy: Register called twice for " + key)
+	}
+	registry.factories[key] = factory
+}
+
+// New creates the strategy registered under name
+func New(name string) (Strategy, error) {
+	registry.RLock()
+	factory, ok := registry.factories[strings.ToLower(strings.TrimSpace(name))]
+	registry.RUnlock()
+	if !ok {
+		return nil, fmt.Errorf("unknown strategy %q (valid: %s)", name, strings.Join(Names(), ", "))
+	}
+	return factory(), nil
+}
+
+// Registered reports whether a strategy is registered under name
+func Registered(name string) bool {
+	registry.RLock()
+	defer registry.RUnlock()
+	_, ok := registry.factories[strings.ToLower(strings.TrimSpace(name))]
+	return ok
+}
+
+// Names returns the registered strategy names, sorted
+func Names() []string {
+	registry.RLock()
+	defer registry.RUnlock()
+	names := make([]string, 0, len(registry.factories))
+	for name := range registry.factories {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
@@ -9,6 +9,7 @@ import (
 // RoundRobin implements round-robin load balancing strategy
 type RoundRobin struct {
 	current uint64
+	table   atomic.Pointer[eligibleTable]
 }
 
 // NewRoundRobin creates a new round-robin strategy
@@ -22,21 +23,13 @@ func (rr *RoundRobin) SelectBackend(backends []*backend.Backend) *backend.Backen
 		return nil
 	}
 
-	// Find alive backends
-	aliveBackends := []*backend.Backend{}
-	for _, b := range backends {
-		if b.IsAlive() {
-			aliveBackends = append(aliveBackends, b)
-		}
-	}
-
-	if len(aliveBackends) == 0 {
+	t := eligible(&rr.table, backends)
+	if len(t.backends) == 0 {
 		return nil
 	}
 
-	// Get next backend using atomic operation
-	next := atomic.AddUint64(&rr.current, 1)
-	return aliveBackends[(int(next)-1)%len(aliveBackends)]
+	// Get next backend using atomic operation; a full one passes its turn on
+	return t.from(atomic.AddUint64(&rr.current, 1) - 1)
 }
 
 // Name returns the strategy name
@@ -1,6 +1,8 @@
 package strategy
 
 import (
+	"sync/atomic"
+
 	"github.com/TaiTitans/go-balancer/backend"
 )
 
@@ -11,3 +13,78 @@ type Strategy interface {
 	// Name returns the name of the strategy
 	Name() string
 }
+
+// countAvailable returns how many of backends can take a request
+func countAvailable(backends []*backend.Backend) int {
+	n := 0
+	for _, b := range backends {
+		if b.IsAvailable() {
+			n++
+		}
+	}
+	return n
+}
+
+// nthAvailable returns the n-th (0-based) available backend; if backends
+// became unavailable since they were counted it falls back to the first
+// available one
+func nthAvailable(backends []*backend.Backend, n int) *backend.Backend {
+	var first *backend.Backend
+	for _, b := range backends {
+		if !b.IsAvailable() {
+			continue
+		}
+		if n == 0 {
+			return b
+		}
+		if first == nil {
+			first = b
+		}
+		n--
+	}
+	return first
+}
+
+// eligibleTable lists the backends of a slice that are alive, enabled and
+// not draining, so selection only checks connection limits per request. It
+// is rebuilt when the slice or backend.Generation changes. WeightedRoundRobin
+// lists a backend once per slot it owns.
+type eligibleTable struct {
+	array      **backend.Backend // &backends[0] of the list it was built for
+	length     int
+	generation uint64
+	backends   []*backend.Backend
+}
+
+// eligible returns the table for backends from cache, building it if the
+// cached one is stale; backends must not be empty. The slice is compared by
+// identity, not by members: a replaced list is a new array even when its
+// length and first backend match, and the table keeps the old one
+// reachable so its address cannot be reused.
+func eligible(cache *atomic.Pointer[eligibleTable], backends []*backend.Backend) *eligibleTable {
+	generation := backend.Generation()
+	if t := cache.Load(); t != nil && t.array == &backends[0] && t.length == len(backends) && t.generation == generation {
+		return t
+	}
+
+	t := &eligibleTable{array: &backends[0], length: len(backends), generation: generation}
+	for _, b := range backends {
+		if b.IsAlive() && !b.IsDraining() && !b.IsDisabled() && !b.IsEjected() {
+			t.backends = append(t.backends, b)
+		}
+	}
+	cache.Store(t)
+	return t
+}
+
+// from returns the first backend with capacity at or after position start,
+// wrapping around, or nil if all are full
+func (t *eligibleTable) from(start uint64) *backend.Backend {
+	n := uint64(len(t.backends))
+	for i := range n {
+		if b := t.backends[(start+i)%n]; b.HasCapacity() {
+			return b
+		}
+	}
+	return nil
+}
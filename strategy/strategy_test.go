@@ -1,6 +1,9 @@
 package strategy
 
 import (
+	"slices"
+	"strconv"
+	"strings"
 	"testing"
 
 	"github.com/TaiTitans/go-balancer/backend"
@@ -64,6 +67,53 @@ func TestRoundRobin_DeadBackends(t *testing.T) {
 	}
 }
 
+func TestRoundRobin_SkipsUnavailable(t *testing.T) {
+	strategy := NewRoundRobin()
+	backends := createTestBackends(3)
+	backends[1].SetAlive(false)
+
+	selected := make(map[*backend.Backend]int)
+	for i := 0; i < 10; i++ {
+		selected[strategy.SelectBackend(backends)]++
+	}
+	if selected[backends[0]] != 5 || selected[backends[2]] != 5 || selected[backends[1]] != 0 {
+		t.Errorf("Expected the alive backends to share evenly, got %d/%d/%d", selected[backends[0]], selected[backends[1]], selected[backends[2]])
+	}
+
+	// A full backend passes its turn on
+	full, _ := backend.NewBackendWithConfig(backend.Config{URL: "http://localhost:8089", MaxConnections: 1})
+	full.TryAcquire()
+	backends = append(backends, full)
+	for i := 0; i < 10; i++ {
+		if b := strategy.SelectBackend(backends); b == nil || b == full || b == backends[1] {
+			t.Fatalf("Expected an available backend, got %v", b)
+		}
+	}
+}
+
+func TestEligible_ReplacedList(t *testing.T) {
+	backends := createTestBackends(4)
+	for _, s := range []Strategy{NewRoundRobin(), NewLeastConnections()} {
+		// Same length and first backend, another member in place of the last
+		before := []*backend.Backend{backends[0], backends[1], backends[2]}
+		after := []*backend.Backend{backends[0], backends[1], backends[3]}
+		for range 3 {
+			s.SelectBackend(before)
+		}
+		backends[0].IncrementConnections()
+		backends[1].IncrementConnections()
+		selected := make(map[*backend.Backend]int)
+		for range 6 {
+			selected[s.SelectBackend(after)]++
+		}
+		backends[0].DecrementConnections()
+		backends[1].DecrementConnections()
+		if selected[backends[2]] != 0 || selected[backends[3]] == 0 {
+			t.Errorf("%s: expected the replaced list to be used, got %v", s.Name(), selected)
+		}
+	}
+}
+
 func TestLeastConnections(t *testing.T) {
 	strategy := NewLeastConnections()
 	backends := createTestBackends(3)
@@ -91,6 +141,36 @@ func TestLeastConnections_EmptyBackends(t *testing.T) {
 	}
 }
 
+func TestLeastConnections_NoneAvailable(t *testing.T) {
+	strategy := NewLeastConnections()
+	backends := createTestBackends(2)
+	for _, b := range backends {
+		b.SetAlive(false)
+	}
+	if b := strategy.SelectBackend(backends); b != nil {
+		t.Errorf("Expected nil when no backend is available, got %s", b.GetURL())
+	}
+}
+
+func TestLeastConnections_TieBreaking(t *testing.T) {
+	strategy := NewLeastConnections()
+	backends := createTestBackends(4)
+	backends[3].IncrementConnections()
+
+	counts := make(map[*backend.Backend]int)
+	for i := 0; i < 300; i++ {
+		counts[strategy.SelectBackend(backends)]++
+	}
+	for i, b := range backends[:3] {
+		if counts[b] != 100 {
+			t.Errorf("Expected tied backend %d to be selected 100 times, got %d", i, counts[b])
+		}
+	}
+	if counts[backends[3]] != 0 {
+		t.Errorf("Expected busier backend not to be selected, got %d", counts[backends[3]])
+	}
+}
+
 func TestRandom(t *testing.T) {
 	strategy := NewRandom()
 	backends := createTestBackends(3)
@@ -122,3 +202,250 @@ func TestRandom_EmptyBackends(t *testing.T) {
 		t.Error("SelectBackend should return nil for empty backends")
 	}
 }
+
+func TestWeightedRoundRobin(t *testing.T) {
+	backends := createTestBackends(3)
+	backends[0].SetWeight(3)
+	backends[2].SetAlive(false)
+	strategy := NewWeightedRoundRobin(map[*backend.Backend]int{backends[1]: 2})
+
+	selected := make(map[*backend.Backend]int)
+	for i := 0; i < 10; i++ {
+		selected[strategy.SelectBackend(backends)]++
+	}
+	if selected[backends[0]] != 6 || selected[backends[1]] != 4 || selected[backends[2]] != 0 {
+		t.Errorf("Expected a 6/4/0 split, got %d/%d/%d", selected[backends[0]], selected[backends[1]], selected[backends[2]])
+	}
+
+	backends[0].SetAlive(false)
+	backends[1].SetAlive(false)
+	if b := strategy.SelectBackend(backends); b != nil {
+		t.Errorf("Expected nil with no alive backends, got %s", b.GetURL())
+	}
+}
+
+func TestWeightedRoundRobin_Smooth(t *testing.T) {
+	backends := createTestBackends(3)
+	backends[0].SetWeight(5)
+	strategy := NewWeightedRoundRobin(nil)
+
+	// The light backends' turns fall amid the heavy backend's, not after a
+	// run of five
+	want := []*backend.Backend{backends[0], backends[0], backends[0], backends[1], backends[2], backends[0], backends[0]}
+	for round := range 2 {
+		for i, w := range want {
+			if b := strategy.SelectBackend(backends); b != w {
+				t.Fatalf("Round %d, pick %d: expected %s, got %v", round, i, w.GetURL(), b)
+			}
+		}
+	}
+
+	// A backend at its connection limit sits its turns out
+	full, _ := backend.NewBackendWithConfig(backend.Config{URL: "http://localhost:9000", Weight: 5, MaxConnections: 1})
+	full.SetAlive(true)
+	full.IncrementConnections()
+	pool := []*backend.Backend{full, backends[1]}
+	for range 3 {
+		if b := strategy.SelectBackend(pool); b != backends[1] {
+			t.Fatalf("Expected the backend with capacity, got %v", b)
+		}
+	}
+}
+
+func TestWeightedRoundRobin_TableRebuilt(t *testing.T) {
+	backends := createTestBackends(2)
+	backends[1].SetAlive(false)
+	strategy := NewWeightedRoundRobin(nil)
+
+	count := func() map[*backend.Backend]int {
+		selected := make(map[*backend.Backend]int)
+		for i := 0; i < 12; i++ {
+			selected[strategy.SelectBackend(backends)]++
+		}
+		return selected
+	}
+	if selected := count(); selected[backends[0]] != 12 {
+		t.Errorf("Expected only the live backend, got %d/%d", selected[backends[0]], selected[backends[1]])
+	}
+
+	backends[1].SetAlive(true)
+	backends[1].SetWeight(2)
+	if selected := count(); selected[backends[0]] != 4 || selected[backends[1]] != 8 {
+		t.Errorf("Expected a 4/8 split after the revival and weight change, got %d/%d", selected[backends[0]], selected[backends[1]])
+	}
+}
+
+func TestWeightedRoundRobin_TableSize(t *testing.T) {
+	backends := createTestBackends(2)
+
+	// Weights with a common divisor take as many slots as their ratio
+	strategy := NewWeightedRoundRobin(map[*backend.Backend]int{backends[0]: 3000, backends[1]: 2000})
+	if n := len(strategy.tableFor(backends).backends); n != 5 {
+		t.Errorf("Expected 5 slots for a 3:2 ratio, got %d", n)
+	}
+
+	// Others are scaled down, keeping a slot for the lightest backend
+	strategy = NewWeightedRoundRobin(map[*backend.Backend]int{backends[0]: 1000000, backends[1]: 1})
+	table := strategy.tableFor(backends).backends
+	if len(table) > 2*wrrSlotsPerBackend+2 || !slices.Contains(table, backends[1]) {
+		t.Errorf("Expected at most %d slots including the light backend, got %d", 2*wrrSlotsPerBackend+2, len(table))
+	}
+}
+
+func TestWeightedRoundRobin_ReplacedList(t *testing.T) {
+	backends := createTestBackends(4)
+	strategy := NewWeightedRoundRobin(nil)
+	for range 3 {
+		strategy.SelectBackend([]*backend.Backend{backends[0], backends[1], backends[2]})
+	}
+
+	// Same length and first backend, another member in place of the last
+	after := []*backend.Backend{backends[0], backends[1], backends[3]}
+	selected := make(map[*backend.Backend]int)
+	for range 6 {
+		selected[strategy.SelectBackend(after)]++
+	}
+	if selected[backends[2]] != 0 || selected[backends[3]] != 2 {
+		t.Errorf("Expected the replaced list to be used, got %v", selected)
+	}
+}
+
+func BenchmarkWeightedRoundRobin(b *testing.B) {
+	for _, n := range []int{4, 64} {
+		backends := make([]*backend.Backend, n)
+		for i := range backends {
+			backends[i], _ = backend.NewBackendWithConfig(backend.Config{URL: "http://localhost:8080", Weight: 1 + i%10})
+		}
+		strategy := NewWeightedRoundRobin(nil)
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for b.Loop() {
+				strategy.SelectBackend(backends)
+			}
+		})
+	}
+}
+
+func TestFailover(t *testing.T) {
+	var backends []*backend.Backend
+	for i, priority := range []int{2, 1, 1, 2} {
+		b, _ := backend.NewBackendWithConfig(backend.Config{URL: "http://localhost:808" + strconv.Itoa(i), Priority: priority})
+		backends = append(backends, b)
+	}
+	s := NewFailover(nil)
+	if s.Name() != FailoverStrategy {
+		t.Errorf("Expected strategy name %s, got %s", FailoverStrategy, s.Name())
+	}
+
+	// Only the first tier serves while any of it is available, in turns
+	counts := make(map[*backend.Backend]int)
+	for range 10 {
+		counts[s.SelectBackend(backends)]++
+	}
+	if counts[backends[1]] != 5 || counts[backends[2]] != 5 {
+		t.Errorf("Expected the first tier to share the requests, got %v", counts)
+	}
+	backends[1].SetAlive(false)
+	for range 3 {
+		if b := s.SelectBackend(backends); b != backends[2] {
+			t.Errorf("Expected the last healthy member of the first tier, got %s", b)
+		}
+	}
+
+	// The next tier takes over when the whole first tier is down
+	backends[2].SetAlive(false)
+	counts = make(map[*backend.Backend]int)
+	for range 4 {
+		counts[s.SelectBackend(backends)]++
+	}
+	if counts[backends[0]] != 2 || counts[backends[3]] != 2 {
+		t.Errorf("Expected the second tier to share the requests, got %v", counts)
+	}
+
+	backends[1].SetAlive(true)
+	if b := s.SelectBackend(backends); b != backends[1] {
+		t.Errorf("Expected the first tier back once it recovers, got %s", b)
+	}
+	backends[0].SetAlive(false)
+	backends[3].SetAlive(false)
+	backends[1].SetAlive(false)
+	if b := s.SelectBackend(backends); b != nil {
+		t.Errorf("Expected nil with every tier down, got %s", b)
+	}
+}
+
+func TestFailover_ReplacedList(t *testing.T) {
+	backends := createTestBackends(4)
+	s := NewFailover(nil)
+	for range 3 {
+		s.SelectBackend([]*backend.Backend{backends[0], backends[1], backends[2]})
+	}
+
+	// Same length and first backend, another member in place of the last
+	after := []*backend.Backend{backends[0], backends[1], backends[3]}
+	selected := make(map[*backend.Backend]int)
+	for range 6 {
+		selected[s.SelectBackend(after)]++
+	}
+	if selected[backends[2]] != 0 || selected[backends[3]] != 2 {
+		t.Errorf("Expected the replaced list to be used, got %v", selected)
+	}
+}
+
+func TestSelectBackend_NoAllocations(t *testing.T) {
+	backends := createTestBackends(4)
+	backends[1].SetAlive(false)
+	strategies := []Strategy{
+		NewRoundRobin(),
+		NewRandom(),
+		NewWeightedRoundRobin(nil),
+		NewIPHash(),
+		NewLeastConnections(),
+		NewFailover(nil),
+	}
+	for _, s := range strategies {
+		allocs := testing.AllocsPerRun(100, func() {
+			if s.SelectBackend(backends) == backends[1] {
+				t.Errorf("%s selected a dead backend", s.Name())
+			}
+		})
+		if allocs != 0 {
+			t.Errorf("Expected %s to select without allocating, got %v allocations", s.Name(), allocs)
+		}
+	}
+}
+
+func TestRegistry(t *testing.T) {
+	s, err := New("LeastConnections")
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	if s.Name() != LeastConnectionsStrategy {
+		t.Errorf("Expected %s, got %s", LeastConnectionsStrategy, s.Name())
+	}
+	a, _ := New(RoundRobinName)
+	b, _ := New(RoundRobinName)
+	if a == b {
+		t.Error("Expected a fresh instance per call")
+	}
+	if _, err := New("fastest"); err == nil || !strings.Contains(err.Error(), "roundrobin") {
+		t.Errorf("Expected an error listing the valid names, got %v", err)
+	}
+
+	Register("Test-First", func() Strategy { return NewIPHash() })
+	if !Registered("test-first") {
+		t.Error("Expected the registered strategy to be found case-insensitively")
+	}
+	if s, err := New("test-first"); err != nil || s.Name() != IPHashStrategy {
+		t.Errorf("Expected the registered strategy, got %v, %v", s, err)
+	}
+	if !slices.Contains(Names(), "test-first") || !slices.IsSorted(Names()) {
+		t.Errorf("Unexpected names %v", Names())
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected registering a taken name to panic")
+		}
+	}()
+	Register("roundrobin", func() Strategy { return NewRoundRobin() })
+}
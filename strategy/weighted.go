@@ -1,61 +1,120 @@
 package strategy
 
 import (
-	"math/rand"
+	"cmp"
+	"slices"
 	"sync/atomic"
-	"time"
 
 	"github.com/TaiTitans/go-balancer/backend"
 )
 
-// WeightedRoundRobin implements weighted round-robin load balancing strategy
+// WeightedRoundRobin implements weighted round-robin load balancing:
+// backends get turns in proportion to their weights, spread out rather than
+// in runs
 type WeightedRoundRobin struct {
 	current uint64
 	weights map[*backend.Backend]int
-	rng     *rand.Rand
+	// table caches the slots of the last backend list seen
+	table atomic.Pointer[eligibleTable]
 }
 
-// NewWeightedRoundRobin creates a new weighted round-robin strategy
+// wrrSlotsPerBackend bounds the slot table to this many slots per live
+// backend on average, whatever the weights add up to
+const wrrSlotsPerBackend = 16
+
+// NewWeightedRoundRobin creates a new weighted round-robin strategy; backends
+// missing from weights use their configured weight
 func NewWeightedRoundRobin(weights map[*backend.Backend]int) *WeightedRoundRobin {
-	return &WeightedRoundRobin{
-		current: 0,
-		weights: weights,
-		rng:     rand.New(rand.NewSource(time.Now().UnixNano())),
-	}
+	return &WeightedRoundRobin{current: 0, weights: weights}
 }
 
-// SelectBackend selects a backend based on weighted round-robin
+// SelectBackend selects a backend based on weighted round-robin in constant
+// time; a backend at its connection limit passes its turn on
 func (wrr *WeightedRoundRobin) SelectBackend(backends []*backend.Backend) *backend.Backend {
 	if len(backends) == 0 {
 		return nil
 	}
 
-	// Find alive backends with weights
-	weightedBackends := []*backend.Backend{}
-	totalWeight := 0
+	t := wrr.tableFor(backends)
+	if len(t.backends) == 0 {
+		return nil
+	}
+	return t.from(atomic.AddUint64(&wrr.current, 1) - 1)
+}
+
+// tableFor returns the slot table for backends, building it if the cached
+// one is stale; like eligible, it compares the list by identity. Each live
+// backend owns as many slots as its weight, reduced by the weights' common
+// divisor and scaled down past wrrSlotsPerBackend. Slot k of a backend with
+// weight w sits at (k+0.5)/w of the round, so a backend with weight 5 next
+// to two with weight 1 goes a a a b c a a.
+func (wrr *WeightedRoundRobin) tableFor(backends []*backend.Backend) *eligibleTable {
+	generation := backend.Generation()
+	if t := wrr.table.Load(); t != nil && t.array == &backends[0] && t.length == len(backends) && t.generation == generation {
+		return t
+	}
 
+	t := &eligibleTable{array: &backends[0], length: len(backends), generation: generation}
+	var live []*backend.Backend
+	var weights []int
+	divisor, total := 0, 0
 	for _, b := range backends {
-		if b.IsAlive() {
-			weight := 1
-			if w, ok := wrr.weights[b]; ok {
-				weight = w
-			}
-
-			// Add backend multiple times based on weight
-			for i := 0; i < weight; i++ {
-				weightedBackends = append(weightedBackends, b)
-				totalWeight++
-			}
+		weight := wrr.weightOf(b)
+		if weight <= 0 || !b.IsAlive() || b.IsDraining() || b.IsDisabled() || b.IsEjected() {
+			continue
+		}
+		live = append(live, b)
+		weights = append(weights, weight)
+		divisor = gcd(divisor, weight)
+		total += weight
+	}
+	if len(live) == 0 {
+		wrr.table.Store(t)
+		return t
+	}
+	if limit := wrrSlotsPerBackend * len(live); total/divisor > limit {
+		for i, w := range weights {
+			weights[i] = max(1, w*limit/total)
+		}
+	} else {
+		for i := range weights {
+			weights[i] /= divisor
 		}
 	}
 
-	if len(weightedBackends) == 0 {
-		return nil
+	type slot struct{ backend, k, weight int }
+	var slots []slot
+	for i, w := range weights {
+		for k := range w {
+			slots = append(slots, slot{i, k, w})
+		}
 	}
+	// (2a.k+1)/(2a.weight) < (2b.k+1)/(2b.weight), ties in list order
+	slices.SortStableFunc(slots, func(a, b slot) int {
+		return cmp.Compare((2*a.k+1)*b.weight, (2*b.k+1)*a.weight)
+	})
+	t.backends = make([]*backend.Backend, len(slots))
+	for i, s := range slots {
+		t.backends[i] = live[s.backend]
+	}
+	wrr.table.Store(t)
+	return t
+}
 
-	// Select using round-robin on weighted list
-	next := atomic.AddUint64(&wrr.current, 1)
-	return weightedBackends[(int(next)-1)%len(weightedBackends)]
+// gcd returns the greatest common divisor of a and b, b when a is 0
+func gcd(a, b int) int {
+	for a != 0 {
+		a, b = b%a, a
+	}
+	return b
+}
+
+// weightOf returns the weight of b, preferring the explicit weights map
+func (wrr *WeightedRoundRobin) weightOf(b *backend.Backend) int {
+	if w, ok := wrr.weights[b]; ok {
+		return w
+	}
+	return b.GetWeight()
 }
 
 // Name returns the strategy name
@@ -79,20 +138,9 @@ func (ih *IPHash) SelectBackend(backends []*backend.Backend) *backend.Backend {
 		return nil
 	}
 
-	aliveBackends := []*backend.Backend{}
-	for _, b := range backends {
-		if b.IsAlive() {
-			aliveBackends = append(aliveBackends, b)
-		}
-	}
-
-	if len(aliveBackends) == 0 {
-		return nil
-	}
-
 	// Simplified: just return first alive backend
 	// Real implementation would hash client IP
-	return aliveBackends[0]
+	return nthAvailable(backends, 0)
 }
 
 // Name returns the strategy name
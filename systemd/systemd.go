@@ -0,0 +1,98 @@
+// Package systemd implements socket activation and sd_notify without
+// depending on libsystemd
+package systemd
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Notification states understood by systemd (see sd_notify(3))
+const (
+	Ready     = "READY=1"
+	Stopping  = "STOPPING=1"
+	Reloading = "RELOADING=1"
+)
+
+// listenFDsStart is the first file descriptor passed by systemd
+const listenFDsStart = 3
+
+// Listeners returns the sockets passed by systemd socket activation, keyed
+// by their FileDescriptorName (the socket unit name when unset). It returns
+// nil when the process was not socket-activated. The LISTEN_* variables are
+// cleared so child processes don't inherit them
+func Listeners() (map[string][]net.Listener, error) {
+	count, names, err := passedFDs(os.Getenv("LISTEN_PID"), os.Getenv("LISTEN_FDS"), os.Getenv("LISTEN_FDNAMES"), os.Getpid())
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+	if err != nil || count == 0 {
+		return nil, err
+	}
+
+	listeners := make(map[string][]net.Listener, count)
+	for i := 0; i < count; i++ {
+		file := os.NewFile(uintptr(listenFDsStart+i), names[i])
+		l, err := net.FileListener(file)
+		file.Close()
+		if err != nil {
+			return nil, fmt.Errorf("systemd socket %q (fd %d): %w", names[i], listenFDsStart+i, err)
+		}
+		listeners[names[i]] = append(listeners[names[i]], l)
+	}
+	return listeners, nil
+}
+
+// passedFDs parses the LISTEN_* variables for process pid, returning the
+// number of passed descriptors and their names
+func passedFDs(listenPID, listenFDs, fdNames string, pid int) (int, []string, error) {
+	if listenPID == "" || listenFDs == "" {
+		return 0, nil, nil
+	}
+	if p, err := strconv.Atoi(listenPID); err != nil || p != pid {
+		// Meant for another process
+		return 0, nil, nil
+	}
+	count, err := strconv.Atoi(listenFDs)
+	if err != nil || count < 0 {
+		return 0, nil, fmt.Errorf("invalid LISTEN_FDS %q", listenFDs)
+	}
+
+	names := make([]string, count)
+	given := strings.Split(fdNames, ":")
+	for i := range names {
+		names[i] = "unknown"
+		if fdNames != "" && i < len(given) && given[i] != "" {
+			names[i] = given[i]
+		}
+	}
+	return count, names, nil
+}
+
+// Notify sends state to the service manager through $NOTIFY_SOCKET. It
+// reports false without error when not running under systemd (or with
+// Type= other than notify)
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	if strings.HasPrefix(socket, "@") {
+		// Abstract namespace socket
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify systemd: %w", err)
+	}
+	return true, nil
+}
@@ -0,0 +1,78 @@
+package systemd
+
+import (
+	"net"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestPassedFDs(t *testing.T) {
+	tests := []struct {
+		name      string
+		pid       string
+		fds       string
+		names     string
+		wantCount int
+		wantNames []string
+		wantErr   bool
+	}{
+		{"not activated", "", "", "", 0, nil, false},
+		{"other process", "1", "2", "", 0, nil, false},
+		{"unnamed", "42", "2", "", 2, []string{"unknown", "unknown"}, false},
+		{"named", "42", "2", "http:admin", 2, []string{"http", "admin"}, false},
+		{"fewer names", "42", "2", "http", 2, []string{"http", "unknown"}, false},
+		{"invalid count", "42", "two", "", 0, nil, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			count, names, err := passedFDs(tt.pid, tt.fds, tt.names, 42)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Expected error %v, got %v", tt.wantErr, err)
+			}
+			if count != tt.wantCount || !reflect.DeepEqual(names, tt.wantNames) {
+				t.Errorf("Expected %d %v, got %d %v", tt.wantCount, tt.wantNames, count, names)
+			}
+		})
+	}
+}
+
+func TestListeners_NotActivated(t *testing.T) {
+	t.Setenv("LISTEN_PID", "")
+	t.Setenv("LISTEN_FDS", "")
+	listeners, err := Listeners()
+	if err != nil || listeners != nil {
+		t.Errorf("Expected no listeners, got %v (%v)", listeners, err)
+	}
+}
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify(Ready); sent || err != nil {
+		t.Errorf("Expected no notification without NOTIFY_SOCKET, got %v (%v)", sent, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unixgram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+
+	t.Setenv("NOTIFY_SOCKET", path)
+	if sent, err := Notify(Ready); !sent || err != nil {
+		t.Fatalf("Expected the notification to be sent, got %v (%v)", sent, err)
+	}
+
+	buf := make([]byte, 64)
+	conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("Failed to read notification: %v", err)
+	}
+	if string(buf[:n]) != Ready {
+		t.Errorf("Expected %q, got %q", Ready, buf[:n])
+	}
+}
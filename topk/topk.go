@@ -0,0 +1,154 @@
+package topk
+
+import (
+	"hash/fnv"
+	"sort"
+	"sync"
+)
+
+// Default sketch dimensions: ~0.1% overestimation with 99.3% confidence
+const (
+	DefaultWidth = 2048
+	DefaultDepth = 5
+)
+
+// Sketch is a count-min sketch estimating per-key counts in fixed memory
+type Sketch struct {
+	width  uint64
+	depth  int
+	counts []uint64
+}
+
+// NewSketch creates a count-min sketch with depth rows of width counters
+func NewSketch(width, depth int) *Sketch {
+	if width <= 0 {
+		width = DefaultWidth
+	}
+	if depth <= 0 {
+		depth = DefaultDepth
+	}
+	return &Sketch{
+		width:  uint64(width),
+		depth:  depth,
+		counts: make([]uint64, width*depth),
+	}
+}
+
+// Add increments key by n and returns its new estimated count
+func (s *Sketch) Add(key string, n uint64) uint64 {
+	h1, h2 := hashes(key)
+	var min uint64
+	for i := 0; i < s.depth; i++ {
+		idx := uint64(i)*s.width + (h1+uint64(i)*h2)%s.width
+		s.counts[idx] += n
+		if i == 0 || s.counts[idx] < min {
+			min = s.counts[idx]
+		}
+	}
+	return min
+}
+
+// Estimate returns the estimated count for key (never an underestimate)
+func (s *Sketch) Estimate(key string) uint64 {
+	h1, h2 := hashes(key)
+	var min uint64
+	for i := 0; i < s.depth; i++ {
+		c := s.counts[uint64(i)*s.width+(h1+uint64(i)*h2)%s.width]
+		if i == 0 || c < min {
+			min = c
+		}
+	}
+	return min
+}
+
+// Reset zeroes every counter
+func (s *Sketch) Reset() {
+	clear(s.counts)
+}
+
+// hashes derives the two base hashes used for double hashing across rows
+func hashes(key string) (uint64, uint64) {
+	h := fnv.New64a()
+	h.Write([]byte(key))
+	sum := h.Sum64()
+	return sum, sum>>32 | 1
+}
+
+// Item is a key with its estimated count
+type Item struct {
+	Key   string `json:"key"`
+	Count uint64 `json:"count"`
+}
+
+// TopK tracks the approximate k most frequent keys
+type TopK struct {
+	mu     sync.Mutex
+	k      int
+	sketch *Sketch
+	items  map[string]uint64
+}
+
+// New creates a tracker keeping the k heaviest keys
+func New(k, width, depth int) *TopK {
+	if k <= 0 {
+		k = 10
+	}
+	return &TopK{
+		k:      k,
+		sketch: NewSketch(width, depth),
+		items:  make(map[string]uint64, k+1),
+	}
+}
+
+// Add counts one occurrence of key
+func (t *TopK) Add(key string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	est := t.sketch.Add(key, 1)
+	if _, ok := t.items[key]; ok || len(t.items) < t.k {
+		t.items[key] = est
+		return
+	}
+
+	// Replace the lightest tracked key if this one is now heavier
+	minKey, minCount := "", uint64(0)
+	for k, c := range t.items {
+		if minKey == "" || c < minCount {
+			minKey, minCount = k, c
+		}
+	}
+	if est > minCount {
+		delete(t.items, minKey)
+		t.items[key] = est
+	}
+}
+
+// Top returns up to n tracked keys ordered by descending count
+func (t *TopK) Top(n int) []Item {
+	t.mu.Lock()
+	items := make([]Item, 0, len(t.items))
+	for k, c := range t.items {
+		items = append(items, Item{Key: k, Count: c})
+	}
+	t.mu.Unlock()
+
+	sort.Slice(items, func(i, j int) bool {
+		if items[i].Count != items[j].Count {
+			return items[i].Count > items[j].Count
+		}
+		return items[i].Key < items[j].Key
+	})
+	if n > 0 && len(items) > n {
+		items = items[:n]
+	}
+	return items
+}
+
+// Reset forgets every key
+func (t *TopK) Reset() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.sketch.Reset()
+	t.items = make(map[string]uint64, t.k+1)
+}
@@ -0,0 +1,47 @@
+package topk
+
+import (
+	"fmt"
+	"testing"
+)
+
+func TestSketch_NeverUnderestimates(t *testing.T) {
+	s := NewSketch(64, 4)
+	for i := 0; i < 500; i++ {
+		s.Add(fmt.Sprintf("key-%d", i%50), 1)
+	}
+	for i := 0; i < 50; i++ {
+		if est := s.Estimate(fmt.Sprintf("key-%d", i)); est < 10 {
+			t.Errorf("Expected estimate >= 10 for key-%d, got %d", i, est)
+		}
+	}
+}
+
+func TestTopK_FindsHeavyHitters(t *testing.T) {
+	tk := New(3, 0, 0)
+	for i := 0; i < 1000; i++ {
+		tk.Add(fmt.Sprintf("noise-%d", i))
+		if i%2 == 0 {
+			tk.Add("hot")
+		}
+		if i%4 == 0 {
+			tk.Add("warm")
+		}
+	}
+
+	top := tk.Top(2)
+	if len(top) != 2 {
+		t.Fatalf("Expected 2 items, got %d", len(top))
+	}
+	if top[0].Key != "hot" || top[1].Key != "warm" {
+		t.Errorf("Expected [hot warm], got %v", top)
+	}
+	if top[0].Count < 500 {
+		t.Errorf("Expected hot count >= 500, got %d", top[0].Count)
+	}
+
+	tk.Reset()
+	if len(tk.Top(0)) != 0 {
+		t.Error("Expected no items after reset")
+	}
+}
@@ -0,0 +1,254 @@
+package tracing
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/TaiTitans/go-balancer/logging"
+)
+
+// Defaults of the OTLP exporter
+const (
+	DefaultServiceName   = "go-balancer"
+	DefaultBatchSize     = 512
+	DefaultFlushInterval = 5 * time.Second
+	// exportBuffer bounds the spans waiting for export; more are dropped
+	exportBuffer = 8192
+)
+
+// instrumentationScope names the spans' origin in OTLP
+const instrumentationScope = "github.com/TaiTitans/go-balancer"
+
+// validEndpoint checks that endpoint is an http(s) URL
+func validEndpoint(endpoint string) error {
+	u, err := url.Parse(endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("endpoint %q must be an http(s) URL", endpoint)
+	}
+	return nil
+}
+
+// OTLPExporter posts batches of spans to an OpenTelemetry collector using
+// OTLP/HTTP with JSON encoding
+type OTLPExporter struct {
+	endpoint      string
+	headers       map[string]string
+	resource      []byte
+	batchSize     int
+	flushInterval time.Duration
+	client        *http.Client
+	spans         chan *Span
+	done          chan struct{}
+	closeOnce     sync.Once
+	dropped       atomic.Int64
+}
+
+// NewOTLPExporter starts a background exporter posting to c.Endpoint
+func NewOTLPExporter(c Config) *OTLPExporter {
+	if c.ServiceName == "" {
+		c.ServiceName = DefaultServiceName
+	}
+	if c.BatchSize <= 0 {
+		c.BatchSize = DefaultBatchSize
+	}
+	if c.FlushInterval <= 0 {
+		c.FlushInterval = DefaultFlushInterval
+	}
+	resource, _ := json.Marshal(otlpResource{
+		Attributes: []otlpAttribute{otlpAttr(Attribute{"service.name", c.ServiceName})},
+	})
+
+	e := &OTLPExporter{
+		endpoint:      c.Endpoint,
+		headers:       c.Headers,
+		resource:      resource,
+		batchSize:     c.BatchSize,
+		flushInterval: c.FlushInterval,
+		client:        &http.Client{Timeout: 10 * time.Second},
+		spans:         make(chan *Span, exportBuffer),
+		done:          make(chan struct{}),
+	}
+	go e.run()
+	return e
+}
+
+// Export queues the span; it is dropped when the buffer is full
+func (e *OTLPExporter) Export(s *Span) {
+	select {
+	case e.spans <- s:
+	default:
+		e.dropped.Add(1)
+	}
+}
+
+// Dropped returns the number of spans dropped because the buffer was full
+func (e *OTLPExporter) Dropped() int64 {
+	return e.dropped.Load()
+}
+
+// Close exports the queued spans and stops the exporter
+func (e *OTLPExporter) Close() error {
+	e.closeOnce.Do(func() {
+		close(e.spans)
+		<-e.done
+	})
+	return nil
+}
+
+func (e *OTLPExporter) run() {
+	defer close(e.done)
+
+	ticker := time.NewTicker(e.flushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*Span, 0, e.batchSize)
+	for {
+		select {
+		case s, ok := <-e.spans:
+			if !ok {
+				e.send(batch)
+				return
+			}
+			batch = append(batch, s)
+			if len(batch) >= e.batchSize {
+				e.send(batch)
+				batch = batch[:0]
+			}
+		case <-ticker.C:
+			e.send(batch)
+			batch = batch[:0]
+		}
+	}
+}
+
+func (e *OTLPExporter) send(batch []*Span) {
+	if len(batch) == 0 {
+		return
+	}
+	body, err := e.encode(batch)
+	if err != nil {
+		logging.Logger().Warn("failed to encode spans", "spans", len(batch), "error", err)
+		return
+	}
+
+	req, err := http.NewRequest(http.MethodPost, e.endpoint, bytes.NewReader(body))
+	if err != nil {
+		logging.Logger().Warn("failed to export spans", "spans", len(batch), "error", err)
+		return
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range e.headers {
+		req.Header.Set(k, v)
+	}
+	resp, err := e.client.Do(req)
+	if err != nil {
+		logging.Logger().Warn("failed to export spans", "spans", len(batch), "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 300 {
+		logging.Logger().Warn("trace collector refused spans", "spans", len(batch), "status", resp.StatusCode)
+	}
+}
+
+// OTLP/JSON request body (ExportTraceServiceRequest); IDs are hex and
+// 64-bit integers are strings, as the protobuf JSON mapping requires
+type (
+	otlpRequest struct {
+		ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+	}
+	otlpResourceSpans struct {
+		Resource   json.RawMessage  `json:"resource"`
+		ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+	}
+	otlpResource struct {
+		Attributes []otlpAttribute `json:"attributes"`
+	}
+	otlpScopeSpans struct {
+		Scope struct {
+			Name string `json:"name"`
+		} `json:"scope"`
+		Spans []otlpSpan `json:"spans"`
+	}
+	otlpSpan struct {
+		TraceID           string          `json:"traceId"`
+		SpanID            string          `json:"spanId"`
+		ParentSpanID      string          `json:"parentSpanId,omitempty"`
+		Name              string          `json:"name"`
+		Kind              int             `json:"kind"`
+		StartTimeUnixNano string          `json:"startTimeUnixNano"`
+		EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+		Attributes        []otlpAttribute `json:"attributes,omitempty"`
+		Status            *otlpStatus     `json:"status,omitempty"`
+	}
+	otlpStatus struct {
+		Code    int    `json:"code"`
+		Message string `json:"message,omitempty"`
+	}
+	otlpAttribute struct {
+		Key   string         `json:"key"`
+		Value map[string]any `json:"value"`
+	}
+)
+
+// OTLP span kind and status codes
+const (
+	otlpKindServer  = 2
+	otlpStatusError = 2
+)
+
+func (e *OTLPExporter) encode(batch []*Span) ([]byte, error) {
+	scope := otlpScopeSpans{Spans: make([]otlpSpan, 0, len(batch))}
+	scope.Scope.Name = instrumentationScope
+	for _, s := range batch {
+		s.mu.Lock()
+		span := otlpSpan{
+			TraceID:           s.Context.TraceID,
+			SpanID:            s.Context.SpanID,
+			ParentSpanID:      s.ParentSpanID,
+			Name:              s.Name,
+			Kind:              otlpKindServer,
+			StartTimeUnixNano: strconv.FormatInt(s.StartTime.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(s.EndTime.UnixNano(), 10),
+		}
+		for _, a := range s.Attributes {
+			span.Attributes = append(span.Attributes, otlpAttr(a))
+		}
+		if s.Error != "" {
+			span.Status = &otlpStatus{Code: otlpStatusError, Message: s.Error}
+		}
+		s.mu.Unlock()
+		scope.Spans = append(scope.Spans, span)
+	}
+	return json.Marshal(otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   e.resource,
+		ScopeSpans: []otlpScopeSpans{scope},
+	}}})
+}
+
+// otlpAttr encodes an attribute as an OTLP AnyValue
+func otlpAttr(a Attribute) otlpAttribute {
+	var v map[string]any
+	switch x := a.Value.(type) {
+	case string:
+		v = map[string]any{"stringValue": x}
+	case bool:
+		v = map[string]any{"boolValue": x}
+	case int:
+		v = map[string]any{"intValue": strconv.Itoa(x)}
+	case int64:
+		v = map[string]any{"intValue": strconv.FormatInt(x, 10)}
+	case float64:
+		v = map[string]any{"doubleValue": x}
+	default:
+		v = map[string]any{"stringValue": fmt.Sprint(x)}
+	}
+	return otlpAttribute{Key: a.Key, Value: v}
+}
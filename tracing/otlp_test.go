@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOTLPExporter_Exports(t *testing.T) {
+	var body otlpRequest
+	var auth string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		auth = r.Header.Get("Authorization")
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("Failed to decode export: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	tracer, err := New(Config{Enabled: true, Endpoint: server.URL, Headers: map[string]string{"Authorization": "Bearer t"}})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	span := tracer.Start(httptest.NewRequest(http.MethodGet, "/", nil))
+	span.SetAttribute("http.response.status_code", 502)
+	span.SetError("Bad Gateway")
+	span.End()
+	tracer.Close()
+
+	if auth != "Bearer t" {
+		t.Errorf("Expected the configured headers, got Authorization %q", auth)
+	}
+	if len(body.ResourceSpans) != 1 || len(body.ResourceSpans[0].ScopeSpans) != 1 {
+		t.Fatalf("Unexpected export: %+v", body)
+	}
+	spans := body.ResourceSpans[0].ScopeSpans[0].Spans
+	if len(spans) != 1 {
+		t.Fatalf("Expected 1 span, got %d", len(spans))
+	}
+	got := spans[0]
+	if got.TraceID != span.Context.TraceID || got.SpanID != span.Context.SpanID || got.Kind != otlpKindServer {
+		t.Errorf("Unexpected span %+v", got)
+	}
+	if got.Status == nil || got.Status.Code != otlpStatusError {
+		t.Errorf("Expected an error status, got %+v", got.Status)
+	}
+	if len(got.Attributes) != 1 || got.Attributes[0].Value["intValue"] != "502" {
+		t.Errorf("Unexpected attributes %+v", got.Attributes)
+	}
+}
+
+func TestConfig_Validate(t *testing.T) {
+	if err := (Config{SampleRate: 1.5}).Validate(); err == nil {
+		t.Error("Expected a sample rate above 1 to be rejected")
+	}
+	if err := (Config{Endpoint: "collector:4318"}).Validate(); err == nil {
+		t.Error("Expected an endpoint without scheme to be rejected")
+	}
+	if tracer, err := New(Config{}); tracer != nil || err != nil {
+		t.Errorf("New(disabled) = %v, %v; want nil, nil", tracer, err)
+	}
+}
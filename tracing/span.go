@@ -0,0 +1,197 @@
+package tracing
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Config enables tracing of proxied requests
+type Config struct {
+	// Enabled starts a span per proxied request, continuing the incoming
+	// trace, and propagates it to the backend
+	Enabled bool `json:"enabled,omitempty"`
+	// Endpoint is the OTLP/HTTP traces URL of a collector (e.g.
+	// http://otel-collector:4318/v1/traces); without one spans are only
+	// propagated, not exported
+	Endpoint string `json:"endpoint,omitempty"`
+	// Headers are sent with every export, e.g. an API key
+	Headers map[string]string `json:"headers,omitempty"`
+	// ServiceName is the service.name of the spans (empty = DefaultServiceName)
+	ServiceName string `json:"serviceName,omitempty"`
+	// SampleRate is the share of new traces that are sampled (0 = all);
+	// incoming traces keep the sampling decision of their caller
+	SampleRate float64 `json:"sampleRate,omitempty"`
+	// BatchSize and FlushInterval bound how long finished spans wait
+	// before an export (0 = DefaultBatchSize, DefaultFlushInterval)
+	BatchSize     int           `json:"batchSize,omitempty"`
+	FlushInterval time.Duration `json:"flushInterval,omitempty"`
+}
+
+// Validate checks the sample rate, endpoint and batching settings
+func (c Config) Validate() error {
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sampleRate %v is out of range (0-1)", c.SampleRate)
+	}
+	if c.BatchSize < 0 || c.FlushInterval < 0 {
+		return fmt.Errorf("batchSize and flushInterval must not be negative")
+	}
+	if c.Endpoint != "" {
+		if err := validEndpoint(c.Endpoint); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Tracer starts spans for proxied requests and hands the sampled ones to
+// an exporter when they end
+type Tracer struct {
+	sampleRate float64
+	exporter   Exporter
+}
+
+// Exporter receives finished sampled spans
+type Exporter interface {
+	// Export queues a span; it must not block the request
+	Export(s *Span)
+	// Close sends the queued spans and stops the exporter
+	Close() error
+}
+
+// New returns the tracer described by c, exporting over OTLP when an
+// endpoint is set; it returns nil when tracing is disabled
+func New(c Config) (*Tracer, error) {
+	if !c.Enabled {
+		return nil, nil
+	}
+	if err := c.Validate(); err != nil {
+		return nil, err
+	}
+	var exporter Exporter
+	if c.Endpoint != "" {
+		exporter = NewOTLPExporter(c)
+	}
+	return NewTracer(c.SampleRate, exporter), nil
+}
+
+// NewTracer creates a tracer sampling sampleRate of the new traces (0 =
+// all); a nil exporter only propagates the trace context
+func NewTracer(sampleRate float64, exporter Exporter) *Tracer {
+	if sampleRate == 0 {
+		sampleRate = 1
+	}
+	return &Tracer{sampleRate: sampleRate, exporter: exporter}
+}
+
+// Close flushes the spans waiting for export
+func (t *Tracer) Close() error {
+	if t == nil || t.exporter == nil {
+		return nil
+	}
+	return t.exporter.Close()
+}
+
+// Start begins a server span for r, as a child of its traceparent when it
+// carries a valid one and as the root of a new trace otherwise
+func (t *Tracer) Start(r *http.Request) *Span {
+	s := &Span{
+		Name:      r.Method,
+		StartTime: time.Now(),
+		tracer:    t,
+	}
+	if parent, ok := ParseTraceparent(r.Header.Get(TraceparentHeader)); ok {
+		s.ParentSpanID = parent.SpanID
+		s.Context = SpanContext{TraceID: parent.TraceID, Sampled: parent.Sampled}
+	} else {
+		s.Context = SpanContext{TraceID: newID(16), Sampled: rand.Float64() < t.sampleRate}
+	}
+	s.Context.SpanID = newID(8)
+	return s
+}
+
+// Span is one proxied request within a trace
+type Span struct {
+	Context      SpanContext
+	ParentSpanID string // empty for the root of a trace
+	Name         string
+	StartTime    time.Time
+	EndTime      time.Time
+	Attributes   []Attribute
+	// Error describes why the request failed (empty when it didn't)
+	Error string
+
+	mu     sync.Mutex
+	tracer *Tracer
+}
+
+// Attribute is a key/value pair describing a span; values are strings,
+// integers, floats or booleans
+type Attribute struct {
+	Key   string
+	Value any
+}
+
+// SetAttribute adds or replaces an attribute
+func (s *Span) SetAttribute(key string, value any) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for i := range s.Attributes {
+		if s.Attributes[i].Key == key {
+			s.Attributes[i].Value = value
+			return
+		}
+	}
+	s.Attributes = append(s.Attributes, Attribute{key, value})
+}
+
+// SetError marks the span as failed
+func (s *Span) SetError(msg string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.Error = msg
+}
+
+// Inject returns r with a traceparent naming this span as the parent of the
+// backend's spans; r itself is not modified
+func (s *Span) Inject(r *http.Request) *http.Request {
+	out := r.Clone(r.Context())
+	out.Header.Set(TraceparentHeader, s.Context.Traceparent())
+	return out
+}
+
+// End finishes the span and exports it when sampled
+func (s *Span) End() {
+	s.mu.Lock()
+	s.EndTime = time.Now()
+	s.mu.Unlock()
+	if s.Context.Sampled && s.tracer.exporter != nil {
+		s.tracer.exporter.Export(s)
+	}
+}
+
+// Traceparent formats the span context as a W3C traceparent header value
+func (sc SpanContext) Traceparent() string {
+	flags := "00"
+	if sc.Sampled {
+		flags = "01"
+	}
+	return "00-" + sc.TraceID + "-" + sc.SpanID + "-" + flags
+}
+
+// newID returns n (8 or 16) random bytes in lowercase hex, never all zero
+func newID(n int) string {
+	var b [16]byte
+	for {
+		hi, lo := rand.Uint64(), rand.Uint64()
+		binary.BigEndian.PutUint64(b[:8], hi)
+		binary.BigEndian.PutUint64(b[8:], lo)
+		if hi != 0 || (n > 8 && lo != 0) {
+			return hex.EncodeToString(b[:n])
+		}
+	}
+}
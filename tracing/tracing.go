@@ -0,0 +1,84 @@
+package tracing
+
+import (
+	"net/http"
+	"strings"
+)
+
+// TraceparentHeader is the W3C Trace Context propagation header
+const TraceparentHeader = "traceparent"
+
+// SpanContext identifies a span within a trace
+type SpanContext struct {
+	TraceID string // 32 lowercase hex characters
+	SpanID  string // 16 lowercase hex characters
+	Sampled bool
+}
+
+// IsValid reports whether the span context carries non-zero IDs
+func (sc SpanContext) IsValid() bool {
+	return sc.TraceID != "" && sc.SpanID != ""
+}
+
+// ParseTraceparent parses a W3C traceparent header value
+// ("00-<trace-id>-<parent-id>-<flags>"); ok is false when it is malformed
+func ParseTraceparent(value string) (sc SpanContext, ok bool) {
+	parts := strings.Split(strings.TrimSpace(value), "-")
+	if len(parts) < 4 {
+		return SpanContext{}, false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+
+	if len(version) != 2 || !isHex(version) || version == "ff" {
+		return SpanContext{}, false
+	}
+	// Version 00 has exactly four fields; later versions may append more
+	if version == "00" && len(parts) != 4 {
+		return SpanContext{}, false
+	}
+	if len(traceID) != 32 || !isHex(traceID) || isZero(traceID) {
+		return SpanContext{}, false
+	}
+	if len(spanID) != 16 || !isHex(spanID) || isZero(spanID) {
+		return SpanContext{}, false
+	}
+	if len(flags) != 2 || !isHex(flags) {
+		return SpanContext{}, false
+	}
+
+	return SpanContext{
+		TraceID: traceID,
+		SpanID:  spanID,
+		Sampled: hexValue(flags[1])&0x1 == 1,
+	}, true
+}
+
+// TraceIDFromRequest returns the trace ID of a sampled incoming trace, or "" if there is none
+func TraceIDFromRequest(r *http.Request) string {
+	sc, ok := ParseTraceparent(r.Header.Get(TraceparentHeader))
+	if !ok || !sc.Sampled {
+		return ""
+	}
+	return sc.TraceID
+}
+
+func isHex(s string) bool {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func isZero(s string) bool {
+	return strings.Trim(s, "0") == ""
+}
+
+func hexValue(c byte) byte {
+	if c >= 'a' {
+		return c - 'a' + 10
+	}
+	return c - '0'
+}
@@ -0,0 +1,64 @@
+package tracing
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		ok      bool
+		sampled bool
+	}{
+		{"sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true, true},
+		{"not sampled", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-00", true, false},
+		{"future version with extra field", "01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-xyz", true, true},
+		{"empty", "", false, false},
+		{"uppercase hex", "00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false, false},
+		{"zero trace id", "00-00000000000000000000000000000000-00f067aa0ba902b7-01", false, false},
+		{"short span id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa-01", false, false},
+		{"invalid version", "ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sc, ok := ParseTraceparent(tt.value)
+			if ok != tt.ok {
+				t.Fatalf("ParseTraceparent() ok = %v, want %v", ok, tt.ok)
+			}
+			if ok && sc.Sampled != tt.sampled {
+				t.Errorf("Sampled = %v, want %v", sc.Sampled, tt.sampled)
+			}
+		})
+	}
+}
+
+func TestTracer_Start(t *testing.T) {
+	tracer := NewTracer(0, nil)
+
+	r := httptest.NewRequest(http.MethodGet, "/", nil)
+	r.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	span := tracer.Start(r)
+	if span.Context.TraceID != "4bf92f3577b34da6a3ce929d0e0e4736" || span.ParentSpanID != "00f067aa0ba902b7" {
+		t.Errorf("Expected the incoming trace to continue, got %+v (parent %s)", span.Context, span.ParentSpanID)
+	}
+	injected := span.Inject(r)
+	sc, ok := ParseTraceparent(injected.Header.Get(TraceparentHeader))
+	if !ok || sc != span.Context {
+		t.Errorf("Expected the span to be propagated, got %q", injected.Header.Get(TraceparentHeader))
+	}
+	if r.Header.Get(TraceparentHeader) != "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01" {
+		t.Error("Inject must not modify the incoming request")
+	}
+
+	root := tracer.Start(httptest.NewRequest(http.MethodGet, "/", nil))
+	if root.ParentSpanID != "" || !root.Context.Sampled {
+		t.Errorf("Expected a sampled root span, got %+v", root)
+	}
+	if _, ok := ParseTraceparent(root.Context.Traceparent()); !ok {
+		t.Errorf("Expected a valid traceparent, got %q", root.Context.Traceparent())
+	}
+}
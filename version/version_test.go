@@ -0,0 +1,40 @@
+package version
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestGet_UsesLinkerValues(t *testing.T) {
+	oldVersion, oldCommit, oldDate := Version, Commit, BuildDate
+	defer func() { Version, Commit, BuildDate = oldVersion, oldCommit, oldDate }()
+
+	Version, Commit, BuildDate = "v1.2.3", "0123456789abcdef", "2025-11-07T10:00:00Z"
+	info := Get()
+	if info.Version != "v1.2.3" || info.Commit != "0123456789abcdef" || info.BuildDate != "2025-11-07T10:00:00Z" {
+		t.Errorf("Unexpected info: %+v", info)
+	}
+	if info.GoVersion != runtime.Version() {
+		t.Errorf("Expected Go version %s, got %s", runtime.Version(), info.GoVersion)
+	}
+	if !strings.Contains(info.String(), "commit 0123456789ab") {
+		t.Errorf("Expected shortened commit in %q", info.String())
+	}
+}
+
+func TestHandler(t *testing.T) {
+	rr := httptest.NewRecorder()
+	Handler().ServeHTTP(rr, httptest.NewRequest(http.MethodGet, "/version", nil))
+
+	var info Info
+	if err := json.Unmarshal(rr.Body.Bytes(), &info); err != nil {
+		t.Fatalf("Failed to decode response: %v", err)
+	}
+	if info.Version == "" || info.Commit == "" || info.BuildDate == "" {
+		t.Errorf("Expected populated fields, got %+v", info)
+	}
+}
@@ -0,0 +1,27 @@
+// Package winsvc runs the balancer as a Windows service: it installs and
+// removes the service, answers the service control manager and writes the
+// log to the Windows event log. On other platforms every operation fails
+// with ErrUnsupported.
+package winsvc
+
+import (
+	"errors"
+	"strings"
+)
+
+// DefaultName is the service and event log source name
+const DefaultName = "go-balancer"
+
+// ErrUnsupported is returned on platforms without Windows services
+var ErrUnsupported = errors.New("windows services are not supported on this platform")
+
+// eventLevel picks the event type of a log line from its wording
+func eventLevel(line string) string {
+	switch {
+	case strings.HasPrefix(line, "Warning"):
+		return "warning"
+	case strings.HasPrefix(line, "Failed"), strings.Contains(line, " error: "):
+		return "error"
+	}
+	return "info"
+}
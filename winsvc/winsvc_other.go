@@ -0,0 +1,35 @@
+//go:build !windows
+
+package winsvc
+
+import "io"
+
+// Run is not supported on this platform
+func Run(name string, main func(stop <-chan struct{})) error {
+	return ErrUnsupported
+}
+
+// EventLog is not supported on this platform
+func EventLog(name string) (io.WriteCloser, error) {
+	return nil, ErrUnsupported
+}
+
+// Install is not supported on this platform
+func Install(name, exe string, args []string) error {
+	return ErrUnsupported
+}
+
+// Remove is not supported on this platform
+func Remove(name string) error {
+	return ErrUnsupported
+}
+
+// Start is not supported on this platform
+func Start(name string) error {
+	return ErrUnsupported
+}
+
+// Stop is not supported on this platform
+func Stop(name string) error {
+	return ErrUnsupported
+}
@@ -0,0 +1,20 @@
+package winsvc
+
+import "testing"
+
+func TestEventLevel(t *testing.T) {
+	tests := []struct {
+		line string
+		want string
+	}{
+		{"Starting load balancer with strategy: RoundRobin", "info"},
+		{"Warning: ignoring saved state: bad version", "warning"},
+		{"Failed to save state: disk full", "error"},
+		{"Server error: listener closed", "error"},
+	}
+	for _, tt := range tests {
+		if got := eventLevel(tt.line); got != tt.want {
+			t.Errorf("Expected %s for %q, got %s", tt.want, tt.line, got)
+		}
+	}
+}